@@ -50,6 +50,7 @@ func (src *Cluster) ConvertTo(dstRaw conversion.Hub) error {
 	dst.Spec.ControlPlaneRef = restored.Spec.ControlPlaneRef
 	dst.Status.ControlPlaneReady = restored.Status.ControlPlaneReady
 	dst.Status.FailureDomains = restored.Status.FailureDomains
+	dst.Status.Conditions = restored.Status.Conditions
 	dst.Spec.Paused = restored.Spec.Paused
 
 	return nil
@@ -115,6 +116,8 @@ func (src *Machine) ConvertTo(dstRaw conversion.Hub) error {
 		return err
 	}
 	restoreMachineSpec(&restored.Spec, &dst.Spec)
+	dst.Status.Interruptible = restored.Status.Interruptible
+	dst.Status.Conditions = restored.Status.Conditions
 
 	return nil
 }
@@ -125,6 +128,7 @@ func restoreMachineSpec(restored *v1alpha3.MachineSpec, dst *v1alpha3.MachineSpe
 	}
 	dst.Bootstrap.DataSecretName = restored.Bootstrap.DataSecretName
 	dst.FailureDomain = restored.FailureDomain
+	dst.NodeDrainTimeout = restored.NodeDrainTimeout
 }
 
 func (dst *Machine) ConvertFrom(srcRaw conversion.Hub) error {
@@ -241,6 +245,7 @@ func (src *MachineDeployment) ConvertTo(dstRaw conversion.Hub) error {
 	}
 	dst.Spec.Paused = restored.Spec.Paused
 	dst.Status.Phase = restored.Status.Phase
+	dst.Status.Conditions = restored.Status.Conditions
 	restoreMachineSpec(&restored.Spec.Template.Spec, &dst.Spec.Template.Spec)
 
 	return nil
@@ -18,6 +18,7 @@ package v1alpha2
 
 import (
 	"testing"
+	"time"
 
 	. "github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
@@ -76,6 +77,14 @@ func TestConvertCluster(t *testing.T) {
 				},
 				Status: v1alpha3.ClusterStatus{
 					ControlPlaneReady: true,
+					Conditions: v1alpha3.Conditions{
+						{
+							Type:     v1alpha3.ControlPlaneReadyCondition,
+							Status:   corev1.ConditionFalse,
+							Severity: v1alpha3.ConditionSeverityInfo,
+							Reason:   v1alpha3.WaitingForControlPlaneReason,
+						},
+					},
 				},
 			}
 			dst := &Cluster{}
@@ -88,6 +97,7 @@ func TestConvertCluster(t *testing.T) {
 			g.Expect(restored.Name).To(Equal(src.Name))
 			g.Expect(restored.Spec.ControlPlaneRef).To(Equal(src.Spec.ControlPlaneRef))
 			g.Expect(restored.Status.ControlPlaneReady).To(Equal(src.Status.ControlPlaneReady))
+			g.Expect(restored.Status.Conditions).To(Equal(src.Status.Conditions))
 		})
 
 		t.Run("should convert Spec.ControlPlaneEndpoint to Status.APIEndpoints[0]", func(t *testing.T) {
@@ -139,7 +149,17 @@ func TestConvertMachine(t *testing.T) {
 					Bootstrap: v1alpha3.Bootstrap{
 						DataSecretName: pointer.StringPtr("secret-data"),
 					},
-					FailureDomain: &failureDomain,
+					FailureDomain:    &failureDomain,
+					NodeDrainTimeout: &metav1.Duration{Duration: 10 * time.Second},
+				},
+				Status: v1alpha3.MachineStatus{
+					Interruptible: true,
+					Conditions: v1alpha3.Conditions{
+						{
+							Type:   v1alpha3.BootstrapReadyCondition,
+							Status: corev1.ConditionTrue,
+						},
+					},
 				},
 			}
 			dst := &Machine{}
@@ -153,6 +173,9 @@ func TestConvertMachine(t *testing.T) {
 			g.Expect(restored.Spec.Bootstrap.DataSecretName).To(Equal(src.Spec.Bootstrap.DataSecretName))
 			g.Expect(restored.Spec.ClusterName).To(Equal(src.Spec.ClusterName))
 			g.Expect(restored.Spec.FailureDomain).To(Equal(src.Spec.FailureDomain))
+			g.Expect(restored.Spec.NodeDrainTimeout).To(Equal(src.Spec.NodeDrainTimeout))
+			g.Expect(restored.Status.Interruptible).To(Equal(src.Status.Interruptible))
+			g.Expect(restored.Status.Conditions).To(Equal(src.Status.Conditions))
 		})
 	})
 }
@@ -124,6 +124,10 @@ type ClusterStatus struct {
 	// ControlPlaneReady defines if the control plane is ready.
 	// +optional
 	ControlPlaneReady bool `json:"controlPlaneReady,omitempty"`
+
+	// Conditions defines current service state of the cluster.
+	// +optional
+	Conditions Conditions `json:"conditions,omitempty"`
 }
 
 // ANCHOR_END: ClusterStatus
@@ -187,6 +191,16 @@ type Cluster struct {
 	Status ClusterStatus `json:"status,omitempty"`
 }
 
+// GetConditions returns the conditions of the Cluster.
+func (c *Cluster) GetConditions() Conditions {
+	return c.Status.Conditions
+}
+
+// SetConditions sets the conditions of the Cluster.
+func (c *Cluster) SetConditions(conditions Conditions) {
+	c.Status.Conditions = conditions
+}
+
 // +kubebuilder:object:root=true
 
 // ClusterList contains a list of Cluster
@@ -0,0 +1,140 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ClusterResourceSetFinalizer is added to the ClusterResourceSet object for additional cleanup logic on deletion.
+	ClusterResourceSetFinalizer = "clusterresourceset.cluster.x-k8s.io"
+
+	// ClusterResourceSetSecretType is the only accepted type of Secret in ClusterResourceSet resources, so that
+	// arbitrary Secrets, e.g. credentials, cannot be copied to the workload clusters.
+	ClusterResourceSetSecretType corev1.SecretType = "cluster.x-k8s.io/secret"
+)
+
+// ANCHOR: ClusterResourceSetSpec
+
+// ClusterResourceSetSpec defines the desired state of ClusterResourceSet
+type ClusterResourceSetSpec struct {
+	// Label selector for Clusters. The Clusters that are selected by this will be the ones affected by this
+	// ClusterResourceSet. An empty selector selects no Clusters.
+	ClusterSelector metav1.LabelSelector `json:"clusterSelector"`
+
+	// Resources is a list of Secrets/ConfigMaps where each contains 1 or more resources to be applied to the
+	// workload clusters.
+	// +optional
+	Resources []ResourceRef `json:"resources,omitempty"`
+
+	// Strategy is the strategy to be used when applying the resources, ApplyOnce (the default) or Reconcile.
+	// +kubebuilder:validation:Enum=ApplyOnce;Reconcile
+	// +optional
+	Strategy ClusterResourceSetStrategy `json:"strategy,omitempty"`
+}
+
+// ANCHOR_END: ClusterResourceSetSpec
+
+// ClusterResourceSetResourceKind is a kind of resource referenced by a ClusterResourceSet.
+type ClusterResourceSetResourceKind string
+
+const (
+	// SecretClusterResourceSetResourceKind is the Secret kind of resource, whose data is base64 encoded.
+	SecretClusterResourceSetResourceKind ClusterResourceSetResourceKind = "Secret"
+
+	// ConfigMapClusterResourceSetResourceKind is the ConfigMap kind of resource.
+	ConfigMapClusterResourceSetResourceKind ClusterResourceSetResourceKind = "ConfigMap"
+)
+
+// ResourceRef specifies a Secret or a ConfigMap in the namespace of the ClusterResourceSet, each value of which is
+// a YAML or JSON manifest of one or more objects.
+type ResourceRef struct {
+	// Name of the resource that is in the same namespace as the ClusterResourceSet.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Kind of the resource. Supported kinds are: Secret and ConfigMap.
+	// +kubebuilder:validation:Enum=Secret;ConfigMap
+	Kind ClusterResourceSetResourceKind `json:"kind"`
+}
+
+// ClusterResourceSetStrategy is the strategy a ClusterResourceSet applies its resources with.
+type ClusterResourceSetStrategy string
+
+const (
+	// ClusterResourceSetStrategyApplyOnce applies each resource to a workload cluster only once, even if it changes
+	// later on.
+	ClusterResourceSetStrategyApplyOnce ClusterResourceSetStrategy = "ApplyOnce"
+
+	// ClusterResourceSetStrategyReconcile applies each resource to a workload cluster again whenever it changes.
+	ClusterResourceSetStrategyReconcile ClusterResourceSetStrategy = "Reconcile"
+)
+
+// ANCHOR: ClusterResourceSetStatus
+
+// ClusterResourceSetStatus defines the observed state of ClusterResourceSet
+type ClusterResourceSetStatus struct {
+	// ObservedGeneration reflects the generation of the most recently observed ClusterResourceSet.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions defines current state of the ClusterResourceSet.
+	// +optional
+	Conditions Conditions `json:"conditions,omitempty"`
+}
+
+// ANCHOR_END: ClusterResourceSetStatus
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path=clusterresourcesets,shortName=crs,scope=Namespaced,categories=cluster-api
+// +kubebuilder:storageversion
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Strategy",type="string",JSONPath=".spec.strategy",description="Strategy the resources are applied with"
+
+// ClusterResourceSet is the Schema for the clusterresourcesets API
+type ClusterResourceSet struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ClusterResourceSetSpec   `json:"spec,omitempty"`
+	Status ClusterResourceSetStatus `json:"status,omitempty"`
+}
+
+// GetConditions returns the conditions of the ClusterResourceSet.
+func (m *ClusterResourceSet) GetConditions() Conditions {
+	return m.Status.Conditions
+}
+
+// SetConditions sets the conditions of the ClusterResourceSet.
+func (m *ClusterResourceSet) SetConditions(conditions Conditions) {
+	m.Status.Conditions = conditions
+}
+
+// +kubebuilder:object:root=true
+
+// ClusterResourceSetList contains a list of ClusterResourceSet
+type ClusterResourceSetList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterResourceSet `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterResourceSet{}, &ClusterResourceSetList{})
+}
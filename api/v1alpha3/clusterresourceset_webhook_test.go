@@ -0,0 +1,115 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestClusterResourceSetDefault(t *testing.T) {
+	g := NewWithT(t)
+	crs := &ClusterResourceSet{}
+
+	crs.Default()
+
+	g.Expect(crs.Spec.Strategy).To(Equal(ClusterResourceSetStrategyApplyOnce))
+}
+
+func TestClusterResourceSetLabelSelectorAsSelectorValidation(t *testing.T) {
+	tests := []struct {
+		name      string
+		selectors map[string]string
+		expectErr bool
+	}{
+		{
+			name:      "should not return error for valid selector",
+			selectors: map[string]string{"foo": "bar"},
+			expectErr: false,
+		},
+		{
+			name:      "should return error for invalid selector",
+			selectors: map[string]string{"-123-foo": "bar"},
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			crs := &ClusterResourceSet{
+				Spec: ClusterResourceSetSpec{
+					ClusterSelector: metav1.LabelSelector{
+						MatchLabels: tt.selectors,
+					},
+				},
+			}
+			if tt.expectErr {
+				g.Expect(crs.ValidateCreate()).NotTo(Succeed())
+				g.Expect(crs.ValidateUpdate(crs)).NotTo(Succeed())
+			} else {
+				g.Expect(crs.ValidateCreate()).To(Succeed())
+				g.Expect(crs.ValidateUpdate(crs)).To(Succeed())
+			}
+		})
+	}
+}
+
+func TestClusterResourceSetStrategyImmutable(t *testing.T) {
+	tests := []struct {
+		name        string
+		oldStrategy ClusterResourceSetStrategy
+		newStrategy ClusterResourceSetStrategy
+		expectErr   bool
+	}{
+		{
+			name:        "when the strategy is not changed",
+			oldStrategy: ClusterResourceSetStrategyApplyOnce,
+			newStrategy: ClusterResourceSetStrategyApplyOnce,
+			expectErr:   false,
+		},
+		{
+			name:        "when the strategy is set for the first time",
+			oldStrategy: "",
+			newStrategy: ClusterResourceSetStrategyReconcile,
+			expectErr:   false,
+		},
+		{
+			name:        "when the strategy is changed",
+			oldStrategy: ClusterResourceSetStrategyApplyOnce,
+			newStrategy: ClusterResourceSetStrategyReconcile,
+			expectErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			newCRS := &ClusterResourceSet{Spec: ClusterResourceSetSpec{Strategy: tt.newStrategy}}
+			oldCRS := &ClusterResourceSet{Spec: ClusterResourceSetSpec{Strategy: tt.oldStrategy}}
+
+			if tt.expectErr {
+				g.Expect(newCRS.ValidateUpdate(oldCRS)).NotTo(Succeed())
+			} else {
+				g.Expect(newCRS.ValidateUpdate(oldCRS)).To(Succeed())
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ANCHOR: ResourceBinding
+
+// ResourceBinding shows the status of a resource that belongs to a ClusterResourceSet matched by the owner cluster
+// of the ClusterResourceSetBinding object.
+type ResourceBinding struct {
+	// ResourceRef specifies a resource.
+	ResourceRef `json:",inline"`
+
+	// Hash is the hash of a resource's data. This can be used to decide if a resource is changed.
+	// For "ApplyOnce" ClusterResourceSet.spec.strategy, this is no-op as that strategy does not act on change.
+	// +optional
+	Hash string `json:"hash,omitempty"`
+
+	// LastAppliedTime identifies when this resource was last applied to the cluster.
+	// +optional
+	LastAppliedTime *metav1.Time `json:"lastAppliedTime,omitempty"`
+
+	// Applied is to track if a resource is applied to the cluster or not.
+	Applied bool `json:"applied"`
+}
+
+// ANCHOR_END: ResourceBinding
+
+// ResourceSetBinding keeps info on all of the resources in a ClusterResourceSet.
+type ResourceSetBinding struct {
+	// ClusterResourceSetName is the name of the ClusterResourceSet that is applied to the owner cluster of the binding.
+	ClusterResourceSetName string `json:"clusterResourceSetName"`
+
+	// Resources is a list of resources that the ClusterResourceSet has.
+	// +optional
+	Resources []ResourceBinding `json:"resources,omitempty"`
+}
+
+// IsApplied returns true if the resource is applied to the cluster by checking the cluster's binding.
+func (r *ResourceSetBinding) IsApplied(resourceRef ResourceRef) bool {
+	resourceBinding := r.GetResource(resourceRef)
+	return resourceBinding != nil && resourceBinding.Applied
+}
+
+// GetResource returns the binding of the resource, or nil if the resource has not been applied yet.
+func (r *ResourceSetBinding) GetResource(resourceRef ResourceRef) *ResourceBinding {
+	for i := range r.Resources {
+		if r.Resources[i].ResourceRef == resourceRef {
+			return &r.Resources[i]
+		}
+	}
+	return nil
+}
+
+// SetBinding sets the binding of a resource, replacing the existing one if there is one.
+func (r *ResourceSetBinding) SetBinding(resourceBinding ResourceBinding) {
+	for i := range r.Resources {
+		if r.Resources[i].ResourceRef == resourceBinding.ResourceRef {
+			r.Resources[i] = resourceBinding
+			return
+		}
+	}
+	r.Resources = append(r.Resources, resourceBinding)
+}
+
+// ANCHOR: ClusterResourceSetBindingSpec
+
+// ClusterResourceSetBindingSpec defines the desired state of ClusterResourceSetBinding
+type ClusterResourceSetBindingSpec struct {
+	// Bindings is a list of ClusterResourceSets and their resources.
+	// +optional
+	Bindings []*ResourceSetBinding `json:"bindings,omitempty"`
+}
+
+// ANCHOR_END: ClusterResourceSetBindingSpec
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path=clusterresourcesetbindings,scope=Namespaced,categories=cluster-api
+// +kubebuilder:storageversion
+
+// ClusterResourceSetBinding lists all matching ClusterResourceSets with the cluster it belongs to.
+// It is named after its Cluster, which owns it.
+type ClusterResourceSetBinding struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              ClusterResourceSetBindingSpec `json:"spec,omitempty"`
+}
+
+// GetOrCreateBinding returns the binding of the ClusterResourceSet, which is added if it does not exist yet.
+func (c *ClusterResourceSetBinding) GetOrCreateBinding(clusterResourceSet *ClusterResourceSet) *ResourceSetBinding {
+	for _, binding := range c.Spec.Bindings {
+		if binding.ClusterResourceSetName == clusterResourceSet.Name {
+			return binding
+		}
+	}
+	binding := &ResourceSetBinding{ClusterResourceSetName: clusterResourceSet.Name, Resources: []ResourceBinding{}}
+	c.Spec.Bindings = append(c.Spec.Bindings, binding)
+	return binding
+}
+
+// DeleteBinding removes the binding of the ClusterResourceSet, if there is one.
+func (c *ClusterResourceSetBinding) DeleteBinding(clusterResourceSet *ClusterResourceSet) {
+	for i, binding := range c.Spec.Bindings {
+		if binding.ClusterResourceSetName == clusterResourceSet.Name {
+			c.Spec.Bindings = append(c.Spec.Bindings[:i], c.Spec.Bindings[i+1:]...)
+			return
+		}
+	}
+}
+
+// +kubebuilder:object:root=true
+
+// ClusterResourceSetBindingList contains a list of ClusterResourceSetBinding
+type ClusterResourceSetBindingList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterResourceSetBinding `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterResourceSetBinding{}, &ClusterResourceSetBindingList{})
+}
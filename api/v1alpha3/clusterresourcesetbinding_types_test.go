@@ -0,0 +1,57 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestClusterResourceSetBinding(t *testing.T) {
+	g := NewWithT(t)
+
+	crs1 := &ClusterResourceSet{ObjectMeta: metav1.ObjectMeta{Name: "crs1"}}
+	crs2 := &ClusterResourceSet{ObjectMeta: metav1.ObjectMeta{Name: "crs2"}}
+	configMap := ResourceRef{Name: "addons", Kind: ConfigMapClusterResourceSetResourceKind}
+	secret := ResourceRef{Name: "addons", Kind: SecretClusterResourceSetResourceKind}
+
+	binding := &ClusterResourceSetBinding{}
+	resourceSetBinding := binding.GetOrCreateBinding(crs1)
+	g.Expect(binding.Spec.Bindings).To(HaveLen(1))
+	g.Expect(resourceSetBinding.IsApplied(configMap)).To(BeFalse())
+
+	resourceSetBinding.SetBinding(ResourceBinding{ResourceRef: configMap, Hash: "a", Applied: true})
+	resourceSetBinding.SetBinding(ResourceBinding{ResourceRef: secret, Hash: "b", Applied: false})
+	g.Expect(resourceSetBinding.IsApplied(configMap)).To(BeTrue())
+	g.Expect(resourceSetBinding.IsApplied(secret)).To(BeFalse())
+
+	// Setting the binding of a resource again replaces it.
+	resourceSetBinding.SetBinding(ResourceBinding{ResourceRef: configMap, Hash: "c", Applied: true})
+	g.Expect(resourceSetBinding.Resources).To(HaveLen(2))
+	g.Expect(resourceSetBinding.GetResource(configMap).Hash).To(Equal("c"))
+
+	// The binding of a ClusterResourceSet is only created once.
+	g.Expect(binding.GetOrCreateBinding(crs1)).To(BeIdenticalTo(resourceSetBinding))
+	binding.GetOrCreateBinding(crs2)
+	g.Expect(binding.Spec.Bindings).To(HaveLen(2))
+
+	binding.DeleteBinding(crs1)
+	g.Expect(binding.Spec.Bindings).To(HaveLen(1))
+	g.Expect(binding.Spec.Bindings[0].ClusterResourceSetName).To(Equal("crs2"))
+}
@@ -36,6 +36,61 @@ const (
 	// Controllers working with Cluster API objects must check the existence of this annotation
 	// on the reconciled object.
 	PausedAnnotation = "cluster.x-k8s.io/paused"
+
+	// MachineUnhealthyAnnotation is an annotation that marks a Machine as unhealthy, e.g. by a MachineHealthCheck.
+	//
+	// Controllers owning the Machine can remediate it by deleting and replacing it.
+	MachineUnhealthyAnnotation = "cluster.x-k8s.io/unhealthy"
+
+	// DeleteMachineAnnotation marks a Machine to be deleted first when its owner scales down.
+	DeleteMachineAnnotation = "cluster.x-k8s.io/delete-machine"
+
+	// PreDrainDeleteHookAnnotationPrefix is the prefix of the annotations that pause the deletion of a Machine
+	// before its node is drained, e.g. "pre-drain.delete.hook.machine.cluster.x-k8s.io/detach-storage".
+	//
+	// The external controller owning a hook removes its annotation once it is done, which lets the deletion move on.
+	PreDrainDeleteHookAnnotationPrefix = "pre-drain.delete.hook.machine.cluster.x-k8s.io"
+
+	// PreTerminateDeleteHookAnnotationPrefix is the prefix of the annotations that pause the deletion of a Machine
+	// after its node is drained, and before the node and the infrastructure of the Machine are deleted.
+	//
+	// The external controller owning a hook removes its annotation once it is done, which lets the deletion move on.
+	PreTerminateDeleteHookAnnotationPrefix = "pre-terminate.delete.hook.machine.cluster.x-k8s.io"
+
+	// NodeRoleLabelPrefix is the prefix of the node role labels, e.g. "node-role.kubernetes.io/worker".
+	// The node role labels of a Machine are synchronized to its node, as kubelets are not allowed to set them.
+	NodeRoleLabelPrefix = "node-role.kubernetes.io"
+
+	// ManagedNodeLabelDomain is the domain of the labels and annotations of a Machine that are synchronized to its
+	// node, e.g. "node.cluster.x-k8s.io/tier" or "example.node.cluster.x-k8s.io/tier".
+	ManagedNodeLabelDomain = "node.cluster.x-k8s.io"
+
+	// LabelsFromMachineAnnotation is the annotation set on nodes to track the comma separated keys of the labels
+	// synchronized from their Machine, so that the ones removed from the Machine are removed from the node too.
+	LabelsFromMachineAnnotation = "cluster.x-k8s.io/labels-from-machine"
+
+	// AnnotationsFromMachineAnnotation is the annotation set on nodes to track the comma separated keys of the
+	// annotations synchronized from their Machine, so that the ones removed from the Machine are removed from the
+	// node too.
+	AnnotationsFromMachineAnnotation = "cluster.x-k8s.io/annotations-from-machine"
+)
+
+const (
+	// AutoscalerCapacityCPUAnnotation is the number of CPUs of the machines of a MachineDeployment or MachineSet.
+	// Together with the other capacity annotations, it lets the cluster autoscaler scale them up from zero replicas.
+	//
+	// The capacity annotations are maintained from the "status.capacity" field of the infrastructure template, if any.
+	AutoscalerCapacityCPUAnnotation = "capacity.cluster-autoscaler.kubernetes.io/cpu"
+
+	// AutoscalerCapacityMemoryAnnotation is the memory of the machines of a MachineDeployment or MachineSet.
+	AutoscalerCapacityMemoryAnnotation = "capacity.cluster-autoscaler.kubernetes.io/memory"
+
+	// AutoscalerCapacityGPUCountAnnotation is the number of GPUs of the machines of a MachineDeployment or MachineSet.
+	AutoscalerCapacityGPUCountAnnotation = "capacity.cluster-autoscaler.kubernetes.io/gpu-count"
+
+	// AutoscalerCapacityGPUTypeAnnotation is the resource name of the GPUs of the machines of a MachineDeployment or
+	// MachineSet, e.g. "nvidia.com/gpu".
+	AutoscalerCapacityGPUTypeAnnotation = "capacity.cluster-autoscaler.kubernetes.io/gpu-type"
 )
 
 // MachineAddressType describes a valid MachineAddress type.
@@ -0,0 +1,242 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ANCHOR: Condition
+
+// ConditionType is a valid value for Condition.Type.
+type ConditionType string
+
+// ConditionSeverity expresses the severity of a Condition Type failing.
+type ConditionSeverity string
+
+const (
+	// ConditionSeverityError specifies that a condition with `Status=False` is an error.
+	ConditionSeverityError ConditionSeverity = "Error"
+
+	// ConditionSeverityWarning specifies that a condition with `Status=False` is a warning.
+	ConditionSeverityWarning ConditionSeverity = "Warning"
+
+	// ConditionSeverityInfo specifies that a condition with `Status=False` is informative, e.g. while waiting for
+	// something to happen.
+	ConditionSeverityInfo ConditionSeverity = "Info"
+
+	// ConditionSeverityNone should apply only to conditions with `Status=True`.
+	ConditionSeverityNone ConditionSeverity = ""
+)
+
+// Condition defines an observation of a Cluster API resource operational state.
+// Conditions have a positive polarity, i.e. True means that the state is the expected one, while False, qualified by
+// its severity, means that something is not there yet or went wrong.
+type Condition struct {
+	// Type of condition in CamelCase or in foo.example.com/CamelCase.
+	// Many .condition.type values are consistent across resources like Available, but because arbitrary conditions
+	// can be useful (see .node.status.conditions), the ability to deconflict is important.
+	Type ConditionType `json:"type"`
+
+	// Status of the condition, one of True, False, Unknown.
+	Status corev1.ConditionStatus `json:"status"`
+
+	// Severity provides an explicit classification of Reason code, so the users or machines can immediately
+	// understand the current situation and act accordingly.
+	// The Severity field MUST be set only when Status=False.
+	// +optional
+	Severity ConditionSeverity `json:"severity,omitempty"`
+
+	// LastTransitionTime is the last time the condition transitioned from one status to another.
+	// +optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+
+	// Reason is the reason for the condition's last transition in CamelCase.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+
+	// Message is a human readable message indicating details about the transition.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// ANCHOR_END: Condition
+
+// Conditions provide observations of the operational state of a Cluster API resource.
+type Conditions []Condition
+
+// Conditions and condition reasons common to Cluster API objects.
+
+const (
+	// ReadyCondition reports the overall state of the object. On Cluster API objects, it summarizes their other
+	// conditions, while the Ready condition of the objects of providers is mirrored on the Cluster API objects
+	// referencing them, e.g. as the InfrastructureReady condition.
+	ReadyCondition ConditionType = "Ready"
+)
+
+const (
+	// PausedCondition reports whether the reconciliation of the object is paused, either because its Cluster is
+	// paused or because the object has the PausedAnnotation. It is False once the object is no longer paused.
+	PausedCondition ConditionType = "Paused"
+
+	// ClusterPausedReason is used when the Cluster of the object is paused.
+	ClusterPausedReason = "ClusterPaused"
+
+	// PausedAnnotationReason is used when the object has the PausedAnnotation.
+	PausedAnnotationReason = "PausedAnnotation"
+)
+
+// Conditions and condition reasons for the Cluster object.
+
+const (
+	// ControlPlaneInitializedCondition reports whether the control plane of the Cluster was initialized, i.e. whether
+	// its API server can be reached and the worker Machines can join it. Once True, it is no longer updated.
+	ControlPlaneInitializedCondition ConditionType = "ControlPlaneInitialized"
+
+	// WaitingForControlPlaneInitializedReason is used when the control plane provider, or the control plane Machines
+	// if the Cluster has no control plane provider, did not initialize the control plane yet.
+	WaitingForControlPlaneInitializedReason = "WaitingForControlPlaneInitialized"
+)
+
+const (
+	// ControlPlaneReadyCondition reports whether the control plane provider reports the control plane of the Cluster
+	// as ready.
+	ControlPlaneReadyCondition ConditionType = "ControlPlaneReady"
+
+	// WaitingForControlPlaneReason is used when the control plane provider did not report the control plane as ready
+	// yet.
+	WaitingForControlPlaneReason = "WaitingForControlPlane"
+)
+
+// Conditions and condition reasons for the Machine object.
+
+const (
+	// BootstrapReadyCondition reports whether the bootstrap provider generated the bootstrap data of the Machine.
+	BootstrapReadyCondition ConditionType = "BootstrapReady"
+
+	// WaitingForDataSecretReason is used when the bootstrap provider did not generate the bootstrap data yet.
+	WaitingForDataSecretReason = "WaitingForDataSecret"
+)
+
+const (
+	// InfrastructureReadyCondition reports whether the infrastructure provider provisioned the infrastructure of
+	// the Machine or of the Cluster.
+	InfrastructureReadyCondition ConditionType = "InfrastructureReady"
+
+	// WaitingForInfrastructureReason is used when the infrastructure provider did not provision the infrastructure
+	// yet.
+	WaitingForInfrastructureReason = "WaitingForInfrastructure"
+
+	// InfrastructureDeletedReason is used when the infrastructure object was deleted after it was ready.
+	InfrastructureDeletedReason = "InfrastructureDeleted"
+)
+
+const (
+	// NodeHealthyCondition mirrors the Ready condition of the node of the Machine.
+	NodeHealthyCondition ConditionType = "NodeHealthy"
+
+	// WaitingForNodeRefReason is used when the Machine does not reference its node yet.
+	WaitingForNodeRefReason = "WaitingForNodeRef"
+
+	// NodeNotFoundReason is used when the node of the Machine no longer exists.
+	NodeNotFoundReason = "NodeNotFound"
+
+	// NodeInspectionFailedReason is used when the node of the Machine could not be looked up.
+	NodeInspectionFailedReason = "NodeInspectionFailed"
+
+	// NodeNotReadyReason is used when the node of the Machine is not ready.
+	NodeNotReadyReason = "NodeNotReady"
+)
+
+const (
+	// DrainingSucceededCondition reports whether the node of a deleted Machine was drained.
+	DrainingSucceededCondition ConditionType = "DrainingSucceeded"
+
+	// DrainingFailedReason is used when the node of the Machine could not be drained.
+	DrainingFailedReason = "DrainingFailed"
+
+	// DrainingTimeoutExceededReason is used when the drain was given up because the node drain timeout of the
+	// Machine was exceeded.
+	DrainingTimeoutExceededReason = "DrainingTimeoutExceeded"
+)
+
+const (
+	// MachineOwnerRemediatedCondition is set to False on an unhealthy Machine whose remediation is handed off to its
+	// owner by a MachineHealthCheck, e.g. control plane Machines, which must be remediated without losing quorum.
+	MachineOwnerRemediatedCondition ConditionType = "OwnerRemediated"
+
+	// WaitingForRemediationReason is used when the owner of the Machine did not remediate it yet.
+	WaitingForRemediationReason = "WaitingForRemediation"
+
+	// RemediationUnsafeReason is used when the owner of the Machine does not remediate it because it is not safe,
+	// e.g. because it would leave the control plane without quorum.
+	RemediationUnsafeReason = "RemediationUnsafe"
+)
+
+// Conditions and condition reasons for the MachineDeployment object.
+
+const (
+	// MachineDeploymentAvailableCondition reports whether the MachineDeployment has the minimum number of available
+	// machines, i.e. its replicas minus the maximum number of unavailable machines of its strategy.
+	MachineDeploymentAvailableCondition ConditionType = "Available"
+
+	// MinimumReplicasUnavailableReason is used when fewer machines than the minimum are available.
+	MinimumReplicasUnavailableReason = "MinimumReplicasUnavailable"
+)
+
+const (
+	// MachineDeploymentProgressingCondition reports whether the rollout of the MachineDeployment makes progress. It is
+	// False once the MachineDeployment made no progress for ProgressDeadlineSeconds.
+	MachineDeploymentProgressingCondition ConditionType = "Progressing"
+
+	// ProgressDeadlineExceededReason is used when the MachineDeployment made no progress for ProgressDeadlineSeconds.
+	ProgressDeadlineExceededReason = "ProgressDeadlineExceeded"
+
+	// MachineDeploymentPausedReason is used when the rollout is paused, during which progress is not estimated.
+	MachineDeploymentPausedReason = "MachineDeploymentPaused"
+)
+
+// Conditions and condition reasons for the MachineHealthCheck object.
+
+const (
+	// RemediationAllowedCondition reports whether the MachineHealthCheck is allowed to remediate unhealthy Machines,
+	// i.e. whether the number of unhealthy Machines is within its MaxUnhealthy or UnhealthyRange.
+	RemediationAllowedCondition ConditionType = "RemediationAllowed"
+
+	// TooManyUnhealthyReason is used when there are too many unhealthy Machines to remediate them, e.g. during an
+	// outage of the whole cluster, where replacing Machines would only make things worse.
+	TooManyUnhealthyReason = "TooManyUnhealthy"
+)
+
+// Conditions and condition reasons for the ClusterResourceSet object.
+
+const (
+	// ResourcesAppliedCondition reports whether the resources of the ClusterResourceSet were applied to all the
+	// workload clusters it selects.
+	ResourcesAppliedCondition ConditionType = "ResourcesApplied"
+
+	// RemoteClusterClientFailedReason is used when a client for a workload cluster could not be created.
+	RemoteClusterClientFailedReason = "RemoteClusterClientFailed"
+
+	// RetrievingResourceFailedReason is used when a resource could not be retrieved, e.g. because it does not exist
+	// or is a Secret of another type than ClusterResourceSetSecretType.
+	RetrievingResourceFailedReason = "RetrievingResourceFailed"
+
+	// ApplyFailedReason is used when a resource could not be applied to a workload cluster.
+	ApplyFailedReason = "ApplyFailed"
+)
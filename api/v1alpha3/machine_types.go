@@ -32,11 +32,19 @@ const (
 	// ExcludeNodeDrainingAnnotation annotation explicitly skips node draining if set
 	ExcludeNodeDrainingAnnotation = "machine.cluster.x-k8s.io/exclude-node-draining"
 
+	// ExcludeNodeDeletionAnnotation annotation explicitly skips deleting the node of a Machine once its
+	// infrastructure is deleted if set
+	ExcludeNodeDeletionAnnotation = "machine.cluster.x-k8s.io/exclude-node-deletion"
+
 	// MachineSetLabelName is the label set on machines if they're controlled by MachineSet
 	MachineSetLabelName = "cluster.x-k8s.io/set-name"
 
 	// MachineDeploymentLabelName is the label set on machines if they're controlled by MachineDeployment
 	MachineDeploymentLabelName = "cluster.x-k8s.io/deployment-name"
+
+	// InterruptibleLabelName is the label set on the nodes of interruptible machines, so that workloads, e.g.
+	// termination handlers, can target them.
+	InterruptibleLabelName = "cluster.x-k8s.io/interruptible"
 )
 
 // ANCHOR: MachineSpec
@@ -77,6 +85,14 @@ type MachineSpec struct {
 	// Must match a key in the FailureDomains map stored on the cluster object.
 	// +optional
 	FailureDomain *string `json:"failureDomain,omitempty"`
+
+	// NodeDrainTimeout is the total amount of time that the controller will spend on draining a node,
+	// measured from when the Machine is deleted. Once it is exceeded, the node is deleted without waiting
+	// for the remaining pods to be evicted, e.g. because a PodDisruptionBudget does not allow it.
+	// The node is drained without a time limit if it is not set or zero.
+	// NOTE: NodeDrainTimeout is different from `kubectl drain --timeout`.
+	// +optional
+	NodeDrainTimeout *metav1.Duration `json:"nodeDrainTimeout,omitempty"`
 }
 
 // ANCHOR_END: MachineSpec
@@ -155,6 +171,17 @@ type MachineStatus struct {
 	// InfrastructureReady is the state of the infrastructure provider.
 	// +optional
 	InfrastructureReady bool `json:"infrastructureReady"`
+
+	// Interruptible reports whether the infrastructure of the Machine can be reclaimed by the infrastructure
+	// provider at any time, e.g. spot or preemptible instances.
+	// This field is copied from the infrastructure provider reference, and its node is labeled with
+	// InterruptibleLabelName if it is set.
+	// +optional
+	Interruptible bool `json:"interruptible,omitempty"`
+
+	// Conditions defines current service state of the Machine.
+	// +optional
+	Conditions Conditions `json:"conditions,omitempty"`
 }
 
 // ANCHOR_END: MachineStatus
@@ -227,6 +254,16 @@ type Machine struct {
 	Status MachineStatus `json:"status,omitempty"`
 }
 
+// GetConditions returns the conditions of the Machine.
+func (m *Machine) GetConditions() Conditions {
+	return m.Status.Conditions
+}
+
+// SetConditions sets the conditions of the Machine.
+func (m *Machine) SetConditions(conditions Conditions) {
+	m.Status.Conditions = conditions
+}
+
 // +kubebuilder:object:root=true
 
 // MachineList contains a list of Machine
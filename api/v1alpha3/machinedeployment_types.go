@@ -28,6 +28,10 @@ const (
 	// i.e. gradually scale down the old MachineSet and scale up the new one.
 	RollingUpdateMachineDeploymentStrategyType MachineDeploymentStrategyType = "RollingUpdate"
 
+	// Replace the old MachineSet by new one only as the Machines of the old MachineSet are deleted,
+	// i.e. scale down the old MachineSet by the number of its deleted Machines and scale up the new one.
+	OnDeleteMachineDeploymentStrategyType MachineDeploymentStrategyType = "OnDelete"
+
 	// RevisionAnnotation is the revision annotation of a machine deployment's machine sets which records its rollout sequence
 	RevisionAnnotation = "machinedeployment.clusters.x-k8s.io/revision"
 	// RevisionHistoryAnnotation maintains the history of all old revisions that a machine set has served for a machine deployment.
@@ -40,6 +44,12 @@ const (
 	// is machinedeployment.spec.replicas + maxSurge. Used by the underlying machine sets to estimate their
 	// proportions in case the deployment has surge replicas.
 	MaxReplicasAnnotation = "machinedeployment.clusters.x-k8s.io/max-replicas"
+	// DisableMachineCreateAnnotation is set to "true" on the old machine sets of a machine deployment with the OnDelete
+	// strategy, so that they do not replace their deleted machines and only the new machine set creates machines.
+	DisableMachineCreateAnnotation = "machinedeployment.clusters.x-k8s.io/disable-machine-create"
+	// RollbackToRevisionAnnotation is set on a machine deployment to roll it back to the machine template of one of
+	// its previous revisions, or of the revision before the current one if it is "0". It is removed once handled.
+	RollbackToRevisionAnnotation = "machinedeployment.clusters.x-k8s.io/rollback-to-revision"
 )
 
 // ANCHOR: MachineDeploymentSpec
@@ -99,9 +109,9 @@ type MachineDeploymentSpec struct {
 // MachineDeploymentStrategy describes how to replace existing machines
 // with new ones.
 type MachineDeploymentStrategy struct {
-	// Type of deployment. Currently the only supported strategy is
-	// "RollingUpdate".
+	// Type of deployment. Allowed values are "RollingUpdate" and "OnDelete".
 	// Default is RollingUpdate.
+	// +kubebuilder:validation:Enum=RollingUpdate;OnDelete
 	// +optional
 	Type MachineDeploymentStrategyType `json:"type,omitempty"`
 
@@ -195,6 +205,10 @@ type MachineDeploymentStatus struct {
 	// Phase represents the current phase of a MachineDeployment (ScalingUp, ScalingDown, Running, Failed, or Unknown).
 	// +optional
 	Phase string `json:"phase,omitempty"`
+
+	// Conditions defines current service state of the MachineDeployment.
+	// +optional
+	Conditions Conditions `json:"conditions,omitempty"`
 }
 
 // ANCHOR_END: MachineDeploymentStatus
@@ -258,6 +272,16 @@ type MachineDeployment struct {
 	Status MachineDeploymentStatus `json:"status,omitempty"`
 }
 
+// GetConditions returns the conditions of the MachineDeployment.
+func (m *MachineDeployment) GetConditions() Conditions {
+	return m.Status.Conditions
+}
+
+// SetConditions sets the conditions of the MachineDeployment.
+func (m *MachineDeployment) SetConditions(conditions Conditions) {
+	m.Status.Conditions = conditions
+}
+
 // +kubebuilder:object:root=true
 
 // MachineDeploymentList contains a list of MachineDeployment
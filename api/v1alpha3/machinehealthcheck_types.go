@@ -40,10 +40,30 @@ type MachineHealthCheckSpec struct {
 	// +kubebuilder:validation:MinItems=1
 	UnhealthyConditions []UnhealthyCondition `json:"unhealthyConditions"`
 
+	// Machines older than this duration without a node will be considered to have
+	// failed and will be remediated, e.g. when their bootstrap failed. Defaults to 10 minutes.
+	// Set to 0 to disable.
+	// +optional
+	NodeStartupTimeout *metav1.Duration `json:"nodeStartupTimeout,omitempty"`
+
 	// Any further remediation is only allowed if at most "MaxUnhealthy" machines selected by
 	// "selector" are not healthy.
 	// +optional
 	MaxUnhealthy *intstr.IntOrString `json:"maxUnhealthy,omitempty"`
+
+	// Any further remediation is only allowed if the number of machines selected by "selector" as not healthy
+	// is within the range of "UnhealthyRange", e.g. "[3-5]". Takes precedence over "MaxUnhealthy".
+	// +optional
+	// +kubebuilder:validation:Pattern=^\[[0-9]+-[0-9]+\]$
+	UnhealthyRange *string `json:"unhealthyRange,omitempty"`
+
+	// ExternalRemediationTemplate is a reference to a remediation template provided by an infrastructure provider.
+	//
+	// When set, unhealthy Machines are not marked to be deleted by their owner: a remediation request is created from
+	// the template instead, named after the Machine and owned by it, so that an external controller can remediate
+	// the Machine, e.g. by rebooting or reimaging it.
+	// +optional
+	ExternalRemediationTemplate *corev1.ObjectReference `json:"externalRemediationTemplate,omitempty"`
 }
 
 // ANCHOR_END: MachineHealthCHeckSpec
@@ -63,8 +83,25 @@ type UnhealthyCondition struct {
 	Status corev1.ConditionStatus `json:"status"`
 
 	Timeout metav1.Duration `json:"timeout"`
+
+	// Source is the kind of object the condition is looked up on, either the Node (the default) or the Machine,
+	// e.g. to remediate Machines whose bootstrap failed and which never get a Node.
+	// +kubebuilder:validation:Enum=Node;Machine
+	// +optional
+	Source UnhealthyConditionSource `json:"source,omitempty"`
 }
 
+// UnhealthyConditionSource is the kind of object the condition of an UnhealthyCondition is looked up on.
+type UnhealthyConditionSource string
+
+const (
+	// NodeUnhealthyConditionSource looks up the condition on the Node of the Machine.
+	NodeUnhealthyConditionSource UnhealthyConditionSource = "Node"
+
+	// MachineUnhealthyConditionSource looks up the condition on the Machine itself.
+	MachineUnhealthyConditionSource UnhealthyConditionSource = "Machine"
+)
+
 // ANCHOR_END: UnhealthyCondition
 
 // ANCHOR: MachineHealthCheckStatus
@@ -78,6 +115,16 @@ type MachineHealthCheckStatus struct {
 	// total number of healthy machines counted by this machine health check
 	// +kubebuilder:validation:Minimum=0
 	CurrentHealthy int32 `json:"currentHealthy"`
+
+	// RemediationsAllowed is the number of further remediations allowed by this machine health check before
+	// maxUnhealthy or unhealthyRange short circuiting is applied
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	RemediationsAllowed int32 `json:"remediationsAllowed,omitempty"`
+
+	// Conditions defines current service state of the MachineHealthCheck.
+	// +optional
+	Conditions Conditions `json:"conditions,omitempty"`
 }
 
 // ANCHOR_END: MachineHealthCheckStatus
@@ -102,6 +149,16 @@ type MachineHealthCheck struct {
 	Status MachineHealthCheckStatus `json:"status,omitempty"`
 }
 
+// GetConditions returns the conditions of the MachineHealthCheck.
+func (m *MachineHealthCheck) GetConditions() Conditions {
+	return m.Status.Conditions
+}
+
+// SetConditions sets the conditions of the MachineHealthCheck.
+func (m *MachineHealthCheck) SetConditions(conditions Conditions) {
+	m.Status.Conditions = conditions
+}
+
 // +kubebuilder:object:root=true
 
 // MachineHealthCheckList contains a list of MachineHealthCheck
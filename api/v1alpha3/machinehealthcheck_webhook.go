@@ -17,7 +17,11 @@ limitations under the License.
 package v1alpha3
 
 import (
+	"errors"
 	"fmt"
+	"regexp"
+	"strconv"
+	"time"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -28,6 +32,12 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 )
 
+// defaultNodeStartupTimeout is the default NodeStartupTimeout of MachineHealthChecks.
+const defaultNodeStartupTimeout = 10 * time.Minute
+
+// unhealthyRangeRegexp matches the valid values of MachineHealthCheckSpec.UnhealthyRange, e.g. "[3-5]".
+var unhealthyRangeRegexp = regexp.MustCompile(`^\[([0-9]+)-([0-9]+)\]$`)
+
 func (m *MachineHealthCheck) SetupWebhookWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewWebhookManagedBy(mgr).
 		For(m).
@@ -46,6 +56,16 @@ func (m *MachineHealthCheck) Default() {
 		defaultMaxUnhealthy := intstr.FromString("100%")
 		m.Spec.MaxUnhealthy = &defaultMaxUnhealthy
 	}
+
+	if m.Spec.NodeStartupTimeout == nil {
+		m.Spec.NodeStartupTimeout = &metav1.Duration{Duration: defaultNodeStartupTimeout}
+	}
+
+	for i := range m.Spec.UnhealthyConditions {
+		if m.Spec.UnhealthyConditions[i].Source == "" {
+			m.Spec.UnhealthyConditions[i].Source = NodeUnhealthyConditionSource
+		}
+	}
 }
 
 // ValidateCreate implements webhook.Validator so a webhook will be registered for the type
@@ -79,6 +99,43 @@ func (m *MachineHealthCheck) validate(old *MachineHealthCheck) error {
 		)
 	}
 
+	if m.Spec.MaxUnhealthy != nil {
+		if _, err := intstr.GetValueFromIntOrPercent(m.Spec.MaxUnhealthy, 0, false); err != nil {
+			allErrs = append(
+				allErrs,
+				field.Invalid(field.NewPath("spec", "maxUnhealthy"), m.Spec.MaxUnhealthy.String(), err.Error()),
+			)
+		}
+	}
+
+	if m.Spec.NodeStartupTimeout != nil && m.Spec.NodeStartupTimeout.Duration < 0 {
+		allErrs = append(
+			allErrs,
+			field.Invalid(field.NewPath("spec", "nodeStartupTimeout"), m.Spec.NodeStartupTimeout.String(), "must not be negative"),
+		)
+	}
+
+	if m.Spec.UnhealthyRange != nil {
+		if err := validateUnhealthyRange(*m.Spec.UnhealthyRange); err != nil {
+			allErrs = append(
+				allErrs,
+				field.Invalid(field.NewPath("spec", "unhealthyRange"), *m.Spec.UnhealthyRange, err.Error()),
+			)
+		}
+	}
+
+	if m.Spec.ExternalRemediationTemplate != nil && m.Spec.ExternalRemediationTemplate.Namespace != "" &&
+		m.Spec.ExternalRemediationTemplate.Namespace != m.Namespace {
+		allErrs = append(
+			allErrs,
+			field.Invalid(
+				field.NewPath("spec", "externalRemediationTemplate", "namespace"),
+				m.Spec.ExternalRemediationTemplate.Namespace,
+				"must match metadata.namespace",
+			),
+		)
+	}
+
 	if old != nil && old.Spec.ClusterName != m.Spec.ClusterName {
 		allErrs = append(
 			allErrs,
@@ -92,3 +149,23 @@ func (m *MachineHealthCheck) validate(old *MachineHealthCheck) error {
 
 	return apierrors.NewInvalid(GroupVersion.WithKind("MachineHealthCheck").GroupKind(), m.Name, allErrs)
 }
+
+// validateUnhealthyRange returns an error if the unhealthy range is not of the form "[min-max]" with min <= max.
+func validateUnhealthyRange(unhealthyRange string) error {
+	bounds := unhealthyRangeRegexp.FindStringSubmatch(unhealthyRange)
+	if bounds == nil {
+		return errors.New("must be of the form [min-max], e.g. [3-5]")
+	}
+	min, err := strconv.Atoi(bounds[1])
+	if err != nil {
+		return err
+	}
+	max, err := strconv.Atoi(bounds[2])
+	if err != nil {
+		return err
+	}
+	if min > max {
+		return errors.New("the minimum must be lower than or equal to the maximum")
+	}
+	return nil
+}
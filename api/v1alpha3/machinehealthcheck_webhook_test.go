@@ -18,18 +18,73 @@ package v1alpha3
 
 import (
 	"testing"
+	"time"
 
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 func TestMachineHealthCheckDefault(t *testing.T) {
 	g := NewWithT(t)
-	mhc := &MachineHealthCheck{}
+	mhc := &MachineHealthCheck{
+		Spec: MachineHealthCheckSpec{
+			UnhealthyConditions: []UnhealthyCondition{
+				{Type: corev1.NodeReady, Status: corev1.ConditionUnknown},
+				{Type: "BootstrapReady", Status: corev1.ConditionFalse, Source: MachineUnhealthyConditionSource},
+			},
+		},
+	}
 
 	mhc.Default()
 
 	g.Expect(mhc.Spec.MaxUnhealthy.String()).To(Equal("100%"))
+	g.Expect(mhc.Spec.NodeStartupTimeout).To(Equal(&metav1.Duration{Duration: 10 * time.Minute}))
+	g.Expect(mhc.Spec.UnhealthyConditions[0].Source).To(Equal(NodeUnhealthyConditionSource))
+	g.Expect(mhc.Spec.UnhealthyConditions[1].Source).To(Equal(MachineUnhealthyConditionSource))
+}
+
+func TestMachineHealthCheckNodeStartupTimeoutValidation(t *testing.T) {
+	tests := []struct {
+		name      string
+		timeout   time.Duration
+		expectErr bool
+	}{
+		{
+			name:      "should not return error for a positive timeout",
+			timeout:   5 * time.Minute,
+			expectErr: false,
+		},
+		{
+			name:      "should not return error for a disabled timeout",
+			timeout:   0,
+			expectErr: false,
+		},
+		{
+			name:      "should return error for a negative timeout",
+			timeout:   -time.Minute,
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			mhc := &MachineHealthCheck{
+				Spec: MachineHealthCheckSpec{
+					NodeStartupTimeout: &metav1.Duration{Duration: tt.timeout},
+				},
+			}
+			if tt.expectErr {
+				g.Expect(mhc.ValidateCreate()).NotTo(Succeed())
+				g.Expect(mhc.ValidateUpdate(mhc)).NotTo(Succeed())
+			} else {
+				g.Expect(mhc.ValidateCreate()).To(Succeed())
+				g.Expect(mhc.ValidateUpdate(mhc)).To(Succeed())
+			}
+		})
+	}
 }
 
 func TestMachineHealthCheckLabelSelectorAsSelectorValidation(t *testing.T) {
@@ -115,3 +170,115 @@ func TestMachineHealthCheckClusterNameImmutable(t *testing.T) {
 		})
 	}
 }
+
+func TestMachineHealthCheckExternalRemediationTemplateNamespace(t *testing.T) {
+	tests := []struct {
+		name      string
+		namespace string
+		expectErr bool
+	}{
+		{
+			name:      "when the template namespace is empty",
+			namespace: "",
+			expectErr: false,
+		},
+		{
+			name:      "when the template is in the namespace of the MachineHealthCheck",
+			namespace: "foo",
+			expectErr: false,
+		},
+		{
+			name:      "when the template is in another namespace",
+			namespace: "bar",
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			mhc := &MachineHealthCheck{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+				},
+				Spec: MachineHealthCheckSpec{
+					ExternalRemediationTemplate: &corev1.ObjectReference{
+						Kind:      "InfrastructureRemediationTemplate",
+						Name:      "reboot",
+						Namespace: tt.namespace,
+					},
+				},
+			}
+			if tt.expectErr {
+				g.Expect(mhc.ValidateCreate()).NotTo(Succeed())
+				g.Expect(mhc.ValidateUpdate(mhc)).NotTo(Succeed())
+			} else {
+				g.Expect(mhc.ValidateCreate()).To(Succeed())
+				g.Expect(mhc.ValidateUpdate(mhc)).To(Succeed())
+			}
+		})
+	}
+}
+
+func TestMachineHealthCheckUnhealthyRangeValidation(t *testing.T) {
+	tests := []struct {
+		name           string
+		unhealthyRange string
+		expectErr      bool
+	}{
+		{
+			name:           "should not return error for valid range",
+			unhealthyRange: "[3-5]",
+			expectErr:      false,
+		},
+		{
+			name:           "should not return error for a range of a single value",
+			unhealthyRange: "[3-3]",
+			expectErr:      false,
+		},
+		{
+			name:           "should return error for a range with a minimum greater than its maximum",
+			unhealthyRange: "[5-3]",
+			expectErr:      true,
+		},
+		{
+			name:           "should return error for a malformed range",
+			unhealthyRange: "3-5",
+			expectErr:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			unhealthyRange := tt.unhealthyRange
+			mhc := &MachineHealthCheck{
+				Spec: MachineHealthCheckSpec{
+					UnhealthyRange: &unhealthyRange,
+				},
+			}
+			if tt.expectErr {
+				g.Expect(mhc.ValidateCreate()).NotTo(Succeed())
+				g.Expect(mhc.ValidateUpdate(mhc)).NotTo(Succeed())
+			} else {
+				g.Expect(mhc.ValidateCreate()).To(Succeed())
+				g.Expect(mhc.ValidateUpdate(mhc)).To(Succeed())
+			}
+		})
+	}
+}
+
+func TestMachineHealthCheckMaxUnhealthyValidation(t *testing.T) {
+	g := NewWithT(t)
+
+	maxUnhealthy := intstr.FromString("50%")
+	mhc := &MachineHealthCheck{
+		Spec: MachineHealthCheckSpec{
+			MaxUnhealthy: &maxUnhealthy,
+		},
+	}
+	g.Expect(mhc.ValidateCreate()).To(Succeed())
+
+	maxUnhealthy = intstr.FromString("half")
+	g.Expect(mhc.ValidateCreate()).NotTo(Succeed())
+}
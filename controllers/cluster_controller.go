@@ -34,8 +34,10 @@ import (
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	"sigs.k8s.io/cluster-api/controllers/external"
 	"sigs.k8s.io/cluster-api/controllers/metrics"
+	"sigs.k8s.io/cluster-api/controllers/remote"
 	capierrors "sigs.k8s.io/cluster-api/errors"
 	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/patch"
 	"sigs.k8s.io/cluster-api/util/secret"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -65,6 +67,10 @@ type ClusterReconciler struct {
 	Client client.Client
 	Log    logr.Logger
 
+	// Tracker holds the clients and caches of the workload clusters shared by the other controllers of the
+	// management cluster. The entry of a cluster is torn down once the cluster is deleted.
+	Tracker *remote.ClusterCacheTracker
+
 	scheme          *runtime.Scheme
 	recorder        record.EventRecorder
 	externalTracker external.ObjectTracker
@@ -109,25 +115,36 @@ func (r *ClusterReconciler) Reconcile(req ctrl.Request) (_ ctrl.Result, reterr e
 		return ctrl.Result{}, err
 	}
 
-	// Return early if the object or Cluster is paused.
-	if util.IsPaused(cluster, cluster) {
-		logger.V(3).Info("reconciliation is paused for this object")
-		return ctrl.Result{}, nil
-	}
-
 	// Initialize the patch helper.
 	patchHelper, err := patch.NewHelper(cluster, r.Client)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
 
+	// Return early if the object or Cluster is paused.
+	if util.IsPausedWithCondition(cluster, cluster) {
+		logger.V(3).Info("reconciliation is paused for this object")
+		return ctrl.Result{}, patchHelper.Patch(ctx, cluster)
+	}
+
 	defer func() {
 		// Always reconcile the Status.Phase field.
 		r.reconcilePhase(ctx, cluster)
 		r.reconcileMetrics(ctx, cluster)
 
+		// Always summarize the readiness of the infrastructure and of the control plane in the Ready condition.
+		conditions.SetSummary(cluster,
+			conditions.WithConditions(clusterv1.ControlPlaneReadyCondition, clusterv1.InfrastructureReadyCondition),
+		)
+
 		// Always attempt to Patch the Cluster object and status after each reconciliation.
-		if err := patchHelper.Patch(ctx, cluster); err != nil {
+		// The conditions reported by this controller take precedence over changes made by other processes.
+		if err := patchHelper.Patch(ctx, cluster, patch.WithOwnedConditions(
+			clusterv1.ReadyCondition,
+			clusterv1.InfrastructureReadyCondition,
+			clusterv1.ControlPlaneInitializedCondition,
+			clusterv1.ControlPlaneReadyCondition,
+		)); err != nil {
 			reterr = kerrors.NewAggregate([]error{reterr, err})
 		}
 	}()
@@ -203,30 +220,56 @@ func (r *ClusterReconciler) reconcileMetrics(_ context.Context, cluster *cluster
 	}
 }
 
-// reconcileDelete handles cluster deletion.
+// reconcileDelete deletes the objects of the Cluster in order: first the workers (MachineDeployments, MachineSets,
+// MachinePools and worker Machines), then the control plane, and finally the cluster infrastructure, so that the
+// control plane is still available while the worker nodes are drained and deleted.
 func (r *ClusterReconciler) reconcileDelete(ctx context.Context, cluster *clusterv1.Cluster) (reconcile.Result, error) {
 	logger := r.Log.WithValues("cluster", cluster.Name, "namespace", cluster.Namespace)
 
-	// First handle the control plane
+	descendants, err := r.listDescendants(ctx, cluster)
+	if err != nil {
+		logger.Error(err, "Failed to list descendants")
+		return reconcile.Result{}, err
+	}
+
+	// First delete the workers, and wait for them to be gone.
+	if workerCount := descendants.workerLength(); workerCount > 0 {
+		workers, err := descendants.filterOwnedWorkers(cluster)
+		if err != nil {
+			logger.Error(err, "Failed to extract direct worker descendants")
+			return reconcile.Result{}, err
+		}
+
+		if err := r.deleteChildren(ctx, cluster, workers); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		logger.Info("Cluster still has workers - need to requeue", "descendants", descendants.descendantNames(), "indirect descendants count", workerCount-len(workers))
+		// Requeue so we can check the next time to see if there are still any workers left.
+		return ctrl.Result{RequeueAfter: deleteRequeueAfter}, nil
+	}
+
+	// Then delete the control plane, and wait for it to be gone.
 	if cluster.Spec.ControlPlaneRef != nil {
 		obj, err := external.Get(ctx, r.Client, cluster.Spec.ControlPlaneRef, cluster.Namespace)
 		switch {
 		case apierrors.IsNotFound(errors.Cause(err)):
+			// All good - the control plane has been deleted
 		case err != nil:
 			return reconcile.Result{}, err
-		case obj.GetDeletionTimestamp().IsZero():
-			if err := r.Client.Delete(ctx, obj); err != nil {
-				return ctrl.Result{}, errors.Wrapf(err,
-					"failed to delete %v %q for Cluster %q in namespace %q",
-					obj.GroupVersionKind(), obj.GetName(), cluster.Name, cluster.Namespace)
+		default:
+			if obj.GetDeletionTimestamp().IsZero() {
+				if err := r.Client.Delete(ctx, obj); err != nil {
+					return ctrl.Result{}, errors.Wrapf(err,
+						"failed to delete %v %q for Cluster %q in namespace %q",
+						obj.GroupVersionKind(), obj.GetName(), cluster.Name, cluster.Namespace)
+				}
 			}
-		}
-	}
 
-	descendants, err := r.listDescendants(ctx, cluster)
-	if err != nil {
-		logger.Error(err, "Failed to list descendants")
-		return reconcile.Result{}, err
+			logger.Info("Cluster still has a control plane - need to requeue", "control plane", obj.GetName())
+			// Requeue so we can check the next time to see if the control plane is gone.
+			return ctrl.Result{RequeueAfter: deleteRequeueAfter}, nil
+		}
 	}
 
 	children, err := descendants.filterOwnedDescendants(cluster)
@@ -238,32 +281,8 @@ func (r *ClusterReconciler) reconcileDelete(ctx context.Context, cluster *cluste
 	if len(children) > 0 {
 		logger.Info("Cluster still has children - deleting them first", "count", len(children))
 
-		var errs []error
-
-		for _, child := range children {
-			accessor, err := meta.Accessor(child)
-			if err != nil {
-				logger.Error(err, "Couldn't create accessor", "type", fmt.Sprintf("%T", child))
-				continue
-			}
-
-			if !accessor.GetDeletionTimestamp().IsZero() {
-				// Don't handle deleted child
-				continue
-			}
-
-			gvk := child.GetObjectKind().GroupVersionKind().String()
-
-			logger.Info("Deleting child", "gvk", gvk, "name", accessor.GetName())
-			if err := r.Client.Delete(context.Background(), child); err != nil {
-				err = errors.Wrapf(err, "error deleting cluster %s/%s: failed to delete %s %s", cluster.Namespace, cluster.Name, gvk, accessor.GetName())
-				logger.Error(err, "Error deleting resource", "gvk", gvk, "name", accessor.GetName())
-				errs = append(errs, err)
-			}
-		}
-
-		if len(errs) > 0 {
-			return ctrl.Result{}, kerrors.NewAggregate(errs)
+		if err := r.deleteChildren(ctx, cluster, children); err != nil {
+			return ctrl.Result{}, err
 		}
 	}
 
@@ -297,22 +316,63 @@ func (r *ClusterReconciler) reconcileDelete(ctx context.Context, cluster *cluste
 		}
 	}
 
+	if r.Tracker != nil {
+		r.Tracker.DeleteClusterCache(types.NamespacedName{Namespace: cluster.Namespace, Name: cluster.Name})
+	}
+
 	controllerutil.RemoveFinalizer(cluster, clusterv1.ClusterFinalizer)
 	return ctrl.Result{}, nil
 }
 
+// deleteChildren issues a deletion request for each of the given children of the Cluster which is not already being deleted.
+func (r *ClusterReconciler) deleteChildren(ctx context.Context, cluster *clusterv1.Cluster, children []runtime.Object) error {
+	logger := r.Log.WithValues("cluster", cluster.Name, "namespace", cluster.Namespace)
+
+	var errs []error
+	for _, child := range children {
+		accessor, err := meta.Accessor(child)
+		if err != nil {
+			logger.Error(err, "Couldn't create accessor", "type", fmt.Sprintf("%T", child))
+			continue
+		}
+
+		if !accessor.GetDeletionTimestamp().IsZero() {
+			// Don't handle deleted child
+			continue
+		}
+
+		gvk := child.GetObjectKind().GroupVersionKind().String()
+
+		logger.Info("Deleting child", "gvk", gvk, "name", accessor.GetName())
+		if err := r.Client.Delete(ctx, child); err != nil {
+			err = errors.Wrapf(err, "error deleting cluster %s/%s: failed to delete %s %s", cluster.Namespace, cluster.Name, gvk, accessor.GetName())
+			logger.Error(err, "Error deleting resource", "gvk", gvk, "name", accessor.GetName())
+			errs = append(errs, err)
+		}
+	}
+
+	return kerrors.NewAggregate(errs)
+}
+
 type clusterDescendants struct {
 	machineDeployments   clusterv1.MachineDeploymentList
 	machineSets          clusterv1.MachineSetList
+	machinePools         clusterv1.MachinePoolList
 	controlPlaneMachines clusterv1.MachineList
 	workerMachines       clusterv1.MachineList
 }
 
 // length returns the number of descendants
 func (c *clusterDescendants) length() int {
+	return c.workerLength() +
+		len(c.controlPlaneMachines.Items)
+}
+
+// workerLength returns the number of descendants which are not control plane machines
+func (c *clusterDescendants) workerLength() int {
 	return len(c.machineDeployments.Items) +
 		len(c.machineSets.Items) +
-		len(c.controlPlaneMachines.Items) +
+		len(c.machinePools.Items) +
 		len(c.workerMachines.Items)
 }
 
@@ -339,6 +399,13 @@ func (c *clusterDescendants) descendantNames() string {
 	if len(machineSetNames) > 0 {
 		descendants = append(descendants, "Machine sets: "+strings.Join(machineSetNames, ","))
 	}
+	machinePoolNames := make([]string, len(c.machinePools.Items))
+	for i, machinePool := range c.machinePools.Items {
+		machinePoolNames[i] = machinePool.Name
+	}
+	if len(machinePoolNames) > 0 {
+		descendants = append(descendants, "Machine pools: "+strings.Join(machinePoolNames, ","))
+	}
 	workerMachineNames := make([]string, len(c.workerMachines.Items))
 	for i, workerMachine := range c.workerMachines.Items {
 		workerMachineNames[i] = workerMachine.Name
@@ -349,7 +416,7 @@ func (c *clusterDescendants) descendantNames() string {
 	return strings.Join(descendants, ";")
 }
 
-// listDescendants returns a list of all MachineDeployments, MachineSets, and Machines for the cluster.
+// listDescendants returns a list of all MachineDeployments, MachineSets, MachinePools, and Machines for the cluster.
 func (r *ClusterReconciler) listDescendants(ctx context.Context, cluster *clusterv1.Cluster) (clusterDescendants, error) {
 	var descendants clusterDescendants
 
@@ -366,6 +433,10 @@ func (r *ClusterReconciler) listDescendants(ctx context.Context, cluster *cluste
 		return descendants, errors.Wrapf(err, "failed to list MachineSets for cluster %s/%s", cluster.Namespace, cluster.Name)
 	}
 
+	if err := r.Client.List(ctx, &descendants.machinePools, listOptions...); err != nil {
+		return descendants, errors.Wrapf(err, "failed to list MachinePools for cluster %s/%s", cluster.Namespace, cluster.Name)
+	}
+
 	var machines clusterv1.MachineList
 	if err := r.Client.List(ctx, &machines, listOptions...); err != nil {
 		return descendants, errors.Wrapf(err, "failed to list Machines for cluster %s/%s", cluster.Namespace, cluster.Name)
@@ -382,6 +453,28 @@ func (r *ClusterReconciler) listDescendants(ctx context.Context, cluster *cluste
 // filterOwnedDescendants returns an array of runtime.Objects containing only those descendants that have the cluster
 // as an owner reference, with control plane machines sorted last.
 func (c clusterDescendants) filterOwnedDescendants(cluster *clusterv1.Cluster) ([]runtime.Object, error) {
+	return filterOwnedObjects(cluster,
+		&c.machineDeployments,
+		&c.machineSets,
+		&c.machinePools,
+		&c.workerMachines,
+		&c.controlPlaneMachines,
+	)
+}
+
+// filterOwnedWorkers returns an array of runtime.Objects containing only those descendants that have the cluster
+// as an owner reference, excluding control plane machines.
+func (c clusterDescendants) filterOwnedWorkers(cluster *clusterv1.Cluster) ([]runtime.Object, error) {
+	return filterOwnedObjects(cluster,
+		&c.machineDeployments,
+		&c.machineSets,
+		&c.machinePools,
+		&c.workerMachines,
+	)
+}
+
+// filterOwnedObjects returns the items of the given lists that have the cluster as an owner reference.
+func filterOwnedObjects(cluster *clusterv1.Cluster, lists ...runtime.Object) ([]runtime.Object, error) {
 	var ownedDescendants []runtime.Object
 	eachFunc := func(o runtime.Object) error {
 		acc, err := meta.Accessor(o)
@@ -396,12 +489,6 @@ func (c clusterDescendants) filterOwnedDescendants(cluster *clusterv1.Cluster) (
 		return nil
 	}
 
-	lists := []runtime.Object{
-		&c.machineDeployments,
-		&c.machineSets,
-		&c.workerMachines,
-		&c.controlPlaneMachines,
-	}
 	for _, list := range lists {
 		if err := meta.EachListItem(list, eachFunc); err != nil {
 			return nil, errors.Wrapf(err, "error finding owned descendants of cluster %s/%s", cluster.Namespace, cluster.Name)
@@ -435,6 +522,7 @@ func (r *ClusterReconciler) reconcileControlPlaneInitialized(ctx context.Context
 	}
 
 	if cluster.Status.ControlPlaneInitialized {
+		conditions.MarkTrue(cluster, clusterv1.ControlPlaneInitializedCondition)
 		return nil
 	}
 
@@ -447,10 +535,13 @@ func (r *ClusterReconciler) reconcileControlPlaneInitialized(ctx context.Context
 	for _, m := range machines {
 		if util.IsControlPlaneMachine(m) && m.Status.NodeRef != nil {
 			cluster.Status.ControlPlaneInitialized = true
+			conditions.MarkTrue(cluster, clusterv1.ControlPlaneInitializedCondition)
 			return nil
 		}
 	}
 
+	conditions.MarkFalse(cluster, clusterv1.ControlPlaneInitializedCondition, clusterv1.WaitingForControlPlaneInitializedReason, clusterv1.ConditionSeverityInfo,
+		"no control plane Machine has a Node yet")
 	return nil
 }
 
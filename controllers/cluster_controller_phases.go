@@ -29,6 +29,7 @@ import (
 	"sigs.k8s.io/cluster-api/controllers/external"
 	capierrors "sigs.k8s.io/cluster-api/errors"
 	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	utilconversion "sigs.k8s.io/cluster-api/util/conversion"
 	"sigs.k8s.io/cluster-api/util/kubeconfig"
 	"sigs.k8s.io/cluster-api/util/patch"
@@ -162,9 +163,13 @@ func (r *ClusterReconciler) reconcileInfrastructure(ctx context.Context, cluster
 	}
 	cluster.Status.InfrastructureReady = ready
 	if !ready {
+		conditions.SetMirror(cluster, clusterv1.InfrastructureReadyCondition, conditions.UnstructuredGetter(infraConfig),
+			conditions.WithFallbackValue(false, clusterv1.WaitingForInfrastructureReason, clusterv1.ConditionSeverityInfo,
+				"%s %q is not ready", infraConfig.GetKind(), infraConfig.GetName()))
 		logger.V(3).Info("Infrastructure provider is not ready yet")
 		return nil
 	}
+	conditions.MarkTrue(cluster, clusterv1.InfrastructureReadyCondition)
 
 	// Get and parse Spec.ControlPlaneEndpoint field from the infrastructure provider.
 	if cluster.Spec.ControlPlaneEndpoint.IsZero() {
@@ -214,6 +219,12 @@ func (r *ClusterReconciler) reconcileControlPlane(ctx context.Context, cluster *
 		}
 		cluster.Status.ControlPlaneInitialized = initialized
 	}
+	if cluster.Status.ControlPlaneInitialized {
+		conditions.MarkTrue(cluster, clusterv1.ControlPlaneInitializedCondition)
+	} else {
+		conditions.MarkFalse(cluster, clusterv1.ControlPlaneInitializedCondition, clusterv1.WaitingForControlPlaneInitializedReason, clusterv1.ConditionSeverityInfo,
+			"%s %q is not initialized", controlPlaneConfig.GetKind(), controlPlaneConfig.GetName())
+	}
 
 	// Determine if the control plane provider is ready.
 	ready, err := external.IsReady(controlPlaneConfig)
@@ -221,6 +232,13 @@ func (r *ClusterReconciler) reconcileControlPlane(ctx context.Context, cluster *
 		return err
 	}
 	cluster.Status.ControlPlaneReady = ready
+	if ready {
+		conditions.MarkTrue(cluster, clusterv1.ControlPlaneReadyCondition)
+	} else {
+		conditions.SetMirror(cluster, clusterv1.ControlPlaneReadyCondition, conditions.UnstructuredGetter(controlPlaneConfig),
+			conditions.WithFallbackValue(false, clusterv1.WaitingForControlPlaneReason, clusterv1.ConditionSeverityInfo,
+				"%s %q is not ready", controlPlaneConfig.GetKind(), controlPlaneConfig.GetName()))
+	}
 
 	return nil
 }
@@ -30,6 +30,7 @@ import (
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	"sigs.k8s.io/cluster-api/controllers/external"
 	capierrors "sigs.k8s.io/cluster-api/errors"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/log"
@@ -228,6 +229,136 @@ func TestClusterReconcilePhases(t *testing.T) {
 	})
 }
 
+func TestClusterReconcileConditions(t *testing.T) {
+	newExternal := func(name string, status map[string]interface{}) *unstructured.Unstructured {
+		return &unstructured.Unstructured{
+			Object: map[string]interface{}{
+				"kind":       "InfrastructureMachine",
+				"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+				"metadata": map[string]interface{}{
+					"name":      name,
+					"namespace": "test-namespace",
+				},
+				"status": status,
+			},
+		}
+	}
+	newCluster := func() *clusterv1.Cluster {
+		return &clusterv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-cluster",
+				Namespace: "test-namespace",
+			},
+			Spec: clusterv1.ClusterSpec{
+				ControlPlaneEndpoint: clusterv1.APIEndpoint{
+					Host: "1.2.3.4",
+					Port: 8443,
+				},
+				InfrastructureRef: &corev1.ObjectReference{
+					APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+					Kind:       "InfrastructureMachine",
+					Name:       "test-infrastructure",
+				},
+				// The control plane provider is faked with the generic infrastructure kind, whose readiness is read
+				// the same way.
+				ControlPlaneRef: &corev1.ObjectReference{
+					APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+					Kind:       "InfrastructureMachine",
+					Name:       "test-control-plane",
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name                   string
+		infrastructureStatus   map[string]interface{}
+		controlPlaneStatus     map[string]interface{}
+		expectedInfrastructure corev1.ConditionStatus
+		expectedInitialized    corev1.ConditionStatus
+		expectedControlPlane   corev1.ConditionStatus
+		expectedReason         string
+	}{
+		{
+			name:                   "nothing is ready",
+			infrastructureStatus:   map[string]interface{}{"ready": false},
+			controlPlaneStatus:     map[string]interface{}{"initialized": false, "ready": false},
+			expectedInfrastructure: corev1.ConditionFalse,
+			expectedInitialized:    corev1.ConditionFalse,
+			expectedControlPlane:   corev1.ConditionFalse,
+			expectedReason:         clusterv1.WaitingForInfrastructureReason,
+		},
+		{
+			name: "infrastructure not ready with a ready condition",
+			infrastructureStatus: map[string]interface{}{
+				"ready": false,
+				"conditions": []interface{}{
+					map[string]interface{}{"type": "Ready", "status": "False", "severity": "Info", "reason": "LoadBalancerProvisioning"},
+				},
+			},
+			controlPlaneStatus:     map[string]interface{}{"initialized": false, "ready": false},
+			expectedInfrastructure: corev1.ConditionFalse,
+			expectedInitialized:    corev1.ConditionFalse,
+			expectedControlPlane:   corev1.ConditionFalse,
+			expectedReason:         "LoadBalancerProvisioning",
+		},
+		{
+			name:                   "control plane initialized but not ready",
+			infrastructureStatus:   map[string]interface{}{"ready": true},
+			controlPlaneStatus:     map[string]interface{}{"initialized": true, "ready": false},
+			expectedInfrastructure: corev1.ConditionTrue,
+			expectedInitialized:    corev1.ConditionTrue,
+			expectedControlPlane:   corev1.ConditionFalse,
+		},
+		{
+			name:                   "everything is ready",
+			infrastructureStatus:   map[string]interface{}{"ready": true},
+			controlPlaneStatus:     map[string]interface{}{"initialized": true, "ready": true},
+			expectedInfrastructure: corev1.ConditionTrue,
+			expectedInitialized:    corev1.ConditionTrue,
+			expectedControlPlane:   corev1.ConditionTrue,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(clusterv1.AddToScheme(scheme.Scheme)).To(Succeed())
+			g.Expect(apiextensionsv1.AddToScheme(scheme.Scheme)).To(Succeed())
+
+			cluster := newCluster()
+			c := fake.NewFakeClientWithScheme(scheme.Scheme, external.TestGenericInfrastructureCRD, cluster,
+				newExternal("test-infrastructure", tt.infrastructureStatus),
+				newExternal("test-control-plane", tt.controlPlaneStatus),
+			)
+			r := &ClusterReconciler{
+				Client: c,
+				Log:    log.Log,
+				scheme: scheme.Scheme,
+			}
+
+			g.Expect(r.reconcileInfrastructure(context.Background(), cluster)).To(Succeed())
+			g.Expect(r.reconcileControlPlane(context.Background(), cluster)).To(Succeed())
+
+			for conditionType, expected := range map[clusterv1.ConditionType]corev1.ConditionStatus{
+				clusterv1.InfrastructureReadyCondition:     tt.expectedInfrastructure,
+				clusterv1.ControlPlaneInitializedCondition: tt.expectedInitialized,
+				clusterv1.ControlPlaneReadyCondition:       tt.expectedControlPlane,
+			} {
+				condition := conditions.Get(cluster, conditionType)
+				g.Expect(condition).NotTo(BeNil())
+				g.Expect(condition.Status).To(Equal(expected))
+				if expected == corev1.ConditionFalse {
+					g.Expect(condition.Severity).To(Equal(clusterv1.ConditionSeverityInfo))
+				}
+			}
+			if tt.expectedReason != "" {
+				g.Expect(conditions.Get(cluster, clusterv1.InfrastructureReadyCondition).Reason).To(Equal(tt.expectedReason))
+			}
+		})
+	}
+}
+
 func TestClusterReconciler_reconcilePhase(t *testing.T) {
 	cluster := &clusterv1.Cluster{
 		ObjectMeta: metav1.ObjectMeta{
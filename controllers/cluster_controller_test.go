@@ -25,7 +25,9 @@ import (
 	. "github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/utils/pointer"
@@ -670,6 +672,32 @@ func (b *machineSetBuilder) build() clusterv1.MachineSet {
 	return b.ms
 }
 
+type machinePoolBuilder struct {
+	mp clusterv1.MachinePool
+}
+
+func newMachinePoolBuilder() *machinePoolBuilder {
+	return &machinePoolBuilder{}
+}
+
+func (b *machinePoolBuilder) named(name string) *machinePoolBuilder {
+	b.mp.Name = name
+	return b
+}
+
+func (b *machinePoolBuilder) ownedBy(c *clusterv1.Cluster) *machinePoolBuilder {
+	b.mp.OwnerReferences = append(b.mp.OwnerReferences, metav1.OwnerReference{
+		APIVersion: clusterv1.GroupVersion.String(),
+		Kind:       "Cluster",
+		Name:       c.Name,
+	})
+	return b
+}
+
+func (b *machinePoolBuilder) build() clusterv1.MachinePool {
+	return b.mp
+}
+
 type machineBuilder struct {
 	m clusterv1.Machine
 }
@@ -720,6 +748,9 @@ func TestFilterOwnedDescendants(t *testing.T) {
 	ms3NotOwnedByCluster := newMachineSetBuilder().named("ms3").build()
 	ms4OwnedByCluster := newMachineSetBuilder().named("ms4").ownedBy(&c).build()
 
+	mp1NotOwnedByCluster := newMachinePoolBuilder().named("mp1").build()
+	mp2OwnedByCluster := newMachinePoolBuilder().named("mp2").ownedBy(&c).build()
+
 	m1NotOwnedByCluster := newMachineBuilder().named("m1").build()
 	m2OwnedByCluster := newMachineBuilder().named("m2").ownedBy(&c).build()
 	m3ControlPlaneOwnedByCluster := newMachineBuilder().named("m3").ownedBy(&c).controlPlane().build()
@@ -744,6 +775,12 @@ func TestFilterOwnedDescendants(t *testing.T) {
 				ms4OwnedByCluster,
 			},
 		},
+		machinePools: clusterv1.MachinePoolList{
+			Items: []clusterv1.MachinePool{
+				mp1NotOwnedByCluster,
+				mp2OwnedByCluster,
+			},
+		},
 		controlPlaneMachines: clusterv1.MachineList{
 			Items: []clusterv1.Machine{
 				m3ControlPlaneOwnedByCluster,
@@ -768,6 +805,7 @@ func TestFilterOwnedDescendants(t *testing.T) {
 		&md4OwnedByCluster,
 		&ms2OwnedByCluster,
 		&ms4OwnedByCluster,
+		&mp2OwnedByCluster,
 		&m2OwnedByCluster,
 		&m5OwnedByCluster,
 		&m3ControlPlaneOwnedByCluster,
@@ -777,6 +815,96 @@ func TestFilterOwnedDescendants(t *testing.T) {
 	g.Expect(actual).To(Equal(expected))
 }
 
+func TestClusterReconcileDeleteOrder(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(clusterv1.AddToScheme(scheme.Scheme)).To(Succeed())
+
+	newExternal := func(apiVersion, kind, name string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{
+			Object: map[string]interface{}{
+				"apiVersion": apiVersion,
+				"kind":       kind,
+				"metadata": map[string]interface{}{
+					"name":      name,
+					"namespace": "test-namespace",
+				},
+			},
+		}
+	}
+	controlPlane := newExternal("controlplane.cluster.x-k8s.io/v1alpha3", "ControlPlane", "test-control-plane")
+	infraCluster := newExternal("infrastructure.cluster.x-k8s.io/v1alpha3", "InfrastructureCluster", "test-infra")
+
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "test-cluster",
+			Namespace:  "test-namespace",
+			Finalizers: []string{clusterv1.ClusterFinalizer},
+		},
+		Spec: clusterv1.ClusterSpec{
+			ControlPlaneRef: &corev1.ObjectReference{
+				APIVersion: controlPlane.GetAPIVersion(),
+				Kind:       controlPlane.GetKind(),
+				Name:       controlPlane.GetName(),
+			},
+			InfrastructureRef: &corev1.ObjectReference{
+				APIVersion: infraCluster.GetAPIVersion(),
+				Kind:       infraCluster.GetKind(),
+				Name:       infraCluster.GetName(),
+			},
+		},
+	}
+
+	clusterLabels := map[string]string{clusterv1.ClusterLabelName: cluster.Name}
+	md := newMachineDeploymentBuilder().named("test-md").ownedBy(cluster).build()
+	md.Namespace = cluster.Namespace
+	md.Labels = clusterLabels
+	mp := newMachinePoolBuilder().named("test-mp").ownedBy(cluster).build()
+	mp.Namespace = cluster.Namespace
+	mp.Labels = clusterLabels
+
+	r := &ClusterReconciler{
+		Client: fake.NewFakeClientWithScheme(scheme.Scheme, cluster, &md, &mp, controlPlane, infraCluster),
+		Log:    log.Log,
+	}
+
+	exists := func(obj runtime.Object, name string) bool {
+		err := r.Client.Get(ctx, client.ObjectKey{Namespace: cluster.Namespace, Name: name}, obj)
+		if apierrors.IsNotFound(err) {
+			return false
+		}
+		g.Expect(err).NotTo(HaveOccurred())
+		return true
+	}
+
+	// The workers are deleted first, while the control plane and the infrastructure are left untouched.
+	result, err := r.reconcileDelete(ctx, cluster)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.RequeueAfter).To(Equal(deleteRequeueAfter))
+	g.Expect(exists(&clusterv1.MachineDeployment{}, md.Name)).To(BeFalse())
+	g.Expect(exists(&clusterv1.MachinePool{}, mp.Name)).To(BeFalse())
+	g.Expect(exists(controlPlane.DeepCopy(), controlPlane.GetName())).To(BeTrue())
+	g.Expect(exists(infraCluster.DeepCopy(), infraCluster.GetName())).To(BeTrue())
+
+	// Once the workers are gone, the control plane is deleted.
+	result, err = r.reconcileDelete(ctx, cluster)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.RequeueAfter).To(Equal(deleteRequeueAfter))
+	g.Expect(exists(controlPlane.DeepCopy(), controlPlane.GetName())).To(BeFalse())
+	g.Expect(exists(infraCluster.DeepCopy(), infraCluster.GetName())).To(BeTrue())
+
+	// Once the control plane is gone, the infrastructure is deleted.
+	_, err = r.reconcileDelete(ctx, cluster)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(exists(infraCluster.DeepCopy(), infraCluster.GetName())).To(BeFalse())
+	g.Expect(cluster.Finalizers).To(ContainElement(clusterv1.ClusterFinalizer))
+
+	// Finally the finalizer is removed.
+	_, err = r.reconcileDelete(ctx, cluster)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(cluster.Finalizers).NotTo(ContainElement(clusterv1.ClusterFinalizer))
+}
+
 func TestReconcileControlPlaneInitializedControlPlaneRef(t *testing.T) {
 	g := NewWithT(t)
 
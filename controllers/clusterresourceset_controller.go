@@ -0,0 +1,408 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/controllers/remote"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/patch"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// +kubebuilder:rbac:groups=core,resources=secrets;configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=clusters;clusters/status,verbs=get;list;watch
+// +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=clusterresourcesets;clusterresourcesets/status,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=clusterresourcesetbindings,verbs=get;list;watch;create;update;patch;delete
+
+// ClusterResourceSetReconciler reconciles a ClusterResourceSet object
+type ClusterResourceSetReconciler struct {
+	Client client.Client
+	Log    logr.Logger
+
+	// Tracker holds the clients and caches of the workload clusters, which may be shared with the other controllers
+	// watching them.
+	Tracker *remote.ClusterCacheTracker
+
+	remoteClientGetter remote.ClusterClientGetter
+	scheme             *runtime.Scheme
+}
+
+func (r *ClusterResourceSetReconciler) SetupWithManager(mgr ctrl.Manager, options controller.Options) error {
+	_, err := ctrl.NewControllerManagedBy(mgr).
+		For(&clusterv1.ClusterResourceSet{}).
+		Watches(
+			&source.Kind{Type: &clusterv1.Cluster{}},
+			&handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(r.clusterToClusterResourceSet)},
+		).
+		Watches(
+			&source.Kind{Type: &corev1.ConfigMap{}},
+			&handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(r.resourceToClusterResourceSet)},
+		).
+		Watches(
+			&source.Kind{Type: &corev1.Secret{}},
+			&handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(r.resourceToClusterResourceSet)},
+		).
+		WithOptions(options).
+		Build(r)
+
+	if err != nil {
+		return errors.Wrap(err, "failed setting up with a controller manager")
+	}
+
+	r.scheme = mgr.GetScheme()
+	if r.remoteClientGetter == nil {
+		r.remoteClientGetter = remote.NewClusterClient
+		if r.Tracker != nil {
+			r.remoteClientGetter = r.Tracker.ClusterClient
+		}
+	}
+	return nil
+}
+
+func (r *ClusterResourceSetReconciler) Reconcile(req ctrl.Request) (_ ctrl.Result, reterr error) {
+	ctx := context.Background()
+	logger := r.Log.WithValues("clusterresourceset", req.Name, "namespace", req.Namespace)
+
+	// Fetch the ClusterResourceSet instance.
+	clusterResourceSet := &clusterv1.ClusterResourceSet{}
+	if err := r.Client.Get(ctx, req.NamespacedName, clusterResourceSet); err != nil {
+		if apierrors.IsNotFound(err) {
+			// Object not found, return. Created objects are automatically garbage collected.
+			// For additional cleanup logic use finalizers.
+			return ctrl.Result{}, nil
+		}
+		// Error reading the object - requeue the request.
+		return ctrl.Result{}, err
+	}
+
+	// Initialize the patch helper.
+	patchHelper, err := patch.NewHelper(clusterResourceSet, r.Client)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	defer func() {
+		// Always attempt to patch the object and status after each reconciliation.
+		clusterResourceSet.Status.ObservedGeneration = clusterResourceSet.Generation
+		if err := patchHelper.Patch(ctx, clusterResourceSet); err != nil {
+			reterr = kerrors.NewAggregate([]error{reterr, err})
+		}
+	}()
+
+	// Handle deletion reconciliation loop.
+	if !clusterResourceSet.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, r.reconcileDelete(ctx, clusterResourceSet)
+	}
+
+	clusters, err := r.getClustersByClusterResourceSetSelector(ctx, clusterResourceSet)
+	if err != nil {
+		logger.Error(err, "Failed fetching clusters that matches ClusterResourceSet labels", "ClusterResourceSet", clusterResourceSet.Name)
+		return ctrl.Result{}, err
+	}
+
+	// Add the finalizer first if it does not exist, to clean up the bindings on deletion.
+	controllerutil.AddFinalizer(clusterResourceSet, clusterv1.ClusterResourceSetFinalizer)
+
+	var errs []error
+	for _, cluster := range clusters {
+		if err := r.ApplyClusterResourceSet(ctx, cluster, clusterResourceSet); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return ctrl.Result{}, kerrors.NewAggregate(errs)
+	}
+
+	conditions.MarkTrue(clusterResourceSet, clusterv1.ResourcesAppliedCondition)
+	return ctrl.Result{}, nil
+}
+
+// reconcileDelete removes the ClusterResourceSet from the ClusterResourceSetBindings in its namespace, deleting the
+// bindings left empty. The resources already applied to the workload clusters are kept.
+func (r *ClusterResourceSetReconciler) reconcileDelete(ctx context.Context, crs *clusterv1.ClusterResourceSet) error {
+	clusterResourceSetBindingList := &clusterv1.ClusterResourceSetBindingList{}
+	if err := r.Client.List(ctx, clusterResourceSetBindingList, client.InNamespace(crs.Namespace)); err != nil {
+		return errors.Wrap(err, "failed to list ClusterResourceSetBindings")
+	}
+
+	for i := range clusterResourceSetBindingList.Items {
+		clusterResourceSetBinding := &clusterResourceSetBindingList.Items[i]
+
+		patchHelper, err := patch.NewHelper(clusterResourceSetBinding, r.Client)
+		if err != nil {
+			return err
+		}
+
+		clusterResourceSetBinding.DeleteBinding(crs)
+		clusterResourceSetBinding.OwnerReferences = util.RemoveOwnerRef(clusterResourceSetBinding.OwnerReferences, metav1.OwnerReference{
+			APIVersion: clusterv1.GroupVersion.String(),
+			Kind:       "ClusterResourceSet",
+			Name:       crs.Name,
+		})
+
+		// Delete the binding if it no longer binds any ClusterResourceSet.
+		if len(clusterResourceSetBinding.Spec.Bindings) == 0 {
+			if err := r.Client.Delete(ctx, clusterResourceSetBinding); err != nil && !apierrors.IsNotFound(err) {
+				return errors.Wrapf(err, "failed to delete empty ClusterResourceSetBinding %s/%s",
+					clusterResourceSetBinding.Namespace, clusterResourceSetBinding.Name)
+			}
+			continue
+		}
+
+		if err := patchHelper.Patch(ctx, clusterResourceSetBinding); err != nil {
+			return err
+		}
+	}
+
+	controllerutil.RemoveFinalizer(crs, clusterv1.ClusterResourceSetFinalizer)
+	return nil
+}
+
+// getClustersByClusterResourceSetSelector returns the Clusters selected by the ClusterResourceSet, leaving out
+// the Clusters being deleted.
+func (r *ClusterResourceSetReconciler) getClustersByClusterResourceSetSelector(ctx context.Context, clusterResourceSet *clusterv1.ClusterResourceSet) ([]*clusterv1.Cluster, error) {
+	selector, err := metav1.LabelSelectorAsSelector(&clusterResourceSet.Spec.ClusterSelector)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build selector")
+	}
+
+	// An empty selector selects no Clusters, rather than all of them.
+	if selector.Empty() {
+		return nil, nil
+	}
+
+	clusterList := &clusterv1.ClusterList{}
+	if err := r.Client.List(ctx, clusterList, client.InNamespace(clusterResourceSet.Namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, errors.Wrap(err, "failed to list clusters")
+	}
+
+	clusters := []*clusterv1.Cluster{}
+	for i := range clusterList.Items {
+		c := &clusterList.Items[i]
+		if c.DeletionTimestamp.IsZero() {
+			clusters = append(clusters, c)
+		}
+	}
+	return clusters, nil
+}
+
+// ApplyClusterResourceSet applies the resources of the ClusterResourceSet to the workload cluster, and records them
+// in the ClusterResourceSetBinding of the Cluster. With the ApplyOnce strategy, the resources already applied are
+// skipped. With the Reconcile strategy, the resources whose hash changed since they were last applied are applied
+// again. The resources of Clusters whose control plane is not initialized yet are applied once it is.
+func (r *ClusterResourceSetReconciler) ApplyClusterResourceSet(ctx context.Context, cluster *clusterv1.Cluster, clusterResourceSet *clusterv1.ClusterResourceSet) error {
+	logger := r.Log.WithValues("clusterresourceset", clusterResourceSet.Name, "namespace", clusterResourceSet.Namespace, "cluster", cluster.Name)
+
+	if !cluster.Status.ControlPlaneInitialized {
+		logger.V(4).Info("Waiting for the control plane to be initialized before applying resources")
+		return nil
+	}
+
+	// Get the ClusterResourceSetBinding of the Cluster, or create it.
+	clusterResourceSetBinding, err := r.getOrCreateClusterResourceSetBinding(ctx, cluster, clusterResourceSet)
+	if err != nil {
+		return err
+	}
+
+	patchHelper, err := patch.NewHelper(clusterResourceSetBinding, r.Client)
+	if err != nil {
+		return err
+	}
+	resourceSetBinding := clusterResourceSetBinding.GetOrCreateBinding(clusterResourceSet)
+
+	// Retrieve the resources to apply, and the ones that changed since they were applied.
+	var errs []error
+	objsByResource := map[clusterv1.ResourceRef][]byte{}
+	hashByResource := map[clusterv1.ResourceRef]string{}
+	for _, resource := range clusterResourceSet.Spec.Resources {
+		if clusterResourceSet.Spec.Strategy != clusterv1.ClusterResourceSetStrategyReconcile && resourceSetBinding.IsApplied(resource) {
+			continue
+		}
+
+		data, err := r.getResourceData(ctx, clusterResourceSet.Namespace, resource)
+		if err != nil {
+			conditions.MarkFalse(clusterResourceSet, clusterv1.ResourcesAppliedCondition, clusterv1.RetrievingResourceFailedReason, clusterv1.ConditionSeverityError, "%v", err)
+			errs = append(errs, err)
+			continue
+		}
+
+		hash := computeHash(data)
+		if binding := resourceSetBinding.GetResource(resource); binding != nil && binding.Applied && binding.Hash == hash {
+			continue
+		}
+		objsByResource[resource] = data
+		hashByResource[resource] = hash
+	}
+
+	if len(objsByResource) > 0 {
+		remoteClient, err := r.remoteClientGetter(ctx, r.Client, cluster, r.scheme)
+		if err != nil {
+			conditions.MarkFalse(clusterResourceSet, clusterv1.ResourcesAppliedCondition, clusterv1.RemoteClusterClientFailedReason, clusterv1.ConditionSeverityWarning, "%v", err)
+			return kerrors.NewAggregate(append(errs, err))
+		}
+
+		// Apply the resources in the order they are listed in.
+		for _, resource := range clusterResourceSet.Spec.Resources {
+			data, ok := objsByResource[resource]
+			if !ok {
+				continue
+			}
+
+			applied := true
+			if err := applyResourceData(ctx, remoteClient, data, clusterResourceSet.Spec.Strategy == clusterv1.ClusterResourceSetStrategyReconcile); err != nil {
+				err = errors.Wrapf(err, "failed to apply %s %q to Cluster %s/%s", resource.Kind, resource.Name, cluster.Namespace, cluster.Name)
+				conditions.MarkFalse(clusterResourceSet, clusterv1.ResourcesAppliedCondition, clusterv1.ApplyFailedReason, clusterv1.ConditionSeverityError, "%v", err)
+				errs = append(errs, err)
+				applied = false
+			}
+			resourceSetBinding.SetBinding(clusterv1.ResourceBinding{
+				ResourceRef:     resource,
+				Hash:            hashByResource[resource],
+				LastAppliedTime: &metav1.Time{Time: time.Now().UTC()},
+				Applied:         applied,
+			})
+		}
+	}
+
+	if err := patchHelper.Patch(ctx, clusterResourceSetBinding); err != nil {
+		errs = append(errs, err)
+	}
+	return kerrors.NewAggregate(errs)
+}
+
+// getOrCreateClusterResourceSetBinding returns the ClusterResourceSetBinding of the Cluster, which is named after it.
+// It is created if it does not exist yet, and owned by both the Cluster and the ClusterResourceSet.
+func (r *ClusterResourceSetReconciler) getOrCreateClusterResourceSetBinding(ctx context.Context, cluster *clusterv1.Cluster, clusterResourceSet *clusterv1.ClusterResourceSet) (*clusterv1.ClusterResourceSetBinding, error) {
+	clusterResourceSetBinding := &clusterv1.ClusterResourceSetBinding{}
+	key := client.ObjectKey{Namespace: cluster.Namespace, Name: cluster.Name}
+
+	if err := r.Client.Get(ctx, key, clusterResourceSetBinding); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, errors.Wrapf(err, "failed to get ClusterResourceSetBinding for Cluster %s/%s", cluster.Namespace, cluster.Name)
+		}
+
+		clusterResourceSetBinding.Name = cluster.Name
+		clusterResourceSetBinding.Namespace = cluster.Namespace
+		clusterResourceSetBinding.OwnerReferences = []metav1.OwnerReference{
+			{
+				APIVersion: clusterv1.GroupVersion.String(),
+				Kind:       "Cluster",
+				Name:       cluster.Name,
+				UID:        cluster.UID,
+			},
+			{
+				APIVersion: clusterv1.GroupVersion.String(),
+				Kind:       "ClusterResourceSet",
+				Name:       clusterResourceSet.Name,
+				UID:        clusterResourceSet.UID,
+			},
+		}
+		if err := r.Client.Create(ctx, clusterResourceSetBinding); err != nil {
+			return nil, errors.Wrapf(err, "failed to create ClusterResourceSetBinding for Cluster %s/%s", cluster.Namespace, cluster.Name)
+		}
+		return clusterResourceSetBinding, nil
+	}
+
+	clusterResourceSetBinding.OwnerReferences = util.EnsureOwnerRef(clusterResourceSetBinding.OwnerReferences, metav1.OwnerReference{
+		APIVersion: clusterv1.GroupVersion.String(),
+		Kind:       "ClusterResourceSet",
+		Name:       clusterResourceSet.Name,
+		UID:        clusterResourceSet.UID,
+	})
+	return clusterResourceSetBinding, nil
+}
+
+// clusterToClusterResourceSet maps events from Cluster objects to the
+// ClusterResourceSet objects that select them
+func (r *ClusterResourceSetReconciler) clusterToClusterResourceSet(o handler.MapObject) []reconcile.Request {
+	cluster, ok := o.Object.(*clusterv1.Cluster)
+	if !ok {
+		r.Log.Error(errors.New("incorrect type"), "expected a Cluster", "type", fmt.Sprintf("%T", o.Object))
+		return nil
+	}
+
+	clusterResourceSetList := &clusterv1.ClusterResourceSetList{}
+	if err := r.Client.List(context.TODO(), clusterResourceSetList, client.InNamespace(cluster.Namespace)); err != nil {
+		r.Log.Error(err, "Unable to list ClusterResourceSets", "cluster", cluster.Name, "namespace", cluster.Namespace)
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+	for _, crs := range clusterResourceSetList.Items {
+		selector, err := metav1.LabelSelectorAsSelector(&crs.Spec.ClusterSelector)
+		if err != nil || selector.Empty() || !selector.Matches(labels.Set(cluster.Labels)) {
+			continue
+		}
+		key := types.NamespacedName{Namespace: crs.Namespace, Name: crs.Name}
+		requests = append(requests, reconcile.Request{NamespacedName: key})
+	}
+	return requests
+}
+
+// resourceToClusterResourceSet maps events from Secret and ConfigMap objects to the
+// ClusterResourceSet objects that reference them
+func (r *ClusterResourceSetReconciler) resourceToClusterResourceSet(o handler.MapObject) []reconcile.Request {
+	var kind clusterv1.ClusterResourceSetResourceKind
+	switch o.Object.(type) {
+	case *corev1.ConfigMap:
+		kind = clusterv1.ConfigMapClusterResourceSetResourceKind
+	case *corev1.Secret:
+		kind = clusterv1.SecretClusterResourceSetResourceKind
+	default:
+		r.Log.Error(errors.New("incorrect type"), "expected a ConfigMap or a Secret", "type", fmt.Sprintf("%T", o.Object))
+		return nil
+	}
+
+	clusterResourceSetList := &clusterv1.ClusterResourceSetList{}
+	if err := r.Client.List(context.TODO(), clusterResourceSetList, client.InNamespace(o.Meta.GetNamespace())); err != nil {
+		r.Log.Error(err, "Unable to list ClusterResourceSets", "resource", o.Meta.GetName(), "namespace", o.Meta.GetNamespace())
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+	for _, crs := range clusterResourceSetList.Items {
+		for _, resource := range crs.Spec.Resources {
+			if resource.Kind == kind && resource.Name == o.Meta.GetName() {
+				key := types.NamespacedName{Namespace: crs.Namespace, Name: crs.Name}
+				requests = append(requests, reconcile.Request{NamespacedName: key})
+				break
+			}
+		}
+	}
+	return requests
+}
@@ -0,0 +1,192 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	fakeremote "sigs.k8s.io/cluster-api/controllers/remote/fake"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestClusterResourceSetReconciler(t *testing.T) {
+	newConfigMap := func(value string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "resource", Namespace: "default"},
+			Data: map[string]string{
+				"manifest": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: applied\n  namespace: default\ndata:\n  key: " + value + "\n",
+			},
+		}
+	}
+	newCluster := func(initialized bool) *clusterv1.Cluster {
+		return &clusterv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "default", Labels: map[string]string{"cni": "calico"}},
+			Status:     clusterv1.ClusterStatus{ControlPlaneInitialized: initialized},
+		}
+	}
+	newClusterResourceSet := func(strategy clusterv1.ClusterResourceSetStrategy) *clusterv1.ClusterResourceSet {
+		return &clusterv1.ClusterResourceSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-crs", Namespace: "default"},
+			Spec: clusterv1.ClusterResourceSetSpec{
+				ClusterSelector: metav1.LabelSelector{MatchLabels: map[string]string{"cni": "calico"}},
+				Resources: []clusterv1.ResourceRef{
+					{Name: "resource", Kind: clusterv1.ConfigMapClusterResourceSetResourceKind},
+				},
+				Strategy: strategy,
+			},
+		}
+	}
+	newReconciler := func(objs ...runtime.Object) *ClusterResourceSetReconciler {
+		return &ClusterResourceSetReconciler{
+			Client:             fake.NewFakeClientWithScheme(scheme.Scheme, objs...),
+			Log:                log.Log,
+			remoteClientGetter: fakeremote.NewClusterClient,
+			scheme:             scheme.Scheme,
+		}
+	}
+	request := ctrl.Request{NamespacedName: client.ObjectKey{Namespace: "default", Name: "test-crs"}}
+	key := client.ObjectKey{Namespace: "default", Name: "test-cluster"}
+
+	t.Run("applies the resources to the selected cluster", func(t *testing.T) {
+		g := NewWithT(t)
+
+		r := newReconciler(newConfigMap("value"), newCluster(true), newClusterResourceSet(clusterv1.ClusterResourceSetStrategyApplyOnce))
+		_, err := r.Reconcile(request)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		applied := &corev1.ConfigMap{}
+		g.Expect(r.Client.Get(ctx, client.ObjectKey{Namespace: "default", Name: "applied"}, applied)).To(Succeed())
+		g.Expect(applied.Data).To(HaveKeyWithValue("key", "value"))
+
+		binding := &clusterv1.ClusterResourceSetBinding{}
+		g.Expect(r.Client.Get(ctx, key, binding)).To(Succeed())
+		g.Expect(binding.OwnerReferences).To(HaveLen(2))
+		g.Expect(binding.Spec.Bindings).To(HaveLen(1))
+		g.Expect(binding.Spec.Bindings[0].ClusterResourceSetName).To(Equal("test-crs"))
+		g.Expect(binding.Spec.Bindings[0].Resources).To(HaveLen(1))
+		g.Expect(binding.Spec.Bindings[0].Resources[0].Applied).To(BeTrue())
+		g.Expect(binding.Spec.Bindings[0].Resources[0].Hash).NotTo(BeEmpty())
+
+		crs := &clusterv1.ClusterResourceSet{}
+		g.Expect(r.Client.Get(ctx, request.NamespacedName, crs)).To(Succeed())
+		g.Expect(crs.Finalizers).To(ContainElement(clusterv1.ClusterResourceSetFinalizer))
+		g.Expect(conditions.IsTrue(crs, clusterv1.ResourcesAppliedCondition)).To(BeTrue())
+	})
+
+	t.Run("waits for the control plane to be initialized", func(t *testing.T) {
+		g := NewWithT(t)
+
+		r := newReconciler(newConfigMap("value"), newCluster(false), newClusterResourceSet(clusterv1.ClusterResourceSetStrategyApplyOnce))
+		_, err := r.Reconcile(request)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		err = r.Client.Get(ctx, client.ObjectKey{Namespace: "default", Name: "applied"}, &corev1.ConfigMap{})
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		err = r.Client.Get(ctx, key, &clusterv1.ClusterResourceSetBinding{})
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	t.Run("does not apply changed resources again with the ApplyOnce strategy", func(t *testing.T) {
+		g := NewWithT(t)
+
+		r := newReconciler(newConfigMap("value"), newCluster(true), newClusterResourceSet(clusterv1.ClusterResourceSetStrategyApplyOnce))
+		_, err := r.Reconcile(request)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		g.Expect(r.Client.Update(ctx, newConfigMap("changed"))).To(Succeed())
+		_, err = r.Reconcile(request)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		applied := &corev1.ConfigMap{}
+		g.Expect(r.Client.Get(ctx, client.ObjectKey{Namespace: "default", Name: "applied"}, applied)).To(Succeed())
+		g.Expect(applied.Data).To(HaveKeyWithValue("key", "value"))
+	})
+
+	t.Run("applies changed resources again with the Reconcile strategy", func(t *testing.T) {
+		g := NewWithT(t)
+
+		r := newReconciler(newConfigMap("value"), newCluster(true), newClusterResourceSet(clusterv1.ClusterResourceSetStrategyReconcile))
+		_, err := r.Reconcile(request)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		binding := &clusterv1.ClusterResourceSetBinding{}
+		g.Expect(r.Client.Get(ctx, key, binding)).To(Succeed())
+		hash := binding.Spec.Bindings[0].Resources[0].Hash
+
+		g.Expect(r.Client.Update(ctx, newConfigMap("changed"))).To(Succeed())
+		_, err = r.Reconcile(request)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		applied := &corev1.ConfigMap{}
+		g.Expect(r.Client.Get(ctx, client.ObjectKey{Namespace: "default", Name: "applied"}, applied)).To(Succeed())
+		g.Expect(applied.Data).To(HaveKeyWithValue("key", "changed"))
+
+		g.Expect(r.Client.Get(ctx, key, binding)).To(Succeed())
+		g.Expect(binding.Spec.Bindings[0].Resources[0].Hash).NotTo(Equal(hash))
+	})
+
+	t.Run("rejects Secrets of other types", func(t *testing.T) {
+		g := NewWithT(t)
+
+		crs := newClusterResourceSet(clusterv1.ClusterResourceSetStrategyApplyOnce)
+		crs.Spec.Resources = []clusterv1.ResourceRef{{Name: "credentials", Kind: clusterv1.SecretClusterResourceSetResourceKind}}
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "credentials", Namespace: "default"},
+			Type:       corev1.SecretTypeOpaque,
+		}
+
+		r := newReconciler(secret, newCluster(true), crs)
+		_, err := r.Reconcile(request)
+		g.Expect(err).To(HaveOccurred())
+
+		g.Expect(r.Client.Get(ctx, request.NamespacedName, crs)).To(Succeed())
+		g.Expect(conditions.IsFalse(crs, clusterv1.ResourcesAppliedCondition)).To(BeTrue())
+		g.Expect(conditions.Get(crs, clusterv1.ResourcesAppliedCondition).Reason).To(Equal(clusterv1.RetrievingResourceFailedReason))
+	})
+
+	t.Run("removes the binding on deletion", func(t *testing.T) {
+		g := NewWithT(t)
+
+		r := newReconciler(newConfigMap("value"), newCluster(true), newClusterResourceSet(clusterv1.ClusterResourceSetStrategyApplyOnce))
+		_, err := r.Reconcile(request)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		crs := &clusterv1.ClusterResourceSet{}
+		g.Expect(r.Client.Get(ctx, request.NamespacedName, crs)).To(Succeed())
+		g.Expect(crs.Finalizers).To(ContainElement(clusterv1.ClusterResourceSetFinalizer))
+
+		g.Expect(r.reconcileDelete(ctx, crs)).To(Succeed())
+		g.Expect(crs.Finalizers).NotTo(ContainElement(clusterv1.ClusterResourceSetFinalizer))
+
+		err = r.Client.Get(ctx, key, &clusterv1.ClusterResourceSetBinding{})
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+		// The resources applied to the workload cluster are kept.
+		g.Expect(r.Client.Get(ctx, client.ObjectKey{Namespace: "default", Name: "applied"}, &corev1.ConfigMap{})).To(Succeed())
+	})
+}
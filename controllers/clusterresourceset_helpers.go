@@ -0,0 +1,128 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// yamlSeparator separates the values of a resource, each of which is a manifest of one or more objects.
+var yamlSeparator = []byte("\n---\n")
+
+// getResourceData returns the manifests held by the Secret or ConfigMap of the ClusterResourceSet, with the values
+// joined in the order of their keys.
+func (r *ClusterResourceSetReconciler) getResourceData(ctx context.Context, namespace string, resource clusterv1.ResourceRef) ([]byte, error) {
+	key := client.ObjectKey{Namespace: namespace, Name: resource.Name}
+	values := map[string][]byte{}
+
+	switch resource.Kind {
+	case clusterv1.SecretClusterResourceSetResourceKind:
+		secret := &corev1.Secret{}
+		if err := r.Client.Get(ctx, key, secret); err != nil {
+			return nil, errors.Wrapf(err, "failed to get Secret %s/%s", namespace, resource.Name)
+		}
+		if secret.Type != clusterv1.ClusterResourceSetSecretType {
+			return nil, errors.Errorf("Secret %s/%s has type %q, only %q is supported", namespace, resource.Name, secret.Type, clusterv1.ClusterResourceSetSecretType)
+		}
+		for k, v := range secret.Data {
+			values[k] = v
+		}
+	case clusterv1.ConfigMapClusterResourceSetResourceKind:
+		configMap := &corev1.ConfigMap{}
+		if err := r.Client.Get(ctx, key, configMap); err != nil {
+			return nil, errors.Wrapf(err, "failed to get ConfigMap %s/%s", namespace, resource.Name)
+		}
+		for k, v := range configMap.Data {
+			values[k] = []byte(v)
+		}
+	default:
+		return nil, errors.Errorf("unsupported resource kind %q", resource.Kind)
+	}
+
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	data := make([][]byte, 0, len(keys))
+	for _, k := range keys {
+		data = append(data, values[k])
+	}
+	return bytes.Join(data, yamlSeparator), nil
+}
+
+// computeHash returns the hash of the data of a resource, used to tell whether it changed since it was last applied.
+func computeHash(data []byte) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+}
+
+// applyResourceData creates the objects of the YAML or JSON manifests in the workload cluster. The objects that
+// already exist are updated if reconcile is true, and left as they are otherwise.
+func applyResourceData(ctx context.Context, c client.Client, data []byte, reconcile bool) error {
+	objs := []*unstructured.Unstructured{}
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	for {
+		obj := &unstructured.Unstructured{}
+		if err := decoder.Decode(&obj.Object); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return errors.Wrap(err, "failed to decode manifest")
+		}
+		// Skip the empty documents, e.g. the ones between two separators.
+		if len(obj.Object) == 0 {
+			continue
+		}
+		objs = append(objs, obj)
+	}
+
+	for _, obj := range objs {
+		if err := c.Create(ctx, obj.DeepCopy()); err != nil {
+			if !apierrors.IsAlreadyExists(err) {
+				return errors.Wrapf(err, "failed to create %s %s", obj.GroupVersionKind(), obj.GetName())
+			}
+			if !reconcile {
+				continue
+			}
+
+			existing := &unstructured.Unstructured{}
+			existing.SetGroupVersionKind(obj.GroupVersionKind())
+			if err := c.Get(ctx, client.ObjectKey{Namespace: obj.GetNamespace(), Name: obj.GetName()}, existing); err != nil {
+				return errors.Wrapf(err, "failed to get %s %s", obj.GroupVersionKind(), obj.GetName())
+			}
+			obj.SetResourceVersion(existing.GetResourceVersion())
+			if err := c.Update(ctx, obj); err != nil {
+				return errors.Wrapf(err, "failed to update %s %s", obj.GroupVersionKind(), obj.GetName())
+			}
+		}
+	}
+	return nil
+}
@@ -62,6 +62,10 @@ type CloneTemplateInput struct {
 	// +required
 	Namespace string
 
+	// Name is an optional name of the cloned object. If empty, a name is generated from the name of the template.
+	// +optional
+	Name string
+
 	// ClusterName is the cluster this object is linked to.
 	// +required
 	ClusterName string
@@ -94,7 +98,11 @@ func CloneTemplate(ctx context.Context, in *CloneTemplateInput) (*corev1.ObjectR
 	to.SetFinalizers(nil)
 	to.SetUID("")
 	to.SetSelfLink("")
-	to.SetName(names.SimpleNameGenerator.GenerateName(from.GetName() + "-"))
+	if in.Name != "" {
+		to.SetName(in.Name)
+	} else {
+		to.SetName(names.SimpleNameGenerator.GenerateName(from.GetName() + "-"))
+	}
 	to.SetNamespace(in.Namespace)
 
 	// Set labels.
@@ -261,6 +261,54 @@ func TestCloneTemplateResourceFoundNoOwner(t *testing.T) {
 	g.Expect(cloneSpec).To(Equal(expectedSpec))
 }
 
+func TestCloneTemplateWithName(t *testing.T) {
+	g := NewWithT(t)
+
+	namespace := "test"
+
+	template := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"kind":       "YellowTemplate",
+			"apiVersion": "yellow.io/v1",
+			"metadata": map[string]interface{}{
+				"name":      "yellowTemplate",
+				"namespace": namespace,
+			},
+			"spec": map[string]interface{}{
+				"template": map[string]interface{}{
+					"spec": map[string]interface{}{
+						"hello": "world",
+					},
+				},
+			},
+		},
+	}
+
+	templateRef := &corev1.ObjectReference{
+		Kind:       "YellowTemplate",
+		APIVersion: "yellow.io/v1",
+		Name:       "yellowTemplate",
+		Namespace:  namespace,
+	}
+
+	fakeClient := fake.NewFakeClientWithScheme(runtime.NewScheme(), template.DeepCopy())
+
+	ref, err := CloneTemplate(context.Background(), &CloneTemplateInput{
+		Client:      fakeClient,
+		TemplateRef: templateRef,
+		Namespace:   namespace,
+		Name:        "yellow",
+		ClusterName: "test-cluster",
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ref.Name).To(Equal("yellow"))
+
+	clone := &unstructured.Unstructured{}
+	clone.SetKind("Yellow")
+	clone.SetAPIVersion("yellow.io/v1")
+	g.Expect(fakeClient.Get(context.Background(), client.ObjectKey{Name: "yellow", Namespace: namespace}, clone)).To(Succeed())
+}
+
 func TestCloneTemplateMissingSpecTemplate(t *testing.T) {
 	g := NewWithT(t)
 
@@ -19,6 +19,8 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
@@ -42,13 +44,21 @@ import (
 	capierrors "sigs.k8s.io/cluster-api/errors"
 	kubedrain "sigs.k8s.io/cluster-api/third_party/kubernetes-drain"
 	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/cluster-api/util/predicates"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
+// defaultNodeDrainAttemptTimeout is how long a single attempt at draining the node of a deleted Machine takes
+// if MachineReconciler.NodeDrainAttemptTimeout is not set.
+const defaultNodeDrainAttemptTimeout = 20 * time.Second
+
 var (
 	errNilNodeRef           = errors.New("noderef is nil")
 	errLastControlPlaneNode = errors.New("last control plane member")
@@ -67,10 +77,29 @@ type MachineReconciler struct {
 	Client client.Client
 	Log    logr.Logger
 
+	// NodeDrainAttemptTimeout is how long a single attempt at draining the node of a deleted Machine waits for its
+	// pods to be evicted, before the drain is retried on the next reconcile to allow other Machines to be reconciled.
+	// Evictions respect PodDisruptionBudgets, so a drain may take several attempts; see MachineSpec.NodeDrainTimeout
+	// to bound how long a Machine is drained overall. defaultNodeDrainAttemptTimeout is used if it is zero.
+	NodeDrainAttemptTimeout time.Duration
+
+	// WatchWorkloadNodes makes the reconciler watch the Nodes of every workload cluster with a Machine, so that
+	// NodeRefs are assigned as soon as the Nodes register, instead of polling the workload clusters for them.
+	// This adds a watch per workload cluster.
+	WatchWorkloadNodes bool
+
+	// Tracker holds the clients and caches of the workload clusters, which may be shared with the other controllers
+	// of the management cluster. One is created if it is nil.
+	Tracker *remote.ClusterCacheTracker
+
 	config          *rest.Config
 	scheme          *runtime.Scheme
 	recorder        record.EventRecorder
+	controller      controller.Controller
 	externalTracker external.ObjectTracker
+
+	// remoteClientGetter returns the clients of the workload clusters in place of the Tracker if it is set.
+	remoteClientGetter remote.ClusterClientGetter
 }
 
 func (r *MachineReconciler) SetupWithManager(mgr ctrl.Manager, options controller.Options) error {
@@ -83,12 +112,25 @@ func (r *MachineReconciler) SetupWithManager(mgr ctrl.Manager, options controlle
 		return errors.Wrap(err, "failed setting up with a controller manager")
 	}
 
+	err = controller.Watch(
+		&source.Kind{Type: &clusterv1.Cluster{}},
+		&handler.EnqueueRequestsFromMapFunc{ToRequests: util.ClusterToObjectsMapper(r.Client, &clusterv1.MachineList{})},
+		predicates.ClusterPausedChanged(),
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed adding Watch for Clusters to controller manager")
+	}
+
 	r.recorder = mgr.GetEventRecorderFor("machine-controller")
 	r.config = mgr.GetConfig()
 	r.scheme = mgr.GetScheme()
+	r.controller = controller
 	r.externalTracker = external.ObjectTracker{
 		Controller: controller,
 	}
+	if r.Tracker == nil {
+		r.Tracker = remote.NewClusterCacheTracker(r.Log, r.Client, r.scheme)
+	}
 	return nil
 }
 
@@ -115,24 +157,36 @@ func (r *MachineReconciler) Reconcile(req ctrl.Request) (_ ctrl.Result, reterr e
 			m.Spec.ClusterName, m.Name, m.Namespace)
 	}
 
-	// Return early if the object or Cluster is paused.
-	if util.IsPaused(cluster, m) {
-		logger.V(3).Info("reconciliation is paused for this object")
-		return ctrl.Result{}, nil
-	}
-
 	// Initialize the patch helper
 	patchHelper, err := patch.NewHelper(m, r.Client)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
 
+	// Return early if the object or Cluster is paused.
+	if util.IsPausedWithCondition(cluster, m) {
+		logger.V(3).Info("reconciliation is paused for this object")
+		return ctrl.Result{}, patchHelper.Patch(ctx, m)
+	}
+
 	defer func() {
 		r.reconcilePhase(ctx, m)
 		r.reconcileMetrics(ctx, m)
 
+		// Always summarize the provisioning and the health of the Machine in the Ready condition.
+		conditions.SetSummary(m,
+			conditions.WithConditions(clusterv1.BootstrapReadyCondition, clusterv1.InfrastructureReadyCondition, clusterv1.NodeHealthyCondition),
+		)
+
 		// Always attempt to patch the object and status after each reconciliation.
-		if err := patchHelper.Patch(ctx, m); err != nil {
+		// The conditions reported by this controller take precedence over changes made by other processes.
+		if err := patchHelper.Patch(ctx, m, patch.WithOwnedConditions(
+			clusterv1.ReadyCondition,
+			clusterv1.BootstrapReadyCondition,
+			clusterv1.InfrastructureReadyCondition,
+			clusterv1.NodeHealthyCondition,
+			clusterv1.DrainingSucceededCondition,
+		)); err != nil {
 			reterr = kerrors.NewAggregate([]error{reterr, err})
 		}
 	}()
@@ -169,11 +223,20 @@ func (r *MachineReconciler) reconcile(ctx context.Context, cluster *clusterv1.Cl
 	// If the Machine doesn't have a finalizer, add one.
 	controllerutil.AddFinalizer(m, clusterv1.MachineFinalizer)
 
+	if cluster.Status.ControlPlaneInitialized {
+		if err := r.watchClusterNodes(ctx, cluster); err != nil {
+			// Nodes are still looked up on the next requeue.
+			logger.Error(err, "Failed to watch workload cluster Nodes")
+		}
+	}
+
 	// Call the inner reconciliation methods.
 	reconciliationErrors := []error{
 		r.reconcileBootstrap(ctx, cluster, m),
 		r.reconcileInfrastructure(ctx, cluster, m),
 		r.reconcileNodeRef(ctx, cluster, m),
+		r.reconcileNodeHealth(ctx, cluster, m),
+		r.reconcileNode(ctx, cluster, m),
 	}
 
 	// Parse the errors, making sure we record if there is a RequeueAfterError.
@@ -217,6 +280,17 @@ func (r *MachineReconciler) reconcileDelete(ctx context.Context, cluster *cluste
 	logger := r.Log.WithValues("machine", m.Name, "namespace", m.Namespace)
 	logger = logger.WithValues("cluster", cluster.Name)
 
+	if !cluster.DeletionTimestamp.IsZero() {
+		r.unwatchClusterNodes(cluster)
+	}
+
+	// Let the pre-drain hooks complete before the node is cordoned and drained.
+	if hooks := pendingDeleteHooks(m, clusterv1.PreDrainDeleteHookAnnotationPrefix); len(hooks) > 0 {
+		logger.Info("Waiting for pre-drain delete hooks to complete", "hooks", hooks)
+		return ctrl.Result{}, nil
+	}
+
+	deleteNode := false
 	if err := r.isDeleteNodeAllowed(ctx, m); err != nil {
 		switch err {
 		case errNilNodeRef:
@@ -228,15 +302,43 @@ func (r *MachineReconciler) reconcileDelete(ctx context.Context, cluster *cluste
 			return ctrl.Result{}, err
 		}
 	} else {
-		// Drain node before deletion
-		if _, exists := m.ObjectMeta.Annotations[clusterv1.ExcludeNodeDrainingAnnotation]; !exists {
-			logger.Info("Draining node", "node", m.Status.NodeRef.Name)
-			if err := r.drainNode(ctx, cluster, m.Status.NodeRef.Name, m.Name); err != nil {
-				r.recorder.Eventf(m, corev1.EventTypeWarning, "FailedDrainNode", "error draining Machine's node %q: %v", m.Status.NodeRef.Name, err)
-				return ctrl.Result{}, err
+		// Drain node before deletion, unless it was drained already by a previous reconciliation.
+		if _, exists := m.ObjectMeta.Annotations[clusterv1.ExcludeNodeDrainingAnnotation]; !exists && !conditions.IsTrue(m, clusterv1.DrainingSucceededCondition) {
+			if isNodeDrainTimeoutExceeded(m, time.Now()) {
+				logger.Info("Node drain timeout exceeded, deleting node without draining it", "node", m.Status.NodeRef.Name, "timeout", m.Spec.NodeDrainTimeout.Duration)
+				r.recorder.Eventf(m, corev1.EventTypeWarning, "NodeDrainTimeoutExceeded", "gave up draining Machine's node %q after %s", m.Status.NodeRef.Name, m.Spec.NodeDrainTimeout.Duration)
+				conditions.MarkFalse(m, clusterv1.DrainingSucceededCondition, clusterv1.DrainingTimeoutExceededReason, clusterv1.ConditionSeverityWarning,
+					"gave up draining node %q after %s", m.Status.NodeRef.Name, m.Spec.NodeDrainTimeout.Duration)
+			} else {
+				logger.Info("Draining node", "node", m.Status.NodeRef.Name)
+				if err := r.drainNode(ctx, cluster, m.Status.NodeRef.Name, m.Name); err != nil {
+					r.recorder.Eventf(m, corev1.EventTypeWarning, "FailedDrainNode", "error draining Machine's node %q: %v", m.Status.NodeRef.Name, err)
+					conditions.MarkFalse(m, clusterv1.DrainingSucceededCondition, clusterv1.DrainingFailedReason, clusterv1.ConditionSeverityWarning, "error draining node %q: %v", m.Status.NodeRef.Name, err)
+					return ctrl.Result{}, err
+				}
+				r.recorder.Eventf(m, corev1.EventTypeNormal, "SuccessfulDrainNode", "success draining Machine's node %q", m.Status.NodeRef.Name)
+				conditions.MarkTrue(m, clusterv1.DrainingSucceededCondition)
 			}
-			r.recorder.Eventf(m, corev1.EventTypeNormal, "SuccessfulDrainNode", "success draining Machine's node %q", m.Status.NodeRef.Name)
 		}
+		_, excluded := m.ObjectMeta.Annotations[clusterv1.ExcludeNodeDeletionAnnotation]
+		deleteNode = !excluded
+	}
+
+	// Let the pre-terminate hooks complete before the infrastructure and the node are deleted.
+	if hooks := pendingDeleteHooks(m, clusterv1.PreTerminateDeleteHookAnnotationPrefix); len(hooks) > 0 {
+		logger.Info("Waiting for pre-terminate delete hooks to complete", "hooks", hooks)
+		return ctrl.Result{}, nil
+	}
+
+	if ok, err := r.reconcileDeleteExternal(ctx, m); !ok || err != nil {
+		// Return early and don't remove the finalizer if we got an error or
+		// the external reconciliation deletion isn't ready.
+		return ctrl.Result{}, err
+	}
+
+	// The node is deleted once the infrastructure is gone, so that a kubelet that is still running cannot register it
+	// again, and no stale NotReady node is left behind.
+	if deleteNode {
 		logger.Info("Deleting node", "node", m.Status.NodeRef.Name)
 
 		var deleteNodeErr error
@@ -252,16 +354,23 @@ func (r *MachineReconciler) reconcileDelete(ctx context.Context, cluster *cluste
 		}
 	}
 
-	if ok, err := r.reconcileDeleteExternal(ctx, m); !ok || err != nil {
-		// Return early and don't remove the finalizer if we got an error or
-		// the external reconciliation deletion isn't ready.
-		return ctrl.Result{}, err
-	}
-
 	controllerutil.RemoveFinalizer(m, clusterv1.MachineFinalizer)
 	return ctrl.Result{}, nil
 }
 
+// pendingDeleteHooks returns the sorted names of the delete hooks with the given annotation prefix that the Machine
+// still waits for before its deletion moves on.
+func pendingDeleteHooks(machine *clusterv1.Machine, prefix string) []string {
+	hooks := []string{}
+	for key := range machine.Annotations {
+		if strings.HasPrefix(key, prefix+"/") {
+			hooks = append(hooks, strings.TrimPrefix(key, prefix+"/"))
+		}
+	}
+	sort.Strings(hooks)
+	return hooks
+}
+
 // isDeleteNodeAllowed returns nil only if the Machine's NodeRef is not nil
 // and if the Machine is not the last control plane node in the cluster.
 func (r *MachineReconciler) isDeleteNodeAllowed(ctx context.Context, machine *clusterv1.Machine) error {
@@ -294,6 +403,22 @@ func (r *MachineReconciler) isDeleteNodeAllowed(ctx context.Context, machine *cl
 	}
 }
 
+func (r *MachineReconciler) nodeDrainAttemptTimeout() time.Duration {
+	if r.NodeDrainAttemptTimeout > 0 {
+		return r.NodeDrainAttemptTimeout
+	}
+	return defaultNodeDrainAttemptTimeout
+}
+
+// isNodeDrainTimeoutExceeded returns true if the Machine has a NodeDrainTimeout and it has been deleted for longer
+// than that, in which case its node is deleted without being drained any further.
+func isNodeDrainTimeoutExceeded(machine *clusterv1.Machine, now time.Time) bool {
+	if machine.Spec.NodeDrainTimeout == nil || machine.Spec.NodeDrainTimeout.Duration <= 0 || machine.DeletionTimestamp == nil {
+		return false
+	}
+	return now.Sub(machine.DeletionTimestamp.Time) > machine.Spec.NodeDrainTimeout.Duration
+}
+
 func (r *MachineReconciler) drainNode(ctx context.Context, cluster *clusterv1.Cluster, nodeName string, machineName string) error {
 	logger := r.Log.WithValues("machine", machineName, "node", nodeName, "cluster", cluster.Name, "namespace", cluster.Namespace)
 	var kubeClient kubernetes.Interface
@@ -333,9 +458,9 @@ func (r *MachineReconciler) drainNode(ctx context.Context, cluster *clusterv1.Cl
 		IgnoreAllDaemonSets: true,
 		DeleteLocalData:     true,
 		GracePeriodSeconds:  -1,
-		// If a pod is not evicted in 20 seconds, retry the eviction next time the
+		// If a pod is not evicted in time, retry the eviction next time the
 		// machine gets reconciled again (to allow other machines to be reconciled).
-		Timeout: 20 * time.Second,
+		Timeout: r.nodeDrainAttemptTimeout(),
 		OnPodDeletedOrEvicted: func(pod *corev1.Pod, usingEviction bool) {
 			verbStr := "Deleted"
 			if usingEviction {
@@ -370,11 +495,22 @@ func (r *MachineReconciler) drainNode(ctx context.Context, cluster *clusterv1.Cl
 	return nil
 }
 
+// clusterClient returns a client for the workload cluster, shared through the Tracker if there is one.
+func (r *MachineReconciler) clusterClient(ctx context.Context, cluster *clusterv1.Cluster) (client.Client, error) {
+	if r.remoteClientGetter != nil {
+		return r.remoteClientGetter(ctx, r.Client, cluster, r.scheme)
+	}
+	if r.Tracker != nil {
+		return r.Tracker.GetClient(ctx, cluster)
+	}
+	return remote.NewClusterClient(ctx, r.Client, cluster, r.scheme)
+}
+
 func (r *MachineReconciler) deleteNode(ctx context.Context, cluster *clusterv1.Cluster, name string) error {
 	logger := r.Log.WithValues("machine", name, "cluster", cluster.Name, "namespace", cluster.Namespace)
 
 	// Create a remote client to delete the node
-	c, err := remote.NewClusterClient(ctx, r.Client, cluster, r.scheme)
+	c, err := r.clusterClient(ctx, cluster)
 	if err != nil {
 		logger.Error(err, "Error creating a remote client for cluster while deleting Machine, won't retry")
 		return nil
@@ -18,14 +18,18 @@ package controllers
 
 import (
 	"context"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
 	apicorev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	"sigs.k8s.io/cluster-api/controllers/noderefutil"
-	"sigs.k8s.io/cluster-api/controllers/remote"
 	capierrors "sigs.k8s.io/cluster-api/errors"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/patch"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -64,7 +68,7 @@ func (r *MachineReconciler) reconcileNodeRef(ctx context.Context, cluster *clust
 		return err
 	}
 
-	clusterClient, err := remote.NewClusterClient(ctx, r.Client, cluster, r.scheme)
+	clusterClient, err := r.clusterClient(ctx, cluster)
 	if err != nil {
 		return err
 	}
@@ -73,6 +77,11 @@ func (r *MachineReconciler) reconcileNodeRef(ctx context.Context, cluster *clust
 	nodeRef, err := r.getNodeReference(clusterClient, providerID)
 	if err != nil {
 		if err == ErrNodeNotFound {
+			// The Node watch triggers a reconcile when the Node registers.
+			if r.watchingClusterNodes(cluster) {
+				logger.Info("Cannot assign NodeRef to Machine yet, no matching Node")
+				return nil
+			}
 			return errors.Wrapf(&capierrors.RequeueAfterError{RequeueAfter: 10 * time.Second},
 				"cannot assign NodeRef to Machine %q in namespace %q, no matching Node", machine.Name, machine.Namespace)
 		}
@@ -88,6 +97,179 @@ func (r *MachineReconciler) reconcileNodeRef(ctx context.Context, cluster *clust
 	return nil
 }
 
+// reconcileNodeHealth mirrors the Ready condition of the Machine's node in the NodeHealthy condition of the Machine.
+// The condition is only informational, so failing to look up the node marks it as Unknown without failing the
+// reconciliation.
+func (r *MachineReconciler) reconcileNodeHealth(ctx context.Context, cluster *clusterv1.Cluster, machine *clusterv1.Machine) error {
+	logger := r.Log.WithValues("machine", machine.Name, "namespace", machine.Namespace)
+	// Check that the Machine hasn't been deleted or in the process.
+	if !machine.DeletionTimestamp.IsZero() {
+		return nil
+	}
+
+	if machine.Status.NodeRef == nil {
+		conditions.MarkFalse(machine, clusterv1.NodeHealthyCondition, clusterv1.WaitingForNodeRefReason, clusterv1.ConditionSeverityInfo, "")
+		return nil
+	}
+
+	// Check that Cluster isn't nil.
+	if cluster == nil {
+		return nil
+	}
+
+	clusterClient, err := r.clusterClient(ctx, cluster)
+	if err != nil {
+		logger.Error(err, "Failed to connect to the workload cluster to check the health of the node")
+		conditions.MarkUnknown(machine, clusterv1.NodeHealthyCondition, clusterv1.NodeInspectionFailedReason, "failed to connect to the workload cluster: %v", err)
+		return nil
+	}
+
+	node := &apicorev1.Node{}
+	if err := clusterClient.Get(ctx, client.ObjectKey{Name: machine.Status.NodeRef.Name}, node); err != nil {
+		if apierrors.IsNotFound(err) {
+			conditions.MarkFalse(machine, clusterv1.NodeHealthyCondition, clusterv1.NodeNotFoundReason, clusterv1.ConditionSeverityError, "node %q not found", machine.Status.NodeRef.Name)
+			return nil
+		}
+		logger.Error(err, "Failed to get the node to check its health", "node", machine.Status.NodeRef.Name)
+		conditions.MarkUnknown(machine, clusterv1.NodeHealthyCondition, clusterv1.NodeInspectionFailedReason, "failed to get node %q: %v", machine.Status.NodeRef.Name, err)
+		return nil
+	}
+
+	setNodeHealthyCondition(machine, node)
+	return nil
+}
+
+// setNodeHealthyCondition sets the NodeHealthy condition of the Machine from the Ready condition of its node.
+func setNodeHealthyCondition(machine *clusterv1.Machine, node *apicorev1.Node) {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type != apicorev1.NodeReady {
+			continue
+		}
+		switch condition.Status {
+		case apicorev1.ConditionTrue:
+			conditions.MarkTrue(machine, clusterv1.NodeHealthyCondition)
+		case apicorev1.ConditionFalse:
+			conditions.MarkFalse(machine, clusterv1.NodeHealthyCondition, clusterv1.NodeNotReadyReason, clusterv1.ConditionSeverityWarning, "%s", condition.Message)
+		default:
+			conditions.MarkUnknown(machine, clusterv1.NodeHealthyCondition, clusterv1.NodeNotReadyReason, "%s", condition.Message)
+		}
+		return
+	}
+	conditions.MarkUnknown(machine, clusterv1.NodeHealthyCondition, clusterv1.NodeNotReadyReason, "node %q does not report its readiness yet", node.Name)
+}
+
+// reconcileNode synchronizes the node role labels, and the labels and annotations in the ManagedNodeLabelDomain, of
+// the Machine to its node. The ones synchronized before that were removed from the Machine since are removed from
+// the node. The node of an interruptible Machine is labeled with InterruptibleLabelName.
+func (r *MachineReconciler) reconcileNode(ctx context.Context, cluster *clusterv1.Cluster, machine *clusterv1.Machine) error {
+	// Check that the Machine hasn't been deleted or in the process.
+	if !machine.DeletionTimestamp.IsZero() {
+		return nil
+	}
+
+	// Check that the Machine has a NodeRef, and that the API server of its cluster is up.
+	if machine.Status.NodeRef == nil || cluster == nil || !cluster.Status.ControlPlaneInitialized {
+		return nil
+	}
+
+	clusterClient, err := r.clusterClient(ctx, cluster)
+	if err != nil {
+		return err
+	}
+
+	node := &apicorev1.Node{}
+	if err := clusterClient.Get(ctx, client.ObjectKey{Name: machine.Status.NodeRef.Name}, node); err != nil {
+		if apierrors.IsNotFound(err) {
+			// The NodeHealthy condition reports the missing node.
+			return nil
+		}
+		return errors.Wrapf(err, "failed to get node %q", machine.Status.NodeRef.Name)
+	}
+
+	patchHelper, err := patch.NewHelper(node, clusterClient)
+	if err != nil {
+		return err
+	}
+	syncNodeMetadata(machine, node)
+	if err := patchHelper.Patch(ctx, node); err != nil {
+		return errors.Wrapf(err, "failed to patch node %q", node.Name)
+	}
+	return nil
+}
+
+// syncNodeMetadata synchronizes the managed labels and annotations, and whether it is interruptible, of the Machine
+// to the node.
+func syncNodeMetadata(machine *clusterv1.Machine, node *apicorev1.Node) {
+	if machine.Status.Interruptible {
+		if node.Labels == nil {
+			node.Labels = map[string]string{}
+		}
+		node.Labels[clusterv1.InterruptibleLabelName] = ""
+	} else {
+		delete(node.Labels, clusterv1.InterruptibleLabelName)
+	}
+
+	var labels, annotations []string
+	node.Labels, labels = syncManagedMetadata(node.Labels, machine.Labels, node.Annotations[clusterv1.LabelsFromMachineAnnotation], isManagedNodeLabel)
+	node.Annotations, annotations = syncManagedMetadata(node.Annotations, machine.Annotations, node.Annotations[clusterv1.AnnotationsFromMachineAnnotation], isInManagedNodeLabelDomain)
+
+	for annotation, keys := range map[string][]string{
+		clusterv1.LabelsFromMachineAnnotation:      labels,
+		clusterv1.AnnotationsFromMachineAnnotation: annotations,
+	} {
+		if len(keys) == 0 {
+			delete(node.Annotations, annotation)
+			continue
+		}
+		if node.Annotations == nil {
+			node.Annotations = map[string]string{}
+		}
+		node.Annotations[annotation] = strings.Join(keys, ",")
+	}
+}
+
+// syncManagedMetadata copies the managed entries of from into to, and removes the entries of to that were copied
+// before according to the comma separated keys of previous but are no longer in from.
+// It returns the updated entries, and the sorted keys of the copied ones.
+func syncManagedMetadata(to, from map[string]string, previous string, managed func(string) bool) (map[string]string, []string) {
+	keys := []string{}
+	for key, value := range from {
+		if !managed(key) {
+			continue
+		}
+		if to == nil {
+			to = map[string]string{}
+		}
+		to[key] = value
+		keys = append(keys, key)
+	}
+
+	for _, key := range strings.Split(previous, ",") {
+		if _, ok := from[key]; !ok || !managed(key) {
+			delete(to, key)
+		}
+	}
+
+	sort.Strings(keys)
+	return to, keys
+}
+
+// isManagedNodeLabel returns whether the label of a Machine is synchronized to its node.
+func isManagedNodeLabel(key string) bool {
+	return strings.HasPrefix(key, clusterv1.NodeRoleLabelPrefix+"/") || isInManagedNodeLabelDomain(key)
+}
+
+// isInManagedNodeLabelDomain returns whether the label or annotation key is in the ManagedNodeLabelDomain or one of
+// its subdomains.
+func isInManagedNodeLabelDomain(key string) bool {
+	i := strings.Index(key, "/")
+	if i < 0 {
+		return false
+	}
+	domain := key[:i]
+	return domain == clusterv1.ManagedNodeLabelDomain || strings.HasSuffix(domain, "."+clusterv1.ManagedNodeLabelDomain)
+}
+
 func (r *MachineReconciler) getNodeReference(c client.Client, providerID *noderefutil.ProviderID) (*apicorev1.ObjectReference, error) {
 	logger := r.Log.WithValues("providerID", providerID)
 
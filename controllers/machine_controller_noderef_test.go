@@ -30,6 +30,7 @@ import (
 
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	"sigs.k8s.io/cluster-api/controllers/noderefutil"
+	"sigs.k8s.io/cluster-api/util/conditions"
 )
 
 func TestGetNodeReference(t *testing.T) {
@@ -125,3 +126,211 @@ func TestGetNodeReference(t *testing.T) {
 
 	}
 }
+
+func TestReconcileNodeHealthWithoutNodeRef(t *testing.T) {
+	g := NewWithT(t)
+
+	r := &MachineReconciler{
+		Client: fake.NewFakeClientWithScheme(scheme.Scheme),
+		Log:    log.Log,
+	}
+	machine := &clusterv1.Machine{}
+	g.Expect(r.reconcileNodeHealth(ctx, &clusterv1.Cluster{}, machine)).To(Succeed())
+
+	nodeHealthy := conditions.Get(machine, clusterv1.NodeHealthyCondition)
+	g.Expect(nodeHealthy).ToNot(BeNil())
+	g.Expect(nodeHealthy.Status).To(Equal(corev1.ConditionFalse))
+	g.Expect(nodeHealthy.Reason).To(Equal(clusterv1.WaitingForNodeRefReason))
+}
+
+func TestSetNodeHealthyCondition(t *testing.T) {
+	testCases := []struct {
+		name           string
+		conditions     []corev1.NodeCondition
+		expectedStatus corev1.ConditionStatus
+		expectedReason string
+	}{
+		{
+			name: "node is ready",
+			conditions: []corev1.NodeCondition{
+				{Type: corev1.NodeMemoryPressure, Status: corev1.ConditionFalse},
+				{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+			},
+			expectedStatus: corev1.ConditionTrue,
+		},
+		{
+			name: "node is not ready",
+			conditions: []corev1.NodeCondition{
+				{Type: corev1.NodeReady, Status: corev1.ConditionFalse, Message: "kubelet is not ready"},
+			},
+			expectedStatus: corev1.ConditionFalse,
+			expectedReason: clusterv1.NodeNotReadyReason,
+		},
+		{
+			name: "node readiness is unknown",
+			conditions: []corev1.NodeCondition{
+				{Type: corev1.NodeReady, Status: corev1.ConditionUnknown},
+			},
+			expectedStatus: corev1.ConditionUnknown,
+			expectedReason: clusterv1.NodeNotReadyReason,
+		},
+		{
+			name:           "node does not report its readiness",
+			expectedStatus: corev1.ConditionUnknown,
+			expectedReason: clusterv1.NodeNotReadyReason,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			machine := &clusterv1.Machine{}
+			node := &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+				Status:     corev1.NodeStatus{Conditions: tc.conditions},
+			}
+			setNodeHealthyCondition(machine, node)
+
+			nodeHealthy := conditions.Get(machine, clusterv1.NodeHealthyCondition)
+			g.Expect(nodeHealthy).ToNot(BeNil())
+			g.Expect(nodeHealthy.Status).To(Equal(tc.expectedStatus))
+			g.Expect(nodeHealthy.Reason).To(Equal(tc.expectedReason))
+		})
+	}
+}
+
+func TestSyncNodeMetadata(t *testing.T) {
+	testCases := []struct {
+		name                string
+		machineLabels       map[string]string
+		machineAnnotations  map[string]string
+		interruptible       bool
+		nodeLabels          map[string]string
+		nodeAnnotations     map[string]string
+		expectedLabels      map[string]string
+		expectedAnnotations map[string]string
+	}{
+		{
+			name: "managed labels and annotations are added",
+			machineLabels: map[string]string{
+				clusterv1.ClusterLabelName:         "test-cluster",
+				"node-role.kubernetes.io/worker":   "",
+				"node.cluster.x-k8s.io/tier":       "frontend",
+				"example.node.cluster.x-k8s.io/az": "a",
+			},
+			machineAnnotations: map[string]string{
+				clusterv1.PausedAnnotation:    "",
+				"node.cluster.x-k8s.io/owner": "team-a",
+			},
+			nodeLabels: map[string]string{
+				"kubernetes.io/hostname": "node-1",
+			},
+			expectedLabels: map[string]string{
+				"kubernetes.io/hostname":           "node-1",
+				"node-role.kubernetes.io/worker":   "",
+				"node.cluster.x-k8s.io/tier":       "frontend",
+				"example.node.cluster.x-k8s.io/az": "a",
+			},
+			expectedAnnotations: map[string]string{
+				"node.cluster.x-k8s.io/owner":              "team-a",
+				clusterv1.LabelsFromMachineAnnotation:      "example.node.cluster.x-k8s.io/az,node-role.kubernetes.io/worker,node.cluster.x-k8s.io/tier",
+				clusterv1.AnnotationsFromMachineAnnotation: "node.cluster.x-k8s.io/owner",
+			},
+		},
+		{
+			name: "managed labels are updated and the ones removed from the Machine are removed",
+			machineLabels: map[string]string{
+				"node.cluster.x-k8s.io/tier": "backend",
+			},
+			nodeLabels: map[string]string{
+				"kubernetes.io/hostname":         "node-1",
+				"node-role.kubernetes.io/worker": "",
+				"node.cluster.x-k8s.io/tier":     "frontend",
+				"node.cluster.x-k8s.io/manual":   "true",
+			},
+			nodeAnnotations: map[string]string{
+				"node.cluster.x-k8s.io/owner":              "team-a",
+				clusterv1.LabelsFromMachineAnnotation:      "node-role.kubernetes.io/worker,node.cluster.x-k8s.io/tier",
+				clusterv1.AnnotationsFromMachineAnnotation: "node.cluster.x-k8s.io/owner",
+			},
+			expectedLabels: map[string]string{
+				"kubernetes.io/hostname":       "node-1",
+				"node.cluster.x-k8s.io/tier":   "backend",
+				"node.cluster.x-k8s.io/manual": "true",
+			},
+			expectedAnnotations: map[string]string{
+				clusterv1.LabelsFromMachineAnnotation: "node.cluster.x-k8s.io/tier",
+			},
+		},
+		{
+			name: "interruptible Machine labels its node",
+			machineLabels: map[string]string{
+				"node-role.kubernetes.io/worker": "",
+			},
+			interruptible: true,
+			expectedLabels: map[string]string{
+				clusterv1.InterruptibleLabelName: "",
+				"node-role.kubernetes.io/worker": "",
+			},
+			expectedAnnotations: map[string]string{
+				clusterv1.LabelsFromMachineAnnotation: "node-role.kubernetes.io/worker",
+			},
+		},
+		{
+			name: "interruptible label is removed once the Machine is no longer interruptible",
+			nodeLabels: map[string]string{
+				clusterv1.InterruptibleLabelName: "",
+				"kubernetes.io/hostname":         "node-1",
+			},
+			expectedLabels: map[string]string{
+				"kubernetes.io/hostname": "node-1",
+			},
+		},
+		{
+			name: "labels of other domains are not synchronized",
+			machineLabels: map[string]string{
+				"cluster.x-k8s.io/tier":           "frontend",
+				"node.cluster.x-k8s.io.evil/tier": "frontend",
+				"node-role.kubernetes.io":         "",
+			},
+			nodeLabels: map[string]string{
+				"kubernetes.io/hostname": "node-1",
+			},
+			expectedLabels: map[string]string{
+				"kubernetes.io/hostname": "node-1",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			machine := &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      tc.machineLabels,
+					Annotations: tc.machineAnnotations,
+				},
+				Status: clusterv1.MachineStatus{
+					Interruptible: tc.interruptible,
+				},
+			}
+			node := &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "node-1",
+					Labels:      tc.nodeLabels,
+					Annotations: tc.nodeAnnotations,
+				},
+			}
+			syncNodeMetadata(machine, node)
+
+			g.Expect(node.Labels).To(Equal(tc.expectedLabels))
+			if tc.expectedAnnotations == nil {
+				g.Expect(node.Annotations).To(BeEmpty())
+				return
+			}
+			g.Expect(node.Annotations).To(Equal(tc.expectedAnnotations))
+		})
+	}
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/controllers/noderefutil"
+	"sigs.k8s.io/cluster-api/controllers/remote"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// machineNodeWatchName identifies the Node watch of the Machine controller in the ClusterCacheTracker.
+const machineNodeWatchName = "machine-watchNodes"
+
+// watchClusterNodes starts watching the Nodes of a workload cluster, if WatchWorkloadNodes is set, so that a Node
+// registering or changing readiness promptly triggers a reconcile of the Machine the Node belongs to.
+// There is at most one watch per workload cluster; it runs until unwatchClusterNodes is called.
+func (r *MachineReconciler) watchClusterNodes(ctx context.Context, cluster *clusterv1.Cluster) error {
+	if !r.WatchWorkloadNodes || r.controller == nil || r.Tracker == nil {
+		return nil
+	}
+
+	clusterKey := types.NamespacedName{Namespace: cluster.Namespace, Name: cluster.Name}
+	return r.Tracker.Watch(ctx, remote.WatchInput{
+		Name:         machineNodeWatchName,
+		Cluster:      cluster,
+		Watcher:      r.controller,
+		Kind:         &corev1.Node{},
+		EventHandler: &handler.EnqueueRequestsFromMapFunc{ToRequests: r.nodeToMachines(clusterKey)},
+		Predicates:   []predicate.Predicate{nodeProviderIDOrReadinessChanged()},
+	})
+}
+
+// watchingClusterNodes returns true if the Nodes of the workload cluster are watched, in which case the Machines of
+// the cluster do not need to be requeued to notice their Nodes.
+func (r *MachineReconciler) watchingClusterNodes(cluster *clusterv1.Cluster) bool {
+	if r.Tracker == nil {
+		return false
+	}
+	return r.Tracker.Watching(types.NamespacedName{Namespace: cluster.Namespace, Name: cluster.Name}, machineNodeWatchName)
+}
+
+// unwatchClusterNodes stops watching the Nodes of a workload cluster. The cache of the workload cluster is left
+// running for the other controllers sharing it, and is torn down by the Cluster controller once the cluster is deleted.
+func (r *MachineReconciler) unwatchClusterNodes(cluster *clusterv1.Cluster) {
+	if r.Tracker == nil {
+		return
+	}
+	r.Tracker.Unwatch(types.NamespacedName{Namespace: cluster.Namespace, Name: cluster.Name}, machineNodeWatchName)
+}
+
+// nodeToMachines returns a mapper from the Nodes of a workload cluster to reconcile requests for the Machines whose
+// ProviderID is the Node's ProviderID.
+func (r *MachineReconciler) nodeToMachines(clusterKey types.NamespacedName) handler.ToRequestsFunc {
+	return func(o handler.MapObject) []reconcile.Request {
+		node, ok := o.Object.(*corev1.Node)
+		if !ok {
+			r.Log.Error(errors.Errorf("expected a Node but got a %T", o.Object), "failed to map object to Machine")
+			return nil
+		}
+		nodeProviderID, err := noderefutil.NewProviderID(node.Spec.ProviderID)
+		if err != nil {
+			return nil
+		}
+
+		machines := &clusterv1.MachineList{}
+		if err := r.Client.List(context.Background(), machines,
+			client.InNamespace(clusterKey.Namespace),
+			client.MatchingLabels{clusterv1.ClusterLabelName: clusterKey.Name}); err != nil {
+			r.Log.Error(err, "failed to list Machines", "cluster", clusterKey.Name, "namespace", clusterKey.Namespace)
+			return nil
+		}
+
+		requests := []reconcile.Request{}
+		for _, m := range machines.Items {
+			if m.Spec.ProviderID == nil {
+				continue
+			}
+			pid, err := noderefutil.NewProviderID(*m.Spec.ProviderID)
+			if err != nil || !pid.Equals(nodeProviderID) {
+				continue
+			}
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: m.Namespace, Name: m.Name},
+			})
+		}
+		return requests
+	}
+}
+
+// nodeProviderIDOrReadinessChanged returns a predicate that passes Node creations, deletions, and the updates that
+// change the ProviderID of a Node or whether it is ready.
+func nodeProviderIDOrReadinessChanged() predicate.Funcs {
+	readinessChanged := nodeReadinessChanged()
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldNode, ok := e.ObjectOld.(*corev1.Node)
+			if !ok {
+				return false
+			}
+			newNode, ok := e.ObjectNew.(*corev1.Node)
+			if !ok {
+				return false
+			}
+			return oldNode.Spec.ProviderID != newNode.Spec.ProviderID || readinessChanged.Update(e)
+		},
+		GenericFunc: func(event.GenericEvent) bool { return false },
+	}
+}
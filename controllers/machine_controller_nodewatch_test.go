@@ -0,0 +1,126 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+)
+
+func TestMachineNodeToMachines(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(clusterv1.AddToScheme(scheme.Scheme)).To(Succeed())
+
+	machine := func(name, clusterName string, providerID *string) *clusterv1.Machine {
+		return &clusterv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Labels:    map[string]string{clusterv1.ClusterLabelName: clusterName},
+			},
+			Spec: clusterv1.MachineSpec{ProviderID: providerID},
+		}
+	}
+	r := &MachineReconciler{
+		Client: fake.NewFakeClientWithScheme(scheme.Scheme,
+			machine("first-machine", "test-cluster", pointer.StringPtr("aws://us-east-1/id-node-1")),
+			machine("second-machine", "test-cluster", pointer.StringPtr("aws://us-east-1/id-node-2")),
+			machine("pending-machine", "test-cluster", nil),
+			machine("other-cluster-machine", "other-cluster", pointer.StringPtr("aws://us-east-1/id-node-1")),
+		),
+		Log: log.Log,
+	}
+	toRequests := r.nodeToMachines(types.NamespacedName{Namespace: "default", Name: "test-cluster"})
+
+	node := func(providerID string) handler.MapObject {
+		n := &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node"},
+			Spec:       corev1.NodeSpec{ProviderID: providerID},
+		}
+		return handler.MapObject{Meta: n, Object: n}
+	}
+
+	g.Expect(toRequests(node("aws://us-east-1/id-node-1"))).To(ConsistOf(
+		reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "first-machine"}},
+	))
+	g.Expect(toRequests(node("aws://us-east-1/id-node-2"))).To(ConsistOf(
+		reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "second-machine"}},
+	))
+	g.Expect(toRequests(node("aws://us-east-1/id-node-3"))).To(BeEmpty())
+	g.Expect(toRequests(node(""))).To(BeEmpty())
+}
+
+func TestMachineNodeProviderIDOrReadinessChanged(t *testing.T) {
+	node := func(providerID string, status corev1.ConditionStatus) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node"},
+			Spec:       corev1.NodeSpec{ProviderID: providerID},
+			Status: corev1.NodeStatus{
+				Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: status}},
+			},
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		oldNode  *corev1.Node
+		newNode  *corev1.Node
+		expected bool
+	}{
+		{name: "node is unchanged", oldNode: node("aws://us-east-1/id-node-1", corev1.ConditionTrue), newNode: node("aws://us-east-1/id-node-1", corev1.ConditionTrue), expected: false},
+		{name: "node gets a provider ID", oldNode: node("", corev1.ConditionFalse), newNode: node("aws://us-east-1/id-node-1", corev1.ConditionFalse), expected: true},
+		{name: "node becomes ready", oldNode: node("aws://us-east-1/id-node-1", corev1.ConditionFalse), newNode: node("aws://us-east-1/id-node-1", corev1.ConditionTrue), expected: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			update := event.UpdateEvent{MetaOld: tc.oldNode, ObjectOld: tc.oldNode, MetaNew: tc.newNode, ObjectNew: tc.newNode}
+			g.Expect(nodeProviderIDOrReadinessChanged().Update(update)).To(Equal(tc.expected))
+		})
+	}
+}
+
+func TestMachineWatchClusterNodes(t *testing.T) {
+	g := NewWithT(t)
+
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "default"}}
+
+	// Watching is a no-op when disabled, and the Machines keep being requeued until their Node registers.
+	r := &MachineReconciler{Client: fake.NewFakeClientWithScheme(scheme.Scheme), Log: log.Log}
+	g.Expect(r.watchClusterNodes(context.TODO(), cluster)).To(Succeed())
+	g.Expect(r.watchingClusterNodes(cluster)).To(BeFalse())
+
+	// Unwatching a cluster that is not watched is a no-op.
+	r.unwatchClusterNodes(cluster)
+	g.Expect(r.watchingClusterNodes(cluster)).To(BeFalse())
+}
@@ -35,6 +35,7 @@ import (
 	"sigs.k8s.io/cluster-api/controllers/external"
 	capierrors "sigs.k8s.io/cluster-api/errors"
 	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/patch"
 )
 
@@ -165,6 +166,7 @@ func (r *MachineReconciler) reconcileBootstrap(ctx context.Context, cluster *clu
 	// If the bootstrap data is populated, set ready and return.
 	if m.Spec.Bootstrap.Data != nil || m.Spec.Bootstrap.DataSecretName != nil {
 		m.Status.BootstrapReady = true
+		conditions.MarkTrue(m, clusterv1.BootstrapReadyCondition)
 		return nil
 	}
 
@@ -178,6 +180,9 @@ func (r *MachineReconciler) reconcileBootstrap(ctx context.Context, cluster *clu
 	if err != nil {
 		return err
 	} else if !ready {
+		conditions.SetMirror(m, clusterv1.BootstrapReadyCondition, conditions.UnstructuredGetter(bootstrapConfig),
+			conditions.WithFallbackValue(false, clusterv1.WaitingForDataSecretReason, clusterv1.ConditionSeverityInfo,
+				"%s %q is not ready", bootstrapConfig.GetKind(), bootstrapConfig.GetName()))
 		return errors.Wrapf(&capierrors.RequeueAfterError{RequeueAfter: externalReadyWait},
 			"Bootstrap provider for Machine %q in namespace %q is not ready, requeuing", m.Name, m.Namespace)
 	}
@@ -192,6 +197,7 @@ func (r *MachineReconciler) reconcileBootstrap(ctx context.Context, cluster *clu
 
 	m.Spec.Bootstrap.DataSecretName = pointer.StringPtr(secretName)
 	m.Status.BootstrapReady = true
+	conditions.MarkTrue(m, clusterv1.BootstrapReadyCondition)
 	return nil
 }
 
@@ -206,6 +212,8 @@ func (r *MachineReconciler) reconcileInfrastructure(ctx context.Context, cluster
 			m.Status.FailureReason = capierrors.MachineStatusErrorPtr(capierrors.InvalidConfigurationMachineError)
 			m.Status.FailureMessage = pointer.StringPtr(fmt.Sprintf("Machine infrastructure resource %v with name %q has been deleted after being ready",
 				m.Spec.InfrastructureRef.GroupVersionKind(), m.Spec.InfrastructureRef.Name))
+			conditions.MarkFalse(m, clusterv1.InfrastructureReadyCondition, clusterv1.InfrastructureDeletedReason, clusterv1.ConditionSeverityError,
+				"%s %q has been deleted after being ready", m.Spec.InfrastructureRef.Kind, m.Spec.InfrastructureRef.Name)
 		}
 		return err
 	}
@@ -226,6 +234,9 @@ func (r *MachineReconciler) reconcileInfrastructure(ctx context.Context, cluster
 	}
 	m.Status.InfrastructureReady = ready
 	if !ready {
+		conditions.SetMirror(m, clusterv1.InfrastructureReadyCondition, conditions.UnstructuredGetter(infraConfig),
+			conditions.WithFallbackValue(false, clusterv1.WaitingForInfrastructureReason, clusterv1.ConditionSeverityInfo,
+				"%s %q is not ready", infraConfig.GetKind(), infraConfig.GetName()))
 		return errors.Wrapf(&capierrors.RequeueAfterError{RequeueAfter: externalReadyWait},
 			"Infrastructure provider for Machine %q in namespace %q is not ready, requeuing", m.Name, m.Namespace,
 		)
@@ -245,6 +256,14 @@ func (r *MachineReconciler) reconcileInfrastructure(ctx context.Context, cluster
 		return errors.Wrapf(err, "failed to retrieve addresses from infrastructure provider for Machine %q in namespace %q", m.Name, m.Namespace)
 	}
 
+	// Get and set Status.Interruptible from the infrastructure provider.
+	var interruptible bool
+	err = util.UnstructuredUnmarshalField(infraConfig, &interruptible, "status", "interruptible")
+	if err != nil && err != util.ErrUnstructuredFieldNotFound {
+		return errors.Wrapf(err, "failed to retrieve interruptible from infrastructure provider for Machine %q in namespace %q", m.Name, m.Namespace)
+	}
+	m.Status.Interruptible = interruptible
+
 	// Get and set the failure domain from the infrastructure provider.
 	var failureDomain string
 	err = util.UnstructuredUnmarshalField(infraConfig, &failureDomain, "spec", "failureDomain")
@@ -257,5 +276,6 @@ func (r *MachineReconciler) reconcileInfrastructure(ctx context.Context, cluster
 	}
 
 	m.Spec.ProviderID = pointer.StringPtr(providerID)
+	conditions.MarkTrue(m, clusterv1.InfrastructureReadyCondition)
 	return nil
 }
@@ -32,6 +32,7 @@ import (
 	"k8s.io/utils/pointer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	"sigs.k8s.io/cluster-api/controllers/external"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/kubeconfig"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/log"
@@ -226,6 +227,9 @@ var _ = Describe("Reconcile Machine Phases", func() {
 		err = unstructured.SetNestedField(infraConfig.Object, "us-east-2a", "spec", "failureDomain")
 		Expect(err).NotTo(HaveOccurred())
 
+		err = unstructured.SetNestedField(infraConfig.Object, true, "status", "interruptible")
+		Expect(err).NotTo(HaveOccurred())
+
 		err = unstructured.SetNestedField(infraConfig.Object, []interface{}{
 			map[string]interface{}{
 				"type":    "InternalIP",
@@ -260,6 +264,7 @@ var _ = Describe("Reconcile Machine Phases", func() {
 		Expect(res.Requeue).To(BeFalse())
 		Expect(machine.Status.Addresses).To(HaveLen(2))
 		Expect(*machine.Spec.FailureDomain).To(Equal("us-east-2a"))
+		Expect(machine.Status.Interruptible).To(BeTrue())
 
 		r.reconcilePhase(context.Background(), machine)
 		Expect(machine.Status.GetTypedPhase()).To(Equal(clusterv1.MachinePhaseRunning))
@@ -517,6 +522,7 @@ func TestReconcileBootstrap(t *testing.T) {
 				g.Expect(m.Status.BootstrapReady).To(BeTrue())
 				g.Expect(m.Spec.Bootstrap.DataSecretName).ToNot(BeNil())
 				g.Expect(*m.Spec.Bootstrap.DataSecretName).To(ContainSubstring("secret-data"))
+				g.Expect(conditions.IsTrue(m, clusterv1.BootstrapReadyCondition)).To(BeTrue())
 			},
 		},
 		{
@@ -554,6 +560,36 @@ func TestReconcileBootstrap(t *testing.T) {
 			expectError: true,
 			expected: func(g *WithT, m *clusterv1.Machine) {
 				g.Expect(m.Status.BootstrapReady).To(BeFalse())
+				g.Expect(conditions.Get(m, clusterv1.BootstrapReadyCondition).Reason).To(Equal(clusterv1.WaitingForDataSecretReason))
+			},
+		},
+		{
+			name: "new machine, bootstrap config not ready with a ready condition",
+			bootstrapConfig: map[string]interface{}{
+				"kind":       "BootstrapMachine",
+				"apiVersion": "bootstrap.cluster.x-k8s.io/v1alpha3",
+				"metadata": map[string]interface{}{
+					"name":      "bootstrap-config1",
+					"namespace": "default",
+				},
+				"spec": map[string]interface{}{},
+				"status": map[string]interface{}{
+					"conditions": []interface{}{
+						map[string]interface{}{
+							"type":     "Ready",
+							"status":   "False",
+							"severity": "Warning",
+							"reason":   "CertificatesGenerationFailed",
+						},
+					},
+				},
+			},
+			expectError: true,
+			expected: func(g *WithT, m *clusterv1.Machine) {
+				g.Expect(m.Status.BootstrapReady).To(BeFalse())
+				bootstrapReady := conditions.Get(m, clusterv1.BootstrapReadyCondition)
+				g.Expect(bootstrapReady.Reason).To(Equal("CertificatesGenerationFailed"))
+				g.Expect(bootstrapReady.Severity).To(Equal(clusterv1.ConditionSeverityWarning))
 			},
 		},
 		{
@@ -776,6 +812,7 @@ func TestReconcileInfrastructure(t *testing.T) {
 			expectChanged: true,
 			expected: func(g *WithT, m *clusterv1.Machine) {
 				g.Expect(m.Status.InfrastructureReady).To(BeTrue())
+				g.Expect(conditions.IsTrue(m, clusterv1.InfrastructureReadyCondition)).To(BeTrue())
 			},
 		},
 		{
@@ -830,6 +867,7 @@ func TestReconcileInfrastructure(t *testing.T) {
 				g.Expect(m.Status.FailureMessage).ToNot(BeNil())
 				g.Expect(m.Status.FailureReason).ToNot(BeNil())
 				g.Expect(m.Status.GetTypedPhase()).To(Equal(clusterv1.MachinePhaseFailed))
+				g.Expect(conditions.Get(m, clusterv1.InfrastructureReadyCondition).Reason).To(Equal(clusterv1.InfrastructureDeletedReason))
 			},
 		},
 		{
@@ -19,14 +19,17 @@ package controllers
 import (
 	"context"
 	"testing"
+	"time"
 
 	. "github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/utils/pointer"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
@@ -36,6 +39,7 @@ import (
 
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	"sigs.k8s.io/cluster-api/controllers/external"
+	"sigs.k8s.io/cluster-api/util/conditions"
 )
 
 func TestMachineFinalizer(t *testing.T) {
@@ -568,6 +572,289 @@ func TestRemoveMachineFinalizerAfterDeleteReconcile(t *testing.T) {
 	g.Expect(m.ObjectMeta.Finalizers).To(Equal([]string{metav1.FinalizerDeleteDependents}))
 }
 
+func TestMachineDeleteHooks(t *testing.T) {
+	dt := metav1.Now()
+	testCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+
+	tests := []struct {
+		name               string
+		annotations        map[string]string
+		expectedFinalizers []string
+	}{
+		{
+			name: "pending pre-drain hook",
+			annotations: map[string]string{
+				clusterv1.PreDrainDeleteHookAnnotationPrefix + "/detach-storage": "",
+			},
+			expectedFinalizers: []string{clusterv1.MachineFinalizer, metav1.FinalizerDeleteDependents},
+		},
+		{
+			name: "pending pre-terminate hook",
+			annotations: map[string]string{
+				clusterv1.PreTerminateDeleteHookAnnotationPrefix + "/update-cmdb": "",
+			},
+			expectedFinalizers: []string{clusterv1.MachineFinalizer, metav1.FinalizerDeleteDependents},
+		},
+		{
+			name: "no pending hooks",
+			annotations: map[string]string{
+				"pre-drain.delete.hook.machine.cluster.x-k8s.io": "",
+			},
+			expectedFinalizers: []string{metav1.FinalizerDeleteDependents},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			m := &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "delete123",
+					Namespace:         "default",
+					Annotations:       tc.annotations,
+					Finalizers:        []string{clusterv1.MachineFinalizer, metav1.FinalizerDeleteDependents},
+					DeletionTimestamp: &dt,
+				},
+				Spec: clusterv1.MachineSpec{
+					ClusterName: "test-cluster",
+					InfrastructureRef: corev1.ObjectReference{
+						APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+						Kind:       "InfrastructureMachine",
+						Name:       "infra-config1",
+					},
+					Bootstrap: clusterv1.Bootstrap{Data: pointer.StringPtr("data")},
+				},
+			}
+			key := client.ObjectKey{Namespace: m.Namespace, Name: m.Name}
+			mr := &MachineReconciler{
+				Client: fake.NewFakeClientWithScheme(scheme.Scheme, testCluster, m),
+				Log:    log.Log,
+				scheme: scheme.Scheme,
+			}
+			_, err := mr.Reconcile(reconcile.Request{NamespacedName: key})
+			g.Expect(err).ToNot(HaveOccurred())
+
+			g.Expect(mr.Client.Get(ctx, key, m)).To(Succeed())
+			g.Expect(m.ObjectMeta.Finalizers).To(Equal(tc.expectedFinalizers))
+		})
+	}
+}
+
+func TestMachineReconcileDeleteNode(t *testing.T) {
+	dt := metav1.Now()
+	testCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+	controlPlaneMachine := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "control-plane",
+			Namespace: "default",
+			Labels: map[string]string{
+				clusterv1.ClusterLabelName:             "test-cluster",
+				clusterv1.MachineControlPlaneLabelName: "",
+			},
+		},
+		Spec: clusterv1.MachineSpec{ClusterName: "test-cluster"},
+	}
+	infraMachine := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"kind":       "InfrastructureMachine",
+			"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+			"metadata": map[string]interface{}{
+				"name":      "infra-config1",
+				"namespace": "default",
+			},
+		},
+	}
+	newMachine := func(annotations map[string]string) *clusterv1.Machine {
+		return &clusterv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "delete123",
+				Namespace:         "default",
+				Labels:            map[string]string{clusterv1.ClusterLabelName: "test-cluster"},
+				Annotations:       annotations,
+				Finalizers:        []string{clusterv1.MachineFinalizer},
+				DeletionTimestamp: &dt,
+			},
+			Spec: clusterv1.MachineSpec{
+				ClusterName: "test-cluster",
+				InfrastructureRef: corev1.ObjectReference{
+					APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+					Kind:       "InfrastructureMachine",
+					Name:       "infra-config1",
+				},
+				Bootstrap: clusterv1.Bootstrap{Data: pointer.StringPtr("data")},
+			},
+			Status: clusterv1.MachineStatus{
+				NodeRef: &corev1.ObjectReference{Name: "worker-node"},
+			},
+		}
+	}
+	newReconciler := func(m *clusterv1.Machine, workloadClient client.Client) (*MachineReconciler, *record.FakeRecorder) {
+		recorder := record.NewFakeRecorder(10)
+		return &MachineReconciler{
+			Client:   fake.NewFakeClientWithScheme(scheme.Scheme, testCluster, controlPlaneMachine, m, infraMachine.DeepCopy()),
+			Log:      log.Log,
+			scheme:   scheme.Scheme,
+			recorder: recorder,
+			remoteClientGetter: func(context.Context, client.Client, *clusterv1.Cluster, *runtime.Scheme) (client.Client, error) {
+				return workloadClient, nil
+			},
+		}, recorder
+	}
+	nodeExists := func(g *WithT, workloadClient client.Client) bool {
+		err := workloadClient.Get(ctx, client.ObjectKey{Name: "worker-node"}, &corev1.Node{})
+		if apierrors.IsNotFound(err) {
+			return false
+		}
+		g.Expect(err).NotTo(HaveOccurred())
+		return true
+	}
+
+	t.Run("node is deleted after the infrastructure", func(t *testing.T) {
+		g := NewWithT(t)
+
+		m := newMachine(nil)
+		workloadClient := fake.NewFakeClientWithScheme(scheme.Scheme, &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "worker-node"}})
+		r, _ := newReconciler(m, workloadClient)
+
+		// The infrastructure is deleted first, while the node is left alone.
+		_, err := r.reconcileDelete(ctx, testCluster, m)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(nodeExists(g, workloadClient)).To(BeTrue())
+		g.Expect(m.Finalizers).To(ContainElement(clusterv1.MachineFinalizer))
+
+		// Once the infrastructure is gone, the node is deleted.
+		_, err = r.reconcileDelete(ctx, testCluster, m)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(nodeExists(g, workloadClient)).To(BeFalse())
+		g.Expect(m.Finalizers).NotTo(ContainElement(clusterv1.MachineFinalizer))
+	})
+
+	t.Run("node deletion is skipped with the exclude annotation", func(t *testing.T) {
+		g := NewWithT(t)
+
+		m := newMachine(map[string]string{clusterv1.ExcludeNodeDeletionAnnotation: ""})
+		workloadClient := fake.NewFakeClientWithScheme(scheme.Scheme, &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "worker-node"}})
+		r, _ := newReconciler(m, workloadClient)
+
+		_, err := r.reconcileDelete(ctx, testCluster, m)
+		g.Expect(err).NotTo(HaveOccurred())
+		_, err = r.reconcileDelete(ctx, testCluster, m)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(nodeExists(g, workloadClient)).To(BeTrue())
+		g.Expect(m.Finalizers).NotTo(ContainElement(clusterv1.MachineFinalizer))
+	})
+
+	t.Run("node is drained once", func(t *testing.T) {
+		g := NewWithT(t)
+
+		m := newMachine(nil)
+		workloadClient := fake.NewFakeClientWithScheme(scheme.Scheme, &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "worker-node"}})
+		r, recorder := newReconciler(m, workloadClient)
+
+		// The node is drained by the first reconciliation, which waits for the infrastructure to be deleted.
+		_, err := r.reconcileDelete(ctx, testCluster, m)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(conditions.IsTrue(m, clusterv1.DrainingSucceededCondition)).To(BeTrue())
+		g.Expect(recorder.Events).To(Receive(ContainSubstring("SuccessfulDrainNode")))
+
+		// The next reconciliation does not drain the node again.
+		_, err = r.reconcileDelete(ctx, testCluster, m)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(recorder.Events).NotTo(Receive(ContainSubstring("DrainNode")))
+	})
+}
+
+func TestPendingDeleteHooks(t *testing.T) {
+	g := NewWithT(t)
+
+	m := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				clusterv1.PreDrainDeleteHookAnnotationPrefix + "/update-cmdb":        "",
+				clusterv1.PreDrainDeleteHookAnnotationPrefix + "/detach-storage":     "storage-controller",
+				clusterv1.PreTerminateDeleteHookAnnotationPrefix + "/release-ip":     "",
+				"pre-drain.delete.hook.machine.cluster.x-k8s.io.example.com/not-one": "",
+			},
+		},
+	}
+	g.Expect(pendingDeleteHooks(m, clusterv1.PreDrainDeleteHookAnnotationPrefix)).To(Equal([]string{"detach-storage", "update-cmdb"}))
+	g.Expect(pendingDeleteHooks(m, clusterv1.PreTerminateDeleteHookAnnotationPrefix)).To(Equal([]string{"release-ip"}))
+	g.Expect(pendingDeleteHooks(&clusterv1.Machine{}, clusterv1.PreDrainDeleteHookAnnotationPrefix)).To(BeEmpty())
+}
+
+func TestNodeDrainAttemptTimeout(t *testing.T) {
+	g := NewWithT(t)
+
+	r := &MachineReconciler{}
+	g.Expect(r.nodeDrainAttemptTimeout()).To(Equal(defaultNodeDrainAttemptTimeout))
+
+	r.NodeDrainAttemptTimeout = time.Minute
+	g.Expect(r.nodeDrainAttemptTimeout()).To(Equal(time.Minute))
+}
+
+func TestIsNodeDrainTimeoutExceeded(t *testing.T) {
+	deletionTimestamp := metav1.NewTime(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		name              string
+		deletionTimestamp *metav1.Time
+		nodeDrainTimeout  *metav1.Duration
+		now               time.Time
+		expected          bool
+	}{
+		{
+			name:              "no node drain timeout",
+			deletionTimestamp: &deletionTimestamp,
+			now:               deletionTimestamp.Add(time.Hour),
+			expected:          false,
+		},
+		{
+			name:              "zero node drain timeout",
+			deletionTimestamp: &deletionTimestamp,
+			nodeDrainTimeout:  &metav1.Duration{},
+			now:               deletionTimestamp.Add(time.Hour),
+			expected:          false,
+		},
+		{
+			name:             "not deleted",
+			nodeDrainTimeout: &metav1.Duration{Duration: time.Minute},
+			now:              deletionTimestamp.Add(time.Hour),
+			expected:         false,
+		},
+		{
+			name:              "within the node drain timeout",
+			deletionTimestamp: &deletionTimestamp,
+			nodeDrainTimeout:  &metav1.Duration{Duration: time.Minute},
+			now:               deletionTimestamp.Add(30 * time.Second),
+			expected:          false,
+		},
+		{
+			name:              "node drain timeout exceeded",
+			deletionTimestamp: &deletionTimestamp,
+			nodeDrainTimeout:  &metav1.Duration{Duration: time.Minute},
+			now:               deletionTimestamp.Add(2 * time.Minute),
+			expected:          true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			machine := &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{DeletionTimestamp: tt.deletionTimestamp},
+				Spec:       clusterv1.MachineSpec{NodeDrainTimeout: tt.nodeDrainTimeout},
+			}
+			g.Expect(isNodeDrainTimeoutExceeded(machine, tt.now)).To(Equal(tt.expected))
+		})
+	}
+}
+
 func TestReconcileMetrics(t *testing.T) {
 	tests := []struct {
 		name            string
@@ -19,6 +19,7 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
@@ -31,6 +32,7 @@ import (
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	"sigs.k8s.io/cluster-api/util"
 	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/cluster-api/util/predicates"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
@@ -58,7 +60,7 @@ type MachineDeploymentReconciler struct {
 }
 
 func (r *MachineDeploymentReconciler) SetupWithManager(mgr ctrl.Manager, options controller.Options) error {
-	err := ctrl.NewControllerManagedBy(mgr).
+	c, err := ctrl.NewControllerManagedBy(mgr).
 		For(&clusterv1.MachineDeployment{}).
 		Owns(&clusterv1.MachineSet{}).
 		Watches(
@@ -66,12 +68,21 @@ func (r *MachineDeploymentReconciler) SetupWithManager(mgr ctrl.Manager, options
 			&handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(r.MachineSetToDeployments)},
 		).
 		WithOptions(options).
-		Complete(r)
+		Build(r)
 
 	if err != nil {
 		return errors.Wrap(err, "failed setting up with a controller manager")
 	}
 
+	err = c.Watch(
+		&source.Kind{Type: &clusterv1.Cluster{}},
+		&handler.EnqueueRequestsFromMapFunc{ToRequests: util.ClusterToObjectsMapper(r.Client, &clusterv1.MachineDeploymentList{})},
+		predicates.ClusterPausedChanged(),
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed adding Watch for Clusters to controller manager")
+	}
+
 	r.recorder = mgr.GetEventRecorderFor("machinedeployment-controller")
 	return nil
 }
@@ -97,18 +108,18 @@ func (r *MachineDeploymentReconciler) Reconcile(req ctrl.Request) (_ ctrl.Result
 		return ctrl.Result{}, err
 	}
 
-	// Return early if the object or Cluster is paused.
-	if util.IsPaused(cluster, deployment) {
-		logger.V(3).Info("reconciliation is paused for this object")
-		return ctrl.Result{}, nil
-	}
-
 	// Initialize the patch helper
 	patchHelper, err := patch.NewHelper(deployment, r.Client)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
 
+	// Return early if the object or Cluster is paused.
+	if util.IsPausedWithCondition(cluster, deployment) {
+		logger.V(3).Info("reconciliation is paused for this object")
+		return ctrl.Result{}, patchHelper.Patch(ctx, deployment)
+	}
+
 	defer func() {
 		// Always attempt to patch the object and status after each reconciliation.
 		if err := patchHelper.Patch(ctx, deployment); err != nil {
@@ -173,6 +184,11 @@ func (r *MachineDeploymentReconciler) reconcile(ctx context.Context, cluster *cl
 		}
 	}
 
+	// Keep the cluster autoscaler capacity annotations in sync with the infrastructure template.
+	if _, err := reconcileCapacityAnnotations(ctx, r.Client, d, &d.Spec.Template.Spec.InfrastructureRef); err != nil {
+		return ctrl.Result{}, err
+	}
+
 	msList, err := r.getMachineSetsForDeployment(d)
 	if err != nil {
 		return ctrl.Result{}, err
@@ -182,11 +198,26 @@ func (r *MachineDeploymentReconciler) reconcile(ctx context.Context, cluster *cl
 		return ctrl.Result{}, r.sync(d, msList)
 	}
 
-	if d.Spec.Strategy.Type == clusterv1.RollingUpdateMachineDeploymentStrategyType {
-		return ctrl.Result{}, r.rolloutRolling(d, msList)
+	// The template that is rolled back to is rolled out once the deployment is updated.
+	if _, ok := d.Annotations[clusterv1.RollbackToRevisionAnnotation]; ok {
+		r.rollback(d, msList)
+		return ctrl.Result{}, nil
+	}
+
+	switch d.Spec.Strategy.Type {
+	case clusterv1.RollingUpdateMachineDeploymentStrategyType:
+		err = r.rolloutRolling(d, msList)
+	case clusterv1.OnDeleteMachineDeploymentStrategyType:
+		err = r.rolloutOnDelete(d, msList)
+	default:
+		return ctrl.Result{}, errors.Errorf("unexpected deployment strategy type: %s", d.Spec.Strategy.Type)
 	}
 
-	return ctrl.Result{}, errors.Errorf("unexpected deployment strategy type: %s", d.Spec.Strategy.Type)
+	// Check the progress deadline again once it passes, as a stalled rollout does not trigger any event.
+	if deadline, ok := progressDeadline(d); ok && err == nil {
+		return ctrl.Result{RequeueAfter: time.Until(deadline) + time.Second}, nil
+	}
+	return ctrl.Result{}, err
 }
 
 // getMachineSetsForDeployment returns a list of MachineSets associated with a MachineDeployment.
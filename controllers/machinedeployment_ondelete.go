@@ -0,0 +1,141 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pkg/errors"
+	"k8s.io/utils/integer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/controllers/mdutil"
+	"sigs.k8s.io/cluster-api/util/patch"
+)
+
+// rolloutOnDelete implements the logic for the OnDelete strategy: the machines of the old machine sets are only
+// replaced by machines of the new machine set as they are deleted by users.
+func (r *MachineDeploymentReconciler) rolloutOnDelete(d *clusterv1.MachineDeployment, msList []*clusterv1.MachineSet) error {
+	newMS, oldMSs, err := r.getAllMachineSetsAndSyncRevision(d, msList, true)
+	if err != nil {
+		return err
+	}
+
+	// newMS can be nil in case there is already a MachineSet associated with this deployment,
+	// but there are only either changes in annotations or MinReadySeconds. Or in other words,
+	// this can be nil if there are changes, but no replacement of existing machines is needed.
+	if newMS == nil {
+		return nil
+	}
+
+	allMSs := append(oldMSs, newMS)
+
+	// Scale down the old machine sets by the number of machines they lost.
+	if err := r.reconcileOldMachineSetsOnDelete(allMSs, oldMSs, d); err != nil {
+		return err
+	}
+
+	if err := r.syncDeploymentStatus(allMSs, newMS, d); err != nil {
+		return err
+	}
+
+	// Scale up the new machine set to replace them.
+	if err := r.setMachineCreateDisabled(newMS, false); err != nil {
+		return err
+	}
+	if err := r.reconcileNewMachineSet(allMSs, newMS, d); err != nil {
+		return err
+	}
+
+	if err := r.syncDeploymentStatus(allMSs, newMS, d); err != nil {
+		return err
+	}
+
+	if mdutil.DeploymentComplete(d, &d.Status) {
+		if err := r.cleanupDeployment(oldMSs, d); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reconcileOldMachineSetsOnDelete disables machine creation on the old machine sets, and scales them down to the
+// number of machines they have left. If the deployment has more replicas than desired, e.g. after it was scaled
+// down, the oldest machine sets are scaled down further.
+func (r *MachineDeploymentReconciler) reconcileOldMachineSetsOnDelete(allMSs []*clusterv1.MachineSet, oldMSs []*clusterv1.MachineSet, deployment *clusterv1.MachineDeployment) error {
+	logger := r.Log.WithValues("machinedeployment", deployment.Name, "namespace", deployment.Namespace)
+
+	if deployment.Spec.Replicas == nil {
+		return errors.Errorf("spec replicas for MachineDeployment %q/%q is nil, this is unexpected",
+			deployment.Namespace, deployment.Name)
+	}
+
+	excess := mdutil.GetReplicaCountForMachineSets(allMSs) - *(deployment.Spec.Replicas)
+
+	sort.Sort(mdutil.MachineSetsByCreationTimestamp(oldMSs))
+	for _, oldMS := range oldMSs {
+		if oldMS.Spec.Replicas == nil {
+			return errors.Errorf("spec replicas for MachineSet %q/%q is nil, this is unexpected",
+				oldMS.Namespace, oldMS.Name)
+		}
+
+		if err := r.setMachineCreateDisabled(oldMS, true); err != nil {
+			return err
+		}
+
+		// Deleted machines are excluded from the replicas in the status, and no longer replaced.
+		newReplicas := integer.Int32Min(*(oldMS.Spec.Replicas), oldMS.Status.Replicas)
+		excess -= *(oldMS.Spec.Replicas) - newReplicas
+		if excess > 0 {
+			scaleDown := integer.Int32Min(excess, newReplicas)
+			newReplicas -= scaleDown
+			excess -= scaleDown
+		}
+
+		if newReplicas == *(oldMS.Spec.Replicas) {
+			continue
+		}
+		logger.V(4).Info("Scaling down old MachineSet", "machineset", oldMS.Name, "replicas", newReplicas)
+		if err := r.scaleMachineSet(oldMS, newReplicas, deployment); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setMachineCreateDisabled sets whether the machine set may create machines to replace the ones it lost.
+func (r *MachineDeploymentReconciler) setMachineCreateDisabled(ms *clusterv1.MachineSet, disabled bool) error {
+	if (ms.Annotations[clusterv1.DisableMachineCreateAnnotation] == "true") == disabled {
+		return nil
+	}
+
+	patchHelper, err := patch.NewHelper(ms, r.Client)
+	if err != nil {
+		return err
+	}
+	if disabled {
+		if ms.Annotations == nil {
+			ms.Annotations = map[string]string{}
+		}
+		ms.Annotations[clusterv1.DisableMachineCreateAnnotation] = "true"
+	} else {
+		delete(ms.Annotations, clusterv1.DisableMachineCreateAnnotation)
+	}
+	return patchHelper.Patch(context.Background(), ms)
+}
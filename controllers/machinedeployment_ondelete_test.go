@@ -0,0 +1,121 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestReconcileOldMachineSetsOnDelete(t *testing.T) {
+	now := time.Now()
+	machineSet := func(name string, created time.Time, specReplicas, statusReplicas int32) *clusterv1.MachineSet {
+		return &clusterv1.MachineSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Namespace:         "default",
+				CreationTimestamp: metav1.NewTime(created),
+			},
+			Spec: clusterv1.MachineSetSpec{
+				Replicas: pointer.Int32Ptr(specReplicas),
+			},
+			Status: clusterv1.MachineSetStatus{
+				Replicas: statusReplicas,
+			},
+		}
+	}
+
+	testCases := []struct {
+		name             string
+		replicas         int32
+		oldMSs           []*clusterv1.MachineSet
+		newMS            *clusterv1.MachineSet
+		expectedReplicas map[string]int32
+	}{
+		{
+			name:     "old machine sets are scaled down by the number of deleted machines",
+			replicas: 5,
+			oldMSs: []*clusterv1.MachineSet{
+				machineSet("old-1", now.Add(-2*time.Hour), 3, 2),
+				machineSet("old-2", now.Add(-time.Hour), 2, 2),
+			},
+			newMS: machineSet("new", now, 1, 1),
+			expectedReplicas: map[string]int32{
+				"old-1": 2,
+				"old-2": 2,
+			},
+		},
+		{
+			name:     "oldest machine sets are scaled down first when the deployment is scaled down",
+			replicas: 3,
+			oldMSs: []*clusterv1.MachineSet{
+				machineSet("old-2", now.Add(-time.Hour), 2, 2),
+				machineSet("old-1", now.Add(-2*time.Hour), 3, 3),
+			},
+			newMS: machineSet("new", now, 0, 0),
+			expectedReplicas: map[string]int32{
+				"old-1": 1,
+				"old-2": 2,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			g.Expect(clusterv1.AddToScheme(scheme.Scheme)).To(Succeed())
+
+			deployment := &clusterv1.MachineDeployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "md", Namespace: "default"},
+				Spec: clusterv1.MachineDeploymentSpec{
+					Replicas: pointer.Int32Ptr(tc.replicas),
+					Strategy: &clusterv1.MachineDeploymentStrategy{
+						Type: clusterv1.OnDeleteMachineDeploymentStrategyType,
+					},
+				},
+			}
+			c := fake.NewFakeClientWithScheme(scheme.Scheme, tc.oldMSs[0], tc.oldMSs[1], tc.newMS)
+			r := &MachineDeploymentReconciler{
+				Client:   c,
+				Log:      log.Log,
+				recorder: record.NewFakeRecorder(32),
+			}
+
+			allMSs := append([]*clusterv1.MachineSet{}, tc.oldMSs...)
+			allMSs = append(allMSs, tc.newMS)
+			g.Expect(r.reconcileOldMachineSetsOnDelete(allMSs, tc.oldMSs, deployment)).To(Succeed())
+
+			for name, replicas := range tc.expectedReplicas {
+				ms := &clusterv1.MachineSet{}
+				g.Expect(c.Get(ctx, client.ObjectKey{Namespace: "default", Name: name}, ms)).To(Succeed())
+				g.Expect(*ms.Spec.Replicas).To(Equal(replicas), "replicas of MachineSet %s", name)
+				g.Expect(ms.Annotations).To(HaveKeyWithValue(clusterv1.DisableMachineCreateAnnotation, "true"))
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/controllers/mdutil"
+)
+
+// rollback rolls the deployment back to the machine template of the revision in its RollbackToRevisionAnnotation,
+// which is then removed. The template is rolled out on the next reconcile, as for any other template change.
+func (r *MachineDeploymentReconciler) rollback(d *clusterv1.MachineDeployment, msList []*clusterv1.MachineSet) {
+	logger := r.Log.WithValues("machinedeployment", d.Name, "namespace", d.Namespace)
+
+	value := d.Annotations[clusterv1.RollbackToRevisionAnnotation]
+	delete(d.Annotations, clusterv1.RollbackToRevisionAnnotation)
+
+	toRevision, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || toRevision < 0 {
+		r.recorder.Eventf(d, corev1.EventTypeWarning, "RollbackInvalidRevision", "Invalid revision %q to roll back to", value)
+		return
+	}
+	if toRevision == 0 {
+		toRevision = mdutil.LastRevision(msList, logger)
+		if toRevision == 0 {
+			r.recorder.Eventf(d, corev1.EventTypeWarning, "RollbackRevisionNotFound", "Unable to find the revision to roll back to")
+			return
+		}
+	}
+
+	for _, ms := range msList {
+		revision, err := mdutil.Revision(ms)
+		if err != nil || revision != toRevision {
+			continue
+		}
+
+		template := ms.Spec.Template.DeepCopy()
+		// The hash label is added back to the template of the machine set it is rolled out with.
+		delete(template.Labels, mdutil.DefaultMachineDeploymentUniqueLabelKey)
+		if mdutil.EqualMachineTemplate(&d.Spec.Template, template) {
+			r.recorder.Eventf(d, corev1.EventTypeNormal, "RollbackTemplateUnchanged", "The template of revision %d is already the current one", toRevision)
+			return
+		}
+
+		logger.Info("Rolling back to revision", "revision", toRevision, "machineset", ms.Name)
+		d.Spec.Template = *template
+		r.recorder.Eventf(d, corev1.EventTypeNormal, "RollbackDone", "Rolled back to revision %d", toRevision)
+		return
+	}
+
+	r.recorder.Eventf(d, corev1.EventTypeWarning, "RollbackRevisionNotFound", "Unable to find revision %d to roll back to", toRevision)
+}
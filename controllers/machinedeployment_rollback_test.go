@@ -0,0 +1,120 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/controllers/mdutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestMachineDeploymentRollback(t *testing.T) {
+	machineSet := func(revision, version string) *clusterv1.MachineSet {
+		return &clusterv1.MachineSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "ms-" + revision,
+				Annotations: map[string]string{clusterv1.RevisionAnnotation: revision},
+			},
+			Spec: clusterv1.MachineSetSpec{
+				Template: clusterv1.MachineTemplateSpec{
+					ObjectMeta: clusterv1.ObjectMeta{
+						Labels: map[string]string{
+							"app": "worker",
+							mdutil.DefaultMachineDeploymentUniqueLabelKey: "hash-" + revision,
+						},
+					},
+					Spec: clusterv1.MachineSpec{
+						Version: pointer.StringPtr(version),
+					},
+				},
+			},
+		}
+	}
+	msList := []*clusterv1.MachineSet{
+		machineSet("1", "v1.17.0"),
+		machineSet("2", "v1.17.3"),
+		machineSet("3", "v1.18.0"),
+	}
+
+	testCases := []struct {
+		name            string
+		rollbackTo      string
+		expectedVersion string
+	}{
+		{
+			name:            "rolls back to the given revision",
+			rollbackTo:      "1",
+			expectedVersion: "v1.17.0",
+		},
+		{
+			name:            "rolls back to the previous revision",
+			rollbackTo:      "0",
+			expectedVersion: "v1.17.3",
+		},
+		{
+			name:            "unknown revisions are ignored",
+			rollbackTo:      "7",
+			expectedVersion: "v1.18.0",
+		},
+		{
+			name:            "invalid revisions are ignored",
+			rollbackTo:      "latest",
+			expectedVersion: "v1.18.0",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			d := &clusterv1.MachineDeployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "md",
+					Annotations: map[string]string{
+						clusterv1.RevisionAnnotation:           "3",
+						clusterv1.RollbackToRevisionAnnotation: tc.rollbackTo,
+					},
+				},
+				Spec: clusterv1.MachineDeploymentSpec{
+					Template: clusterv1.MachineTemplateSpec{
+						ObjectMeta: clusterv1.ObjectMeta{
+							Labels: map[string]string{"app": "worker"},
+						},
+						Spec: clusterv1.MachineSpec{
+							Version: pointer.StringPtr("v1.18.0"),
+						},
+					},
+				},
+			}
+			r := &MachineDeploymentReconciler{
+				Log:      log.Log,
+				recorder: record.NewFakeRecorder(32),
+			}
+			r.rollback(d, msList)
+
+			g.Expect(d.Annotations).ToNot(HaveKey(clusterv1.RollbackToRevisionAnnotation))
+			g.Expect(*d.Spec.Template.Spec.Version).To(Equal(tc.expectedVersion))
+			g.Expect(d.Spec.Template.Labels).To(Equal(map[string]string{"app": "worker"}))
+		})
+	}
+}
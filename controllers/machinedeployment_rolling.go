@@ -42,6 +42,10 @@ func (r *MachineDeploymentReconciler) rolloutRolling(d *clusterv1.MachineDeploym
 	allMSs := append(oldMSs, newMS)
 
 	// Scale up, if we can.
+	// The new machine set may have been an old one of the OnDelete strategy, which did not create machines.
+	if err := r.setMachineCreateDisabled(newMS, false); err != nil {
+		return err
+	}
 	if err := r.reconcileNewMachineSet(allMSs, newMS, d); err != nil {
 		return err
 	}
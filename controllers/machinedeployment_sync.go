@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"sort"
 	"strconv"
+	"time"
 
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
@@ -32,6 +33,7 @@ import (
 	"k8s.io/client-go/util/retry"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	"sigs.k8s.io/cluster-api/controllers/mdutil"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/patch"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -341,10 +343,77 @@ func (r *MachineDeploymentReconciler) scale(deployment *clusterv1.MachineDeploym
 
 // syncDeploymentStatus checks if the status is up-to-date and sync it if necessary
 func (r *MachineDeploymentReconciler) syncDeploymentStatus(allMSs []*clusterv1.MachineSet, newMS *clusterv1.MachineSet, d *clusterv1.MachineDeployment) error {
-	d.Status = calculateStatus(allMSs, newMS, d)
+	newStatus := calculateStatus(allMSs, newMS, d)
+	newStatus.Conditions = d.Status.Conditions
+	progressed := hasProgressed(&d.Status, &newStatus)
+
+	d.Status = newStatus
+	setDeploymentConditions(d, progressed, time.Now())
 	return nil
 }
 
+// hasProgressed returns true if the rollout of the deployment made progress between the given statuses, i.e. a
+// machine set was updated, or machines were created, updated, became ready or available.
+func hasProgressed(oldStatus, newStatus *clusterv1.MachineDeploymentStatus) bool {
+	return newStatus.ObservedGeneration != oldStatus.ObservedGeneration ||
+		newStatus.Replicas != oldStatus.Replicas ||
+		newStatus.UpdatedReplicas != oldStatus.UpdatedReplicas ||
+		newStatus.ReadyReplicas != oldStatus.ReadyReplicas ||
+		newStatus.AvailableReplicas != oldStatus.AvailableReplicas
+}
+
+// setDeploymentConditions sets the Available and Progressing conditions of the deployment from its status.
+//
+// The Progressing condition is True as long as the rollout makes progress, and its last transition time is reset
+// every time it does; once it made no progress for ProgressDeadlineSeconds, the condition is set to False with the
+// ProgressDeadlineExceeded reason. The deadline does not apply to the OnDelete strategy, which only makes progress
+// when users delete old machines.
+func setDeploymentConditions(d *clusterv1.MachineDeployment, progressed bool, now time.Time) {
+	minAvailable := *(d.Spec.Replicas) - mdutil.MaxUnavailable(*d)
+	if d.Status.AvailableReplicas >= minAvailable {
+		conditions.MarkTrue(d, clusterv1.MachineDeploymentAvailableCondition)
+	} else {
+		conditions.MarkFalse(d, clusterv1.MachineDeploymentAvailableCondition, clusterv1.MinimumReplicasUnavailableReason, clusterv1.ConditionSeverityWarning,
+			"%d of minimum %d machines are available", d.Status.AvailableReplicas, minAvailable)
+	}
+
+	progressing := conditions.Get(d, clusterv1.MachineDeploymentProgressingCondition)
+	switch {
+	case d.Spec.Paused:
+		conditions.MarkUnknown(d, clusterv1.MachineDeploymentProgressingCondition, clusterv1.MachineDeploymentPausedReason,
+			"MachineDeployment is paused")
+	case mdutil.DeploymentComplete(d, &d.Status):
+		conditions.MarkTrue(d, clusterv1.MachineDeploymentProgressingCondition)
+	case progressed:
+		// Deleting the condition resets its last transition time, which is when the deadline started.
+		conditions.Delete(d, clusterv1.MachineDeploymentProgressingCondition)
+		conditions.MarkTrue(d, clusterv1.MachineDeploymentProgressingCondition)
+	case progressing == nil || progressing.Status == corev1.ConditionUnknown:
+		conditions.MarkTrue(d, clusterv1.MachineDeploymentProgressingCondition)
+	default:
+		if deadline, ok := progressDeadline(d); ok && !now.Before(deadline) {
+			conditions.MarkFalse(d, clusterv1.MachineDeploymentProgressingCondition, clusterv1.ProgressDeadlineExceededReason, clusterv1.ConditionSeverityError,
+				"MachineDeployment made no progress for %d seconds", *d.Spec.ProgressDeadlineSeconds)
+		}
+	}
+}
+
+// progressDeadline returns the time by which the rollout of the deployment must make progress, and false if the
+// deployment has no deadline or no rollout in progress.
+func progressDeadline(d *clusterv1.MachineDeployment) (time.Time, bool) {
+	if d.Spec.ProgressDeadlineSeconds == nil || d.Spec.Paused ||
+		d.Spec.Strategy == nil || d.Spec.Strategy.Type == clusterv1.OnDeleteMachineDeploymentStrategyType ||
+		mdutil.DeploymentComplete(d, &d.Status) {
+		return time.Time{}, false
+	}
+
+	progressing := conditions.Get(d, clusterv1.MachineDeploymentProgressingCondition)
+	if progressing == nil || progressing.Status != corev1.ConditionTrue {
+		return time.Time{}, false
+	}
+	return progressing.LastTransitionTime.Add(time.Duration(*d.Spec.ProgressDeadlineSeconds) * time.Second), true
+}
+
 // calculateStatus calculates the latest status for the provided deployment by looking into the provided machine sets.
 func calculateStatus(allMSs []*clusterv1.MachineSet, newMS *clusterv1.MachineSet, deployment *clusterv1.MachineDeployment) clusterv1.MachineDeploymentStatus {
 	availableReplicas := mdutil.GetAvailableReplicaCountForMachineSets(allMSs)
@@ -17,12 +17,17 @@ limitations under the License.
 package controllers
 
 import (
+	"reflect"
 	"testing"
+	"time"
 
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/utils/pointer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	capierrors "sigs.k8s.io/cluster-api/errors"
+	"sigs.k8s.io/cluster-api/util/conditions"
 )
 
 func TestMachineDeploymentSyncStatus(t *testing.T) {
@@ -212,10 +217,130 @@ func TestMachineDeploymentSyncStatus(t *testing.T) {
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
 			actualStatus := calculateStatus(test.machineSets, test.newMachineSet, test.deployment)
-			if actualStatus != test.expectedStatus {
+			if !reflect.DeepEqual(actualStatus, test.expectedStatus) {
 				t.Errorf("Expected %+v but got %+v", test.expectedStatus, actualStatus)
 			}
 		})
 
 	}
 }
+
+func TestMachineDeploymentSetConditions(t *testing.T) {
+	now := time.Now()
+
+	newDeployment := func(strategyType clusterv1.MachineDeploymentStrategyType, availableReplicas int32) *clusterv1.MachineDeployment {
+		return &clusterv1.MachineDeployment{
+			Spec: clusterv1.MachineDeploymentSpec{
+				Replicas:                pointer.Int32Ptr(3),
+				ProgressDeadlineSeconds: pointer.Int32Ptr(600),
+				Strategy: &clusterv1.MachineDeploymentStrategy{
+					Type: strategyType,
+					RollingUpdate: &clusterv1.MachineRollingUpdateDeployment{
+						MaxUnavailable: intOrStrPtr(1),
+						MaxSurge:       intOrStrPtr(1),
+					},
+				},
+			},
+			Status: clusterv1.MachineDeploymentStatus{
+				Replicas:          3,
+				UpdatedReplicas:   1,
+				AvailableReplicas: availableReplicas,
+			},
+		}
+	}
+	progressingSince := func(d *clusterv1.MachineDeployment, since time.Time) {
+		d.Status.Conditions = clusterv1.Conditions{
+			{
+				Type:               clusterv1.MachineDeploymentProgressingCondition,
+				Status:             corev1.ConditionTrue,
+				LastTransitionTime: metav1.NewTime(since),
+			},
+		}
+	}
+
+	t.Run("available and progressing rollout", func(t *testing.T) {
+		g := NewWithT(t)
+
+		d := newDeployment(clusterv1.RollingUpdateMachineDeploymentStrategyType, 2)
+		setDeploymentConditions(d, false, now)
+
+		g.Expect(conditions.IsTrue(d, clusterv1.MachineDeploymentAvailableCondition)).To(BeTrue())
+		g.Expect(conditions.IsTrue(d, clusterv1.MachineDeploymentProgressingCondition)).To(BeTrue())
+
+		deadline, ok := progressDeadline(d)
+		g.Expect(ok).To(BeTrue())
+		g.Expect(deadline).To(BeTemporally(">", now))
+	})
+
+	t.Run("minimum replicas unavailable", func(t *testing.T) {
+		g := NewWithT(t)
+
+		d := newDeployment(clusterv1.RollingUpdateMachineDeploymentStrategyType, 1)
+		setDeploymentConditions(d, false, now)
+
+		available := conditions.Get(d, clusterv1.MachineDeploymentAvailableCondition)
+		g.Expect(available.Status).To(Equal(corev1.ConditionFalse))
+		g.Expect(available.Reason).To(Equal(clusterv1.MinimumReplicasUnavailableReason))
+	})
+
+	t.Run("stalled rollout exceeds the progress deadline", func(t *testing.T) {
+		g := NewWithT(t)
+
+		d := newDeployment(clusterv1.RollingUpdateMachineDeploymentStrategyType, 2)
+		progressingSince(d, now.Add(-11*time.Minute))
+		setDeploymentConditions(d, false, now)
+
+		progressing := conditions.Get(d, clusterv1.MachineDeploymentProgressingCondition)
+		g.Expect(progressing.Status).To(Equal(corev1.ConditionFalse))
+		g.Expect(progressing.Reason).To(Equal(clusterv1.ProgressDeadlineExceededReason))
+
+		_, ok := progressDeadline(d)
+		g.Expect(ok).To(BeFalse())
+	})
+
+	t.Run("progress resets the progress deadline", func(t *testing.T) {
+		g := NewWithT(t)
+
+		d := newDeployment(clusterv1.RollingUpdateMachineDeploymentStrategyType, 2)
+		progressingSince(d, now.Add(-11*time.Minute))
+		setDeploymentConditions(d, true, now)
+
+		progressing := conditions.Get(d, clusterv1.MachineDeploymentProgressingCondition)
+		g.Expect(progressing.Status).To(Equal(corev1.ConditionTrue))
+		g.Expect(progressing.LastTransitionTime.Time).To(BeTemporally(">", now.Add(-time.Minute)))
+	})
+
+	t.Run("OnDelete rollouts have no progress deadline", func(t *testing.T) {
+		g := NewWithT(t)
+
+		d := newDeployment(clusterv1.OnDeleteMachineDeploymentStrategyType, 3)
+		progressingSince(d, now.Add(-11*time.Minute))
+		setDeploymentConditions(d, false, now)
+
+		g.Expect(conditions.IsTrue(d, clusterv1.MachineDeploymentProgressingCondition)).To(BeTrue())
+	})
+
+	t.Run("paused deployment", func(t *testing.T) {
+		g := NewWithT(t)
+
+		d := newDeployment(clusterv1.RollingUpdateMachineDeploymentStrategyType, 2)
+		d.Spec.Paused = true
+		setDeploymentConditions(d, false, now)
+
+		progressing := conditions.Get(d, clusterv1.MachineDeploymentProgressingCondition)
+		g.Expect(progressing.Status).To(Equal(corev1.ConditionUnknown))
+		g.Expect(progressing.Reason).To(Equal(clusterv1.MachineDeploymentPausedReason))
+	})
+
+	t.Run("complete rollout", func(t *testing.T) {
+		g := NewWithT(t)
+
+		d := newDeployment(clusterv1.RollingUpdateMachineDeploymentStrategyType, 3)
+		d.Status.UpdatedReplicas = 3
+		progressingSince(d, now.Add(-11*time.Minute))
+		setDeploymentConditions(d, false, now)
+
+		g.Expect(conditions.IsTrue(d, clusterv1.MachineDeploymentAvailableCondition)).To(BeTrue())
+		g.Expect(conditions.IsTrue(d, clusterv1.MachineDeploymentProgressingCondition)).To(BeTrue())
+	})
+}
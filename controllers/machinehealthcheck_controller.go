@@ -25,13 +25,17 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	kerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/client-go/tools/record"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/controllers/remote"
 	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/cluster-api/util/predicates"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
@@ -44,13 +48,23 @@ const (
 	mhcClusterNameIndex = "spec.clusterName"
 )
 
+// +kubebuilder:rbac:groups=core,resources=events,verbs=get;list;watch;create;patch
+// +kubebuilder:rbac:groups=core,resources=nodes,verbs=get;list;watch
+// +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machines;machines/status,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machinehealthchecks;machinehealthchecks/status,verbs=get;list;watch;update;patch
+
 // MachineHealthCheckReconciler reconciles a MachineHealthCheck object
 type MachineHealthCheckReconciler struct {
 	Client client.Client
 	Log    logr.Logger
 
+	// Tracker holds the clients and caches of the workload clusters, which may be shared with the other controllers
+	// watching them.
+	Tracker *remote.ClusterCacheTracker
+
 	controller controller.Controller
 	recorder   record.EventRecorder
+	scheme     *runtime.Scheme
 }
 
 func (r *MachineHealthCheckReconciler) SetupWithManager(mgr ctrl.Manager, options controller.Options) error {
@@ -60,6 +74,10 @@ func (r *MachineHealthCheckReconciler) SetupWithManager(mgr ctrl.Manager, option
 			&source.Kind{Type: &clusterv1.Cluster{}},
 			&handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(r.clusterToMachineHealthCheck)},
 		).
+		Watches(
+			&source.Kind{Type: &clusterv1.Machine{}},
+			&handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(r.machineToMachineHealthCheck)},
+		).
 		WithOptions(options).
 		Build(r)
 
@@ -67,6 +85,15 @@ func (r *MachineHealthCheckReconciler) SetupWithManager(mgr ctrl.Manager, option
 		return errors.Wrap(err, "failed setting up with a controller manager")
 	}
 
+	err = controller.Watch(
+		&source.Kind{Type: &clusterv1.Cluster{}},
+		&handler.EnqueueRequestsFromMapFunc{ToRequests: util.ClusterToObjectsMapper(r.Client, &clusterv1.MachineHealthCheckList{})},
+		predicates.ClusterPausedChanged(),
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed adding Watch for Clusters to controller manager")
+	}
+
 	// Add index to MachineHealthCheck for listing by Cluster Name
 	if err := mgr.GetCache().IndexField(&clusterv1.MachineHealthCheck{},
 		mhcClusterNameIndex,
@@ -77,6 +104,10 @@ func (r *MachineHealthCheckReconciler) SetupWithManager(mgr ctrl.Manager, option
 
 	r.controller = controller
 	r.recorder = mgr.GetEventRecorderFor("machinehealthcheck-controller")
+	r.scheme = mgr.GetScheme()
+	if r.Tracker == nil {
+		r.Tracker = remote.NewClusterCacheTracker(r.Log, r.Client, r.scheme)
+	}
 	return nil
 }
 
@@ -105,12 +136,6 @@ func (r *MachineHealthCheckReconciler) Reconcile(req ctrl.Request) (_ ctrl.Resul
 			m.Spec.ClusterName, m.Name, m.Namespace)
 	}
 
-	// Return early if the object or Cluster is paused.
-	if util.IsPaused(cluster, m) {
-		logger.V(3).Info("reconciliation is paused for this object")
-		return ctrl.Result{}, nil
-	}
-
 	// Initialize the patch helper
 	patchHelper, err := patch.NewHelper(m, r.Client)
 	if err != nil {
@@ -118,6 +143,12 @@ func (r *MachineHealthCheckReconciler) Reconcile(req ctrl.Request) (_ ctrl.Resul
 		return ctrl.Result{}, err
 	}
 
+	// Return early if the object or Cluster is paused.
+	if util.IsPausedWithCondition(cluster, m) {
+		logger.V(3).Info("reconciliation is paused for this object")
+		return ctrl.Result{}, patchHelper.Patch(ctx, m)
+	}
+
 	defer func() {
 		// Always attempt to patch the object and status after each reconciliation.
 		if err := patchHelper.Patch(ctx, m); err != nil {
@@ -135,14 +166,13 @@ func (r *MachineHealthCheckReconciler) Reconcile(req ctrl.Request) (_ ctrl.Resul
 	if err != nil {
 		logger.Error(err, "Failed to reconcile MachineHealthCheck")
 		r.recorder.Eventf(m, corev1.EventTypeWarning, "ReconcileError", "%v", err)
-
-		//TODO(JoelSpeed): Determine how/when to requeue requests if errors occur within r.reconcile
+		return ctrl.Result{}, err
 	}
 
 	return result, nil
 }
 
-func (r *MachineHealthCheckReconciler) reconcile(_ context.Context, cluster *clusterv1.Cluster, m *clusterv1.MachineHealthCheck) (ctrl.Result, error) {
+func (r *MachineHealthCheckReconciler) reconcile(ctx context.Context, cluster *clusterv1.Cluster, m *clusterv1.MachineHealthCheck) (ctrl.Result, error) {
 	// Ensure the MachineHealthCheck is owned by the Cluster it belongs to
 	m.OwnerReferences = util.EnsureOwnerRef(m.OwnerReferences, metav1.OwnerReference{
 		APIVersion: clusterv1.GroupVersion.String(),
@@ -151,7 +181,79 @@ func (r *MachineHealthCheckReconciler) reconcile(_ context.Context, cluster *clu
 		UID:        cluster.UID,
 	})
 
-	return ctrl.Result{}, fmt.Errorf("controller not yet implemented")
+	logger := r.Log.WithValues("machinehealthcheck", m.Name, "namespace", m.Namespace, "cluster", cluster.Name)
+
+	// The Nodes of the targets cannot be checked until the control plane is up.
+	if !cluster.Status.ControlPlaneInitialized {
+		logger.V(3).Info("Skipping health check until the control plane is initialized")
+		return ctrl.Result{}, nil
+	}
+
+	clusterClient, err := r.clusterClient(ctx, cluster)
+	if err != nil {
+		return ctrl.Result{}, errors.Wrapf(err, "failed to create client for Cluster %q in namespace %q", cluster.Name, cluster.Namespace)
+	}
+
+	targets, err := r.getTargetsFromMHC(ctx, clusterClient, m)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	healthy, unhealthy, nextCheck := r.healthCheckTargets(logger, targets)
+	m.Status.ExpectedMachines = int32(len(targets))
+	m.Status.CurrentHealthy = int32(len(healthy))
+
+	if err := r.remediateUnhealthy(ctx, m, unhealthy); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Check the targets again when one of their unhealthy conditions or node startup timeouts elapses.
+	if nextCheck > 0 {
+		return ctrl.Result{RequeueAfter: nextCheck}, nil
+	}
+	return ctrl.Result{}, nil
+}
+
+// clusterClient returns a client for the workload cluster, shared through the Tracker if there is one.
+func (r *MachineHealthCheckReconciler) clusterClient(ctx context.Context, cluster *clusterv1.Cluster) (client.Client, error) {
+	if r.Tracker != nil {
+		return r.Tracker.GetClient(ctx, cluster)
+	}
+	return remote.NewClusterClient(ctx, r.Client, cluster, r.scheme)
+}
+
+// markUnhealthy marks the Machine with the MachineUnhealthyAnnotation, so that its owner deletes and replaces it.
+// Machines remediated by their owner also get the MachineOwnerRemediatedCondition set to False until then.
+func (r *MachineHealthCheckReconciler) markUnhealthy(ctx context.Context, m *clusterv1.MachineHealthCheck, machine *clusterv1.Machine) error {
+	_, marked := machine.Annotations[clusterv1.MachineUnhealthyAnnotation]
+	// An existing condition is left as is, as the owner may have updated it, e.g. when the remediation is not safe yet.
+	handedOff := !isRemediatedByOwner(machine) || conditions.Get(machine, clusterv1.MachineOwnerRemediatedCondition) != nil
+	if marked && handedOff {
+		return nil
+	}
+
+	patchHelper, err := patch.NewHelper(machine, r.Client)
+	if err != nil {
+		return err
+	}
+	if machine.Annotations == nil {
+		machine.Annotations = make(map[string]string)
+	}
+	machine.Annotations[clusterv1.MachineUnhealthyAnnotation] = ""
+	if !handedOff {
+		conditions.MarkFalse(machine, clusterv1.MachineOwnerRemediatedCondition, clusterv1.WaitingForRemediationReason, clusterv1.ConditionSeverityWarning,
+			"MachineHealthCheck %q handed off the remediation of the Machine to its owner", m.Name)
+	}
+	if err := patchHelper.Patch(ctx, machine); err != nil {
+		return errors.Wrapf(err, "failed to mark Machine %q as unhealthy", machine.Name)
+	}
+
+	if !handedOff {
+		owner := metav1.GetControllerOf(machine)
+		r.recorder.Eventf(machine, corev1.EventTypeNormal, "RemediationHandedOff",
+			"MachineHealthCheck %q handed off the remediation of the Machine to its owner %s %q", m.Name, owner.Kind, owner.Name)
+	}
+	return nil
 }
 
 func (r *MachineHealthCheckReconciler) indexMachineHealthCheckByClusterName(object runtime.Object) []string {
@@ -192,3 +294,35 @@ func (r *MachineHealthCheckReconciler) clusterToMachineHealthCheck(o handler.Map
 	}
 	return requests
 }
+
+// machineToMachineHealthCheck maps events from Machine objects to the
+// MachineHealthCheck objects that select them
+func (r *MachineHealthCheckReconciler) machineToMachineHealthCheck(o handler.MapObject) []reconcile.Request {
+	m, ok := o.Object.(*clusterv1.Machine)
+	if !ok {
+		r.Log.Error(errors.New("incorrect type"), "expected a Machine", "type", fmt.Sprintf("%T", o))
+		return nil
+	}
+
+	mhcList := &clusterv1.MachineHealthCheckList{}
+	if err := r.Client.List(
+		context.TODO(),
+		mhcList,
+		client.InNamespace(m.Namespace),
+		client.MatchingFields{mhcClusterNameIndex: m.Spec.ClusterName},
+	); err != nil {
+		r.Log.Error(err, "Unable to list MachineHealthChecks", "machine", m.Name, "namespace", m.Namespace)
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+	for _, mhc := range mhcList.Items {
+		selector, err := metav1.LabelSelectorAsSelector(&mhc.Spec.Selector)
+		if err != nil || !selector.Matches(labels.Set(m.Labels)) {
+			continue
+		}
+		key := types.NamespacedName{Namespace: mhc.Namespace, Name: mhc.Name}
+		requests = append(requests, reconcile.Request{NamespacedName: key})
+	}
+	return requests
+}
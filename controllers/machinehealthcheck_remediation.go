@@ -0,0 +1,165 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/controllers/external"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/conditions"
+)
+
+// remediateUnhealthy remediates the unhealthy Machines targeted by the MachineHealthCheck, unless more Machines are
+// unhealthy than its UnhealthyRange or MaxUnhealthy allow, e.g. during an outage of the whole cluster, in which case
+// remediation is short-circuited to avoid a remediation storm. The outcome is reported by the RemediationAllowed
+// condition of the MachineHealthCheck.
+//
+// The number of unhealthy Machines is computed from the ExpectedMachines and CurrentHealthy status fields.
+func (r *MachineHealthCheckReconciler) remediateUnhealthy(ctx context.Context, m *clusterv1.MachineHealthCheck, unhealthy []*clusterv1.Machine) error {
+	remediationsAllowed, err := remediationsAllowed(m)
+	if err != nil {
+		return err
+	}
+
+	if remediationsAllowed < 0 {
+		m.Status.RemediationsAllowed = 0
+		conditions.MarkFalse(m, clusterv1.RemediationAllowedCondition, clusterv1.TooManyUnhealthyReason, clusterv1.ConditionSeverityWarning,
+			"Remediation is not allowed, %d of %d machines are unhealthy (%s)",
+			m.Status.ExpectedMachines-m.Status.CurrentHealthy, m.Status.ExpectedMachines, unhealthyLimit(m))
+		r.recorder.Eventf(m, corev1.EventTypeWarning, "RemediationRestricted",
+			"Remediation restricted due to exceeded number of unhealthy machines (total: %d, unhealthy: %d, %s)",
+			m.Status.ExpectedMachines, m.Status.ExpectedMachines-m.Status.CurrentHealthy, unhealthyLimit(m))
+		return nil
+	}
+
+	m.Status.RemediationsAllowed = remediationsAllowed
+	conditions.MarkTrue(m, clusterv1.RemediationAllowedCondition)
+
+	var errs []error
+	for _, machine := range unhealthy {
+		if err := r.remediate(ctx, m, machine); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return kerrors.NewAggregate(errs)
+}
+
+// remediationsAllowed returns how many more Machines targeted by the MachineHealthCheck can become unhealthy before
+// remediation is short-circuited, or a negative number if it is short-circuited already.
+func remediationsAllowed(m *clusterv1.MachineHealthCheck) (int32, error) {
+	unhealthy := m.Status.ExpectedMachines - m.Status.CurrentHealthy
+
+	if m.Spec.UnhealthyRange != nil {
+		min, max, err := parseUnhealthyRange(*m.Spec.UnhealthyRange)
+		if err != nil {
+			return 0, err
+		}
+		if unhealthy < min {
+			return -1, nil
+		}
+		return max - unhealthy, nil
+	}
+
+	maxUnhealthy := intstr.FromString("100%")
+	if m.Spec.MaxUnhealthy != nil {
+		maxUnhealthy = *m.Spec.MaxUnhealthy
+	}
+	max, err := intstr.GetValueFromIntOrPercent(&maxUnhealthy, int(m.Status.ExpectedMachines), false)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to compute the maximum number of unhealthy machines from %q", maxUnhealthy.String())
+	}
+	return int32(max) - unhealthy, nil
+}
+
+// parseUnhealthyRange parses an UnhealthyRange, e.g. "[3-5]", into its bounds.
+func parseUnhealthyRange(unhealthyRange string) (int32, int32, error) {
+	var min, max int32
+	if _, err := fmt.Sscanf(unhealthyRange, "[%d-%d]", &min, &max); err != nil {
+		return 0, 0, errors.Wrapf(err, "failed to parse the unhealthy range %q", unhealthyRange)
+	}
+	if min > max {
+		return 0, 0, errors.Errorf("invalid unhealthy range %q: the minimum is greater than the maximum", unhealthyRange)
+	}
+	return min, max, nil
+}
+
+// unhealthyLimit describes the limit of unhealthy Machines of the MachineHealthCheck, for events and conditions.
+func unhealthyLimit(m *clusterv1.MachineHealthCheck) string {
+	if m.Spec.UnhealthyRange != nil {
+		return fmt.Sprintf("unhealthyRange: %s", *m.Spec.UnhealthyRange)
+	}
+	if m.Spec.MaxUnhealthy != nil {
+		return fmt.Sprintf("maxUnhealthy: %s", m.Spec.MaxUnhealthy.String())
+	}
+	return "maxUnhealthy: 100%"
+}
+
+// remediate hands off the remediation of an unhealthy Machine targeted by the MachineHealthCheck.
+//
+// By default, the Machine is marked with the MachineUnhealthyAnnotation, so that its owner deletes and replaces it.
+// When the MachineHealthCheck has an ExternalRemediationTemplate, a remediation request is created from the template
+// instead, named after the Machine and owned by it, so that an external controller remediates the Machine, e.g. by
+// rebooting or reimaging it. The remediation request is garbage collected along with the Machine.
+//
+// Control plane Machines owned by a controller, e.g. a KubeadmControlPlane, are always handed off to their owner,
+// which remediates them one at a time without losing quorum.
+func (r *MachineHealthCheckReconciler) remediate(ctx context.Context, m *clusterv1.MachineHealthCheck, machine *clusterv1.Machine) error {
+	if m.Spec.ExternalRemediationTemplate == nil || isRemediatedByOwner(machine) {
+		return r.markUnhealthy(ctx, m, machine)
+	}
+
+	ref, err := external.CloneTemplate(ctx, &external.CloneTemplateInput{
+		Client:      r.Client,
+		TemplateRef: m.Spec.ExternalRemediationTemplate,
+		Namespace:   machine.Namespace,
+		Name:        machine.Name,
+		ClusterName: machine.Spec.ClusterName,
+		OwnerRef: &metav1.OwnerReference{
+			APIVersion: clusterv1.GroupVersion.String(),
+			Kind:       "Machine",
+			Name:       machine.Name,
+			UID:        machine.UID,
+		},
+	})
+	if apierrors.IsAlreadyExists(errors.Cause(err)) {
+		// The remediation of the Machine has already been requested.
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to create a remediation request for Machine %q from %s %q",
+			machine.Name, m.Spec.ExternalRemediationTemplate.Kind, m.Spec.ExternalRemediationTemplate.Name)
+	}
+
+	r.recorder.Eventf(machine, corev1.EventTypeNormal, "ExternalRemediationRequested",
+		"MachineHealthCheck %q requested the remediation of the Machine with %s %q", m.Name, ref.Kind, ref.Name)
+	return nil
+}
+
+// isRemediatedByOwner returns whether the Machine is remediated by its owner rather than by the MachineHealthCheck,
+// i.e. whether it is a control plane Machine owned by a controller.
+func isRemediatedByOwner(machine *clusterv1.Machine) bool {
+	return util.IsControlPlaneMachine(machine) && metav1.GetControllerOf(machine) != nil
+}
@@ -0,0 +1,295 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestMachineHealthCheckRemediate(t *testing.T) {
+	newMachine := func() *clusterv1.Machine {
+		return &clusterv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "unhealthy",
+				Namespace: "default",
+				UID:       "machine-uid",
+			},
+			Spec: clusterv1.MachineSpec{
+				ClusterName: "test-cluster",
+			},
+		}
+	}
+	newRemediationTemplate := func() *unstructured.Unstructured {
+		template := &unstructured.Unstructured{
+			Object: map[string]interface{}{
+				"spec": map[string]interface{}{
+					"template": map[string]interface{}{
+						"spec": map[string]interface{}{
+							"strategy": "Reboot",
+						},
+					},
+				},
+			},
+		}
+		template.SetKind("InfrastructureRemediationTemplate")
+		template.SetAPIVersion("infrastructure.cluster.x-k8s.io/v1alpha3")
+		template.SetName("reboot")
+		template.SetNamespace("default")
+		return template
+	}
+
+	t.Run("without an external remediation template the Machine is marked unhealthy", func(t *testing.T) {
+		g := NewWithT(t)
+
+		machine := newMachine()
+		r := &MachineHealthCheckReconciler{
+			Client:   fake.NewFakeClientWithScheme(scheme.Scheme, machine.DeepCopy()),
+			Log:      log.Log,
+			recorder: record.NewFakeRecorder(32),
+		}
+		mhc := &clusterv1.MachineHealthCheck{}
+
+		g.Expect(r.remediate(ctx, mhc, machine)).To(Succeed())
+
+		updated := &clusterv1.Machine{}
+		g.Expect(r.Client.Get(ctx, client.ObjectKey{Namespace: "default", Name: "unhealthy"}, updated)).To(Succeed())
+		g.Expect(updated.Annotations).To(HaveKey(clusterv1.MachineUnhealthyAnnotation))
+	})
+
+	t.Run("with an external remediation template a remediation request is created", func(t *testing.T) {
+		g := NewWithT(t)
+
+		machine := newMachine()
+		r := &MachineHealthCheckReconciler{
+			Client:   fake.NewFakeClientWithScheme(scheme.Scheme, machine.DeepCopy(), newRemediationTemplate()),
+			Log:      log.Log,
+			recorder: record.NewFakeRecorder(32),
+		}
+		mhc := &clusterv1.MachineHealthCheck{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "mhc",
+				Namespace: "default",
+			},
+			Spec: clusterv1.MachineHealthCheckSpec{
+				ExternalRemediationTemplate: &corev1.ObjectReference{
+					APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+					Kind:       "InfrastructureRemediationTemplate",
+					Name:       "reboot",
+				},
+			},
+		}
+
+		g.Expect(r.remediate(ctx, mhc, machine)).To(Succeed())
+
+		request := &unstructured.Unstructured{}
+		request.SetAPIVersion("infrastructure.cluster.x-k8s.io/v1alpha3")
+		request.SetKind("InfrastructureRemediation")
+		g.Expect(r.Client.Get(ctx, client.ObjectKey{Namespace: "default", Name: "unhealthy"}, request)).To(Succeed())
+		g.Expect(request.GetOwnerReferences()).To(HaveLen(1))
+		g.Expect(request.GetOwnerReferences()[0].Kind).To(Equal("Machine"))
+		g.Expect(request.GetOwnerReferences()[0].UID).To(Equal(machine.UID))
+		strategy, _, _ := unstructured.NestedString(request.Object, "spec", "strategy")
+		g.Expect(strategy).To(Equal("Reboot"))
+
+		// Remediating the Machine again does not create another remediation request.
+		g.Expect(r.remediate(ctx, mhc, machine)).To(Succeed())
+
+		// The Machine is not marked to be deleted by its owner.
+		updated := &clusterv1.Machine{}
+		g.Expect(r.Client.Get(ctx, client.ObjectKey{Namespace: "default", Name: "unhealthy"}, updated)).To(Succeed())
+		g.Expect(updated.Annotations).NotTo(HaveKey(clusterv1.MachineUnhealthyAnnotation))
+	})
+
+	t.Run("control plane Machines are handed off to their owner", func(t *testing.T) {
+		g := NewWithT(t)
+
+		machine := newMachine()
+		machine.Labels = map[string]string{clusterv1.MachineControlPlaneLabelName: ""}
+		machine.OwnerReferences = []metav1.OwnerReference{{
+			APIVersion: "controlplane.cluster.x-k8s.io/v1alpha3",
+			Kind:       "KubeadmControlPlane",
+			Name:       "kcp",
+			UID:        "kcp-uid",
+			Controller: pointer.BoolPtr(true),
+		}}
+		r := &MachineHealthCheckReconciler{
+			Client:   fake.NewFakeClientWithScheme(scheme.Scheme, machine.DeepCopy(), newRemediationTemplate()),
+			Log:      log.Log,
+			recorder: record.NewFakeRecorder(32),
+		}
+		mhc := &clusterv1.MachineHealthCheck{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "mhc",
+				Namespace: "default",
+			},
+			Spec: clusterv1.MachineHealthCheckSpec{
+				ExternalRemediationTemplate: &corev1.ObjectReference{
+					APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+					Kind:       "InfrastructureRemediationTemplate",
+					Name:       "reboot",
+				},
+			},
+		}
+
+		g.Expect(r.remediate(ctx, mhc, machine)).To(Succeed())
+
+		updated := &clusterv1.Machine{}
+		g.Expect(r.Client.Get(ctx, client.ObjectKey{Namespace: "default", Name: "unhealthy"}, updated)).To(Succeed())
+		g.Expect(updated.Annotations).To(HaveKey(clusterv1.MachineUnhealthyAnnotation))
+		g.Expect(conditions.IsFalse(updated, clusterv1.MachineOwnerRemediatedCondition)).To(BeTrue())
+		g.Expect(conditions.Get(updated, clusterv1.MachineOwnerRemediatedCondition).Reason).To(Equal(clusterv1.WaitingForRemediationReason))
+
+		// No remediation request is created for the Machine.
+		request := &unstructured.Unstructured{}
+		request.SetAPIVersion("infrastructure.cluster.x-k8s.io/v1alpha3")
+		request.SetKind("InfrastructureRemediation")
+		err := r.Client.Get(ctx, client.ObjectKey{Namespace: "default", Name: "unhealthy"}, request)
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+}
+
+func TestMachineHealthCheckRemediateUnhealthy(t *testing.T) {
+	maxUnhealthy := func(s string) *intstr.IntOrString {
+		v := intstr.Parse(s)
+		return &v
+	}
+	unhealthyRange := func(s string) *string {
+		return &s
+	}
+
+	tests := []struct {
+		name                        string
+		maxUnhealthy                *intstr.IntOrString
+		unhealthyRange              *string
+		expectedMachines            int32
+		currentHealthy              int32
+		expectRemediation           bool
+		expectedRemediationsAllowed int32
+	}{
+		{
+			name:                        "maxUnhealthy defaults to 100%",
+			expectedMachines:            3,
+			currentHealthy:              0,
+			expectRemediation:           true,
+			expectedRemediationsAllowed: 0,
+		},
+		{
+			name:                        "within maxUnhealthy percentage",
+			maxUnhealthy:                maxUnhealthy("40%"),
+			expectedMachines:            10,
+			currentHealthy:              7,
+			expectRemediation:           true,
+			expectedRemediationsAllowed: 1,
+		},
+		{
+			name:              "above maxUnhealthy percentage",
+			maxUnhealthy:      maxUnhealthy("40%"),
+			expectedMachines:  10,
+			currentHealthy:    5,
+			expectRemediation: false,
+		},
+		{
+			name:              "above absolute maxUnhealthy",
+			maxUnhealthy:      maxUnhealthy("1"),
+			expectedMachines:  3,
+			currentHealthy:    1,
+			expectRemediation: false,
+		},
+		{
+			name:                        "within unhealthyRange",
+			maxUnhealthy:                maxUnhealthy("1"),
+			unhealthyRange:              unhealthyRange("[1-3]"),
+			expectedMachines:            5,
+			currentHealthy:              3,
+			expectRemediation:           true,
+			expectedRemediationsAllowed: 1,
+		},
+		{
+			name:              "above unhealthyRange",
+			unhealthyRange:    unhealthyRange("[1-3]"),
+			expectedMachines:  5,
+			currentHealthy:    1,
+			expectRemediation: false,
+		},
+		{
+			name:              "below unhealthyRange",
+			unhealthyRange:    unhealthyRange("[2-3]"),
+			expectedMachines:  5,
+			currentHealthy:    4,
+			expectRemediation: false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			machine := &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "unhealthy",
+					Namespace: "default",
+				},
+			}
+			r := &MachineHealthCheckReconciler{
+				Client:   fake.NewFakeClientWithScheme(scheme.Scheme, machine.DeepCopy()),
+				Log:      log.Log,
+				recorder: record.NewFakeRecorder(32),
+			}
+			mhc := &clusterv1.MachineHealthCheck{
+				Spec: clusterv1.MachineHealthCheckSpec{
+					MaxUnhealthy:   tc.maxUnhealthy,
+					UnhealthyRange: tc.unhealthyRange,
+				},
+				Status: clusterv1.MachineHealthCheckStatus{
+					ExpectedMachines: tc.expectedMachines,
+					CurrentHealthy:   tc.currentHealthy,
+				},
+			}
+
+			g.Expect(r.remediateUnhealthy(ctx, mhc, []*clusterv1.Machine{machine})).To(Succeed())
+
+			updated := &clusterv1.Machine{}
+			g.Expect(r.Client.Get(ctx, client.ObjectKey{Namespace: "default", Name: "unhealthy"}, updated)).To(Succeed())
+			if tc.expectRemediation {
+				g.Expect(conditions.IsTrue(mhc, clusterv1.RemediationAllowedCondition)).To(BeTrue())
+				g.Expect(mhc.Status.RemediationsAllowed).To(Equal(tc.expectedRemediationsAllowed))
+				g.Expect(updated.Annotations).To(HaveKey(clusterv1.MachineUnhealthyAnnotation))
+			} else {
+				remediationAllowed := conditions.Get(mhc, clusterv1.RemediationAllowedCondition)
+				g.Expect(remediationAllowed.Status).To(Equal(corev1.ConditionFalse))
+				g.Expect(remediationAllowed.Reason).To(Equal(clusterv1.TooManyUnhealthyReason))
+				g.Expect(mhc.Status.RemediationsAllowed).To(BeZero())
+				g.Expect(updated.Annotations).NotTo(HaveKey(clusterv1.MachineUnhealthyAnnotation))
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// healthCheckTarget is a Machine targeted by a MachineHealthCheck, along with its Node if it has one.
+type healthCheckTarget struct {
+	Machine *clusterv1.Machine
+	Node    *corev1.Node
+	MHC     *clusterv1.MachineHealthCheck
+}
+
+func (t *healthCheckTarget) string() string {
+	return fmt.Sprintf("%s/%s/%s", t.MHC.Name, t.Machine.Namespace, t.Machine.Name)
+}
+
+// needsRemediation returns whether the target is unhealthy. When it is not, it also returns the duration after which
+// the target has to be checked again because an unhealthy condition it is in times out, or zero if there is none.
+//
+// A target is unhealthy when one of the unhealthy conditions of the MachineHealthCheck has been met for longer than its
+// timeout, on the Machine or on the Node depending on the source of the condition, when its Node has been deleted, or
+// when it never got a Node within the NodeStartupTimeout of the MachineHealthCheck, e.g. because its bootstrap failed.
+func (t *healthCheckTarget) needsRemediation(logger logr.Logger, now time.Time) (bool, time.Duration) {
+	var nextCheck time.Duration
+	// checkTimeout reports whether the deadline has passed, or remembers it as the next time to check the target.
+	checkTimeout := func(deadline time.Time) bool {
+		if !now.Before(deadline) {
+			return true
+		}
+		if wait := deadline.Sub(now); nextCheck == 0 || wait < nextCheck {
+			nextCheck = wait
+		}
+		return false
+	}
+
+	for _, c := range t.MHC.Spec.UnhealthyConditions {
+		if c.Source != clusterv1.MachineUnhealthyConditionSource {
+			continue
+		}
+		condition := conditions.Get(t.Machine, clusterv1.ConditionType(c.Type))
+		if condition == nil || condition.Status != c.Status {
+			continue
+		}
+		if checkTimeout(condition.LastTransitionTime.Add(c.Timeout.Duration)) {
+			logger.V(3).Info("Target is unhealthy: machine condition has timed out", "target", t.string(), "condition", c.Type)
+			return true, 0
+		}
+	}
+
+	if t.Node == nil {
+		// The Node has been deleted.
+		if t.Machine.Status.NodeRef != nil {
+			logger.V(3).Info("Target is unhealthy: node is missing", "target", t.string(), "node", t.Machine.Status.NodeRef.Name)
+			return true, 0
+		}
+
+		// The Node has not appeared yet.
+		timeout := t.MHC.Spec.NodeStartupTimeout
+		if timeout == nil || timeout.Duration == 0 {
+			return false, nextCheck
+		}
+		if checkTimeout(t.Machine.CreationTimestamp.Add(timeout.Duration)) {
+			logger.V(3).Info("Target is unhealthy: node has not appeared within the node startup timeout", "target", t.string(), "timeout", timeout.Duration)
+			return true, 0
+		}
+		return false, nextCheck
+	}
+
+	for _, c := range t.MHC.Spec.UnhealthyConditions {
+		if c.Source == clusterv1.MachineUnhealthyConditionSource {
+			continue
+		}
+		condition := getNodeCondition(t.Node, c.Type)
+		if condition == nil || condition.Status != c.Status {
+			continue
+		}
+		if checkTimeout(condition.LastTransitionTime.Add(c.Timeout.Duration)) {
+			logger.V(3).Info("Target is unhealthy: node condition has timed out", "target", t.string(), "condition", c.Type)
+			return true, 0
+		}
+	}
+
+	return false, nextCheck
+}
+
+// getTargetsFromMHC returns the Machines of the Cluster selected by the MachineHealthCheck, along with their Nodes.
+// Machines being deleted are left out.
+func (r *MachineHealthCheckReconciler) getTargetsFromMHC(ctx context.Context, clusterClient client.Reader, m *clusterv1.MachineHealthCheck) ([]healthCheckTarget, error) {
+	selector, err := metav1.LabelSelectorAsSelector(&m.Spec.Selector)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to build selector for MachineHealthCheck %q in namespace %q", m.Name, m.Namespace)
+	}
+	machineList := &clusterv1.MachineList{}
+	if err := r.Client.List(ctx, machineList, client.InNamespace(m.Namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, errors.Wrapf(err, "failed to list Machines for MachineHealthCheck %q in namespace %q", m.Name, m.Namespace)
+	}
+
+	targets := []healthCheckTarget{}
+	for i := range machineList.Items {
+		machine := &machineList.Items[i]
+		// Only target the Machines of the Cluster of the MachineHealthCheck.
+		if machine.Spec.ClusterName != m.Spec.ClusterName || !machine.DeletionTimestamp.IsZero() {
+			continue
+		}
+
+		target := healthCheckTarget{Machine: machine, MHC: m}
+		if machine.Status.NodeRef != nil {
+			node := &corev1.Node{}
+			if err := clusterClient.Get(ctx, client.ObjectKey{Name: machine.Status.NodeRef.Name}, node); err != nil {
+				if !apierrors.IsNotFound(err) {
+					return nil, errors.Wrapf(err, "failed to get Node %q of Machine %q in namespace %q",
+						machine.Status.NodeRef.Name, machine.Name, machine.Namespace)
+				}
+				node = nil
+			}
+			target.Node = node
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+// healthCheckTargets sorts the targets into healthy and unhealthy Machines, and returns the shortest duration after
+// which one of the healthy targets has to be checked again, or zero if there is none.
+func (r *MachineHealthCheckReconciler) healthCheckTargets(logger logr.Logger, targets []healthCheckTarget) ([]*clusterv1.Machine, []*clusterv1.Machine, time.Duration) {
+	var healthy, unhealthy []*clusterv1.Machine
+	var nextCheck time.Duration
+
+	now := time.Now()
+	for _, t := range targets {
+		needsRemediation, wait := t.needsRemediation(logger, now)
+		if needsRemediation {
+			unhealthy = append(unhealthy, t.Machine)
+			continue
+		}
+		healthy = append(healthy, t.Machine)
+		if wait > 0 && (nextCheck == 0 || wait < nextCheck) {
+			nextCheck = wait
+		}
+	}
+	return healthy, unhealthy, nextCheck
+}
+
+// getNodeCondition returns the condition of the given type of the Node, or nil if it does not have one.
+func getNodeCondition(node *corev1.Node, conditionType corev1.NodeConditionType) *corev1.NodeCondition {
+	for i := range node.Status.Conditions {
+		if node.Status.Conditions[i].Type == conditionType {
+			return &node.Status.Conditions[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,232 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestHealthCheckTargetNeedsRemediation(t *testing.T) {
+	now := time.Now()
+
+	mhc := &clusterv1.MachineHealthCheck{
+		ObjectMeta: metav1.ObjectMeta{Name: "mhc", Namespace: "default"},
+		Spec: clusterv1.MachineHealthCheckSpec{
+			NodeStartupTimeout: &metav1.Duration{Duration: 10 * time.Minute},
+			UnhealthyConditions: []clusterv1.UnhealthyCondition{
+				{
+					Type:    corev1.NodeReady,
+					Status:  corev1.ConditionUnknown,
+					Timeout: metav1.Duration{Duration: 5 * time.Minute},
+				},
+				{
+					Type:    "BootstrapReady",
+					Status:  corev1.ConditionFalse,
+					Timeout: metav1.Duration{Duration: 15 * time.Minute},
+					Source:  clusterv1.MachineUnhealthyConditionSource,
+				},
+			},
+		},
+	}
+
+	newMachine := func(created time.Time, nodeRef bool, machineConditions ...clusterv1.Condition) *clusterv1.Machine {
+		machine := &clusterv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "machine",
+				Namespace:         "default",
+				CreationTimestamp: metav1.NewTime(created),
+			},
+			Status: clusterv1.MachineStatus{Conditions: machineConditions},
+		}
+		if nodeRef {
+			machine.Status.NodeRef = &corev1.ObjectReference{Name: "node"}
+		}
+		return machine
+	}
+	newNode := func(nodeConditions ...corev1.NodeCondition) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node"},
+			Status:     corev1.NodeStatus{Conditions: nodeConditions},
+		}
+	}
+
+	testCases := []struct {
+		name              string
+		target            healthCheckTarget
+		expectUnhealthy   bool
+		expectedNextCheck time.Duration
+	}{
+		{
+			name: "healthy node",
+			target: healthCheckTarget{
+				Machine: newMachine(now.Add(-time.Hour), true),
+				Node: newNode(corev1.NodeCondition{
+					Type:               corev1.NodeReady,
+					Status:             corev1.ConditionTrue,
+					LastTransitionTime: metav1.NewTime(now.Add(-time.Hour)),
+				}),
+			},
+		},
+		{
+			name: "node condition not timed out yet",
+			target: healthCheckTarget{
+				Machine: newMachine(now.Add(-time.Hour), true),
+				Node: newNode(corev1.NodeCondition{
+					Type:               corev1.NodeReady,
+					Status:             corev1.ConditionUnknown,
+					LastTransitionTime: metav1.NewTime(now.Add(-2 * time.Minute)),
+				}),
+			},
+			expectedNextCheck: 3 * time.Minute,
+		},
+		{
+			name: "node condition timed out",
+			target: healthCheckTarget{
+				Machine: newMachine(now.Add(-time.Hour), true),
+				Node: newNode(corev1.NodeCondition{
+					Type:               corev1.NodeReady,
+					Status:             corev1.ConditionUnknown,
+					LastTransitionTime: metav1.NewTime(now.Add(-6 * time.Minute)),
+				}),
+			},
+			expectUnhealthy: true,
+		},
+		{
+			name: "node deleted",
+			target: healthCheckTarget{
+				Machine: newMachine(now.Add(-time.Hour), true),
+			},
+			expectUnhealthy: true,
+		},
+		{
+			name: "node not started yet",
+			target: healthCheckTarget{
+				Machine: newMachine(now.Add(-4*time.Minute), false),
+			},
+			expectedNextCheck: 6 * time.Minute,
+		},
+		{
+			name: "node startup timed out",
+			target: healthCheckTarget{
+				Machine: newMachine(now.Add(-11*time.Minute), false),
+			},
+			expectUnhealthy: true,
+		},
+		{
+			name: "machine condition not timed out yet",
+			target: healthCheckTarget{
+				Machine: newMachine(now.Add(-4*time.Minute), false, clusterv1.Condition{
+					Type:               "BootstrapReady",
+					Status:             corev1.ConditionFalse,
+					LastTransitionTime: metav1.NewTime(now.Add(-4 * time.Minute)),
+				}),
+			},
+			expectedNextCheck: 6 * time.Minute,
+		},
+		{
+			name: "machine condition timed out",
+			target: healthCheckTarget{
+				Machine: newMachine(now.Add(-time.Hour), true, clusterv1.Condition{
+					Type:               "BootstrapReady",
+					Status:             corev1.ConditionFalse,
+					LastTransitionTime: metav1.NewTime(now.Add(-16 * time.Minute)),
+				}),
+				Node: newNode(),
+			},
+			expectUnhealthy: true,
+		},
+		{
+			name: "node condition is not looked up on the machine",
+			target: healthCheckTarget{
+				Machine: newMachine(now.Add(-time.Hour), true, clusterv1.Condition{
+					Type:               clusterv1.ConditionType(corev1.NodeReady),
+					Status:             corev1.ConditionUnknown,
+					LastTransitionTime: metav1.NewTime(now.Add(-time.Hour)),
+				}),
+				Node: newNode(),
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			tc.target.MHC = mhc
+			unhealthy, nextCheck := tc.target.needsRemediation(log.Log, now)
+			g.Expect(unhealthy).To(Equal(tc.expectUnhealthy))
+			g.Expect(nextCheck).To(Equal(tc.expectedNextCheck))
+		})
+	}
+}
+
+func TestGetTargetsFromMHC(t *testing.T) {
+	g := NewWithT(t)
+
+	mhc := &clusterv1.MachineHealthCheck{
+		ObjectMeta: metav1.ObjectMeta{Name: "mhc", Namespace: "default"},
+		Spec: clusterv1.MachineHealthCheckSpec{
+			ClusterName: "test-cluster",
+			Selector:    metav1.LabelSelector{MatchLabels: map[string]string{"role": "worker"}},
+		},
+	}
+	newMachine := func(name, clusterName string, labels map[string]string, nodeName string) *clusterv1.Machine {
+		machine := &clusterv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: labels},
+			Spec:       clusterv1.MachineSpec{ClusterName: clusterName},
+		}
+		if nodeName != "" {
+			machine.Status.NodeRef = &corev1.ObjectReference{Name: nodeName}
+		}
+		return machine
+	}
+	deleted := newMachine("deleted", "test-cluster", map[string]string{"role": "worker"}, "")
+	deleted.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+
+	managementClient := fake.NewFakeClientWithScheme(scheme.Scheme,
+		newMachine("with-node", "test-cluster", map[string]string{"role": "worker"}, "node"),
+		newMachine("missing-node", "test-cluster", map[string]string{"role": "worker"}, "deleted-node"),
+		newMachine("no-node", "test-cluster", map[string]string{"role": "worker"}, ""),
+		newMachine("other-role", "test-cluster", map[string]string{"role": "control-plane"}, ""),
+		newMachine("other-cluster", "other-cluster", map[string]string{"role": "worker"}, ""),
+		deleted,
+	)
+	clusterClient := fake.NewFakeClientWithScheme(scheme.Scheme, &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node"}})
+
+	r := &MachineHealthCheckReconciler{Client: managementClient, Log: log.Log}
+	targets, err := r.getTargetsFromMHC(ctx, clusterClient, mhc)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	nodes := map[string]*corev1.Node{}
+	for _, target := range targets {
+		nodes[target.Machine.Name] = target.Node
+	}
+	g.Expect(nodes).To(HaveLen(3))
+	g.Expect(nodes).To(HaveKey("with-node"))
+	g.Expect(nodes["with-node"].Name).To(Equal("node"))
+	g.Expect(nodes).To(HaveKeyWithValue("missing-node", BeNil()))
+	g.Expect(nodes).To(HaveKeyWithValue("no-node", BeNil()))
+}
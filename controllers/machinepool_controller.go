@@ -19,6 +19,7 @@ package controllers
 import (
 	"context"
 	"sync"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
@@ -27,6 +28,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	kerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/record"
@@ -36,10 +38,13 @@ import (
 	capierrors "sigs.k8s.io/cluster-api/errors"
 	"sigs.k8s.io/cluster-api/util"
 	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/cluster-api/util/predicates"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 // +kubebuilder:rbac:groups=core,resources=events,verbs=get;list;watch;create;patch
@@ -53,6 +58,34 @@ type MachinePoolReconciler struct {
 	Client client.Client
 	Log    logr.Logger
 
+	// RetiredNodeDrainTimeout is how long a single attempt at draining a retired Node may take.
+	// Retired Nodes are deleted without being drained if it is zero.
+	RetiredNodeDrainTimeout time.Duration
+
+	// RetiredNodeMaxDrainAttempts is the number of times draining a retired Node is attempted, one attempt per
+	// reconcile, before the Node is deleted regardless. defaultRetiredNodeMaxDrainAttempts is used if it is not positive.
+	RetiredNodeMaxDrainAttempts int
+
+	// WatchWorkloadNodes makes the reconciler watch the Nodes of every workload cluster with a MachinePool,
+	// so that Node readiness changes are reflected in the MachinePool status without waiting for a requeue.
+	// This adds a watch per workload cluster.
+	WatchWorkloadNodes bool
+
+	// Tracker holds the clients and caches of the workload clusters, which may be shared with the other controllers
+	// of the management cluster. One is created if it is nil.
+	Tracker *remote.ClusterCacheTracker
+
+	// UnavailableNodeTaintKeys are the keys of taints that keep an otherwise matching Node from counting as available,
+	// e.g. taints with the NoExecute effect that the MachinePool's workloads do not tolerate. Taints are ignored if empty.
+	UnavailableNodeTaintKeys []string
+
+	// drainAttempts counts the failed drain attempts of retired Nodes.
+	drainAttemptsLock sync.Mutex
+	drainAttempts     map[types.UID]int
+
+	// retiredNodeDrainer drains a retired Node; drainRetiredNode is used if it is nil.
+	retiredNodeDrainer func(ctx context.Context, cluster *clusterv1.Cluster, node *corev1.Node) error
+
 	config           *rest.Config
 	controller       controller.Controller
 	recorder         record.EventRecorder
@@ -69,10 +102,22 @@ func (r *MachinePoolReconciler) SetupWithManager(mgr ctrl.Manager, options contr
 		return errors.Wrap(err, "failed setting up with a controller manager")
 	}
 
+	err = c.Watch(
+		&source.Kind{Type: &clusterv1.Cluster{}},
+		&handler.EnqueueRequestsFromMapFunc{ToRequests: util.ClusterToObjectsMapper(r.Client, &clusterv1.MachinePoolList{})},
+		predicates.ClusterPausedChanged(),
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed adding Watch for Clusters to controller manager")
+	}
+
 	r.controller = c
 	r.recorder = mgr.GetEventRecorderFor("machinepool-controller")
 	r.config = mgr.GetConfig()
 	r.scheme = mgr.GetScheme()
+	if r.Tracker == nil {
+		r.Tracker = remote.NewClusterCacheTracker(r.Log, r.Client, r.scheme)
+	}
 	return nil
 }
 
@@ -150,6 +195,13 @@ func (r *MachinePoolReconciler) reconcile(ctx context.Context, cluster *clusterv
 	// If the MachinePool doesn't have a finalizer, add one.
 	controllerutil.AddFinalizer(mp, clusterv1.MachinePoolFinalizer)
 
+	if cluster.Status.ControlPlaneInitialized {
+		if err := r.watchClusterNodes(ctx, cluster); err != nil {
+			// Node readiness changes are still picked up on the next requeue.
+			logger.Error(err, "Failed to watch workload cluster Nodes")
+		}
+	}
+
 	// Call the inner reconciliation methods.
 	reconciliationErrors := []error{
 		r.reconcileBootstrap(ctx, cluster, mp),
@@ -177,6 +229,10 @@ func (r *MachinePoolReconciler) reconcile(ctx context.Context, cluster *clusterv
 }
 
 func (r *MachinePoolReconciler) reconcileDelete(ctx context.Context, cluster *clusterv1.Cluster, mp *clusterv1.MachinePool) (ctrl.Result, error) {
+	if !cluster.DeletionTimestamp.IsZero() {
+		r.unwatchClusterNodes(cluster)
+	}
+
 	if ok, err := r.reconcileDeleteExternal(ctx, mp); !ok || err != nil {
 		// Return early and don't remove the finalizer if we got an error or
 		// the external reconciliation deletion isn't ready.
@@ -185,6 +241,9 @@ func (r *MachinePoolReconciler) reconcileDelete(ctx context.Context, cluster *cl
 
 	if err := r.reconcileDeleteNodes(ctx, cluster, mp); err != nil {
 		// Return early and don't remove the finalizer if we got an error.
+		if requeueErr, ok := errors.Cause(err).(capierrors.HasRequeueAfterError); ok {
+			return ctrl.Result{RequeueAfter: requeueErr.GetRequeueAfter()}, nil
+		}
 		return ctrl.Result{}, err
 	}
 
@@ -192,17 +251,25 @@ func (r *MachinePoolReconciler) reconcileDelete(ctx context.Context, cluster *cl
 	return ctrl.Result{}, nil
 }
 
+// clusterClient returns a client for the workload cluster, shared through the Tracker if there is one.
+func (r *MachinePoolReconciler) clusterClient(ctx context.Context, cluster *clusterv1.Cluster) (client.Client, error) {
+	if r.Tracker != nil {
+		return r.Tracker.GetClient(ctx, cluster)
+	}
+	return remote.NewClusterClient(ctx, r.Client, cluster, r.scheme)
+}
+
 func (r *MachinePoolReconciler) reconcileDeleteNodes(ctx context.Context, cluster *clusterv1.Cluster, machinepool *clusterv1.MachinePool) error {
 	if len(machinepool.Status.NodeRefs) == 0 {
 		return nil
 	}
 
-	clusterClient, err := remote.NewClusterClient(ctx, r.Client, cluster, r.scheme)
+	clusterClient, err := r.clusterClient(ctx, cluster)
 	if err != nil {
 		return err
 	}
 
-	if err := r.deleteRetiredNodes(ctx, clusterClient, machinepool.Status.NodeRefs, machinepool.Spec.ProviderIDList); err != nil {
+	if err := r.deleteRetiredNodes(ctx, cluster, clusterClient, machinepool.Status.NodeRefs, machinepool.Spec.ProviderIDList); err != nil {
 		return err
 	}
 	return nil
@@ -19,16 +19,21 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"sort"
 	"time"
 
+	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
 	apicorev1 "k8s.io/api/core/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	"sigs.k8s.io/cluster-api/controllers/noderefutil"
 	"sigs.k8s.io/cluster-api/controllers/remote"
 	capierrors "sigs.k8s.io/cluster-api/errors"
+	kubedrain "sigs.k8s.io/cluster-api/third_party/kubernetes-drain"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -37,9 +42,12 @@ var (
 )
 
 type getNodeReferencesResult struct {
-	references []apicorev1.ObjectReference
-	available  int
-	ready      int
+	references  []apicorev1.ObjectReference
+	available   int
+	ready       int
+	unreachable int
+	// taintExcluded is the number of matched nodes that do not count as available because of their taints.
+	taintExcluded int
 }
 
 func (r *MachinePoolReconciler) reconcileNodeRefs(ctx context.Context, cluster *clusterv1.Cluster, mp *clusterv1.MachinePool) error {
@@ -50,7 +58,9 @@ func (r *MachinePoolReconciler) reconcileNodeRefs(ctx context.Context, cluster *
 	}
 
 	// Check that the Machine doesn't already have a NodeRefs.
-	if mp.Status.Replicas == mp.Status.ReadyReplicas && len(mp.Status.NodeRefs) == int(mp.Status.ReadyReplicas) {
+	// When workload cluster Nodes are watched, a reconcile may be the result of a Node becoming not ready,
+	// so the NodeRefs are always refreshed.
+	if !r.WatchWorkloadNodes && mp.Status.Replicas == mp.Status.ReadyReplicas && len(mp.Status.NodeRefs) == int(mp.Status.ReadyReplicas) {
 		return nil
 	}
 
@@ -68,12 +78,12 @@ func (r *MachinePoolReconciler) reconcileNodeRefs(ctx context.Context, cluster *
 		return nil
 	}
 
-	clusterClient, err := remote.NewClusterClient(ctx, r.Client, cluster, r.scheme)
+	clusterClient, err := r.clusterClient(ctx, cluster)
 	if err != nil {
 		return err
 	}
 
-	if err = r.deleteRetiredNodes(ctx, clusterClient, mp.Status.NodeRefs, mp.Spec.ProviderIDList); err != nil {
+	if err = r.deleteRetiredNodes(ctx, cluster, clusterClient, mp.Status.NodeRefs, mp.Spec.ProviderIDList); err != nil {
 		return err
 	}
 
@@ -81,6 +91,11 @@ func (r *MachinePoolReconciler) reconcileNodeRefs(ctx context.Context, cluster *
 	nodeRefsResult, err := r.getNodeReferences(ctx, clusterClient, mp.Spec.ProviderIDList)
 	if err != nil {
 		if err == ErrNoAvailableNodes {
+			// The Node watch triggers a reconcile when a matching Node appears.
+			if r.watchingClusterNodes(cluster) {
+				logger.Info("Cannot assign NodeRefs to MachinePool yet, no matching Nodes")
+				return nil
+			}
 			return errors.Wrapf(&capierrors.RequeueAfterError{RequeueAfter: 10 * time.Second},
 				"cannot assign NodeRefs to MachinePool, no matching Nodes")
 		}
@@ -96,7 +111,7 @@ func (r *MachinePoolReconciler) reconcileNodeRefs(ctx context.Context, cluster *
 	logger.Info("Set MachinePools's NodeRefs", "noderefs", mp.Status.NodeRefs)
 	r.recorder.Event(mp, apicorev1.EventTypeNormal, "SuccessfulSetNodeRefs", fmt.Sprintf("%+v", mp.Status.NodeRefs))
 
-	if mp.Status.Replicas != mp.Status.ReadyReplicas || len(nodeRefsResult.references) != int(mp.Status.ReadyReplicas) {
+	if (mp.Status.Replicas != mp.Status.ReadyReplicas || len(nodeRefsResult.references) != int(mp.Status.ReadyReplicas)) && !r.watchingClusterNodes(cluster) {
 		return errors.Wrapf(&capierrors.RequeueAfterError{RequeueAfter: 30 * time.Second},
 			"NodeRefs != ReadyReplicas [%q != %q] for MachinePool %q in namespace %q", len(nodeRefsResult.references), mp.Status.ReadyReplicas, mp.Name, mp.Namespace)
 	}
@@ -106,7 +121,10 @@ func (r *MachinePoolReconciler) reconcileNodeRefs(ctx context.Context, cluster *
 // deleteRetiredNodes deletes nodes that don't have a corresponding ProviderID in Spec.ProviderIDList.
 // A MachinePool infrastucture provider indicates an instance in the set has been deleted by
 // removing its ProviderID from the slice.
-func (r *MachinePoolReconciler) deleteRetiredNodes(ctx context.Context, c client.Client, nodeRefs []apicorev1.ObjectReference, providerIDList []string) error {
+// If RetiredNodeDrainTimeout is set, nodes are drained before they are deleted. A node that fails to drain
+// is left in place and the MachinePool is requeued, until RetiredNodeMaxDrainAttempts is exhausted and the
+// node is deleted without being drained.
+func (r *MachinePoolReconciler) deleteRetiredNodes(ctx context.Context, cluster *clusterv1.Cluster, c client.Client, nodeRefs []apicorev1.ObjectReference, providerIDList []string) error {
 	logger := r.Log.WithValues("providerIDList", len(providerIDList))
 	nodeRefsMap := make(map[string]*apicorev1.Node, len(nodeRefs))
 	for _, nodeRef := range nodeRefs {
@@ -132,10 +150,106 @@ func (r *MachinePoolReconciler) deleteRetiredNodes(ctx context.Context, c client
 		}
 		delete(nodeRefsMap, pid.ID())
 	}
+	undrained := 0
 	for _, node := range nodeRefsMap {
+		if r.RetiredNodeDrainTimeout > 0 {
+			if err := r.drainer()(ctx, cluster, node); err != nil {
+				attempts := r.recordFailedDrain(node.UID)
+				if attempts < r.maxDrainAttempts() {
+					logger.Info("Failed to drain retired Node, will retry", "node", node.Name, "attempt", attempts, "err", err.Error())
+					undrained++
+					continue
+				}
+				logger.Info("Failed to drain retired Node, giving up and deleting it", "node", node.Name, "attempts", attempts, "err", err.Error())
+			}
+		}
 		if err := c.Delete(ctx, node); err != nil {
 			return errors.Wrapf(err, "failed to delete Node")
 		}
+		r.forgetDrainAttempts(node.UID)
+	}
+	if undrained > 0 {
+		return errors.Wrapf(&capierrors.RequeueAfterError{RequeueAfter: r.RetiredNodeDrainTimeout},
+			"failed to drain %d retired Nodes", undrained)
+	}
+	return nil
+}
+
+// defaultRetiredNodeMaxDrainAttempts is the number of times draining a retired Node is attempted
+// when RetiredNodeMaxDrainAttempts is not set.
+const defaultRetiredNodeMaxDrainAttempts = 3
+
+func (r *MachinePoolReconciler) maxDrainAttempts() int {
+	if r.RetiredNodeMaxDrainAttempts <= 0 {
+		return defaultRetiredNodeMaxDrainAttempts
+	}
+	return r.RetiredNodeMaxDrainAttempts
+}
+
+func (r *MachinePoolReconciler) drainer() func(ctx context.Context, cluster *clusterv1.Cluster, node *corev1.Node) error {
+	if r.retiredNodeDrainer != nil {
+		return r.retiredNodeDrainer
+	}
+	return r.drainRetiredNode
+}
+
+// recordFailedDrain records a failed attempt at draining a Node and returns the number of failed attempts so far.
+func (r *MachinePoolReconciler) recordFailedDrain(uid types.UID) int {
+	r.drainAttemptsLock.Lock()
+	defer r.drainAttemptsLock.Unlock()
+
+	if r.drainAttempts == nil {
+		r.drainAttempts = map[types.UID]int{}
+	}
+	r.drainAttempts[uid]++
+	return r.drainAttempts[uid]
+}
+
+func (r *MachinePoolReconciler) forgetDrainAttempts(uid types.UID) {
+	r.drainAttemptsLock.Lock()
+	defer r.drainAttemptsLock.Unlock()
+
+	delete(r.drainAttempts, uid)
+}
+
+// drainRetiredNode cordons and drains a retired Node, giving up after RetiredNodeDrainTimeout.
+func (r *MachinePoolReconciler) drainRetiredNode(ctx context.Context, cluster *clusterv1.Cluster, node *corev1.Node) error {
+	logger := r.Log.WithValues("node", node.Name, "cluster", cluster.Name, "namespace", cluster.Namespace)
+
+	restConfig, err := remote.RESTConfig(ctx, r.Client, cluster)
+	if err != nil {
+		return errors.Wrap(err, "unable to get remote REST config")
+	}
+	kubeClient, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return errors.Wrap(err, "unable to build kube client")
+	}
+
+	drainer := &kubedrain.Helper{
+		Client:              kubeClient,
+		Force:               true,
+		IgnoreAllDaemonSets: true,
+		DeleteLocalData:     true,
+		GracePeriodSeconds:  -1,
+		Timeout:             r.RetiredNodeDrainTimeout,
+		OnPodDeletedOrEvicted: func(pod *corev1.Pod, usingEviction bool) {
+			verbStr := "Deleted"
+			if usingEviction {
+				verbStr = "Evicted"
+			}
+			logger.Info(fmt.Sprintf("%s pod from Node", verbStr),
+				"pod", fmt.Sprintf("%s/%s", pod.Name, pod.Namespace))
+		},
+		Out:    writer{klog.Info},
+		ErrOut: writer{klog.Error},
+		DryRun: false,
+	}
+
+	if err := kubedrain.RunCordonOrUncordon(drainer, node, true); err != nil {
+		return errors.Wrapf(err, "unable to cordon node %s", node.Name)
+	}
+	if err := kubedrain.RunNodeDrain(drainer, node.Name); err != nil {
+		return errors.Wrapf(err, "unable to drain node %s", node.Name)
 	}
 	return nil
 }
@@ -143,18 +257,107 @@ func (r *MachinePoolReconciler) deleteRetiredNodes(ctx context.Context, c client
 func (r *MachinePoolReconciler) getNodeReferences(ctx context.Context, c client.Client, providerIDList []string) (getNodeReferencesResult, error) {
 	logger := r.Log.WithValues("providerIDList", len(providerIDList))
 
-	var ready, available int
+	var ready, available, unreachable, taintExcluded int
+	nodeRefsMap, err := listNodesByProviderID(ctx, c, logger)
+	if err != nil {
+		return getNodeReferencesResult{}, err
+	}
+
+	var skipped int
+	var nodeRefs []apicorev1.ObjectReference
+	for _, providerID := range providerIDList {
+		pid, err := noderefutil.NewProviderID(providerID)
+		if err != nil {
+			logger.V(2).Info("Failed to parse ProviderID, skipping", "err", err, "source", "providerIDList", "providerID", providerID)
+			skipped++
+			continue
+		}
+		if node, ok := nodeRefsMap[pid.ID()]; ok {
+			if r.hasUnavailableTaint(&node) {
+				taintExcluded++
+			} else {
+				available++
+			}
+			isReady, isUnreachable := nodeReadyState(&node)
+			if isReady {
+				ready++
+			}
+			if isUnreachable {
+				unreachable++
+			}
+			nodeRefs = append(nodeRefs, apicorev1.ObjectReference{
+				Kind:       node.Kind,
+				APIVersion: node.APIVersion,
+				Name:       node.Name,
+				UID:        node.UID,
+			})
+		}
+	}
+
+	logger.V(2).Info("Matched ProviderIDs to nodes", "total", len(providerIDList), "matched", len(nodeRefs), "skippedParseError", skipped, "excludedByTaint", taintExcluded)
+
+	if len(nodeRefs) == 0 {
+		return getNodeReferencesResult{}, ErrNoAvailableNodes
+	}
+	return getNodeReferencesResult{nodeRefs, available, ready, unreachable, taintExcluded}, nil
+}
+
+// WaitForNodeRefsConverged polls the workload cluster every pollInterval until as many Nodes match the MachinePool's
+// ProviderIDList as the MachinePool has desired replicas. It returns the last matched node references, along with
+// the context's error if the context is done before they converge. The pollInterval must be positive.
+func (r *MachinePoolReconciler) WaitForNodeRefsConverged(ctx context.Context, c client.Client, mp *clusterv1.MachinePool, pollInterval time.Duration) ([]apicorev1.ObjectReference, error) {
+	if pollInterval <= 0 {
+		return nil, errors.Errorf("invalid poll interval %v: must be positive", pollInterval)
+	}
+
+	replicas := 1
+	if mp.Spec.Replicas != nil {
+		replicas = int(*mp.Spec.Replicas)
+	}
+
+	for {
+		result, err := r.getNodeReferences(ctx, c, mp.Spec.ProviderIDList)
+		if err != nil && err != ErrNoAvailableNodes {
+			return result.references, err
+		}
+		if len(result.references) == replicas {
+			return result.references, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return result.references, ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// hasUnavailableTaint reports whether the node has a taint whose key is one of UnavailableNodeTaintKeys.
+func (r *MachinePoolReconciler) hasUnavailableTaint(node *apicorev1.Node) bool {
+	for _, taint := range node.Spec.Taints {
+		for _, key := range r.UnavailableNodeTaintKeys {
+			if taint.Key == key {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// listNodesByProviderID lists all nodes of a workload cluster, keyed by the ID part of their provider ID.
+// Nodes whose provider ID cannot be parsed are skipped.
+func listNodesByProviderID(ctx context.Context, c client.Client, logger logr.Logger) (map[string]apicorev1.Node, error) {
 	nodeRefsMap := make(map[string]apicorev1.Node)
 	nodeList := apicorev1.NodeList{}
 	for {
 		if err := c.List(ctx, &nodeList, client.Continue(nodeList.Continue)); err != nil {
-			return getNodeReferencesResult{}, errors.Wrapf(err, "failed to List nodes")
+			return nil, errors.Wrapf(err, "failed to List nodes")
 		}
 
 		for _, node := range nodeList.Items {
 			nodeProviderID, err := noderefutil.NewProviderID(node.Spec.ProviderID)
 			if err != nil {
-				logger.V(2).Info("Failed to parse ProviderID, skipping", "err", err, "providerID", node.Spec.ProviderID)
+				logger.V(2).Info("Failed to parse ProviderID, skipping", "err", err, "source", "node", "node", node.Name, "providerID", node.Spec.ProviderID)
 				continue
 			}
 
@@ -165,39 +368,92 @@ func (r *MachinePoolReconciler) getNodeReferences(ctx context.Context, c client.
 			break
 		}
 	}
+	return nodeRefsMap, nil
+}
 
-	var nodeRefs []apicorev1.ObjectReference
+// RankNodesForScaleDown orders the given provider IDs from the least to the most valuable instance to keep when a
+// MachinePool scales down: instances without a matching node come first, followed by instances whose node is
+// NotReady, unreachable or cordoned, and finally healthy instances, oldest node first.
+// It is advisory only; infrastructure providers may consult it when picking the instances to remove.
+func (r *MachinePoolReconciler) RankNodesForScaleDown(ctx context.Context, c client.Client, providerIDList []string) ([]string, error) {
+	logger := r.Log.WithValues("providerIDList", len(providerIDList))
+
+	nodes, err := listNodesByProviderID(ctx, c, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	type candidate struct {
+		providerID string
+		rank       int
+		node       *apicorev1.Node
+	}
+	candidates := make([]candidate, 0, len(providerIDList))
 	for _, providerID := range providerIDList {
-		pid, err := noderefutil.NewProviderID(providerID)
-		if err != nil {
-			logger.V(2).Info("Failed to parse ProviderID, skipping", "err", err, "providerID", providerID)
-			continue
-		}
-		if node, ok := nodeRefsMap[pid.ID()]; ok {
-			available++
-			if nodeIsReady(&node) {
-				ready++
+		c := candidate{providerID: providerID, rank: scaleDownRankNoNode}
+		if pid, err := noderefutil.NewProviderID(providerID); err == nil {
+			if node, ok := nodes[pid.ID()]; ok {
+				c.node = &node
+				c.rank = scaleDownRank(&node)
 			}
-			nodeRefs = append(nodeRefs, apicorev1.ObjectReference{
-				Kind:       node.Kind,
-				APIVersion: node.APIVersion,
-				Name:       node.Name,
-				UID:        node.UID,
-			})
 		}
+		candidates = append(candidates, c)
 	}
 
-	if len(nodeRefs) == 0 {
-		return getNodeReferencesResult{}, ErrNoAvailableNodes
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].rank != candidates[j].rank {
+			return candidates[i].rank < candidates[j].rank
+		}
+		if candidates[i].node == nil || candidates[j].node == nil {
+			return false
+		}
+		return candidates[i].node.CreationTimestamp.Before(&candidates[j].node.CreationTimestamp)
+	})
+
+	ranked := make([]string, 0, len(candidates))
+	for _, c := range candidates {
+		ranked = append(ranked, c.providerID)
+	}
+	return ranked, nil
+}
+
+// Ranks of an instance when scaling down, from the first to remove to the last.
+const (
+	scaleDownRankNoNode = iota
+	scaleDownRankNotReady
+	scaleDownRankUnreachable
+	scaleDownRankCordoned
+	scaleDownRankHealthy
+)
+
+// scaleDownRank returns the rank of the instance backing the given node when scaling down.
+func scaleDownRank(node *apicorev1.Node) int {
+	ready, unreachable := nodeReadyState(node)
+	switch {
+	case unreachable:
+		return scaleDownRankUnreachable
+	case !ready:
+		return scaleDownRankNotReady
+	case node.Spec.Unschedulable:
+		return scaleDownRankCordoned
+	default:
+		return scaleDownRankHealthy
 	}
-	return getNodeReferencesResult{nodeRefs, available, ready}, nil
 }
 
 func nodeIsReady(node *apicorev1.Node) bool {
+	ready, _ := nodeReadyState(node)
+	return ready
+}
+
+// nodeReadyState reports whether a node is ready and, if it is not, whether it is unreachable.
+// A node is unreachable when its Ready condition is Unknown, which means the kubelet stopped reporting status;
+// this is a different failure mode from Ready=False, where the kubelet reports the node as broken.
+func nodeReadyState(node *apicorev1.Node) (ready bool, unreachable bool) {
 	for _, n := range node.Status.Conditions {
 		if n.Type == apicorev1.NodeReady {
-			return n.Status == apicorev1.ConditionTrue
+			return n.Status == apicorev1.ConditionTrue, n.Status == apicorev1.ConditionUnknown
 		}
 	}
-	return false
+	return false, false
 }
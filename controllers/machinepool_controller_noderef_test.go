@@ -19,17 +19,23 @@ package controllers
 import (
 	"context"
 	"testing"
+	"time"
 
 	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	capierrors "sigs.k8s.io/cluster-api/errors"
 )
 
 func TestMachinePoolGetNodeReference(t *testing.T) {
@@ -166,3 +172,373 @@ func TestMachinePoolGetNodeReference(t *testing.T) {
 
 	}
 }
+
+func TestMachinePoolNodeReadyState(t *testing.T) {
+	nodeWithReady := func(status corev1.ConditionStatus) *corev1.Node {
+		return &corev1.Node{
+			Status: corev1.NodeStatus{
+				Conditions: []corev1.NodeCondition{
+					{Type: corev1.NodeReady, Status: status},
+				},
+			},
+		}
+	}
+
+	testCases := []struct {
+		name                string
+		node                *corev1.Node
+		expectedReady       bool
+		expectedUnreachable bool
+	}{
+		{
+			name:          "ready node",
+			node:          nodeWithReady(corev1.ConditionTrue),
+			expectedReady: true,
+		},
+		{
+			name: "not ready node",
+			node: nodeWithReady(corev1.ConditionFalse),
+		},
+		{
+			name:                "unreachable node",
+			node:                nodeWithReady(corev1.ConditionUnknown),
+			expectedUnreachable: true,
+		},
+		{
+			name: "node without ready condition",
+			node: &corev1.Node{},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			ready, unreachable := nodeReadyState(test.node)
+			g.Expect(ready).To(Equal(test.expectedReady))
+			g.Expect(unreachable).To(Equal(test.expectedUnreachable))
+			g.Expect(nodeIsReady(test.node)).To(Equal(test.expectedReady))
+		})
+	}
+}
+
+func TestMachinePoolGetNodeReferenceUnreachable(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(clusterv1.AddToScheme(scheme.Scheme)).To(Succeed())
+
+	r := &MachinePoolReconciler{
+		Client:   fake.NewFakeClientWithScheme(scheme.Scheme),
+		Log:      log.Log,
+		recorder: record.NewFakeRecorder(32),
+	}
+
+	node := func(name string, status corev1.ConditionStatus) runtime.Object {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+			},
+			Spec: corev1.NodeSpec{
+				ProviderID: "aws://us-east-1/" + name,
+			},
+			Status: corev1.NodeStatus{
+				Conditions: []corev1.NodeCondition{
+					{Type: corev1.NodeReady, Status: status},
+				},
+			},
+		}
+	}
+	client := fake.NewFakeClientWithScheme(scheme.Scheme,
+		node("ready-node", corev1.ConditionTrue),
+		node("not-ready-node", corev1.ConditionFalse),
+		node("unreachable-node", corev1.ConditionUnknown),
+	)
+
+	result, err := r.getNodeReferences(context.TODO(), client, []string{
+		"aws://us-east-1/ready-node",
+		"aws://us-east-1/not-ready-node",
+		"aws://us-east-1/unreachable-node",
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.available).To(Equal(3))
+	g.Expect(result.ready).To(Equal(1))
+	g.Expect(result.unreachable).To(Equal(1))
+}
+
+func TestMachinePoolGetNodeReferencesTaintPolicy(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(clusterv1.AddToScheme(scheme.Scheme)).To(Succeed())
+
+	node := func(name string, taints ...corev1.Taint) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+			},
+			Spec: corev1.NodeSpec{
+				ProviderID: "aws://us-east-1/" + name,
+				Taints:     taints,
+			},
+			Status: corev1.NodeStatus{
+				Conditions: []corev1.NodeCondition{
+					{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+				},
+			},
+		}
+	}
+	client := fake.NewFakeClientWithScheme(scheme.Scheme,
+		node("untainted-node"),
+		node("tolerated-node", corev1.Taint{Key: "dedicated", Value: "gpu", Effect: corev1.TaintEffectNoSchedule}),
+		node("disqualified-node", corev1.Taint{Key: "node.example.com/broken", Effect: corev1.TaintEffectNoExecute}),
+	)
+	providerIDList := []string{
+		"aws://us-east-1/untainted-node",
+		"aws://us-east-1/tolerated-node",
+		"aws://us-east-1/disqualified-node",
+	}
+
+	testCases := []struct {
+		name            string
+		taintKeys       []string
+		expectAvailable int
+		expectTaintExcl int
+	}{
+		{name: "taints are ignored by default", expectAvailable: 3},
+		{name: "disqualifying taint", taintKeys: []string{"node.example.com/broken"}, expectAvailable: 2, expectTaintExcl: 1},
+		{name: "unrelated taint keys", taintKeys: []string{"node.example.com/other"}, expectAvailable: 3},
+	}
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			gt := NewWithT(t)
+
+			r := &MachinePoolReconciler{
+				Client:                   fake.NewFakeClientWithScheme(scheme.Scheme),
+				Log:                      log.Log,
+				recorder:                 record.NewFakeRecorder(32),
+				UnavailableNodeTaintKeys: test.taintKeys,
+			}
+			result, err := r.getNodeReferences(context.TODO(), client, providerIDList)
+			gt.Expect(err).NotTo(HaveOccurred())
+			gt.Expect(result.references).To(HaveLen(3))
+			gt.Expect(result.ready).To(Equal(3))
+			gt.Expect(result.available).To(Equal(test.expectAvailable))
+			gt.Expect(result.taintExcluded).To(Equal(test.expectTaintExcl))
+		})
+	}
+}
+
+// nodeCreatingClient creates a Node right before the List call with the same (1-based) number.
+type nodeCreatingClient struct {
+	client.Client
+	lists        int
+	createOnList map[int]*corev1.Node
+}
+
+func (c *nodeCreatingClient) List(ctx context.Context, list runtime.Object, opts ...client.ListOption) error {
+	c.lists++
+	if node, ok := c.createOnList[c.lists]; ok {
+		if err := c.Client.Create(ctx, node); err != nil {
+			return err
+		}
+	}
+	return c.Client.List(ctx, list, opts...)
+}
+
+func TestMachinePoolWaitForNodeRefsConverged(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(clusterv1.AddToScheme(scheme.Scheme)).To(Succeed())
+
+	node := func(name string) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       corev1.NodeSpec{ProviderID: "aws://us-east-1/" + name},
+		}
+	}
+	replicas := int32(2)
+	mp := &clusterv1.MachinePool{
+		ObjectMeta: metav1.ObjectMeta{Name: "machinepool-test", Namespace: "default"},
+		Spec: clusterv1.MachinePoolSpec{
+			Replicas:       &replicas,
+			ProviderIDList: []string{"aws://us-east-1/node-1", "aws://us-east-1/node-2"},
+		},
+	}
+	r := &MachinePoolReconciler{
+		Client:   fake.NewFakeClientWithScheme(scheme.Scheme),
+		Log:      log.Log,
+		recorder: record.NewFakeRecorder(32),
+	}
+
+	t.Run("converges after a few polls", func(t *testing.T) {
+		gt := NewWithT(t)
+
+		c := &nodeCreatingClient{
+			Client:       fake.NewFakeClientWithScheme(scheme.Scheme),
+			createOnList: map[int]*corev1.Node{2: node("node-1"), 3: node("node-2")},
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		refs, err := r.WaitForNodeRefsConverged(ctx, c, mp, time.Millisecond)
+		gt.Expect(err).NotTo(HaveOccurred())
+		gt.Expect(refs).To(HaveLen(2))
+		gt.Expect(c.lists).To(Equal(3))
+	})
+
+	t.Run("times out", func(t *testing.T) {
+		gt := NewWithT(t)
+
+		c := fake.NewFakeClientWithScheme(scheme.Scheme, node("node-1"))
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		refs, err := r.WaitForNodeRefsConverged(ctx, c, mp, 10*time.Millisecond)
+		gt.Expect(err).To(Equal(context.DeadlineExceeded))
+		gt.Expect(refs).To(HaveLen(1))
+		gt.Expect(refs[0].Name).To(Equal("node-1"))
+	})
+
+	t.Run("rejects a non-positive poll interval", func(t *testing.T) {
+		gt := NewWithT(t)
+
+		c := fake.NewFakeClientWithScheme(scheme.Scheme, node("node-1"))
+		_, err := r.WaitForNodeRefsConverged(context.Background(), c, mp, 0)
+		gt.Expect(err).To(HaveOccurred())
+	})
+}
+
+func TestMachinePoolDeleteRetiredNodesDrain(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(clusterv1.AddToScheme(scheme.Scheme)).To(Succeed())
+
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "default"}}
+	retiredNode := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "retired-node",
+			UID:  "retired-node-uid",
+		},
+		Spec: corev1.NodeSpec{
+			ProviderID: "aws://us-east-1/retired-node",
+		},
+	}
+	nodeRefs := []corev1.ObjectReference{{Kind: "Node", Name: "retired-node"}}
+	providerIDList := []string{"aws://us-east-1/other-node"}
+
+	nodeExists := func(c client.Client) bool {
+		err := c.Get(context.TODO(), types.NamespacedName{Name: "retired-node"}, &corev1.Node{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			t.Fatal(err)
+		}
+		return err == nil
+	}
+
+	testCases := []struct {
+		name string
+		// drainResults are the results of consecutive drain attempts.
+		drainResults []error
+		// expectRequeues is the number of reconciles that leave the node in place.
+		expectRequeues int
+	}{
+		{
+			name:           "drain succeeds first try",
+			drainResults:   []error{nil},
+			expectRequeues: 0,
+		},
+		{
+			name:           "drain times out then succeeds on requeue",
+			drainResults:   []error{errors.New("drain timed out"), nil},
+			expectRequeues: 1,
+		},
+		{
+			name:           "drain exhausts attempts then force deletes",
+			drainResults:   []error{errors.New("drain timed out"), errors.New("drain timed out"), errors.New("drain timed out")},
+			expectRequeues: 2,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			drains := 0
+			r := &MachinePoolReconciler{
+				Client:                      fake.NewFakeClientWithScheme(scheme.Scheme),
+				Log:                         log.Log,
+				recorder:                    record.NewFakeRecorder(32),
+				RetiredNodeDrainTimeout:     10 * time.Second,
+				RetiredNodeMaxDrainAttempts: 3,
+				retiredNodeDrainer: func(_ context.Context, _ *clusterv1.Cluster, _ *corev1.Node) error {
+					err := tc.drainResults[drains]
+					drains++
+					return err
+				},
+			}
+			clusterClient := fake.NewFakeClientWithScheme(scheme.Scheme, retiredNode.DeepCopy())
+
+			for i := 0; i < tc.expectRequeues; i++ {
+				err := r.deleteRetiredNodes(context.TODO(), cluster, clusterClient, nodeRefs, providerIDList)
+				requeueErr, ok := errors.Cause(err).(capierrors.HasRequeueAfterError)
+				g.Expect(ok).To(BeTrue())
+				g.Expect(requeueErr.GetRequeueAfter()).To(Equal(10 * time.Second))
+				g.Expect(nodeExists(clusterClient)).To(BeTrue())
+			}
+
+			g.Expect(r.deleteRetiredNodes(context.TODO(), cluster, clusterClient, nodeRefs, providerIDList)).To(Succeed())
+			g.Expect(nodeExists(clusterClient)).To(BeFalse())
+			g.Expect(drains).To(Equal(len(tc.drainResults)))
+			g.Expect(r.drainAttempts).NotTo(HaveKey(retiredNode.UID))
+		})
+	}
+}
+
+func TestMachinePoolRankNodesForScaleDown(t *testing.T) {
+	g := NewWithT(t)
+
+	r := &MachinePoolReconciler{
+		Client:   fake.NewFakeClientWithScheme(scheme.Scheme),
+		Log:      log.Log,
+		recorder: record.NewFakeRecorder(32),
+	}
+
+	node := func(name string, created int64, ready corev1.ConditionStatus, cordoned bool) runtime.Object {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				CreationTimestamp: metav1.NewTime(time.Unix(created, 0)),
+			},
+			Spec: corev1.NodeSpec{
+				ProviderID:    "aws://us-east-1/" + name,
+				Unschedulable: cordoned,
+			},
+			Status: corev1.NodeStatus{
+				Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: ready}},
+			},
+		}
+	}
+	client := fake.NewFakeClientWithScheme(scheme.Scheme,
+		node("healthy-new", 3, corev1.ConditionTrue, false),
+		node("healthy-old", 1, corev1.ConditionTrue, false),
+		node("cordoned", 2, corev1.ConditionTrue, true),
+		node("unreachable", 2, corev1.ConditionUnknown, false),
+		node("not-ready", 2, corev1.ConditionFalse, false),
+	)
+
+	ranked, err := r.RankNodesForScaleDown(context.Background(), client, []string{
+		"aws://us-east-1/healthy-new",
+		"aws://us-east-1/healthy-old",
+		"aws://us-east-1/cordoned",
+		"aws://us-east-1/unreachable",
+		"aws://us-east-1/not-ready",
+		"aws://us-east-1/missing",
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ranked).To(Equal([]string{
+		"aws://us-east-1/missing",
+		"aws://us-east-1/not-ready",
+		"aws://us-east-1/unreachable",
+		"aws://us-east-1/cordoned",
+		"aws://us-east-1/healthy-old",
+		"aws://us-east-1/healthy-new",
+	}))
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/controllers/external"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// gpuResourceSuffix is the suffix of the extended resources reported as GPUs, e.g. "nvidia.com/gpu".
+const gpuResourceSuffix = "/gpu"
+
+// reconcileCapacityAnnotations sets the cluster autoscaler capacity annotations of a MachineDeployment or MachineSet
+// from the "status.capacity" field of the infrastructure template its machines are created from, so that the
+// cluster autoscaler can scale it up from zero replicas. It returns true if the annotations changed.
+//
+// Templates that do not report their capacity are ignored, which leaves the annotations set by users untouched.
+func reconcileCapacityAnnotations(ctx context.Context, c client.Client, obj metav1.Object, ref *corev1.ObjectReference) (bool, error) {
+	if !strings.HasSuffix(ref.Kind, external.TemplateSuffix) {
+		return false, nil
+	}
+
+	template, err := external.Get(ctx, c, ref, obj.GetNamespace())
+	if err != nil {
+		return false, err
+	}
+
+	capacity, found, err := unstructured.NestedStringMap(template.Object, "status", "capacity")
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to retrieve the capacity of %s %q", template.GetKind(), template.GetName())
+	}
+	if !found {
+		return false, nil
+	}
+
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	changed := false
+	set := func(key, value string) {
+		if annotations[key] != value {
+			annotations[key] = value
+			changed = true
+		}
+	}
+
+	names := make([]string, 0, len(capacity))
+	for name := range capacity {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		quantity, err := resource.ParseQuantity(capacity[name])
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to parse the %q capacity of %s %q", name, template.GetKind(), template.GetName())
+		}
+
+		switch {
+		case name == string(corev1.ResourceCPU):
+			set(clusterv1.AutoscalerCapacityCPUAnnotation, quantity.String())
+		case name == string(corev1.ResourceMemory):
+			set(clusterv1.AutoscalerCapacityMemoryAnnotation, quantity.String())
+		case strings.HasSuffix(name, gpuResourceSuffix):
+			set(clusterv1.AutoscalerCapacityGPUCountAnnotation, quantity.String())
+			set(clusterv1.AutoscalerCapacityGPUTypeAnnotation, name)
+		}
+	}
+
+	obj.SetAnnotations(annotations)
+	return changed, nil
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/scheme"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconcileCapacityAnnotations(t *testing.T) {
+	newTemplate := func(capacity map[string]interface{}) *unstructured.Unstructured {
+		template := &unstructured.Unstructured{
+			Object: map[string]interface{}{
+				"spec": map[string]interface{}{},
+			},
+		}
+		if capacity != nil {
+			template.Object["status"] = map[string]interface{}{
+				"capacity": capacity,
+			}
+		}
+		template.SetKind("InfrastructureMachineTemplate")
+		template.SetAPIVersion("infrastructure.cluster.x-k8s.io/v1alpha3")
+		template.SetName("ms-template")
+		template.SetNamespace("default")
+		return template
+	}
+	ref := &corev1.ObjectReference{
+		Kind:       "InfrastructureMachineTemplate",
+		APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+		Name:       "ms-template",
+	}
+
+	tests := []struct {
+		name                string
+		template            *unstructured.Unstructured
+		annotations         map[string]string
+		expectedChanged     bool
+		expectedAnnotations map[string]string
+		expectError         bool
+	}{
+		{
+			name: "template with capacity",
+			template: newTemplate(map[string]interface{}{
+				"cpu":            "4",
+				"memory":         "16Gi",
+				"nvidia.com/gpu": "1",
+				"pods":           "110",
+			}),
+			expectedChanged: true,
+			expectedAnnotations: map[string]string{
+				clusterv1.AutoscalerCapacityCPUAnnotation:      "4",
+				clusterv1.AutoscalerCapacityMemoryAnnotation:   "16Gi",
+				clusterv1.AutoscalerCapacityGPUCountAnnotation: "1",
+				clusterv1.AutoscalerCapacityGPUTypeAnnotation:  "nvidia.com/gpu",
+			},
+		},
+		{
+			name: "template capacity overrides the annotations",
+			template: newTemplate(map[string]interface{}{
+				"cpu":    "8",
+				"memory": "16Gi",
+			}),
+			annotations: map[string]string{
+				clusterv1.AutoscalerCapacityCPUAnnotation:    "4",
+				clusterv1.AutoscalerCapacityMemoryAnnotation: "16Gi",
+				"foo": "bar",
+			},
+			expectedChanged: true,
+			expectedAnnotations: map[string]string{
+				clusterv1.AutoscalerCapacityCPUAnnotation:    "8",
+				clusterv1.AutoscalerCapacityMemoryAnnotation: "16Gi",
+				"foo": "bar",
+			},
+		},
+		{
+			name: "annotations up to date",
+			template: newTemplate(map[string]interface{}{
+				"cpu": "4",
+			}),
+			annotations: map[string]string{
+				clusterv1.AutoscalerCapacityCPUAnnotation: "4",
+			},
+			expectedAnnotations: map[string]string{
+				clusterv1.AutoscalerCapacityCPUAnnotation: "4",
+			},
+		},
+		{
+			name:     "template without capacity keeps the annotations set by users",
+			template: newTemplate(nil),
+			annotations: map[string]string{
+				clusterv1.AutoscalerCapacityCPUAnnotation: "2",
+			},
+			expectedAnnotations: map[string]string{
+				clusterv1.AutoscalerCapacityCPUAnnotation: "2",
+			},
+		},
+		{
+			name: "invalid capacity",
+			template: newTemplate(map[string]interface{}{
+				"cpu": "four",
+			}),
+			expectError: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			ms := &clusterv1.MachineSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "ms",
+					Namespace:   "default",
+					Annotations: tc.annotations,
+				},
+			}
+			c := fake.NewFakeClientWithScheme(scheme.Scheme, tc.template)
+
+			changed, err := reconcileCapacityAnnotations(ctx, c, ms, ref)
+			if tc.expectError {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(changed).To(Equal(tc.expectedChanged))
+			g.Expect(ms.Annotations).To(Equal(tc.expectedAnnotations))
+		})
+	}
+}
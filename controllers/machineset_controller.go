@@ -40,6 +40,7 @@ import (
 	"sigs.k8s.io/cluster-api/util"
 	utilconversion "sigs.k8s.io/cluster-api/util/conversion"
 	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/cluster-api/util/predicates"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
@@ -75,7 +76,7 @@ type MachineSetReconciler struct {
 }
 
 func (r *MachineSetReconciler) SetupWithManager(mgr ctrl.Manager, options controller.Options) error {
-	err := ctrl.NewControllerManagedBy(mgr).
+	c, err := ctrl.NewControllerManagedBy(mgr).
 		For(&clusterv1.MachineSet{}).
 		Owns(&clusterv1.Machine{}).
 		Watches(
@@ -83,12 +84,21 @@ func (r *MachineSetReconciler) SetupWithManager(mgr ctrl.Manager, options contro
 			&handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(r.MachineToMachineSets)},
 		).
 		WithOptions(options).
-		Complete(r)
+		Build(r)
 
 	if err != nil {
 		return errors.Wrap(err, "failed setting up with a controller manager")
 	}
 
+	err = c.Watch(
+		&source.Kind{Type: &clusterv1.Cluster{}},
+		&handler.EnqueueRequestsFromMapFunc{ToRequests: util.ClusterToObjectsMapper(r.Client, &clusterv1.MachineSetList{})},
+		predicates.ClusterPausedChanged(),
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed adding Watch for Clusters to controller manager")
+	}
+
 	r.recorder = mgr.GetEventRecorderFor("machineset-controller")
 	r.scheme = mgr.GetScheme()
 	return nil
@@ -174,6 +184,20 @@ func (r *MachineSetReconciler) reconcile(ctx context.Context, cluster *clusterv1
 		}
 	}
 
+	// Keep the cluster autoscaler capacity annotations in sync with the infrastructure template.
+	capacityPatch := client.MergeFrom(machineSet.DeepCopy())
+	changed, err := reconcileCapacityAnnotations(ctx, r.Client, machineSet, &machineSet.Spec.Template.Spec.InfrastructureRef)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if changed {
+		// Patch using a deep copy to avoid overwriting any unexpected Status changes from the returned result
+		if err := r.Client.Patch(ctx, machineSet.DeepCopy(), capacityPatch); err != nil {
+			return ctrl.Result{}, errors.Wrapf(err, "failed to update the capacity annotations of MachineSet %s/%s",
+				machineSet.Namespace, machineSet.Name)
+		}
+	}
+
 	// Make sure selector and template to be in the same cluster.
 	machineSet.Spec.Selector.MatchLabels[clusterv1.ClusterLabelName] = machineSet.Spec.ClusterName
 	machineSet.Spec.Template.Labels[clusterv1.ClusterLabelName] = machineSet.Spec.ClusterName
@@ -276,6 +300,10 @@ func (r *MachineSetReconciler) syncReplicas(ctx context.Context, ms *clusterv1.M
 
 	if diff < 0 {
 		diff *= -1
+		if ms.Annotations[clusterv1.DisableMachineCreateAnnotation] == "true" {
+			logger.V(2).Info("Too few replicas, but machine creation is disabled", "need", *(ms.Spec.Replicas), "missing", diff)
+			return nil
+		}
 		logger.Info("Too few replicas", "need", *(ms.Spec.Replicas), "creating", diff)
 
 		var machineList []*clusterv1.Machine
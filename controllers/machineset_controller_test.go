@@ -31,6 +31,7 @@ import (
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/klog/klogr"
+	"k8s.io/utils/pointer"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
@@ -809,3 +810,32 @@ func newMachineSet(name, cluster string) *clusterv1.MachineSet {
 		},
 	}
 }
+
+func TestSyncReplicasWithMachineCreateDisabled(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(clusterv1.AddToScheme(scheme.Scheme)).To(Succeed())
+
+	ms := &clusterv1.MachineSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "ms",
+			Namespace:   "default",
+			Annotations: map[string]string{clusterv1.DisableMachineCreateAnnotation: "true"},
+		},
+		Spec: clusterv1.MachineSetSpec{
+			ClusterName: "test-cluster",
+			Replicas:    pointer.Int32Ptr(2),
+		},
+	}
+	c := fake.NewFakeClientWithScheme(scheme.Scheme, ms)
+	r := &MachineSetReconciler{
+		Client:   c,
+		Log:      log.Log,
+		recorder: record.NewFakeRecorder(32),
+	}
+	g.Expect(r.syncReplicas(ctx, ms, nil)).To(Succeed())
+
+	machines := &clusterv1.MachineList{}
+	g.Expect(c.List(ctx, machines)).To(Succeed())
+	g.Expect(machines.Items).To(BeEmpty())
+}
@@ -33,6 +33,8 @@ type (
 const (
 	// DeleteNodeAnnotation marks nodes that will be given priority for deletion
 	// when a machineset scales down. This annotation is given top priority on all delete policies.
+	//
+	// Deprecated: Use clusterv1.DeleteMachineAnnotation instead.
 	DeleteNodeAnnotation = "cluster.k8s.io/delete-machine"
 
 	mustDelete    deletePriority = 100.0
@@ -48,7 +50,7 @@ func oldestDeletePriority(machine *clusterv1.Machine) deletePriority {
 	if !machine.DeletionTimestamp.IsZero() {
 		return mustDelete
 	}
-	if machine.ObjectMeta.Annotations != nil && machine.ObjectMeta.Annotations[DeleteNodeAnnotation] != "" {
+	if hasDeleteMachineAnnotation(machine) {
 		return mustDelete
 	}
 	if machine.Status.FailureReason != nil || machine.Status.FailureMessage != nil {
@@ -68,7 +70,7 @@ func newestDeletePriority(machine *clusterv1.Machine) deletePriority {
 	if !machine.DeletionTimestamp.IsZero() {
 		return mustDelete
 	}
-	if machine.ObjectMeta.Annotations != nil && machine.ObjectMeta.Annotations[DeleteNodeAnnotation] != "" {
+	if hasDeleteMachineAnnotation(machine) {
 		return mustDelete
 	}
 	if machine.Status.FailureReason != nil || machine.Status.FailureMessage != nil {
@@ -81,7 +83,7 @@ func randomDeletePolicy(machine *clusterv1.Machine) deletePriority {
 	if !machine.DeletionTimestamp.IsZero() {
 		return mustDelete
 	}
-	if machine.ObjectMeta.Annotations != nil && machine.ObjectMeta.Annotations[DeleteNodeAnnotation] != "" {
+	if hasDeleteMachineAnnotation(machine) {
 		return betterDelete
 	}
 	if machine.Status.FailureReason != nil || machine.Status.FailureMessage != nil {
@@ -90,6 +92,15 @@ func randomDeletePolicy(machine *clusterv1.Machine) deletePriority {
 	return couldDelete
 }
 
+// hasDeleteMachineAnnotation returns whether the Machine is marked to be deleted first, either with
+// clusterv1.DeleteMachineAnnotation, whatever its value, or with a non-empty DeleteNodeAnnotation.
+func hasDeleteMachineAnnotation(machine *clusterv1.Machine) bool {
+	if _, ok := machine.Annotations[clusterv1.DeleteMachineAnnotation]; ok {
+		return true
+	}
+	return machine.Annotations[DeleteNodeAnnotation] != ""
+}
+
 type sortableMachines struct {
 	machines []*clusterv1.Machine
 	priority deletePriorityFunc
@@ -112,7 +123,8 @@ func getMachinesToDeletePrioritized(filteredMachines []*clusterv1.Machine, diff
 		machines: filteredMachines,
 		priority: fun,
 	}
-	sort.Sort(sortable)
+	// Keep the order of Machines with the same priority, so that the same ones are picked on every reconcile.
+	sort.Stable(sortable)
 
 	return sortable.machines[:diff]
 }
@@ -32,6 +32,7 @@ func TestMachineToDelete(t *testing.T) {
 	mustDeleteMachine := &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{DeletionTimestamp: &now}}
 	betterDeleteMachine := &clusterv1.Machine{Status: clusterv1.MachineStatus{FailureMessage: &msg}}
 	deleteMeMachine := &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{DeleteNodeAnnotation: "yes"}}}
+	deleteMachineWithAnnotation := &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{clusterv1.DeleteMachineAnnotation: ""}}}
 
 	tests := []struct {
 		desc     string
@@ -149,6 +150,18 @@ func TestMachineToDelete(t *testing.T) {
 			expect: []*clusterv1.Machine{
 				deleteMeMachine,
 			},
+		},
+		{
+			desc: "func=randomDeletePolicy, delete-machine annotation, diff=1",
+			diff: 1,
+			machines: []*clusterv1.Machine{
+				{},
+				deleteMachineWithAnnotation,
+				{},
+			},
+			expect: []*clusterv1.Machine{
+				deleteMachineWithAnnotation,
+			},
 		}}
 
 	for _, test := range tests {
@@ -169,6 +182,7 @@ func TestMachineNewestDelete(t *testing.T) {
 	old := &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(currentTime.Time.AddDate(0, 0, -10))}}
 	oldest := &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(currentTime.Time.AddDate(0, 0, -10))}}
 	annotatedMachine := &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{DeleteNodeAnnotation: "yes"}, CreationTimestamp: metav1.NewTime(currentTime.Time.AddDate(0, 0, -10))}}
+	deleteMachineWithAnnotation := &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{clusterv1.DeleteMachineAnnotation: ""}, CreationTimestamp: metav1.NewTime(currentTime.Time.AddDate(0, 0, -1))}}
 	unhealthyMachine := &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(currentTime.Time.AddDate(0, 0, -10))}, Status: clusterv1.MachineStatus{FailureReason: &statusError}}
 
 	tests := []struct {
@@ -209,6 +223,14 @@ func TestMachineNewestDelete(t *testing.T) {
 			},
 			expect: []*clusterv1.Machine{annotatedMachine},
 		},
+		{
+			desc: "func=newestDeletePriority, diff=1 (delete-machine annotation)",
+			diff: 1,
+			machines: []*clusterv1.Machine{
+				new, oldest, old, newest, deleteMachineWithAnnotation,
+			},
+			expect: []*clusterv1.Machine{deleteMachineWithAnnotation},
+		},
 		{
 			desc: "func=newestDeletePriority, diff=1 (unhealthy)",
 			diff: 1,
@@ -237,6 +259,7 @@ func TestMachineOldestDelete(t *testing.T) {
 	old := &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(currentTime.Time.AddDate(0, 0, -10))}}
 	oldest := &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(currentTime.Time.AddDate(0, 0, -10))}}
 	annotatedMachine := &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{DeleteNodeAnnotation: "yes"}, CreationTimestamp: metav1.NewTime(currentTime.Time.AddDate(0, 0, -10))}}
+	deleteMachineWithAnnotation := &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{clusterv1.DeleteMachineAnnotation: ""}, CreationTimestamp: metav1.NewTime(currentTime.Time.AddDate(0, 0, -1))}}
 	unhealthyMachine := &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(currentTime.Time.AddDate(0, 0, -10))}, Status: clusterv1.MachineStatus{FailureReason: &statusError}}
 
 	tests := []struct {
@@ -285,6 +308,14 @@ func TestMachineOldestDelete(t *testing.T) {
 			},
 			expect: []*clusterv1.Machine{annotatedMachine},
 		},
+		{
+			desc: "func=oldestDeletePriority, diff=1 (delete-machine annotation)",
+			diff: 1,
+			machines: []*clusterv1.Machine{
+				empty, new, oldest, old, newest, deleteMachineWithAnnotation,
+			},
+			expect: []*clusterv1.Machine{deleteMachineWithAnnotation},
+		},
 		{
 			desc: "func=oldestDeletePriority, diff=1 (unhealthy)",
 			diff: 1,
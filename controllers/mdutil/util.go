@@ -125,6 +125,24 @@ func MaxRevision(allMSs []*clusterv1.MachineSet, logger logr.Logger) int64 {
 	return max
 }
 
+// LastRevision finds the second highest revision in the machine sets, i.e. the revision before the current one.
+func LastRevision(allMSs []*clusterv1.MachineSet, logger logr.Logger) int64 {
+	max, secMax := int64(0), int64(0)
+	for _, ms := range allMSs {
+		if v, err := Revision(ms); err != nil {
+			// Skip the machine sets when it failed to parse their revision information
+			logger.Error(err, "Couldn't parse revision for machine set, deployment controller will skip it when reconciling revisions",
+				"machineset", ms.Name)
+		} else if v >= max {
+			secMax = max
+			max = v
+		} else if v > secMax {
+			secMax = v
+		}
+	}
+	return secMax
+}
+
 // Revision returns the revision number of the input object.
 func Revision(obj runtime.Object) (int64, error) {
 	acc, err := meta.Accessor(obj)
@@ -144,6 +162,8 @@ var annotationsToSkip = map[string]bool{
 	clusterv1.RevisionHistoryAnnotation: true,
 	clusterv1.DesiredReplicasAnnotation: true,
 	clusterv1.MaxReplicasAnnotation:     true,
+
+	clusterv1.RollbackToRevisionAnnotation: true,
 }
 
 // skipCopyAnnotation returns true if we should skip copying the annotation with the given annotation key
@@ -524,6 +544,15 @@ func NewMSNewReplicas(deployment *clusterv1.MachineDeployment, allMSs []*cluster
 		// Do not exceed the number of desired replicas.
 		scaleUpCount = integer.Int32Min(scaleUpCount, *(deployment.Spec.Replicas)-*(newMS.Spec.Replicas))
 		return *(newMS.Spec.Replicas) + scaleUpCount, nil
+	case clusterv1.OnDeleteMachineDeploymentStrategyType:
+		// Find the total number of machines
+		currentMachineCount := GetReplicaCountForMachineSets(allMSs)
+		if currentMachineCount >= *(deployment.Spec.Replicas) {
+			// Cannot scale up until old machines are deleted.
+			return *(newMS.Spec.Replicas), nil
+		}
+		// Scale up to replace the deleted old machines.
+		return *(newMS.Spec.Replicas) + *(deployment.Spec.Replicas) - currentMachineCount, nil
 	default:
 		// Check if we can scale up.
 		maxSurge, err := intstrutil.GetValueFromIntOrPercent(deployment.Spec.Strategy.RollingUpdate.MaxSurge, int(*(deployment.Spec.Replicas)), true)
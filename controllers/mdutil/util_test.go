@@ -518,6 +518,49 @@ func TestResolveFenceposts(t *testing.T) {
 	}
 }
 
+func TestLastRevision(t *testing.T) {
+	msWithRevision := func(revision string) *clusterv1.MachineSet {
+		ms := generateMS(generateDeployment("nginx"))
+		ms.Annotations = map[string]string{clusterv1.RevisionAnnotation: revision}
+		return &ms
+	}
+
+	tests := []struct {
+		Name     string
+		msList   []*clusterv1.MachineSet
+		expected int64
+	}{
+		{
+			"no machine sets",
+			nil,
+			0,
+		},
+		{
+			"single revision",
+			[]*clusterv1.MachineSet{msWithRevision("1")},
+			0,
+		},
+		{
+			"second highest revision",
+			[]*clusterv1.MachineSet{msWithRevision("2"), msWithRevision("4"), msWithRevision("3"), msWithRevision("1")},
+			3,
+		},
+		{
+			"invalid revisions are skipped",
+			[]*clusterv1.MachineSet{msWithRevision("2"), msWithRevision("invalid"), msWithRevision("1")},
+			1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			if revision := LastRevision(test.msList, klogr.New()); revision != test.expected {
+				t.Errorf("In test case %s, expected %d, got %d", test.Name, test.expected, revision)
+			}
+		})
+	}
+}
+
 func TestNewMSNewReplicas(t *testing.T) {
 	tests := []struct {
 		Name          string
@@ -537,6 +580,16 @@ func TestNewMSNewReplicas(t *testing.T) {
 			clusterv1.RollingUpdateMachineDeploymentStrategyType,
 			6, 2, 10, 6,
 		},
+		{
+			"on delete can not scale up - to newMSReplicas",
+			clusterv1.OnDeleteMachineDeploymentStrategyType,
+			5, 0, 0, 0,
+		},
+		{
+			"on delete scale up - to replace missing replicas",
+			clusterv1.OnDeleteMachineDeploymentStrategyType,
+			7, 1, 0, 3,
+		},
 	}
 	newDeployment := generateDeployment("nginx")
 	newRC := generateMS(newDeployment)
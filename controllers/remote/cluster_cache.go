@@ -0,0 +1,421 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remote
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util/secret"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+const (
+	// defaultHealthCheckInterval is how often the API server of a workload cluster is probed if
+	// ClusterCacheTracker.HealthCheckInterval is not set.
+	defaultHealthCheckInterval = 10 * time.Second
+
+	// defaultHealthCheckTimeout is how long a probe of the API server of a workload cluster may take if
+	// ClusterCacheTracker.HealthCheckTimeout is not set.
+	defaultHealthCheckTimeout = 5 * time.Second
+
+	// defaultHealthCheckFailureThreshold is the number of consecutive failed probes after which the entry of a
+	// workload cluster is torn down if ClusterCacheTracker.HealthCheckFailureThreshold is not set.
+	defaultHealthCheckFailureThreshold = 3
+)
+
+// ClusterCacheTracker manages a client and a cache per workload cluster, so that the controllers of the management
+// cluster share their connections to a workload cluster, and can watch its objects instead of polling them.
+//
+// The entry of a workload cluster is created on first use, and runs until DeleteClusterCache is called. It is torn
+// down, along with its watches, when the kubeconfig of the workload cluster changes or its API server stops answering
+// the health checks; the next use recreates it.
+type ClusterCacheTracker struct {
+	// HealthCheckInterval is how often the API server of every tracked workload cluster is probed.
+	// defaultHealthCheckInterval is used if it is zero.
+	HealthCheckInterval time.Duration
+
+	// HealthCheckTimeout is how long a probe of the API server of a workload cluster may take.
+	// defaultHealthCheckTimeout is used if it is zero.
+	HealthCheckTimeout time.Duration
+
+	// HealthCheckFailureThreshold is the number of consecutive failed probes after which the entry of a workload
+	// cluster is torn down. defaultHealthCheckFailureThreshold is used if it is not positive.
+	HealthCheckFailureThreshold int
+
+	log    logr.Logger
+	client client.Client
+	scheme *runtime.Scheme
+
+	// healthCheck probes the API server of a workload cluster; pingAPIServer is used if it is nil.
+	healthCheck func(ctx context.Context, restConfig *rest.Config) error
+
+	// lock protects clusterCaches, clusterLocks and the watches of the entries. It is only held briefly: reading the
+	// kubeconfig of a workload cluster and building its entry are serialized by the lock of the cluster instead, so
+	// that a slow workload cluster does not hold up the others.
+	lock          sync.Mutex
+	clusterCaches map[types.NamespacedName]*clusterCache
+	clusterLocks  map[types.NamespacedName]*sync.Mutex
+}
+
+// clusterCache is the client and the cache of a workload cluster, along with the watches it feeds.
+type clusterCache struct {
+	cache.Cache
+	client     client.Client
+	restConfig *rest.Config
+
+	// kubeconfigVersion is the ResourceVersion of the kubeconfig secret the entry was built from.
+	kubeconfigVersion string
+
+	stop chan struct{}
+	// watches maps the name of every watch fed by the cache to the channel that stops it.
+	watches map[string]chan struct{}
+}
+
+// NewClusterCacheTracker returns a ClusterCacheTracker that reads the kubeconfig of the workload clusters with the
+// given management cluster client.
+func NewClusterCacheTracker(log logr.Logger, c client.Client, scheme *runtime.Scheme) *ClusterCacheTracker {
+	return &ClusterCacheTracker{
+		log:           log,
+		client:        c,
+		scheme:        scheme,
+		clusterCaches: map[types.NamespacedName]*clusterCache{},
+		clusterLocks:  map[types.NamespacedName]*sync.Mutex{},
+	}
+}
+
+// GetClient returns the shared client of a workload cluster.
+func (t *ClusterCacheTracker) GetClient(ctx context.Context, cluster *clusterv1.Cluster) (client.Client, error) {
+	clusterLock := t.clusterLock(cluster)
+	clusterLock.Lock()
+	defer clusterLock.Unlock()
+
+	cc, err := t.getOrCreateClusterCache(ctx, cluster)
+	if err != nil {
+		return nil, err
+	}
+	return cc.client, nil
+}
+
+// GetRESTConfig returns a copy of the REST configuration the shared client of a workload cluster was built from.
+func (t *ClusterCacheTracker) GetRESTConfig(ctx context.Context, cluster *clusterv1.Cluster) (*rest.Config, error) {
+	clusterLock := t.clusterLock(cluster)
+	clusterLock.Lock()
+	defer clusterLock.Unlock()
+
+	cc, err := t.getOrCreateClusterCache(ctx, cluster)
+	if err != nil {
+		return nil, err
+	}
+	return rest.CopyConfig(cc.restConfig), nil
+}
+
+// ClusterClient implements ClusterClientGetter with the shared clients of the tracker. The given client and scheme
+// are ignored in favor of the ones the tracker was created with.
+func (t *ClusterCacheTracker) ClusterClient(ctx context.Context, _ client.Client, cluster *clusterv1.Cluster, _ *runtime.Scheme) (client.Client, error) {
+	return t.GetClient(ctx, cluster)
+}
+
+// Watcher is the part of a controller.Controller that adds watches.
+type Watcher interface {
+	Watch(src source.Source, eventhandler handler.EventHandler, predicates ...predicate.Predicate) error
+}
+
+// WatchInput specifies a watch on the objects of a workload cluster.
+type WatchInput struct {
+	// Name identifies the watch, so that it is added at most once per workload cluster.
+	Name string
+
+	// Cluster is the workload cluster to watch.
+	Cluster *clusterv1.Cluster
+
+	// Watcher is the controller that reconciles the events of the watch.
+	Watcher Watcher
+
+	// Kind is the kind of object to watch.
+	Kind runtime.Object
+
+	// EventHandler maps the events of the watch to reconcile requests.
+	EventHandler handler.EventHandler
+
+	// Predicates filter the events of the watch.
+	Predicates []predicate.Predicate
+}
+
+// Watch adds a watch on the objects of a workload cluster, unless a watch with the same name already exists.
+// The cache of the workload cluster is created and started on the first watch.
+func (t *ClusterCacheTracker) Watch(ctx context.Context, input WatchInput) error {
+	clusterLock := t.clusterLock(input.Cluster)
+	clusterLock.Lock()
+	defer clusterLock.Unlock()
+
+	clusterKey := types.NamespacedName{Namespace: input.Cluster.Namespace, Name: input.Cluster.Name}
+	cc, err := t.getOrCreateClusterCache(ctx, input.Cluster)
+	if err != nil {
+		return err
+	}
+	t.lock.Lock()
+	_, ok := cc.watches[input.Name]
+	t.lock.Unlock()
+	if ok {
+		return nil
+	}
+
+	informer, err := cc.GetInformer(input.Kind)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create an informer for %T of Cluster %s/%s", input.Kind, clusterKey.Namespace, clusterKey.Name)
+	}
+	stop := make(chan struct{})
+	predicates := append([]predicate.Predicate{watchRunning(stop)}, input.Predicates...)
+	if err := input.Watcher.Watch(&source.Informer{Informer: informer}, input.EventHandler, predicates...); err != nil {
+		return errors.Wrapf(err, "failed to add watch %q on Cluster %s/%s", input.Name, clusterKey.Namespace, clusterKey.Name)
+	}
+
+	t.lock.Lock()
+	cc.watches[input.Name] = stop
+	t.lock.Unlock()
+	t.log.Info("Added watch on workload cluster", "watch", input.Name, "cluster", clusterKey.Name, "namespace", clusterKey.Namespace)
+	return nil
+}
+
+// Watching returns true if the named watch was added on the workload cluster.
+func (t *ClusterCacheTracker) Watching(clusterKey types.NamespacedName, name string) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	cc, ok := t.clusterCaches[clusterKey]
+	if !ok {
+		return false
+	}
+	_, ok = cc.watches[name]
+	return ok
+}
+
+// Unwatch stops the named watch on the workload cluster, if any. Unlike DeleteClusterCache, it leaves the cache of
+// the workload cluster, and the other watches it feeds, running.
+func (t *ClusterCacheTracker) Unwatch(clusterKey types.NamespacedName, name string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	cc, ok := t.clusterCaches[clusterKey]
+	if !ok {
+		return
+	}
+	stop, ok := cc.watches[name]
+	if !ok {
+		return
+	}
+	close(stop)
+	delete(cc.watches, name)
+	t.log.Info("Removed watch on workload cluster", "watch", name, "cluster", clusterKey.Name, "namespace", clusterKey.Namespace)
+}
+
+// watchRunning returns a predicate that passes every event until stop is closed. The informers of a cache cannot
+// drop the handlers of a watch, so a stopped watch filters out the events it still receives instead.
+func watchRunning(stop <-chan struct{}) predicate.Funcs {
+	running := func() bool {
+		select {
+		case <-stop:
+			return false
+		default:
+			return true
+		}
+	}
+	return predicate.Funcs{
+		CreateFunc:  func(event.CreateEvent) bool { return running() },
+		UpdateFunc:  func(event.UpdateEvent) bool { return running() },
+		DeleteFunc:  func(event.DeleteEvent) bool { return running() },
+		GenericFunc: func(event.GenericEvent) bool { return running() },
+	}
+}
+
+// DeleteClusterCache stops the cache of the workload cluster, and with it all the watches on the cluster.
+func (t *ClusterCacheTracker) DeleteClusterCache(clusterKey types.NamespacedName) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.deleteClusterCache(clusterKey)
+}
+
+// deleteClusterCache stops and forgets the entry of the workload cluster. It must be called with the lock held.
+func (t *ClusterCacheTracker) deleteClusterCache(clusterKey types.NamespacedName) {
+	cc, ok := t.clusterCaches[clusterKey]
+	if !ok {
+		return
+	}
+	close(cc.stop)
+	delete(t.clusterCaches, clusterKey)
+	t.log.Info("Stopped tracking workload cluster", "cluster", clusterKey.Name, "namespace", clusterKey.Namespace)
+}
+
+// clusterLock returns the lock serializing the creation of the entry of the workload cluster. Locks are kept for the
+// lifetime of the tracker, so that every caller for a cluster gets the same one.
+func (t *ClusterCacheTracker) clusterLock(cluster *clusterv1.Cluster) *sync.Mutex {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	clusterKey := types.NamespacedName{Namespace: cluster.Namespace, Name: cluster.Name}
+	clusterLock, ok := t.clusterLocks[clusterKey]
+	if !ok {
+		clusterLock = &sync.Mutex{}
+		t.clusterLocks[clusterKey] = clusterLock
+	}
+	return clusterLock
+}
+
+// getOrCreateClusterCache returns the entry of the workload cluster, creating and starting it if needed, or if the
+// kubeconfig of the workload cluster changed since the entry was created. It must be called with the lock of the
+// cluster held, and without the lock of the tracker.
+func (t *ClusterCacheTracker) getOrCreateClusterCache(ctx context.Context, cluster *clusterv1.Cluster) (*clusterCache, error) {
+	clusterKey := types.NamespacedName{Namespace: cluster.Namespace, Name: cluster.Name}
+	kubeconfigSecret, err := secret.Get(ctx, t.client, cluster, secret.Kubeconfig)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to retrieve kubeconfig secret for Cluster %s/%s", cluster.Namespace, cluster.Name)
+	}
+	t.lock.Lock()
+	cc, ok := t.clusterCaches[clusterKey]
+	if ok && cc.kubeconfigVersion != kubeconfigSecret.ResourceVersion {
+		t.log.Info("Kubeconfig of workload cluster changed, recreating its client and cache", "cluster", cluster.Name, "namespace", cluster.Namespace)
+		t.deleteClusterCache(clusterKey)
+		ok = false
+	}
+	t.lock.Unlock()
+	if ok {
+		return cc, nil
+	}
+
+	kubeconfig, ok := kubeconfigSecret.Data[secret.KubeconfigDataName]
+	if !ok {
+		return nil, errors.Errorf("missing key %q in kubeconfig secret for Cluster %s/%s", secret.KubeconfigDataName, cluster.Namespace, cluster.Name)
+	}
+	restConfig, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create REST configuration for Cluster %s/%s", cluster.Namespace, cluster.Name)
+	}
+	mapper, err := apiutil.NewDynamicRESTMapper(restConfig, apiutil.WithLazyDiscovery)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create a DynamicRESTMapper for Cluster %s/%s", cluster.Namespace, cluster.Name)
+	}
+	remoteClient, err := client.New(restConfig, client.Options{Scheme: t.scheme, Mapper: mapper})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create client for Cluster %s/%s", cluster.Namespace, cluster.Name)
+	}
+	remoteCache, err := cache.New(restConfig, cache.Options{Scheme: t.scheme, Mapper: mapper})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create a cache for Cluster %s/%s", cluster.Namespace, cluster.Name)
+	}
+
+	cc = &clusterCache{
+		Cache:             remoteCache,
+		client:            remoteClient,
+		restConfig:        restConfig,
+		kubeconfigVersion: kubeconfigSecret.ResourceVersion,
+		stop:              make(chan struct{}),
+		watches:           map[string]chan struct{}{},
+	}
+	go func() {
+		if err := cc.Start(cc.stop); err != nil {
+			t.log.Error(err, "Workload cluster cache stopped", "cluster", cluster.Name, "namespace", cluster.Namespace)
+		}
+	}()
+	go t.healthCheckCluster(clusterKey, cc)
+
+	t.lock.Lock()
+	t.clusterCaches[clusterKey] = cc
+	t.lock.Unlock()
+	return cc, nil
+}
+
+// healthCheckCluster probes the API server of a workload cluster until its entry is stopped, and tears the entry
+// down after HealthCheckFailureThreshold consecutive failed probes, so that it is recreated on next use.
+func (t *ClusterCacheTracker) healthCheckCluster(clusterKey types.NamespacedName, cc *clusterCache) {
+	healthCheck := t.healthCheck
+	if healthCheck == nil {
+		healthCheck = pingAPIServer
+	}
+
+	failures := 0
+	_ = wait.PollImmediateUntil(t.healthCheckInterval(), func() (bool, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), t.healthCheckTimeout())
+		defer cancel()
+
+		if err := healthCheck(ctx, cc.restConfig); err != nil {
+			failures++
+			t.log.Error(err, "Workload cluster health check failed", "cluster", clusterKey.Name, "namespace", clusterKey.Namespace, "failures", failures)
+			if failures < t.healthCheckFailureThreshold() {
+				return false, nil
+			}
+
+			t.lock.Lock()
+			defer t.lock.Unlock()
+			// The entry may have been replaced already, e.g. because the kubeconfig changed.
+			if t.clusterCaches[clusterKey] == cc {
+				t.log.Info("Workload cluster is unreachable, tearing down its client and cache", "cluster", clusterKey.Name, "namespace", clusterKey.Namespace)
+				t.deleteClusterCache(clusterKey)
+			}
+			return true, nil
+		}
+		failures = 0
+		return false, nil
+	}, cc.stop)
+}
+
+func (t *ClusterCacheTracker) healthCheckInterval() time.Duration {
+	if t.HealthCheckInterval > 0 {
+		return t.HealthCheckInterval
+	}
+	return defaultHealthCheckInterval
+}
+
+func (t *ClusterCacheTracker) healthCheckTimeout() time.Duration {
+	if t.HealthCheckTimeout > 0 {
+		return t.HealthCheckTimeout
+	}
+	return defaultHealthCheckTimeout
+}
+
+func (t *ClusterCacheTracker) healthCheckFailureThreshold() int {
+	if t.HealthCheckFailureThreshold > 0 {
+		return t.HealthCheckFailureThreshold
+	}
+	return defaultHealthCheckFailureThreshold
+}
+
+// pingAPIServer checks that the API server of a workload cluster answers a version request.
+func pingAPIServer(ctx context.Context, restConfig *rest.Config) error {
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(restConfig)
+	if err != nil {
+		return errors.Wrap(err, "failed to create a discovery client")
+	}
+	return discoveryClient.RESTClient().Get().AbsPath("/version").Context(ctx).Do().Error()
+}
@@ -0,0 +1,226 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remote
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestClusterCacheTrackerWatch(t *testing.T) {
+	g := NewWithT(t)
+
+	testScheme := runtime.NewScheme()
+	g.Expect(scheme.AddToScheme(testScheme)).To(Succeed())
+	ctx := context.Background()
+
+	t.Run("cluster with no kubeconfig", func(t *testing.T) {
+		tracker := NewClusterCacheTracker(log.Log, fake.NewFakeClientWithScheme(testScheme), testScheme)
+		err := tracker.Watch(ctx, WatchInput{Name: "watch-nodes", Cluster: clusterWithNoKubeConfig, Kind: &corev1.Node{}})
+		g.Expect(err).To(MatchError(ContainSubstring("not found")))
+		g.Expect(tracker.clusterCaches).To(BeEmpty())
+	})
+
+	t.Run("watch already added", func(t *testing.T) {
+		c := fake.NewFakeClientWithScheme(testScheme, validSecret.DeepCopy())
+		tracker := NewClusterCacheTracker(log.Log, c, testScheme)
+		clusterKey := types.NamespacedName{Namespace: clusterWithValidKubeConfig.Namespace, Name: clusterWithValidKubeConfig.Name}
+		tracker.clusterCaches[clusterKey] = &clusterCache{
+			kubeconfigVersion: kubeconfigVersion(g, c),
+			stop:              make(chan struct{}),
+			watches:           map[string]chan struct{}{"watch-nodes": make(chan struct{})},
+		}
+
+		// The existing watch is kept without adding it to the controller again.
+		g.Expect(tracker.Watch(ctx, WatchInput{Name: "watch-nodes", Cluster: clusterWithValidKubeConfig, Kind: &corev1.Node{}})).To(Succeed())
+		g.Expect(tracker.Watching(clusterKey, "watch-nodes")).To(BeTrue())
+		g.Expect(tracker.Watching(clusterKey, "watch-pods")).To(BeFalse())
+	})
+}
+
+func TestClusterCacheTrackerGetClient(t *testing.T) {
+	g := NewWithT(t)
+
+	testScheme := runtime.NewScheme()
+	g.Expect(scheme.AddToScheme(testScheme)).To(Succeed())
+	ctx := context.Background()
+	clusterKey := types.NamespacedName{Namespace: clusterWithValidKubeConfig.Namespace, Name: clusterWithValidKubeConfig.Name}
+
+	t.Run("client is shared until the kubeconfig changes", func(t *testing.T) {
+		c := fake.NewFakeClientWithScheme(testScheme, validSecret.DeepCopy())
+		tracker := NewClusterCacheTracker(log.Log, c, testScheme)
+		tracker.healthCheck = func(context.Context, *rest.Config) error { return nil }
+		defer tracker.DeleteClusterCache(clusterKey)
+
+		first, err := tracker.GetClient(ctx, clusterWithValidKubeConfig)
+		g.Expect(err).NotTo(HaveOccurred())
+		second, err := tracker.GetClient(ctx, clusterWithValidKubeConfig)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(second).To(BeIdenticalTo(first))
+		stop := tracker.clusterCaches[clusterKey].stop
+
+		// Rotating the kubeconfig recreates the client, and stops the watches fed by the previous cache.
+		kubeconfigSecret := &corev1.Secret{}
+		g.Expect(c.Get(ctx, types.NamespacedName{Namespace: validSecret.Namespace, Name: validSecret.Name}, kubeconfigSecret)).To(Succeed())
+		kubeconfigSecret.Labels = map[string]string{"rotated": "true"}
+		g.Expect(c.Update(ctx, kubeconfigSecret)).To(Succeed())
+
+		third, err := tracker.GetClient(ctx, clusterWithValidKubeConfig)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(third).NotTo(BeIdenticalTo(first))
+		g.Expect(stop).To(BeClosed())
+	})
+
+	t.Run("cluster with invalid kubeconfig", func(t *testing.T) {
+		tracker := NewClusterCacheTracker(log.Log, fake.NewFakeClientWithScheme(testScheme, invalidSecret), testScheme)
+		_, err := tracker.GetClient(ctx, clusterWithInvalidKubeConfig)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(tracker.clusterCaches).To(BeEmpty())
+	})
+
+	t.Run("unreachable cluster is torn down", func(t *testing.T) {
+		tracker := NewClusterCacheTracker(log.Log, fake.NewFakeClientWithScheme(testScheme, validSecret.DeepCopy()), testScheme)
+		tracker.HealthCheckInterval = 10 * time.Millisecond
+		tracker.HealthCheckFailureThreshold = 2
+		tracker.healthCheck = func(context.Context, *rest.Config) error { return errors.New("connection refused") }
+
+		_, err := tracker.GetClient(ctx, clusterWithValidKubeConfig)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Eventually(func() int {
+			tracker.lock.Lock()
+			defer tracker.lock.Unlock()
+			return len(tracker.clusterCaches)
+		}, 5*time.Second).Should(BeZero())
+	})
+
+	t.Run("slow cluster does not hold up the others", func(t *testing.T) {
+		blocked := make(chan struct{})
+		release := make(chan struct{})
+		c := &blockingClient{
+			Client:  fake.NewFakeClientWithScheme(testScheme, validSecret.DeepCopy()),
+			name:    clusterWithNoKubeConfig.Name + "-kubeconfig",
+			blocked: blocked,
+			release: release,
+		}
+		tracker := NewClusterCacheTracker(log.Log, c, testScheme)
+		tracker.healthCheck = func(context.Context, *rest.Config) error { return nil }
+		defer tracker.DeleteClusterCache(clusterKey)
+
+		slowErr := make(chan error, 1)
+		go func() {
+			_, err := tracker.GetClient(ctx, clusterWithNoKubeConfig)
+			slowErr <- err
+		}()
+		<-blocked
+
+		_, err := tracker.GetClient(ctx, clusterWithValidKubeConfig)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(tracker.Watching(clusterKey, "watch-nodes")).To(BeFalse())
+
+		close(release)
+		g.Expect(<-slowErr).To(MatchError(ContainSubstring("not found")))
+	})
+}
+
+// blockingClient is a client whose reads of the named object block until release is closed.
+type blockingClient struct {
+	client.Client
+	name    string
+	blocked chan struct{}
+	release chan struct{}
+}
+
+func (c *blockingClient) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	if key.Name == c.name {
+		close(c.blocked)
+		<-c.release
+	}
+	return c.Client.Get(ctx, key, obj)
+}
+
+// kubeconfigVersion returns the ResourceVersion of the kubeconfig secret of the cluster with a valid kubeconfig.
+func kubeconfigVersion(g *WithT, c client.Client) string {
+	kubeconfigSecret := &corev1.Secret{}
+	g.Expect(c.Get(context.Background(), types.NamespacedName{Namespace: validSecret.Namespace, Name: validSecret.Name}, kubeconfigSecret)).To(Succeed())
+	return kubeconfigSecret.ResourceVersion
+}
+
+func TestClusterCacheTrackerDeleteClusterCache(t *testing.T) {
+	g := NewWithT(t)
+
+	tracker := NewClusterCacheTracker(log.Log, fake.NewFakeClientWithScheme(scheme.Scheme), scheme.Scheme)
+	clusterKey := types.NamespacedName{Namespace: "default", Name: "test-cluster"}
+	otherClusterKey := types.NamespacedName{Namespace: "default", Name: "other-cluster"}
+	stop := make(chan struct{})
+	otherStop := make(chan struct{})
+	tracker.clusterCaches[clusterKey] = &clusterCache{stop: stop, watches: map[string]chan struct{}{"watch-nodes": make(chan struct{})}}
+	tracker.clusterCaches[otherClusterKey] = &clusterCache{stop: otherStop, watches: map[string]chan struct{}{"watch-nodes": make(chan struct{})}}
+
+	// Deleting stops the cache of the cluster only.
+	tracker.DeleteClusterCache(clusterKey)
+	g.Expect(stop).To(BeClosed())
+	g.Expect(otherStop).NotTo(BeClosed())
+	g.Expect(tracker.Watching(clusterKey, "watch-nodes")).To(BeFalse())
+	g.Expect(tracker.Watching(otherClusterKey, "watch-nodes")).To(BeTrue())
+
+	// Deleting a cluster that is not watched is a no-op.
+	tracker.DeleteClusterCache(clusterKey)
+	g.Expect(tracker.clusterCaches).To(HaveLen(1))
+}
+
+func TestClusterCacheTrackerUnwatch(t *testing.T) {
+	g := NewWithT(t)
+
+	tracker := NewClusterCacheTracker(log.Log, fake.NewFakeClientWithScheme(scheme.Scheme), scheme.Scheme)
+	clusterKey := types.NamespacedName{Namespace: "default", Name: "test-cluster"}
+	stop := make(chan struct{})
+	watchNodesStop := make(chan struct{})
+	watchPodsStop := make(chan struct{})
+	tracker.clusterCaches[clusterKey] = &clusterCache{
+		stop:    stop,
+		watches: map[string]chan struct{}{"watch-nodes": watchNodesStop, "watch-pods": watchPodsStop},
+	}
+	watchNodes := watchRunning(watchNodesStop)
+	node := &corev1.Node{}
+	g.Expect(watchNodes.Create(event.CreateEvent{Meta: node, Object: node})).To(BeTrue())
+
+	// Unwatching stops the watch only, and leaves the cache of the cluster and its other watches running.
+	tracker.Unwatch(clusterKey, "watch-nodes")
+	g.Expect(tracker.Watching(clusterKey, "watch-nodes")).To(BeFalse())
+	g.Expect(watchNodes.Create(event.CreateEvent{Meta: node, Object: node})).To(BeFalse())
+	g.Expect(tracker.Watching(clusterKey, "watch-pods")).To(BeTrue())
+	g.Expect(watchPodsStop).NotTo(BeClosed())
+	g.Expect(stop).NotTo(BeClosed())
+
+	// Unwatching a watch or a cluster that is not watched is a no-op.
+	tracker.Unwatch(clusterKey, "watch-nodes")
+	tracker.Unwatch(types.NamespacedName{Namespace: "default", Name: "other-cluster"}, "watch-nodes")
+	g.Expect(tracker.clusterCaches).To(HaveLen(1))
+}
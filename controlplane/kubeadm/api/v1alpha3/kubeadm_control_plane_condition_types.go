@@ -0,0 +1,80 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+)
+
+const (
+	// MachinesReadyCondition reports whether every control plane Machine has a Node with a provider ID.
+	MachinesReadyCondition clusterv1.ConditionType = "MachinesReady"
+
+	// ResizedCondition reports whether the number of control plane Machines matches the desired number of replicas.
+	ResizedCondition clusterv1.ConditionType = "Resized"
+
+	// EtcdClusterHealthyCondition reports the outcome of the last etcd health check of the target cluster.
+	EtcdClusterHealthyCondition clusterv1.ConditionType = "EtcdClusterHealthy"
+
+	// ControlPlaneComponentsHealthyCondition reports the outcome of the last health check of the control plane
+	// static pods of the target cluster.
+	ControlPlaneComponentsHealthyCondition clusterv1.ConditionType = "ControlPlaneComponentsHealthy"
+
+	// CertificatesAvailableCondition reports whether the cluster certificates have been looked up or generated.
+	CertificatesAvailableCondition clusterv1.ConditionType = "CertificatesAvailable"
+
+	// ExternalCACondition reports whether some cluster CAs are provided without their private key, in which case
+	// the certificates they sign, e.g. the kubeconfig of the cluster, have to be provided by the user as well.
+	ExternalCACondition clusterv1.ConditionType = "ExternalCA"
+
+	// MachineCertificatesValidCondition reports whether the certificates of every control plane Machine are valid
+	// for longer than the RolloutBefore.CertificatesExpiryDays of the KubeadmControlPlane, or 30 days if it is not set.
+	MachineCertificatesValidCondition clusterv1.ConditionType = "MachineCertificatesValid"
+
+	// LifecycleHooksCompletedCondition reports whether scaling or upgrading the control plane waits for lifecycle
+	// hooks to complete.
+	LifecycleHooksCompletedCondition clusterv1.ConditionType = "LifecycleHooksCompleted"
+)
+
+const (
+	// MachinesNotReadyReason is used when some control plane Machines do not have a ready Node yet.
+	MachinesNotReadyReason = "MachinesNotReady"
+
+	// ScalingUpReason is used when there are fewer control plane Machines than desired replicas.
+	ScalingUpReason = "ScalingUp"
+
+	// ScalingDownReason is used when there are more control plane Machines than desired replicas.
+	ScalingDownReason = "ScalingDown"
+
+	// EtcdClusterUnhealthyReason is used when the etcd health check of the target cluster fails.
+	EtcdClusterUnhealthyReason = "EtcdClusterUnhealthy"
+
+	// ControlPlaneComponentsUnhealthyReason is used when the control plane health check of the target cluster fails.
+	ControlPlaneComponentsUnhealthyReason = "ControlPlaneComponentsUnhealthy"
+
+	// CertificatesGenerationFailedReason is used when the cluster certificates cannot be looked up or generated.
+	CertificatesGenerationFailedReason = "CertificatesGenerationFailed"
+
+	// CAPrivateKeysAvailableReason is used when the private key of every cluster CA is available to sign certificates.
+	CAPrivateKeysAvailableReason = "CAPrivateKeysAvailable"
+
+	// MachineCertificatesExpiringReason is used when the certificates of some control plane Machines expire soon.
+	MachineCertificatesExpiringReason = "MachineCertificatesExpiring"
+
+	// WaitingForLifecycleHooksReason is used when a control plane Machine still has lifecycle hook annotations.
+	WaitingForLifecycleHooksReason = "WaitingForLifecycleHooks"
+)
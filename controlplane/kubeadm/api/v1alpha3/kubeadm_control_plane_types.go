@@ -19,7 +19,9 @@ package v1alpha3
 import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	cabpkv1 "sigs.k8s.io/cluster-api/bootstrap/kubeadm/api/v1alpha3"
 	"sigs.k8s.io/cluster-api/errors"
 )
@@ -27,6 +29,44 @@ import (
 const (
 	KubeadmControlPlaneFinalizer    = "kubeadm.controlplane.cluster.x-k8s.io"
 	KubeadmControlPlaneHashLabelKey = "kubeadm.controlplane.cluster.x-k8s.io/hash"
+
+	// KubeadmClusterConfigurationAnnotation is set on control plane Machines to the JSON encoded ClusterConfiguration
+	// they were created with, since the bootstrap provider fills in the ClusterConfiguration of their KubeadmConfig.
+	KubeadmClusterConfigurationAnnotation = "controlplane.cluster.x-k8s.io/kubeadm-cluster-configuration"
+
+	// SkipKubeProxyAnnotation can be set on a KubeadmControlPlane to stop it from upgrading the kube-proxy
+	// DaemonSet of the workload cluster, e.g. when kube-proxy is managed by the user.
+	SkipKubeProxyAnnotation = "controlplane.cluster.x-k8s.io/skip-kube-proxy"
+
+	// RecoverEtcdNoSpaceAnnotation can be set on a KubeadmControlPlane to make it defragment the stacked etcd members
+	// that raised a NOSPACE alarm and disarm the alarm when the etcd health check fails, instead of waiting for the
+	// user to recover etcd.
+	RecoverEtcdNoSpaceAnnotation = "controlplane.cluster.x-k8s.io/recover-etcd-nospace"
+
+	// DefragmentEtcdAnnotation can be set on a KubeadmControlPlane to request the defragmentation of its stacked etcd
+	// members. It is removed once every member has been defragmented.
+	DefragmentEtcdAnnotation = "controlplane.cluster.x-k8s.io/defragment-etcd"
+
+	// EtcdSnapshotLabelName is the label set on the etcd snapshots stored in Secrets to the name of the
+	// KubeadmControlPlane they were taken from.
+	EtcdSnapshotLabelName = "controlplane.cluster.x-k8s.io/etcd-snapshot"
+
+	// CertificatesExpiryAnnotation is the time, in RFC3339 format, the certificates kubeadm generated on a control
+	// plane node expire. It can be set on the Node at bootstrap, e.g. by a postKubeadmCommand; otherwise the expiry
+	// of the API server serving certificate is used. It is copied onto the control plane Machine, from where it can
+	// be removed to look up the expiry again after the certificates were renewed.
+	CertificatesExpiryAnnotation = "controlplane.cluster.x-k8s.io/certificates-expiry"
+
+	// PreDeleteHookAnnotationPrefix is the prefix of the annotations, one per LifecycleHooks.PreDelete hook, set on
+	// control plane Machines when they are created, e.g. "pre-delete.hook.controlplane.cluster.x-k8s.io/etcd-backup".
+	// Once a Machine is picked for deletion it is marked with the DeleteMachineAnnotation, and it is only deleted
+	// after the controllers running the hooks have removed their annotations.
+	PreDeleteHookAnnotationPrefix = "pre-delete.hook.controlplane.cluster.x-k8s.io"
+
+	// PostUpgradeHookAnnotationPrefix is the prefix of the annotations, one per LifecycleHooks.PostUpgrade hook, set
+	// on the control plane Machines created to replace outdated ones. The upgrade does not move on to the next
+	// Machine until the controllers running the hooks have removed their annotations.
+	PostUpgradeHookAnnotationPrefix = "post-upgrade.hook.controlplane.cluster.x-k8s.io"
 )
 
 // KubeadmControlPlaneSpec defines the desired state of KubeadmControlPlane.
@@ -54,6 +94,146 @@ type KubeadmControlPlaneSpec struct {
 	// KubeadmControlPlane
 	// +optional
 	UpgradeAfter *metav1.Time `json:"upgradeAfter,omitempty"`
+
+	// RolloutAfter is a time after which every control plane Machine created before it is replaced, following the
+	// RolloutStrategy, e.g. to renew certificates or pick up a new machine image without changing the configuration.
+	// +optional
+	RolloutAfter *metav1.Time `json:"rolloutAfter,omitempty"`
+
+	// LifecycleHooks pause scaling and upgrades of the control plane at given points until external controllers,
+	// e.g. taking etcd backups or running validation suites, are done.
+	// +optional
+	LifecycleHooks *LifecycleHooks `json:"lifecycleHooks,omitempty"`
+
+	// NodeDrainTimeout is the total amount of time that the controller will spend on draining a control plane node,
+	// measured from when its Machine is deleted. It is set on the control plane Machines when they are created, and
+	// on the Machines the control plane deletes, so that scaling down and upgrades are not blocked by pods that
+	// cannot be evicted. The node is drained without a time limit if it is not set or zero.
+	// +optional
+	NodeDrainTimeout *metav1.Duration `json:"nodeDrainTimeout,omitempty"`
+
+	// RolloutStrategy is the strategy used to replace the existing control plane Machines
+	// with new ones when the control plane is upgraded.
+	// Defaults to a RollingUpdate with a MaxSurge of 1.
+	// +optional
+	RolloutStrategy *RolloutStrategy `json:"rolloutStrategy,omitempty"`
+
+	// DeletePolicy is how the control plane Machine to delete is picked when scaling down, or when replacing
+	// Machines during a rollout. Machines with the "cluster.x-k8s.io/delete-machine" annotation are always picked
+	// first. Defaults to FailureDomainBalance.
+	// +optional
+	DeletePolicy DeletePolicy `json:"deletePolicy,omitempty"`
+
+	// RolloutBefore makes the control plane Machines be replaced, following the RolloutStrategy, ahead of
+	// events such as the expiry of their certificates.
+	// +optional
+	RolloutBefore *RolloutBefore `json:"rolloutBefore,omitempty"`
+
+	// EtcdBackup configures periodic snapshots of the stacked etcd cluster of the control plane.
+	// Snapshots are not taken if it is not set, or if the control plane uses external etcd.
+	// +optional
+	EtcdBackup *EtcdBackup `json:"etcdBackup,omitempty"`
+}
+
+// EtcdBackupSinkType is the type of storage etcd snapshots are stored in.
+type EtcdBackupSinkType string
+
+const (
+	// SecretEtcdBackupSinkType stores every etcd snapshot, compressed, in a Secret in the namespace of the
+	// KubeadmControlPlane, owned by it. Secrets are limited to 1MiB, so it only suits small clusters.
+	SecretEtcdBackupSinkType EtcdBackupSinkType = "Secret"
+)
+
+// EtcdBackup describes how often etcd snapshots are taken, where they are stored and how many of them are kept.
+type EtcdBackup struct {
+	// Interval is how long to wait between two snapshots, e.g. 6h.
+	Interval metav1.Duration `json:"interval"`
+
+	// Retention is the number of snapshots to keep. Older snapshots are deleted.
+	// Defaults to 3.
+	// +optional
+	Retention *int32 `json:"retention,omitempty"`
+
+	// SinkType is the type of storage the snapshots are stored in. Other types than "Secret" must be
+	// registered with the controller.
+	// Defaults to Secret.
+	// +optional
+	SinkType EtcdBackupSinkType `json:"sinkType,omitempty"`
+}
+
+// LifecycleHooks names the hooks to run at each point of the lifecycle of the control plane Machines. Hooks are
+// run by adding an annotation named after them to the Machine, which the controller running the hook removes once
+// it is done. Hooks only apply to the Machines created after they were configured.
+type LifecycleHooks struct {
+	// PreDelete hooks run before a control plane Machine is deleted, when scaling down or replacing Machines.
+	// See PreDeleteHookAnnotationPrefix.
+	// +optional
+	PreDelete []string `json:"preDelete,omitempty"`
+
+	// PostUpgrade hooks run on each Machine created to replace an outdated one, before the next outdated Machine is
+	// replaced. See PostUpgradeHookAnnotationPrefix.
+	// +optional
+	PostUpgrade []string `json:"postUpgrade,omitempty"`
+}
+
+// DeletePolicy defines how the control plane Machine to delete is picked when scaling down.
+type DeletePolicy string
+
+const (
+	// FailureDomainBalanceDeletePolicy deletes the oldest Machine of the failure domain with the most Machines, so
+	// that the remaining Machines stay spread across failure domains.
+	FailureDomainBalanceDeletePolicy DeletePolicy = "FailureDomainBalance"
+
+	// OldestDeletePolicy deletes the oldest Machine.
+	OldestDeletePolicy DeletePolicy = "Oldest"
+
+	// NewestDeletePolicy deletes the newest Machine.
+	NewestDeletePolicy DeletePolicy = "Newest"
+
+	// UnhealthyFirstDeletePolicy deletes the oldest Machine that failed or has no Node yet, falling back to the
+	// oldest Machine.
+	UnhealthyFirstDeletePolicy DeletePolicy = "UnhealthyFirst"
+)
+
+// RolloutBefore describes when to replace control plane Machines ahead of time.
+type RolloutBefore struct {
+	// CertificatesExpiryDays is the number of days before the certificates of a control plane Machine expire
+	// that the Machine is replaced. Machines are not replaced because of their certificates if it is not set.
+	// The minimum is 7 days.
+	// +optional
+	CertificatesExpiryDays *int32 `json:"certificatesExpiryDays,omitempty"`
+}
+
+// RolloutStrategyType defines the rollout strategies for a KubeadmControlPlane.
+type RolloutStrategyType string
+
+const (
+	// RollingUpdateStrategyType replaces the old control plane Machines one at a time,
+	// creating or deleting at most MaxSurge Machines beyond the desired number of replicas.
+	RollingUpdateStrategyType RolloutStrategyType = "RollingUpdate"
+)
+
+// RolloutStrategy describes how to replace existing control plane Machines with new ones.
+type RolloutStrategy struct {
+	// Type of rollout. Currently the only supported strategy is "RollingUpdate".
+	// Default is RollingUpdate.
+	// +optional
+	Type RolloutStrategyType `json:"type,omitempty"`
+
+	// Rolling update config params. Present only if RolloutStrategyType = RollingUpdate.
+	// +optional
+	RollingUpdate *RollingUpdate `json:"rollingUpdate,omitempty"`
+}
+
+// RollingUpdate is used to control the desired behavior of a rolling update.
+type RollingUpdate struct {
+	// The maximum number of control plane Machines that can be created above the desired number of replicas
+	// while the old Machines are replaced. The value must be an absolute number, either 0 or 1.
+	// With 1, a new Machine is created before an old one is deleted. With 0, an old Machine is deleted
+	// before its replacement is created, which requires at least 3 replicas to keep etcd quorum.
+	// Defaults to 1.
+	// +optional
+	MaxSurge *intstr.IntOrString `json:"maxSurge,omitempty"`
 }
 
 // KubeadmControlPlaneStatus defines the observed state of KubeadmControlPlane.
@@ -80,6 +260,12 @@ type KubeadmControlPlaneStatus struct {
 	// +optional
 	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
 
+	// FailureDomainReplicas is the number of non-terminated control plane machines in each
+	// failure domain of the Cluster that is suitable for control plane machines.
+	// It is not set if the Cluster does not report such failure domains.
+	// +optional
+	FailureDomainReplicas map[string]int32 `json:"failureDomainReplicas,omitempty"`
+
 	// Total number of unavailable machines targeted by this control plane.
 	// This is the total number of machines that are still required for
 	// the deployment to have 100% available capacity. They may either
@@ -108,6 +294,19 @@ type KubeadmControlPlaneStatus struct {
 	// state, and will be set to a descriptive error message.
 	// +optional
 	FailureMessage *string `json:"failureMessage,omitempty"`
+
+	// Conditions reports the observed state of the control plane, e.g. whether its Machines are ready
+	// and whether the last health checks of the target cluster passed.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+
+	// LastEtcdDefragmentationTime is when every stacked etcd member of the control plane was last defragmented.
+	// +optional
+	LastEtcdDefragmentationTime *metav1.Time `json:"lastEtcdDefragmentationTime,omitempty"`
+
+	// LastEtcdSnapshotTime is when the last etcd snapshot of the control plane was stored.
+	// +optional
+	LastEtcdSnapshotTime *metav1.Time `json:"lastEtcdSnapshotTime,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -131,6 +330,16 @@ type KubeadmControlPlane struct {
 	Status KubeadmControlPlaneStatus `json:"status,omitempty"`
 }
 
+// GetConditions returns the conditions of the KubeadmControlPlane.
+func (r *KubeadmControlPlane) GetConditions() clusterv1.Conditions {
+	return r.Status.Conditions
+}
+
+// SetConditions sets the conditions of the KubeadmControlPlane.
+func (r *KubeadmControlPlane) SetConditions(conditions clusterv1.Conditions) {
+	r.Status.Conditions = conditions
+}
+
 // +kubebuilder:object:root=true
 
 // KubeadmControlPlaneList contains a list of KubeadmControlPlane.
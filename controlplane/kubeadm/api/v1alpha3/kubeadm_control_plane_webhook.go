@@ -17,10 +17,14 @@ limitations under the License.
 package v1alpha3
 
 import (
+	"fmt"
 	"reflect"
+	"strings"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
@@ -48,6 +52,36 @@ func (r *KubeadmControlPlane) Default() {
 	if r.Spec.InfrastructureTemplate.Namespace == "" {
 		r.Spec.InfrastructureTemplate.Namespace = r.Namespace
 	}
+
+	if r.Spec.RolloutStrategy == nil {
+		r.Spec.RolloutStrategy = &RolloutStrategy{}
+	}
+	if r.Spec.RolloutStrategy.Type == "" {
+		r.Spec.RolloutStrategy.Type = RollingUpdateStrategyType
+	}
+	if r.Spec.RolloutStrategy.Type == RollingUpdateStrategyType {
+		if r.Spec.RolloutStrategy.RollingUpdate == nil {
+			r.Spec.RolloutStrategy.RollingUpdate = &RollingUpdate{}
+		}
+		if r.Spec.RolloutStrategy.RollingUpdate.MaxSurge == nil {
+			maxSurge := intstr.FromInt(1)
+			r.Spec.RolloutStrategy.RollingUpdate.MaxSurge = &maxSurge
+		}
+	}
+
+	if r.Spec.DeletePolicy == "" {
+		r.Spec.DeletePolicy = FailureDomainBalanceDeletePolicy
+	}
+
+	if r.Spec.EtcdBackup != nil {
+		if r.Spec.EtcdBackup.Retention == nil {
+			retention := int32(3)
+			r.Spec.EtcdBackup.Retention = &retention
+		}
+		if r.Spec.EtcdBackup.SinkType == "" {
+			r.Spec.EtcdBackup.SinkType = SecretEtcdBackupSinkType
+		}
+	}
 }
 
 // ValidateCreate implements webhook.Validator so a webhook will be registered for the type
@@ -62,37 +96,14 @@ func (r *KubeadmControlPlane) ValidateCreate() error {
 				"is required",
 			),
 		)
-	} else if *r.Spec.Replicas <= 0 {
-		// The use of the scale subresource should provide a guarantee that negative values
-		// should not be accepted for this field, but since we have to validate that Replicas != 0
-		// it doesn't hurt to also additionally validate for negative numbers here as well.
-		allErrs = append(
-			allErrs,
-			field.Forbidden(
-				field.NewPath("spec", "replicas"),
-				"cannot be less than or equal to 0",
-			),
-		)
-	}
-
-	externalEtcd := false
-	if r.Spec.KubeadmConfigSpec.InitConfiguration != nil {
-		if r.Spec.KubeadmConfigSpec.InitConfiguration.Etcd.External != nil {
-			externalEtcd = true
-		}
-	}
-
-	if !externalEtcd {
-		if r.Spec.Replicas != nil && *r.Spec.Replicas%2 == 0 {
-			allErrs = append(
-				allErrs,
-				field.Forbidden(
-					field.NewPath("spec", "replicas"),
-					"cannot be an even number when using managed etcd",
-				),
-			)
-		}
 	}
+	allErrs = append(allErrs, r.validateReplicas()...)
+	allErrs = append(allErrs, r.validateRolloutStrategy()...)
+	allErrs = append(allErrs, r.validateRolloutBefore()...)
+	allErrs = append(allErrs, r.validateDeletePolicy()...)
+	allErrs = append(allErrs, r.validateLifecycleHooks()...)
+	allErrs = append(allErrs, r.validateNodeDrainTimeout()...)
+	allErrs = append(allErrs, r.validateEtcdBackup()...)
 
 	if r.Spec.InfrastructureTemplate.Namespace != r.Namespace {
 		allErrs = append(
@@ -127,6 +138,14 @@ func (r *KubeadmControlPlane) ValidateUpdate(old runtime.Object) error {
 		)
 	}
 
+	allErrs = append(allErrs, r.validateReplicas()...)
+	allErrs = append(allErrs, r.validateRolloutStrategy()...)
+	allErrs = append(allErrs, r.validateRolloutBefore()...)
+	allErrs = append(allErrs, r.validateDeletePolicy()...)
+	allErrs = append(allErrs, r.validateLifecycleHooks()...)
+	allErrs = append(allErrs, r.validateNodeDrainTimeout()...)
+	allErrs = append(allErrs, r.validateEtcdBackup()...)
+
 	if len(allErrs) == 0 {
 		return nil
 	}
@@ -134,6 +153,216 @@ func (r *KubeadmControlPlane) ValidateUpdate(old runtime.Object) error {
 	return apierrors.NewInvalid(GroupVersion.WithKind("KubeadmControlPlane").GroupKind(), r.Name, allErrs)
 }
 
+// validateReplicas checks that the number of replicas, if set, is positive, and odd unless the control plane uses
+// an external etcd cluster, as stacked etcd members are added and removed along with control plane Machines.
+func (r *KubeadmControlPlane) validateReplicas() field.ErrorList {
+	var allErrs field.ErrorList
+
+	if r.Spec.Replicas == nil {
+		return nil
+	}
+	if *r.Spec.Replicas <= 0 {
+		// The use of the scale subresource should provide a guarantee that negative values
+		// should not be accepted for this field, but since we have to validate that Replicas != 0
+		// it doesn't hurt to also additionally validate for negative numbers here as well.
+		allErrs = append(
+			allErrs,
+			field.Forbidden(
+				field.NewPath("spec", "replicas"),
+				"cannot be less than or equal to 0",
+			),
+		)
+	}
+	if !r.usesExternalEtcd() && *r.Spec.Replicas%2 == 0 {
+		allErrs = append(
+			allErrs,
+			field.Forbidden(
+				field.NewPath("spec", "replicas"),
+				"cannot be an even number when using managed etcd",
+			),
+		)
+	}
+
+	return allErrs
+}
+
+// usesExternalEtcd returns true if the control plane is configured to use an external etcd cluster.
+func (r *KubeadmControlPlane) usesExternalEtcd() bool {
+	spec := r.Spec.KubeadmConfigSpec
+	if spec.ClusterConfiguration != nil && spec.ClusterConfiguration.Etcd.External != nil {
+		return true
+	}
+	return spec.InitConfiguration != nil && spec.InitConfiguration.Etcd.External != nil
+}
+
+// validateRolloutStrategy checks that the rollout strategy is a RollingUpdate with a MaxSurge of 0 or 1, and that
+// there are enough replicas to delete a Machine before creating its replacement if MaxSurge is 0.
+func (r *KubeadmControlPlane) validateRolloutStrategy() field.ErrorList {
+	var allErrs field.ErrorList
+
+	strategy := r.Spec.RolloutStrategy
+	if strategy == nil {
+		return nil
+	}
+	if strategy.Type != "" && strategy.Type != RollingUpdateStrategyType {
+		allErrs = append(
+			allErrs,
+			field.NotSupported(
+				field.NewPath("spec", "rolloutStrategy", "type"),
+				strategy.Type,
+				[]string{string(RollingUpdateStrategyType)},
+			),
+		)
+	}
+	if strategy.RollingUpdate == nil || strategy.RollingUpdate.MaxSurge == nil {
+		return allErrs
+	}
+
+	maxSurge := strategy.RollingUpdate.MaxSurge
+	maxSurgePath := field.NewPath("spec", "rolloutStrategy", "rollingUpdate", "maxSurge")
+	switch {
+	case maxSurge.Type != intstr.Int || (maxSurge.IntVal != 0 && maxSurge.IntVal != 1):
+		allErrs = append(
+			allErrs,
+			field.Invalid(
+				maxSurgePath,
+				maxSurge.String(),
+				"must be either 0 or 1",
+			),
+		)
+	case maxSurge.IntVal == 0 && r.Spec.Replicas != nil && *r.Spec.Replicas < 3:
+		allErrs = append(
+			allErrs,
+			field.Forbidden(
+				maxSurgePath,
+				"cannot be 0 with less than 3 replicas",
+			),
+		)
+	}
+
+	return allErrs
+}
+
+// validateDeletePolicy checks that the delete policy, if set, is supported.
+func (r *KubeadmControlPlane) validateDeletePolicy() field.ErrorList {
+	var allErrs field.ErrorList
+
+	switch r.Spec.DeletePolicy {
+	case "", FailureDomainBalanceDeletePolicy, OldestDeletePolicy, NewestDeletePolicy, UnhealthyFirstDeletePolicy:
+	default:
+		allErrs = append(
+			allErrs,
+			field.NotSupported(
+				field.NewPath("spec", "deletePolicy"),
+				r.Spec.DeletePolicy,
+				[]string{string(FailureDomainBalanceDeletePolicy), string(OldestDeletePolicy), string(NewestDeletePolicy), string(UnhealthyFirstDeletePolicy)},
+			),
+		)
+	}
+
+	return allErrs
+}
+
+// validateNodeDrainTimeout checks that the node drain timeout, if set, is not negative.
+func (r *KubeadmControlPlane) validateNodeDrainTimeout() field.ErrorList {
+	var allErrs field.ErrorList
+
+	if r.Spec.NodeDrainTimeout != nil && r.Spec.NodeDrainTimeout.Duration < 0 {
+		allErrs = append(
+			allErrs,
+			field.Invalid(
+				field.NewPath("spec", "nodeDrainTimeout"),
+				r.Spec.NodeDrainTimeout.Duration.String(),
+				"cannot be negative",
+			),
+		)
+	}
+
+	return allErrs
+}
+
+// validateRolloutBefore checks that control plane Machines are not replaced too close to the expiry of their
+// certificates to complete the rollout, nor so far ahead that they are replaced continuously.
+func (r *KubeadmControlPlane) validateRolloutBefore() field.ErrorList {
+	var allErrs field.ErrorList
+
+	if r.Spec.RolloutBefore == nil || r.Spec.RolloutBefore.CertificatesExpiryDays == nil {
+		return nil
+	}
+	if days := *r.Spec.RolloutBefore.CertificatesExpiryDays; days < 7 {
+		allErrs = append(
+			allErrs,
+			field.Invalid(
+				field.NewPath("spec", "rolloutBefore", "certificatesExpiryDays"),
+				days,
+				"must be at least 7",
+			),
+		)
+	}
+
+	return allErrs
+}
+
+// validateLifecycleHooks checks that the lifecycle hook names make valid annotation names.
+func (r *KubeadmControlPlane) validateLifecycleHooks() field.ErrorList {
+	var allErrs field.ErrorList
+
+	hooks := r.Spec.LifecycleHooks
+	if hooks == nil {
+		return nil
+	}
+	validate := func(path *field.Path, prefix string, names []string) {
+		for i, name := range names {
+			if errs := validation.IsQualifiedName(fmt.Sprintf("%s/%s", prefix, name)); len(errs) > 0 {
+				allErrs = append(
+					allErrs,
+					field.Invalid(
+						path.Index(i),
+						name,
+						strings.Join(errs, "; "),
+					),
+				)
+			}
+		}
+	}
+	validate(field.NewPath("spec", "lifecycleHooks", "preDelete"), PreDeleteHookAnnotationPrefix, hooks.PreDelete)
+	validate(field.NewPath("spec", "lifecycleHooks", "postUpgrade"), PostUpgradeHookAnnotationPrefix, hooks.PostUpgrade)
+
+	return allErrs
+}
+
+// validateEtcdBackup checks that etcd snapshots are taken at a positive interval and that at least one is kept.
+func (r *KubeadmControlPlane) validateEtcdBackup() field.ErrorList {
+	var allErrs field.ErrorList
+
+	backup := r.Spec.EtcdBackup
+	if backup == nil {
+		return nil
+	}
+	if backup.Interval.Duration <= 0 {
+		allErrs = append(
+			allErrs,
+			field.Invalid(
+				field.NewPath("spec", "etcdBackup", "interval"),
+				backup.Interval.Duration.String(),
+				"must be greater than 0",
+			),
+		)
+	}
+	if backup.Retention != nil && *backup.Retention < 1 {
+		allErrs = append(
+			allErrs,
+			field.Invalid(
+				field.NewPath("spec", "etcdBackup", "retention"),
+				*backup.Retention,
+				"must be at least 1",
+			),
+		)
+	}
+
+	return allErrs
+}
+
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type
 func (r *KubeadmControlPlane) ValidateDelete() error {
 	return nil
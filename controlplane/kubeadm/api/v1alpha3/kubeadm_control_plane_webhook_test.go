@@ -18,10 +18,12 @@ package v1alpha3
 
 import (
 	"testing"
+	"time"
 
 	. "github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/utils/pointer"
 
 	bootstrapv1 "sigs.k8s.io/cluster-api/bootstrap/kubeadm/api/v1alpha3"
@@ -37,11 +39,17 @@ func TestKubeadmControlPlaneDefault(t *testing.T) {
 		},
 		Spec: KubeadmControlPlaneSpec{
 			InfrastructureTemplate: corev1.ObjectReference{},
+			EtcdBackup:             &EtcdBackup{Interval: metav1.Duration{Duration: time.Hour}},
 		},
 	}
 	kcp.Default()
 
 	g.Expect(kcp.Spec.InfrastructureTemplate.Namespace).To(Equal(kcp.Namespace))
+	g.Expect(kcp.Spec.RolloutStrategy.Type).To(Equal(RollingUpdateStrategyType))
+	g.Expect(kcp.Spec.RolloutStrategy.RollingUpdate.MaxSurge.IntVal).To(Equal(int32(1)))
+	g.Expect(kcp.Spec.DeletePolicy).To(Equal(FailureDomainBalanceDeletePolicy))
+	g.Expect(*kcp.Spec.EtcdBackup.Retention).To(Equal(int32(3)))
+	g.Expect(kcp.Spec.EtcdBackup.SinkType).To(Equal(SecretEtcdBackupSinkType))
 }
 
 func TestKubeadmControlPlaneValidateCreate(t *testing.T) {
@@ -81,6 +89,55 @@ func TestKubeadmControlPlaneValidateCreate(t *testing.T) {
 		},
 	}
 
+	scaleInRollout := valid.DeepCopy()
+	scaleInRollout.Spec.Replicas = pointer.Int32Ptr(3)
+	scaleInRollout.Spec.RolloutStrategy = rollingUpdateWithMaxSurge(intstr.FromInt(0))
+
+	scaleInRolloutSingleReplica := scaleInRollout.DeepCopy()
+	scaleInRolloutSingleReplica.Spec.Replicas = pointer.Int32Ptr(1)
+
+	invalidMaxSurge := valid.DeepCopy()
+	invalidMaxSurge.Spec.RolloutStrategy = rollingUpdateWithMaxSurge(intstr.FromInt(2))
+
+	percentageMaxSurge := valid.DeepCopy()
+	percentageMaxSurge.Spec.RolloutStrategy = rollingUpdateWithMaxSurge(intstr.FromString("100%"))
+
+	unsupportedRolloutStrategy := valid.DeepCopy()
+	unsupportedRolloutStrategy.Spec.RolloutStrategy = &RolloutStrategy{Type: "Recreate"}
+
+	etcdBackup := valid.DeepCopy()
+	etcdBackup.Spec.EtcdBackup = &EtcdBackup{Interval: metav1.Duration{Duration: time.Hour}, Retention: pointer.Int32Ptr(1)}
+
+	zeroEtcdBackupInterval := etcdBackup.DeepCopy()
+	zeroEtcdBackupInterval.Spec.EtcdBackup.Interval.Duration = 0
+
+	zeroEtcdBackupRetention := etcdBackup.DeepCopy()
+	zeroEtcdBackupRetention.Spec.EtcdBackup.Retention = pointer.Int32Ptr(0)
+
+	rolloutBeforeCertificatesExpiry := valid.DeepCopy()
+	rolloutBeforeCertificatesExpiry.Spec.RolloutBefore = &RolloutBefore{CertificatesExpiryDays: pointer.Int32Ptr(21)}
+
+	rolloutTooCloseToCertificatesExpiry := valid.DeepCopy()
+	rolloutTooCloseToCertificatesExpiry.Spec.RolloutBefore = &RolloutBefore{CertificatesExpiryDays: pointer.Int32Ptr(6)}
+
+	newestDeletePolicy := valid.DeepCopy()
+	newestDeletePolicy.Spec.DeletePolicy = NewestDeletePolicy
+
+	unsupportedDeletePolicy := valid.DeepCopy()
+	unsupportedDeletePolicy.Spec.DeletePolicy = "Random"
+
+	lifecycleHooks := valid.DeepCopy()
+	lifecycleHooks.Spec.LifecycleHooks = &LifecycleHooks{PreDelete: []string{"etcd-backup"}, PostUpgrade: []string{"conformance"}}
+
+	invalidLifecycleHook := valid.DeepCopy()
+	invalidLifecycleHook.Spec.LifecycleHooks = &LifecycleHooks{PreDelete: []string{"etcd backup"}}
+
+	nodeDrainTimeout := valid.DeepCopy()
+	nodeDrainTimeout.Spec.NodeDrainTimeout = &metav1.Duration{Duration: 10 * time.Minute}
+
+	negativeNodeDrainTimeout := valid.DeepCopy()
+	negativeNodeDrainTimeout.Spec.NodeDrainTimeout = &metav1.Duration{Duration: -time.Minute}
+
 	tests := []struct {
 		name      string
 		expectErr bool
@@ -91,6 +148,86 @@ func TestKubeadmControlPlaneValidateCreate(t *testing.T) {
 			expectErr: false,
 			kcp:       valid,
 		},
+		{
+			name:      "should succeed when scaling in with enough replicas",
+			expectErr: false,
+			kcp:       scaleInRollout,
+		},
+		{
+			name:      "should return error when scaling in a single replica",
+			expectErr: true,
+			kcp:       scaleInRolloutSingleReplica,
+		},
+		{
+			name:      "should return error when maxSurge is not 0 or 1",
+			expectErr: true,
+			kcp:       invalidMaxSurge,
+		},
+		{
+			name:      "should return error when maxSurge is a percentage",
+			expectErr: true,
+			kcp:       percentageMaxSurge,
+		},
+		{
+			name:      "should return error when the rollout strategy is not supported",
+			expectErr: true,
+			kcp:       unsupportedRolloutStrategy,
+		},
+		{
+			name:      "should succeed when deleting the newest machine first",
+			expectErr: false,
+			kcp:       newestDeletePolicy,
+		},
+		{
+			name:      "should return error when the delete policy is not supported",
+			expectErr: true,
+			kcp:       unsupportedDeletePolicy,
+		},
+		{
+			name:      "should succeed when given lifecycle hooks",
+			expectErr: false,
+			kcp:       lifecycleHooks,
+		},
+		{
+			name:      "should return error when a lifecycle hook name is not a valid annotation name",
+			expectErr: true,
+			kcp:       invalidLifecycleHook,
+		},
+		{
+			name:      "should succeed when given a node drain timeout",
+			expectErr: false,
+			kcp:       nodeDrainTimeout,
+		},
+		{
+			name:      "should return error when the node drain timeout is negative",
+			expectErr: true,
+			kcp:       negativeNodeDrainTimeout,
+		},
+		{
+			name:      "should succeed when taking etcd snapshots",
+			expectErr: false,
+			kcp:       etcdBackup,
+		},
+		{
+			name:      "should return error when the etcd snapshot interval is zero",
+			expectErr: true,
+			kcp:       zeroEtcdBackupInterval,
+		},
+		{
+			name:      "should return error when no etcd snapshot is retained",
+			expectErr: true,
+			kcp:       zeroEtcdBackupRetention,
+		},
+		{
+			name:      "should succeed when rolling out before the certificates expire",
+			expectErr: false,
+			kcp:       rolloutBeforeCertificatesExpiry,
+		},
+		{
+			name:      "should return error when rolling out less than 7 days before the certificates expire",
+			expectErr: true,
+			kcp:       rolloutTooCloseToCertificatesExpiry,
+		},
 		{
 			name:      "should return error when kubeadmControlPlane namespace and infrastructureTemplate  namespace mismatch",
 			expectErr: true,
@@ -155,9 +292,31 @@ func TestKubeadmControlPlaneValidateUpdate(t *testing.T) {
 	validUpdate.Spec.InfrastructureTemplate.Name = "orange"
 	validUpdate.Spec.Replicas = pointer.Int32Ptr(5)
 
+	scaleInRollout := validUpdate.DeepCopy()
+	scaleInRollout.Spec.RolloutStrategy = rollingUpdateWithMaxSurge(intstr.FromInt(0))
+
+	invalidMaxSurge := before.DeepCopy()
+	invalidMaxSurge.Spec.RolloutStrategy = rollingUpdateWithMaxSurge(intstr.FromInt(0))
+
+	evenReplicas := before.DeepCopy()
+	evenReplicas.Spec.Replicas = pointer.Int32Ptr(2)
+
+	zeroReplicas := before.DeepCopy()
+	zeroReplicas.Spec.Replicas = pointer.Int32Ptr(0)
+
+	externalEtcd := before.DeepCopy()
+	externalEtcd.Spec.KubeadmConfigSpec.ClusterConfiguration = &kubeadmv1beta1.ClusterConfiguration{
+		Etcd: kubeadmv1beta1.Etcd{
+			External: &kubeadmv1beta1.ExternalEtcd{},
+		},
+	}
+	evenReplicasExternalEtcd := externalEtcd.DeepCopy()
+	evenReplicasExternalEtcd.Spec.Replicas = pointer.Int32Ptr(2)
+
 	tests := []struct {
 		name      string
 		expectErr bool
+		before    *KubeadmControlPlane
 		kcp       *KubeadmControlPlane
 	}{
 		{
@@ -170,13 +329,43 @@ func TestKubeadmControlPlaneValidateUpdate(t *testing.T) {
 			expectErr: true,
 			kcp:       invalidUpdate,
 		},
+		{
+			name:      "should succeed when switching to scale in rollouts with enough replicas",
+			expectErr: false,
+			kcp:       scaleInRollout,
+		},
+		{
+			name:      "should return error when switching to scale in rollouts with a single replica",
+			expectErr: true,
+			kcp:       invalidMaxSurge,
+		},
+		{
+			name:      "should return error when scaling to an even number of replicas",
+			expectErr: true,
+			kcp:       evenReplicas,
+		},
+		{
+			name:      "should return error when scaling to zero replicas",
+			expectErr: true,
+			kcp:       zeroReplicas,
+		},
+		{
+			name:      "should allow even replicas when using external etcd",
+			expectErr: false,
+			before:    externalEtcd,
+			kcp:       evenReplicasExternalEtcd,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			g := NewWithT(t)
 
-			err := tt.kcp.ValidateUpdate(before.DeepCopy())
+			old := before
+			if tt.before != nil {
+				old = tt.before
+			}
+			err := tt.kcp.ValidateUpdate(old.DeepCopy())
 			if tt.expectErr {
 				g.Expect(err).To(HaveOccurred())
 			} else {
@@ -185,3 +374,10 @@ func TestKubeadmControlPlaneValidateUpdate(t *testing.T) {
 		})
 	}
 }
+
+func rollingUpdateWithMaxSurge(maxSurge intstr.IntOrString) *RolloutStrategy {
+	return &RolloutStrategy{
+		Type:          RollingUpdateStrategyType,
+		RollingUpdate: &RollingUpdate{MaxSurge: &maxSurge},
+	}
+}
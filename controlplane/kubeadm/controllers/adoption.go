@@ -0,0 +1,172 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/version"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	bootstrapv1 "sigs.k8s.io/cluster-api/bootstrap/kubeadm/api/v1alpha3"
+	controlplanev1 "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/controlplane/kubeadm/internal/hash"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/patch"
+)
+
+// errNotAdoptable is returned when a control plane Machine is not compatible with the KubeadmControlPlane
+// that would adopt it.
+var errNotAdoptable = errors.New("control plane Machine cannot be adopted")
+
+// adoptMachines makes the KubeadmControlPlane the controller of the given control plane Machines, which no controller
+// owns, e.g. because they were created before the KubeadmControlPlane. The Machines are labeled with the configuration
+// hash of their Kubernetes version, so that Machines running another version than the KubeadmControlPlane are upgraded.
+// No Machine is adopted unless every one of them is compatible with the KubeadmControlPlane, as it would otherwise
+// manage only part of the control plane; a warning event is recorded instead.
+func (r *KubeadmControlPlaneReconciler) adoptMachines(ctx context.Context, cluster *clusterv1.Cluster, kcp *controlplanev1.KubeadmControlPlane, machines []*clusterv1.Machine) (ctrl.Result, error) {
+	// Machines adopted by a KubeadmControlPlane that is being deleted would be deleted along with it.
+	if !kcp.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	kubeadmConfigs := make([]*bootstrapv1.KubeadmConfig, 0, len(machines))
+	for _, machine := range machines {
+		kubeadmConfig, err := r.validateAdoption(ctx, kcp, machine)
+		if err != nil {
+			if errors.Cause(err) == errNotAdoptable {
+				r.recorder.Eventf(kcp, corev1.EventTypeWarning, "AdoptionFailed", "Could not adopt Machine %s/%s: %v", machine.Namespace, machine.Name, err)
+				return ctrl.Result{}, nil
+			}
+			return ctrl.Result{}, err
+		}
+		kubeadmConfigs = append(kubeadmConfigs, kubeadmConfig)
+	}
+
+	controllerRef := metav1.NewControllerRef(kcp, controlplanev1.GroupVersion.WithKind("KubeadmControlPlane"))
+	for i, machine := range machines {
+		// The cluster certificates are owned by the KubeadmConfig of the first control plane Machine; make sure they
+		// outlive it, as the KubeadmControlPlane now relies on them.
+		if err := r.adoptOwnedSecrets(ctx, cluster, kcp, kubeadmConfigs[i]); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		patchHelper, err := patch.NewHelper(machine, r.Client)
+		if err != nil {
+			return ctrl.Result{}, errors.Wrapf(err, "failed to configure the patch helper for Machine %s/%s", machine.Namespace, machine.Name)
+		}
+		spec := kcp.Spec.DeepCopy()
+		spec.Version = *machine.Spec.Version
+		if machine.Labels == nil {
+			machine.Labels = map[string]string{}
+		}
+		machine.Labels[controlplanev1.KubeadmControlPlaneHashLabelKey] = hash.Compute(spec)
+		machine.OwnerReferences = util.EnsureOwnerRef(machine.OwnerReferences, *controllerRef)
+		if err := patchHelper.Patch(ctx, machine); err != nil {
+			return ctrl.Result{}, errors.Wrapf(err, "failed to adopt Machine %s/%s", machine.Namespace, machine.Name)
+		}
+	}
+
+	// Requeue so that the adopted Machines are picked up as owned Machines.
+	return ctrl.Result{Requeue: true}, nil
+}
+
+// validateAdoption checks that the given Machine can be managed by the KubeadmControlPlane, and returns its
+// KubeadmConfig. The Machine must be bootstrapped as a control plane node by a KubeadmConfig, and the KubeadmControlPlane
+// must be able to upgrade it to its Kubernetes version, i.e. it must not run a newer version or one more than a minor
+// version older. An error caused by errNotAdoptable is returned if the Machine cannot be adopted.
+func (r *KubeadmControlPlaneReconciler) validateAdoption(ctx context.Context, kcp *controlplanev1.KubeadmControlPlane, machine *clusterv1.Machine) (*bootstrapv1.KubeadmConfig, error) {
+	if machine.Spec.Version == nil {
+		return nil, errors.Wrap(errNotAdoptable, "it has no Kubernetes version")
+	}
+	machineVersion, err := version.ParseSemantic(*machine.Spec.Version)
+	if err != nil {
+		return nil, errors.Wrapf(errNotAdoptable, "failed to parse its Kubernetes version %q: %v", *machine.Spec.Version, err)
+	}
+	kcpVersion, err := version.ParseSemantic(kcp.Spec.Version)
+	if err != nil {
+		return nil, errors.Wrapf(errNotAdoptable, "failed to parse the Kubernetes version %q of the KubeadmControlPlane: %v", kcp.Spec.Version, err)
+	}
+	if kcpVersion.Major() != machineVersion.Major() || kcpVersion.Minor() < machineVersion.Minor() || kcpVersion.Minor() > machineVersion.Minor()+1 {
+		return nil, errors.Wrapf(errNotAdoptable, "its Kubernetes version %s cannot be upgraded to version %s of the KubeadmControlPlane", *machine.Spec.Version, kcp.Spec.Version)
+	}
+
+	ref := machine.Spec.Bootstrap.ConfigRef
+	if ref == nil || ref.Kind != "KubeadmConfig" || ref.GroupVersionKind().Group != bootstrapv1.GroupVersion.Group {
+		return nil, errors.Wrap(errNotAdoptable, "it is not bootstrapped by a KubeadmConfig")
+	}
+	kubeadmConfig := &bootstrapv1.KubeadmConfig{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Namespace: machine.Namespace, Name: ref.Name}, kubeadmConfig); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, errors.Wrapf(errNotAdoptable, "its KubeadmConfig %s/%s does not exist", machine.Namespace, ref.Name)
+		}
+		return nil, errors.Wrapf(err, "failed to get KubeadmConfig of control plane Machine %s/%s", machine.Namespace, machine.Name)
+	}
+
+	joinConfiguration := kubeadmConfig.Spec.JoinConfiguration
+	if kubeadmConfig.Spec.InitConfiguration == nil && (joinConfiguration == nil || joinConfiguration.ControlPlane == nil) {
+		return nil, errors.Wrapf(errNotAdoptable, "its KubeadmConfig %s/%s does not configure a control plane node", kubeadmConfig.Namespace, kubeadmConfig.Name)
+	}
+	if clusterConfiguration := kubeadmConfig.Spec.ClusterConfiguration; clusterConfiguration != nil {
+		kcpExternalEtcd := kcp.Spec.KubeadmConfigSpec.ClusterConfiguration != nil && kcp.Spec.KubeadmConfigSpec.ClusterConfiguration.Etcd.External != nil
+		if (clusterConfiguration.Etcd.External != nil) != kcpExternalEtcd {
+			return nil, errors.Wrapf(errNotAdoptable, "its KubeadmConfig %s/%s uses another etcd topology than the KubeadmControlPlane", kubeadmConfig.Namespace, kubeadmConfig.Name)
+		}
+	}
+	return kubeadmConfig, nil
+}
+
+// adoptOwnedSecrets makes the KubeadmControlPlane the controller of the secrets of the cluster owned by the given KubeadmConfig,
+// in place of the KubeadmConfig, so that they are not garbage collected when the KubeadmConfig is deleted.
+func (r *KubeadmControlPlaneReconciler) adoptOwnedSecrets(ctx context.Context, cluster *clusterv1.Cluster, kcp *controlplanev1.KubeadmControlPlane, kubeadmConfig *bootstrapv1.KubeadmConfig) error {
+	secrets := &corev1.SecretList{}
+	if err := r.Client.List(ctx, secrets, client.InNamespace(kcp.Namespace), client.MatchingLabels{clusterv1.ClusterLabelName: cluster.Name}); err != nil {
+		return errors.Wrap(err, "failed to list secrets")
+	}
+
+	controllerRef := metav1.NewControllerRef(kcp, controlplanev1.GroupVersion.WithKind("KubeadmControlPlane"))
+	for i := range secrets.Items {
+		secret := &secrets.Items[i]
+		ownerRefs := make([]metav1.OwnerReference, 0, len(secret.OwnerReferences))
+		for _, ownerRef := range secret.OwnerReferences {
+			if ownerRef.Kind == "KubeadmConfig" && ownerRef.Name == kubeadmConfig.Name {
+				continue
+			}
+			ownerRefs = append(ownerRefs, ownerRef)
+		}
+		if len(ownerRefs) == len(secret.OwnerReferences) {
+			continue
+		}
+
+		patchHelper, err := patch.NewHelper(secret, r.Client)
+		if err != nil {
+			return errors.Wrapf(err, "failed to configure the patch helper for secret %s/%s", secret.Namespace, secret.Name)
+		}
+		secret.OwnerReferences = util.EnsureOwnerRef(ownerRefs, *controllerRef)
+		if err := patchHelper.Patch(ctx, secret); err != nil {
+			return errors.Wrapf(err, "failed to adopt secret %s/%s", secret.Namespace, secret.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	utilpointer "k8s.io/utils/pointer"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	bootstrapv1 "sigs.k8s.io/cluster-api/bootstrap/kubeadm/api/v1alpha3"
+	kubeadmv1 "sigs.k8s.io/cluster-api/bootstrap/kubeadm/types/v1beta1"
+	controlplanev1 "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/controlplane/kubeadm/internal"
+	"sigs.k8s.io/cluster-api/controlplane/kubeadm/internal/hash"
+)
+
+func TestKubeadmControlPlaneReconciler_adoptMachines(t *testing.T) {
+	// setup returns a reconciler and control plane Machines created before the KubeadmControlPlane, bootstrapped by
+	// KubeadmConfigs. The KubeadmConfig of the first Machine owns the cluster CA secret.
+	setup := func(g *WithT, versions ...string) (*KubeadmControlPlaneReconciler, *clusterv1.Cluster, *controlplanev1.KubeadmControlPlane, []*clusterv1.Machine) {
+		fakeClient, err := fakeClient()
+		g.Expect(err).NotTo(HaveOccurred())
+
+		cluster, kcp, _ := createClusterWithControlPlane()
+		kcp.UID = "kcp-uid"
+		kcp.Spec.Version = "v1.17.3"
+
+		machines := []*clusterv1.Machine{}
+		for i, version := range versions {
+			kubeadmConfig := &bootstrapv1.KubeadmConfig{
+				ObjectMeta: metav1.ObjectMeta{Namespace: cluster.Namespace, Name: fmt.Sprintf("config-%d", i), UID: types.UID(fmt.Sprintf("config-uid-%d", i))},
+				Spec: bootstrapv1.KubeadmConfigSpec{
+					JoinConfiguration: &kubeadmv1.JoinConfiguration{ControlPlane: &kubeadmv1.JoinControlPlane{}},
+				},
+			}
+			if i == 0 {
+				kubeadmConfig.Spec.JoinConfiguration = nil
+				kubeadmConfig.Spec.InitConfiguration = &kubeadmv1.InitConfiguration{}
+			}
+			g.Expect(fakeClient.Create(context.Background(), kubeadmConfig)).To(Succeed())
+
+			m, _ := createMachineNodePair(fmt.Sprintf("test-%d", i), cluster, kcp, true)
+			m.OwnerReferences = nil
+			m.Spec.ClusterName = cluster.Name
+			m.Spec.Version = utilpointer.StringPtr(version)
+			m.Spec.Bootstrap.ConfigRef = &corev1.ObjectReference{
+				APIVersion: bootstrapv1.GroupVersion.String(),
+				Kind:       "KubeadmConfig",
+				Namespace:  cluster.Namespace,
+				Name:       kubeadmConfig.Name,
+			}
+			g.Expect(fakeClient.Create(context.Background(), m)).To(Succeed())
+			machines = append(machines, m)
+		}
+
+		caSecret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: cluster.Namespace,
+				Name:      cluster.Name + "-ca",
+				Labels:    map[string]string{clusterv1.ClusterLabelName: cluster.Name},
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: bootstrapv1.GroupVersion.String(),
+					Kind:       "KubeadmConfig",
+					Name:       "config-0",
+					UID:        "config-uid-0",
+					Controller: utilpointer.BoolPtr(true),
+				}},
+			},
+		}
+		g.Expect(fakeClient.Create(context.Background(), caSecret)).To(Succeed())
+
+		r := &KubeadmControlPlaneReconciler{
+			Client:            fakeClient,
+			managementCluster: &internal.Management{Client: fakeClient},
+			recorder:          record.NewFakeRecorder(32),
+		}
+		return r, cluster, kcp, machines
+	}
+
+	t.Run("adopts the Machines and the secrets owned by their KubeadmConfigs", func(t *testing.T) {
+		g := NewWithT(t)
+
+		r, cluster, kcp, machines := setup(g, "v1.17.3", "v1.16.8")
+
+		result, err := r.adoptMachines(context.Background(), cluster, kcp, machines)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(result).To(Equal(ctrl.Result{Requeue: true}))
+
+		for _, machine := range machines {
+			adopted := &clusterv1.Machine{}
+			g.Expect(r.Client.Get(context.Background(), types.NamespacedName{Namespace: machine.Namespace, Name: machine.Name}, adopted)).To(Succeed())
+			g.Expect(internal.OwnedControlPlaneMachines(kcp.Name)(adopted)).To(BeTrue())
+
+			spec := kcp.Spec.DeepCopy()
+			spec.Version = *machine.Spec.Version
+			g.Expect(adopted.Labels).To(HaveKeyWithValue(controlplanev1.KubeadmControlPlaneHashLabelKey, hash.Compute(spec)))
+		}
+
+		caSecret := &corev1.Secret{}
+		g.Expect(r.Client.Get(context.Background(), types.NamespacedName{Namespace: cluster.Namespace, Name: cluster.Name + "-ca"}, caSecret)).To(Succeed())
+		g.Expect(caSecret.OwnerReferences).To(HaveLen(1))
+		g.Expect(caSecret.OwnerReferences[0].Kind).To(Equal("KubeadmControlPlane"))
+		g.Expect(caSecret.OwnerReferences[0].UID).To(Equal(kcp.UID))
+	})
+	t.Run("adopts no Machine if any of them cannot be upgraded to the version of the KubeadmControlPlane", func(t *testing.T) {
+		g := NewWithT(t)
+
+		r, cluster, kcp, machines := setup(g, "v1.17.3", "v1.15.3")
+
+		result, err := r.adoptMachines(context.Background(), cluster, kcp, machines)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(result).To(Equal(ctrl.Result{}))
+		g.Expect(r.recorder.(*record.FakeRecorder).Events).To(Receive(ContainSubstring("AdoptionFailed")))
+
+		controlPlaneMachines := &clusterv1.MachineList{}
+		g.Expect(r.Client.List(context.Background(), controlPlaneMachines)).To(Succeed())
+		for i := range controlPlaneMachines.Items {
+			g.Expect(metav1.GetControllerOf(&controlPlaneMachines.Items[i])).To(BeNil())
+		}
+	})
+	t.Run("does not adopt Machines not bootstrapped as control plane nodes by a KubeadmConfig", func(t *testing.T) {
+		g := NewWithT(t)
+
+		r, cluster, kcp, machines := setup(g, "v1.17.3")
+		machines[0].Spec.Bootstrap.ConfigRef.Kind = "OtherConfig"
+
+		result, err := r.adoptMachines(context.Background(), cluster, kcp, machines)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(result).To(Equal(ctrl.Result{}))
+		g.Expect(r.recorder.(*record.FakeRecorder).Events).To(Receive(ContainSubstring("not bootstrapped by a KubeadmConfig")))
+
+		r, cluster, kcp, machines = setup(g, "v1.17.3", "v1.17.3")
+		kubeadmConfig := &bootstrapv1.KubeadmConfig{}
+		g.Expect(r.Client.Get(context.Background(), types.NamespacedName{Namespace: cluster.Namespace, Name: "config-1"}, kubeadmConfig)).To(Succeed())
+		kubeadmConfig.Spec.JoinConfiguration.ControlPlane = nil
+		g.Expect(r.Client.Update(context.Background(), kubeadmConfig)).To(Succeed())
+
+		result, err = r.adoptMachines(context.Background(), cluster, kcp, machines)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(result).To(Equal(ctrl.Result{}))
+		g.Expect(r.recorder.(*record.FakeRecorder).Events).To(Receive(ContainSubstring("does not configure a control plane node")))
+	})
+	t.Run("does not adopt Machines into a KubeadmControlPlane that is being deleted", func(t *testing.T) {
+		g := NewWithT(t)
+
+		r, cluster, kcp, machines := setup(g, "v1.17.3")
+		now := metav1.Now()
+		kcp.DeletionTimestamp = &now
+
+		result, err := r.adoptMachines(context.Background(), cluster, kcp, machines)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(result).To(Equal(ctrl.Result{}))
+
+		machine := &clusterv1.Machine{}
+		g.Expect(r.Client.Get(context.Background(), types.NamespacedName{Namespace: machines[0].Namespace, Name: machines[0].Name}, machine)).To(Succeed())
+		g.Expect(metav1.GetControllerOf(machine)).To(BeNil())
+	})
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	controlplanev1 "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/controlplane/kubeadm/internal"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/patch"
+)
+
+// defaultCertificatesExpiryWarningDays is how many days before the certificates of a control plane Machine expire
+// the MachineCertificatesValidCondition turns false, when the KubeadmControlPlane does not set
+// RolloutBefore.CertificatesExpiryDays.
+const defaultCertificatesExpiryWarningDays = 30
+
+// reconcileCertificatesExpiry looks up when the certificates of the owned control plane Machines expire, records the
+// expiry on the Machines that do not have it yet, and reports the Machines whose certificates expire soon in the
+// MachineCertificatesValidCondition. Lookup failures are reported as events and do not stop the reconciliation.
+func (r *KubeadmControlPlaneReconciler) reconcileCertificatesExpiry(ctx context.Context, cluster *clusterv1.Cluster, kcp *controlplanev1.KubeadmControlPlane, ownedMachines []*clusterv1.Machine) error {
+	expiries, err := r.managementCluster.GetMachineCertificatesExpiries(ctx, clusterKey(cluster), ownedMachines)
+	if err != nil {
+		r.recorder.Eventf(kcp, corev1.EventTypeWarning, "FailedCertificatesExpiryLookup", "Failed to look up when the certificates of the control plane Machines of cluster %s/%s expire: %v", cluster.Namespace, cluster.Name, err)
+	}
+
+	for _, machine := range ownedMachines {
+		expiry, ok := expiries[machine.Name]
+		if !ok {
+			continue
+		}
+		if _, ok := machine.Annotations[controlplanev1.CertificatesExpiryAnnotation]; ok {
+			continue
+		}
+		patchHelper, err := patch.NewHelper(machine, r.Client)
+		if err != nil {
+			return errors.Wrapf(err, "failed to configure the patch helper for Machine %s/%s", machine.Namespace, machine.Name)
+		}
+		if machine.Annotations == nil {
+			machine.Annotations = map[string]string{}
+		}
+		machine.Annotations[controlplanev1.CertificatesExpiryAnnotation] = expiry.UTC().Format(time.RFC3339)
+		if err := patchHelper.Patch(ctx, machine); err != nil {
+			return errors.Wrapf(err, "failed to record the certificates expiry of Machine %s/%s", machine.Namespace, machine.Name)
+		}
+	}
+
+	deadline := time.Now().Add(certificatesExpiryWindow(kcp, defaultCertificatesExpiryWarningDays))
+	expiring := []string{}
+	for _, machine := range internal.FilterMachines(ownedMachines, internal.CertificatesExpireBefore(deadline)) {
+		expiring = append(expiring, fmt.Sprintf("%s (%s)", machine.Name, machine.Annotations[controlplanev1.CertificatesExpiryAnnotation]))
+	}
+	if len(expiring) == 0 {
+		conditions.MarkTrue(kcp, controlplanev1.MachineCertificatesValidCondition)
+		return nil
+	}
+	sort.Strings(expiring)
+	conditions.MarkFalse(kcp, controlplanev1.MachineCertificatesValidCondition, controlplanev1.MachineCertificatesExpiringReason, clusterv1.ConditionSeverityWarning,
+		"The certificates of control plane Machines %s expire soon", strings.Join(expiring, ", "))
+	return nil
+}
+
+// certificatesExpireBeforeRollout returns a MachineFilter function to find the control plane Machines whose
+// certificates expire within the RolloutBefore.CertificatesExpiryDays of the KubeadmControlPlane, which are to be
+// replaced. No Machine matches if it is not set.
+func certificatesExpireBeforeRollout(kcp *controlplanev1.KubeadmControlPlane) func(machine *clusterv1.Machine) bool {
+	if kcp.Spec.RolloutBefore == nil || kcp.Spec.RolloutBefore.CertificatesExpiryDays == nil {
+		return func(*clusterv1.Machine) bool { return false }
+	}
+	return internal.CertificatesExpireBefore(time.Now().Add(certificatesExpiryWindow(kcp, 0)))
+}
+
+// certificatesExpiryWindow returns the RolloutBefore.CertificatesExpiryDays of the KubeadmControlPlane, or the given
+// number of days if it is not set, as a duration.
+func certificatesExpiryWindow(kcp *controlplanev1.KubeadmControlPlane, defaultDays int32) time.Duration {
+	days := defaultDays
+	if kcp.Spec.RolloutBefore != nil && kcp.Spec.RolloutBefore.CertificatesExpiryDays != nil {
+		days = *kcp.Spec.RolloutBefore.CertificatesExpiryDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
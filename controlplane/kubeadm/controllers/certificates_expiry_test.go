@@ -0,0 +1,113 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	utilpointer "k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	controlplanev1 "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
+	fakecluster "sigs.k8s.io/cluster-api/controlplane/kubeadm/internal/fake"
+	"sigs.k8s.io/cluster-api/util/conditions"
+)
+
+func TestKubeadmControlPlaneReconciler_reconcileCertificatesExpiry(t *testing.T) {
+	expiringSoon := time.Now().Add(10 * 24 * time.Hour).UTC().Truncate(time.Second)
+	expiringLater := time.Now().Add(300 * 24 * time.Hour).UTC().Truncate(time.Second)
+
+	setup := func(g *WithT) (*KubeadmControlPlaneReconciler, *clusterv1.Cluster, *controlplanev1.KubeadmControlPlane, []*clusterv1.Machine) {
+		fakeClient, err := fakeClient()
+		g.Expect(err).NotTo(HaveOccurred())
+
+		cluster, kcp, _ := createClusterWithControlPlane()
+		machines := []*clusterv1.Machine{}
+		for _, name := range []string{"soon", "later", "unknown"} {
+			machine, _ := createMachineNodePair(name, cluster, kcp, true)
+			g.Expect(fakeClient.Create(context.Background(), machine)).To(Succeed())
+			machines = append(machines, machine)
+		}
+
+		r := &KubeadmControlPlaneReconciler{
+			Client: fakeClient,
+			managementCluster: &fakecluster.ManagementCluster{CertificatesExpiries: map[string]time.Time{
+				"soon":  expiringSoon,
+				"later": expiringLater,
+			}},
+			recorder: record.NewFakeRecorder(32),
+		}
+		return r, cluster, kcp, machines
+	}
+
+	t.Run("records the expiry on Machines and reports the ones expiring soon", func(t *testing.T) {
+		g := NewWithT(t)
+
+		r, cluster, kcp, machines := setup(g)
+		g.Expect(r.reconcileCertificatesExpiry(context.Background(), cluster, kcp, machines)).To(Succeed())
+
+		for name, expiry := range map[string]time.Time{"soon": expiringSoon, "later": expiringLater} {
+			machine := &clusterv1.Machine{}
+			g.Expect(r.Client.Get(context.Background(), client.ObjectKey{Namespace: cluster.Namespace, Name: name}, machine)).To(Succeed())
+			g.Expect(machine.Annotations).To(HaveKeyWithValue(controlplanev1.CertificatesExpiryAnnotation, expiry.Format(time.RFC3339)))
+		}
+		machine := &clusterv1.Machine{}
+		g.Expect(r.Client.Get(context.Background(), client.ObjectKey{Namespace: cluster.Namespace, Name: "unknown"}, machine)).To(Succeed())
+		g.Expect(machine.Annotations).NotTo(HaveKey(controlplanev1.CertificatesExpiryAnnotation))
+
+		condition := conditions.Get(kcp, controlplanev1.MachineCertificatesValidCondition)
+		g.Expect(condition).NotTo(BeNil())
+		g.Expect(condition.Status).To(Equal(corev1.ConditionFalse))
+		g.Expect(condition.Reason).To(Equal(controlplanev1.MachineCertificatesExpiringReason))
+		g.Expect(condition.Message).To(ContainSubstring("soon"))
+		g.Expect(condition.Message).NotTo(ContainSubstring("later"))
+	})
+	t.Run("reports valid certificates outside of the rollout window", func(t *testing.T) {
+		g := NewWithT(t)
+
+		r, cluster, kcp, machines := setup(g)
+		kcp.Spec.RolloutBefore = &controlplanev1.RolloutBefore{CertificatesExpiryDays: utilpointer.Int32Ptr(7)}
+		g.Expect(r.reconcileCertificatesExpiry(context.Background(), cluster, kcp, machines)).To(Succeed())
+
+		condition := conditions.Get(kcp, controlplanev1.MachineCertificatesValidCondition)
+		g.Expect(condition).NotTo(BeNil())
+		g.Expect(condition.Status).To(Equal(corev1.ConditionTrue))
+	})
+}
+
+func TestCertificatesExpireBeforeRollout(t *testing.T) {
+	g := NewWithT(t)
+
+	machine := &clusterv1.Machine{}
+	machine.Annotations = map[string]string{
+		controlplanev1.CertificatesExpiryAnnotation: time.Now().Add(10 * 24 * time.Hour).UTC().Format(time.RFC3339),
+	}
+	kcp := &controlplanev1.KubeadmControlPlane{}
+	g.Expect(certificatesExpireBeforeRollout(kcp)(machine)).To(BeFalse())
+
+	kcp.Spec.RolloutBefore = &controlplanev1.RolloutBefore{CertificatesExpiryDays: utilpointer.Int32Ptr(7)}
+	g.Expect(certificatesExpireBeforeRollout(kcp)(machine)).To(BeFalse())
+
+	kcp.Spec.RolloutBefore.CertificatesExpiryDays = utilpointer.Int32Ptr(14)
+	g.Expect(certificatesExpireBeforeRollout(kcp)(machine)).To(BeTrue())
+}
@@ -0,0 +1,220 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	controlplanev1 "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
+)
+
+const (
+	// etcdSnapshotSecretKey is the key of the compressed etcd snapshot in the Secrets of the Secret sink.
+	etcdSnapshotSecretKey = "snapshot.db.gz"
+
+	// maxEtcdSnapshotSecretSize is the largest compressed etcd snapshot the Secret sink stores, leaving room
+	// for the metadata of the Secret within the 1MiB the API server accepts.
+	maxEtcdSnapshotSecretSize = 1000 * 1024
+)
+
+// EtcdSnapshotSink stores the etcd snapshots of the control planes of a given sink type, e.g. in an object store.
+// Snapshots are named after the cluster and the time they were taken, so that their names sort in chronological order.
+type EtcdSnapshotSink interface {
+	// Store reads an etcd snapshot of the control plane to completion and stores it under the given name.
+	Store(ctx context.Context, cluster *clusterv1.Cluster, kcp *controlplanev1.KubeadmControlPlane, name string, snapshot io.Reader) error
+
+	// List returns the names of the stored etcd snapshots of the control plane.
+	List(ctx context.Context, cluster *clusterv1.Cluster, kcp *controlplanev1.KubeadmControlPlane) ([]string, error)
+
+	// Delete deletes the named etcd snapshot of the control plane. Deleting a snapshot that does not exist succeeds.
+	Delete(ctx context.Context, cluster *clusterv1.Cluster, kcp *controlplanev1.KubeadmControlPlane, name string) error
+}
+
+// reconcileEtcdBackup takes a snapshot of the stacked etcd cluster of the target cluster and stores it in the sink of
+// the KubeadmControlPlane once its backup interval has passed since the last snapshot, or since the KubeadmControlPlane
+// was created if no snapshot was taken yet, and then deletes the oldest snapshots beyond its retention.
+func (r *KubeadmControlPlaneReconciler) reconcileEtcdBackup(ctx context.Context, cluster *clusterv1.Cluster, kcp *controlplanev1.KubeadmControlPlane) (ctrl.Result, error) {
+	backup := kcp.Spec.EtcdBackup
+	if backup == nil || backup.Interval.Duration <= 0 || usesExternalEtcd(kcp) {
+		return ctrl.Result{}, nil
+	}
+
+	last := kcp.CreationTimestamp
+	if kcp.Status.LastEtcdSnapshotTime != nil {
+		last = *kcp.Status.LastEtcdSnapshotTime
+	}
+	if wait := time.Until(last.Add(backup.Interval.Duration)); wait > 0 {
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
+
+	sink, err := r.etcdSnapshotSink(backup.SinkType)
+	if err != nil {
+		r.recorder.Eventf(kcp, corev1.EventTypeWarning, "FailedEtcdSnapshot", "Failed to take an etcd snapshot of cluster %s/%s: %v", cluster.Namespace, cluster.Name, err)
+		return ctrl.Result{}, nil
+	}
+
+	now := metav1.Now()
+	name := fmt.Sprintf("%s-etcd-%s", cluster.Name, now.UTC().Format("20060102150405"))
+	if err := r.storeEtcdSnapshot(ctx, cluster, kcp, sink, name); err != nil {
+		r.recorder.Eventf(kcp, corev1.EventTypeWarning, "FailedEtcdSnapshot", "Failed to take an etcd snapshot of cluster %s/%s: %v", cluster.Namespace, cluster.Name, err)
+		return ctrl.Result{RequeueAfter: HealthCheckFailedRequeueAfter}, nil
+	}
+	kcp.Status.LastEtcdSnapshotTime = &now
+	r.recorder.Eventf(kcp, corev1.EventTypeNormal, "EtcdSnapshotTaken", "Stored etcd snapshot %s", name)
+
+	retention := 3
+	if backup.Retention != nil {
+		retention = int(*backup.Retention)
+	}
+	if err := pruneEtcdSnapshots(ctx, cluster, kcp, sink, retention); err != nil {
+		r.recorder.Eventf(kcp, corev1.EventTypeWarning, "FailedEtcdSnapshotPruning", "Failed to delete old etcd snapshots of cluster %s/%s: %v", cluster.Namespace, cluster.Name, err)
+	}
+	return ctrl.Result{RequeueAfter: backup.Interval.Duration}, nil
+}
+
+// etcdSnapshotSink returns the sink registered for the given type, falling back to the Secret sink.
+func (r *KubeadmControlPlaneReconciler) etcdSnapshotSink(sinkType controlplanev1.EtcdBackupSinkType) (EtcdSnapshotSink, error) {
+	if sink, ok := r.EtcdSnapshotSinks[sinkType]; ok {
+		return sink, nil
+	}
+	if sinkType == "" || sinkType == controlplanev1.SecretEtcdBackupSinkType {
+		return &secretEtcdSnapshotSink{Client: r.Client}, nil
+	}
+	return nil, errors.Errorf("no etcd snapshot sink is registered for type %q", sinkType)
+}
+
+// storeEtcdSnapshot streams an etcd snapshot of the target cluster to the sink.
+func (r *KubeadmControlPlaneReconciler) storeEtcdSnapshot(ctx context.Context, cluster *clusterv1.Cluster, kcp *controlplanev1.KubeadmControlPlane, sink EtcdSnapshotSink, name string) error {
+	workloadCluster, err := r.managementCluster.GetWorkloadCluster(ctx, clusterKey(cluster))
+	if err != nil {
+		return errors.Wrapf(err, "failed to get the workload cluster of cluster %s/%s", cluster.Namespace, cluster.Name)
+	}
+
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(workloadCluster.SnapshotEtcd(ctx, writer))
+	}()
+	// Unblock the snapshot if the sink returns before reading it to completion.
+	defer reader.Close()
+
+	return sink.Store(ctx, cluster, kcp, name, reader)
+}
+
+// pruneEtcdSnapshots deletes the oldest etcd snapshots of the control plane so that at most retention are left.
+func pruneEtcdSnapshots(ctx context.Context, cluster *clusterv1.Cluster, kcp *controlplanev1.KubeadmControlPlane, sink EtcdSnapshotSink, retention int) error {
+	names, err := sink.List(ctx, cluster, kcp)
+	if err != nil {
+		return err
+	}
+	if len(names) <= retention {
+		return nil
+	}
+	sort.Strings(names)
+	for _, name := range names[:len(names)-retention] {
+		if err := sink.Delete(ctx, cluster, kcp, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// secretEtcdSnapshotSink stores etcd snapshots, gzip compressed, in Secrets in the namespace of the KubeadmControlPlane.
+// The Secrets are owned by the KubeadmControlPlane, so they are deleted along with it.
+type secretEtcdSnapshotSink struct {
+	Client client.Client
+}
+
+var _ EtcdSnapshotSink = &secretEtcdSnapshotSink{}
+
+func (s *secretEtcdSnapshotSink) Store(ctx context.Context, cluster *clusterv1.Cluster, kcp *controlplanev1.KubeadmControlPlane, name string, snapshot io.Reader) error {
+	compressed := &bytes.Buffer{}
+	gz := gzip.NewWriter(compressed)
+	if _, err := io.Copy(gz, snapshot); err != nil {
+		return errors.Wrap(err, "failed to read etcd snapshot")
+	}
+	if err := gz.Close(); err != nil {
+		return errors.Wrap(err, "failed to compress etcd snapshot")
+	}
+	if compressed.Len() > maxEtcdSnapshotSecretSize {
+		return errors.Errorf("compressed etcd snapshot is %d bytes, more than a Secret can hold; use another sink type", compressed.Len())
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: kcp.Namespace,
+			Name:      name,
+			Labels: map[string]string{
+				clusterv1.ClusterLabelName:           cluster.Name,
+				controlplanev1.EtcdSnapshotLabelName: kcp.Name,
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(kcp, controlplanev1.GroupVersion.WithKind("KubeadmControlPlane")),
+			},
+		},
+		Data: map[string][]byte{
+			etcdSnapshotSecretKey: compressed.Bytes(),
+		},
+	}
+	if err := s.Client.Create(ctx, secret); err != nil {
+		return errors.Wrapf(err, "failed to create etcd snapshot Secret %s/%s", secret.Namespace, secret.Name)
+	}
+	return nil
+}
+
+func (s *secretEtcdSnapshotSink) List(ctx context.Context, cluster *clusterv1.Cluster, kcp *controlplanev1.KubeadmControlPlane) ([]string, error) {
+	secrets := &corev1.SecretList{}
+	labels := client.MatchingLabels{
+		clusterv1.ClusterLabelName:           cluster.Name,
+		controlplanev1.EtcdSnapshotLabelName: kcp.Name,
+	}
+	if err := s.Client.List(ctx, secrets, client.InNamespace(kcp.Namespace), labels); err != nil {
+		return nil, errors.Wrap(err, "failed to list etcd snapshot Secrets")
+	}
+
+	names := make([]string, 0, len(secrets.Items))
+	for _, secret := range secrets.Items {
+		names = append(names, secret.Name)
+	}
+	return names, nil
+}
+
+func (s *secretEtcdSnapshotSink) Delete(ctx context.Context, cluster *clusterv1.Cluster, kcp *controlplanev1.KubeadmControlPlane, name string) error {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: kcp.Namespace,
+			Name:      name,
+		},
+	}
+	if err := s.Client.Delete(ctx, secret); err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to delete etcd snapshot Secret %s/%s", secret.Namespace, secret.Name)
+	}
+	return nil
+}
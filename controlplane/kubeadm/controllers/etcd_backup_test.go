@@ -0,0 +1,159 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	utilpointer "k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	controlplanev1 "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
+	fakecluster "sigs.k8s.io/cluster-api/controlplane/kubeadm/internal/fake"
+)
+
+// memoryEtcdSnapshotSink is an EtcdSnapshotSink keeping snapshots in memory.
+type memoryEtcdSnapshotSink struct {
+	snapshots map[string]string
+}
+
+func (s *memoryEtcdSnapshotSink) Store(_ context.Context, _ *clusterv1.Cluster, _ *controlplanev1.KubeadmControlPlane, name string, snapshot io.Reader) error {
+	data, err := ioutil.ReadAll(snapshot)
+	if err != nil {
+		return err
+	}
+	s.snapshots[name] = string(data)
+	return nil
+}
+
+func (s *memoryEtcdSnapshotSink) List(_ context.Context, _ *clusterv1.Cluster, _ *controlplanev1.KubeadmControlPlane) ([]string, error) {
+	names := []string{}
+	for name := range s.snapshots {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func (s *memoryEtcdSnapshotSink) Delete(_ context.Context, _ *clusterv1.Cluster, _ *controlplanev1.KubeadmControlPlane, name string) error {
+	delete(s.snapshots, name)
+	return nil
+}
+
+func TestKubeadmControlPlaneReconciler_reconcileEtcdBackup(t *testing.T) {
+	setup := func(g *WithT) (*KubeadmControlPlaneReconciler, *clusterv1.Cluster, *controlplanev1.KubeadmControlPlane) {
+		fakeClient, err := fakeClient()
+		g.Expect(err).NotTo(HaveOccurred())
+
+		cluster, kcp, _ := createClusterWithControlPlane()
+		kcp.UID = "kcp-uid"
+		kcp.CreationTimestamp = metav1.Time{Time: time.Now().Add(-24 * time.Hour)}
+		kcp.Spec.EtcdBackup = &controlplanev1.EtcdBackup{
+			Interval:  metav1.Duration{Duration: time.Hour},
+			Retention: utilpointer.Int32Ptr(2),
+		}
+
+		r := &KubeadmControlPlaneReconciler{
+			Client:            fakeClient,
+			managementCluster: &fakecluster.ManagementCluster{Workload: &fakecluster.WorkloadCluster{EtcdSnapshot: "snapshot"}},
+			recorder:          record.NewFakeRecorder(32),
+		}
+		return r, cluster, kcp
+	}
+
+	t.Run("stores snapshots in Secrets and deletes the oldest ones", func(t *testing.T) {
+		g := NewWithT(t)
+
+		r, cluster, kcp := setup(g)
+		for _, name := range []string{"foo-etcd-20200101000000", "foo-etcd-20200102000000"} {
+			g.Expect(r.Client.Create(context.Background(), &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: kcp.Namespace,
+					Name:      name,
+					Labels:    map[string]string{clusterv1.ClusterLabelName: cluster.Name, controlplanev1.EtcdSnapshotLabelName: kcp.Name},
+				},
+			})).To(Succeed())
+		}
+
+		result, err := r.reconcileEtcdBackup(context.Background(), cluster, kcp)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(result.RequeueAfter).To(Equal(time.Hour))
+		g.Expect(kcp.Status.LastEtcdSnapshotTime).NotTo(BeNil())
+		g.Expect(r.recorder.(*record.FakeRecorder).Events).To(Receive(ContainSubstring("EtcdSnapshotTaken")))
+
+		secrets := &corev1.SecretList{}
+		g.Expect(r.Client.List(context.Background(), secrets, client.MatchingLabels{controlplanev1.EtcdSnapshotLabelName: kcp.Name})).To(Succeed())
+		g.Expect(secrets.Items).To(HaveLen(2))
+		g.Expect(secrets.Items[0].Name).To(Equal("foo-etcd-20200102000000"))
+
+		snapshot := secrets.Items[1]
+		g.Expect(metav1.IsControlledBy(&snapshot, kcp)).To(BeTrue())
+		gz, err := gzip.NewReader(bytes.NewReader(snapshot.Data[etcdSnapshotSecretKey]))
+		g.Expect(err).NotTo(HaveOccurred())
+		data, err := ioutil.ReadAll(gz)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(string(data)).To(Equal("snapshot"))
+	})
+	t.Run("waits for the interval to pass", func(t *testing.T) {
+		g := NewWithT(t)
+
+		r, cluster, kcp := setup(g)
+		kcp.Status.LastEtcdSnapshotTime = &metav1.Time{Time: time.Now().Add(-30 * time.Minute)}
+
+		result, err := r.reconcileEtcdBackup(context.Background(), cluster, kcp)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(result.RequeueAfter).To(BeNumerically("~", 30*time.Minute, time.Minute))
+
+		secrets := &corev1.SecretList{}
+		g.Expect(r.Client.List(context.Background(), secrets)).To(Succeed())
+		g.Expect(secrets.Items).To(BeEmpty())
+	})
+	t.Run("stores snapshots in the registered sink of their type", func(t *testing.T) {
+		g := NewWithT(t)
+
+		r, cluster, kcp := setup(g)
+		kcp.Spec.EtcdBackup.SinkType = "Memory"
+		sink := &memoryEtcdSnapshotSink{snapshots: map[string]string{"foo-etcd-20200101000000": "old"}}
+		r.EtcdSnapshotSinks = map[controlplanev1.EtcdBackupSinkType]EtcdSnapshotSink{"Memory": sink}
+
+		_, err := r.reconcileEtcdBackup(context.Background(), cluster, kcp)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(sink.snapshots).To(HaveLen(2))
+		g.Expect(sink.snapshots).To(ContainElement("snapshot"))
+	})
+	t.Run("does not take snapshots without a sink of their type", func(t *testing.T) {
+		g := NewWithT(t)
+
+		r, cluster, kcp := setup(g)
+		kcp.Spec.EtcdBackup.SinkType = "S3"
+
+		_, err := r.reconcileEtcdBackup(context.Background(), cluster, kcp)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(kcp.Status.LastEtcdSnapshotTime).To(BeNil())
+		g.Expect(r.recorder.(*record.FakeRecorder).Events).To(Receive(ContainSubstring("no etcd snapshot sink is registered")))
+	})
+}
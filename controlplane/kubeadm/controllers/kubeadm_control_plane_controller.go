@@ -18,7 +18,9 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"reflect"
 	"sort"
 	"strings"
 	"time"
@@ -32,6 +34,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/apiserver/pkg/storage/names"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -47,10 +50,12 @@ import (
 	"sigs.k8s.io/cluster-api/controllers/external"
 	"sigs.k8s.io/cluster-api/controllers/remote"
 	controlplanev1 "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/controlplane/kubeadm/controllers/metrics"
 	"sigs.k8s.io/cluster-api/controlplane/kubeadm/internal"
 	"sigs.k8s.io/cluster-api/controlplane/kubeadm/internal/hash"
 	capierrors "sigs.k8s.io/cluster-api/errors"
 	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/kubeconfig"
 	"sigs.k8s.io/cluster-api/util/patch"
 	"sigs.k8s.io/cluster-api/util/secret"
@@ -66,29 +71,62 @@ const (
 	HealthCheckFailedRequeueAfter = 20 * time.Second
 )
 
-type managementCluster interface {
-	GetMachinesForCluster(ctx context.Context, cluster types.NamespacedName, filters ...func(machine *clusterv1.Machine) bool) ([]*clusterv1.Machine, error)
-	TargetClusterControlPlaneIsHealthy(ctx context.Context, clusterKey types.NamespacedName, controlPlaneName string) error
-	TargetClusterEtcdIsHealthy(ctx context.Context, clusterKey types.NamespacedName, controlPlaneName string) error
-}
-
 // +kubebuilder:rbac:groups=core,resources=events,verbs=get;list;watch;create;patch
-// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;patch
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;patch;delete
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io;bootstrap.cluster.x-k8s.io;controlplane.cluster.x-k8s.io,resources=*,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=clusters;clusters/status,verbs=get;list;watch
 // +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machines;machines/status,verbs=get;list;watch;create;update;patch;delete
 
 // KubeadmControlPlaneReconciler reconciles a KubeadmControlPlane object
 type KubeadmControlPlaneReconciler struct {
-	Client     client.Client
-	Log        logr.Logger
+	Client client.Client
+	Log    logr.Logger
+
+	// PingWorkloadAPIServer makes health checks fail fast when the workload cluster's API server is not serving.
+	PingWorkloadAPIServer bool
+
+	// HealthCheckCacheTTL is how long the result of a control plane health check is reused for the same cluster.
+	// Results are not cached if it is zero.
+	HealthCheckCacheTTL time.Duration
+
+	// DiscoverNodesFromMachines makes health checks fall back to the nodes referenced by control plane Machines
+	// when no node in the workload cluster has the control plane role label.
+	DiscoverNodesFromMachines bool
+
+	// HealthCheckTimeout bounds how long a control plane or etcd health check may take, retries included.
+	// Health checks are not bounded if it is zero.
+	HealthCheckTimeout time.Duration
+
+	// HealthCheckNodeTimeout bounds how long health checks wait for a single control plane node.
+	// The default of the management cluster is used if it is zero.
+	HealthCheckNodeTimeout time.Duration
+
+	// HealthCheckRetryBackoff is how failed health checks are retried before they fail a preflight check.
+	// Failed health checks are not retried if its Steps is less than two.
+	HealthCheckRetryBackoff wait.Backoff
+
+	// EtcdDialTimeout is how long to wait for a connection to an etcd member of a workload cluster.
+	// The etcd client and proxy defaults are used if it is zero.
+	EtcdDialTimeout time.Duration
+
+	// EtcdDefragmentationInterval is how often the stacked etcd members of a control plane are defragmented.
+	// Etcd is only defragmented on request with the DefragmentEtcdAnnotation if it is zero.
+	EtcdDefragmentationInterval time.Duration
+
+	// EtcdSnapshotSinks are the sinks etcd snapshots can be stored in, by sink type, in addition to Secrets.
+	EtcdSnapshotSinks map[controlplanev1.EtcdBackupSinkType]EtcdSnapshotSink
+
+	// Tracker holds the clients and caches of the workload clusters, which may be shared with the other controllers
+	// of the management cluster. One is created if it is nil.
+	Tracker *remote.ClusterCacheTracker
+
 	scheme     *runtime.Scheme
 	controller controller.Controller
 	recorder   record.EventRecorder
 
 	remoteClientGetter remote.ClusterClientGetter
 
-	managementCluster managementCluster
+	managementCluster internal.ManagementCluster
 }
 
 func (r *KubeadmControlPlaneReconciler) SetupWithManager(mgr ctrl.Manager, options controller.Options) error {
@@ -109,13 +147,36 @@ func (r *KubeadmControlPlaneReconciler) SetupWithManager(mgr ctrl.Manager, optio
 	r.scheme = mgr.GetScheme()
 	r.controller = c
 	r.recorder = mgr.GetEventRecorderFor("kubeadm-control-plane-controller")
+	if r.Tracker == nil {
+		r.Tracker = remote.NewClusterCacheTracker(r.Log, r.Client, r.scheme)
+	}
 	if r.remoteClientGetter == nil {
-		r.remoteClientGetter = remote.NewClusterClient
+		r.remoteClientGetter = r.Tracker.ClusterClient
+	}
+	// The management cluster is shared across reconciles so workload cluster clients can be cached.
+	if r.managementCluster == nil {
+		r.managementCluster = r.newManagementCluster()
 	}
 
 	return nil
 }
 
+// newManagementCluster returns the management cluster used to operate on workload clusters, configured from the reconciler.
+func (r *KubeadmControlPlaneReconciler) newManagementCluster() *internal.Management {
+	return &internal.Management{
+		Client:                    r.Client,
+		Tracker:                   r.Tracker,
+		PingAPIServer:             r.PingWorkloadAPIServer,
+		HealthCheckCacheTTL:       r.HealthCheckCacheTTL,
+		DiscoverNodesFromMachines: r.DiscoverNodesFromMachines,
+		HealthCheckTimeout:        r.HealthCheckTimeout,
+		HealthCheckNodeTimeout:    r.HealthCheckNodeTimeout,
+		HealthCheckRetryBackoff:   r.HealthCheckRetryBackoff,
+		EtcdDialTimeout:           r.EtcdDialTimeout,
+		MetricsSink:               metrics.Sink{},
+	}
+}
+
 func (r *KubeadmControlPlaneReconciler) Reconcile(req ctrl.Request) (res ctrl.Result, reterr error) {
 	logger := r.Log.WithValues("kubeadmControlPlane", req.Name, "namespace", req.Namespace)
 	ctx := context.Background()
@@ -146,24 +207,26 @@ func (r *KubeadmControlPlaneReconciler) Reconcile(req ctrl.Request) (res ctrl.Re
 	}
 	logger = logger.WithValues("cluster", cluster.Name)
 
-	if util.IsPaused(cluster, kcp) {
+	// Initialize the patch helper.
+	patchHelper, err := patch.NewHelper(kcp, r.Client)
+	if err != nil {
+		logger.Error(err, "Failed to configure the patch helper")
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	if util.IsPausedWithCondition(cluster, kcp) {
 		logger.Info("Reconciliation is paused")
-		return ctrl.Result{}, nil
+		return ctrl.Result{}, patchHelper.Patch(ctx, kcp, patch.WithOwnedConditions(clusterv1.PausedCondition))
+	}
+	if r.managementCluster == nil {
+		r.managementCluster = r.newManagementCluster()
 	}
-	r.managementCluster = &internal.ManagementCluster{Client: r.Client}
 
 	// Wait for the cluster infrastructure to be ready before creating machines
 	if !cluster.Status.InfrastructureReady {
 		return ctrl.Result{}, nil
 	}
 
-	// Initialize the patch helper.
-	patchHelper, err := patch.NewHelper(kcp, r.Client)
-	if err != nil {
-		logger.Error(err, "Failed to configure the patch helper")
-		return ctrl.Result{Requeue: true}, nil
-	}
-
 	defer func() {
 		// Always attempt to update status.
 		if err := r.updateStatus(ctx, kcp, cluster); err != nil {
@@ -171,8 +234,31 @@ func (r *KubeadmControlPlaneReconciler) Reconcile(req ctrl.Request) (res ctrl.Re
 			reterr = kerrors.NewAggregate([]error{reterr, err})
 		}
 
+		// Always summarize the state of the control plane in the Ready condition.
+		conditions.SetSummary(kcp,
+			conditions.WithConditions(
+				controlplanev1.MachinesReadyCondition,
+				controlplanev1.ResizedCondition,
+				controlplanev1.CertificatesAvailableCondition,
+				controlplanev1.ControlPlaneComponentsHealthyCondition,
+				controlplanev1.EtcdClusterHealthyCondition,
+			),
+		)
+
 		// Always attempt to Patch the KubeadmControlPlane object and status after each reconciliation.
-		if err := patchHelper.Patch(ctx, kcp); err != nil {
+		// The conditions reported by this controller take precedence over changes made by other processes.
+		if err := patchHelper.Patch(ctx, kcp, patch.WithOwnedConditions(
+			clusterv1.ReadyCondition,
+			clusterv1.PausedCondition,
+			controlplanev1.MachinesReadyCondition,
+			controlplanev1.ResizedCondition,
+			controlplanev1.EtcdClusterHealthyCondition,
+			controlplanev1.ControlPlaneComponentsHealthyCondition,
+			controlplanev1.CertificatesAvailableCondition,
+			controlplanev1.ExternalCACondition,
+			controlplanev1.MachineCertificatesValidCondition,
+			controlplanev1.LifecycleHooksCompletedCondition,
+		)); err != nil {
 			logger.Error(err, "Failed to patch KubeadmControlPlane")
 			reterr = kerrors.NewAggregate([]error{reterr, err})
 		}
@@ -207,8 +293,19 @@ func (r *KubeadmControlPlaneReconciler) reconcile(ctx context.Context, cluster *
 	controllerRef := metav1.NewControllerRef(kcp, controlplanev1.GroupVersion.WithKind("KubeadmControlPlane"))
 	if err := certificates.LookupOrGenerate(ctx, r.Client, clusterKey(cluster), *controllerRef); err != nil {
 		logger.Error(err, "unable to lookup or create cluster certificates")
+		conditions.MarkFalse(kcp, controlplanev1.CertificatesAvailableCondition, controlplanev1.CertificatesGenerationFailedReason, clusterv1.ConditionSeverityError, "%v", err)
 		return ctrl.Result{}, err
 	}
+	conditions.MarkTrue(kcp, controlplanev1.CertificatesAvailableCondition)
+	if externalCAs := caPurposesWithoutPrivateKey(certificates); len(externalCAs) > 0 {
+		conditions.Set(kcp, &clusterv1.Condition{
+			Type:    controlplanev1.ExternalCACondition,
+			Status:  corev1.ConditionTrue,
+			Message: fmt.Sprintf("The private keys of the %s CAs are not available, certificates they sign must be provided", strings.Join(externalCAs, ", ")),
+		})
+	} else {
+		conditions.MarkFalse(kcp, controlplanev1.ExternalCACondition, controlplanev1.CAPrivateKeysAvailableReason, clusterv1.ConditionSeverityInfo, "")
+	}
 
 	// If ControlPlaneEndpoint is not set, return early
 	if cluster.Spec.ControlPlaneEndpoint.IsZero() {
@@ -228,33 +325,70 @@ func (r *KubeadmControlPlaneReconciler) reconcile(ctx context.Context, cluster *
 		return ctrl.Result{}, err
 	}
 
-	// TODO: handle proper adoption of Machines
-	ownedMachines, err := r.managementCluster.GetMachinesForCluster(ctx, clusterKey(cluster), internal.OwnedControlPlaneMachines(kcp.Name))
+	controlPlaneMachines, err := r.managementCluster.GetMachinesForCluster(ctx, clusterKey(cluster), internal.ControlPlaneMachines(cluster.Name))
 	if err != nil {
 		return ctrl.Result{}, err
 	}
 
-	currentConfigurationHash := hash.Compute(&kcp.Spec)
-	requireUpgrade := internal.FilterMachines(
-		ownedMachines,
-		internal.HasOutdatedConfiguration(currentConfigurationHash),
-		internal.OlderThan(kcp.Spec.UpgradeAfter),
-	)
+	// Adopt the control plane Machines of the cluster that no controller owns, e.g. Machines created before the
+	// KubeadmControlPlane, before doing anything else, so that they are managed like the ones it created.
+	adoptableMachines := internal.FilterMachines(controlPlaneMachines, internal.AdoptableControlPlaneMachines(cluster.Name))
+	if len(adoptableMachines) > 0 {
+		logger.Info("Adopting control plane Machines", "Adoptable", len(adoptableMachines))
+		return r.adoptMachines(ctx, cluster, kcp, adoptableMachines)
+	}
+
+	ownedMachines := internal.FilterMachines(controlPlaneMachines, internal.OwnedControlPlaneMachines(kcp.Name))
+
+	// Remediation of unhealthy Machines takes precedence over other operations, as their health checks cannot pass
+	unhealthyMachines := internal.FilterMachines(ownedMachines, internal.IsMarkedUnhealthy())
+	if len(unhealthyMachines) > 0 {
+		logger.Info("Remediating unhealthy control plane Machine", "Unhealthy", len(unhealthyMachines))
+		result, err := r.remediateUnhealthyMachine(ctx, cluster, kcp, ownedMachines, unhealthyMachines)
+		if err != nil {
+			logger.Error(err, "Failed to remediate unhealthy control plane Machine")
+			r.recorder.Eventf(kcp, corev1.EventTypeWarning, "FailedRemediation", "Failed to remediate unhealthy control plane Machine of cluster %s/%s: %v", cluster.Namespace, cluster.Name, err)
+		}
+		return result, err
+	}
+
+	if err := r.reconcileCertificatesExpiry(ctx, cluster, kcp, ownedMachines); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	machineConfigs, err := r.getMachineConfigs(ctx, kcp, ownedMachines)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	hasOutdatedConfiguration := internal.HasOutdatedKCPConfiguration(kcp, machineConfigs)
+	olderThanUpgradeAfter := internal.OlderThan(kcp.Spec.UpgradeAfter)
+	// Machines created before RolloutAfter, or whose certificates are about to expire, are replaced like outdated ones.
+	createdBeforeRolloutAfter := internal.CreatedBeforeRolloutAfter(kcp.Spec.RolloutAfter, time.Now())
+	hasExpiringCertificates := certificatesExpireBeforeRollout(kcp)
+	requireUpgrade := internal.FilterMachines(ownedMachines, func(machine *clusterv1.Machine) bool {
+		return (hasOutdatedConfiguration(machine) && olderThanUpgradeAfter(machine)) || createdBeforeRolloutAfter(machine) || hasExpiringCertificates(machine)
+	})
+	metrics.ObserveUpgrade(types.NamespacedName{Namespace: kcp.Namespace, Name: kcp.Name}, len(requireUpgrade) > 0, time.Now())
 
 	// Upgrade takes precedence over other operations
 	if len(requireUpgrade) > 0 {
 		logger.Info("Upgrading Control Plane")
-		if err := r.upgradeControlPlane(ctx, cluster, kcp, requireUpgrade); err != nil {
+		result, err := r.upgradeControlPlane(ctx, cluster, kcp, ownedMachines, requireUpgrade)
+		if err != nil {
 			logger.Error(err, "Failed to upgrade the Control Plane")
 			r.recorder.Eventf(kcp, corev1.EventTypeWarning, "FailedUpgrade", "Failed to upgrade the control plane: %v", err)
-			return ctrl.Result{}, err
 		}
-		// TODO: need to requeue if there are additional operations to perform
-		return ctrl.Result{}, nil
+		return result, err
+	}
+
+	if err := r.reconcileKubeProxy(ctx, cluster, kcp, ownedMachines); err != nil {
+		logger.Error(err, "Failed to upgrade kube-proxy")
+		r.recorder.Eventf(kcp, corev1.EventTypeWarning, "FailedKubeProxyUpgrade", "Failed to upgrade kube-proxy of cluster %s/%s: %v", cluster.Namespace, cluster.Name, err)
+		return ctrl.Result{}, err
 	}
 
 	// If we've made it this far, we don't need to worry about Machines that are older than kcp.Spec.UpgradeAfter
-	currentMachines := internal.FilterMachines(ownedMachines, internal.MatchesConfigurationHash(currentConfigurationHash))
+	currentMachines := internal.FilterMachines(ownedMachines, internal.MatchesKCPConfiguration(kcp, machineConfigs))
 	numMachines := len(currentMachines)
 	desiredReplicas := int(*kcp.Spec.Replicas)
 
@@ -274,7 +408,7 @@ func (r *KubeadmControlPlaneReconciler) reconcile(ctx context.Context, cluster *
 	case numMachines < desiredReplicas && numMachines > 0:
 		// Create a new Machine w/ join
 		logger.Info("Scaling up control plane", "Desired", desiredReplicas, "Existing", numMachines)
-		result, err := r.scaleUpControlPlane(ctx, cluster, kcp)
+		result, err := r.scaleUpControlPlane(ctx, cluster, kcp, nil)
 		if err != nil {
 			logger.Error(err, "Failed to scale up control plane")
 			r.recorder.Eventf(kcp, corev1.EventTypeWarning, "FailedScaleUp", "Failed to scale up cluster %s/%s control plane: %v", cluster.Namespace, cluster.Name, err)
@@ -284,7 +418,7 @@ func (r *KubeadmControlPlaneReconciler) reconcile(ctx context.Context, cluster *
 	// We are scaling down
 	case numMachines > desiredReplicas:
 		logger.Info("Scaling down control plane", "Desired", desiredReplicas, "Existing", numMachines)
-		result, err := r.scaleDownControlPlane(ctx, cluster, kcp)
+		result, err := r.scaleDownControlPlane(ctx, cluster, kcp, nil)
 		if err != nil {
 			logger.Error(err, "Failed to scale down control plane")
 			r.recorder.Eventf(kcp, corev1.EventTypeWarning, "FailedScaleDown", "Failed to scale down cluster %s/%s control plane: %v", cluster.Namespace, cluster.Name, err)
@@ -293,7 +427,34 @@ func (r *KubeadmControlPlaneReconciler) reconcile(ctx context.Context, cluster *
 		return result, nil
 	}
 
-	return ctrl.Result{}, nil
+	// Maintenance only runs once the control plane is at the desired number of replicas.
+	backupResult, err := r.reconcileEtcdBackup(ctx, cluster, kcp)
+	if err != nil {
+		return backupResult, err
+	}
+	defragmentationResult, err := r.reconcileEtcdDefragmentation(ctx, cluster, kcp)
+	if err != nil {
+		return defragmentationResult, err
+	}
+	// Come back when RolloutAfter is reached to start replacing the Machines.
+	rolloutResult := ctrl.Result{}
+	if kcp.Spec.RolloutAfter != nil {
+		if wait := time.Until(kcp.Spec.RolloutAfter.Time); wait > 0 {
+			rolloutResult.RequeueAfter = wait
+		}
+	}
+	return lowestRequeueAfter(backupResult, defragmentationResult, rolloutResult), nil
+}
+
+// lowestRequeueAfter returns the result that requeues the soonest, ignoring results that do not requeue.
+func lowestRequeueAfter(results ...ctrl.Result) ctrl.Result {
+	lowest := ctrl.Result{}
+	for _, result := range results {
+		if result.RequeueAfter > 0 && (lowest.RequeueAfter == 0 || result.RequeueAfter < lowest.RequeueAfter) {
+			lowest = result
+		}
+	}
+	return lowest
 }
 
 func (r *KubeadmControlPlaneReconciler) updateStatus(ctx context.Context, kcp *controlplanev1.KubeadmControlPlane, cluster *clusterv1.Cluster) error {
@@ -312,11 +473,16 @@ func (r *KubeadmControlPlaneReconciler) updateStatus(ctx context.Context, kcp *c
 		return errors.Wrap(err, "failed to get list of owned machines")
 	}
 
-	currentMachines := internal.FilterMachines(ownedMachines, internal.MatchesConfigurationHash(hash.Compute(&kcp.Spec)))
+	machineConfigs, err := r.getMachineConfigs(ctx, kcp, ownedMachines)
+	if err != nil {
+		return err
+	}
+	currentMachines := internal.FilterMachines(ownedMachines, internal.MatchesKCPConfiguration(kcp, machineConfigs))
 	kcp.Status.UpdatedReplicas = int32(len(currentMachines))
 
 	replicas := int32(len(ownedMachines))
 	kcp.Status.Replicas = replicas
+	kcp.Status.FailureDomainReplicas = internal.CountMachines(cluster.Status.FailureDomains.FilterControlPlane(), ownedMachines)
 
 	remoteClient, err := r.remoteClientGetter(ctx, r.Client, cluster, r.scheme)
 	if err != nil && !apierrors.IsNotFound(errors.Cause(err)) {
@@ -338,6 +504,18 @@ func (r *KubeadmControlPlaneReconciler) updateStatus(ctx context.Context, kcp *c
 	}
 	kcp.Status.ReadyReplicas = readyMachines
 	kcp.Status.UnavailableReplicas = replicas - readyMachines
+	setReplicaConditions(kcp)
+
+	// The metrics of a deleted KubeadmControlPlane are dropped by reconcileDelete once its last Machine is gone.
+	if !kcp.DeletionTimestamp.IsZero() && replicas == 0 {
+		return nil
+	}
+	if kcp.Spec.Replicas != nil {
+		metrics.DesiredReplicas.WithLabelValues(kcp.Name, kcp.Namespace).Set(float64(*kcp.Spec.Replicas))
+	}
+	metrics.ReadyReplicas.WithLabelValues(kcp.Name, kcp.Namespace).Set(float64(kcp.Status.ReadyReplicas))
+	metrics.UpdatedReplicas.WithLabelValues(kcp.Name, kcp.Namespace).Set(float64(kcp.Status.UpdatedReplicas))
+	metrics.UnavailableReplicas.WithLabelValues(kcp.Name, kcp.Namespace).Set(float64(kcp.Status.UnavailableReplicas))
 
 	if !kcp.Status.Initialized {
 		if kcp.Status.ReadyReplicas > 0 {
@@ -347,27 +525,63 @@ func (r *KubeadmControlPlaneReconciler) updateStatus(ctx context.Context, kcp *c
 	return nil
 }
 
-func (r *KubeadmControlPlaneReconciler) upgradeControlPlane(ctx context.Context, cluster *clusterv1.Cluster, kcp *controlplanev1.KubeadmControlPlane, requireUpgrade []*clusterv1.Machine) error {
+// upgradeControlPlane replaces the outdated control plane Machines one at a time, according to the rollout strategy.
+// With a MaxSurge of 1 the replacement Machine is created before an outdated Machine is deleted. With a MaxSurge of 0
+// an outdated Machine is deleted first, and its replacement is created once it is gone. Replacement Machines run the
+// post-upgrade lifecycle hooks, which have to complete before the next Machine is replaced.
+func (r *KubeadmControlPlaneReconciler) upgradeControlPlane(ctx context.Context, cluster *clusterv1.Cluster, kcp *controlplanev1.KubeadmControlPlane, ownedMachines, requireUpgrade []*clusterv1.Machine) (ctrl.Result, error) {
+	workloadCluster, err := r.managementCluster.GetWorkloadCluster(ctx, clusterKey(cluster))
+	if err != nil {
+		return ctrl.Result{}, errors.Wrapf(err, "failed to get the workload cluster of cluster %s/%s", cluster.Namespace, cluster.Name)
+	}
 
-	// TODO: verify health for each existing replica
-	// TODO: mark an old Machine via the label kubeadm.controlplane.cluster.x-k8s.io/selected-for-upgrade
-	// TODO: check full cluster health
-	// TODO: provision new Machine to replace marked Machine
-	// TODO: wait for health of all existing replicas, ideally in a re-entrant way to avoid waiting in process
-	// TODO: wait for full cluster health, ideally in a re-entrant way to avoid waiting in process
-	// TODO: Remove etcd membership for old Machine instance
-	// TODO: Update the kubeadm configmap
-	// TODO: Delete the Marked ControlPlane machine
-	// TODO: Continue with next OldMachine
+	// Machines joining the control plane use the ClusterConfiguration in the kubeadm-config ConfigMap
+	if err := workloadCluster.UpdateKubeadmConfigMap(ctx, kcp.Spec.Version, kcp.Spec.KubeadmConfigSpec.ClusterConfiguration); err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "failed to update the kubeadm-config ConfigMap")
+	}
 
-	return nil
+	if err := workloadCluster.UpdateCoreDNS(ctx, kcp.Spec.KubeadmConfigSpec.ClusterConfiguration); err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "failed to upgrade CoreDNS")
+	}
+
+	// Let the post-upgrade hooks of the replacement Machines complete before replacing the next Machine.
+	if waitForPostUpgradeHooks(kcp, ownedMachines) {
+		return ctrl.Result{RequeueAfter: LifecycleHookRequeueAfter}, nil
+	}
+
+	if len(ownedMachines) < int(*kcp.Spec.Replicas)+maxSurge(kcp) {
+		var annotations map[string]string
+		if kcp.Spec.LifecycleHooks != nil {
+			annotations = lifecycleHookAnnotations(controlplanev1.PostUpgradeHookAnnotationPrefix, kcp.Spec.LifecycleHooks.PostUpgrade)
+		}
+		return r.scaleUpControlPlane(ctx, cluster, kcp, annotations)
+	}
+	return r.scaleDownControlPlane(ctx, cluster, kcp, requireUpgrade)
+}
+
+// reconcileKubeProxy sets the kube-proxy image of the target cluster to the version of the KubeadmControlPlane once
+// every control plane Machine runs that version, as kube-proxy must not be newer than the API servers.
+// Nothing is done if the KubeadmControlPlane has the SkipKubeProxyAnnotation.
+func (r *KubeadmControlPlaneReconciler) reconcileKubeProxy(ctx context.Context, cluster *clusterv1.Cluster, kcp *controlplanev1.KubeadmControlPlane, ownedMachines []*clusterv1.Machine) error {
+	if _, ok := kcp.Annotations[controlplanev1.SkipKubeProxyAnnotation]; ok {
+		return nil
+	}
+	upgradedMachines := internal.FilterMachines(ownedMachines, internal.MatchesKubernetesVersion(kcp.Spec.Version))
+	if len(ownedMachines) == 0 || len(upgradedMachines) != len(ownedMachines) {
+		return nil
+	}
+	workloadCluster, err := r.managementCluster.GetWorkloadCluster(ctx, clusterKey(cluster))
+	if err != nil {
+		return errors.Wrapf(err, "failed to get the workload cluster of cluster %s/%s", cluster.Namespace, cluster.Name)
+	}
+	return workloadCluster.UpdateKubeProxyImage(ctx, kcp.Spec.Version)
 }
 
 func (r *KubeadmControlPlaneReconciler) initializeControlPlane(ctx context.Context, cluster *clusterv1.Cluster, kcp *controlplanev1.KubeadmControlPlane) (ctrl.Result, error) {
 	bootstrapSpec := kcp.Spec.KubeadmConfigSpec.DeepCopy()
 	bootstrapSpec.JoinConfiguration = nil
 
-	if err := r.cloneConfigsAndGenerateMachine(ctx, cluster, kcp, bootstrapSpec); err != nil {
+	if err := r.cloneConfigsAndGenerateMachine(ctx, cluster, kcp, bootstrapSpec, nil); err != nil {
 		return ctrl.Result{}, errors.Wrapf(err, "failed to create control plane Machine for cluster %s/%s", cluster.Name, cluster.Namespace)
 	}
 
@@ -375,13 +589,10 @@ func (r *KubeadmControlPlaneReconciler) initializeControlPlane(ctx context.Conte
 	return ctrl.Result{Requeue: true}, nil
 }
 
-func (r *KubeadmControlPlaneReconciler) scaleUpControlPlane(ctx context.Context, cluster *clusterv1.Cluster, kcp *controlplanev1.KubeadmControlPlane) (ctrl.Result, error) {
-	if err := r.managementCluster.TargetClusterControlPlaneIsHealthy(ctx, clusterKey(cluster), kcp.Name); err != nil {
-		return ctrl.Result{RequeueAfter: HealthCheckFailedRequeueAfter}, errors.Wrap(err, "control plane is not healthy")
-	}
-
-	if err := r.managementCluster.TargetClusterEtcdIsHealthy(ctx, clusterKey(cluster), kcp.Name); err != nil {
-		return ctrl.Result{RequeueAfter: HealthCheckFailedRequeueAfter}, errors.Wrap(err, "etcd cluster is not healthy")
+// scaleUpControlPlane creates a control plane Machine that joins the control plane, with the given annotations.
+func (r *KubeadmControlPlaneReconciler) scaleUpControlPlane(ctx context.Context, cluster *clusterv1.Cluster, kcp *controlplanev1.KubeadmControlPlane, annotations map[string]string) (ctrl.Result, error) {
+	if result, err := r.checkHealth(ctx, cluster, kcp); err != nil {
+		return result, err
 	}
 
 	// Create the bootstrap configuration
@@ -389,7 +600,7 @@ func (r *KubeadmControlPlaneReconciler) scaleUpControlPlane(ctx context.Context,
 	bootstrapSpec.InitConfiguration = nil
 	bootstrapSpec.ClusterConfiguration = nil
 
-	if err := r.cloneConfigsAndGenerateMachine(ctx, cluster, kcp, bootstrapSpec); err != nil {
+	if err := r.cloneConfigsAndGenerateMachine(ctx, cluster, kcp, bootstrapSpec, annotations); err != nil {
 		return ctrl.Result{}, errors.Wrapf(err, "failed to create control plane Machine for cluster %s/%s", cluster.Name, cluster.Namespace)
 	}
 
@@ -397,14 +608,99 @@ func (r *KubeadmControlPlaneReconciler) scaleUpControlPlane(ctx context.Context,
 	return ctrl.Result{Requeue: true}, nil
 }
 
-func (r *KubeadmControlPlaneReconciler) scaleDownControlPlane(ctx context.Context, cluster *clusterv1.Cluster, kcp *controlplanev1.KubeadmControlPlane) (ctrl.Result, error) {
+// checkHealth runs the control plane and etcd health checks of the target cluster and records their outcome in the
+// ControlPlaneComponentsHealthy and EtcdClusterHealthy conditions. Etcd is not checked if the control plane is not healthy.
+// If etcd is not healthy and the KubeadmControlPlane has the RecoverEtcdNoSpaceAnnotation, NOSPACE alarms are recovered from.
+func (r *KubeadmControlPlaneReconciler) checkHealth(ctx context.Context, cluster *clusterv1.Cluster, kcp *controlplanev1.KubeadmControlPlane) (ctrl.Result, error) {
 	if err := r.managementCluster.TargetClusterControlPlaneIsHealthy(ctx, clusterKey(cluster), kcp.Name); err != nil {
+		conditions.MarkFalse(kcp, controlplanev1.ControlPlaneComponentsHealthyCondition, controlplanev1.ControlPlaneComponentsUnhealthyReason, clusterv1.ConditionSeverityWarning, "%v", err)
 		return ctrl.Result{RequeueAfter: HealthCheckFailedRequeueAfter}, errors.Wrap(err, "control plane is not healthy")
 	}
+	conditions.MarkTrue(kcp, controlplanev1.ControlPlaneComponentsHealthyCondition)
 
 	if err := r.managementCluster.TargetClusterEtcdIsHealthy(ctx, clusterKey(cluster), kcp.Name); err != nil {
+		conditions.MarkFalse(kcp, controlplanev1.EtcdClusterHealthyCondition, controlplanev1.EtcdClusterUnhealthyReason, clusterv1.ConditionSeverityWarning, "%v", err)
+		if _, ok := kcp.Annotations[controlplanev1.RecoverEtcdNoSpaceAnnotation]; ok && !usesExternalEtcd(kcp) {
+			r.recoverEtcdNoSpaceAlarms(ctx, cluster, kcp)
+		}
 		return ctrl.Result{RequeueAfter: HealthCheckFailedRequeueAfter}, errors.Wrap(err, "etcd cluster is not healthy")
 	}
+	conditions.MarkTrue(kcp, controlplanev1.EtcdClusterHealthyCondition)
+
+	return ctrl.Result{}, nil
+}
+
+// recoverEtcdNoSpaceAlarms defragments the etcd members that raised a NOSPACE alarm and disarms the alarms, recording
+// an event for each alarm disarmed and for failures. The etcd health check is expected to pass on the next reconcile.
+func (r *KubeadmControlPlaneReconciler) recoverEtcdNoSpaceAlarms(ctx context.Context, cluster *clusterv1.Cluster, kcp *controlplanev1.KubeadmControlPlane) {
+	workloadCluster, err := r.managementCluster.GetWorkloadCluster(ctx, clusterKey(cluster))
+	if err != nil {
+		r.recorder.Eventf(kcp, corev1.EventTypeWarning, "FailedEtcdAlarmRecovery", "Failed to recover from etcd NOSPACE alarms of cluster %s/%s: %v", cluster.Namespace, cluster.Name, err)
+		return
+	}
+	disarmed, err := workloadCluster.RecoverEtcdNoSpaceAlarms(ctx)
+	for _, alarm := range disarmed {
+		r.recorder.Eventf(kcp, corev1.EventTypeNormal, "EtcdAlarmDisarmed", "Defragmented etcd member on node %s and disarmed its %s alarm", alarm.NodeName, alarm.Type)
+	}
+	if err != nil {
+		r.recorder.Eventf(kcp, corev1.EventTypeWarning, "FailedEtcdAlarmRecovery", "Failed to recover from etcd NOSPACE alarms of cluster %s/%s: %v", cluster.Namespace, cluster.Name, err)
+	}
+}
+
+// reconcileEtcdDefragmentation defragments the stacked etcd members of the target cluster, one at a time, if requested
+// with the DefragmentEtcdAnnotation or if EtcdDefragmentationInterval has passed since the last defragmentation, or since
+// the KubeadmControlPlane was created if etcd was never defragmented. Nothing is done unless the control plane and etcd
+// are healthy.
+func (r *KubeadmControlPlaneReconciler) reconcileEtcdDefragmentation(ctx context.Context, cluster *clusterv1.Cluster, kcp *controlplanev1.KubeadmControlPlane) (ctrl.Result, error) {
+	if usesExternalEtcd(kcp) {
+		return ctrl.Result{}, nil
+	}
+	if _, ok := kcp.Annotations[controlplanev1.DefragmentEtcdAnnotation]; !ok {
+		if r.EtcdDefragmentationInterval <= 0 {
+			return ctrl.Result{}, nil
+		}
+		last := kcp.CreationTimestamp
+		if kcp.Status.LastEtcdDefragmentationTime != nil {
+			last = *kcp.Status.LastEtcdDefragmentationTime
+		}
+		if wait := time.Until(last.Add(r.EtcdDefragmentationInterval)); wait > 0 {
+			return ctrl.Result{RequeueAfter: wait}, nil
+		}
+	}
+
+	if result, err := r.checkHealth(ctx, cluster, kcp); err != nil {
+		r.recorder.Eventf(kcp, corev1.EventTypeWarning, "EtcdDefragmentationDelayed", "Waiting for the control plane of cluster %s/%s to be healthy to defragment etcd: %v", cluster.Namespace, cluster.Name, err)
+		return result, nil
+	}
+
+	workloadCluster, err := r.managementCluster.GetWorkloadCluster(ctx, clusterKey(cluster))
+	if err != nil {
+		return ctrl.Result{}, errors.Wrapf(err, "failed to get the workload cluster of cluster %s/%s", cluster.Namespace, cluster.Name)
+	}
+	defragmented, err := workloadCluster.DefragmentEtcd(ctx)
+	if len(defragmented) > 0 {
+		r.recorder.Eventf(kcp, corev1.EventTypeNormal, "EtcdDefragmented", "Defragmented etcd members on nodes %s", strings.Join(defragmented, ", "))
+	}
+	if err != nil {
+		r.recorder.Eventf(kcp, corev1.EventTypeWarning, "FailedEtcdDefragmentation", "Failed to defragment etcd of cluster %s/%s: %v", cluster.Namespace, cluster.Name, err)
+		return ctrl.Result{RequeueAfter: HealthCheckFailedRequeueAfter}, nil
+	}
+
+	now := metav1.Now()
+	kcp.Status.LastEtcdDefragmentationTime = &now
+	delete(kcp.Annotations, controlplanev1.DefragmentEtcdAnnotation)
+	if r.EtcdDefragmentationInterval > 0 {
+		return ctrl.Result{RequeueAfter: r.EtcdDefragmentationInterval}, nil
+	}
+	return ctrl.Result{}, nil
+}
+
+// scaleDownControlPlane deletes one of the given outdated Machines, or one of the owned Machines if none are given,
+// as picked by selectMachineForScaleDown.
+func (r *KubeadmControlPlaneReconciler) scaleDownControlPlane(ctx context.Context, cluster *clusterv1.Cluster, kcp *controlplanev1.KubeadmControlPlane, outdatedMachines []*clusterv1.Machine) (ctrl.Result, error) {
+	if result, err := r.checkHealth(ctx, cluster, kcp); err != nil {
+		return result, err
+	}
 
 	ownedMachines, err := r.managementCluster.GetMachinesForCluster(ctx, clusterKey(cluster), internal.OwnedControlPlaneMachines(kcp.Name))
 	if err != nil {
@@ -416,20 +712,70 @@ func (r *KubeadmControlPlaneReconciler) scaleDownControlPlane(ctx context.Contex
 		return ctrl.Result{RequeueAfter: DeleteRequeueAfter}, nil
 	}
 
-	machineToDelete, err := oldestMachine(ownedMachines)
+	candidates := ownedMachines
+	if len(outdatedMachines) > 0 {
+		candidates = outdatedMachines
+	}
+	machineToDelete, err := selectMachineForScaleDown(cluster, kcp, candidates)
 	if err != nil {
 		return ctrl.Result{}, errors.Wrap(err, "failed to pick control plane Machine to delete")
 	}
 
-	if err := r.Client.Delete(ctx, machineToDelete); err != nil && !apierrors.IsNotFound(err) {
-		return ctrl.Result{}, errors.Wrapf(err, "failed to delete control plane Machine %s/%s", machineToDelete.Namespace, machineToDelete.Name)
+	// Let the pre-delete lifecycle hooks of the Machine complete before removing it from the control plane.
+	waiting, err := r.waitForPreDeleteHooks(ctx, kcp, machineToDelete)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if waiting {
+		return ctrl.Result{RequeueAfter: LifecycleHookRequeueAfter}, nil
+	}
+
+	// Remove the etcd member of the Machine first, so it does not linger in the member list and count towards quorum.
+	// If the member is the leader, hand the leadership over beforehand to avoid an election.
+	// External etcd does not run on the control plane Machines, and Machines without a node run no etcd member.
+	if !usesExternalEtcd(kcp) && machineToDelete.Status.NodeRef != nil {
+		workloadCluster, err := r.managementCluster.GetWorkloadCluster(ctx, clusterKey(cluster))
+		if err != nil {
+			return ctrl.Result{}, errors.Wrapf(err, "failed to get the workload cluster of cluster %s/%s", cluster.Namespace, cluster.Name)
+		}
+		nodeName := machineToDelete.Status.NodeRef.Name
+		if err := workloadCluster.ForwardEtcdLeadership(ctx, nodeName); err != nil {
+			return ctrl.Result{}, errors.Wrapf(err, "failed to move etcd leadership away from control plane Machine %s/%s", machineToDelete.Namespace, machineToDelete.Name)
+		}
+		if err := workloadCluster.RemoveEtcdMemberForNode(ctx, nodeName); err != nil {
+			return ctrl.Result{}, errors.Wrapf(err, "failed to remove etcd member for control plane Machine %s/%s", machineToDelete.Namespace, machineToDelete.Name)
+		}
+	}
+
+	if err := r.deleteMachine(ctx, kcp, machineToDelete); err != nil {
+		return ctrl.Result{}, err
 	}
 
 	// Requeue the control plane, in case we are not done scaling down
 	return ctrl.Result{Requeue: true}, nil
 }
 
-func (r *KubeadmControlPlaneReconciler) cloneConfigsAndGenerateMachine(ctx context.Context, cluster *clusterv1.Cluster, kcp *controlplanev1.KubeadmControlPlane, bootstrapSpec *bootstrapv1.KubeadmConfigSpec) error {
+// deleteMachine deletes a control plane Machine, after setting its NodeDrainTimeout to the one of the control plane,
+// so that a timeout configured after the Machine was created still applies when its node is drained.
+func (r *KubeadmControlPlaneReconciler) deleteMachine(ctx context.Context, kcp *controlplanev1.KubeadmControlPlane, machine *clusterv1.Machine) error {
+	if !reflect.DeepEqual(machine.Spec.NodeDrainTimeout, kcp.Spec.NodeDrainTimeout) {
+		patchHelper, err := patch.NewHelper(machine, r.Client)
+		if err != nil {
+			return errors.Wrapf(err, "failed to configure the patch helper for Machine %s/%s", machine.Namespace, machine.Name)
+		}
+		machine.Spec.NodeDrainTimeout = kcp.Spec.NodeDrainTimeout
+		if err := patchHelper.Patch(ctx, machine); err != nil {
+			return errors.Wrapf(err, "failed to set the node drain timeout of control plane Machine %s/%s", machine.Namespace, machine.Name)
+		}
+	}
+
+	if err := r.Client.Delete(ctx, machine); err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to delete control plane Machine %s/%s", machine.Namespace, machine.Name)
+	}
+	return nil
+}
+
+func (r *KubeadmControlPlaneReconciler) cloneConfigsAndGenerateMachine(ctx context.Context, cluster *clusterv1.Cluster, kcp *controlplanev1.KubeadmControlPlane, bootstrapSpec *bootstrapv1.KubeadmConfigSpec, annotations map[string]string) error {
 	var errs []error
 
 	// Since the cloned resource should eventually have a controller ref for the Machine, we create an
@@ -463,7 +809,7 @@ func (r *KubeadmControlPlaneReconciler) cloneConfigsAndGenerateMachine(ctx conte
 
 	// Only proceed to generating the Machine if we haven't encountered an error
 	if len(errs) == 0 {
-		if err := r.generateMachine(ctx, kcp, cluster, infraRef, bootstrapRef); err != nil {
+		if err := r.generateMachine(ctx, kcp, cluster, infraRef, bootstrapRef, annotations); err != nil {
 			errs = append(errs, errors.Wrap(err, "failed to create Machine"))
 		}
 	}
@@ -545,20 +891,73 @@ func (r *KubeadmControlPlaneReconciler) failureDomainForScaleUp(ctx context.Cont
 		return nil, err
 	}
 	failureDomain := internal.PickFewest(cluster.Status.FailureDomains.FilterControlPlane(), ownedMachines)
+	if failureDomain == "" {
+		// None of the failure domains is suitable for control plane Machines.
+		return nil, nil
+	}
 	return &failureDomain, nil
 }
 
-func (r *KubeadmControlPlaneReconciler) generateMachine(ctx context.Context, kcp *controlplanev1.KubeadmControlPlane, cluster *clusterv1.Cluster, infraRef, bootstrapRef *corev1.ObjectReference) error {
+// selectMachineForScaleDown picks which of the given Machines to delete. Machines marked with the
+// DeleteMachineAnnotation are picked first; among the remaining candidates the pick follows the DeletePolicy of
+// the KubeadmControlPlane. The default FailureDomainBalance policy picks the oldest Machine in the control plane
+// failure domain with the most Machines, so that the remaining Machines stay spread across failure domains, or the
+// oldest Machine if none of them is in a control plane failure domain.
+func selectMachineForScaleDown(cluster *clusterv1.Cluster, kcp *controlplanev1.KubeadmControlPlane, machines []*clusterv1.Machine) (*clusterv1.Machine, error) {
+	if annotated := internal.FilterMachines(machines, internal.HasDeleteMachineAnnotation()); len(annotated) > 0 {
+		machines = annotated
+	}
+
+	switch kcp.Spec.DeletePolicy {
+	case controlplanev1.OldestDeletePolicy:
+		return oldestMachine(machines)
+	case controlplanev1.NewestDeletePolicy:
+		return newestMachine(machines)
+	case controlplanev1.UnhealthyFirstDeletePolicy:
+		if unhealthy := internal.FilterMachines(machines, internal.IsUnhealthy()); len(unhealthy) > 0 {
+			machines = unhealthy
+		}
+		return oldestMachine(machines)
+	default:
+		failureDomain := internal.PickMost(cluster.Status.FailureDomains.FilterControlPlane(), machines)
+		if inFailureDomain := internal.FilterMachines(machines, internal.InFailureDomain(failureDomain)); len(inFailureDomain) > 0 {
+			machines = inFailureDomain
+		}
+		return oldestMachine(machines)
+	}
+}
+
+// generateMachine creates a control plane Machine with the given annotations, which runs the pre-delete lifecycle
+// hooks of the KubeadmControlPlane.
+func (r *KubeadmControlPlaneReconciler) generateMachine(ctx context.Context, kcp *controlplanev1.KubeadmControlPlane, cluster *clusterv1.Cluster, infraRef, bootstrapRef *corev1.ObjectReference, annotations map[string]string) error {
 	fd, err := r.failureDomainForScaleUp(ctx, kcp, cluster)
 	if err != nil {
 		return err
 	}
 
+	// Record the ClusterConfiguration, since the bootstrap provider fills in the one of the KubeadmConfig
+	clusterConfiguration, err := json.Marshal(kcp.Spec.KubeadmConfigSpec.ClusterConfiguration)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal cluster configuration")
+	}
+	machineAnnotations := map[string]string{
+		controlplanev1.KubeadmClusterConfigurationAnnotation: string(clusterConfiguration),
+	}
+	if kcp.Spec.LifecycleHooks != nil {
+		for key, value := range lifecycleHookAnnotations(controlplanev1.PreDeleteHookAnnotationPrefix, kcp.Spec.LifecycleHooks.PreDelete) {
+			machineAnnotations[key] = value
+		}
+	}
+	for key, value := range annotations {
+		machineAnnotations[key] = value
+	}
+
 	machine := &clusterv1.Machine{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      names.SimpleNameGenerator.GenerateName(kcp.Name + "-"),
-			Namespace: kcp.Namespace,
-			Labels:    internal.ControlPlaneLabelsForClusterWithHash(cluster.Name, hash.Compute(&kcp.Spec)),
+			Name:        names.SimpleNameGenerator.GenerateName(kcp.Name + "-"),
+			Namespace:   kcp.Namespace,
+			Labels:      internal.ControlPlaneLabelsForClusterWithHash(cluster.Name, hash.Compute(&kcp.Spec)),
+			Annotations: machineAnnotations,
 			OwnerReferences: []metav1.OwnerReference{
 				*metav1.NewControllerRef(kcp, controlplanev1.GroupVersion.WithKind("KubeadmControlPlane")),
 			},
@@ -570,7 +969,8 @@ func (r *KubeadmControlPlaneReconciler) generateMachine(ctx context.Context, kcp
 			Bootstrap: clusterv1.Bootstrap{
 				ConfigRef: bootstrapRef,
 			},
-			FailureDomain: fd,
+			FailureDomain:    fd,
+			NodeDrainTimeout: kcp.Spec.NodeDrainTimeout,
 		},
 	}
 
@@ -581,6 +981,51 @@ func (r *KubeadmControlPlaneReconciler) generateMachine(ctx context.Context, kcp
 	return nil
 }
 
+// getMachineConfigs gets the KubeadmConfigs and infrastructure Machines of the given Machines, and the current
+// infrastructure template of the KubeadmControlPlane, to compare them with its spec. Objects that are not set or
+// not found are left out.
+func (r *KubeadmControlPlaneReconciler) getMachineConfigs(ctx context.Context, kcp *controlplanev1.KubeadmControlPlane, machines []*clusterv1.Machine) (internal.MachineConfigs, error) {
+	configs := internal.MachineConfigs{
+		KubeadmConfigs: map[string]*bootstrapv1.KubeadmConfig{},
+		InfraMachines:  map[string]*unstructured.Unstructured{},
+	}
+	if len(machines) == 0 {
+		return configs, nil
+	}
+
+	if kcp.Spec.InfrastructureTemplate.Name != "" {
+		infraTemplate, err := external.Get(ctx, r.Client, &kcp.Spec.InfrastructureTemplate, kcp.Namespace)
+		if err != nil && !apierrors.IsNotFound(errors.Cause(err)) {
+			return configs, err
+		}
+		configs.InfraTemplate = infraTemplate
+	}
+
+	for _, machine := range machines {
+		if ref := machine.Spec.Bootstrap.ConfigRef; ref != nil && ref.Kind == "KubeadmConfig" {
+			kubeadmConfig := &bootstrapv1.KubeadmConfig{}
+			err := r.Client.Get(ctx, types.NamespacedName{Namespace: machine.Namespace, Name: ref.Name}, kubeadmConfig)
+			if err != nil && !apierrors.IsNotFound(err) {
+				return configs, errors.Wrapf(err, "failed to get KubeadmConfig of control plane Machine %s/%s", machine.Namespace, machine.Name)
+			}
+			if err == nil {
+				configs.KubeadmConfigs[machine.Name] = kubeadmConfig
+			}
+		}
+
+		if machine.Spec.InfrastructureRef.Name != "" {
+			infraMachine, err := external.Get(ctx, r.Client, &machine.Spec.InfrastructureRef, machine.Namespace)
+			if err != nil && !apierrors.IsNotFound(errors.Cause(err)) {
+				return configs, err
+			}
+			if err == nil {
+				configs.InfraMachines[machine.Name] = infraMachine
+			}
+		}
+	}
+	return configs, nil
+}
+
 // reconcileDelete handles KubeadmControlPlane deletion.
 // The implementation does not take non-control plane workloads into
 // consideration. This may or may not change in the future. Please see
@@ -604,6 +1049,7 @@ func (r *KubeadmControlPlaneReconciler) reconcileDelete(ctx context.Context, clu
 	// If no control plane machines remain, remove the finalizer
 	if len(ownedMachines) == 0 {
 		controllerutil.RemoveFinalizer(kcp, controlplanev1.KubeadmControlPlaneFinalizer)
+		metrics.DeleteKubeadmControlPlane(types.NamespacedName{Namespace: kcp.Namespace, Name: kcp.Name})
 		return ctrl.Result{}, nil
 	}
 
@@ -641,6 +1087,11 @@ func (r *KubeadmControlPlaneReconciler) reconcileKubeconfig(ctx context.Context,
 					"could not find secret %q for Cluster %q in namespace %q, requeuing",
 					secret.ClusterCA, clusterName.Name, clusterName.Namespace)
 			}
+			if createErr == kubeconfig.ErrCAPrivateKeyNotFound {
+				return errors.Wrapf(&capierrors.RequeueAfterError{RequeueAfter: 30 * time.Second},
+					"secret %q for Cluster %q in namespace %q has no CA private key to sign a kubeconfig, waiting for secret %q to be provided",
+					secret.ClusterCA, clusterName.Name, clusterName.Namespace, secret.Kubeconfig)
+			}
 			return createErr
 		}
 	case err != nil:
@@ -650,6 +1101,23 @@ func (r *KubeadmControlPlaneReconciler) reconcileKubeconfig(ctx context.Context,
 	return nil
 }
 
+// caPurposesWithoutPrivateKey returns the purposes of the CAs that were provided with a certificate but no private
+// key, e.g. because they are managed outside of the management cluster. The etcd CA of external etcd is expected to
+// come without its key and is not reported.
+func caPurposesWithoutPrivateKey(certificates secret.Certificates) []string {
+	purposes := []string{}
+	for _, purpose := range []secret.Purpose{secret.ClusterCA, secret.EtcdCA, secret.FrontProxyCA} {
+		certificate := certificates.GetByPurpose(purpose)
+		if certificate == nil || certificate.KeyFile == "" || certificate.KeyPair == nil {
+			continue
+		}
+		if len(certificate.KeyPair.Key) == 0 {
+			purposes = append(purposes, string(purpose))
+		}
+	}
+	return purposes
+}
+
 func (r *KubeadmControlPlaneReconciler) reconcileExternalReference(ctx context.Context, cluster *clusterv1.Cluster, ref corev1.ObjectReference) error {
 	if !strings.HasSuffix(ref.Kind, external.TemplateSuffix) {
 		return nil
@@ -734,3 +1202,53 @@ func oldestMachine(machines []*clusterv1.Machine) (*clusterv1.Machine, error) {
 	sort.Sort(util.MachinesByCreationTimestamp(machines))
 	return machines[0], nil
 }
+
+func newestMachine(machines []*clusterv1.Machine) (*clusterv1.Machine, error) {
+	if len(machines) == 0 {
+		return &clusterv1.Machine{}, errors.New("no machines given")
+	}
+	sort.Sort(util.MachinesByCreationTimestamp(machines))
+	return machines[len(machines)-1], nil
+}
+
+// usesExternalEtcd reports whether the KubeadmControlPlane is configured with external etcd rather than stacked etcd.
+func usesExternalEtcd(kcp *controlplanev1.KubeadmControlPlane) bool {
+	clusterConfiguration := kcp.Spec.KubeadmConfigSpec.ClusterConfiguration
+	return clusterConfiguration != nil && clusterConfiguration.Etcd.External != nil
+}
+
+// maxSurge returns the number of control plane Machines that can be created above the desired number of replicas
+// during an upgrade. It defaults to 1 if the KubeadmControlPlane does not set a rolling update MaxSurge.
+func maxSurge(kcp *controlplanev1.KubeadmControlPlane) int {
+	strategy := kcp.Spec.RolloutStrategy
+	if strategy == nil || strategy.RollingUpdate == nil || strategy.RollingUpdate.MaxSurge == nil {
+		return 1
+	}
+	return strategy.RollingUpdate.MaxSurge.IntValue()
+}
+
+// setReplicaConditions sets the MachinesReady and Resized conditions from the replica counts in the status.
+func setReplicaConditions(kcp *controlplanev1.KubeadmControlPlane) {
+	status := &kcp.Status
+	if status.ReadyReplicas == status.Replicas {
+		conditions.MarkTrue(kcp, controlplanev1.MachinesReadyCondition)
+	} else {
+		conditions.MarkFalse(kcp, controlplanev1.MachinesReadyCondition, controlplanev1.MachinesNotReadyReason, clusterv1.ConditionSeverityWarning,
+			"%d of %d Machines are ready", status.ReadyReplicas, status.Replicas)
+	}
+
+	if kcp.Spec.Replicas == nil {
+		return
+	}
+	desired := *kcp.Spec.Replicas
+	switch {
+	case status.Replicas < desired:
+		conditions.MarkFalse(kcp, controlplanev1.ResizedCondition, controlplanev1.ScalingUpReason, clusterv1.ConditionSeverityWarning,
+			"scaling up from %d to %d replicas", status.Replicas, desired)
+	case status.Replicas > desired:
+		conditions.MarkFalse(kcp, controlplanev1.ResizedCondition, controlplanev1.ScalingDownReason, clusterv1.ConditionSeverityWarning,
+			"scaling down from %d to %d replicas", status.Replicas, desired)
+	default:
+		conditions.MarkTrue(kcp, controlplanev1.ResizedCondition)
+	}
+}
@@ -20,14 +20,17 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	. "github.com/onsi/gomega"
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/klog/klogr"
@@ -45,7 +48,12 @@ import (
 	fakeremote "sigs.k8s.io/cluster-api/controllers/remote/fake"
 	controlplanev1 "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
 	"sigs.k8s.io/cluster-api/controlplane/kubeadm/internal"
+	"sigs.k8s.io/cluster-api/controlplane/kubeadm/internal/etcd"
+	fakecluster "sigs.k8s.io/cluster-api/controlplane/kubeadm/internal/fake"
 	"sigs.k8s.io/cluster-api/controlplane/kubeadm/internal/hash"
+	capierrors "sigs.k8s.io/cluster-api/errors"
+	"sigs.k8s.io/cluster-api/util/certs"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/kubeconfig"
 	"sigs.k8s.io/cluster-api/util/secret"
 )
@@ -316,6 +324,64 @@ func TestKubeadmControlPlaneReconciler_reconcileKubeconfig(t *testing.T) {
 	g.Expect(kubeconfigSecret.Labels).To(HaveKeyWithValue(clusterv1.ClusterLabelName, clusterName.Name))
 }
 
+func TestKubeadmControlPlaneReconciler_reconcileKubeconfigWithoutCAKey(t *testing.T) {
+	g := NewWithT(t)
+
+	kcp := &controlplanev1.KubeadmControlPlane{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foo",
+			Namespace: "test",
+		},
+	}
+	clusterName := types.NamespacedName{Namespace: "test", Name: "foo"}
+	endpoint := clusterv1.APIEndpoint{Host: "test.local", Port: 8443}
+
+	clusterCerts := secret.NewCertificatesForInitialControlPlane(&kubeadmv1.ClusterConfiguration{})
+	g.Expect(clusterCerts.Generate()).To(Succeed())
+	existingCACertSecret := clusterCerts.GetByPurpose(secret.ClusterCA).AsSecret(clusterName, *metav1.NewControllerRef(kcp, controlplanev1.GroupVersion.WithKind("KubeadmControlPlane")))
+	delete(existingCACertSecret.Data, secret.TLSKeyDataName)
+
+	g.Expect(clusterv1.AddToScheme(scheme.Scheme)).To(Succeed())
+	g.Expect(controlplanev1.AddToScheme(scheme.Scheme)).To(Succeed())
+	r := &KubeadmControlPlaneReconciler{
+		Client: fake.NewFakeClientWithScheme(scheme.Scheme, kcp, existingCACertSecret),
+		Log:    log.Log,
+	}
+	err := r.reconcileKubeconfig(context.Background(), clusterName, endpoint, kcp)
+	g.Expect(err).To(HaveOccurred())
+	requeueErr, ok := errors.Cause(err).(capierrors.HasRequeueAfterError)
+	g.Expect(ok).To(BeTrue())
+	g.Expect(requeueErr.GetRequeueAfter()).To(Equal(30 * time.Second))
+
+	kubeconfigSecret := &corev1.Secret{}
+	secretName := types.NamespacedName{Namespace: "test", Name: secret.Name(clusterName.Name, secret.Kubeconfig)}
+	g.Expect(apierrors.IsNotFound(r.Client.Get(context.Background(), secretName, kubeconfigSecret))).To(BeTrue())
+}
+
+func TestCAPurposesWithoutPrivateKey(t *testing.T) {
+	g := NewWithT(t)
+
+	certificates := secret.NewCertificatesForInitialControlPlane(&kubeadmv1.ClusterConfiguration{})
+	g.Expect(certificates.Generate()).To(Succeed())
+	g.Expect(caPurposesWithoutPrivateKey(certificates)).To(BeEmpty())
+
+	certificates.GetByPurpose(secret.ClusterCA).KeyPair.Key = []byte{}
+	certificates.GetByPurpose(secret.EtcdCA).KeyPair.Key = []byte{}
+	g.Expect(caPurposesWithoutPrivateKey(certificates)).To(ConsistOf(string(secret.ClusterCA), string(secret.EtcdCA)))
+
+	// The etcd CA key of external etcd is never known to Cluster API.
+	externalEtcd := secret.NewCertificatesForInitialControlPlane(&kubeadmv1.ClusterConfiguration{
+		Etcd: kubeadmv1.Etcd{External: &kubeadmv1.ExternalEtcd{CAFile: "/etc/kubernetes/pki/etcd/ca.crt"}},
+	})
+	for _, certificate := range externalEtcd {
+		certificate.KeyPair = &certs.KeyPair{Cert: []byte("crt")}
+		if certificate.KeyFile != "" {
+			certificate.KeyPair.Key = []byte("key")
+		}
+	}
+	g.Expect(caPurposesWithoutPrivateKey(externalEtcd)).To(BeEmpty())
+}
+
 func TestKubeadmControlPlaneReconciler_initializeControlPlane(t *testing.T) {
 	g := NewWithT(t)
 
@@ -475,6 +541,55 @@ func TestReconcileNoCluster(t *testing.T) {
 	g.Expect(machineList.Items).To(BeEmpty())
 }
 
+func TestReconcilePaused(t *testing.T) {
+	g := NewWithT(t)
+
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foo",
+			Namespace: "test",
+		},
+		Spec: clusterv1.ClusterSpec{
+			Paused: true,
+		},
+	}
+
+	kcp := &controlplanev1.KubeadmControlPlane{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: cluster.Namespace,
+			Name:      "foo",
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					Kind:       "Cluster",
+					APIVersion: clusterv1.GroupVersion.String(),
+					Name:       cluster.Name,
+				},
+			},
+		},
+	}
+	kcp.Default()
+	g.Expect(kcp.ValidateCreate()).To(Succeed())
+
+	g.Expect(clusterv1.AddToScheme(scheme.Scheme)).To(Succeed())
+	g.Expect(controlplanev1.AddToScheme(scheme.Scheme)).To(Succeed())
+	fakeClient := fake.NewFakeClientWithScheme(scheme.Scheme, kcp, cluster)
+
+	r := &KubeadmControlPlaneReconciler{
+		Client: fakeClient,
+		Log:    log.Log,
+	}
+
+	_, err := r.Reconcile(ctrl.Request{NamespacedName: types.NamespacedName{Name: kcp.Name, Namespace: kcp.Namespace}})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(r.Client.Get(context.Background(), types.NamespacedName{Name: kcp.Name, Namespace: kcp.Namespace}, kcp)).To(Succeed())
+
+	paused := conditions.Get(kcp, clusterv1.PausedCondition)
+	g.Expect(paused).NotTo(BeNil())
+	g.Expect(paused.Status).To(Equal(corev1.ConditionTrue))
+	g.Expect(paused.Reason).To(Equal(clusterv1.ClusterPausedReason))
+	g.Expect(kcp.Finalizers).To(BeEmpty())
+}
+
 func TestReconcileClusterNoEndpoints(t *testing.T) {
 	g := NewWithT(t)
 
@@ -526,6 +641,14 @@ func TestReconcileClusterNoEndpoints(t *testing.T) {
 
 	g.Expect(kcp.Status.Selector).NotTo(BeEmpty())
 
+	// The Ready condition summarizes the conditions of the control plane, which is still to be scaled up.
+	g.Expect(conditions.IsTrue(kcp, controlplanev1.CertificatesAvailableCondition)).To(BeTrue())
+	ready := conditions.Get(kcp, clusterv1.ReadyCondition)
+	g.Expect(ready).NotTo(BeNil())
+	g.Expect(ready.Status).To(Equal(corev1.ConditionFalse))
+	g.Expect(ready.Reason).To(Equal(controlplanev1.ScalingUpReason))
+	g.Expect(ready.Severity).To(Equal(clusterv1.ConditionSeverityWarning))
+
 	_, err = secret.GetFromNamespacedName(context.Background(), fakeClient, client.ObjectKey{Namespace: "test", Name: "foo"}, secret.ClusterCA)
 	g.Expect(err).NotTo(HaveOccurred())
 
@@ -674,7 +797,8 @@ func TestKubeadmControlPlaneReconciler_generateMachine(t *testing.T) {
 			Namespace: cluster.Namespace,
 		},
 		Spec: controlplanev1.KubeadmControlPlaneSpec{
-			Version: "my-version",
+			Version:          "my-version",
+			NodeDrainTimeout: &metav1.Duration{Duration: 10 * time.Minute},
 		},
 	}
 
@@ -697,13 +821,14 @@ func TestKubeadmControlPlaneReconciler_generateMachine(t *testing.T) {
 			ConfigRef: bootstrapRef.DeepCopy(),
 		},
 		InfrastructureRef: *infraRef.DeepCopy(),
+		NodeDrainTimeout:  kcp.Spec.NodeDrainTimeout,
 	}
 	r := &KubeadmControlPlaneReconciler{
 		Client:            fakeClient,
 		Log:               log.Log,
-		managementCluster: &internal.ManagementCluster{Client: fakeClient},
+		managementCluster: &internal.Management{Client: fakeClient},
 	}
-	g.Expect(r.generateMachine(context.Background(), kcp, cluster, infraRef, bootstrapRef)).To(Succeed())
+	g.Expect(r.generateMachine(context.Background(), kcp, cluster, infraRef, bootstrapRef, nil)).To(Succeed())
 
 	machineList := &clusterv1.MachineList{}
 	g.Expect(fakeClient.List(context.Background(), machineList, client.InNamespace(cluster.Namespace))).To(Succeed())
@@ -713,6 +838,7 @@ func TestKubeadmControlPlaneReconciler_generateMachine(t *testing.T) {
 	g.Expect(machine.Name).To(HavePrefix(kcp.Name))
 	g.Expect(machine.Namespace).To(Equal(kcp.Namespace))
 	g.Expect(machine.Labels).To(Equal(internal.ControlPlaneLabelsForClusterWithHash(cluster.Name, hash.Compute(&kcp.Spec))))
+	g.Expect(machine.Annotations).To(HaveKeyWithValue(controlplanev1.KubeadmClusterConfigurationAnnotation, "null"))
 	g.Expect(machine.OwnerReferences).To(HaveLen(1))
 	g.Expect(machine.OwnerReferences).To(ContainElement(*metav1.NewControllerRef(kcp, controlplanev1.GroupVersion.WithKind("KubeadmControlPlane"))))
 	g.Expect(machine.Spec).To(Equal(expectedMachineSpec))
@@ -852,7 +978,7 @@ func TestKubeadmControlPlaneReconciler_updateStatusNoMachines(t *testing.T) {
 		Log:                log.Log,
 		remoteClientGetter: fakeremote.NewClusterClient,
 		scheme:             scheme.Scheme,
-		managementCluster:  &internal.ManagementCluster{Client: fakeClient},
+		managementCluster:  &internal.Management{Client: fakeClient},
 	}
 
 	g.Expect(r.updateStatus(context.Background(), kcp, cluster)).To(Succeed())
@@ -941,7 +1067,7 @@ func TestKubeadmControlPlaneReconciler_updateStatusAllMachinesNotReady(t *testin
 		Log:                log.Log,
 		remoteClientGetter: fakeremote.NewClusterClient,
 		scheme:             scheme.Scheme,
-		managementCluster:  &internal.ManagementCluster{Client: fakeClient},
+		managementCluster:  &internal.Management{Client: fakeClient},
 	}
 
 	g.Expect(r.updateStatus(context.Background(), kcp, cluster)).To(Succeed())
@@ -953,6 +1079,9 @@ func TestKubeadmControlPlaneReconciler_updateStatusAllMachinesNotReady(t *testin
 	g.Expect(kcp.Status.FailureReason).To(BeEquivalentTo(""))
 	g.Expect(kcp.Status.Initialized).To(BeFalse())
 	g.Expect(kcp.Status.Ready).To(BeFalse())
+	g.Expect(conditions.Get(kcp, controlplanev1.MachinesReadyCondition).Status).To(Equal(corev1.ConditionFalse))
+	g.Expect(conditions.Get(kcp, controlplanev1.MachinesReadyCondition).Reason).To(Equal(controlplanev1.MachinesNotReadyReason))
+	g.Expect(conditions.Get(kcp, controlplanev1.ResizedCondition).Reason).To(Equal(controlplanev1.ScalingDownReason))
 }
 
 func TestKubeadmControlPlaneReconciler_updateStatusAllMachinesReady(t *testing.T) {
@@ -992,7 +1121,7 @@ func TestKubeadmControlPlaneReconciler_updateStatusAllMachinesReady(t *testing.T
 		Log:                log.Log,
 		remoteClientGetter: fakeremote.NewClusterClient,
 		scheme:             scheme.Scheme,
-		managementCluster:  &internal.ManagementCluster{Client: fakeClient},
+		managementCluster:  &internal.Management{Client: fakeClient},
 	}
 
 	g.Expect(r.updateStatus(context.Background(), kcp, cluster)).To(Succeed())
@@ -1003,6 +1132,7 @@ func TestKubeadmControlPlaneReconciler_updateStatusAllMachinesReady(t *testing.T
 	g.Expect(kcp.Status.FailureMessage).To(BeNil())
 	g.Expect(kcp.Status.FailureReason).To(BeEquivalentTo(""))
 	g.Expect(kcp.Status.Initialized).To(BeTrue())
+	g.Expect(conditions.Get(kcp, controlplanev1.MachinesReadyCondition).Status).To(Equal(corev1.ConditionTrue))
 
 	// TODO: will need to be updated once we start handling Ready
 	g.Expect(kcp.Status.Ready).To(BeFalse())
@@ -1047,7 +1177,7 @@ func TestKubeadmControlPlaneReconciler_updateStatusMachinesReadyMixed(t *testing
 		Log:                log.Log,
 		remoteClientGetter: fakeremote.NewClusterClient,
 		scheme:             scheme.Scheme,
-		managementCluster:  &internal.ManagementCluster{Client: fakeClient},
+		managementCluster:  &internal.Management{Client: fakeClient},
 	}
 
 	g.Expect(r.updateStatus(context.Background(), kcp, cluster)).To(Succeed())
@@ -1126,7 +1256,7 @@ func TestCloneConfigsAndGenerateMachine(t *testing.T) {
 	bootstrapSpec := &bootstrapv1.KubeadmConfigSpec{
 		JoinConfiguration: &kubeadmv1.JoinConfiguration{},
 	}
-	g.Expect(r.cloneConfigsAndGenerateMachine(context.Background(), cluster, kcp, bootstrapSpec)).To(Succeed())
+	g.Expect(r.cloneConfigsAndGenerateMachine(context.Background(), cluster, kcp, bootstrapSpec, nil)).To(Succeed())
 
 	machineList := &clusterv1.MachineList{}
 	g.Expect(fakeClient.List(context.Background(), machineList, client.InNamespace(cluster.Namespace))).To(Succeed())
@@ -1232,7 +1362,7 @@ func TestKubeadmControlPlaneReconciler_reconcileDelete(t *testing.T) {
 
 		r := &KubeadmControlPlaneReconciler{
 			Client:            fakeClient,
-			managementCluster: &internal.ManagementCluster{Client: fakeClient},
+			managementCluster: &internal.Management{Client: fakeClient},
 		}
 
 		result, err := r.reconcileDelete(context.Background(), cluster, kcp, log.Log)
@@ -1277,7 +1407,7 @@ func TestKubeadmControlPlaneReconciler_reconcileDelete(t *testing.T) {
 
 		r := &KubeadmControlPlaneReconciler{
 			Client:            fakeClient,
-			managementCluster: &internal.ManagementCluster{Client: fakeClient},
+			managementCluster: &internal.Management{Client: fakeClient},
 		}
 
 		result, err := r.reconcileDelete(context.Background(), cluster, kcp, log.Log)
@@ -1304,7 +1434,7 @@ func TestKubeadmControlPlaneReconciler_reconcileDelete(t *testing.T) {
 
 		r := &KubeadmControlPlaneReconciler{
 			Client:            fakeClient,
-			managementCluster: &internal.ManagementCluster{Client: fakeClient},
+			managementCluster: &internal.Management{Client: fakeClient},
 		}
 
 		result, err := r.reconcileDelete(context.Background(), cluster, kcp, log.Log)
@@ -1315,30 +1445,6 @@ func TestKubeadmControlPlaneReconciler_reconcileDelete(t *testing.T) {
 
 }
 
-type fakeManagementCluster struct {
-	ControlPlaneHealthy bool
-	EtcdHealthy         bool
-	Machines            []*clusterv1.Machine
-}
-
-func (f *fakeManagementCluster) GetMachinesForCluster(ctx context.Context, cluster types.NamespacedName, filters ...func(machine *clusterv1.Machine) bool) ([]*clusterv1.Machine, error) {
-	return f.Machines, nil
-}
-
-func (f *fakeManagementCluster) TargetClusterControlPlaneIsHealthy(ctx context.Context, clusterKey types.NamespacedName, controlPlaneName string) error {
-	if !f.ControlPlaneHealthy {
-		return errors.New("control plane is not healthy")
-	}
-	return nil
-}
-
-func (f *fakeManagementCluster) TargetClusterEtcdIsHealthy(ctx context.Context, clusterKey types.NamespacedName, controlPlaneName string) error {
-	if !f.EtcdHealthy {
-		return errors.New("etcd is not healthy")
-	}
-	return nil
-}
-
 func TestKubeadmControlPlaneReconciler_scaleUpControlPlane(t *testing.T) {
 	t.Run("creates a control plane Machine if health checks pass", func(t *testing.T) {
 		g := NewWithT(t)
@@ -1349,10 +1455,11 @@ func TestKubeadmControlPlaneReconciler_scaleUpControlPlane(t *testing.T) {
 		cluster, kcp, genericMachineTemplate := createClusterWithControlPlane()
 		g.Expect(fakeClient.Create(context.Background(), genericMachineTemplate)).To(Succeed())
 
-		fmc := &fakeManagementCluster{
+		fmc := &fakecluster.ManagementCluster{
 			Machines:            []*clusterv1.Machine{},
 			ControlPlaneHealthy: true,
 			EtcdHealthy:         true,
+			Workload:            &fakecluster.WorkloadCluster{},
 		}
 
 		for i := 0; i < 2; i++ {
@@ -1366,7 +1473,7 @@ func TestKubeadmControlPlaneReconciler_scaleUpControlPlane(t *testing.T) {
 			managementCluster: fmc,
 		}
 
-		result, err := r.scaleUpControlPlane(context.Background(), cluster, kcp)
+		result, err := r.scaleUpControlPlane(context.Background(), cluster, kcp, nil)
 		g.Expect(result).To(Equal(ctrl.Result{Requeue: true}))
 		g.Expect(err).ToNot(HaveOccurred())
 
@@ -1377,23 +1484,30 @@ func TestKubeadmControlPlaneReconciler_scaleUpControlPlane(t *testing.T) {
 	t.Run("does not create a control plane Machine if any health check fails", func(t *testing.T) {
 		g := NewWithT(t)
 
-		fmc := &fakeManagementCluster{}
+		fmc := &fakecluster.ManagementCluster{}
 
 		r := &KubeadmControlPlaneReconciler{
 			managementCluster: fmc,
 		}
 
+		kcp := &controlplanev1.KubeadmControlPlane{}
+
 		fmc.ControlPlaneHealthy = true
 		fmc.EtcdHealthy = false
-		result, err := r.scaleUpControlPlane(context.Background(), &clusterv1.Cluster{}, &controlplanev1.KubeadmControlPlane{})
+		result, err := r.scaleUpControlPlane(context.Background(), &clusterv1.Cluster{}, kcp, nil)
 		g.Expect(result).To(Equal(ctrl.Result{RequeueAfter: HealthCheckFailedRequeueAfter}))
 		g.Expect(err).To(HaveOccurred())
+		g.Expect(conditions.Get(kcp, controlplanev1.ControlPlaneComponentsHealthyCondition).Status).To(Equal(corev1.ConditionTrue))
+		g.Expect(conditions.Get(kcp, controlplanev1.EtcdClusterHealthyCondition).Status).To(Equal(corev1.ConditionFalse))
+		g.Expect(conditions.Get(kcp, controlplanev1.EtcdClusterHealthyCondition).Message).To(Equal("etcd is not healthy"))
 
 		fmc.ControlPlaneHealthy = false
 		fmc.EtcdHealthy = true
-		result, err = r.scaleUpControlPlane(context.Background(), &clusterv1.Cluster{}, &controlplanev1.KubeadmControlPlane{})
+		result, err = r.scaleUpControlPlane(context.Background(), &clusterv1.Cluster{}, kcp, nil)
 		g.Expect(result).To(Equal(ctrl.Result{RequeueAfter: HealthCheckFailedRequeueAfter}))
 		g.Expect(err).To(HaveOccurred())
+		g.Expect(conditions.Get(kcp, controlplanev1.ControlPlaneComponentsHealthyCondition).Status).To(Equal(corev1.ConditionFalse))
+		g.Expect(conditions.Get(kcp, controlplanev1.ControlPlaneComponentsHealthyCondition).Reason).To(Equal(controlplanev1.ControlPlaneComponentsUnhealthyReason))
 
 	})
 }
@@ -1408,10 +1522,11 @@ func TestKubeadmControlPlaneReconciler_scaleDownControlPlane(t *testing.T) {
 		cluster, kcp, genericMachineTemplate := createClusterWithControlPlane()
 		g.Expect(fakeClient.Create(context.Background(), genericMachineTemplate)).To(Succeed())
 
-		fmc := &fakeManagementCluster{
+		fmc := &fakecluster.ManagementCluster{
 			Machines:            []*clusterv1.Machine{},
 			ControlPlaneHealthy: true,
 			EtcdHealthy:         true,
+			Workload:            &fakecluster.WorkloadCluster{},
 		}
 
 		for i := 0; i < 2; i++ {
@@ -1427,13 +1542,51 @@ func TestKubeadmControlPlaneReconciler_scaleDownControlPlane(t *testing.T) {
 
 		fmc.ControlPlaneHealthy = true
 		fmc.EtcdHealthy = true
-		result, err := r.scaleDownControlPlane(context.Background(), &clusterv1.Cluster{}, &controlplanev1.KubeadmControlPlane{})
+		result, err := r.scaleDownControlPlane(context.Background(), cluster, kcp, nil)
 		g.Expect(err).ToNot(HaveOccurred())
 		g.Expect(result).To(Equal(ctrl.Result{Requeue: true}))
 
 		controlPlaneMachines := clusterv1.MachineList{}
 		g.Expect(fakeClient.List(context.Background(), &controlPlaneMachines)).To(Succeed())
 		g.Expect(controlPlaneMachines.Items).To(HaveLen(1))
+		g.Expect(fmc.Workload.RemovedEtcdMembers).To(HaveLen(1))
+		g.Expect(fmc.Workload.RemovedEtcdMembers).NotTo(ContainElement(controlPlaneMachines.Items[0].Name))
+		g.Expect(fmc.Workload.EtcdLeaderForwards).To(Equal(fmc.Workload.RemovedEtcdMembers))
+	})
+	t.Run("sets the node drain timeout of the control plane on the deleted Machine", func(t *testing.T) {
+		g := NewWithT(t)
+
+		fakeClient, err := fakeClient()
+		g.Expect(err).NotTo(HaveOccurred())
+
+		cluster, kcp, genericMachineTemplate := createClusterWithControlPlane()
+		g.Expect(fakeClient.Create(context.Background(), genericMachineTemplate)).To(Succeed())
+		kcp.Spec.DeletePolicy = controlplanev1.OldestDeletePolicy
+		kcp.Spec.NodeDrainTimeout = &metav1.Duration{Duration: 10 * time.Minute}
+
+		fmc := &fakecluster.ManagementCluster{
+			Machines:            []*clusterv1.Machine{},
+			ControlPlaneHealthy: true,
+			EtcdHealthy:         true,
+			Workload:            &fakecluster.WorkloadCluster{},
+		}
+		for i := 0; i < 2; i++ {
+			m, _ := createMachineNodePair(fmt.Sprintf("test-%d", i), cluster, kcp, true)
+			g.Expect(fakeClient.Create(context.Background(), m)).To(Succeed())
+			fmc.Machines = append(fmc.Machines, m)
+		}
+
+		r := &KubeadmControlPlaneReconciler{
+			Client:            fakeClient,
+			managementCluster: fmc,
+		}
+
+		result, err := r.scaleDownControlPlane(context.Background(), cluster, kcp, nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(result).To(Equal(ctrl.Result{Requeue: true}))
+		g.Expect(fmc.Workload.RemovedEtcdMembers).To(Equal([]string{"test-0"}))
+		g.Expect(fmc.Machines[0].Spec.NodeDrainTimeout).To(Equal(kcp.Spec.NodeDrainTimeout))
+		g.Expect(fmc.Machines[1].Spec.NodeDrainTimeout).To(BeNil())
 	})
 	t.Run("does not delete a control plane Machine if health checks fail", func(t *testing.T) {
 		g := NewWithT(t)
@@ -1444,10 +1597,11 @@ func TestKubeadmControlPlaneReconciler_scaleDownControlPlane(t *testing.T) {
 		cluster, kcp, genericMachineTemplate := createClusterWithControlPlane()
 		g.Expect(fakeClient.Create(context.Background(), genericMachineTemplate)).To(Succeed())
 
-		fmc := &fakeManagementCluster{
+		fmc := &fakecluster.ManagementCluster{
 			Machines:            []*clusterv1.Machine{},
 			ControlPlaneHealthy: true,
 			EtcdHealthy:         true,
+			Workload:            &fakecluster.WorkloadCluster{},
 		}
 
 		for i := 0; i < 2; i++ {
@@ -1465,7 +1619,7 @@ func TestKubeadmControlPlaneReconciler_scaleDownControlPlane(t *testing.T) {
 
 		fmc.ControlPlaneHealthy = false
 		fmc.EtcdHealthy = true
-		result, err := r.scaleDownControlPlane(context.Background(), &clusterv1.Cluster{}, &controlplanev1.KubeadmControlPlane{})
+		result, err := r.scaleDownControlPlane(context.Background(), &clusterv1.Cluster{}, &controlplanev1.KubeadmControlPlane{}, nil)
 		g.Expect(err).To(HaveOccurred())
 		g.Expect(result).To(Equal(ctrl.Result{RequeueAfter: HealthCheckFailedRequeueAfter}))
 		g.Expect(fakeClient.List(context.Background(), &controlPlaneMachines)).To(Succeed())
@@ -1473,10 +1627,529 @@ func TestKubeadmControlPlaneReconciler_scaleDownControlPlane(t *testing.T) {
 
 		fmc.ControlPlaneHealthy = true
 		fmc.EtcdHealthy = false
-		result, err = r.scaleDownControlPlane(context.Background(), &clusterv1.Cluster{}, &controlplanev1.KubeadmControlPlane{})
+		result, err = r.scaleDownControlPlane(context.Background(), &clusterv1.Cluster{}, &controlplanev1.KubeadmControlPlane{}, nil)
 		g.Expect(err).To(HaveOccurred())
 		g.Expect(result).To(Equal(ctrl.Result{RequeueAfter: HealthCheckFailedRequeueAfter}))
 		g.Expect(fakeClient.List(context.Background(), &controlPlaneMachines)).To(Succeed())
 		g.Expect(controlPlaneMachines.Items).To(HaveLen(2))
+		g.Expect(fmc.Workload.RemovedEtcdMembers).To(BeEmpty())
+		g.Expect(fmc.Workload.EtcdLeaderForwards).To(BeEmpty())
 	})
 }
+
+func TestKubeadmControlPlaneReconciler_upgradeControlPlane(t *testing.T) {
+	tests := []struct {
+		name             string
+		maxSurge         *intstr.IntOrString
+		existingMachines int
+		expectedMachines int
+	}{
+		{
+			name:             "creates the replacement Machine first by default",
+			existingMachines: 3,
+			expectedMachines: 4,
+		},
+		{
+			name:             "deletes an outdated Machine once the replacement exists",
+			existingMachines: 4,
+			expectedMachines: 3,
+		},
+		{
+			name:             "deletes an outdated Machine first when MaxSurge is 0",
+			maxSurge:         &intstr.IntOrString{Type: intstr.Int, IntVal: 0},
+			existingMachines: 3,
+			expectedMachines: 2,
+		},
+		{
+			name:             "creates the replacement Machine once the outdated one is gone when MaxSurge is 0",
+			maxSurge:         &intstr.IntOrString{Type: intstr.Int, IntVal: 0},
+			existingMachines: 2,
+			expectedMachines: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			fakeClient, err := fakeClient()
+			g.Expect(err).NotTo(HaveOccurred())
+
+			cluster, kcp, genericMachineTemplate := createClusterWithControlPlane()
+			g.Expect(fakeClient.Create(context.Background(), genericMachineTemplate)).To(Succeed())
+			kcp.Spec.Replicas = utilpointer.Int32Ptr(3)
+			kcp.Spec.KubeadmConfigSpec.ClusterConfiguration = &kubeadmv1.ClusterConfiguration{
+				DNS: kubeadmv1.DNS{ImageMeta: kubeadmv1.ImageMeta{ImageTag: "1.6.7"}},
+			}
+			if tt.maxSurge != nil {
+				kcp.Spec.RolloutStrategy = &controlplanev1.RolloutStrategy{
+					Type:          controlplanev1.RollingUpdateStrategyType,
+					RollingUpdate: &controlplanev1.RollingUpdate{MaxSurge: tt.maxSurge},
+				}
+			}
+
+			fmc := &fakecluster.ManagementCluster{
+				Machines:            []*clusterv1.Machine{},
+				ControlPlaneHealthy: true,
+				EtcdHealthy:         true,
+				Workload:            &fakecluster.WorkloadCluster{},
+			}
+			for i := 0; i < tt.existingMachines; i++ {
+				m, _ := createMachineNodePair(fmt.Sprintf("test-%d", i), cluster, kcp, true)
+				g.Expect(fakeClient.Create(context.Background(), m)).To(Succeed())
+				fmc.Machines = append(fmc.Machines, m)
+			}
+
+			r := &KubeadmControlPlaneReconciler{
+				Client:            fakeClient,
+				managementCluster: fmc,
+			}
+
+			result, err := r.upgradeControlPlane(context.Background(), cluster, kcp, fmc.Machines, fmc.Machines)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(result).To(Equal(ctrl.Result{Requeue: true}))
+
+			controlPlaneMachines := clusterv1.MachineList{}
+			g.Expect(fakeClient.List(context.Background(), &controlPlaneMachines)).To(Succeed())
+			g.Expect(controlPlaneMachines.Items).To(HaveLen(tt.expectedMachines))
+			g.Expect(fmc.Workload.KubeadmConfigMapVersion).To(Equal(kcp.Spec.Version))
+			g.Expect(fmc.Workload.CoreDNSImageTag).To(Equal("1.6.7"))
+		})
+	}
+}
+
+func TestKubeadmControlPlaneReconciler_reconcileKubeProxy(t *testing.T) {
+	tests := []struct {
+		name            string
+		machineVersions []string
+		skip            bool
+		expectedVersion string
+	}{
+		{
+			name:            "upgrades kube-proxy once every Machine runs the new version",
+			machineVersions: []string{"v1.17.2", "v1.17.2", "1.17.2"},
+			expectedVersion: "v1.17.2",
+		},
+		{
+			name:            "waits for every Machine to run the new version",
+			machineVersions: []string{"v1.17.2", "v1.17.2", "v1.16.1"},
+		},
+		{
+			name: "waits for the first Machine",
+		},
+		{
+			name:            "does not upgrade kube-proxy if the KubeadmControlPlane opted out",
+			machineVersions: []string{"v1.17.2", "v1.17.2", "v1.17.2"},
+			skip:            true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			cluster, kcp, _ := createClusterWithControlPlane()
+			kcp.Spec.Version = "v1.17.2"
+			if tt.skip {
+				kcp.Annotations = map[string]string{controlplanev1.SkipKubeProxyAnnotation: ""}
+			}
+
+			fmc := &fakecluster.ManagementCluster{Workload: &fakecluster.WorkloadCluster{}}
+			for i, version := range tt.machineVersions {
+				m, _ := createMachineNodePair(fmt.Sprintf("test-%d", i), cluster, kcp, true)
+				m.Spec.Version = utilpointer.StringPtr(version)
+				fmc.Machines = append(fmc.Machines, m)
+			}
+
+			r := &KubeadmControlPlaneReconciler{managementCluster: fmc}
+			g.Expect(r.reconcileKubeProxy(context.Background(), cluster, kcp, fmc.Machines)).To(Succeed())
+			g.Expect(fmc.Workload.KubeProxyVersion).To(Equal(tt.expectedVersion))
+		})
+	}
+}
+
+func TestKubeadmControlPlaneReconciler_checkHealthRecoversEtcdNoSpaceAlarms(t *testing.T) {
+	tests := []struct {
+		name            string
+		recover         bool
+		externalEtcd    bool
+		expectedEvent   string
+		expectRecovered bool
+	}{
+		{
+			name:            "disarms NOSPACE alarms if the KubeadmControlPlane opted in",
+			recover:         true,
+			expectedEvent:   "EtcdAlarmDisarmed",
+			expectRecovered: true,
+		},
+		{
+			name: "leaves NOSPACE alarms raised by default",
+		},
+		{
+			name:         "does not recover external etcd",
+			recover:      true,
+			externalEtcd: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			cluster, kcp, _ := createClusterWithControlPlane()
+			if tt.recover {
+				kcp.Annotations = map[string]string{controlplanev1.RecoverEtcdNoSpaceAnnotation: ""}
+			}
+			if tt.externalEtcd {
+				kcp.Spec.KubeadmConfigSpec.ClusterConfiguration = &kubeadmv1.ClusterConfiguration{
+					Etcd: kubeadmv1.Etcd{External: &kubeadmv1.ExternalEtcd{}},
+				}
+			}
+
+			fmc := &fakecluster.ManagementCluster{
+				ControlPlaneHealthy: true,
+				Workload: &fakecluster.WorkloadCluster{
+					EtcdNoSpaceAlarms: []internal.EtcdAlarm{{MemberID: 1, NodeName: "node-1", Type: etcd.AlarmNoSpace}},
+				},
+			}
+			recorder := record.NewFakeRecorder(32)
+			r := &KubeadmControlPlaneReconciler{managementCluster: fmc, recorder: recorder}
+
+			result, err := r.checkHealth(context.Background(), cluster, kcp)
+			g.Expect(err).To(HaveOccurred())
+			g.Expect(result).To(Equal(ctrl.Result{RequeueAfter: HealthCheckFailedRequeueAfter}))
+			g.Expect(fmc.Workload.EtcdNoSpaceAlarms == nil).To(Equal(tt.expectRecovered))
+			if tt.expectedEvent != "" {
+				g.Expect(recorder.Events).To(Receive(ContainSubstring(tt.expectedEvent)))
+			}
+			g.Expect(recorder.Events).NotTo(Receive())
+		})
+	}
+}
+
+func TestKubeadmControlPlaneReconciler_reconcileEtcdDefragmentation(t *testing.T) {
+	now := metav1.Now()
+	tests := []struct {
+		name              string
+		requested         bool
+		interval          time.Duration
+		lastDefragmented  *metav1.Time
+		etcdHealthy       bool
+		expectDefragment  bool
+		expectRequeueWait bool
+	}{
+		{
+			name:             "defragments etcd on request",
+			requested:        true,
+			etcdHealthy:      true,
+			expectDefragment: true,
+		},
+		{
+			name:        "does not defragment etcd by default",
+			etcdHealthy: true,
+		},
+		{
+			name:             "defragments etcd when the interval has passed",
+			interval:         time.Hour,
+			lastDefragmented: &metav1.Time{Time: now.Add(-2 * time.Hour)},
+			etcdHealthy:      true,
+			expectDefragment: true,
+		},
+		{
+			name:              "waits for the interval to pass",
+			interval:          time.Hour,
+			lastDefragmented:  &now,
+			etcdHealthy:       true,
+			expectRequeueWait: true,
+		},
+		{
+			name:              "waits for etcd to be healthy",
+			requested:         true,
+			expectRequeueWait: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			cluster, kcp, _ := createClusterWithControlPlane()
+			kcp.CreationTimestamp = metav1.Time{Time: now.Add(-24 * time.Hour)}
+			kcp.Status.LastEtcdDefragmentationTime = tt.lastDefragmented
+			if tt.requested {
+				kcp.Annotations = map[string]string{controlplanev1.DefragmentEtcdAnnotation: ""}
+			}
+
+			fmc := &fakecluster.ManagementCluster{ControlPlaneHealthy: true, EtcdHealthy: tt.etcdHealthy, Workload: &fakecluster.WorkloadCluster{}}
+			r := &KubeadmControlPlaneReconciler{
+				managementCluster:           fmc,
+				recorder:                    record.NewFakeRecorder(32),
+				EtcdDefragmentationInterval: tt.interval,
+			}
+
+			result, err := r.reconcileEtcdDefragmentation(context.Background(), cluster, kcp)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(result.RequeueAfter > 0).To(Equal(tt.expectRequeueWait || (tt.expectDefragment && tt.interval > 0)))
+			if !tt.expectDefragment {
+				g.Expect(fmc.Workload.EtcdDefragmentations).To(Equal(0))
+				g.Expect(kcp.Status.LastEtcdDefragmentationTime).To(Equal(tt.lastDefragmented))
+				return
+			}
+			g.Expect(fmc.Workload.EtcdDefragmentations).To(Equal(1))
+			g.Expect(kcp.Status.LastEtcdDefragmentationTime.Before(&now)).To(BeFalse())
+			g.Expect(kcp.Annotations).NotTo(HaveKey(controlplanev1.DefragmentEtcdAnnotation))
+		})
+	}
+}
+
+func TestSetReplicaConditions(t *testing.T) {
+	tests := []struct {
+		name                  string
+		desired               int32
+		replicas              int32
+		readyReplicas         int32
+		expectedMachinesReady corev1.ConditionStatus
+		expectedResized       corev1.ConditionStatus
+		expectedResizedReason string
+	}{
+		{
+			name:                  "all machines ready at the desired replicas",
+			desired:               3,
+			replicas:              3,
+			readyReplicas:         3,
+			expectedMachinesReady: corev1.ConditionTrue,
+			expectedResized:       corev1.ConditionTrue,
+		},
+		{
+			name:                  "scaling up",
+			desired:               3,
+			replicas:              2,
+			readyReplicas:         1,
+			expectedMachinesReady: corev1.ConditionFalse,
+			expectedResized:       corev1.ConditionFalse,
+			expectedResizedReason: controlplanev1.ScalingUpReason,
+		},
+		{
+			name:                  "scaling down",
+			desired:               1,
+			replicas:              3,
+			readyReplicas:         3,
+			expectedMachinesReady: corev1.ConditionTrue,
+			expectedResized:       corev1.ConditionFalse,
+			expectedResizedReason: controlplanev1.ScalingDownReason,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			kcp := &controlplanev1.KubeadmControlPlane{
+				Spec: controlplanev1.KubeadmControlPlaneSpec{Replicas: utilpointer.Int32Ptr(tt.desired)},
+				Status: controlplanev1.KubeadmControlPlaneStatus{
+					Replicas:      tt.replicas,
+					ReadyReplicas: tt.readyReplicas,
+				},
+			}
+			setReplicaConditions(kcp)
+
+			g.Expect(conditions.Get(kcp, controlplanev1.MachinesReadyCondition).Status).To(Equal(tt.expectedMachinesReady))
+			resized := conditions.Get(kcp, controlplanev1.ResizedCondition)
+			g.Expect(resized.Status).To(Equal(tt.expectedResized))
+			g.Expect(resized.Reason).To(Equal(tt.expectedResizedReason))
+		})
+	}
+
+	t.Run("keeps the transition time while the status does not change", func(t *testing.T) {
+		g := NewWithT(t)
+
+		kcp := &controlplanev1.KubeadmControlPlane{
+			Spec:   controlplanev1.KubeadmControlPlaneSpec{Replicas: utilpointer.Int32Ptr(3)},
+			Status: controlplanev1.KubeadmControlPlaneStatus{Replicas: 2},
+		}
+		setReplicaConditions(kcp)
+		resized := conditions.Get(kcp, controlplanev1.ResizedCondition)
+		transitionTime := metav1.NewTime(resized.LastTransitionTime.Add(-time.Hour))
+		resized.LastTransitionTime = transitionTime
+		conditions.Delete(kcp, controlplanev1.ResizedCondition)
+		conditions.Set(kcp, resized)
+
+		kcp.Status.Replicas = 1
+		setReplicaConditions(kcp)
+		g.Expect(conditions.Get(kcp, controlplanev1.ResizedCondition).LastTransitionTime).To(Equal(transitionTime))
+		g.Expect(conditions.Get(kcp, controlplanev1.ResizedCondition).Message).To(Equal("scaling up from 1 to 3 replicas"))
+
+		kcp.Status.Replicas = 3
+		setReplicaConditions(kcp)
+		g.Expect(conditions.Get(kcp, controlplanev1.ResizedCondition).LastTransitionTime).NotTo(Equal(transitionTime))
+		g.Expect(kcp.Status.Conditions).To(HaveLen(2))
+	})
+}
+
+func TestSelectMachineForScaleDown(t *testing.T) {
+	now := time.Now()
+	machine := func(name, failureDomain string, age time.Duration) *clusterv1.Machine {
+		m := &clusterv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{Name: name, CreationTimestamp: metav1.NewTime(now.Add(-age))},
+		}
+		if failureDomain != "" {
+			m.Spec.FailureDomain = utilpointer.StringPtr(failureDomain)
+		}
+		return m
+	}
+	healthy := func(m *clusterv1.Machine) *clusterv1.Machine {
+		m.Status.NodeRef = &corev1.ObjectReference{Name: m.Name}
+		return m
+	}
+	markedForDeletion := func(m *clusterv1.Machine) *clusterv1.Machine {
+		m.Annotations = map[string]string{clusterv1.DeleteMachineAnnotation: ""}
+		return m
+	}
+	cluster := &clusterv1.Cluster{
+		Status: clusterv1.ClusterStatus{
+			FailureDomains: clusterv1.FailureDomains{
+				"one":    clusterv1.FailureDomainSpec{ControlPlane: true},
+				"two":    clusterv1.FailureDomainSpec{ControlPlane: true},
+				"worker": clusterv1.FailureDomainSpec{ControlPlane: false},
+			},
+		},
+	}
+
+	tests := []struct {
+		name         string
+		cluster      *clusterv1.Cluster
+		deletePolicy controlplanev1.DeletePolicy
+		machines     []*clusterv1.Machine
+		expected     string
+	}{
+		{
+			name:    "oldest machine in the most populated failure domain",
+			cluster: cluster,
+			machines: []*clusterv1.Machine{
+				machine("one-old", "one", 3*time.Hour),
+				machine("two-newer", "two", time.Hour),
+				machine("two-older", "two", 2*time.Hour),
+			},
+			expected: "two-older",
+		},
+		{
+			name:    "failure domains not suitable for the control plane are ignored",
+			cluster: cluster,
+			machines: []*clusterv1.Machine{
+				machine("one-new", "one", time.Hour),
+				machine("worker-old", "worker", 3*time.Hour),
+				machine("worker-older", "worker", 4*time.Hour),
+			},
+			expected: "one-new",
+		},
+		{
+			name:    "oldest machine without failure domains",
+			cluster: &clusterv1.Cluster{},
+			machines: []*clusterv1.Machine{
+				machine("new", "", time.Hour),
+				machine("old", "", 2*time.Hour),
+			},
+			expected: "old",
+		},
+		{
+			name:         "oldest machine",
+			cluster:      cluster,
+			deletePolicy: controlplanev1.OldestDeletePolicy,
+			machines: []*clusterv1.Machine{
+				machine("one-old", "one", 3*time.Hour),
+				machine("two-newer", "two", time.Hour),
+				machine("two-older", "two", 2*time.Hour),
+			},
+			expected: "one-old",
+		},
+		{
+			name:         "newest machine",
+			cluster:      cluster,
+			deletePolicy: controlplanev1.NewestDeletePolicy,
+			machines: []*clusterv1.Machine{
+				machine("one-old", "one", 3*time.Hour),
+				machine("two-newer", "two", time.Hour),
+				machine("two-older", "two", 2*time.Hour),
+			},
+			expected: "two-newer",
+		},
+		{
+			name:         "oldest unhealthy machine",
+			cluster:      cluster,
+			deletePolicy: controlplanev1.UnhealthyFirstDeletePolicy,
+			machines: []*clusterv1.Machine{
+				healthy(machine("old", "one", 3*time.Hour)),
+				machine("unhealthy-new", "two", time.Hour),
+				machine("unhealthy-old", "two", 2*time.Hour),
+			},
+			expected: "unhealthy-old",
+		},
+		{
+			name:         "oldest machine if all are healthy",
+			cluster:      cluster,
+			deletePolicy: controlplanev1.UnhealthyFirstDeletePolicy,
+			machines: []*clusterv1.Machine{
+				healthy(machine("old", "one", 3*time.Hour)),
+				healthy(machine("new", "two", time.Hour)),
+			},
+			expected: "old",
+		},
+		{
+			name:         "machine marked for deletion",
+			cluster:      cluster,
+			deletePolicy: controlplanev1.OldestDeletePolicy,
+			machines: []*clusterv1.Machine{
+				machine("one-old", "one", 3*time.Hour),
+				markedForDeletion(machine("two-newer", "two", time.Hour)),
+				machine("two-older", "two", 2*time.Hour),
+			},
+			expected: "two-newer",
+		},
+		{
+			name:    "machines marked for deletion are balanced across failure domains",
+			cluster: cluster,
+			machines: []*clusterv1.Machine{
+				machine("one-old", "one", 3*time.Hour),
+				markedForDeletion(machine("one-new", "one", time.Hour)),
+				markedForDeletion(machine("two-newer", "two", time.Hour)),
+				markedForDeletion(machine("two-older", "two", 2*time.Hour)),
+			},
+			expected: "two-older",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			kcp := &controlplanev1.KubeadmControlPlane{Spec: controlplanev1.KubeadmControlPlaneSpec{DeletePolicy: tt.deletePolicy}}
+			selected, err := selectMachineForScaleDown(tt.cluster, kcp, tt.machines)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(selected.Name).To(Equal(tt.expected))
+		})
+	}
+}
+
+func TestKubeadmControlPlaneReconciler_failureDomainForScaleUp(t *testing.T) {
+	g := NewWithT(t)
+
+	cluster, kcp, _ := createClusterWithControlPlane()
+	m, _ := createMachineNodePair("test-0", cluster, kcp, true)
+	m.Spec.FailureDomain = utilpointer.StringPtr("one")
+	r := &KubeadmControlPlaneReconciler{
+		managementCluster: &fakecluster.ManagementCluster{Machines: []*clusterv1.Machine{m}},
+	}
+
+	cluster.Status.FailureDomains = clusterv1.FailureDomains{"worker": clusterv1.FailureDomainSpec{ControlPlane: false}}
+	fd, err := r.failureDomainForScaleUp(context.Background(), kcp, cluster)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(fd).To(BeNil())
+
+	cluster.Status.FailureDomains = clusterv1.FailureDomains{
+		"one": clusterv1.FailureDomainSpec{ControlPlane: true},
+		"two": clusterv1.FailureDomainSpec{ControlPlane: true},
+	}
+	fd, err = r.failureDomainForScaleUp(context.Background(), kcp, cluster)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(fd).To(Equal(utilpointer.StringPtr("two")))
+}
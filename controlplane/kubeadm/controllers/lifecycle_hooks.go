@@ -0,0 +1,111 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	controlplanev1 "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/patch"
+)
+
+// LifecycleHookRequeueAfter is how long to wait before checking again whether the lifecycle hooks of a control
+// plane Machine have completed.
+const LifecycleHookRequeueAfter = 15 * time.Second
+
+// lifecycleHookAnnotations returns the annotations that run the given hooks on a Machine.
+func lifecycleHookAnnotations(prefix string, hooks []string) map[string]string {
+	annotations := map[string]string{}
+	for _, hook := range hooks {
+		annotations[fmt.Sprintf("%s/%s", prefix, hook)] = ""
+	}
+	return annotations
+}
+
+// pendingLifecycleHooks returns the sorted names of the hooks with the given annotation prefix that the Machine
+// still waits for.
+func pendingLifecycleHooks(machine *clusterv1.Machine, prefix string) []string {
+	pending := []string{}
+	for key := range machine.Annotations {
+		if strings.HasPrefix(key, prefix+"/") {
+			pending = append(pending, strings.TrimPrefix(key, prefix+"/"))
+		}
+	}
+	sort.Strings(pending)
+	return pending
+}
+
+// waitForPreDeleteHooks marks the Machine picked for deletion with the DeleteMachineAnnotation, so that the
+// controllers running its pre-delete hooks start and the Machine stays picked, and reports whether some of the hooks
+// have not completed yet.
+func (r *KubeadmControlPlaneReconciler) waitForPreDeleteHooks(ctx context.Context, kcp *controlplanev1.KubeadmControlPlane, machine *clusterv1.Machine) (bool, error) {
+	pending := pendingLifecycleHooks(machine, controlplanev1.PreDeleteHookAnnotationPrefix)
+	if len(pending) == 0 {
+		markLifecycleHooksCompleted(kcp)
+		return false, nil
+	}
+
+	if _, ok := machine.Annotations[clusterv1.DeleteMachineAnnotation]; !ok {
+		patchHelper, err := patch.NewHelper(machine, r.Client)
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to configure the patch helper for Machine %s/%s", machine.Namespace, machine.Name)
+		}
+		machine.Annotations[clusterv1.DeleteMachineAnnotation] = ""
+		if err := patchHelper.Patch(ctx, machine); err != nil {
+			return false, errors.Wrapf(err, "failed to mark Machine %s/%s for deletion", machine.Namespace, machine.Name)
+		}
+	}
+
+	conditions.MarkFalse(kcp, controlplanev1.LifecycleHooksCompletedCondition, controlplanev1.WaitingForLifecycleHooksReason, clusterv1.ConditionSeverityInfo,
+		"Waiting for pre-delete hooks %s of Machine %s", strings.Join(pending, ", "), machine.Name)
+	return true, nil
+}
+
+// waitForPostUpgradeHooks reports whether some of the post-upgrade hooks of the given Machines have not completed
+// yet, in which case the upgrade must not move on to the next Machine.
+func waitForPostUpgradeHooks(kcp *controlplanev1.KubeadmControlPlane, machines []*clusterv1.Machine) bool {
+	waiting := []string{}
+	for _, machine := range machines {
+		if pending := pendingLifecycleHooks(machine, controlplanev1.PostUpgradeHookAnnotationPrefix); len(pending) > 0 {
+			waiting = append(waiting, fmt.Sprintf("%s of Machine %s", strings.Join(pending, ", "), machine.Name))
+		}
+	}
+	if len(waiting) == 0 {
+		markLifecycleHooksCompleted(kcp)
+		return false
+	}
+	sort.Strings(waiting)
+	conditions.MarkFalse(kcp, controlplanev1.LifecycleHooksCompletedCondition, controlplanev1.WaitingForLifecycleHooksReason, clusterv1.ConditionSeverityInfo,
+		"Waiting for post-upgrade hooks %s", strings.Join(waiting, "; "))
+	return true
+}
+
+// markLifecycleHooksCompleted sets the LifecycleHooksCompletedCondition back to true once it was set, so that it is
+// not reported by control planes without lifecycle hooks.
+func markLifecycleHooksCompleted(kcp *controlplanev1.KubeadmControlPlane) {
+	if conditions.Has(kcp, controlplanev1.LifecycleHooksCompletedCondition) {
+		conditions.MarkTrue(kcp, controlplanev1.LifecycleHooksCompletedCondition)
+	}
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilpointer "k8s.io/utils/pointer"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	kubeadmv1 "sigs.k8s.io/cluster-api/bootstrap/kubeadm/types/v1beta1"
+	controlplanev1 "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
+	fakecluster "sigs.k8s.io/cluster-api/controlplane/kubeadm/internal/fake"
+	"sigs.k8s.io/cluster-api/util/conditions"
+)
+
+func TestLifecycleHookAnnotations(t *testing.T) {
+	g := NewWithT(t)
+
+	annotations := lifecycleHookAnnotations(controlplanev1.PreDeleteHookAnnotationPrefix, []string{"etcd-backup", "drain-check"})
+	g.Expect(annotations).To(Equal(map[string]string{
+		"pre-delete.hook.controlplane.cluster.x-k8s.io/etcd-backup": "",
+		"pre-delete.hook.controlplane.cluster.x-k8s.io/drain-check": "",
+	}))
+
+	machine := &clusterv1.Machine{}
+	machine.Annotations = annotations
+	machine.Annotations[controlplanev1.PostUpgradeHookAnnotationPrefix+"/conformance"] = ""
+	g.Expect(pendingLifecycleHooks(machine, controlplanev1.PreDeleteHookAnnotationPrefix)).To(Equal([]string{"drain-check", "etcd-backup"}))
+	g.Expect(pendingLifecycleHooks(machine, controlplanev1.PostUpgradeHookAnnotationPrefix)).To(Equal([]string{"conformance"}))
+	g.Expect(pendingLifecycleHooks(&clusterv1.Machine{}, controlplanev1.PostUpgradeHookAnnotationPrefix)).To(BeEmpty())
+}
+
+func TestKubeadmControlPlaneReconciler_scaleDownControlPlaneWithPreDeleteHooks(t *testing.T) {
+	g := NewWithT(t)
+
+	fakeClient, err := fakeClient()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	cluster, kcp, genericMachineTemplate := createClusterWithControlPlane()
+	g.Expect(fakeClient.Create(context.Background(), genericMachineTemplate)).To(Succeed())
+	kcp.Spec.DeletePolicy = controlplanev1.OldestDeletePolicy
+
+	fmc := &fakecluster.ManagementCluster{
+		Machines:            []*clusterv1.Machine{},
+		ControlPlaneHealthy: true,
+		EtcdHealthy:         true,
+		Workload:            &fakecluster.WorkloadCluster{},
+	}
+	for i := 0; i < 2; i++ {
+		m, _ := createMachineNodePair(fmt.Sprintf("test-%d", i), cluster, kcp, true)
+		m.Annotations = lifecycleHookAnnotations(controlplanev1.PreDeleteHookAnnotationPrefix, []string{"etcd-backup"})
+		g.Expect(fakeClient.Create(context.Background(), m)).To(Succeed())
+		fmc.Machines = append(fmc.Machines, m)
+	}
+
+	r := &KubeadmControlPlaneReconciler{
+		Client:            fakeClient,
+		managementCluster: fmc,
+	}
+
+	result, err := r.scaleDownControlPlane(context.Background(), cluster, kcp, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result).To(Equal(ctrl.Result{RequeueAfter: LifecycleHookRequeueAfter}))
+	g.Expect(fmc.Workload.RemovedEtcdMembers).To(BeEmpty())
+	g.Expect(conditions.Get(kcp, controlplanev1.LifecycleHooksCompletedCondition).Status).To(Equal(corev1.ConditionFalse))
+
+	// The Machine picked for deletion is marked, so the hook controller can start.
+	picked := &clusterv1.Machine{}
+	g.Expect(fakeClient.Get(context.Background(), types.NamespacedName{Namespace: cluster.Namespace, Name: "test-0"}, picked)).To(Succeed())
+	g.Expect(picked.Annotations).To(HaveKey(clusterv1.DeleteMachineAnnotation))
+	other := &clusterv1.Machine{}
+	g.Expect(fakeClient.Get(context.Background(), types.NamespacedName{Namespace: cluster.Namespace, Name: "test-1"}, other)).To(Succeed())
+	g.Expect(other.Annotations).NotTo(HaveKey(clusterv1.DeleteMachineAnnotation))
+
+	// Once the hook is done, the Machine is deleted.
+	delete(fmc.Machines[0].Annotations, controlplanev1.PreDeleteHookAnnotationPrefix+"/etcd-backup")
+	result, err = r.scaleDownControlPlane(context.Background(), cluster, kcp, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result).To(Equal(ctrl.Result{Requeue: true}))
+	g.Expect(fmc.Workload.RemovedEtcdMembers).To(Equal([]string{"test-0"}))
+	g.Expect(conditions.Get(kcp, controlplanev1.LifecycleHooksCompletedCondition).Status).To(Equal(corev1.ConditionTrue))
+}
+
+func TestKubeadmControlPlaneReconciler_upgradeControlPlaneWithPostUpgradeHooks(t *testing.T) {
+	g := NewWithT(t)
+
+	fakeClient, err := fakeClient()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	cluster, kcp, genericMachineTemplate := createClusterWithControlPlane()
+	g.Expect(fakeClient.Create(context.Background(), genericMachineTemplate)).To(Succeed())
+	kcp.Spec.Replicas = utilpointer.Int32Ptr(3)
+	kcp.Spec.KubeadmConfigSpec.ClusterConfiguration = &kubeadmv1.ClusterConfiguration{}
+	kcp.Spec.LifecycleHooks = &controlplanev1.LifecycleHooks{PreDelete: []string{"etcd-backup"}, PostUpgrade: []string{"conformance"}}
+
+	fmc := &fakecluster.ManagementCluster{
+		Machines:            []*clusterv1.Machine{},
+		ControlPlaneHealthy: true,
+		EtcdHealthy:         true,
+		Workload:            &fakecluster.WorkloadCluster{},
+	}
+	for i := 0; i < 3; i++ {
+		m, _ := createMachineNodePair(fmt.Sprintf("test-%d", i), cluster, kcp, true)
+		g.Expect(fakeClient.Create(context.Background(), m)).To(Succeed())
+		fmc.Machines = append(fmc.Machines, m)
+	}
+
+	r := &KubeadmControlPlaneReconciler{
+		Client:            fakeClient,
+		managementCluster: fmc,
+	}
+
+	// The replacement Machine runs the post-upgrade hooks, and the pre-delete hooks once it is deleted.
+	result, err := r.upgradeControlPlane(context.Background(), cluster, kcp, fmc.Machines, fmc.Machines)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result).To(Equal(ctrl.Result{Requeue: true}))
+
+	controlPlaneMachines := clusterv1.MachineList{}
+	g.Expect(fakeClient.List(context.Background(), &controlPlaneMachines)).To(Succeed())
+	g.Expect(controlPlaneMachines.Items).To(HaveLen(4))
+	var replacement *clusterv1.Machine
+	for i := range controlPlaneMachines.Items {
+		if machine := &controlPlaneMachines.Items[i]; machine.Name != "test-0" && machine.Name != "test-1" && machine.Name != "test-2" {
+			replacement = machine
+		}
+	}
+	g.Expect(replacement).NotTo(BeNil())
+	g.Expect(replacement.Annotations).To(HaveKey(controlplanev1.PostUpgradeHookAnnotationPrefix + "/conformance"))
+	g.Expect(replacement.Annotations).To(HaveKey(controlplanev1.PreDeleteHookAnnotationPrefix + "/etcd-backup"))
+
+	// No outdated Machine is deleted until the hooks of the replacement are done.
+	ownedMachines := append(fmc.Machines, replacement)
+	result, err = r.upgradeControlPlane(context.Background(), cluster, kcp, ownedMachines, fmc.Machines)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result).To(Equal(ctrl.Result{RequeueAfter: LifecycleHookRequeueAfter}))
+	g.Expect(fmc.Workload.RemovedEtcdMembers).To(BeEmpty())
+	g.Expect(conditions.Get(kcp, controlplanev1.LifecycleHooksCompletedCondition).Status).To(Equal(corev1.ConditionFalse))
+
+	delete(replacement.Annotations, controlplanev1.PostUpgradeHookAnnotationPrefix+"/conformance")
+	fmc.Machines = ownedMachines
+	result, err = r.upgradeControlPlane(context.Background(), cluster, kcp, ownedMachines, ownedMachines[:3])
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result).To(Equal(ctrl.Result{Requeue: true}))
+	g.Expect(fmc.Workload.RemovedEtcdMembers).To(HaveLen(1))
+	g.Expect(conditions.Get(kcp, controlplanev1.LifecycleHooksCompletedCondition).Status).To(Equal(corev1.ConditionTrue))
+}
@@ -0,0 +1,249 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package metrics defines the metrics available for the kubeadm control plane
+// controller.
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"sigs.k8s.io/cluster-api/controlplane/kubeadm/internal"
+)
+
+var (
+	// DesiredReplicas is a metric that is set to the desired number of
+	// control plane machines of a KubeadmControlPlane.
+	DesiredReplicas = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "capi_kcp_desired_replicas",
+			Help: "Desired number of control plane machines.",
+		},
+		[]string{"kubeadmcontrolplane", "namespace"},
+	)
+
+	// ReadyReplicas is a metric that is set to the number of control plane
+	// machines of a KubeadmControlPlane with a ready node.
+	ReadyReplicas = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "capi_kcp_ready_replicas",
+			Help: "Number of control plane machines with a ready node.",
+		},
+		[]string{"kubeadmcontrolplane", "namespace"},
+	)
+
+	// UpdatedReplicas is a metric that is set to the number of control plane
+	// machines of a KubeadmControlPlane that match its configuration.
+	UpdatedReplicas = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "capi_kcp_updated_replicas",
+			Help: "Number of control plane machines that match the configuration of the control plane.",
+		},
+		[]string{"kubeadmcontrolplane", "namespace"},
+	)
+
+	// UnavailableReplicas is a metric that is set to the number of control
+	// plane machines of a KubeadmControlPlane without a ready node.
+	UnavailableReplicas = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "capi_kcp_unavailable_replicas",
+			Help: "Number of control plane machines without a ready node.",
+		},
+		[]string{"kubeadmcontrolplane", "namespace"},
+	)
+
+	// UpgradeDuration is a metric that observes how long the upgrades of the
+	// control plane machines take, from the first outdated machine seen to
+	// the last one replaced.
+	UpgradeDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "capi_kcp_upgrade_duration_seconds",
+			Help:    "Duration of control plane upgrades.",
+			Buckets: []float64{300, 600, 1200, 1800, 3600, 7200, 14400},
+		},
+		[]string{"kubeadmcontrolplane", "namespace"},
+	)
+
+	// ControlPlaneNodesHealthy is a metric that is set to the number of
+	// nodes that passed the last control plane health check.
+	ControlPlaneNodesHealthy = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "capi_kcp_control_plane_nodes_healthy",
+			Help: "Number of nodes that passed the last control plane health check.",
+		},
+		[]string{"cluster", "namespace"},
+	)
+
+	// ControlPlaneNodesUnhealthy is a metric that is set to the number of
+	// nodes that failed the last control plane health check.
+	ControlPlaneNodesUnhealthy = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "capi_kcp_control_plane_nodes_unhealthy",
+			Help: "Number of nodes that failed the last control plane health check.",
+		},
+		[]string{"cluster", "namespace"},
+	)
+
+	// EtcdMembers is a metric that is set to the number of etcd members seen
+	// during the last etcd health check.
+	EtcdMembers = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "capi_kcp_etcd_members",
+			Help: "Number of etcd members seen during the last etcd health check.",
+		},
+		[]string{"cluster", "namespace"},
+	)
+
+	// EtcdAlarms is a metric that is set to the number of etcd alarms seen
+	// during the last etcd health check.
+	EtcdAlarms = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "capi_kcp_etcd_alarms",
+			Help: "Number of etcd alarms seen during the last etcd health check.",
+		},
+		[]string{"cluster", "namespace"},
+	)
+
+	// EtcdMemberMismatches is a metric that counts the etcd health checks
+	// that found a different number of etcd members than control plane nodes.
+	EtcdMemberMismatches = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "capi_kcp_etcd_member_mismatches_total",
+			Help: "Number of etcd health checks that found a different number of etcd members than control plane nodes.",
+		},
+		[]string{"cluster", "namespace"},
+	)
+
+	// HealthCheckDuration is a metric that observes how long the target
+	// cluster health checks take.
+	HealthCheckDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name: "capi_kcp_health_check_duration_seconds",
+			Help: "Duration of target cluster health checks.",
+		},
+		[]string{"cluster", "namespace", "check"},
+	)
+
+	// HealthCheckFailures is a metric that counts the target cluster health
+	// checks that failed.
+	HealthCheckFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "capi_kcp_health_check_failures_total",
+			Help: "Number of failed target cluster health checks.",
+		},
+		[]string{"cluster", "namespace", "check"},
+	)
+
+	// MachineCertificatesExpiry is a metric that is set to the time the
+	// certificates of a control plane machine expire.
+	MachineCertificatesExpiry = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "capi_kcp_machine_certificates_expiry_timestamp_seconds",
+			Help: "Time the certificates of a control plane machine expire, as a Unix timestamp.",
+		},
+		[]string{"cluster", "namespace", "machine"},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		DesiredReplicas,
+		ReadyReplicas,
+		UpdatedReplicas,
+		UnavailableReplicas,
+		UpgradeDuration,
+		ControlPlaneNodesHealthy,
+		ControlPlaneNodesUnhealthy,
+		EtcdMembers,
+		EtcdAlarms,
+		EtcdMemberMismatches,
+		HealthCheckDuration,
+		HealthCheckFailures,
+		MachineCertificatesExpiry,
+	)
+}
+
+// Sink exposes the observations of the management cluster as Prometheus metrics.
+type Sink struct{}
+
+var _ internal.MetricsSink = Sink{}
+
+// ObserveHealthCheck implements internal.MetricsSink.
+func (Sink) ObserveHealthCheck(observation internal.HealthCheckObservation) {
+	cluster, namespace := observation.Cluster.Name, observation.Cluster.Namespace
+
+	HealthCheckDuration.WithLabelValues(cluster, namespace, string(observation.Check)).Observe(observation.Duration.Seconds())
+	if observation.Err != nil {
+		HealthCheckFailures.WithLabelValues(cluster, namespace, string(observation.Check)).Inc()
+	}
+
+	switch observation.Check {
+	case internal.ControlPlaneHealthCheck:
+		ControlPlaneNodesHealthy.WithLabelValues(cluster, namespace).Set(float64(observation.HealthyNodes))
+		ControlPlaneNodesUnhealthy.WithLabelValues(cluster, namespace).Set(float64(observation.UnhealthyNodes))
+	case internal.EtcdHealthCheck:
+		EtcdMembers.WithLabelValues(cluster, namespace).Set(float64(observation.EtcdMembers))
+		EtcdAlarms.WithLabelValues(cluster, namespace).Set(float64(observation.EtcdAlarms))
+		if observation.ExpectedEtcdMembers > 0 && observation.EtcdMembers != observation.ExpectedEtcdMembers {
+			EtcdMemberMismatches.WithLabelValues(cluster, namespace).Inc()
+		}
+	}
+}
+
+// ObserveCertificatesExpiry implements internal.MetricsSink.
+func (Sink) ObserveCertificatesExpiry(observation internal.CertificatesExpiryObservation) {
+	MachineCertificatesExpiry.WithLabelValues(observation.Cluster.Name, observation.Cluster.Namespace, observation.Machine).Set(float64(observation.Expiry.Unix()))
+}
+
+// upgradeStarts holds when this process first saw the ongoing upgrade of each KubeadmControlPlane.
+var upgradeStarts = struct {
+	sync.Mutex
+	times map[types.NamespacedName]time.Time
+}{times: map[types.NamespacedName]time.Time{}}
+
+// ObserveUpgrade records whether the KubeadmControlPlane is upgrading at the given time, and observes the
+// UpgradeDuration once an upgrade it saw starting is done. Upgrades that started before this process did are
+// observed from when it first saw them.
+func ObserveUpgrade(kcp types.NamespacedName, upgrading bool, now time.Time) {
+	upgradeStarts.Lock()
+	defer upgradeStarts.Unlock()
+
+	start, ok := upgradeStarts.times[kcp]
+	switch {
+	case upgrading && !ok:
+		upgradeStarts.times[kcp] = now
+	case !upgrading && ok:
+		UpgradeDuration.WithLabelValues(kcp.Name, kcp.Namespace).Observe(now.Sub(start).Seconds())
+		delete(upgradeStarts.times, kcp)
+	}
+}
+
+// DeleteKubeadmControlPlane drops the metrics of a deleted KubeadmControlPlane.
+func DeleteKubeadmControlPlane(kcp types.NamespacedName) {
+	upgradeStarts.Lock()
+	delete(upgradeStarts.times, kcp)
+	upgradeStarts.Unlock()
+
+	for _, gauge := range []*prometheus.GaugeVec{DesiredReplicas, ReadyReplicas, UpdatedReplicas, UnavailableReplicas} {
+		gauge.DeleteLabelValues(kcp.Name, kcp.Namespace)
+	}
+	UpgradeDuration.DeleteLabelValues(kcp.Name, kcp.Namespace)
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"k8s.io/apimachinery/pkg/types"
+
+	"sigs.k8s.io/cluster-api/controlplane/kubeadm/internal"
+)
+
+func TestSinkObserveHealthCheck(t *testing.T) {
+	g := NewWithT(t)
+
+	cluster := types.NamespacedName{Namespace: "health", Name: "cluster"}
+	sink := Sink{}
+	sink.ObserveHealthCheck(internal.HealthCheckObservation{
+		Cluster:        cluster,
+		Check:          internal.ControlPlaneHealthCheck,
+		HealthyNodes:   2,
+		UnhealthyNodes: 1,
+		Duration:       time.Second,
+		Err:            errors.New("node is not ready"),
+	})
+	sink.ObserveHealthCheck(internal.HealthCheckObservation{
+		Cluster:             cluster,
+		Check:               internal.EtcdHealthCheck,
+		HealthyNodes:        3,
+		EtcdMembers:         4,
+		ExpectedEtcdMembers: 3,
+		Err:                 errors.New("there are 3 control plane nodes, but 4 etcd members"),
+	})
+	sink.ObserveHealthCheck(internal.HealthCheckObservation{
+		Cluster:             cluster,
+		Check:               internal.EtcdHealthCheck,
+		HealthyNodes:        3,
+		EtcdMembers:         3,
+		ExpectedEtcdMembers: 3,
+	})
+
+	g.Expect(testutil.ToFloat64(ControlPlaneNodesHealthy.WithLabelValues("cluster", "health"))).To(Equal(float64(2)))
+	g.Expect(testutil.ToFloat64(ControlPlaneNodesUnhealthy.WithLabelValues("cluster", "health"))).To(Equal(float64(1)))
+	g.Expect(testutil.ToFloat64(EtcdMembers.WithLabelValues("cluster", "health"))).To(Equal(float64(3)))
+	g.Expect(testutil.ToFloat64(EtcdMemberMismatches.WithLabelValues("cluster", "health"))).To(Equal(float64(1)))
+	g.Expect(testutil.ToFloat64(HealthCheckFailures.WithLabelValues("cluster", "health", "control-plane"))).To(Equal(float64(1)))
+	g.Expect(testutil.ToFloat64(HealthCheckFailures.WithLabelValues("cluster", "health", "etcd"))).To(Equal(float64(1)))
+	g.Expect(histogramOf(t, HealthCheckDuration.WithLabelValues("cluster", "health", "etcd")).GetSampleCount()).To(Equal(uint64(2)))
+}
+
+func TestObserveUpgrade(t *testing.T) {
+	g := NewWithT(t)
+
+	kcp := types.NamespacedName{Namespace: "upgrade", Name: "kcp"}
+	start := time.Now()
+	ObserveUpgrade(kcp, false, start)
+	ObserveUpgrade(kcp, true, start)
+	ObserveUpgrade(kcp, true, start.Add(10*time.Minute))
+	g.Expect(histogramOf(t, UpgradeDuration.WithLabelValues("kcp", "upgrade")).GetSampleCount()).To(BeZero())
+
+	ObserveUpgrade(kcp, false, start.Add(20*time.Minute))
+	histogram := histogramOf(t, UpgradeDuration.WithLabelValues("kcp", "upgrade"))
+	g.Expect(histogram.GetSampleCount()).To(Equal(uint64(1)))
+	g.Expect(histogram.GetSampleSum()).To(Equal((20 * time.Minute).Seconds()))
+
+	// Nothing more is observed until the next upgrade starts.
+	ObserveUpgrade(kcp, false, start.Add(30*time.Minute))
+	g.Expect(histogramOf(t, UpgradeDuration.WithLabelValues("kcp", "upgrade")).GetSampleCount()).To(Equal(uint64(1)))
+
+	DeleteKubeadmControlPlane(kcp)
+	g.Expect(histogramOf(t, UpgradeDuration.WithLabelValues("kcp", "upgrade")).GetSampleCount()).To(BeZero())
+}
+
+func histogramOf(t *testing.T, observer prometheus.Observer) *dto.Histogram {
+	metric := &dto.Metric{}
+	if err := observer.(prometheus.Metric).Write(metric); err != nil {
+		t.Fatal(err)
+	}
+	return metric.GetHistogram()
+}
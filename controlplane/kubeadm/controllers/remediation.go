@@ -0,0 +1,100 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	controlplanev1 "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/controlplane/kubeadm/internal"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/patch"
+)
+
+// errRemediationUnsafe is returned when remediating an unhealthy control plane Machine would lose etcd quorum
+// or remove the last control plane Machine.
+var errRemediationUnsafe = errors.New("remediation would leave the control plane without quorum")
+
+// remediateUnhealthyMachine deletes the oldest of the given unhealthy control plane Machines, so that it is replaced
+// by scaling up on a later reconciliation. The full health checks are not run, as they cannot pass while the Machine
+// is broken. Instead, its etcd member is only removed if the remaining members keep quorum, and the last control
+// plane Machine is never removed. When the Machine cannot be remediated safely, the reason is recorded on its
+// OwnerRemediated condition, through which a MachineHealthCheck hands off the remediation.
+func (r *KubeadmControlPlaneReconciler) remediateUnhealthyMachine(ctx context.Context, cluster *clusterv1.Cluster, kcp *controlplanev1.KubeadmControlPlane, ownedMachines, unhealthyMachines []*clusterv1.Machine) (ctrl.Result, error) {
+	// Wait for any delete in progress to complete before deleting another Machine
+	if len(internal.FilterMachines(ownedMachines, internal.HasDeletionTimestamp())) > 0 {
+		return ctrl.Result{RequeueAfter: DeleteRequeueAfter}, nil
+	}
+
+	machineToDelete, err := oldestMachine(unhealthyMachines)
+	if err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "failed to pick unhealthy control plane Machine to remediate")
+	}
+	if len(ownedMachines) <= 1 {
+		return ctrl.Result{}, r.markRemediationUnsafe(ctx, machineToDelete,
+			errors.Wrapf(errRemediationUnsafe, "control plane Machine %s/%s is the last one", machineToDelete.Namespace, machineToDelete.Name))
+	}
+
+	// External etcd does not run on the control plane Machines.
+	if !usesExternalEtcd(kcp) && machineToDelete.Status.NodeRef != nil {
+		workloadCluster, err := r.managementCluster.GetWorkloadCluster(ctx, clusterKey(cluster))
+		if err != nil {
+			return ctrl.Result{}, errors.Wrapf(err, "failed to get the workload cluster of cluster %s/%s", cluster.Namespace, cluster.Name)
+		}
+		nodeName := machineToDelete.Status.NodeRef.Name
+		safe, err := workloadCluster.CanSafelyRemoveEtcdMember(ctx, nodeName)
+		if err != nil {
+			return ctrl.Result{RequeueAfter: HealthCheckFailedRequeueAfter}, errors.Wrapf(err, "failed to check etcd quorum without node %q", nodeName)
+		}
+		if !safe {
+			return ctrl.Result{RequeueAfter: HealthCheckFailedRequeueAfter}, r.markRemediationUnsafe(ctx, machineToDelete,
+				errors.Wrapf(errRemediationUnsafe, "removing the etcd member of node %q", nodeName))
+		}
+		if err := workloadCluster.ForwardEtcdLeadership(ctx, nodeName); err != nil {
+			return ctrl.Result{}, errors.Wrapf(err, "failed to move etcd leadership away from control plane Machine %s/%s", machineToDelete.Namespace, machineToDelete.Name)
+		}
+		if err := workloadCluster.RemoveEtcdMemberForNode(ctx, nodeName); err != nil {
+			return ctrl.Result{}, errors.Wrapf(err, "failed to remove etcd member for control plane Machine %s/%s", machineToDelete.Namespace, machineToDelete.Name)
+		}
+	}
+
+	if err := r.deleteMachine(ctx, kcp, machineToDelete); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Requeue the control plane, so the Machine is replaced once it is gone
+	return ctrl.Result{Requeue: true}, nil
+}
+
+// markRemediationUnsafe records why the unhealthy Machine is not remediated on its OwnerRemediated condition, and
+// returns that reason.
+func (r *KubeadmControlPlaneReconciler) markRemediationUnsafe(ctx context.Context, machine *clusterv1.Machine, reason error) error {
+	patchHelper, err := patch.NewHelper(machine, r.Client)
+	if err != nil {
+		return kerrors.NewAggregate([]error{reason, err})
+	}
+	conditions.MarkFalse(machine, clusterv1.MachineOwnerRemediatedCondition, clusterv1.RemediationUnsafeReason, clusterv1.ConditionSeverityWarning, "%v", reason)
+	if err := patchHelper.Patch(ctx, machine); err != nil {
+		return kerrors.NewAggregate([]error{reason, errors.Wrapf(err, "failed to patch control plane Machine %s/%s", machine.Namespace, machine.Name)})
+	}
+	return reason
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/bootstrap/kubeadm/types/v1beta1"
+	controlplanev1 "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
+	fakecluster "sigs.k8s.io/cluster-api/controlplane/kubeadm/internal/fake"
+	"sigs.k8s.io/cluster-api/util/conditions"
+)
+
+func TestKubeadmControlPlaneReconciler_remediateUnhealthyMachine(t *testing.T) {
+	setup := func(g *WithT, replicas int) (*KubeadmControlPlaneReconciler, *fakecluster.ManagementCluster, *clusterv1.Cluster, *controlplanev1.KubeadmControlPlane, []*clusterv1.Machine) {
+		fakeClient, err := fakeClient()
+		g.Expect(err).NotTo(HaveOccurred())
+
+		cluster, kcp, _ := createClusterWithControlPlane()
+		fmc := &fakecluster.ManagementCluster{Machines: []*clusterv1.Machine{}, Workload: &fakecluster.WorkloadCluster{}}
+		for i := 0; i < replicas; i++ {
+			m, _ := createMachineNodePair(fmt.Sprintf("test-%d", i), cluster, kcp, true)
+			g.Expect(fakeClient.Create(context.Background(), m)).To(Succeed())
+			fmc.Machines = append(fmc.Machines, m)
+		}
+
+		r := &KubeadmControlPlaneReconciler{
+			Client:            fakeClient,
+			managementCluster: fmc,
+		}
+		return r, fmc, cluster, kcp, fmc.Machines
+	}
+
+	t.Run("deletes the unhealthy Machine after removing its etcd member", func(t *testing.T) {
+		g := NewWithT(t)
+
+		r, fmc, cluster, kcp, machines := setup(g, 3)
+
+		result, err := r.remediateUnhealthyMachine(context.Background(), cluster, kcp, machines, machines[1:2])
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(result).To(Equal(ctrl.Result{Requeue: true}))
+
+		controlPlaneMachines := clusterv1.MachineList{}
+		g.Expect(r.Client.List(context.Background(), &controlPlaneMachines)).To(Succeed())
+		g.Expect(controlPlaneMachines.Items).To(HaveLen(2))
+		g.Expect(fmc.Workload.EtcdLeaderForwards).To(ConsistOf(machines[1].Name))
+		g.Expect(fmc.Workload.RemovedEtcdMembers).To(ConsistOf(machines[1].Name))
+	})
+	t.Run("does not touch etcd when it is external", func(t *testing.T) {
+		g := NewWithT(t)
+
+		r, fmc, cluster, kcp, machines := setup(g, 3)
+		kcp.Spec.KubeadmConfigSpec.ClusterConfiguration = &v1beta1.ClusterConfiguration{
+			Etcd: v1beta1.Etcd{External: &v1beta1.ExternalEtcd{Endpoints: []string{"https://etcd:2379"}}},
+		}
+		fmc.Workload.UnsafeEtcdMemberRemoval = true
+
+		result, err := r.remediateUnhealthyMachine(context.Background(), cluster, kcp, machines, machines[1:2])
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(result).To(Equal(ctrl.Result{Requeue: true}))
+		g.Expect(fmc.Workload.RemovedEtcdMembers).To(BeEmpty())
+	})
+	t.Run("does not delete the Machine if removing its etcd member would lose quorum", func(t *testing.T) {
+		g := NewWithT(t)
+
+		r, fmc, cluster, kcp, machines := setup(g, 3)
+		fmc.Workload.UnsafeEtcdMemberRemoval = true
+
+		result, err := r.remediateUnhealthyMachine(context.Background(), cluster, kcp, machines, machines[1:2])
+		g.Expect(errors.Cause(err)).To(Equal(errRemediationUnsafe))
+		g.Expect(result).To(Equal(ctrl.Result{RequeueAfter: HealthCheckFailedRequeueAfter}))
+
+		controlPlaneMachines := clusterv1.MachineList{}
+		g.Expect(r.Client.List(context.Background(), &controlPlaneMachines)).To(Succeed())
+		g.Expect(controlPlaneMachines.Items).To(HaveLen(3))
+		g.Expect(fmc.Workload.RemovedEtcdMembers).To(BeEmpty())
+
+		unhealthy := &clusterv1.Machine{}
+		g.Expect(r.Client.Get(context.Background(), client.ObjectKey{Namespace: machines[1].Namespace, Name: machines[1].Name}, unhealthy)).To(Succeed())
+		g.Expect(conditions.IsFalse(unhealthy, clusterv1.MachineOwnerRemediatedCondition)).To(BeTrue())
+		g.Expect(conditions.Get(unhealthy, clusterv1.MachineOwnerRemediatedCondition).Reason).To(Equal(clusterv1.RemediationUnsafeReason))
+	})
+	t.Run("does not delete the last control plane Machine", func(t *testing.T) {
+		g := NewWithT(t)
+
+		r, fmc, cluster, kcp, machines := setup(g, 1)
+
+		_, err := r.remediateUnhealthyMachine(context.Background(), cluster, kcp, machines, machines)
+		g.Expect(errors.Cause(err)).To(Equal(errRemediationUnsafe))
+
+		controlPlaneMachines := clusterv1.MachineList{}
+		g.Expect(r.Client.List(context.Background(), &controlPlaneMachines)).To(Succeed())
+		g.Expect(controlPlaneMachines.Items).To(HaveLen(1))
+		g.Expect(fmc.Workload.RemovedEtcdMembers).To(BeEmpty())
+	})
+	t.Run("waits for a delete in progress", func(t *testing.T) {
+		g := NewWithT(t)
+
+		r, fmc, cluster, kcp, machines := setup(g, 3)
+		now := metav1.Now()
+		machines[0].DeletionTimestamp = &now
+
+		result, err := r.remediateUnhealthyMachine(context.Background(), cluster, kcp, machines, machines[1:2])
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(result).To(Equal(ctrl.Result{RequeueAfter: DeleteRequeueAfter}))
+		g.Expect(fmc.Workload.RemovedEtcdMembers).To(BeEmpty())
+	})
+}
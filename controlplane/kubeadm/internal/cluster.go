@@ -25,13 +25,26 @@ import (
 	"crypto/x509/pkix"
 	"fmt"
 	"math/big"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
+	"go.etcd.io/etcd/etcdserver/api/v3rpc/rpctypes"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
 	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
@@ -40,15 +53,218 @@ import (
 	"sigs.k8s.io/cluster-api/controlplane/kubeadm/internal/etcd"
 	etcdutil "sigs.k8s.io/cluster-api/controlplane/kubeadm/internal/etcd/util"
 	"sigs.k8s.io/cluster-api/controlplane/kubeadm/internal/proxy"
+	"sigs.k8s.io/cluster-api/util"
 	"sigs.k8s.io/cluster-api/util/certs"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/secret"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-// ManagementCluster holds operations on the ManagementCluster
-type ManagementCluster struct {
+// ErrWorkloadClusterUnreachable is returned by the target cluster health checks when the
+// workload cluster's API server does not answer requests.
+var ErrWorkloadClusterUnreachable = errors.New("workload cluster API server is unreachable")
+
+// ErrEtcdClientAuthFailed is returned when the etcd cluster rejects the etcd client certificate, or the etcd serving
+// certificate is not signed by the etcd CA.
+var ErrEtcdClientAuthFailed = errors.New("failed to authenticate with etcd")
+
+// ErrNodeNotProvisioned is reported for control plane nodes that have no provider ID yet.
+var ErrNodeNotProvisioned = errors.New("empty provider ID")
+
+// ErrControlPlaneEndpointNotSet is returned when a Cluster has no control plane endpoint yet, usually because its
+// infrastructure is still being provisioned.
+var ErrControlPlaneEndpointNotSet = errors.New("cluster has no control plane endpoint")
+
+// ManagementCluster defines all behaviors necessary for something to function as a management cluster, i.e. to
+// operate on the workload clusters of the control planes it manages.
+type ManagementCluster interface {
+	GetMachinesForCluster(ctx context.Context, cluster types.NamespacedName, filters ...func(machine *clusterv1.Machine) bool) ([]*clusterv1.Machine, error)
+	GetWorkloadCluster(ctx context.Context, clusterKey types.NamespacedName) (WorkloadCluster, error)
+	TargetClusterControlPlaneIsHealthy(ctx context.Context, clusterKey types.NamespacedName, controlPlaneName string, excludeMachines ...types.NamespacedName) error
+	TargetClusterEtcdIsHealthy(ctx context.Context, clusterKey types.NamespacedName, controlPlaneName string, excludeMachines ...types.NamespacedName) error
+	GetMachineCertificatesExpiries(ctx context.Context, clusterKey types.NamespacedName, machines []*clusterv1.Machine) (map[string]time.Time, error)
+}
+
+var _ ManagementCluster = &Management{}
+
+// Management holds operations on the management cluster.
+type Management struct {
 	Client ctrlclient.Client
+
+	// Tracker provides the shared clients of the workload clusters. Target clusters get their own clients, built
+	// from their kubeconfig, if it is nil.
+	Tracker *remote.ClusterCacheTracker
+
+	// PingAPIServer makes the target cluster health checks verify that the workload cluster's API server
+	// is serving before listing nodes and pods, so that an API server that is not ready yet fails the
+	// checks with ErrWorkloadClusterUnreachable.
+	PingAPIServer bool
+
+	// BatchConcurrency bounds the number of concurrent requests batch operations such as GetEtcdCAExpiries
+	// send to the management cluster's API server. defaultBatchConcurrency is used if it is not positive.
+	BatchConcurrency int
+
+	// EtcdDialKeepAliveTime is how often etcd clients ping the etcd members of target clusters to keep connections alive.
+	// Keepalive pings are disabled if it is zero.
+	EtcdDialKeepAliveTime time.Duration
+
+	// EtcdDialKeepAliveTimeout is how long etcd clients wait for a keepalive ping to be answered before closing the connection.
+	// The etcd client default is used if it is zero.
+	EtcdDialKeepAliveTimeout time.Duration
+
+	// EtcdDialTimeout is how long etcd clients wait for a connection to an etcd member of a target cluster, including
+	// the port forward through the workload cluster's API server. The client and proxy defaults are used if it is zero.
+	EtcdDialTimeout time.Duration
+
+	// EtcdMaxCallRecvMsgSize is the maximum size in bytes of a response etcd clients accept.
+	// The etcd client default is used if it is zero.
+	EtcdMaxCallRecvMsgSize int
+
+	// EtcdClientCertConfig returns the subject of the client certificates minted to talk to the etcd cluster
+	// of the given target cluster. The certificates are issued to "cluster-api.x-k8s.io" if it is nil or
+	// returns no CommonName.
+	EtcdClientCertConfig func(clusterKey types.NamespacedName) certs.Config
+
+	// HealthCheckConcurrency bounds the number of control plane nodes checked concurrently by the health checks.
+	// defaultHealthCheckConcurrency is used if it is not positive.
+	HealthCheckConcurrency int
+
+	// HealthCheckTimeout bounds how long TargetClusterControlPlaneIsHealthy and TargetClusterEtcdIsHealthy may take,
+	// retries included. The checks are only bounded by the context they are given if it is zero.
+	HealthCheckTimeout time.Duration
+
+	// HealthCheckRetryBackoff is how TargetClusterControlPlaneIsHealthy and TargetClusterEtcdIsHealthy retry a failed
+	// check before reporting it: the check is run up to Steps times, waiting for the backoff in between, so that
+	// transient failures on slow networks do not fail preflight checks. Failed checks are not retried if Steps is
+	// less than two.
+	HealthCheckRetryBackoff wait.Backoff
+
+	// HealthCheckNodeTimeout bounds how long the health checks wait for a single control plane node, so that a slow
+	// node does not hold up the check of the others. Nodes whose check does not complete in time are reported with the
+	// context's error. defaultHealthCheckNodeTimeout is used if it is not positive.
+	HealthCheckNodeTimeout time.Duration
+
+	// EtcdMembershipSampleInterval is the time between the two etcd member lists compared by EtcdMembershipIsStable.
+	// defaultEtcdMembershipSampleInterval is used if it is not positive.
+	EtcdMembershipSampleInterval time.Duration
+
+	// HealthCheckCacheTTL is how long the control plane node list and static pod readiness observed by a control plane
+	// health check are reused by later checks of the same cluster. Caching trades up to this much staleness for fewer
+	// requests to the workload cluster's apiserver when health checks run in a tight loop.
+	// Results are not cached if it is zero; use InvalidateHealthCheckCache to drop a cached result early.
+	HealthCheckCacheTTL time.Duration
+
+	// DiscoverNodesFromMachines makes health checks fall back to the nodes referenced by the cluster's control plane
+	// machines when no node carries the control plane role label, e.g. because the workload cluster uses a different
+	// role label scheme. The fallback is not used if it is false.
+	DiscoverNodesFromMachines bool
+
+	// TolerateUnprovisionedNodes makes the etcd health check ignore control plane nodes that have no provider ID yet.
+	// Such nodes are reported with ErrNodeNotProvisioned and are not expected to run an etcd member, instead of
+	// failing the check. The check is strict if it is false.
+	TolerateUnprovisionedNodes bool
+
+	// MaxAggregatedNodeErrors caps the number of node errors included in the error returned by a health check,
+	// keeping errors that end up in logs and events bounded when many nodes are unhealthy. The remaining errors are
+	// summarized as "and N more"; the per node results are still available from the health report methods.
+	// All node errors are included if it is not positive.
+	MaxAggregatedNodeErrors int
+
+	// MetricsSink receives an observation for every target cluster health check and control plane machine
+	// certificates expiry lookup.
+	// Observations are discarded if it is nil.
+	MetricsSink MetricsSink
+
+	// EtcdClientIdleTimeout is how long a connection to an etcd member of a target cluster is kept open after its
+	// last use, so that later operations on the same member reuse it instead of dialing again.
+	// defaultEtcdClientIdleTimeout is used if it is not positive.
+	EtcdClientIdleTimeout time.Duration
+
+	// externalEtcdClientGenerator overrides how clients for external etcd endpoints are created;
+	// newExternalEtcdClient is used if it is nil.
+	externalEtcdClientGenerator externalEtcdClientGenerator
+
+	clusterCacheLock sync.Mutex
+	clusterCache     map[types.NamespacedName]*clusterCacheEntry
+
+	// inFlightChecks are the running health checks, shared by concurrent callers asking for the same check.
+	inFlightChecksLock sync.Mutex
+	inFlightChecks     map[inFlightCheckKey]*inFlightCheck
+}
+
+// etcdClientOptions returns the options for the etcd clients created for target clusters.
+func (m *Management) etcdClientOptions() []etcd.EtcdClientOption {
+	options := []etcd.EtcdClientOption{}
+	if m.EtcdDialKeepAliveTime != 0 || m.EtcdDialKeepAliveTimeout != 0 {
+		options = append(options, etcd.WithDialKeepAlive(m.EtcdDialKeepAliveTime, m.EtcdDialKeepAliveTimeout))
+	}
+	if m.EtcdDialTimeout != 0 {
+		options = append(options, etcd.WithDialTimeout(m.EtcdDialTimeout))
+	}
+	if m.EtcdMaxCallRecvMsgSize != 0 {
+		options = append(options, etcd.WithMaxCallRecvMsgSize(m.EtcdMaxCallRecvMsgSize))
+	}
+	return options
+}
+
+// etcdClientCertConfig returns the subject of the etcd client certificates for the given target cluster.
+func (m *Management) etcdClientCertConfig(clusterKey types.NamespacedName) certs.Config {
+	if m.EtcdClientCertConfig == nil {
+		return defaultEtcdClientCertConfig()
+	}
+	cfg := m.EtcdClientCertConfig(clusterKey)
+	if cfg.CommonName == "" {
+		cfg.CommonName = defaultEtcdClientCertConfig().CommonName
+	}
+	return cfg
+}
+
+// defaultHealthCheckNodeTimeout is how long a single control plane node is checked when HealthCheckNodeTimeout is not set.
+const defaultHealthCheckNodeTimeout = 20 * time.Second
+
+func (m *Management) healthCheckNodeTimeout() time.Duration {
+	if m.HealthCheckNodeTimeout <= 0 {
+		return defaultHealthCheckNodeTimeout
+	}
+	return m.HealthCheckNodeTimeout
+}
+
+// defaultEtcdClientIdleTimeout is how long unused etcd connections are kept open when EtcdClientIdleTimeout is not set.
+const defaultEtcdClientIdleTimeout = 5 * time.Minute
+
+func (m *Management) etcdClientIdleTimeout() time.Duration {
+	if m.EtcdClientIdleTimeout <= 0 {
+		return defaultEtcdClientIdleTimeout
+	}
+	return m.EtcdClientIdleTimeout
+}
+
+// defaultHealthCheckConcurrency is the number of control plane nodes checked concurrently when HealthCheckConcurrency is not set.
+const defaultHealthCheckConcurrency = 10
+
+func (m *Management) healthCheckConcurrency() int {
+	if m.HealthCheckConcurrency <= 0 {
+		return defaultHealthCheckConcurrency
+	}
+	return m.HealthCheckConcurrency
+}
+
+// defaultEtcdMembershipSampleInterval is the time between etcd member list samples when EtcdMembershipSampleInterval is not set.
+const defaultEtcdMembershipSampleInterval = 2 * time.Second
+
+func (m *Management) etcdMembershipSampleInterval() time.Duration {
+	if m.EtcdMembershipSampleInterval <= 0 {
+		return defaultEtcdMembershipSampleInterval
+	}
+	return m.EtcdMembershipSampleInterval
+}
+
+func (m *Management) metricsSink() MetricsSink {
+	if m.MetricsSink == nil {
+		return noopMetricsSink{}
+	}
+	return m.MetricsSink
 }
 
 // OwnedControlPlaneMachines returns a MachineFilter function to find all owned control plane machines.
@@ -66,6 +282,27 @@ func OwnedControlPlaneMachines(controlPlaneName string) func(machine *clusterv1.
 	}
 }
 
+// ControlPlaneMachines returns a MachineFilter function to find all control plane machines of the given cluster,
+// whether a KubeadmControlPlane owns them or not.
+func ControlPlaneMachines(clusterName string) func(machine *clusterv1.Machine) bool {
+	return func(machine *clusterv1.Machine) bool {
+		if machine == nil {
+			return false
+		}
+		return util.IsControlPlaneMachine(machine) && machine.Labels[clusterv1.ClusterLabelName] == clusterName
+	}
+}
+
+// AdoptableControlPlaneMachines returns a MachineFilter function to find all control plane machines of the given
+// cluster that no controller owns and that are not being deleted, e.g. machines created before the cluster's
+// KubeadmControlPlane, which the KubeadmControlPlane can adopt.
+func AdoptableControlPlaneMachines(clusterName string) func(machine *clusterv1.Machine) bool {
+	controlPlane := ControlPlaneMachines(clusterName)
+	return func(machine *clusterv1.Machine) bool {
+		return controlPlane(machine) && metav1.GetControllerOf(machine) == nil && machine.GetDeletionTimestamp() == nil
+	}
+}
+
 // HasDeletionTimestamp returns a MachineFilter function to find all machines
 // that have a deletion timestamp.
 func HasDeletionTimestamp() func(machine *clusterv1.Machine) bool {
@@ -99,6 +336,49 @@ func MatchesConfigurationHash(configHash string) func(machine *clusterv1.Machine
 	}
 }
 
+// UpToDateControlPlaneMachines returns a MachineFilter function to find all machines
+// owned by the given KubeadmControlPlane that match its configuration hash.
+func UpToDateControlPlaneMachines(controlPlaneName, configHash string) func(machine *clusterv1.Machine) bool {
+	owned := OwnedControlPlaneMachines(controlPlaneName)
+	matches := MatchesConfigurationHash(configHash)
+	return func(machine *clusterv1.Machine) bool {
+		return owned(machine) && matches(machine)
+	}
+}
+
+// OutdatedControlPlaneMachines returns a MachineFilter function to find all machines
+// owned by the given KubeadmControlPlane that do not match its configuration hash.
+func OutdatedControlPlaneMachines(controlPlaneName, configHash string) func(machine *clusterv1.Machine) bool {
+	owned := OwnedControlPlaneMachines(controlPlaneName)
+	outdated := HasOutdatedConfiguration(configHash)
+	return func(machine *clusterv1.Machine) bool {
+		return owned(machine) && outdated(machine)
+	}
+}
+
+// HasProviderID returns a MachineFilter function to find all machines
+// that have been assigned a provider ID by their infrastructure provider.
+func HasProviderID() func(machine *clusterv1.Machine) bool {
+	return func(machine *clusterv1.Machine) bool {
+		if machine == nil {
+			return false
+		}
+		return machine.Spec.ProviderID != nil && *machine.Spec.ProviderID != ""
+	}
+}
+
+// MissingProviderID returns a MachineFilter function to find all machines
+// that are still waiting for their infrastructure provider to assign a provider ID.
+// Such machines cannot have a node yet.
+func MissingProviderID() func(machine *clusterv1.Machine) bool {
+	return func(machine *clusterv1.Machine) bool {
+		if machine == nil {
+			return false
+		}
+		return !HasProviderID()(machine)
+	}
+}
+
 // OlderThan returns a MachineFilter function to find all machines
 // that have a CreationTimestamp earlier than the given time.
 func OlderThan(t *metav1.Time) func(machine *clusterv1.Machine) bool {
@@ -110,6 +390,100 @@ func OlderThan(t *metav1.Time) func(machine *clusterv1.Machine) bool {
 	}
 }
 
+// CreatedBeforeRolloutAfter returns a MachineFilter function to find all machines
+// that were created before the given rollout time, once that time is before now.
+// No machine matches if the rollout time is nil or still ahead.
+func CreatedBeforeRolloutAfter(rolloutAfter *metav1.Time, now time.Time) func(machine *clusterv1.Machine) bool {
+	return func(machine *clusterv1.Machine) bool {
+		if machine == nil || rolloutAfter == nil || !rolloutAfter.Time.Before(now) {
+			return false
+		}
+		return machine.CreationTimestamp.Before(rolloutAfter)
+	}
+}
+
+// CertificatesExpireBefore returns a MachineFilter function to find all machines
+// whose certificates, as recorded in their CertificatesExpiryAnnotation, expire before the given time.
+// Machines without a valid annotation never match.
+func CertificatesExpireBefore(t time.Time) func(machine *clusterv1.Machine) bool {
+	return func(machine *clusterv1.Machine) bool {
+		if machine == nil {
+			return false
+		}
+		expiry, ok, _ := certificatesExpiryFromAnnotations(machine.Annotations)
+		return ok && expiry.Before(t)
+	}
+}
+
+// MatchesKubernetesVersion returns a MachineFilter function to find all machines
+// that run the given Kubernetes version. Versions are compared semantically, so "v1.17.0" matches "1.17.0".
+// Machines without a version never match.
+func MatchesKubernetesVersion(kubernetesVersion string) func(machine *clusterv1.Machine) bool {
+	return func(machine *clusterv1.Machine) bool {
+		if machine == nil || machine.Spec.Version == nil {
+			return false
+		}
+		machineVersion, err := version.ParseSemantic(*machine.Spec.Version)
+		if err != nil {
+			return false
+		}
+		cmp, err := machineVersion.Compare(kubernetesVersion)
+		if err != nil {
+			return false
+		}
+		return cmp == 0
+	}
+}
+
+// IsMarkedUnhealthy returns a MachineFilter function to find all machines
+// that have been marked unhealthy with the MachineUnhealthyAnnotation, or
+// whose remediation has been handed off with the OwnerRemediated condition.
+func IsMarkedUnhealthy() func(machine *clusterv1.Machine) bool {
+	return func(machine *clusterv1.Machine) bool {
+		if machine == nil {
+			return false
+		}
+		_, ok := machine.Annotations[clusterv1.MachineUnhealthyAnnotation]
+		return ok || conditions.IsFalse(machine, clusterv1.MachineOwnerRemediatedCondition)
+	}
+}
+
+// HasDeleteMachineAnnotation returns a MachineFilter function to find all machines
+// that have been marked for deletion with the DeleteMachineAnnotation.
+func HasDeleteMachineAnnotation() func(machine *clusterv1.Machine) bool {
+	return func(machine *clusterv1.Machine) bool {
+		if machine == nil {
+			return false
+		}
+		_, ok := machine.Annotations[clusterv1.DeleteMachineAnnotation]
+		return ok
+	}
+}
+
+// IsUnhealthy returns a MachineFilter function to find all machines
+// that have failed, have been marked unhealthy, or have no node yet.
+func IsUnhealthy() func(machine *clusterv1.Machine) bool {
+	markedUnhealthy := IsMarkedUnhealthy()
+	return func(machine *clusterv1.Machine) bool {
+		if machine == nil {
+			return false
+		}
+		return machine.Status.FailureReason != nil || machine.Status.FailureMessage != nil ||
+			machine.Status.NodeRef == nil || markedUnhealthy(machine)
+	}
+}
+
+// InFailureDomain returns a MachineFilter function to find all machines
+// that are placed in the given failure domain.
+func InFailureDomain(failureDomain string) func(machine *clusterv1.Machine) bool {
+	return func(machine *clusterv1.Machine) bool {
+		if machine == nil || machine.Spec.FailureDomain == nil {
+			return false
+		}
+		return *machine.Spec.FailureDomain == failureDomain
+	}
+}
+
 // FilterMachines returns a filtered list of machines
 func FilterMachines(machines []*clusterv1.Machine, filters ...func(machine *clusterv1.Machine) bool) []*clusterv1.Machine {
 	if len(filters) == 0 {
@@ -132,14 +506,62 @@ func FilterMachines(machines []*clusterv1.Machine, filters ...func(machine *clus
 	return filteredMachines
 }
 
+// parallelFilterThreshold is the number of machines below which FilterMachinesParallel filters sequentially,
+// since the goroutine overhead outweighs the gain for small lists such as control planes.
+const parallelFilterThreshold = 1000
+
+// FilterMachinesParallel returns a filtered list of machines, spreading the filtering of large lists across
+// the given number of workers. The order of the input list is preserved. Filters must be safe for concurrent use.
+func FilterMachinesParallel(machines []*clusterv1.Machine, workers int, filters ...func(machine *clusterv1.Machine) bool) []*clusterv1.Machine {
+	if len(filters) == 0 || workers <= 1 || len(machines) < parallelFilterThreshold {
+		return FilterMachines(machines, filters...)
+	}
+
+	// Split the machines into contiguous partitions so the results can be joined back in order.
+	partitionSize := (len(machines) + workers - 1) / workers
+	partitions := make([][]*clusterv1.Machine, 0, workers)
+	for start := 0; start < len(machines); start += partitionSize {
+		end := start + partitionSize
+		if end > len(machines) {
+			end = len(machines)
+		}
+		partitions = append(partitions, machines[start:end])
+	}
+
+	results := make([][]*clusterv1.Machine, len(partitions))
+	var wg sync.WaitGroup
+	for i := range partitions {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = FilterMachines(partitions[i], filters...)
+		}(i)
+	}
+	wg.Wait()
+
+	total := 0
+	for _, result := range results {
+		total += len(result)
+	}
+	filteredMachines := make([]*clusterv1.Machine, 0, total)
+	for _, result := range results {
+		filteredMachines = append(filteredMachines, result...)
+	}
+	return filteredMachines
+}
+
 // GetMachinesForCluster returns a list of machines that can be filtered or not.
 // If no filter is supplied then all machines associated with the target cluster are returned.
-func (m *ManagementCluster) GetMachinesForCluster(ctx context.Context, cluster types.NamespacedName, filters ...func(machine *clusterv1.Machine) bool) ([]*clusterv1.Machine, error) {
+func (m *Management) GetMachinesForCluster(ctx context.Context, cluster types.NamespacedName, filters ...func(machine *clusterv1.Machine) bool) ([]*clusterv1.Machine, error) {
 	selector := map[string]string{
 		clusterv1.ClusterLabelName: cluster.Name,
 	}
 	ml := &clusterv1.MachineList{}
 	if err := m.Client.List(ctx, ml, client.InNamespace(cluster.Namespace), client.MatchingLabels(selector)); err != nil {
+		if apierrors.IsForbidden(err) || apierrors.IsUnauthorized(err) {
+			// Most likely the controller's ServiceAccount is missing RBAC permissions rather than the apiserver being down.
+			return nil, errors.Wrapf(err, "not allowed to list machines in namespace %q, check that the controller has RBAC permissions to list machines.cluster.x-k8s.io", cluster.Namespace)
+		}
 		return nil, errors.Wrap(err, "failed to list machines")
 	}
 
@@ -151,10 +573,120 @@ func (m *ManagementCluster) GetMachinesForCluster(ctx context.Context, cluster t
 	return FilterMachines(machines, filters...), nil
 }
 
+// GetOldestMachines returns at most n of the cluster's machines that pass the given filters, oldest first.
+func (m *Management) GetOldestMachines(ctx context.Context, cluster types.NamespacedName, n int, filters ...func(machine *clusterv1.Machine) bool) ([]*clusterv1.Machine, error) {
+	if n <= 0 {
+		return []*clusterv1.Machine{}, nil
+	}
+	machines, err := m.GetMachinesForCluster(ctx, cluster, filters...)
+	if err != nil {
+		return nil, err
+	}
+	sortMachinesOldestFirst(machines)
+	if len(machines) > n {
+		machines = machines[:n]
+	}
+	return machines, nil
+}
+
+// GetControlPlaneMachinesForClusters returns the control plane machines of every cluster matching the given selector,
+// keyed by cluster. Clusters whose control plane is not a KubeadmControlPlane are skipped.
+func (m *Management) GetControlPlaneMachinesForClusters(ctx context.Context, clusterSelector labels.Selector) (map[types.NamespacedName][]*clusterv1.Machine, error) {
+	clusters := &clusterv1.ClusterList{}
+	if err := m.Client.List(ctx, clusters, client.MatchingLabelsSelector{Selector: clusterSelector}); err != nil {
+		return nil, errors.Wrap(err, "failed to list clusters")
+	}
+
+	machinesByCluster := make(map[types.NamespacedName][]*clusterv1.Machine, len(clusters.Items))
+	for _, cluster := range clusters.Items {
+		clusterKey := types.NamespacedName{Namespace: cluster.Namespace, Name: cluster.Name}
+		controlPlaneRef := cluster.Spec.ControlPlaneRef
+		if controlPlaneRef == nil || controlPlaneRef.Kind != "KubeadmControlPlane" || controlPlaneRef.Name == "" {
+			Log.Info("skipping cluster without a KubeadmControlPlane", "cluster-name", cluster.Name, "cluster-namespace", cluster.Namespace)
+			continue
+		}
+		machines, err := m.GetMachinesForCluster(ctx, clusterKey, OwnedControlPlaneMachines(controlPlaneRef.Name))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get control plane machines for cluster %s/%s", cluster.Namespace, cluster.Name)
+		}
+		machinesByCluster[clusterKey] = machines
+	}
+	return machinesByCluster, nil
+}
+
+// ControlPlaneVersionConverged reports whether every control plane machine owned by the named control plane
+// runs targetVersion. It also returns the names of the machines that run any other version, including machines
+// with no version set. A control plane without machines is not considered converged.
+func (m *Management) ControlPlaneVersionConverged(ctx context.Context, clusterKey types.NamespacedName, controlPlaneName, targetVersion string) (bool, []string, error) {
+	machines, err := m.GetMachinesForCluster(ctx, clusterKey, OwnedControlPlaneMachines(controlPlaneName))
+	if err != nil {
+		return false, nil, err
+	}
+
+	matchesVersion := MatchesKubernetesVersion(targetVersion)
+	outdated := []string{}
+	for _, machine := range machines {
+		if !matchesVersion(machine) {
+			outdated = append(outdated, machine.Name)
+		}
+	}
+	return len(machines) > 0 && len(outdated) == 0, outdated, nil
+}
+
+// VerifyControlPlaneImageVersions returns the image tag of every control plane static pod, by node name and component.
+// Unlike ControlPlaneVersionConverged it looks at what actually runs on the nodes rather than at the machine spec.
+// The tags are returned along with an error listing every component that does not run expectedVersion.
+func (m *Management) VerifyControlPlaneImageVersions(ctx context.Context, clusterKey types.NamespacedName, expectedVersion string) (map[string]map[string]string, error) {
+	cluster, err := m.getCluster(ctx, clusterKey)
+	if err != nil {
+		return nil, err
+	}
+	return cluster.controlPlaneImageVersions(ctx, expectedVersion)
+}
+
+// DetectKubeletVersionSkew returns the nodes whose kubelet version violates the version skew policy, i.e. is newer
+// than the oldest kube-apiserver of the control plane or more than maxSkewMinor minor versions older, mapped to their
+// kubelet version. The skewed nodes are returned along with an error listing the nodes whose kubelet version cannot
+// be parsed; nodes that have not reported a kubelet version yet are left out.
+func (m *Management) DetectKubeletVersionSkew(ctx context.Context, clusterKey types.NamespacedName, maxSkewMinor int) (map[string]string, error) {
+	cluster, err := m.getCluster(ctx, clusterKey)
+	if err != nil {
+		return nil, err
+	}
+	return cluster.kubeletVersionSkew(ctx, maxSkewMinor)
+}
+
+// GetControlPlaneEndpoint returns the control plane endpoint recorded on the Cluster as host:port.
+// ErrControlPlaneEndpointNotSet is returned if the endpoint is not set yet.
+func (m *Management) GetControlPlaneEndpoint(ctx context.Context, clusterKey types.NamespacedName) (string, error) {
+	cluster := &clusterv1.Cluster{}
+	if err := m.Client.Get(ctx, clusterKey, cluster); err != nil {
+		return "", errors.Wrapf(err, "failed to get Cluster %s/%s", clusterKey.Namespace, clusterKey.Name)
+	}
+	endpoint := cluster.Spec.ControlPlaneEndpoint
+	if endpoint.Host == "" || endpoint.Port == 0 {
+		return "", errors.Wrapf(ErrControlPlaneEndpointNotSet, "Cluster %s/%s", clusterKey.Namespace, clusterKey.Name)
+	}
+	return net.JoinHostPort(endpoint.Host, strconv.Itoa(int(endpoint.Port))), nil
+}
+
 // getCluster builds a cluster object.
 // The cluster is also populated with secrets stored on the management cluster that is required for
 // secure internal pod connections.
-func (m *ManagementCluster) getCluster(ctx context.Context, clusterKey types.NamespacedName) (*cluster, error) {
+// Built clusters are cached until the kubeconfig or etcd CA secret changes, or the cache is invalidated.
+func (m *Management) getCluster(ctx context.Context, clusterKey types.NamespacedName) (*cluster, error) {
+	kubeconfigSecret, err := secret.GetFromNamespacedName(ctx, m.Client, clusterKey, secret.Kubeconfig)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to retrieve kubeconfig secret for Cluster %s/%s", clusterKey.Namespace, clusterKey.Name)
+	}
+	etcdCASecret, err := m.getEtcdCASecret(ctx, clusterKey)
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := m.cachedCluster(clusterKey, kubeconfigSecret.ResourceVersion, etcdCASecret.ResourceVersion); ok {
+		return c, nil
+	}
+
 	// This adapter is for interop with the `remote` package.
 	adapterCluster := &clusterv1.Cluster{
 		ObjectMeta: metav1.ObjectMeta{
@@ -163,39 +695,121 @@ func (m *ManagementCluster) getCluster(ctx context.Context, clusterKey types.Nam
 		},
 	}
 
-	// TODO(chuckha): Unroll remote.NewClusterClient if we are unhappy with getting a restConfig twice.
-	// TODO(chuckha): Inject this dependency if necessary.
-	restConfig, err := remote.RESTConfig(ctx, m.Client, adapterCluster)
+	restConfig, c, err := m.workloadClusterClient(ctx, adapterCluster)
 	if err != nil {
 		return nil, err
 	}
-
-	c, err := remote.NewClusterClient(ctx, m.Client, adapterCluster, scheme.Scheme)
+	var etcdCA *EtcdCABundle
+	var etcdTLSConfig *tls.Config
+	if etcdCAKeyAvailable(etcdCASecret) {
+		etcdCA, err = etcdCABundleFromSecret(etcdCASecret, clusterKey)
+	} else {
+		// The etcd CA is managed outside of the management cluster, so no client certificate can be minted;
+		// fall back to the apiserver-etcd-client certificate the user provided along with the CA certificate.
+		etcdTLSConfig, err = m.apiServerEtcdClientTLSConfig(ctx, clusterKey, etcdCASecret)
+	}
 	if err != nil {
 		return nil, err
 	}
-	etcdCACert, etcdCAKey, err := m.GetEtcdCerts(ctx, clusterKey)
+	workloadCluster := &cluster{
+		client:                     c,
+		restConfig:                 restConfig,
+		etcdCA:                     etcdCA,
+		etcdTLSConfig:              etcdTLSConfig,
+		etcdClientOptions:          m.etcdClientOptions(),
+		etcdDialTimeout:            m.EtcdDialTimeout,
+		etcdClientCertConfig:       m.etcdClientCertConfig(clusterKey),
+		healthCheckConcurrency:     m.healthCheckConcurrency(),
+		healthCheckNodeTimeout:     m.healthCheckNodeTimeout(),
+		healthCacheTTL:             m.HealthCheckCacheTTL,
+		tolerateUnprovisionedNodes: m.TolerateUnprovisionedNodes,
+		etcdClients:                newEtcdClientPool(m.etcdClientIdleTimeout()),
+	}
+	if m.DiscoverNodesFromMachines {
+		workloadCluster.machineNodeNames = m.controlPlaneMachineNodeNames(clusterKey)
+	}
+	m.cacheCluster(clusterKey, workloadCluster, kubeconfigSecret.ResourceVersion, etcdCASecret.ResourceVersion)
+	return workloadCluster, nil
+}
+
+// workloadClusterClient returns the REST configuration and a client of the workload cluster, shared through the
+// Tracker if there is one.
+func (m *Management) workloadClusterClient(ctx context.Context, cluster *clusterv1.Cluster) (*rest.Config, ctrlclient.Client, error) {
+	if m.Tracker != nil {
+		restConfig, err := m.Tracker.GetRESTConfig(ctx, cluster)
+		if err != nil {
+			return nil, nil, err
+		}
+		c, err := m.Tracker.GetClient(ctx, cluster)
+		if err != nil {
+			return nil, nil, err
+		}
+		return restConfig, c, nil
+	}
+
+	restConfig, err := remote.RESTConfig(ctx, m.Client, cluster)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	c, err := remote.NewClusterClient(ctx, m.Client, cluster, scheme.Scheme)
+	if err != nil {
+		return nil, nil, err
+	}
+	return restConfig, c, nil
+}
+
+// controlPlaneMachineNodeNames returns a function listing the names of the nodes referenced by the control plane
+// machines of the given cluster.
+func (m *Management) controlPlaneMachineNodeNames(clusterKey types.NamespacedName) func(context.Context) ([]string, error) {
+	return func(ctx context.Context) ([]string, error) {
+		machines, err := m.GetMachinesForCluster(ctx, clusterKey, util.IsControlPlaneMachine)
+		if err != nil {
+			return nil, err
+		}
+		names := []string{}
+		for _, machine := range machines {
+			if machine.Status.NodeRef != nil {
+				names = append(names, machine.Status.NodeRef.Name)
+			}
+		}
+		return names, nil
 	}
-	return &cluster{
-		client:     c,
-		restConfig: restConfig,
-		etcdCACert: etcdCACert,
-		etcdCAkey:  etcdCAKey,
-	}, nil
 }
 
 // GetEtcdCerts returns the EtcdCA Cert and Key for a given cluster.
-func (m *ManagementCluster) GetEtcdCerts(ctx context.Context, cluster types.NamespacedName) ([]byte, []byte, error) {
+// Use GetEtcdCABundle to get them already parsed.
+func (m *Management) GetEtcdCerts(ctx context.Context, cluster types.NamespacedName) ([]byte, []byte, error) {
+	etcdCA, err := m.GetEtcdCABundle(ctx, cluster)
+	if err != nil {
+		return nil, nil, err
+	}
+	return etcdCA.CertData, etcdCA.KeyData, nil
+}
+
+// GetEtcdCABundle returns the EtcdCA of a given cluster, both PEM encoded and parsed.
+func (m *Management) GetEtcdCABundle(ctx context.Context, cluster types.NamespacedName) (*EtcdCABundle, error) {
+	etcdCASecret, err := m.getEtcdCASecret(ctx, cluster)
+	if err != nil {
+		return nil, err
+	}
+	return etcdCABundleFromSecret(etcdCASecret, cluster)
+}
+
+// getEtcdCASecret returns the secret holding the EtcdCA for a given cluster.
+func (m *Management) getEtcdCASecret(ctx context.Context, cluster types.NamespacedName) (*corev1.Secret, error) {
 	etcdCASecret := &corev1.Secret{}
 	etcdCAObjectKey := types.NamespacedName{
 		Namespace: cluster.Namespace,
 		Name:      fmt.Sprintf("%s-etcd", cluster.Name),
 	}
 	if err := m.Client.Get(ctx, etcdCAObjectKey, etcdCASecret); err != nil {
-		return nil, nil, errors.Wrapf(err, "failed to get secret; etcd CA bundle %s/%s", etcdCAObjectKey.Namespace, etcdCAObjectKey.Name)
+		return nil, errors.Wrapf(err, "failed to get secret; etcd CA bundle %s/%s", etcdCAObjectKey.Namespace, etcdCAObjectKey.Name)
 	}
+	return etcdCASecret, nil
+}
+
+// etcdCertsFromSecret extracts the EtcdCA Cert and Key from the EtcdCA secret of a given cluster.
+func etcdCertsFromSecret(etcdCASecret *corev1.Secret, cluster types.NamespacedName) ([]byte, []byte, error) {
 	crtData, ok := etcdCASecret.Data[secret.TLSCrtDataName]
 	if !ok {
 		return nil, nil, errors.Errorf("etcd tls crt does not exist for cluster %s/%s", cluster.Namespace, cluster.Name)
@@ -209,25 +823,60 @@ func (m *ManagementCluster) GetEtcdCerts(ctx context.Context, cluster types.Name
 
 type healthCheck func(context.Context) (healthCheckResult, error)
 
+// capNodeErrors keeps the first MaxAggregatedNodeErrors node errors and summarizes the rest.
+func (m *Management) capNodeErrors(nodeErrors []error) []error {
+	if m.MaxAggregatedNodeErrors <= 0 || len(nodeErrors) <= m.MaxAggregatedNodeErrors {
+		return nodeErrors
+	}
+	capped := append([]error{}, nodeErrors[:m.MaxAggregatedNodeErrors]...)
+	return append(capped, errors.Errorf("and %d more", len(nodeErrors)-m.MaxAggregatedNodeErrors))
+}
+
 // healthCheck will run a generic health check function and report any errors discovered.
 // It does some additional validation to make sure there is a 1;1 match between nodes and machines.
-func (m *ManagementCluster) healthCheck(ctx context.Context, check healthCheck, clusterKey types.NamespacedName, controlPlaneName string) error {
-	nodeChecks, err := check(ctx)
-	if err != nil {
-		return err
+// The excluded machines and their nodes are left out of both the node results and the validation.
+func (m *Management) healthCheck(ctx context.Context, check healthCheck, clusterKey types.NamespacedName, controlPlaneName string, excludeMachines []types.NamespacedName) error {
+	nodeChecks, checkErr := check(ctx)
+	excluded := machineKeyIn(excludeMachines)
+	if len(excludeMachines) > 0 {
+		excludedMachines, err := m.GetMachinesForCluster(ctx, clusterKey, OwnedControlPlaneMachines(controlPlaneName), excluded)
+		if err != nil {
+			return err
+		}
+		nodeChecks = nodeChecks.withoutNodesOf(excludedMachines)
 	}
 	errorList := []error{}
-	for nodeName, err := range nodeChecks {
+	if checkErr != nil {
+		errorList = append(errorList, checkErr)
+	}
+	nodeNames := make([]string, 0, len(nodeChecks))
+	for nodeName := range nodeChecks {
+		nodeNames = append(nodeNames, nodeName)
+	}
+	sort.Strings(nodeNames)
+	nodeErrors := []error{}
+	for _, nodeName := range nodeNames {
+		err := nodeChecks[nodeName]
+		if m.TolerateUnprovisionedNodes && err == ErrNodeNotProvisioned {
+			continue
+		}
 		if err != nil {
-			errorList = append(errorList, fmt.Errorf("node %q: %v", nodeName, err))
+			nodeErrors = append(nodeErrors, fmt.Errorf("node %q: %v", nodeName, err))
 		}
 	}
+	errorList = append(errorList, m.capNodeErrors(nodeErrors)...)
 	if len(errorList) != 0 {
+		// Keep the per node results that were gathered before the check failed.
+		if checkErr != nil && len(nodeChecks) > 0 {
+			healthy, _ := nodeChecks.countNodes()
+			return errors.Wrapf(kerrors.NewAggregate(errorList), "%d of %d nodes checked healthy", healthy, len(nodeChecks))
+		}
 		return kerrors.NewAggregate(errorList)
 	}
 
 	// Make sure Cluster API is aware of all the nodes.
-	machines, err := m.GetMachinesForCluster(ctx, clusterKey, OwnedControlPlaneMachines(controlPlaneName))
+	notExcluded := func(machine *clusterv1.Machine) bool { return !excluded(machine) }
+	machines, err := m.GetMachinesForCluster(ctx, clusterKey, OwnedControlPlaneMachines(controlPlaneName), notExcluded)
 	if err != nil {
 		return err
 	}
@@ -249,41 +898,228 @@ func (m *ManagementCluster) healthCheck(ctx context.Context, check healthCheck,
 }
 
 // TargetClusterControlPlaneIsHealthy checks every node for control plane health.
-func (m *ManagementCluster) TargetClusterControlPlaneIsHealthy(ctx context.Context, clusterKey types.NamespacedName, controlPlaneName string) error {
+// The given machines, typically ones that are being replaced, and their nodes are not checked.
+// Concurrent identical checks of the same cluster share a single run.
+func (m *Management) TargetClusterControlPlaneIsHealthy(ctx context.Context, clusterKey types.NamespacedName, controlPlaneName string, excludeMachines ...types.NamespacedName) error {
+	key := newInFlightCheckKey(ControlPlaneHealthCheck, clusterKey, controlPlaneName, excludeMachines)
+	return m.shareHealthCheck(ctx, key, func(ctx context.Context) error {
+		return m.retryHealthCheck(ctx, clusterKey, func(ctx context.Context) error {
+			return m.targetClusterControlPlaneIsHealthy(ctx, clusterKey, controlPlaneName, excludeMachines)
+		})
+	})
+}
+
+func (m *Management) targetClusterControlPlaneIsHealthy(ctx context.Context, clusterKey types.NamespacedName, controlPlaneName string, excludeMachines []types.NamespacedName) (reterr error) {
+	observation := HealthCheckObservation{Cluster: clusterKey, Check: ControlPlaneHealthCheck}
+	defer func(start time.Time) {
+		observation.Duration = time.Since(start)
+		observation.Err = reterr
+		m.metricsSink().ObserveHealthCheck(observation)
+	}(time.Now())
+
 	cluster, err := m.getCluster(ctx, clusterKey)
 	if err != nil {
 		return err
 	}
-	return m.healthCheck(ctx, cluster.controlPlaneIsHealthy, clusterKey, controlPlaneName)
+	if m.PingAPIServer {
+		if err := cluster.pingAPIServer(ctx); err != nil {
+			return err
+		}
+	}
+	check := func(ctx context.Context) (healthCheckResult, error) {
+		response, err := cluster.controlPlaneIsHealthy(ctx)
+		observation.HealthyNodes, observation.UnhealthyNodes = response.countNodes()
+		return response, err
+	}
+	return m.healthCheck(ctx, check, clusterKey, controlPlaneName, excludeMachines)
 }
 
 // TargetClusterEtcdIsHealthy runs a series of checks over a target cluster's etcd cluster.
 // In addition, it verifies that there are the same number of etcd members as control plane Machines.
-func (m *ManagementCluster) TargetClusterEtcdIsHealthy(ctx context.Context, clusterKey types.NamespacedName, controlPlaneName string) error {
+// If the KubeadmControlPlane configures external etcd, its endpoints are checked instead.
+// The given machines, typically ones that are being replaced, and their nodes are not checked.
+// Concurrent identical checks of the same cluster share a single run.
+func (m *Management) TargetClusterEtcdIsHealthy(ctx context.Context, clusterKey types.NamespacedName, controlPlaneName string, excludeMachines ...types.NamespacedName) error {
+	key := newInFlightCheckKey(EtcdHealthCheck, clusterKey, controlPlaneName, excludeMachines)
+	return m.shareHealthCheck(ctx, key, func(ctx context.Context) error {
+		return m.retryHealthCheck(ctx, clusterKey, func(ctx context.Context) error {
+			return m.targetClusterEtcdIsHealthy(ctx, clusterKey, controlPlaneName, excludeMachines)
+		})
+	})
+}
+
+// retryHealthCheck runs check until it passes, retrying it as configured by HealthCheckRetryBackoff, within
+// HealthCheckTimeout. Cached control plane health is dropped before every retry, so that retries query the workload
+// cluster again. Etcd client authentication failures are not retried, as they do not go away by themselves.
+func (m *Management) retryHealthCheck(ctx context.Context, clusterKey types.NamespacedName, check func(ctx context.Context) error) error {
+	if m.HealthCheckTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.HealthCheckTimeout)
+		defer cancel()
+	}
+
+	backoff := m.HealthCheckRetryBackoff
+	for {
+		err := check(ctx)
+		if err == nil || backoff.Steps < 2 || isEtcdClientAuthFailure(err) {
+			return err
+		}
+		retryAfter := time.NewTimer(backoff.Step())
+		select {
+		case <-retryAfter.C:
+		case <-ctx.Done():
+			retryAfter.Stop()
+			return err
+		}
+		m.InvalidateHealthCheckCache(clusterKey)
+	}
+}
+
+func (m *Management) targetClusterEtcdIsHealthy(ctx context.Context, clusterKey types.NamespacedName, controlPlaneName string, excludeMachines []types.NamespacedName) (reterr error) {
+	observation := HealthCheckObservation{Cluster: clusterKey, Check: EtcdHealthCheck}
+	defer func(start time.Time) {
+		observation.Duration = time.Since(start)
+		observation.Err = reterr
+		m.metricsSink().ObserveHealthCheck(observation)
+	}(time.Now())
+
+	external, err := m.getExternalEtcd(ctx, clusterKey, controlPlaneName)
+	if err != nil {
+		return err
+	}
+	if external != nil {
+		return m.externalEtcdIsHealthy(ctx, clusterKey, external, &observation)
+	}
+
 	cluster, err := m.getCluster(ctx, clusterKey)
 	if err != nil {
 		return err
 	}
-	return m.healthCheck(ctx, cluster.etcdIsHealthy, clusterKey, controlPlaneName)
+	if m.PingAPIServer {
+		if err := cluster.pingAPIServer(ctx); err != nil {
+			return err
+		}
+	}
+	excludedNodes, err := m.nodeNamesOf(ctx, clusterKey, controlPlaneName, excludeMachines)
+	if err != nil {
+		return err
+	}
+	check := func(ctx context.Context) (healthCheckResult, error) {
+		response, summary, err := cluster.etcdHealth(ctx, excludedNodes...)
+		observation.HealthyNodes, observation.UnhealthyNodes = response.countNodes()
+		observation.EtcdMembers = summary.members
+		observation.EtcdAlarms = summary.alarms
+		observation.ExpectedEtcdMembers = summary.expectedMembers
+		return response, err
+	}
+	return m.healthCheck(ctx, check, clusterKey, controlPlaneName, excludeMachines)
 }
 
+// etcdClientGenerator creates an etcd client that talks to the etcd member running on the given node.
+type etcdClientGenerator func(nodeName string, tlsConfig *tls.Config, options ...etcd.EtcdClientOption) (*etcd.Client, error)
+
 // cluster are operations on target clusters.
 type cluster struct {
 	client ctrlclient.Client
 	// restConfig is required for the proxy.
-	restConfig            *rest.Config
-	etcdCACert, etcdCAkey []byte
+	restConfig *rest.Config
+	// etcdCA mints the etcd client certificate; it is nil if the etcd CA key is not available, in which case
+	// etcdTLSConfig is set from the apiserver-etcd-client certificate instead.
+	etcdCA *EtcdCABundle
+	// healthCheckConcurrency bounds the number of nodes checked concurrently; defaultHealthCheckConcurrency is used if it is not positive.
+	healthCheckConcurrency int
+	// healthCheckNodeTimeout bounds the check of a single node; defaultHealthCheckNodeTimeout is used if it is not positive.
+	healthCheckNodeTimeout time.Duration
+	// etcdClientCertConfig is the subject of the etcd client certificates; defaultEtcdClientCertConfig is used if it has no CommonName.
+	etcdClientCertConfig certs.Config
+	// etcdClientOptions are passed to every etcd client created for this cluster.
+	etcdClientOptions []etcd.EtcdClientOption
+	// etcdDialTimeout bounds the port forward to etcd members; the proxy default is used if it is zero.
+	etcdDialTimeout time.Duration
+	// etcdClientGenerator overrides how etcd clients are created; getEtcdClientForNode is used if it is nil.
+	etcdClientGenerator etcdClientGenerator
+	// etcdClients keeps the etcd clients of this cluster open for reuse; clients are closed after every use if it is nil.
+	etcdClients *etcdClientPool
+	// apiServerCertificateGetter overrides how the serving certificate of an API server is read; getAPIServerCertificate
+	// is used if it is nil.
+	apiServerCertificateGetter func(ctx context.Context, nodeName string) (*x509.Certificate, error)
+
+	// etcdTLSConfig is the etcd client TLS bundle, generated from etcdCA on first use.
+	etcdTLSLock   sync.Mutex
+	etcdTLSConfig *tls.Config
+
+	// tolerateUnprovisionedNodes makes etcdHealth expect no etcd member on nodes without a provider ID.
+	tolerateUnprovisionedNodes bool
+
+	// machineNodeNames lists the nodes referenced by the control plane machines. If it is set, getControlPlaneNodes
+	// falls back to these nodes when no node has the control plane role label.
+	machineNodeNames func(ctx context.Context) ([]string, error)
+
+	// healthCacheTTL is how long the result of controlPlaneIsHealthy is reused; results are not cached if it is not positive.
+	healthCacheTTL  time.Duration
+	healthCacheLock sync.Mutex
+	healthCache     *healthSnapshot
 }
 
-// generateEtcdTLSClientBundle builds an etcd client TLS bundle from the Etcd CA for this cluster.
+// etcdClientForNode returns a client that talks to the etcd member running on the given node.
+// The client is reused from the pool of this cluster if there is one; callers close it once they are done either way.
+func (c *cluster) etcdClientForNode(nodeName string, tlsConfig *tls.Config) (*etcd.Client, error) {
+	newClient := func() (*etcd.Client, error) {
+		if c.etcdClientGenerator != nil {
+			return c.etcdClientGenerator(nodeName, tlsConfig, c.etcdClientOptions...)
+		}
+		return c.getEtcdClientForNode(nodeName, tlsConfig, c.etcdClientOptions...)
+	}
+	if c.etcdClients == nil {
+		return newClient()
+	}
+	return c.etcdClients.get(nodeName, newClient)
+}
+
+// pingAPIServer checks that the API server answers a request for its version.
+func (c *cluster) pingAPIServer(ctx context.Context) error {
+	if c.restConfig == nil {
+		return errors.Wrap(ErrWorkloadClusterUnreachable, "missing REST config")
+	}
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(c.restConfig)
+	if err != nil {
+		return errors.Wrapf(ErrWorkloadClusterUnreachable, "failed to create discovery client: %v", err)
+	}
+	if err := discoveryClient.RESTClient().Get().AbsPath("/version").Context(ctx).Do().Error(); err != nil {
+		return errors.Wrapf(ErrWorkloadClusterUnreachable, "%v", err)
+	}
+	return nil
+}
+
+// generateEtcdTLSClientBundle returns an etcd client TLS bundle from the Etcd CA for this cluster.
+// The client certificate is minted on first use and reused afterwards; a cluster is rebuilt when its etcd CA changes.
 func (c *cluster) generateEtcdTLSClientBundle() (*tls.Config, error) {
-	clientCert, err := generateClientCert(c.etcdCACert, c.etcdCAkey)
+	c.etcdTLSLock.Lock()
+	defer c.etcdTLSLock.Unlock()
+
+	if c.etcdTLSConfig == nil {
+		tlsConfig, err := c.newEtcdTLSClientBundle()
+		if err != nil {
+			return nil, err
+		}
+		c.etcdTLSConfig = tlsConfig
+	}
+	return c.etcdTLSConfig, nil
+}
+
+// newEtcdTLSClientBundle builds an etcd client TLS bundle with a new client certificate.
+func (c *cluster) newEtcdTLSClientBundle() (*tls.Config, error) {
+	cfg := c.etcdClientCertConfig
+	if cfg.CommonName == "" {
+		cfg = defaultEtcdClientCertConfig()
+	}
+	clientCert, err := generateClientCert(c.etcdCA, cfg)
 	if err != nil {
 		return nil, err
 	}
 
 	caPool := x509.NewCertPool()
-	caPool.AppendCertsFromPEM(c.etcdCACert)
+	caPool.AddCert(c.etcdCA.Cert)
 
 	return &tls.Config{
 		RootCAs:      caPool,
@@ -300,49 +1136,245 @@ func (c *cluster) getControlPlaneNodes(ctx context.Context) (*corev1.NodeList, e
 	if err := c.client.List(ctx, nodes, client.MatchingLabels(labels)); err != nil {
 		return nil, err
 	}
+	if len(nodes.Items) > 0 || c.machineNodeNames == nil {
+		return nodes, nil
+	}
+	return c.getMachineNodes(ctx)
+}
+
+// getMachineNodes returns the nodes referenced by the control plane machines.
+// Nodes that do not exist (yet) are left out.
+func (c *cluster) getMachineNodes(ctx context.Context) (*corev1.NodeList, error) {
+	names, err := c.machineNodeNames(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to discover control plane nodes from machines")
+	}
+	Log.Info("No nodes have the control plane role label, discovering control plane nodes from machines", "nodes", names)
+
+	nodes := &corev1.NodeList{}
+	for _, name := range names {
+		node := corev1.Node{}
+		if err := c.client.Get(ctx, types.NamespacedName{Name: name}, &node); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, errors.Wrapf(err, "failed to get node %q", name)
+		}
+		nodes.Items = append(nodes.Items, node)
+	}
 	return nodes, nil
 }
 
 // healthCheckResult maps nodes that are checked to any errors the node has related to the check.
 type healthCheckResult map[string]error
 
+// withoutNodesOf returns a copy of the result without the nodes of the given machines.
+func (h healthCheckResult) withoutNodesOf(machines []*clusterv1.Machine) healthCheckResult {
+	if h == nil {
+		return nil
+	}
+	result := make(healthCheckResult, len(h))
+	for nodeName, err := range h {
+		result[nodeName] = err
+	}
+	for _, machine := range machines {
+		if machine.Status.NodeRef != nil {
+			delete(result, machine.Status.NodeRef.Name)
+		}
+	}
+	return result
+}
+
+// nodeNamesOf returns the names of the nodes of the given control plane machines, if they have one.
+func (m *Management) nodeNamesOf(ctx context.Context, clusterKey types.NamespacedName, controlPlaneName string, machineKeys []types.NamespacedName) ([]string, error) {
+	if len(machineKeys) == 0 {
+		return nil, nil
+	}
+	machines, err := m.GetMachinesForCluster(ctx, clusterKey, OwnedControlPlaneMachines(controlPlaneName), machineKeyIn(machineKeys))
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(machines))
+	for _, machine := range machines {
+		if machine.Status.NodeRef != nil {
+			names = append(names, machine.Status.NodeRef.Name)
+		}
+	}
+	return names, nil
+}
+
+// machineKeyIn returns a MachineFilter function to find the machines with one of the given keys.
+func machineKeyIn(keys []types.NamespacedName) func(machine *clusterv1.Machine) bool {
+	return func(machine *clusterv1.Machine) bool {
+		for _, key := range keys {
+			if machine.Namespace == key.Namespace && machine.Name == key.Name {
+				return true
+			}
+		}
+		return false
+	}
+}
+
 // controlPlaneIsHealthy does a best effort check of the control plane components the kubeadm control plane cares about.
 // The return map is a map of node names as keys to error that that node encountered.
 // All nodes will exist in the map with nil errors if there were no errors for that node.
+// A result observed less than healthCacheTTL ago is returned without querying the workload cluster again.
 func (c *cluster) controlPlaneIsHealthy(ctx context.Context) (healthCheckResult, error) {
+	if response, ok := c.cachedControlPlaneHealth(); ok {
+		return response, nil
+	}
+	response, err := c.checkControlPlaneHealth(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.cacheControlPlaneHealth(response)
+	return response, nil
+}
+
+// checkControlPlaneHealth checks the static pods of every control plane node against the workload cluster.
+func (c *cluster) checkControlPlaneHealth(ctx context.Context) (healthCheckResult, error) {
 	controlPlaneNodes, err := c.getControlPlaneNodes(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	response := make(map[string]error)
+	components, err := c.staticPodComponents(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	nodeNames := make([]string, 0, len(controlPlaneNodes.Items))
 	for _, node := range controlPlaneNodes.Items {
-		name := node.Name
-		response[name] = nil
-		apiServerPodKey := types.NamespacedName{
-			Namespace: metav1.NamespaceSystem,
-			Name:      staticPodName("kube-apiserver", name),
-		}
-		apiServerPod := &corev1.Pod{}
-		if err := c.client.Get(ctx, apiServerPodKey, apiServerPod); err != nil {
+		nodeNames = append(nodeNames, node.Name)
+	}
+	return c.checkNodes(ctx, nodeNames, func(ctx context.Context, nodeName string) error {
+		return c.staticPodsAreReady(ctx, nodeName, components)
+	}), nil
+}
+
+// nodeCheckContext returns the context for checking a single node, which is done after healthCheckNodeTimeout.
+func (c *cluster) nodeCheckContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeout := c.healthCheckNodeTimeout
+	if timeout <= 0 {
+		timeout = defaultHealthCheckNodeTimeout
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// checkNodes runs check for every given node concurrently, with at most healthCheckConcurrency checks running at a
+// time, and returns the error of every check keyed by node name. Every check gets a context that is done after
+// healthCheckNodeTimeout, so that a slow node does not hold up the check of the others.
+func (c *cluster) checkNodes(ctx context.Context, nodeNames []string, check func(ctx context.Context, nodeName string) error) healthCheckResult {
+	workers := c.healthCheckConcurrency
+	if workers <= 0 {
+		workers = defaultHealthCheckConcurrency
+	}
+
+	var (
+		lock sync.Mutex
+		wg   sync.WaitGroup
+	)
+	sem := make(chan struct{}, workers)
+	response := make(healthCheckResult, len(nodeNames))
+	for _, name := range nodeNames {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			nodeCtx, cancel := c.nodeCheckContext(ctx)
+			defer cancel()
+			err := check(nodeCtx, name)
+
+			lock.Lock()
+			defer lock.Unlock()
 			response[name] = err
+		}(name)
+	}
+	wg.Wait()
+
+	return response
+}
+
+// staticPodsAreReady checks that the static pods of the given components on the given node are ready. Every component
+// is checked, and the errors of the components that are not ready are aggregated.
+func (c *cluster) staticPodsAreReady(ctx context.Context, nodeName string, components []string) error {
+	errs := []error{}
+	for _, component := range components {
+		pod, err := c.getStaticPod(ctx, component, nodeName)
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "failed to get %s static pod", component))
 			continue
 		}
-		response[name] = checkStaticPodReadyCondition(apiServerPod)
-
-		controllerManagerPodKey := types.NamespacedName{
-			Namespace: metav1.NamespaceSystem,
-			Name:      staticPodName("kube-controller-manager", name),
+		if err := checkStaticPodReadyCondition(pod); err != nil {
+			errs = append(errs, err)
 		}
-		controllerManagerPod := &corev1.Pod{}
-		if err := c.client.Get(ctx, controllerManagerPodKey, controllerManagerPod); err != nil {
-			response[name] = err
-			continue
+	}
+	// A single error is returned as is, so that its cause, e.g. a timeout, can still be told apart.
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return kerrors.NewAggregate(errs)
+}
+
+// controlPlaneComponents are the static pods kubeadm runs on every control plane node, besides etcd.
+var controlPlaneComponents = []string{"kube-apiserver", "kube-controller-manager", "kube-scheduler"}
+
+// staticPodComponents returns the components whose static pods run on every control plane node: the
+// controlPlaneComponents, and etcd unless the kubeadm-config ConfigMap configures external etcd. Stacked etcd is
+// assumed if there is no kubeadm-config ConfigMap, as it is the kubeadm default.
+func (c *cluster) staticPodComponents(ctx context.Context) ([]string, error) {
+	components := append([]string{}, controlPlaneComponents...)
+	clusterConfiguration, err := c.getClusterConfiguration(ctx)
+	if err != nil && !apierrors.IsNotFound(errors.Cause(err)) {
+		return nil, err
+	}
+	if clusterConfiguration == nil || clusterConfiguration.Etcd.External == nil {
+		components = append(components, "etcd")
+	}
+	return components, nil
+}
+
+// controlPlaneImageVersions returns the image tag of the control plane static pods on every control plane node.
+// Components whose pod cannot be fetched are left out of the result and reported in the error.
+func (c *cluster) controlPlaneImageVersions(ctx context.Context, expectedVersion string) (map[string]map[string]string, error) {
+	controlPlaneNodes, err := c.getControlPlaneNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make(map[string]map[string]string, len(controlPlaneNodes.Items))
+	errList := []error{}
+	for _, node := range controlPlaneNodes.Items {
+		versions[node.Name] = map[string]string{}
+		for _, component := range controlPlaneComponents {
+			pod, err := c.getStaticPod(ctx, component, node.Name)
+			if err != nil {
+				errList = append(errList, errors.Wrapf(err, "failed to get %s pod on node %q", component, node.Name))
+				continue
+			}
+			_, tag := splitImage(staticPodImage(pod, component))
+			versions[node.Name][component] = tag
+			if strings.TrimPrefix(tag, "v") != strings.TrimPrefix(expectedVersion, "v") {
+				errList = append(errList, errors.Errorf("%s on node %q runs version %q instead of %q", component, node.Name, tag, expectedVersion))
+			}
 		}
-		response[name] = checkStaticPodReadyCondition(controllerManagerPod)
 	}
+	return versions, kerrors.NewAggregate(errList)
+}
 
-	return response, nil
+// getStaticPod returns the static pod of a control plane component running on the given node.
+func (c *cluster) getStaticPod(ctx context.Context, component, nodeName string) (*corev1.Pod, error) {
+	podKey := types.NamespacedName{
+		Namespace: metav1.NamespaceSystem,
+		Name:      staticPodName(component, nodeName),
+	}
+	pod := &corev1.Pod{}
+	if err := c.client.Get(ctx, podKey, pod); err != nil {
+		return nil, err
+	}
+	return pod, nil
 }
 
 // etcdIsHealthy runs checks for every etcd member in the cluster to satisfy our definition of healthy.
@@ -350,42 +1382,105 @@ func (c *cluster) controlPlaneIsHealthy(ctx context.Context) (healthCheckResult,
 // It's used a signal for if we should allow a target cluster to scale up, scale down or upgrade.
 // It returns a map of nodes checked along with an error for a given node.
 func (c *cluster) etcdIsHealthy(ctx context.Context) (healthCheckResult, error) {
+	response, _, err := c.etcdHealth(ctx)
+	return response, err
+}
+
+// etcdSummary holds cluster wide etcd figures gathered while checking etcd health.
+type etcdSummary struct {
+	// members is the number of distinct etcd members reported by the checked nodes.
+	members int
+	// alarms is the number of alarms raised across the etcd cluster.
+	alarms int
+	// expectedMembers is the number of etcd members expected from the control plane nodes.
+	expectedMembers int
+}
+
+// etcdHealth implements etcdIsHealthy and also returns a summary of the etcd cluster as seen during the check.
+// The excluded nodes, e.g. those of machines being replaced, are not checked, and neither they nor their etcd members
+// are counted when comparing the etcd members to the control plane nodes.
+func (c *cluster) etcdHealth(ctx context.Context, excludeNodes ...string) (healthCheckResult, etcdSummary, error) {
 	var knownClusterID uint64
 	var knownMemberIDSet etcdutil.UInt64Set
+	var summary etcdSummary
+	alarmsCounted := false
 
 	controlPlaneNodes, err := c.getControlPlaneNodes(ctx)
 	if err != nil {
-		return nil, err
+		return nil, summary, err
+	}
+	excluded := make(map[string]bool, len(excludeNodes))
+	for _, name := range excludeNodes {
+		excluded[name] = true
+	}
+	nodes := make([]corev1.Node, 0, len(controlPlaneNodes.Items))
+	for _, node := range controlPlaneNodes.Items {
+		if !excluded[node.Name] {
+			nodes = append(nodes, node)
+		}
 	}
 
 	tlsConfig, err := c.generateEtcdTLSClientBundle()
 	if err != nil {
-		return nil, err
+		return nil, summary, err
 	}
 
+	unprovisioned := 0
+	// Fail once rather than for every node if the etcd client cannot authenticate at all.
+	if err := c.probeEtcdAuthentication(ctx, nodes, tlsConfig); err != nil {
+		return nil, summary, err
+	}
+
+	// List etcd members through every member concurrently. This checks that the members are healthy, because the
+	// requests go through consensus.
+	var membersLock sync.Mutex
+	membersByNode := map[string][]*etcd.Member{}
+	provisionedNodeNames := []string{}
+	for _, node := range nodes {
+		if node.Spec.ProviderID != "" {
+			provisionedNodeNames = append(provisionedNodeNames, node.Name)
+		}
+	}
+	listErrs := c.checkNodes(ctx, provisionedNodeNames, func(ctx context.Context, nodeName string) error {
+		members, err := c.etcdMembersForNode(ctx, nodeName, tlsConfig)
+		if err != nil {
+			return err
+		}
+		membersLock.Lock()
+		defer membersLock.Unlock()
+		membersByNode[nodeName] = members
+		return nil
+	})
+
+	// Compare the member lists in node order, so that the result does not depend on which member answered first.
 	response := make(map[string]error)
-	for _, node := range controlPlaneNodes.Items {
+	for _, node := range nodes {
 		name := node.Name
 		response[name] = nil
 		if node.Spec.ProviderID == "" {
-			response[name] = errors.New("empty provider ID")
+			response[name] = ErrNodeNotProvisioned
+			unprovisioned++
 			continue
 		}
 
-		// Create the etcd client for the etcd Pod scheduled on the Node
-		etcdClient, err := c.getEtcdClientForNode(name, tlsConfig)
-		if err != nil {
-			response[name] = errors.Wrap(err, "failed to create etcd client")
+		if err := listErrs[name]; err != nil {
+			response[name] = err
 			continue
 		}
-
-		// List etcd members. This checks that the member is healthy, because the request goes through consensus.
-		members, err := etcdClient.Members(ctx)
-		if err != nil {
-			response[name] = errors.Wrap(err, "failed to list etcd members using etcd client")
+		members := membersByNode[name]
+		member := etcdutil.MemberForName(members, name)
+		if member == nil {
+			response[name] = errors.New("etcd member list does not include a member for this node")
 			continue
 		}
-		member := etcdutil.MemberForName(members, name)
+
+		// Alarms are cluster wide, so count them from the first member list that is returned.
+		if !alarmsCounted {
+			for _, m := range members {
+				summary.alarms += len(m.Alarms)
+			}
+			alarmsCounted = true
+		}
 
 		// Check that the member reports no alarms.
 		if len(member.Alarms) > 0 {
@@ -403,7 +1498,7 @@ func (c *cluster) etcdIsHealthy(ctx context.Context) (healthCheckResult, error)
 		}
 
 		// Check that the member list is stable.
-		memberIDSet := etcdutil.MemberIDSet(members)
+		memberIDSet := etcdutil.MemberIDSet(membersNotOn(members, excluded))
 		if knownMemberIDSet.Len() == 0 {
 			knownMemberIDSet = memberIDSet
 		} else {
@@ -417,15 +1512,102 @@ func (c *cluster) etcdIsHealthy(ctx context.Context) (healthCheckResult, error)
 
 	// Check that there is exactly one etcd member for every control plane machine.
 	// There should be no etcd members added "out of band.""
-	if len(controlPlaneNodes.Items) != len(knownMemberIDSet) {
-		return response, errors.Errorf("there are %d control plane nodes, but %d etcd members", len(controlPlaneNodes.Items), len(knownMemberIDSet))
+	summary.members = len(knownMemberIDSet)
+	expectedMembers := len(nodes)
+	if c.tolerateUnprovisionedNodes {
+		// Nodes without a provider ID are still being provisioned and are not expected to run an etcd member yet.
+		expectedMembers -= unprovisioned
+	}
+	summary.expectedMembers = expectedMembers
+	if expectedMembers != len(knownMemberIDSet) {
+		return response, summary, errors.Errorf("there are %d control plane nodes, but %d etcd members", expectedMembers, len(knownMemberIDSet))
 	}
 
-	return response, nil
+	return response, summary, nil
+}
+
+// membersNotOn returns the etcd members that do not run on one of the given nodes.
+func membersNotOn(members []*etcd.Member, nodes map[string]bool) []*etcd.Member {
+	result := make([]*etcd.Member, 0, len(members))
+	for _, member := range members {
+		if !nodes[member.Name] {
+			result = append(result, member)
+		}
+	}
+	return result
+}
+
+// probeEtcdAuthentication lists the etcd members through the first reachable member to find out whether the etcd
+// client is able to authenticate. Members that cannot be reached are skipped; the probe is inconclusive and passes
+// if no member can be reached.
+func (c *cluster) probeEtcdAuthentication(ctx context.Context, nodes []corev1.Node, tlsConfig *tls.Config) error {
+	for _, node := range nodes {
+		if node.Spec.ProviderID == "" {
+			continue
+		}
+		nodeCtx, cancel := c.nodeCheckContext(ctx)
+		_, err := c.etcdMembersForNode(nodeCtx, node.Name, tlsConfig)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if isEtcdAuthError(err) {
+			return errors.Wrapf(ErrEtcdClientAuthFailed, "node %q: %v", node.Name, err)
+		}
+	}
+	return nil
+}
+
+// isEtcdClientAuthFailure reports whether err is, or aggregates, ErrEtcdClientAuthFailed.
+func isEtcdClientAuthFailure(err error) bool {
+	cause := errors.Cause(err)
+	if aggregate, ok := cause.(kerrors.Aggregate); ok {
+		for _, err := range aggregate.Errors() {
+			if isEtcdClientAuthFailure(err) {
+				return true
+			}
+		}
+		return false
+	}
+	return cause == ErrEtcdClientAuthFailed
+}
+
+// isEtcdAuthError reports whether err is caused by etcd rejecting the client's credentials or by a failed TLS handshake.
+func isEtcdAuthError(err error) bool {
+	cause := errors.Cause(err)
+	switch cause.(type) {
+	case x509.UnknownAuthorityError, x509.CertificateInvalidError, x509.HostnameError:
+		return true
+	}
+	if etcdErr, ok := cause.(rpctypes.EtcdError); ok {
+		return etcdErr.Code() == codes.Unauthenticated || etcdErr.Code() == codes.PermissionDenied
+	}
+	switch status.Code(cause) {
+	case codes.Unauthenticated, codes.PermissionDenied:
+		return true
+	}
+	// gRPC reports failed TLS handshakes as unavailable connections.
+	return strings.Contains(cause.Error(), "authentication handshake failed")
+}
+
+// etcdMembersForNode lists the etcd members through the etcd member running on the given node.
+func (c *cluster) etcdMembersForNode(ctx context.Context, nodeName string, tlsConfig *tls.Config) ([]*etcd.Member, error) {
+	// Create the etcd client for the etcd Pod scheduled on the Node
+	etcdClient, err := c.etcdClientForNode(nodeName, tlsConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create etcd client")
+	}
+	defer etcdClient.Close()
+
+	members, err := etcdClient.Members(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list etcd members using etcd client")
+	}
+	return members, nil
 }
 
 // getEtcdClientForNode returns a client that talks directly to an etcd instance living on a particular node.
-func (c *cluster) getEtcdClientForNode(nodeName string, tlsConfig *tls.Config) (*etcd.Client, error) {
+func (c *cluster) getEtcdClientForNode(nodeName string, tlsConfig *tls.Config, options ...etcd.EtcdClientOption) (*etcd.Client, error) {
 	// This does not support external etcd.
 	p := proxy.Proxy{
 		Kind:         "pods",
@@ -435,46 +1617,46 @@ func (c *cluster) getEtcdClientForNode(nodeName string, tlsConfig *tls.Config) (
 		TLSConfig:    tlsConfig,
 		Port:         2379, // TODO: the pod doesn't expose a port. Is this a problem?
 	}
-	dialer, err := proxy.NewDialer(p)
+	dialerOptions := []func(*proxy.Dialer) error{}
+	if c.etcdDialTimeout != 0 {
+		dialerOptions = append(dialerOptions, proxy.DialTimeout(c.etcdDialTimeout))
+	}
+	dialer, err := proxy.NewDialer(p, dialerOptions...)
 	if err != nil {
 		return nil, err
 	}
-	etcdclient, err := etcd.NewEtcdClient("127.0.0.1", dialer.DialContextWithAddr, tlsConfig)
+	etcdclient, err := etcd.NewEtcdClient("127.0.0.1", dialer.DialContextWithAddr, tlsConfig, options...)
 	if err != nil {
 		return nil, err
 	}
 	customClient, err := etcd.NewClientWithEtcd(etcdclient)
 	if err != nil {
+		etcdclient.Close()
 		return nil, err
 	}
 	return customClient, nil
 }
 
-func generateClientCert(caCertEncoded, caKeyEncoded []byte) (tls.Certificate, error) {
-	privKey, err := certs.NewPrivateKey()
-	if err != nil {
-		return tls.Certificate{}, err
-	}
-	caCert, err := certs.DecodeCertPEM(caCertEncoded)
-	if err != nil {
-		return tls.Certificate{}, err
+// defaultEtcdClientCertConfig returns the subject of the etcd client certificates minted by Cluster API.
+func defaultEtcdClientCertConfig() certs.Config {
+	return certs.Config{
+		CommonName: "cluster-api.x-k8s.io",
 	}
-	caKey, err := certs.DecodePrivateKeyPEM(caKeyEncoded)
+}
+
+func generateClientCert(ca *EtcdCABundle, cfg certs.Config) (tls.Certificate, error) {
+	privKey, err := certs.NewPrivateKey()
 	if err != nil {
 		return tls.Certificate{}, err
 	}
-	x509Cert, err := newClientCert(caCert, privKey, caKey)
+	x509Cert, err := newClientCert(ca.Cert, privKey, ca.Key, cfg)
 	if err != nil {
 		return tls.Certificate{}, err
 	}
 	return tls.X509KeyPair(certs.EncodeCertPEM(x509Cert), certs.EncodePrivateKeyPEM(privKey))
 }
 
-func newClientCert(caCert *x509.Certificate, key *rsa.PrivateKey, caKey *rsa.PrivateKey) (*x509.Certificate, error) {
-	cfg := certs.Config{
-		CommonName: "cluster-api.x-k8s.io",
-	}
-
+func newClientCert(caCert *x509.Certificate, key *rsa.PrivateKey, caKey *rsa.PrivateKey, cfg certs.Config) (*x509.Certificate, error) {
 	now := time.Now().UTC()
 
 	tmpl := x509.Certificate{
@@ -498,6 +1680,80 @@ func newClientCert(caCert *x509.Certificate, key *rsa.PrivateKey, caKey *rsa.Pri
 	return c, errors.WithStack(err)
 }
 
+// kubeletVersionSkew returns the nodes whose kubelet version is newer than the kube-apiserver, or more than
+// maxSkewMinor minor versions older, mapped to their kubelet version.
+func (c *cluster) kubeletVersionSkew(ctx context.Context, maxSkewMinor int) (map[string]string, error) {
+	apiServerVersion, err := c.apiServerVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	nodes := &corev1.NodeList{}
+	if err := c.client.List(ctx, nodes); err != nil {
+		return nil, errors.Wrap(err, "failed to list nodes")
+	}
+
+	skewed := map[string]string{}
+	parseErrs := []error{}
+	for _, node := range nodes.Items {
+		if node.Status.NodeInfo.KubeletVersion == "" {
+			continue
+		}
+		kubeletVersion, err := version.ParseGeneric(node.Status.NodeInfo.KubeletVersion)
+		if err != nil {
+			parseErrs = append(parseErrs, errors.Wrapf(err, "node %q has an unparseable kubelet version %q", node.Name, node.Status.NodeInfo.KubeletVersion))
+			continue
+		}
+		minorSkew := int(apiServerVersion.Minor()) - int(kubeletVersion.Minor())
+		if kubeletVersion.Major() != apiServerVersion.Major() || minorSkew < 0 || minorSkew > maxSkewMinor {
+			skewed[node.Name] = node.Status.NodeInfo.KubeletVersion
+		}
+	}
+	return skewed, kerrors.NewAggregate(parseErrs)
+}
+
+// apiServerVersion returns the oldest version the kube-apiserver static pods of the control plane nodes run,
+// as found in their image tags. Nodes without a kube-apiserver pod, or with an untagged image, are skipped.
+func (c *cluster) apiServerVersion(ctx context.Context) (*version.Version, error) {
+	controlPlaneNodes, err := c.getControlPlaneNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var oldest *version.Version
+	for _, node := range controlPlaneNodes.Items {
+		pod, err := c.getStaticPod(ctx, "kube-apiserver", node.Name)
+		if err != nil {
+			continue
+		}
+		_, tag := splitImage(staticPodImage(pod, "kube-apiserver"))
+		v, err := version.ParseGeneric(tag)
+		if err != nil {
+			continue
+		}
+		if oldest == nil || v.LessThan(oldest) {
+			oldest = v
+		}
+	}
+	if oldest == nil {
+		return nil, errors.New("failed to find the kube-apiserver version on any control plane node")
+	}
+	return oldest, nil
+}
+
+// staticPodImage returns the image of the static pod's container named after the component, falling back to the first
+// container.
+func staticPodImage(pod *corev1.Pod, component string) string {
+	for _, container := range pod.Spec.Containers {
+		if container.Name == component {
+			return container.Image
+		}
+	}
+	if len(pod.Spec.Containers) > 0 {
+		return pod.Spec.Containers[0].Image
+	}
+	return ""
+}
+
 func staticPodName(component, nodeName string) string {
 	return fmt.Sprintf("%s-%s", component, nodeName)
 }
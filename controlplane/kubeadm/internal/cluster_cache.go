@@ -0,0 +1,386 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internal
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/cluster-api/controlplane/kubeadm/internal/etcd"
+)
+
+// clusterCacheEntry is a target cluster built by getCluster along with the resource versions of the
+// secrets it was built from. The entry is stale as soon as either secret changes.
+type clusterCacheEntry struct {
+	cluster                   *cluster
+	kubeconfigResourceVersion string
+	etcdCAResourceVersion     string
+}
+
+// cachedCluster returns the cached target cluster for clusterKey if it was built from the given secret versions.
+func (m *Management) cachedCluster(clusterKey types.NamespacedName, kubeconfigResourceVersion, etcdCAResourceVersion string) (*cluster, bool) {
+	m.clusterCacheLock.Lock()
+	defer m.clusterCacheLock.Unlock()
+
+	entry, ok := m.clusterCache[clusterKey]
+	if !ok {
+		return nil, false
+	}
+	if entry.kubeconfigResourceVersion != kubeconfigResourceVersion || entry.etcdCAResourceVersion != etcdCAResourceVersion {
+		delete(m.clusterCache, clusterKey)
+		entry.cluster.closeEtcdClients()
+		return nil, false
+	}
+	return entry.cluster, true
+}
+
+// cacheCluster stores a target cluster built from the given secret versions.
+func (m *Management) cacheCluster(clusterKey types.NamespacedName, c *cluster, kubeconfigResourceVersion, etcdCAResourceVersion string) {
+	m.clusterCacheLock.Lock()
+	defer m.clusterCacheLock.Unlock()
+
+	if m.clusterCache == nil {
+		m.clusterCache = map[types.NamespacedName]*clusterCacheEntry{}
+	}
+	if entry, ok := m.clusterCache[clusterKey]; ok && entry.cluster != c {
+		entry.cluster.closeEtcdClients()
+	}
+	m.clusterCache[clusterKey] = &clusterCacheEntry{
+		cluster:                   c,
+		kubeconfigResourceVersion: kubeconfigResourceVersion,
+		etcdCAResourceVersion:     etcdCAResourceVersion,
+	}
+}
+
+// InvalidateCache drops the cached client, REST config and etcd CA material for the given cluster,
+// forcing the next operation on the cluster to rebuild them. This is useful right after the kubeconfig
+// or etcd CA has been rotated out-of-band.
+// It is safe to call while health checks are running; in-flight checks finish with the material they started with.
+func (m *Management) InvalidateCache(clusterKey types.NamespacedName) {
+	m.clusterCacheLock.Lock()
+	defer m.clusterCacheLock.Unlock()
+
+	if entry, ok := m.clusterCache[clusterKey]; ok {
+		entry.cluster.closeEtcdClients()
+	}
+	delete(m.clusterCache, clusterKey)
+}
+
+// InvalidateAllCaches drops the cached client, REST config and etcd CA material for every cluster.
+// It is safe to call while health checks are running; in-flight checks finish with the material they started with.
+func (m *Management) InvalidateAllCaches() {
+	m.clusterCacheLock.Lock()
+	defer m.clusterCacheLock.Unlock()
+
+	for _, entry := range m.clusterCache {
+		entry.cluster.closeEtcdClients()
+	}
+	m.clusterCache = nil
+}
+
+// InvalidateHealthCheckCache drops the cached control plane health of the given cluster,
+// forcing the next control plane health check to query the workload cluster.
+func (m *Management) InvalidateHealthCheckCache(clusterKey types.NamespacedName) {
+	m.clusterCacheLock.Lock()
+	entry, ok := m.clusterCache[clusterKey]
+	m.clusterCacheLock.Unlock()
+	if !ok {
+		return
+	}
+	entry.cluster.invalidateControlPlaneHealth()
+}
+
+// etcdClientPool keeps the etcd client of every node of a target cluster open after use, so that repeated operations
+// on the cluster's etcd members, such as health checks, reuse the connection and the proxy dialer behind it instead
+// of dialing again. Clients unused for longer than idleTimeout, and clients that lost their connection, are closed
+// the next time the pool is used.
+type etcdClientPool struct {
+	idleTimeout time.Duration
+
+	lock    sync.Mutex
+	clients map[string]*pooledEtcdClient
+	closed  bool
+}
+
+// pooledEtcdClient is an etcd client of the pool along with the number of callers using it.
+// A client that was removed from the pool is closed as soon as no caller uses it.
+type pooledEtcdClient struct {
+	client   *etcd.Client
+	users    int
+	lastUsed time.Time
+	removed  bool
+}
+
+func newEtcdClientPool(idleTimeout time.Duration) *etcdClientPool {
+	return &etcdClientPool{
+		idleTimeout: idleTimeout,
+		clients:     map[string]*pooledEtcdClient{},
+	}
+}
+
+// get returns the pooled etcd client of the given node, creating it with newClient if there is none.
+// Callers close the returned client once they are done with it, which returns it to the pool.
+func (p *etcdClientPool) get(nodeName string, newClient func() (*etcd.Client, error)) (*etcd.Client, error) {
+	p.lock.Lock()
+	unused := p.removeUnusedLocked()
+	pooled, ok := p.clients[nodeName]
+	if ok && !pooled.client.IsConnected() {
+		p.removeLocked(nodeName)
+		if pooled.users == 0 {
+			unused = append(unused, pooled.client)
+		}
+		ok = false
+	}
+	var client *etcd.Client
+	if ok {
+		client = p.acquireLocked(pooled)
+	}
+	closed := p.closed
+	p.lock.Unlock()
+	closeEtcdClients(unused)
+	if client != nil {
+		return client, nil
+	}
+
+	// Dial without holding the lock, so that the nodes of the cluster can be dialed concurrently.
+	client, err := newClient()
+	if err != nil || closed {
+		return client, err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.closed {
+		// The caller closes the client, as if there was no pool.
+		return client, nil
+	}
+	if pooled, ok := p.clients[nodeName]; ok {
+		// The node was dialed concurrently; use the client that made it into the pool.
+		_ = client.Close()
+		return p.acquireLocked(pooled), nil
+	}
+	pooled = &pooledEtcdClient{client: client}
+	p.clients[nodeName] = pooled
+	return p.acquireLocked(pooled), nil
+}
+
+// acquireLocked returns a client sharing the connection of the pooled client, whose Close returns it to the pool.
+func (p *etcdClientPool) acquireLocked(pooled *pooledEtcdClient) *etcd.Client {
+	pooled.users++
+	var once sync.Once
+	return pooled.client.Share(func() error {
+		var err error
+		once.Do(func() {
+			p.lock.Lock()
+			pooled.users--
+			pooled.lastUsed = time.Now()
+			closeClient := pooled.removed && pooled.users == 0
+			p.lock.Unlock()
+			if closeClient {
+				err = pooled.client.Close()
+			}
+		})
+		return err
+	})
+}
+
+// removeUnusedLocked removes the clients that have not been used for idleTimeout from the pool, and returns them
+// so that they can be closed.
+func (p *etcdClientPool) removeUnusedLocked() []*etcd.Client {
+	var unused []*etcd.Client
+	for nodeName, pooled := range p.clients {
+		if pooled.users == 0 && time.Since(pooled.lastUsed) >= p.idleTimeout {
+			p.removeLocked(nodeName)
+			unused = append(unused, pooled.client)
+		}
+	}
+	return unused
+}
+
+func (p *etcdClientPool) removeLocked(nodeName string) {
+	p.clients[nodeName].removed = true
+	delete(p.clients, nodeName)
+}
+
+// close closes the clients of the pool that are not in use; the others are closed when their callers are done.
+// Clients created after the pool is closed are not pooled.
+func (p *etcdClientPool) close() {
+	p.lock.Lock()
+	var unused []*etcd.Client
+	for nodeName, pooled := range p.clients {
+		p.removeLocked(nodeName)
+		if pooled.users == 0 {
+			unused = append(unused, pooled.client)
+		}
+	}
+	p.closed = true
+	p.lock.Unlock()
+	closeEtcdClients(unused)
+}
+
+func closeEtcdClients(clients []*etcd.Client) {
+	for _, client := range clients {
+		_ = client.Close()
+	}
+}
+
+// closeEtcdClients closes the pooled etcd clients of the cluster once it has been dropped from the cache.
+func (c *cluster) closeEtcdClients() {
+	if c.etcdClients != nil {
+		c.etcdClients.close()
+	}
+}
+
+// healthSnapshot is the result of a control plane health check along with when it was observed.
+type healthSnapshot struct {
+	response   healthCheckResult
+	observedAt time.Time
+}
+
+// cachedControlPlaneHealth returns a copy of the cached control plane health if it is younger than healthCacheTTL.
+func (c *cluster) cachedControlPlaneHealth() (healthCheckResult, bool) {
+	if c.healthCacheTTL <= 0 {
+		return nil, false
+	}
+	c.healthCacheLock.Lock()
+	defer c.healthCacheLock.Unlock()
+
+	if c.healthCache == nil || time.Since(c.healthCache.observedAt) >= c.healthCacheTTL {
+		return nil, false
+	}
+	response := make(healthCheckResult, len(c.healthCache.response))
+	for name, err := range c.healthCache.response {
+		response[name] = err
+	}
+	return response, true
+}
+
+func (c *cluster) cacheControlPlaneHealth(response healthCheckResult) {
+	if c.healthCacheTTL <= 0 {
+		return
+	}
+	snapshot := &healthSnapshot{response: make(healthCheckResult, len(response)), observedAt: time.Now()}
+	for name, err := range response {
+		snapshot.response[name] = err
+	}
+
+	c.healthCacheLock.Lock()
+	defer c.healthCacheLock.Unlock()
+	c.healthCache = snapshot
+}
+
+func (c *cluster) invalidateControlPlaneHealth() {
+	c.healthCacheLock.Lock()
+	defer c.healthCacheLock.Unlock()
+	c.healthCache = nil
+}
+
+// inFlightCheckKey identifies a health check run; runs with the same key are interchangeable.
+type inFlightCheckKey struct {
+	check            HealthCheckType
+	cluster          types.NamespacedName
+	controlPlaneName string
+	// excludeMachines are the sorted keys of the machines excluded from the check, joined by commas.
+	excludeMachines string
+}
+
+func newInFlightCheckKey(check HealthCheckType, clusterKey types.NamespacedName, controlPlaneName string, excludeMachines []types.NamespacedName) inFlightCheckKey {
+	excluded := make([]string, 0, len(excludeMachines))
+	for _, machine := range excludeMachines {
+		excluded = append(excluded, machine.String())
+	}
+	sort.Strings(excluded)
+	return inFlightCheckKey{
+		check:            check,
+		cluster:          clusterKey,
+		controlPlaneName: controlPlaneName,
+		excludeMachines:  strings.Join(excluded, ","),
+	}
+}
+
+// inFlightCheck is a running health check. err is set before done is closed.
+type inFlightCheck struct {
+	done chan struct{}
+	err  error
+
+	// waiters is the number of callers waiting for the check. The check is cancelled once none is left.
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// shareHealthCheck runs check, unless a check with the same key is already running, in which case it waits for that
+// check and returns its result instead. The check runs with a context of its own rather than the context of the caller
+// that started it: callers that stop waiting because their context is done get the context's error, and the check is
+// cancelled only once no caller is left waiting for it. A check that panics fails with an error.
+func (m *Management) shareHealthCheck(ctx context.Context, key inFlightCheckKey, check func(context.Context) error) error {
+	m.inFlightChecksLock.Lock()
+	running, ok := m.inFlightChecks[key]
+	if !ok {
+		if m.inFlightChecks == nil {
+			m.inFlightChecks = map[inFlightCheckKey]*inFlightCheck{}
+		}
+		checkCtx, cancel := context.WithCancel(context.Background())
+		running = &inFlightCheck{done: make(chan struct{}), cancel: cancel}
+		m.inFlightChecks[key] = running
+		go m.runHealthCheck(checkCtx, key, running, check)
+	}
+	running.waiters++
+	m.inFlightChecksLock.Unlock()
+
+	select {
+	case <-running.done:
+		return running.err
+	case <-ctx.Done():
+		m.inFlightChecksLock.Lock()
+		running.waiters--
+		if running.waiters == 0 {
+			// Nobody waits for the result anymore: cancel the check and let the next caller start a new one.
+			running.cancel()
+			m.forgetHealthCheck(key, running)
+		}
+		m.inFlightChecksLock.Unlock()
+		return ctx.Err()
+	}
+}
+
+// runHealthCheck runs check for the callers of shareHealthCheck and records its result in running.
+func (m *Management) runHealthCheck(ctx context.Context, key inFlightCheckKey, running *inFlightCheck, check func(context.Context) error) {
+	defer func() {
+		if r := recover(); r != nil {
+			running.err = errors.Errorf("health check panicked: %v", r)
+		}
+		m.inFlightChecksLock.Lock()
+		m.forgetHealthCheck(key, running)
+		m.inFlightChecksLock.Unlock()
+		running.cancel()
+		close(running.done)
+	}()
+	running.err = check(ctx)
+}
+
+// forgetHealthCheck removes running from the in-flight checks unless a newer check took its place.
+// It must be called with inFlightChecksLock held.
+func (m *Management) forgetHealthCheck(key inFlightCheckKey, running *inFlightCheck) {
+	if m.inFlightChecks[key] == running {
+		delete(m.inFlightChecks, key)
+	}
+}
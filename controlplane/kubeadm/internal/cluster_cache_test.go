@@ -0,0 +1,523 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/cluster-api/controlplane/kubeadm/internal/etcd"
+	"sigs.k8s.io/cluster-api/util/secret"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func secretsForTestClusterCache(kubeconfigVersion, etcdCAVersion string) map[string]interface{} {
+	return map[string]interface{}{
+		"my-namespace/my-cluster-kubeconfig": &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{ResourceVersion: kubeconfigVersion},
+		},
+		"my-namespace/my-cluster-etcd": &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{ResourceVersion: etcdCAVersion},
+			Data: map[string][]byte{
+				secret.TLSCrtDataName: []byte("etcd-ca-crt"),
+				secret.TLSKeyDataName: []byte("etcd-ca-key"),
+			},
+		},
+	}
+}
+
+func TestGetClusterUsesCache(t *testing.T) {
+	clusterKey := types.NamespacedName{Namespace: "my-namespace", Name: "my-cluster"}
+	m := &Management{Client: &fakeClient{get: secretsForTestClusterCache("1", "1")}}
+	cached := &cluster{}
+	m.cacheCluster(clusterKey, cached, "1", "1")
+
+	c, err := m.getCluster(context.Background(), clusterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != cached {
+		t.Fatal("expected the cached cluster to be returned")
+	}
+}
+
+func TestCachedClusterIsStaleWhenSecretsChange(t *testing.T) {
+	clusterKey := types.NamespacedName{Namespace: "my-namespace", Name: "my-cluster"}
+	m := &Management{}
+	m.cacheCluster(clusterKey, &cluster{}, "1", "1")
+
+	if _, ok := m.cachedCluster(clusterKey, "1", "2"); ok {
+		t.Fatal("expected a rotated etcd CA to invalidate the cached cluster")
+	}
+	if _, ok := m.cachedCluster(clusterKey, "1", "1"); ok {
+		t.Fatal("expected the stale cached cluster to have been dropped")
+	}
+}
+
+func TestInvalidateCache(t *testing.T) {
+	first := types.NamespacedName{Namespace: "my-namespace", Name: "first-cluster"}
+	second := types.NamespacedName{Namespace: "my-namespace", Name: "second-cluster"}
+	m := &Management{}
+	m.cacheCluster(first, &cluster{}, "1", "1")
+	m.cacheCluster(second, &cluster{}, "1", "1")
+
+	m.InvalidateCache(first)
+	if _, ok := m.cachedCluster(first, "1", "1"); ok {
+		t.Fatal("expected the invalidated cluster to be dropped from the cache")
+	}
+	if _, ok := m.cachedCluster(second, "1", "1"); !ok {
+		t.Fatal("expected other clusters to remain cached")
+	}
+
+	m.InvalidateAllCaches()
+	if _, ok := m.cachedCluster(second, "1", "1"); ok {
+		t.Fatal("expected all clusters to be dropped from the cache")
+	}
+
+	// Invalidating an empty cache is a no-op.
+	m.InvalidateCache(first)
+	m.InvalidateAllCaches()
+}
+
+func TestInvalidateCacheConcurrently(t *testing.T) {
+	clusterKey := types.NamespacedName{Namespace: "my-namespace", Name: "my-cluster"}
+	m := &Management{}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			m.cacheCluster(clusterKey, &cluster{}, "1", "1")
+			m.cachedCluster(clusterKey, "1", "1")
+		}()
+		go func() {
+			defer wg.Done()
+			m.InvalidateCache(clusterKey)
+		}()
+		go func() {
+			defer wg.Done()
+			m.InvalidateAllCaches()
+		}()
+	}
+	wg.Wait()
+}
+
+// listCountingClient counts the List calls made through it.
+type listCountingClient struct {
+	client.Client
+	lists int
+}
+
+func (c *listCountingClient) List(ctx context.Context, list runtime.Object, opts ...client.ListOption) error {
+	c.lists++
+	return c.Client.List(ctx, list, opts...)
+}
+
+func TestControlPlaneHealthCheckUsesCache(t *testing.T) {
+	clusterKey := types.NamespacedName{Namespace: "my-namespace", Name: "my-cluster"}
+	countingClient := &listCountingClient{Client: &fakeClient{
+		list: nodeListForTestControlPlaneIsHealthy(),
+		get: map[string]interface{}{
+			"kube-system/kube-apiserver-first-control-plane":          &corev1.Pod{Status: corev1.PodStatus{Conditions: []corev1.PodCondition{podReady(corev1.ConditionTrue)}}},
+			"kube-system/kube-controller-manager-first-control-plane": &corev1.Pod{Status: corev1.PodStatus{Conditions: []corev1.PodCondition{podReady(corev1.ConditionTrue)}}},
+			"kube-system/kube-scheduler-first-control-plane":          &corev1.Pod{Status: corev1.PodStatus{Conditions: []corev1.PodCondition{podReady(corev1.ConditionTrue)}}},
+			"kube-system/etcd-first-control-plane":                    &corev1.Pod{Status: corev1.PodStatus{Conditions: []corev1.PodCondition{podReady(corev1.ConditionTrue)}}},
+		},
+	}}
+	m := managementClusterForTest(clusterKey, &cluster{client: countingClient, healthCacheTTL: time.Minute})
+
+	check := func() {
+		t.Helper()
+		workloadCluster, err := m.getCluster(context.Background(), clusterKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		health, err := workloadCluster.controlPlaneIsHealthy(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := health["first-control-plane"]; err != nil {
+			t.Fatalf("expected the node to be healthy but got %v", err)
+		}
+	}
+
+	check()
+	check()
+	if countingClient.lists != 1 {
+		t.Fatalf("expected the second check to be served from the cache but nodes were listed %d times", countingClient.lists)
+	}
+
+	m.InvalidateHealthCheckCache(clusterKey)
+	check()
+	if countingClient.lists != 2 {
+		t.Fatalf("expected the check after invalidation to list nodes again but nodes were listed %d times", countingClient.lists)
+	}
+}
+
+func TestControlPlaneHealthCheckCacheExpires(t *testing.T) {
+	countingClient := &listCountingClient{Client: &fakeClient{list: &corev1.NodeList{}}}
+	workloadCluster := &cluster{client: countingClient, healthCacheTTL: time.Millisecond}
+
+	for i := 0; i < 2; i++ {
+		if _, err := workloadCluster.controlPlaneIsHealthy(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+	if countingClient.lists != 2 {
+		t.Fatalf("expected nodes to be listed again once the cached result expired but they were listed %d times", countingClient.lists)
+	}
+}
+
+func TestShareHealthCheck(t *testing.T) {
+	clusterKey := types.NamespacedName{Namespace: "my-namespace", Name: "my-cluster"}
+	key := newInFlightCheckKey(EtcdHealthCheck, clusterKey, "my-control-plane", nil)
+	checkErr := errors.New("etcd member is unreachable")
+
+	m := &Management{}
+	var (
+		lock sync.Mutex
+		runs int
+	)
+	release := make(chan struct{})
+	check := func(context.Context) error {
+		lock.Lock()
+		runs++
+		lock.Unlock()
+		<-release
+		return checkErr
+	}
+
+	const callers = 10
+	var started, finished sync.WaitGroup
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		started.Add(1)
+		finished.Add(1)
+		go func() {
+			defer finished.Done()
+			started.Done()
+			errs <- m.shareHealthCheck(context.Background(), key, check)
+		}()
+	}
+	started.Wait()
+	// Give every caller the time to join the running check.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	finished.Wait()
+	close(errs)
+
+	if runs != 1 {
+		t.Fatalf("expected the check to run once but it ran %d times", runs)
+	}
+	for err := range errs {
+		if err != checkErr {
+			t.Fatalf("expected every caller to get the check's error but got %v", err)
+		}
+	}
+	if len(m.inFlightChecks) != 0 {
+		t.Fatalf("expected no check to be in flight but got %v", m.inFlightChecks)
+	}
+
+	// The next check runs again rather than reusing the finished one.
+	if err := m.shareHealthCheck(context.Background(), key, func(context.Context) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestShareHealthCheckKeys(t *testing.T) {
+	clusterKey := types.NamespacedName{Namespace: "my-namespace", Name: "my-cluster"}
+	first := types.NamespacedName{Namespace: "my-namespace", Name: "first"}
+	second := types.NamespacedName{Namespace: "my-namespace", Name: "second"}
+
+	if newInFlightCheckKey(EtcdHealthCheck, clusterKey, "my-control-plane", []types.NamespacedName{first, second}) !=
+		newInFlightCheckKey(EtcdHealthCheck, clusterKey, "my-control-plane", []types.NamespacedName{second, first}) {
+		t.Fatal("expected the order of the excluded machines not to matter")
+	}
+
+	m := &Management{}
+	release := make(chan struct{})
+	running := make(chan struct{})
+	go func() {
+		_ = m.shareHealthCheck(context.Background(), newInFlightCheckKey(EtcdHealthCheck, clusterKey, "my-control-plane", nil), func(context.Context) error {
+			close(running)
+			<-release
+			return nil
+		})
+	}()
+	<-running
+	defer close(release)
+
+	ran := false
+	err := m.shareHealthCheck(context.Background(), newInFlightCheckKey(ControlPlaneHealthCheck, clusterKey, "my-control-plane", nil), func(context.Context) error {
+		ran = true
+		return nil
+	})
+	if err != nil || !ran {
+		t.Fatalf("expected a different check of the same cluster to run on its own but got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = m.shareHealthCheck(ctx, newInFlightCheckKey(EtcdHealthCheck, clusterKey, "my-control-plane", nil), func(context.Context) error {
+		t.Fatal("expected the running check to be joined")
+		return nil
+	})
+	if err != context.Canceled {
+		t.Fatalf("expected a caller that stops waiting to get its context's error but got %v", err)
+	}
+}
+
+func TestShareHealthCheckPanics(t *testing.T) {
+	key := newInFlightCheckKey(EtcdHealthCheck, types.NamespacedName{Namespace: "my-namespace", Name: "my-cluster"}, "my-control-plane", nil)
+
+	m := &Management{}
+	err := m.shareHealthCheck(context.Background(), key, func(context.Context) error {
+		panic("etcd client is nil")
+	})
+	if err == nil {
+		t.Fatal("expected a check that panics to fail")
+	}
+	if len(m.inFlightChecks) != 0 {
+		t.Fatalf("expected no check to be in flight but got %v", m.inFlightChecks)
+	}
+}
+
+func TestShareHealthCheckOutlivesCallers(t *testing.T) {
+	key := newInFlightCheckKey(EtcdHealthCheck, types.NamespacedName{Namespace: "my-namespace", Name: "my-cluster"}, "my-control-plane", nil)
+
+	m := &Management{}
+	running := make(chan struct{})
+	release := make(chan struct{})
+	cancelled := make(chan struct{})
+	check := func(ctx context.Context) error {
+		close(running)
+		select {
+		case <-release:
+			return nil
+		case <-ctx.Done():
+			close(cancelled)
+			return ctx.Err()
+		}
+	}
+
+	firstCtx, cancelFirst := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		firstErr <- m.shareHealthCheck(firstCtx, key, check)
+	}()
+	<-running
+
+	secondErr := make(chan error, 1)
+	go func() {
+		secondErr <- m.shareHealthCheck(context.Background(), key, check)
+	}()
+	// Give the second caller the time to join the running check.
+	time.Sleep(50 * time.Millisecond)
+
+	cancelFirst()
+	if err := <-firstErr; err != context.Canceled {
+		t.Fatalf("expected the first caller to get its context's error but got %v", err)
+	}
+	close(release)
+	if err := <-secondErr; err != nil {
+		t.Fatalf("expected the check to go on for the second caller but got %v", err)
+	}
+
+	// A check nobody waits for anymore is cancelled.
+	running = make(chan struct{})
+	release = make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	err := make(chan error, 1)
+	go func() {
+		err <- m.shareHealthCheck(ctx, key, check)
+	}()
+	<-running
+	cancel()
+	<-err
+	select {
+	case <-cancelled:
+	case <-time.After(10 * time.Second):
+		t.Fatal("expected the check to be cancelled once no caller waits for it")
+	}
+}
+
+func TestEtcdTLSClientBundleIsReused(t *testing.T) {
+	workloadCluster := &cluster{etcdCA: etcdCABundleForTest(t)}
+	first, err := workloadCluster.generateEtcdTLSClientBundle()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := workloadCluster.generateEtcdTLSClientBundle()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Fatal("expected the etcd client certificate to be minted once per cluster")
+	}
+
+	// A rotated etcd CA makes getCluster build a new cluster, with its own client certificate.
+	rebuilt := &cluster{etcdCA: etcdCABundleForTest(t)}
+	third, err := rebuilt.generateEtcdTLSClientBundle()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third == first {
+		t.Fatal("expected a new etcd client certificate for a new etcd CA")
+	}
+}
+
+// poolForTest returns an etcd client pool and a function creating clients for the fake etcd member,
+// along with the number of clients created.
+func poolForTest(idleTimeout time.Duration) (*etcdClientPool, *fakeEtcd, func() (*etcd.Client, error), *int) {
+	member := &fakeEtcd{}
+	created := 0
+	newClient := func() (*etcd.Client, error) {
+		created++
+		return etcd.NewClientWithEtcd(member)
+	}
+	return newEtcdClientPool(idleTimeout), member, newClient, &created
+}
+
+func TestEtcdClientPoolReusesClients(t *testing.T) {
+	pool, member, newClient, created := poolForTest(time.Hour)
+
+	for i := 0; i < 3; i++ {
+		client, err := pool.get("node-1", newClient)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.Status(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if err := client.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if *created != 1 {
+		t.Fatalf("expected the etcd client to be created once but it was created %d times", *created)
+	}
+	if member.closed != 0 {
+		t.Fatal("expected the pooled etcd client to be kept open")
+	}
+
+	if _, err := pool.get("node-2", newClient); err != nil {
+		t.Fatal(err)
+	}
+	if *created != 2 {
+		t.Fatal("expected a separate etcd client for every node")
+	}
+}
+
+func TestEtcdClientPoolClosesIdleClients(t *testing.T) {
+	pool, member, newClient, created := poolForTest(0)
+
+	client, err := pool.get("node-1", newClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := pool.get("node-1", newClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *created != 1 || member.closed != 0 {
+		t.Fatal("expected an etcd client in use to be reused rather than closed")
+	}
+
+	// Closing a client twice releases it once.
+	_ = client.Close()
+	_ = client.Close()
+	if _, err := pool.get("node-2", newClient); err != nil {
+		t.Fatal(err)
+	}
+	if member.closed != 0 {
+		t.Fatal("expected an etcd client to be kept open while it is in use")
+	}
+
+	_ = other.Close()
+	if _, err := pool.get("node-2", newClient); err != nil {
+		t.Fatal(err)
+	}
+	if member.closed != 1 {
+		t.Fatal("expected the idle etcd client to be closed")
+	}
+	if _, err := pool.get("node-1", newClient); err != nil {
+		t.Fatal(err)
+	}
+	if *created != 3 {
+		t.Fatalf("expected the closed etcd client to be created again, but %d clients were created", *created)
+	}
+}
+
+func TestEtcdClientPoolClose(t *testing.T) {
+	pool, member, newClient, created := poolForTest(time.Hour)
+
+	idle, err := pool.get("node-1", newClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = idle.Close()
+	inUse, err := pool.get("node-2", newClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool.close()
+	if member.closed != 1 {
+		t.Fatalf("expected the idle etcd client to be closed but %d clients were closed", member.closed)
+	}
+	_ = inUse.Close()
+	if member.closed != 2 {
+		t.Fatal("expected the etcd client in use to be closed once released")
+	}
+
+	// Clients are no longer pooled once the pool is closed.
+	unpooled, err := pool.get("node-1", newClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = unpooled.Close()
+	if *created != 3 || member.closed != 3 {
+		t.Fatal("expected etcd clients created after the pool is closed to be closed after use")
+	}
+}
+
+func TestInvalidateCacheClosesEtcdClients(t *testing.T) {
+	clusterKey := types.NamespacedName{Namespace: "my-namespace", Name: "my-cluster"}
+	pool, member, newClient, _ := poolForTest(time.Hour)
+	m := &Management{}
+	m.cacheCluster(clusterKey, &cluster{etcdClients: pool}, "1", "1")
+
+	client, err := pool.get("node-1", newClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = client.Close()
+
+	m.InvalidateCache(clusterKey)
+	if member.closed != 1 {
+		t.Fatal("expected the etcd clients of an invalidated cluster to be closed")
+	}
+}
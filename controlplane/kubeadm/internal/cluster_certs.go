@@ -0,0 +1,247 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internal
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	controlplanev1 "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/controlplane/kubeadm/internal/proxy"
+	"sigs.k8s.io/cluster-api/util/certs"
+	"sigs.k8s.io/cluster-api/util/secret"
+)
+
+// defaultBatchConcurrency is the number of concurrent requests batch operations send
+// to the management cluster's API server when BatchConcurrency is not set.
+const defaultBatchConcurrency = 10
+
+func (m *Management) batchConcurrency() int {
+	if m.BatchConcurrency <= 0 {
+		return defaultBatchConcurrency
+	}
+	return m.BatchConcurrency
+}
+
+// EtcdCABundle is the etcd CA of a cluster, both PEM encoded and parsed.
+type EtcdCABundle struct {
+	// CertData and KeyData are the PEM encoded CA certificate and private key.
+	CertData, KeyData []byte
+
+	Cert *x509.Certificate
+	Key  *rsa.PrivateKey
+}
+
+// NotAfter returns the time the CA certificate expires.
+func (b *EtcdCABundle) NotAfter() time.Time {
+	return b.Cert.NotAfter
+}
+
+// etcdCAKeyAvailable returns whether the EtcdCA secret holds the private key of the CA along with its certificate.
+// The key is missing when the CA is managed outside of the management cluster.
+func etcdCAKeyAvailable(etcdCASecret *corev1.Secret) bool {
+	return len(etcdCASecret.Data[secret.TLSKeyDataName]) > 0
+}
+
+// etcdCABundleFromSecret extracts and parses the EtcdCA Cert and Key from the EtcdCA secret of a given cluster.
+func etcdCABundleFromSecret(etcdCASecret *corev1.Secret, clusterKey types.NamespacedName) (*EtcdCABundle, error) {
+	crtData, keyData, err := etcdCertsFromSecret(etcdCASecret, clusterKey)
+	if err != nil {
+		return nil, err
+	}
+	crt, err := certs.DecodeCertPEM(crtData)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decode etcd CA certificate for cluster %s/%s", clusterKey.Namespace, clusterKey.Name)
+	}
+	if crt == nil {
+		return nil, errors.Errorf("etcd CA certificate for cluster %s/%s is not PEM encoded", clusterKey.Namespace, clusterKey.Name)
+	}
+	key, err := certs.DecodePrivateKeyPEM(keyData)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decode etcd CA key for cluster %s/%s", clusterKey.Namespace, clusterKey.Name)
+	}
+	if key == nil {
+		return nil, errors.Errorf("etcd CA key for cluster %s/%s is not PEM encoded", clusterKey.Namespace, clusterKey.Name)
+	}
+	return &EtcdCABundle{CertData: crtData, KeyData: keyData, Cert: crt, Key: key}, nil
+}
+
+// GetEtcdCAExpiry returns the time the etcd CA certificate of a given cluster expires.
+func (m *Management) GetEtcdCAExpiry(ctx context.Context, clusterKey types.NamespacedName) (time.Time, error) {
+	etcdCA, err := m.GetEtcdCABundle(ctx, clusterKey)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return etcdCA.NotAfter(), nil
+}
+
+// GetEtcdCAExpiries returns the time the etcd CA certificate of each of the given clusters expires.
+// Secrets are read concurrently, with at most BatchConcurrency requests in flight.
+// Clusters whose expiry cannot be read are reported in the returned error map and do not fail the rest of the batch.
+func (m *Management) GetEtcdCAExpiries(ctx context.Context, clusters []types.NamespacedName) (map[types.NamespacedName]time.Time, map[types.NamespacedName]error) {
+	expiries := make(map[types.NamespacedName]time.Time, len(clusters))
+	errs := make(map[types.NamespacedName]error)
+
+	var (
+		lock sync.Mutex
+		wg   sync.WaitGroup
+	)
+	sem := make(chan struct{}, m.batchConcurrency())
+	for _, clusterKey := range clusters {
+		wg.Add(1)
+		go func(clusterKey types.NamespacedName) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			expiry, err := m.GetEtcdCAExpiry(ctx, clusterKey)
+
+			lock.Lock()
+			defer lock.Unlock()
+			if err != nil {
+				errs[clusterKey] = err
+				return
+			}
+			expiries[clusterKey] = expiry
+		}(clusterKey)
+	}
+	wg.Wait()
+
+	return expiries, errs
+}
+
+// GetMachineCertificatesExpiries returns when the kubeadm-generated certificates of the given control plane machines
+// expire, keyed by machine name. The expiry is read from the CertificatesExpiryAnnotation of the machine if it has
+// one, otherwise from the annotation of its node, falling back to the expiry of the serving certificate of the API
+// server running on the node. Machines without a node are left out. Every expiry found is observed by the MetricsSink.
+func (m *Management) GetMachineCertificatesExpiries(ctx context.Context, clusterKey types.NamespacedName, machines []*clusterv1.Machine) (map[string]time.Time, error) {
+	expiries := make(map[string]time.Time, len(machines))
+	var (
+		workloadCluster *cluster
+		errs            []error
+	)
+	for _, machine := range machines {
+		expiry, ok, err := certificatesExpiryFromAnnotations(machine.Annotations)
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "machine %s", machine.Name))
+		}
+		if !ok {
+			if machine.Status.NodeRef == nil {
+				continue
+			}
+			if workloadCluster == nil {
+				if workloadCluster, err = m.getCluster(ctx, clusterKey); err != nil {
+					return nil, err
+				}
+			}
+			if expiry, err = workloadCluster.certificatesExpiry(ctx, machine.Status.NodeRef.Name); err != nil {
+				errs = append(errs, errors.Wrapf(err, "machine %s", machine.Name))
+				continue
+			}
+		}
+		expiries[machine.Name] = expiry
+		m.metricsSink().ObserveCertificatesExpiry(CertificatesExpiryObservation{Cluster: clusterKey, Machine: machine.Name, Expiry: expiry})
+	}
+	return expiries, kerrors.NewAggregate(errs)
+}
+
+// certificatesExpiryFromAnnotations parses the CertificatesExpiryAnnotation, reporting whether it is set and valid.
+func certificatesExpiryFromAnnotations(annotations map[string]string) (time.Time, bool, error) {
+	value, ok := annotations[controlplanev1.CertificatesExpiryAnnotation]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	expiry, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false, errors.Wrapf(err, "invalid %s annotation", controlplanev1.CertificatesExpiryAnnotation)
+	}
+	return expiry, true, nil
+}
+
+// certificatesExpiry returns when the kubeadm-generated certificates of the given node expire.
+func (c *cluster) certificatesExpiry(ctx context.Context, nodeName string) (time.Time, error) {
+	node := &corev1.Node{}
+	if err := c.client.Get(ctx, types.NamespacedName{Name: nodeName}, node); err != nil {
+		return time.Time{}, errors.Wrapf(err, "failed to get node %s", nodeName)
+	}
+	expiry, ok, err := certificatesExpiryFromAnnotations(node.Annotations)
+	if ok || err != nil {
+		return expiry, errors.Wrapf(err, "node %s", nodeName)
+	}
+
+	getCertificate := c.getAPIServerCertificate
+	if c.apiServerCertificateGetter != nil {
+		getCertificate = c.apiServerCertificateGetter
+	}
+	certificate, err := getCertificate(ctx, nodeName)
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "failed to read the API server certificate of node %s", nodeName)
+	}
+	return certificate.NotAfter, nil
+}
+
+// getAPIServerCertificate reads the serving certificate of the API server running on the given node through a port
+// forward to its static pod. The certificate is verified against the CA of the target cluster.
+func (c *cluster) getAPIServerCertificate(ctx context.Context, nodeName string) (*x509.Certificate, error) {
+	if c.restConfig == nil {
+		return nil, errors.New("missing REST config")
+	}
+	p := proxy.Proxy{
+		Kind:         "pods",
+		Namespace:    "kube-system",
+		ResourceName: staticPodName("kube-apiserver", nodeName),
+		KubeConfig:   c.restConfig,
+		Port:         6443, // The default API server bind port of kubeadm.
+	}
+	dialer, err := proxy.NewDialer(p)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", "")
+	if err != nil {
+		return nil, err
+	}
+
+	caPool := x509.NewCertPool()
+	caPool.AppendCertsFromPEM(c.restConfig.CAData)
+	// kubeadm always includes the "kubernetes" DNS name in the API server certificate.
+	tlsConn := tls.Client(conn, &tls.Config{RootCAs: caPool, ServerName: "kubernetes"})
+	defer tlsConn.Close()
+
+	// The proxied connection does not support deadlines, so close it to abort the handshake once ctx is done.
+	handshake := make(chan error, 1)
+	go func() { handshake <- tlsConn.Handshake() }()
+	select {
+	case err := <-handshake:
+		if err != nil {
+			return nil, err
+		}
+	case <-ctx.Done():
+		tlsConn.Close()
+		return nil, ctx.Err()
+	}
+	return tlsConn.ConnectionState().PeerCertificates[0], nil
+}
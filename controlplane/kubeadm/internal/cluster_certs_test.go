@@ -0,0 +1,306 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internal
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	controlplanev1 "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util/certs"
+	"sigs.k8s.io/cluster-api/util/secret"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// concurrencyTrackingClient records the highest number of concurrent Get calls.
+type concurrencyTrackingClient struct {
+	client.Client
+
+	lock        sync.Mutex
+	inFlight    int
+	maxInFlight int
+}
+
+func (c *concurrencyTrackingClient) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	c.lock.Lock()
+	c.inFlight++
+	if c.inFlight > c.maxInFlight {
+		c.maxInFlight = c.inFlight
+	}
+	c.lock.Unlock()
+
+	time.Sleep(10 * time.Millisecond)
+	err := c.Client.Get(ctx, key, obj)
+
+	c.lock.Lock()
+	c.inFlight--
+	c.lock.Unlock()
+	return err
+}
+
+func TestGetEtcdCAExpiries(t *testing.T) {
+	etcdCACert, etcdCAKey := etcdCAForTest(t)
+	crt, err := certs.DecodeCertPEM(etcdCACert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secrets := map[string]interface{}{}
+	clusters := []types.NamespacedName{}
+	for i := 0; i < 20; i++ {
+		clusterKey := types.NamespacedName{Namespace: "my-namespace", Name: fmt.Sprintf("cluster-%d", i)}
+		clusters = append(clusters, clusterKey)
+		secrets[fmt.Sprintf("%s/%s-etcd", clusterKey.Namespace, clusterKey.Name)] = &corev1.Secret{
+			Data: map[string][]byte{
+				secret.TLSCrtDataName: etcdCACert,
+				secret.TLSKeyDataName: etcdCAKey,
+			},
+		}
+	}
+	missing := types.NamespacedName{Namespace: "my-namespace", Name: "missing"}
+	notPEM := types.NamespacedName{Namespace: "my-namespace", Name: "not-pem"}
+	secrets["my-namespace/not-pem-etcd"] = &corev1.Secret{
+		Data: map[string][]byte{
+			secret.TLSCrtDataName: []byte("not a certificate"),
+			secret.TLSKeyDataName: etcdCAKey,
+		},
+	}
+	clusters = append(clusters, missing, notPEM)
+
+	c := &concurrencyTrackingClient{Client: &fakeClient{get: secrets}}
+	m := &Management{Client: c, BatchConcurrency: 3}
+
+	expiries, errs := m.GetEtcdCAExpiries(context.Background(), clusters)
+	if len(expiries) != 20 {
+		t.Fatalf("expected 20 expiries but got %d", len(expiries))
+	}
+	for clusterKey, expiry := range expiries {
+		if !expiry.Equal(crt.NotAfter) {
+			t.Fatalf("expected cluster %v to expire at %v but got %v", clusterKey, crt.NotAfter, expiry)
+		}
+	}
+	if len(errs) != 2 || errs[missing] == nil || errs[notPEM] == nil {
+		t.Fatalf("expected errors for the missing and malformed secrets but got %v", errs)
+	}
+	if c.maxInFlight > 3 {
+		t.Fatalf("expected at most 3 concurrent requests but got %d", c.maxInFlight)
+	}
+}
+
+func TestGetEtcdCABundle(t *testing.T) {
+	etcdCACert, etcdCAKey := etcdCAForTest(t)
+	crt, err := certs.DecodeCertPEM(etcdCACert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clusterKey := types.NamespacedName{Namespace: "my-namespace", Name: "my-cluster"}
+
+	table := []struct {
+		name        string
+		data        map[string][]byte
+		expectedErr bool
+	}{
+		{name: "valid CA", data: map[string][]byte{secret.TLSCrtDataName: etcdCACert, secret.TLSKeyDataName: etcdCAKey}},
+		{name: "missing key", data: map[string][]byte{secret.TLSCrtDataName: etcdCACert}, expectedErr: true},
+		{name: "malformed certificate", data: map[string][]byte{secret.TLSCrtDataName: []byte("not a certificate"), secret.TLSKeyDataName: etcdCAKey}, expectedErr: true},
+		{name: "malformed key", data: map[string][]byte{secret.TLSCrtDataName: etcdCACert, secret.TLSKeyDataName: []byte("not a key")}, expectedErr: true},
+	}
+	for _, test := range table {
+		t.Run(test.name, func(t *testing.T) {
+			m := &Management{Client: &fakeClient{get: map[string]interface{}{
+				"my-namespace/my-cluster-etcd": &corev1.Secret{Data: test.data},
+			}}}
+			etcdCA, err := m.GetEtcdCABundle(context.Background(), clusterKey)
+			if test.expectedErr != (err != nil) {
+				t.Fatalf("expected error to be %t but got %v", test.expectedErr, err)
+			}
+			if test.expectedErr {
+				return
+			}
+			if !etcdCA.NotAfter().Equal(crt.NotAfter) {
+				t.Fatalf("expected the CA to expire at %v but got %v", crt.NotAfter, etcdCA.NotAfter())
+			}
+			if etcdCA.Key == nil || !reflect.DeepEqual(etcdCA.CertData, etcdCACert) || !reflect.DeepEqual(etcdCA.KeyData, etcdCAKey) {
+				t.Fatal("expected the bundle to hold both the parsed and the encoded CA")
+			}
+
+			crtData, keyData, err := m.GetEtcdCerts(context.Background(), clusterKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(crtData, etcdCACert) || !reflect.DeepEqual(keyData, etcdCAKey) {
+				t.Fatal("expected GetEtcdCerts to return the encoded CA")
+			}
+		})
+	}
+}
+
+func TestEtcdClientCertConfig(t *testing.T) {
+	etcdCA := etcdCABundleForTest(t)
+	tenantCluster := types.NamespacedName{Namespace: "tenant-a", Name: "my-cluster"}
+	m := &Management{
+		EtcdClientCertConfig: func(clusterKey types.NamespacedName) certs.Config {
+			if clusterKey.Namespace != "tenant-a" {
+				return certs.Config{}
+			}
+			return certs.Config{CommonName: "tenant-a.example.com", Organization: []string{"tenant-a"}}
+		},
+	}
+
+	table := []struct {
+		name                 string
+		clusterKey           types.NamespacedName
+		managementCluster    *Management
+		expectedCommonName   string
+		expectedOrganization []string
+	}{
+		{
+			name:                 "overridden subject",
+			clusterKey:           tenantCluster,
+			managementCluster:    m,
+			expectedCommonName:   "tenant-a.example.com",
+			expectedOrganization: []string{"tenant-a"},
+		},
+		{
+			name:               "hook without an override",
+			clusterKey:         types.NamespacedName{Namespace: "tenant-b", Name: "my-cluster"},
+			managementCluster:  m,
+			expectedCommonName: "cluster-api.x-k8s.io",
+		},
+		{
+			name:               "no hook",
+			clusterKey:         tenantCluster,
+			managementCluster:  &Management{},
+			expectedCommonName: "cluster-api.x-k8s.io",
+		},
+	}
+	for _, test := range table {
+		t.Run(test.name, func(t *testing.T) {
+			workloadCluster := &cluster{
+				etcdCA:               etcdCA,
+				etcdClientCertConfig: test.managementCluster.etcdClientCertConfig(test.clusterKey),
+			}
+			tlsConfig, err := workloadCluster.generateEtcdTLSClientBundle()
+			if err != nil {
+				t.Fatal(err)
+			}
+			clientCert, err := x509.ParseCertificate(tlsConfig.Certificates[0].Certificate[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if clientCert.Subject.CommonName != test.expectedCommonName {
+				t.Fatalf("expected CommonName %q but got %q", test.expectedCommonName, clientCert.Subject.CommonName)
+			}
+			if !reflect.DeepEqual(clientCert.Subject.Organization, test.expectedOrganization) {
+				t.Fatalf("expected Organization %v but got %v", test.expectedOrganization, clientCert.Subject.Organization)
+			}
+		})
+	}
+}
+
+func TestEtcdCAKeyAvailable(t *testing.T) {
+	table := []struct {
+		name     string
+		data     map[string][]byte
+		expected bool
+	}{
+		{name: "certificate and key", data: map[string][]byte{secret.TLSCrtDataName: []byte("crt"), secret.TLSKeyDataName: []byte("key")}, expected: true},
+		{name: "certificate only", data: map[string][]byte{secret.TLSCrtDataName: []byte("crt")}},
+		{name: "empty key", data: map[string][]byte{secret.TLSCrtDataName: []byte("crt"), secret.TLSKeyDataName: {}}},
+	}
+	for _, test := range table {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := etcdCAKeyAvailable(&corev1.Secret{Data: test.data}); actual != test.expected {
+				t.Fatalf("expected %t but got %t", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestGetMachineCertificatesExpiries(t *testing.T) {
+	clusterKey := types.NamespacedName{Namespace: "my-namespace", Name: "my-cluster"}
+	machineExpiry := time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)
+	nodeExpiry := time.Date(2021, time.February, 1, 0, 0, 0, 0, time.UTC)
+	apiServerExpiry := time.Date(2021, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	machine := func(name, nodeName, expiry string) *clusterv1.Machine {
+		m := &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		if nodeName != "" {
+			m.Status.NodeRef = &corev1.ObjectReference{Name: nodeName}
+		}
+		if expiry != "" {
+			m.Annotations = map[string]string{controlplanev1.CertificatesExpiryAnnotation: expiry}
+		}
+		return m
+	}
+	machines := []*clusterv1.Machine{
+		machine("annotated", "node-0", machineExpiry.Format(time.RFC3339)),
+		machine("annotated-node", "node-1", ""),
+		machine("unannotated-node", "node-2", ""),
+		machine("no-node", "", ""),
+		machine("invalid", "", "tomorrow"),
+	}
+
+	workloadCluster := &cluster{
+		client: &fakeClient{get: map[string]interface{}{
+			"/node-1": &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{controlplanev1.CertificatesExpiryAnnotation: nodeExpiry.Format(time.RFC3339)},
+			}},
+			"/node-2": &corev1.Node{},
+		}},
+		apiServerCertificateGetter: func(_ context.Context, nodeName string) (*x509.Certificate, error) {
+			if nodeName != "node-2" {
+				return nil, fmt.Errorf("unexpected API server certificate lookup for node %s", nodeName)
+			}
+			return &x509.Certificate{NotAfter: apiServerExpiry}, nil
+		},
+	}
+	sink := &fakeMetricsSink{}
+	m := &Management{Client: &fakeClient{get: secretsForTestClusterCache("1", "1")}, MetricsSink: sink}
+	m.cacheCluster(clusterKey, workloadCluster, "1", "1")
+
+	expiries, err := m.GetMachineCertificatesExpiries(context.Background(), clusterKey, machines)
+	if err == nil {
+		t.Fatal("expected an error for the invalid annotation")
+	}
+	expected := map[string]time.Time{
+		"annotated":        machineExpiry,
+		"annotated-node":   nodeExpiry,
+		"unannotated-node": apiServerExpiry,
+	}
+	if !reflect.DeepEqual(expiries, expected) {
+		t.Fatalf("expected expiries %v but got %v", expected, expiries)
+	}
+	if len(sink.expiryObservations) != len(expected) {
+		t.Fatalf("expected %d observations but got %d", len(expected), len(sink.expiryObservations))
+	}
+	for _, observation := range sink.expiryObservations {
+		if observation.Cluster != clusterKey || !observation.Expiry.Equal(expected[observation.Machine]) {
+			t.Fatalf("unexpected observation %+v", observation)
+		}
+	}
+}
@@ -0,0 +1,970 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internal
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/controlplane/kubeadm/internal/etcd"
+	etcdutil "sigs.k8s.io/cluster-api/controlplane/kubeadm/internal/etcd/util"
+)
+
+// EtcdCompactionStatus is the compaction state of a single etcd member.
+type EtcdCompactionStatus struct {
+	// Revision is the current revision of the member's keyspace.
+	Revision int64
+
+	// CompactedRevision is the revision the member's keyspace was last compacted at, or 0 if it never was.
+	CompactedRevision int64
+}
+
+// EtcdAlarm is an alarm raised on an etcd member.
+type EtcdAlarm struct {
+	// MemberID is the ID of the member the alarm is raised on.
+	MemberID uint64
+
+	// NodeName is the name of the node running the member, or empty if the member is not known to the cluster.
+	NodeName string
+
+	// Type is the type of the alarm, e.g. NOSPACE or CORRUPT.
+	Type etcd.AlarmType
+}
+
+// defaultEtcdDataDir is the data directory etcd uses when its static pod does not set --data-dir.
+const defaultEtcdDataDir = "/var/lib/etcd"
+
+// GetEtcdDataDirHostPaths returns the host path backing the etcd data directory of every etcd member, keyed by node name.
+// The path is read from the hostPath volume mounted at the data directory in the etcd static pod.
+// Members whose static pod cannot be read or has no such volume are left out of the result and reported in the returned error.
+func (m *Management) GetEtcdDataDirHostPaths(ctx context.Context, clusterKey types.NamespacedName) (map[string]string, error) {
+	cluster, err := m.getCluster(ctx, clusterKey)
+	if err != nil {
+		return nil, err
+	}
+	return cluster.etcdDataDirHostPaths(ctx)
+}
+
+// CanSafelyRemoveEtcdMember reports whether the etcd member running on the given node can be removed
+// without the remaining etcd cluster losing quorum. Removing a node that does not run an etcd member is always safe.
+func (m *Management) CanSafelyRemoveEtcdMember(ctx context.Context, clusterKey types.NamespacedName, nodeName string) (bool, error) {
+	cluster, err := m.getCluster(ctx, clusterKey)
+	if err != nil {
+		return false, err
+	}
+	return cluster.CanSafelyRemoveEtcdMember(ctx, nodeName)
+}
+
+// EtcdMembershipIsStable samples the etcd member list of a target cluster twice, EtcdMembershipSampleInterval apart,
+// and reports whether the set of member IDs stayed the same. A change means a member is being added or removed,
+// so callers should back off rather than act on a membership that is still moving.
+func (m *Management) EtcdMembershipIsStable(ctx context.Context, clusterKey types.NamespacedName) (bool, error) {
+	cluster, err := m.getCluster(ctx, clusterKey)
+	if err != nil {
+		return false, err
+	}
+	return cluster.etcdMembershipIsStable(ctx, m.etcdMembershipSampleInterval())
+}
+
+// EtcdLearnersReadyForPromotion returns the names of the etcd learners whose raft log has caught up closely enough
+// with the leader's to be promoted to voting members. It returns an empty slice if there are no such learners.
+// Learners that cannot be reached are not ready and are reported in the returned error.
+func (m *Management) EtcdLearnersReadyForPromotion(ctx context.Context, clusterKey types.NamespacedName) ([]string, error) {
+	cluster, err := m.getCluster(ctx, clusterKey)
+	if err != nil {
+		return nil, err
+	}
+	return cluster.etcdLearnersReadyForPromotion(ctx)
+}
+
+// AddEtcdMemberAsLearner adds a learner member with the given peer URL to the etcd cluster of a target cluster and
+// returns the ID of the new member. The member is only added if every etcd member is healthy, the membership is
+// stable, no other learner is waiting to be promoted (etcd allows a single learner at a time) and no member already
+// uses the peer URL.
+func (m *Management) AddEtcdMemberAsLearner(ctx context.Context, clusterKey types.NamespacedName, peerURL string) (uint64, error) {
+	cluster, err := m.getCluster(ctx, clusterKey)
+	if err != nil {
+		return 0, err
+	}
+	return cluster.addEtcdMemberAsLearner(ctx, peerURL, m.etcdMembershipSampleInterval())
+}
+
+// PromoteEtcdLearner promotes the etcd learner running on the given node to a voting member.
+// Unless force is set, a learner whose raft log has not caught up with the leader's is not promoted,
+// because promoting it prematurely can stall the etcd cluster.
+func (m *Management) PromoteEtcdLearner(ctx context.Context, clusterKey types.NamespacedName, nodeName string, force bool) error {
+	cluster, err := m.getCluster(ctx, clusterKey)
+	if err != nil {
+		return err
+	}
+	return cluster.promoteEtcdLearner(ctx, nodeName, force)
+}
+
+// ForwardEtcdLeadership moves the etcd leadership of a target cluster away from the node of the given control plane
+// Machine to another reachable voting member, so that removing the member or deleting the Machine does not force
+// an election while the cluster is without a leader. Nothing is done if the member on the Machine's node is not
+// the leader, or cannot be reached, in which case the reachable members elect a leader among themselves.
+func (m *Management) ForwardEtcdLeadership(ctx context.Context, clusterKey types.NamespacedName, machine *clusterv1.Machine) error {
+	if machine.Status.NodeRef == nil {
+		return nil
+	}
+	cluster, err := m.getCluster(ctx, clusterKey)
+	if err != nil {
+		return err
+	}
+	return cluster.forwardEtcdLeadership(ctx, machine.Status.NodeRef.Name)
+}
+
+// RemoveEtcdMemberForMachine removes the etcd member running on the node of the given control plane Machine from
+// the etcd cluster of a target cluster, so that deleting the Machine does not leave a member behind that counts
+// towards quorum. The member is removed through the etcd member of another control plane node.
+// Nothing is done if the Machine has no node or the node does not run an etcd member, e.g. because it was already removed.
+func (m *Management) RemoveEtcdMemberForMachine(ctx context.Context, clusterKey types.NamespacedName, machine *clusterv1.Machine) error {
+	if machine.Status.NodeRef == nil {
+		return nil
+	}
+	cluster, err := m.getCluster(ctx, clusterKey)
+	if err != nil {
+		return err
+	}
+	return cluster.removeEtcdMemberForNode(ctx, machine.Status.NodeRef.Name)
+}
+
+// EtcdHealthReport checks the etcd member of every control plane node of a target cluster and returns the result
+// of each node, keyed by node name. The results gathered for individual nodes are returned even when the check
+// ultimately fails, so callers can report which members were checked successfully alongside the error.
+// No results are returned if the target cluster cannot be reached at all.
+func (m *Management) EtcdHealthReport(ctx context.Context, clusterKey types.NamespacedName) (map[string]error, error) {
+	cluster, err := m.getCluster(ctx, clusterKey)
+	if err != nil {
+		return nil, err
+	}
+	response, _, err := cluster.etcdHealth(ctx)
+	return response, err
+}
+
+// SnapshotEtcd streams a snapshot of the etcd keyspace of a target cluster to w.
+// The snapshot is taken from the first etcd member that reports its status; it fails if no member can be reached.
+// The caller decides where the snapshot is persisted.
+func (m *Management) SnapshotEtcd(ctx context.Context, clusterKey types.NamespacedName, w io.Writer) error {
+	cluster, err := m.getCluster(ctx, clusterKey)
+	if err != nil {
+		return err
+	}
+	return cluster.snapshotEtcd(ctx, w)
+}
+
+// EtcdRevisionConsistency returns the current revision of every etcd member, keyed by node name, along with the largest
+// difference between any two of them. A large skew points at a member that is not keeping up with replication, even if
+// it is otherwise healthy. Members that cannot be reached are left out of the result and the skew, and are reported in
+// the returned error.
+func (m *Management) EtcdRevisionConsistency(ctx context.Context, clusterKey types.NamespacedName) (map[string]int64, int64, error) {
+	cluster, err := m.getCluster(ctx, clusterKey)
+	if err != nil {
+		return nil, 0, err
+	}
+	return cluster.etcdRevisionConsistency(ctx)
+}
+
+// ListEtcdAlarms returns every alarm raised in the etcd cluster of a target cluster.
+// Alarms are cluster wide, so they are listed once through a single healthy member. It returns an empty slice
+// if no alarm is raised.
+func (m *Management) ListEtcdAlarms(ctx context.Context, clusterKey types.NamespacedName) ([]EtcdAlarm, error) {
+	cluster, err := m.getCluster(ctx, clusterKey)
+	if err != nil {
+		return nil, err
+	}
+	return cluster.listEtcdAlarms(ctx)
+}
+
+// RecoverEtcdNoSpaceAlarms defragments the etcd members of a target cluster that raised a NOSPACE alarm, and then
+// disarms their alarms so that etcd accepts writes again. It returns the alarms that were disarmed. Other alarms,
+// e.g. CORRUPT, cannot be recovered from automatically and are left raised. A member raises the alarm again if
+// defragmenting did not free enough space, e.g. if its keyspace is not compacted or it needs a larger quota.
+func (m *Management) RecoverEtcdNoSpaceAlarms(ctx context.Context, clusterKey types.NamespacedName) ([]EtcdAlarm, error) {
+	cluster, err := m.getCluster(ctx, clusterKey)
+	if err != nil {
+		return nil, err
+	}
+	return cluster.recoverEtcdNoSpaceAlarms(ctx)
+}
+
+// DefragmentEtcd defragments the etcd members of a target cluster one at a time, and returns the names of the nodes
+// whose member was defragmented. A member does not serve requests while it is defragmented, so every member is checked
+// to be healthy before the next one is defragmented, and the leader is defragmented last to avoid disrupting it more
+// than once. Defragmentation stops at the first member that cannot be defragmented, or as soon as a member is not
+// healthy, and the error is returned along with the nodes that were defragmented until then.
+func (m *Management) DefragmentEtcd(ctx context.Context, clusterKey types.NamespacedName) ([]string, error) {
+	cluster, err := m.getCluster(ctx, clusterKey)
+	if err != nil {
+		return nil, err
+	}
+	return cluster.defragmentEtcd(ctx)
+}
+
+// CompactEtcd compacts the etcd keyspace of a target cluster up to its current revision.
+// Compaction goes through consensus, so it is issued once through a single healthy member rather than per member.
+func (m *Management) CompactEtcd(ctx context.Context, clusterKey types.NamespacedName) error {
+	cluster, err := m.getCluster(ctx, clusterKey)
+	if err != nil {
+		return err
+	}
+	return cluster.compactEtcd(ctx)
+}
+
+// GetEtcdCompactionStatus returns the current and compacted revisions of every etcd member, keyed by node name.
+// Members that cannot be reached are left out of the result and reported in the returned error.
+func (m *Management) GetEtcdCompactionStatus(ctx context.Context, clusterKey types.NamespacedName) (map[string]EtcdCompactionStatus, error) {
+	cluster, err := m.getCluster(ctx, clusterKey)
+	if err != nil {
+		return nil, err
+	}
+	return cluster.etcdCompactionStatus(ctx)
+}
+
+func (c *cluster) etcdDataDirHostPaths(ctx context.Context) (map[string]string, error) {
+	controlPlaneNodes, err := c.getControlPlaneNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make(map[string]string)
+	errs := []error{}
+	for _, node := range controlPlaneNodes.Items {
+		etcdPod, err := c.getStaticPod(ctx, "etcd", node.Name)
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "node %q", node.Name))
+			continue
+		}
+		hostPath, err := etcdDataDirHostPath(etcdPod)
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "node %q", node.Name))
+			continue
+		}
+		response[node.Name] = hostPath
+	}
+	return response, kerrors.NewAggregate(errs)
+}
+
+// etcdDataDirHostPath returns the host path of the hostPath volume mounted at the data directory of the etcd container.
+func etcdDataDirHostPath(pod *corev1.Pod) (string, error) {
+	for _, container := range pod.Spec.Containers {
+		if container.Name != "etcd" {
+			continue
+		}
+		dataDir := etcdDataDir(container)
+		for _, mount := range container.VolumeMounts {
+			if path.Clean(mount.MountPath) != dataDir {
+				continue
+			}
+			for _, volume := range pod.Spec.Volumes {
+				if volume.Name != mount.Name {
+					continue
+				}
+				if volume.HostPath == nil {
+					return "", errors.Errorf("etcd data directory %s is not backed by a hostPath volume", dataDir)
+				}
+				return volume.HostPath.Path, nil
+			}
+			return "", errors.Errorf("volume %q mounted at etcd data directory %s does not exist", mount.Name, dataDir)
+		}
+		return "", errors.Errorf("no volume is mounted at etcd data directory %s", dataDir)
+	}
+	return "", errors.Errorf("static pod %s has no etcd container", pod.Name)
+}
+
+// etcdDataDir returns the data directory an etcd container is configured with.
+func etcdDataDir(container corev1.Container) string {
+	for _, arg := range append(container.Command, container.Args...) {
+		if strings.HasPrefix(arg, "--data-dir=") {
+			return path.Clean(strings.TrimPrefix(arg, "--data-dir="))
+		}
+	}
+	return defaultEtcdDataDir
+}
+
+// etcdMembersHealth returns the etcd members along with the IDs of the members that are healthy.
+// A member is healthy if it answers a status request and has no alarms raised.
+func (c *cluster) etcdMembersHealth(ctx context.Context) ([]*etcd.Member, map[uint64]bool, error) {
+	controlPlaneNodes, err := c.getControlPlaneNodes(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	tlsConfig, err := c.generateEtcdTLSClientBundle()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	etcdClient, _, err := c.getHealthyEtcdClient(ctx, controlPlaneNodes.Items, tlsConfig)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to list etcd members")
+	}
+	members, err := etcdClient.Members(ctx)
+	etcdClient.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	healthy := make(map[uint64]bool, len(members))
+	for _, member := range members {
+		if len(member.Alarms) > 0 || member.Name == "" {
+			continue
+		}
+		healthy[member.ID] = c.etcdMemberIsReachable(ctx, member.Name, tlsConfig)
+	}
+	return members, healthy, nil
+}
+
+func (c *cluster) etcdMembershipIsStable(ctx context.Context, interval time.Duration) (bool, error) {
+	before, err := c.etcdMemberIDs(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	select {
+	case <-ctx.Done():
+		return false, ctx.Err()
+	case <-time.After(interval):
+	}
+
+	after, err := c.etcdMemberIDs(ctx)
+	if err != nil {
+		return false, err
+	}
+	return before.Equal(after), nil
+}
+
+// etcdMemberIDs returns the IDs of the etcd members as reported by the first healthy member.
+func (c *cluster) etcdMemberIDs(ctx context.Context) (etcdutil.UInt64Set, error) {
+	controlPlaneNodes, err := c.getControlPlaneNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := c.generateEtcdTLSClientBundle()
+	if err != nil {
+		return nil, err
+	}
+
+	etcdClient, _, err := c.getHealthyEtcdClient(ctx, controlPlaneNodes.Items, tlsConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list etcd members")
+	}
+	defer etcdClient.Close()
+
+	members, err := etcdClient.Members(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return etcdutil.MemberIDSet(members), nil
+}
+
+// learnerPromotionReadyPercent is the fraction of the leader's raft index a learner must have reached to be promoted.
+// It matches the threshold etcd itself enforces when a learner promotion is requested.
+const learnerPromotionReadyPercent = 0.9
+
+func (c *cluster) etcdLearnersReadyForPromotion(ctx context.Context) ([]string, error) {
+	controlPlaneNodes, err := c.getControlPlaneNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := c.generateEtcdTLSClientBundle()
+	if err != nil {
+		return nil, err
+	}
+
+	etcdClient, status, err := c.getHealthyEtcdClient(ctx, controlPlaneNodes.Items, tlsConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list etcd members")
+	}
+	members, err := etcdClient.Members(ctx)
+	etcdClient.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	ready := []string{}
+	learners := []*etcd.Member{}
+	for _, member := range members {
+		if member.IsLearner {
+			learners = append(learners, member)
+		}
+	}
+	if len(learners) == 0 {
+		return ready, nil
+	}
+
+	leaderStatus, err := c.etcdLeaderStatus(ctx, members, status, tlsConfig)
+	if err != nil {
+		return ready, err
+	}
+
+	errs := []error{}
+	for _, learner := range learners {
+		learnerStatus, err := c.etcdMemberStatus(ctx, learner.Name, tlsConfig)
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "learner %q", learner.Name))
+			continue
+		}
+		if learnerIsCaughtUp(learnerStatus, leaderStatus) {
+			ready = append(ready, learner.Name)
+		}
+	}
+	return ready, kerrors.NewAggregate(errs)
+}
+
+func (c *cluster) addEtcdMemberAsLearner(ctx context.Context, peerURL string, sampleInterval time.Duration) (uint64, error) {
+	stable, err := c.etcdMembershipIsStable(ctx, sampleInterval)
+	if err != nil {
+		return 0, err
+	}
+	if !stable {
+		return 0, errors.New("etcd membership is changing")
+	}
+
+	members, healthy, err := c.etcdMembersHealth(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for _, member := range members {
+		if member.IsLearner {
+			return 0, errors.Errorf("etcd member %x is a learner that has not been promoted yet", member.ID)
+		}
+		if !healthy[member.ID] {
+			return 0, errors.Errorf("etcd member %x is not healthy", member.ID)
+		}
+		for _, memberPeerURL := range member.PeerURLs {
+			if memberPeerURL == peerURL {
+				return 0, errors.Errorf("etcd member %x already uses peer URL %s", member.ID, peerURL)
+			}
+		}
+	}
+
+	controlPlaneNodes, err := c.getControlPlaneNodes(ctx)
+	if err != nil {
+		return 0, err
+	}
+	tlsConfig, err := c.generateEtcdTLSClientBundle()
+	if err != nil {
+		return 0, err
+	}
+	etcdClient, _, err := c.getHealthyEtcdClient(ctx, controlPlaneNodes.Items, tlsConfig)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to add etcd learner")
+	}
+	defer etcdClient.Close()
+
+	learner, err := etcdClient.AddMemberAsLearner(ctx, peerURL)
+	if err != nil {
+		return 0, err
+	}
+	return learner.ID, nil
+}
+
+func (c *cluster) promoteEtcdLearner(ctx context.Context, nodeName string, force bool) error {
+	controlPlaneNodes, err := c.getControlPlaneNodes(ctx)
+	if err != nil {
+		return err
+	}
+	tlsConfig, err := c.generateEtcdTLSClientBundle()
+	if err != nil {
+		return err
+	}
+
+	etcdClient, status, err := c.getHealthyEtcdClient(ctx, controlPlaneNodes.Items, tlsConfig)
+	if err != nil {
+		return errors.Wrap(err, "failed to list etcd members")
+	}
+	defer etcdClient.Close()
+
+	members, err := etcdClient.Members(ctx)
+	if err != nil {
+		return err
+	}
+	learner := etcdutil.MemberForName(members, nodeName)
+	if learner == nil {
+		return errors.Errorf("node %q does not run an etcd member", nodeName)
+	}
+	if !learner.IsLearner {
+		return errors.Errorf("etcd member %q is not a learner", nodeName)
+	}
+
+	if !force {
+		leaderStatus, err := c.etcdLeaderStatus(ctx, members, status, tlsConfig)
+		if err != nil {
+			return err
+		}
+		learnerStatus, err := c.etcdMemberStatus(ctx, nodeName, tlsConfig)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read the status of etcd learner %q", nodeName)
+		}
+		if !learnerIsCaughtUp(learnerStatus, leaderStatus) {
+			return errors.Errorf("etcd learner %q is at raft index %d and has not caught up with the leader at raft index %d",
+				nodeName, learnerStatus.RaftIndex, leaderStatus.RaftIndex)
+		}
+	}
+
+	return etcdClient.PromoteMember(ctx, learner.ID)
+}
+
+// forwardEtcdLeadership moves the etcd leadership to the first reachable voting member on another node if the
+// member running on the given node is the leader. The leader has to be asked to step down, so the request goes
+// through the member on the given node.
+func (c *cluster) forwardEtcdLeadership(ctx context.Context, nodeName string) error {
+	tlsConfig, err := c.generateEtcdTLSClientBundle()
+	if err != nil {
+		return err
+	}
+	// A member that cannot be reached cannot lead the reachable members, so there is no leadership to forward.
+	etcdClient, err := c.etcdClientForNode(nodeName, tlsConfig)
+	if err != nil {
+		return nil
+	}
+	defer etcdClient.Close()
+
+	status, err := etcdClient.Status(ctx)
+	if err != nil || status.Leader != status.MemberID {
+		return nil
+	}
+
+	members, err := etcdClient.Members(ctx)
+	if err != nil {
+		return err
+	}
+	for _, member := range members {
+		if member.ID == status.MemberID || member.IsLearner {
+			continue
+		}
+		if !c.etcdMemberIsReachable(ctx, member.Name, tlsConfig) {
+			continue
+		}
+		return etcdClient.MoveLeader(ctx, member.ID)
+	}
+	return errors.Errorf("cannot move the etcd leadership away from node %q: there is no other reachable voting member", nodeName)
+}
+
+// removeEtcdMemberForNode removes the etcd member running on the given node using a client for the etcd member
+// of any other control plane node.
+func (c *cluster) removeEtcdMemberForNode(ctx context.Context, nodeName string) error {
+	controlPlaneNodes, err := c.getControlPlaneNodes(ctx)
+	if err != nil {
+		return err
+	}
+	otherNodes := []corev1.Node{}
+	for _, node := range controlPlaneNodes.Items {
+		if node.Name != nodeName {
+			otherNodes = append(otherNodes, node)
+		}
+	}
+	if len(otherNodes) == 0 {
+		return errors.Errorf("cannot remove the etcd member of node %q: there are no other control plane nodes", nodeName)
+	}
+	tlsConfig, err := c.generateEtcdTLSClientBundle()
+	if err != nil {
+		return err
+	}
+
+	etcdClient, _, err := c.getHealthyEtcdClient(ctx, otherNodes, tlsConfig)
+	if err != nil {
+		return errors.Wrap(err, "failed to list etcd members")
+	}
+	defer etcdClient.Close()
+
+	members, err := etcdClient.Members(ctx)
+	if err != nil {
+		return err
+	}
+	member := etcdutil.MemberForName(members, nodeName)
+	if member == nil {
+		return nil
+	}
+	if err := etcdClient.RemoveMember(ctx, member.ID); err != nil {
+		return errors.Wrapf(err, "failed to remove the etcd member of node %q", nodeName)
+	}
+	return nil
+}
+
+// etcdLeaderStatus returns the status of the etcd leader, as seen by the member that reported the given status.
+func (c *cluster) etcdLeaderStatus(ctx context.Context, members []*etcd.Member, status *etcd.MemberStatus, tlsConfig *tls.Config) (*etcd.MemberStatus, error) {
+	for _, member := range members {
+		if member.ID != status.Leader {
+			continue
+		}
+		leaderStatus, err := c.etcdMemberStatus(ctx, member.Name, tlsConfig)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read the status of etcd leader %q", member.Name)
+		}
+		return leaderStatus, nil
+	}
+	return nil, errors.Errorf("etcd leader %x is not a member of the etcd cluster", status.Leader)
+}
+
+// learnerIsCaughtUp reports whether a learner's raft log is close enough to the leader's for the learner to be promoted.
+func learnerIsCaughtUp(learner, leader *etcd.MemberStatus) bool {
+	return float64(learner.RaftIndex) >= float64(leader.RaftIndex)*learnerPromotionReadyPercent
+}
+
+// etcdMemberStatus returns the status reported by the etcd member running on the given node.
+func (c *cluster) etcdMemberStatus(ctx context.Context, nodeName string, tlsConfig *tls.Config) (*etcd.MemberStatus, error) {
+	etcdClient, err := c.etcdClientForNode(nodeName, tlsConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create etcd client")
+	}
+	defer etcdClient.Close()
+
+	return etcdClient.Status(ctx)
+}
+
+// etcdMemberIsReachable reports whether the etcd member running on the given node answers a status request.
+func (c *cluster) etcdMemberIsReachable(ctx context.Context, nodeName string, tlsConfig *tls.Config) bool {
+	etcdClient, err := c.etcdClientForNode(nodeName, tlsConfig)
+	if err != nil {
+		return false
+	}
+	defer etcdClient.Close()
+
+	_, err = etcdClient.Status(ctx)
+	return err == nil
+}
+
+// canSafelyRemoveEtcdMember reports whether the healthy voting members left after removing the member
+// running on the given node still make up a quorum of the remaining voting members.
+func canSafelyRemoveEtcdMember(members []*etcd.Member, healthy map[uint64]bool, nodeName string) bool {
+	target := etcdutil.MemberForName(members, nodeName)
+	if target == nil {
+		return true
+	}
+	if target.IsLearner {
+		return true
+	}
+
+	remaining, remainingHealthy := 0, 0
+	for _, member := range members {
+		if member.ID == target.ID || member.IsLearner {
+			continue
+		}
+		remaining++
+		if healthy[member.ID] {
+			remainingHealthy++
+		}
+	}
+	quorum := remaining/2 + 1
+	return remainingHealthy >= quorum
+}
+
+func (c *cluster) snapshotEtcd(ctx context.Context, w io.Writer) error {
+	controlPlaneNodes, err := c.getControlPlaneNodes(ctx)
+	if err != nil {
+		return err
+	}
+	tlsConfig, err := c.generateEtcdTLSClientBundle()
+	if err != nil {
+		return err
+	}
+
+	etcdClient, _, err := c.getHealthyEtcdClient(ctx, controlPlaneNodes.Items, tlsConfig)
+	if err != nil {
+		return errors.Wrap(err, "failed to find an etcd member to snapshot")
+	}
+	defer etcdClient.Close()
+
+	snapshot, err := etcdClient.Snapshot(ctx)
+	if err != nil {
+		return err
+	}
+	defer snapshot.Close()
+
+	if _, err := io.Copy(w, snapshot); err != nil {
+		return errors.Wrap(err, "failed to stream etcd snapshot")
+	}
+	return nil
+}
+
+func (c *cluster) listEtcdAlarms(ctx context.Context) ([]EtcdAlarm, error) {
+	controlPlaneNodes, err := c.getControlPlaneNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := c.generateEtcdTLSClientBundle()
+	if err != nil {
+		return nil, err
+	}
+
+	etcdClient, _, err := c.getHealthyEtcdClient(ctx, controlPlaneNodes.Items, tlsConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list etcd alarms")
+	}
+	defer etcdClient.Close()
+
+	members, err := etcdClient.Members(ctx)
+	if err != nil {
+		return nil, err
+	}
+	memberAlarms, err := etcdClient.Alarms(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	names := etcdutil.MemberNamesByID(members)
+	alarms := make([]EtcdAlarm, 0, len(memberAlarms))
+	for _, alarm := range memberAlarms {
+		alarms = append(alarms, EtcdAlarm{MemberID: alarm.MemberID, NodeName: names[alarm.MemberID], Type: alarm.Type})
+	}
+	return alarms, nil
+}
+
+func (c *cluster) recoverEtcdNoSpaceAlarms(ctx context.Context) ([]EtcdAlarm, error) {
+	alarms, err := c.listEtcdAlarms(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := c.generateEtcdTLSClientBundle()
+	if err != nil {
+		return nil, err
+	}
+
+	disarmed := []EtcdAlarm{}
+	errs := []error{}
+	for _, alarm := range alarms {
+		if alarm.Type != etcd.AlarmNoSpace {
+			continue
+		}
+		if alarm.NodeName == "" {
+			errs = append(errs, errors.Errorf("etcd member %x raising a %s alarm is not a member of the etcd cluster", alarm.MemberID, alarm.Type))
+			continue
+		}
+		if err := c.recoverEtcdAlarm(ctx, alarm, tlsConfig); err != nil {
+			errs = append(errs, errors.Wrapf(err, "node %q", alarm.NodeName))
+			continue
+		}
+		disarmed = append(disarmed, alarm)
+	}
+	return disarmed, kerrors.NewAggregate(errs)
+}
+
+// recoverEtcdAlarm defragments the etcd member that raised the given alarm, to release the space freed by compactions,
+// and disarms the alarm.
+func (c *cluster) recoverEtcdAlarm(ctx context.Context, alarm EtcdAlarm, tlsConfig *tls.Config) error {
+	etcdClient, err := c.etcdClientForNode(alarm.NodeName, tlsConfig)
+	if err != nil {
+		return errors.Wrap(err, "failed to create etcd client")
+	}
+	defer etcdClient.Close()
+
+	if err := etcdClient.Defragment(ctx); err != nil {
+		return err
+	}
+	return etcdClient.DisarmAlarm(ctx, etcd.MemberAlarm{MemberID: alarm.MemberID, Type: alarm.Type})
+}
+
+func (c *cluster) defragmentEtcd(ctx context.Context) ([]string, error) {
+	controlPlaneNodes, err := c.getControlPlaneNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := c.generateEtcdTLSClientBundle()
+	if err != nil {
+		return nil, err
+	}
+
+	etcdClient, status, err := c.getHealthyEtcdClient(ctx, controlPlaneNodes.Items, tlsConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list etcd members")
+	}
+	members, err := etcdClient.Members(ctx)
+	etcdClient.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	nodeNames := make([]string, 0, len(members))
+	leaderName := ""
+	for _, member := range members {
+		if member.Name == "" {
+			return nil, errors.Errorf("etcd member %x has not started", member.ID)
+		}
+		if member.ID == status.Leader {
+			leaderName = member.Name
+			continue
+		}
+		nodeNames = append(nodeNames, member.Name)
+	}
+	sort.Strings(nodeNames)
+	if leaderName != "" {
+		nodeNames = append(nodeNames, leaderName)
+	}
+
+	defragmented := []string{}
+	for _, nodeName := range nodeNames {
+		if err := c.etcdMembersAreHealthy(ctx); err != nil {
+			return defragmented, errors.Wrapf(err, "cannot defragment etcd member on node %q", nodeName)
+		}
+		if err := c.defragmentEtcdMember(ctx, nodeName, tlsConfig); err != nil {
+			return defragmented, errors.Wrapf(err, "node %q", nodeName)
+		}
+		defragmented = append(defragmented, nodeName)
+	}
+	return defragmented, nil
+}
+
+// etcdMembersAreHealthy returns an error if any etcd member is not healthy.
+func (c *cluster) etcdMembersAreHealthy(ctx context.Context) error {
+	members, healthy, err := c.etcdMembersHealth(ctx)
+	if err != nil {
+		return err
+	}
+	for _, member := range members {
+		if !healthy[member.ID] {
+			return errors.Errorf("etcd member %x on node %q is not healthy", member.ID, member.Name)
+		}
+	}
+	return nil
+}
+
+// defragmentEtcdMember defragments the etcd member running on the given node.
+func (c *cluster) defragmentEtcdMember(ctx context.Context, nodeName string, tlsConfig *tls.Config) error {
+	etcdClient, err := c.etcdClientForNode(nodeName, tlsConfig)
+	if err != nil {
+		return errors.Wrap(err, "failed to create etcd client")
+	}
+	defer etcdClient.Close()
+
+	return etcdClient.Defragment(ctx)
+}
+
+func (c *cluster) compactEtcd(ctx context.Context) error {
+	controlPlaneNodes, err := c.getControlPlaneNodes(ctx)
+	if err != nil {
+		return err
+	}
+	tlsConfig, err := c.generateEtcdTLSClientBundle()
+	if err != nil {
+		return err
+	}
+
+	etcdClient, status, err := c.getHealthyEtcdClient(ctx, controlPlaneNodes.Items, tlsConfig)
+	if err != nil {
+		return errors.Wrap(err, "failed to read the current etcd revision")
+	}
+	defer etcdClient.Close()
+
+	return etcdClient.Compact(ctx, status.Revision)
+}
+
+func (c *cluster) etcdCompactionStatus(ctx context.Context) (map[string]EtcdCompactionStatus, error) {
+	controlPlaneNodes, err := c.getControlPlaneNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := c.generateEtcdTLSClientBundle()
+	if err != nil {
+		return nil, err
+	}
+
+	response := make(map[string]EtcdCompactionStatus)
+	errs := []error{}
+	for _, node := range controlPlaneNodes.Items {
+		status, err := c.nodeEtcdCompactionStatus(ctx, node.Name, tlsConfig)
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "node %q", node.Name))
+			continue
+		}
+		response[node.Name] = status
+	}
+	return response, kerrors.NewAggregate(errs)
+}
+
+func (c *cluster) etcdRevisionConsistency(ctx context.Context) (map[string]int64, int64, error) {
+	controlPlaneNodes, err := c.getControlPlaneNodes(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	tlsConfig, err := c.generateEtcdTLSClientBundle()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	revisions := make(map[string]int64)
+	errs := []error{}
+	var minRevision, maxRevision int64
+	for _, node := range controlPlaneNodes.Items {
+		status, err := c.etcdMemberStatus(ctx, node.Name, tlsConfig)
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "node %q", node.Name))
+			continue
+		}
+		if len(revisions) == 0 || status.Revision < minRevision {
+			minRevision = status.Revision
+		}
+		if len(revisions) == 0 || status.Revision > maxRevision {
+			maxRevision = status.Revision
+		}
+		revisions[node.Name] = status.Revision
+	}
+	return revisions, maxRevision - minRevision, kerrors.NewAggregate(errs)
+}
+
+func (c *cluster) nodeEtcdCompactionStatus(ctx context.Context, nodeName string, tlsConfig *tls.Config) (EtcdCompactionStatus, error) {
+	etcdClient, err := c.etcdClientForNode(nodeName, tlsConfig)
+	if err != nil {
+		return EtcdCompactionStatus{}, errors.Wrap(err, "failed to create etcd client")
+	}
+	defer etcdClient.Close()
+
+	status, err := etcdClient.Status(ctx)
+	if err != nil {
+		return EtcdCompactionStatus{}, err
+	}
+	compacted, err := etcdClient.CompactedRevision(ctx, status.Revision)
+	if err != nil {
+		return EtcdCompactionStatus{}, err
+	}
+	return EtcdCompactionStatus{Revision: status.Revision, CompactedRevision: compacted}, nil
+}
+
+// getHealthyEtcdClient returns a client for the first of the given nodes whose etcd member reports its status,
+// along with that status. The caller is responsible for closing the client.
+func (c *cluster) getHealthyEtcdClient(ctx context.Context, nodes []corev1.Node, tlsConfig *tls.Config) (*etcd.Client, *etcd.MemberStatus, error) {
+	errs := []error{}
+	for _, node := range nodes {
+		etcdClient, err := c.etcdClientForNode(node.Name, tlsConfig)
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "node %q: failed to create etcd client", node.Name))
+			continue
+		}
+		status, err := etcdClient.Status(ctx)
+		if err != nil {
+			etcdClient.Close()
+			errs = append(errs, errors.Wrapf(err, "node %q", node.Name))
+			continue
+		}
+		return etcdClient, status, nil
+	}
+	if len(errs) == 0 {
+		return nil, nil, errors.New("there are no control plane nodes")
+	}
+	return nil, nil, errors.Wrap(kerrors.NewAggregate(errs), "no healthy etcd member found")
+}
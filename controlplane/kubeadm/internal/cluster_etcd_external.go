@@ -0,0 +1,174 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internal
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	kubeadmv1beta1 "sigs.k8s.io/cluster-api/bootstrap/kubeadm/types/v1beta1"
+	controlplanev1 "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/controlplane/kubeadm/internal/etcd"
+	etcdutil "sigs.k8s.io/cluster-api/controlplane/kubeadm/internal/etcd/util"
+	"sigs.k8s.io/cluster-api/util/secret"
+)
+
+// externalEtcdClientGenerator creates an etcd client that talks to the given external etcd endpoint.
+type externalEtcdClientGenerator func(endpoint string, tlsConfig *tls.Config, options ...etcd.EtcdClientOption) (*etcd.Client, error)
+
+// getExternalEtcd returns the external etcd configuration of the named KubeadmControlPlane, or nil if the control
+// plane runs stacked etcd or does not exist.
+func (m *Management) getExternalEtcd(ctx context.Context, clusterKey types.NamespacedName, controlPlaneName string) (*kubeadmv1beta1.ExternalEtcd, error) {
+	kcp := &controlplanev1.KubeadmControlPlane{}
+	kcpKey := types.NamespacedName{Namespace: clusterKey.Namespace, Name: controlPlaneName}
+	if err := m.Client.Get(ctx, kcpKey, kcp); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "failed to get KubeadmControlPlane %s/%s", kcpKey.Namespace, kcpKey.Name)
+	}
+	clusterConfiguration := kcp.Spec.KubeadmConfigSpec.ClusterConfiguration
+	if clusterConfiguration == nil {
+		return nil, nil
+	}
+	return clusterConfiguration.Etcd.External, nil
+}
+
+// externalEtcdIsHealthy checks that every external etcd endpoint is reachable, reports no alarms,
+// and agrees with the other endpoints on the etcd cluster membership.
+// There are no etcd static pods to proxy to, so the endpoints are dialed directly with the etcd CA of the cluster and
+// the user supplied apiserver-etcd-client certificate.
+func (m *Management) externalEtcdIsHealthy(ctx context.Context, clusterKey types.NamespacedName, external *kubeadmv1beta1.ExternalEtcd, observation *HealthCheckObservation) error {
+	if len(external.Endpoints) == 0 {
+		return errors.Errorf("external etcd of cluster %s/%s has no endpoints", clusterKey.Namespace, clusterKey.Name)
+	}
+	tlsConfig, err := m.externalEtcdTLSConfig(ctx, clusterKey)
+	if err != nil {
+		return err
+	}
+
+	newClient := m.externalEtcdClientGenerator
+	if newClient == nil {
+		newClient = newExternalEtcdClient
+	}
+	var knownMemberIDs etcdutil.UInt64Set
+	endpointErrors := []error{}
+	for _, endpoint := range external.Endpoints {
+		members, err := externalEtcdMembers(ctx, newClient, endpoint, tlsConfig, m.etcdClientOptions())
+		if err == nil {
+			err = checkExternalEtcdMembers(members, &knownMemberIDs, observation)
+		}
+		if err != nil {
+			observation.UnhealthyNodes++
+			endpointErrors = append(endpointErrors, fmt.Errorf("endpoint %q: %v", endpoint, err))
+			continue
+		}
+		observation.HealthyNodes++
+	}
+	observation.EtcdMembers = knownMemberIDs.Len()
+	return kerrors.NewAggregate(m.capNodeErrors(endpointErrors))
+}
+
+// checkExternalEtcdMembers checks the members reported by one endpoint, and that they are the members reported by
+// the endpoints checked before, if any.
+func checkExternalEtcdMembers(members []*etcd.Member, knownMemberIDs *etcdutil.UInt64Set, observation *HealthCheckObservation) error {
+	memberIDs := etcdutil.MemberIDSet(members)
+	for _, member := range members {
+		if len(member.Alarms) > 0 {
+			observation.EtcdAlarms += len(member.Alarms)
+			return errors.Errorf("etcd member %q has alarms %v", member.Name, member.Alarms)
+		}
+	}
+	if *knownMemberIDs == nil {
+		*knownMemberIDs = memberIDs
+		return nil
+	}
+	if !knownMemberIDs.Equal(memberIDs) {
+		return errors.Errorf("etcd member list %v differs from %v", memberIDs.UnsortedList(), knownMemberIDs.UnsortedList())
+	}
+	return nil
+}
+
+// externalEtcdMembers lists the etcd members through the given external endpoint.
+func externalEtcdMembers(ctx context.Context, newClient externalEtcdClientGenerator, endpoint string, tlsConfig *tls.Config, options []etcd.EtcdClientOption) ([]*etcd.Member, error) {
+	etcdClient, err := newClient(endpoint, tlsConfig, options...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create etcd client")
+	}
+	defer etcdClient.Close()
+	return etcdClient.Members(ctx)
+}
+
+// externalEtcdTLSConfig builds the TLS configuration for external etcd from the etcd CA certificate of the cluster
+// and the apiserver-etcd-client certificate and key. Unlike with stacked etcd, the etcd CA key is not available to
+// mint a client certificate.
+func (m *Management) externalEtcdTLSConfig(ctx context.Context, clusterKey types.NamespacedName) (*tls.Config, error) {
+	etcdCASecret, err := m.getEtcdCASecret(ctx, clusterKey)
+	if err != nil {
+		return nil, err
+	}
+	return m.apiServerEtcdClientTLSConfig(ctx, clusterKey, etcdCASecret)
+}
+
+// apiServerEtcdClientTLSConfig builds an etcd client TLS configuration from the given etcd CA secret and the
+// apiserver-etcd-client certificate and key of the cluster, for when no client certificate can be minted.
+func (m *Management) apiServerEtcdClientTLSConfig(ctx context.Context, clusterKey types.NamespacedName, etcdCASecret *corev1.Secret) (*tls.Config, error) {
+	caPool := x509.NewCertPool()
+	if !caPool.AppendCertsFromPEM(etcdCASecret.Data[secret.TLSCrtDataName]) {
+		return nil, errors.Errorf("etcd CA certificate for cluster %s/%s is missing or not PEM encoded", clusterKey.Namespace, clusterKey.Name)
+	}
+
+	clientSecret, err := secret.GetFromNamespacedName(ctx, m.Client, clusterKey, secret.APIServerEtcdClient)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get the apiserver-etcd-client secret for cluster %s/%s", clusterKey.Namespace, clusterKey.Name)
+	}
+	clientCert, err := tls.X509KeyPair(clientSecret.Data[secret.TLSCrtDataName], clientSecret.Data[secret.TLSKeyDataName])
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid apiserver-etcd-client certificate for cluster %s/%s", clusterKey.Namespace, clusterKey.Name)
+	}
+
+	return &tls.Config{
+		RootCAs:      caPool,
+		Certificates: []tls.Certificate{clientCert},
+	}, nil
+}
+
+// newExternalEtcdClient dials the given external etcd endpoint directly.
+func newExternalEtcdClient(endpoint string, tlsConfig *tls.Config, options ...etcd.EtcdClientOption) (*etcd.Client, error) {
+	dialer := &net.Dialer{}
+	dial := func(ctx context.Context, addr string) (net.Conn, error) {
+		return dialer.DialContext(ctx, "tcp", addr)
+	}
+	etcdClient, err := etcd.NewEtcdClient(endpoint, dial, tlsConfig, options...)
+	if err != nil {
+		return nil, err
+	}
+	customClient, err := etcd.NewClientWithEtcd(etcdClient)
+	if err != nil {
+		etcdClient.Close()
+		return nil, err
+	}
+	return customClient, nil
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.etcd.io/etcd/etcdserver/etcdserverpb"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	cabpkv1 "sigs.k8s.io/cluster-api/bootstrap/kubeadm/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/bootstrap/kubeadm/types/v1beta1"
+	controlplanev1 "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util/secret"
+)
+
+func TestTargetClusterExternalEtcdIsHealthy(t *testing.T) {
+	clusterKey := types.NamespacedName{Namespace: "my-namespace", Name: "my-cluster"}
+	endpoints := []string{"https://etcd-0:2379", "https://etcd-1:2379", "https://etcd-2:2379"}
+	members := []*etcdserverpb.Member{{ID: 1, Name: "etcd-0"}, {ID: 2, Name: "etcd-1"}, {ID: 3, Name: "etcd-2"}}
+	etcdCACert, etcdCAKey := etcdCAForTest(t)
+
+	objects := func(endpoints []string) map[string]interface{} {
+		return map[string]interface{}{
+			"my-namespace/my-control-plane": &controlplanev1.KubeadmControlPlane{
+				Spec: controlplanev1.KubeadmControlPlaneSpec{
+					KubeadmConfigSpec: cabpkv1.KubeadmConfigSpec{
+						ClusterConfiguration: &v1beta1.ClusterConfiguration{
+							Etcd: v1beta1.Etcd{External: &v1beta1.ExternalEtcd{Endpoints: endpoints}},
+						},
+					},
+				},
+			},
+			// The etcd CA key of external etcd is not known to Cluster API.
+			"my-namespace/my-cluster-etcd": &corev1.Secret{
+				Data: map[string][]byte{secret.TLSCrtDataName: etcdCACert},
+			},
+			"my-namespace/my-cluster-apiserver-etcd-client": &corev1.Secret{
+				Data: map[string][]byte{secret.TLSCrtDataName: etcdCACert, secret.TLSKeyDataName: etcdCAKey},
+			},
+		}
+	}
+
+	table := []struct {
+		name        string
+		endpoints   []string
+		etcd        map[string]*fakeEtcd
+		expectedErr string
+	}{
+		{
+			name:      "healthy external etcd",
+			endpoints: endpoints,
+			etcd: map[string]*fakeEtcd{
+				endpoints[0]: {memberID: 1, members: members},
+				endpoints[1]: {memberID: 2, members: members},
+				endpoints[2]: {memberID: 3, members: members},
+			},
+		},
+		{
+			name:      "unreachable endpoint",
+			endpoints: endpoints,
+			etcd: map[string]*fakeEtcd{
+				endpoints[0]: {memberID: 1, members: members},
+				endpoints[1]: {memberID: 2, members: members, err: errors.New("connection refused")},
+				endpoints[2]: {memberID: 3, members: members},
+			},
+			expectedErr: `endpoint "https://etcd-1:2379"`,
+		},
+		{
+			name:      "endpoint with an alarm",
+			endpoints: endpoints,
+			etcd: map[string]*fakeEtcd{
+				endpoints[0]: {memberID: 1, members: members, alarms: []*etcdserverpb.AlarmMember{{MemberID: 3, Alarm: etcdserverpb.AlarmType_NOSPACE}}},
+				endpoints[1]: {memberID: 2, members: members},
+				endpoints[2]: {memberID: 3, members: members},
+			},
+			expectedErr: "alarms",
+		},
+		{
+			name:      "endpoints disagree on the members",
+			endpoints: endpoints,
+			etcd: map[string]*fakeEtcd{
+				endpoints[0]: {memberID: 1, members: members},
+				endpoints[1]: {memberID: 2, members: members[:2]},
+				endpoints[2]: {memberID: 3, members: members},
+			},
+			expectedErr: "differs",
+		},
+		{
+			name:        "no endpoints",
+			expectedErr: "no endpoints",
+		},
+	}
+	for _, test := range table {
+		t.Run(test.name, func(t *testing.T) {
+			sink := &fakeMetricsSink{}
+			m := &Management{
+				Client:                      &fakeClient{get: objects(test.endpoints)},
+				MetricsSink:                 sink,
+				externalEtcdClientGenerator: externalEtcdClientGenerator(fakeEtcdClientGenerator(test.etcd)),
+			}
+
+			// The workload cluster is never built: there is no kubeconfig secret and no control plane machine.
+			err := m.TargetClusterEtcdIsHealthy(context.Background(), clusterKey, "my-control-plane")
+			if test.expectedErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), test.expectedErr) {
+				t.Fatalf("expected an error containing %q but got %v", test.expectedErr, err)
+			}
+			if len(sink.observations) != 1 {
+				t.Fatalf("expected one observation but got %d", len(sink.observations))
+			}
+			observation := sink.observations[0]
+			if observation.HealthyNodes+observation.UnhealthyNodes != len(test.endpoints) {
+				t.Fatalf("expected every endpoint to be observed but got %+v", observation)
+			}
+		})
+	}
+}
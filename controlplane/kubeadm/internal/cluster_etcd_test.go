@@ -0,0 +1,1370 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	pkgerrors "github.com/pkg/errors"
+	"go.etcd.io/etcd/clientv3"
+	"go.etcd.io/etcd/etcdserver/api/v3rpc/rpctypes"
+	"go.etcd.io/etcd/etcdserver/etcdserverpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/bootstrap/kubeadm/types/v1beta1"
+	"sigs.k8s.io/cluster-api/controlplane/kubeadm/internal/etcd"
+	"sigs.k8s.io/cluster-api/util/secret"
+)
+
+// fakeEtcd is a fake of the etcd client from etcd's clientv3 package connected to a single member.
+type fakeEtcd struct {
+	sync.Mutex
+
+	memberID          uint64
+	leader            uint64
+	raftIndex         uint64
+	members           []*etcdserverpb.Member
+	alarms            []*etcdserverpb.AlarmMember
+	revision          int64
+	compactedRevision int64
+	snapshot          string
+	snapshotErr       error
+	err               error
+
+	compactions     []int64
+	promotions      []uint64
+	removals        []uint64
+	leaderMoves     []uint64
+	learners        []string
+	disarmedAlarms  []*clientv3.AlarmMember
+	defragmentation int
+	closed          int
+}
+
+func (f *fakeEtcd) AlarmDisarm(_ context.Context, m *clientv3.AlarmMember) (*clientv3.AlarmResponse, error) {
+	f.Lock()
+	defer f.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.disarmedAlarms = append(f.disarmedAlarms, m)
+	return &clientv3.AlarmResponse{}, nil
+}
+
+func (f *fakeEtcd) AlarmList(_ context.Context) (*clientv3.AlarmResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &clientv3.AlarmResponse{Alarms: f.alarms}, nil
+}
+
+func (f *fakeEtcd) Close() error {
+	f.Lock()
+	defer f.Unlock()
+	f.closed++
+	return nil
+}
+
+func (f *fakeEtcd) Compact(_ context.Context, rev int64, _ ...clientv3.CompactOption) (*clientv3.CompactResponse, error) {
+	f.Lock()
+	defer f.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.compactions = append(f.compactions, rev)
+	f.compactedRevision = rev
+	return &clientv3.CompactResponse{}, nil
+}
+
+func (f *fakeEtcd) Defragment(_ context.Context, _ string) (*clientv3.DefragmentResponse, error) {
+	f.Lock()
+	defer f.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.defragmentation++
+	return &clientv3.DefragmentResponse{}, nil
+}
+
+func (f *fakeEtcd) Endpoints() []string {
+	return []string{"127.0.0.1"}
+}
+
+func (f *fakeEtcd) Get(_ context.Context, _ string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	op := clientv3.OpGet("", opts...)
+	if op.Rev() < f.compactedRevision {
+		return nil, rpctypes.ErrCompacted
+	}
+	return &clientv3.GetResponse{}, nil
+}
+
+func (f *fakeEtcd) MemberList(_ context.Context) (*clientv3.MemberListResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &clientv3.MemberListResponse{
+		Header:  &etcdserverpb.ResponseHeader{ClusterId: 1, MemberId: f.memberID},
+		Members: f.members,
+	}, nil
+}
+
+func (f *fakeEtcd) MemberAddAsLearner(_ context.Context, peerAddrs []string) (*clientv3.MemberAddResponse, error) {
+	f.Lock()
+	defer f.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.learners = append(f.learners, peerAddrs...)
+	return &clientv3.MemberAddResponse{
+		Member: &etcdserverpb.Member{ID: 100, PeerURLs: peerAddrs, IsLearner: true},
+	}, nil
+}
+
+func (f *fakeEtcd) MemberPromote(_ context.Context, id uint64) (*clientv3.MemberPromoteResponse, error) {
+	f.Lock()
+	defer f.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.promotions = append(f.promotions, id)
+	return &clientv3.MemberPromoteResponse{}, nil
+}
+
+func (f *fakeEtcd) MemberRemove(_ context.Context, id uint64) (*clientv3.MemberRemoveResponse, error) {
+	f.Lock()
+	defer f.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.removals = append(f.removals, id)
+	return &clientv3.MemberRemoveResponse{}, nil
+}
+
+func (f *fakeEtcd) MemberUpdate(_ context.Context, _ uint64, _ []string) (*clientv3.MemberUpdateResponse, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeEtcd) MoveLeader(_ context.Context, id uint64) (*clientv3.MoveLeaderResponse, error) {
+	f.Lock()
+	defer f.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.leaderMoves = append(f.leaderMoves, id)
+	return &clientv3.MoveLeaderResponse{}, nil
+}
+
+func (f *fakeEtcd) Snapshot(_ context.Context) (io.ReadCloser, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return ioutil.NopCloser(io.MultiReader(strings.NewReader(f.snapshot), &errReader{err: f.snapshotErr})), nil
+}
+
+func (f *fakeEtcd) Status(_ context.Context, _ string) (*clientv3.StatusResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &clientv3.StatusResponse{
+		Header:    &etcdserverpb.ResponseHeader{ClusterId: 1, MemberId: f.memberID, Revision: f.revision},
+		Leader:    f.leader,
+		RaftIndex: f.raftIndex,
+	}, nil
+}
+
+// errReader is a reader that fails with err, or reports EOF if err is nil.
+type errReader struct {
+	err error
+}
+
+func (r *errReader) Read(_ []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	return 0, io.EOF
+}
+
+// fakeEtcdClientGenerator returns an etcdClientGenerator connecting to the fake etcd member of each node.
+// Nodes without a fake member fail to connect.
+func fakeEtcdClientGenerator(members map[string]*fakeEtcd) etcdClientGenerator {
+	return func(nodeName string, _ *tls.Config, _ ...etcd.EtcdClientOption) (*etcd.Client, error) {
+		member, ok := members[nodeName]
+		if !ok {
+			return nil, fmt.Errorf("failed to dial etcd on node %q", nodeName)
+		}
+		return etcd.NewClientWithEtcd(member)
+	}
+}
+
+// etcdCAForTest returns a freshly generated etcd CA certificate and key.
+func etcdCAForTest(t *testing.T) ([]byte, []byte) {
+	t.Helper()
+	certificates := secret.NewCertificatesForInitialControlPlane(&v1beta1.ClusterConfiguration{})
+	if err := certificates.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	etcdCA := certificates.GetByPurpose(secret.EtcdCA)
+	return etcdCA.KeyPair.Cert, etcdCA.KeyPair.Key
+}
+
+// etcdCABundleForTest returns a freshly generated etcd CA.
+func etcdCABundleForTest(t *testing.T) *EtcdCABundle {
+	t.Helper()
+	etcdCACert, etcdCAKey := etcdCAForTest(t)
+	etcdCASecret := &corev1.Secret{Data: map[string][]byte{
+		secret.TLSCrtDataName: etcdCACert,
+		secret.TLSKeyDataName: etcdCAKey,
+	}}
+	etcdCA, err := etcdCABundleFromSecret(etcdCASecret, types.NamespacedName{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return etcdCA
+}
+
+// etcdClusterForTest returns a workload cluster with control plane nodes backed by the given fake etcd members.
+func etcdClusterForTest(t *testing.T, members map[string]*fakeEtcd, nodeNames ...string) *cluster {
+	t.Helper()
+	nodes := &corev1.NodeList{}
+	for _, name := range nodeNames {
+		nodes.Items = append(nodes.Items, corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       corev1.NodeSpec{ProviderID: "test://" + name},
+		})
+	}
+	return &cluster{
+		client:              &fakeClient{list: nodes},
+		etcdCA:              etcdCABundleForTest(t),
+		etcdClientGenerator: fakeEtcdClientGenerator(members),
+	}
+}
+
+// managementClusterForTest returns a management cluster that has the given workload cluster cached.
+func managementClusterForTest(clusterKey types.NamespacedName, workloadCluster *cluster) *Management {
+	secrets := map[string]interface{}{
+		fmt.Sprintf("%s/%s-kubeconfig", clusterKey.Namespace, clusterKey.Name): &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{ResourceVersion: "1"},
+		},
+		fmt.Sprintf("%s/%s-etcd", clusterKey.Namespace, clusterKey.Name): &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{ResourceVersion: "1"},
+		},
+	}
+	m := &Management{Client: &fakeClient{get: secrets}}
+	m.cacheCluster(clusterKey, workloadCluster, "1", "1")
+	return m
+}
+
+func TestCompactEtcd(t *testing.T) {
+	clusterKey := types.NamespacedName{Namespace: "my-namespace", Name: "my-cluster"}
+
+	t.Run("compacts through the first healthy member", func(t *testing.T) {
+		unhealthy := &fakeEtcd{memberID: 1, err: errors.New("connection refused")}
+		healthy := &fakeEtcd{memberID: 2, revision: 42}
+		other := &fakeEtcd{memberID: 3, revision: 42}
+		members := map[string]*fakeEtcd{"first": unhealthy, "second": healthy, "third": other}
+		m := managementClusterForTest(clusterKey, etcdClusterForTest(t, members, "first", "second", "third"))
+
+		if err := m.CompactEtcd(context.Background(), clusterKey); err != nil {
+			t.Fatal(err)
+		}
+		if len(healthy.compactions) != 1 || healthy.compactions[0] != 42 {
+			t.Fatalf("expected a single compaction to revision 42 but got %v", healthy.compactions)
+		}
+		if len(other.compactions) != 0 {
+			t.Fatalf("expected compaction to be issued once for the cluster but got %v", other.compactions)
+		}
+		if unhealthy.closed != 1 || healthy.closed != 1 {
+			t.Fatal("expected every created etcd client to be closed")
+		}
+	})
+
+	t.Run("fails when no revision can be read", func(t *testing.T) {
+		members := map[string]*fakeEtcd{"first": {err: errors.New("connection refused")}}
+		m := managementClusterForTest(clusterKey, etcdClusterForTest(t, members, "first", "second"))
+
+		if err := m.CompactEtcd(context.Background(), clusterKey); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+func TestGetEtcdCompactionStatus(t *testing.T) {
+	clusterKey := types.NamespacedName{Namespace: "my-namespace", Name: "my-cluster"}
+	members := map[string]*fakeEtcd{
+		"first":  {memberID: 1, revision: 100, compactedRevision: 37},
+		"second": {memberID: 2, revision: 100},
+	}
+	m := managementClusterForTest(clusterKey, etcdClusterForTest(t, members, "first", "second", "third"))
+
+	status, err := m.GetEtcdCompactionStatus(context.Background(), clusterKey)
+	if err == nil {
+		t.Fatal("expected the unreachable member to be reported")
+	}
+	expected := map[string]EtcdCompactionStatus{
+		"first":  {Revision: 100, CompactedRevision: 37},
+		"second": {Revision: 100, CompactedRevision: 0},
+	}
+	if len(status) != len(expected) {
+		t.Fatalf("expected status for %d members but got %v", len(expected), status)
+	}
+	for name, s := range expected {
+		if status[name] != s {
+			t.Fatalf("expected status %+v for node %q but got %+v", s, name, status[name])
+		}
+	}
+}
+
+func TestEtcdClientOptions(t *testing.T) {
+	t.Run("defaults leave the etcd client configuration untouched", func(t *testing.T) {
+		m := &Management{}
+		if options := m.etcdClientOptions(); len(options) != 0 {
+			t.Fatalf("expected no etcd client options but got %d", len(options))
+		}
+	})
+
+	t.Run("options are passed to the etcd client generator", func(t *testing.T) {
+		m := &Management{
+			EtcdDialKeepAliveTime:    30 * time.Second,
+			EtcdDialKeepAliveTimeout: 10 * time.Second,
+			EtcdMaxCallRecvMsgSize:   16 * 1024 * 1024,
+		}
+		var config clientv3.Config
+		workloadCluster := &cluster{
+			etcdClientOptions: m.etcdClientOptions(),
+			etcdClientGenerator: func(_ string, _ *tls.Config, options ...etcd.EtcdClientOption) (*etcd.Client, error) {
+				for _, option := range options {
+					option(&config)
+				}
+				return etcd.NewClientWithEtcd(&fakeEtcd{})
+			},
+		}
+
+		if _, err := workloadCluster.etcdClientForNode("first-control-plane", &tls.Config{}); err != nil {
+			t.Fatal(err)
+		}
+		if config.DialKeepAliveTime != 30*time.Second {
+			t.Fatalf("expected keepalive time 30s but got %v", config.DialKeepAliveTime)
+		}
+		if config.DialKeepAliveTimeout != 10*time.Second {
+			t.Fatalf("expected keepalive timeout 10s but got %v", config.DialKeepAliveTimeout)
+		}
+		if config.MaxCallRecvMsgSize != 16*1024*1024 {
+			t.Fatalf("expected max receive message size %d but got %d", 16*1024*1024, config.MaxCallRecvMsgSize)
+		}
+	})
+}
+
+func TestGetEtcdDataDirHostPaths(t *testing.T) {
+	clusterKey := types.NamespacedName{Namespace: "my-namespace", Name: "my-cluster"}
+	etcdPod := func(args []string, mountPath string, source corev1.VolumeSource) *corev1.Pod {
+		return &corev1.Pod{
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{
+					Name:         "etcd",
+					Command:      append([]string{"etcd"}, args...),
+					VolumeMounts: []corev1.VolumeMount{{Name: "etcd-data", MountPath: mountPath}},
+				}},
+				Volumes: []corev1.Volume{{Name: "etcd-data", VolumeSource: source}},
+			},
+		}
+	}
+	hostPath := func(path string) corev1.VolumeSource {
+		return corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: path}}
+	}
+
+	workloadCluster := &cluster{
+		client: &fakeClient{
+			list: &corev1.NodeList{Items: []corev1.Node{
+				{ObjectMeta: metav1.ObjectMeta{Name: "default-data-dir"}},
+				{ObjectMeta: metav1.ObjectMeta{Name: "custom-data-dir"}},
+				{ObjectMeta: metav1.ObjectMeta{Name: "ephemeral-data-dir"}},
+				{ObjectMeta: metav1.ObjectMeta{Name: "missing-pod"}},
+			}},
+			get: map[string]interface{}{
+				"kube-system/etcd-default-data-dir": etcdPod(nil, "/var/lib/etcd", hostPath("/var/lib/etcd")),
+				"kube-system/etcd-custom-data-dir": etcdPod([]string{"--data-dir=/mnt/etcd/"}, "/mnt/etcd",
+					hostPath("/mnt/disks/etcd")),
+				"kube-system/etcd-ephemeral-data-dir": etcdPod(nil, "/var/lib/etcd",
+					corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}),
+			},
+		},
+	}
+	m := managementClusterForTest(clusterKey, workloadCluster)
+
+	hostPaths, err := m.GetEtcdDataDirHostPaths(context.Background(), clusterKey)
+	if err == nil {
+		t.Fatal("expected the ephemeral data directory and missing pod to be reported")
+	}
+	expected := map[string]string{
+		"default-data-dir": "/var/lib/etcd",
+		"custom-data-dir":  "/mnt/disks/etcd",
+	}
+	if !reflect.DeepEqual(expected, hostPaths) {
+		t.Fatalf("expected host paths %v but got %v", expected, hostPaths)
+	}
+}
+
+func TestCanSafelyRemoveEtcdMember(t *testing.T) {
+	members := func(names ...string) []*etcd.Member {
+		result := []*etcd.Member{}
+		for i, name := range names {
+			result = append(result, &etcd.Member{ID: uint64(i + 1), Name: name})
+		}
+		return result
+	}
+	healthy := func(ids ...uint64) map[uint64]bool {
+		result := map[uint64]bool{}
+		for _, id := range ids {
+			result[id] = true
+		}
+		return result
+	}
+	withLearner := members("first", "second", "third", "fourth")
+	withLearner[3].IsLearner = true
+
+	table := []struct {
+		name     string
+		members  []*etcd.Member
+		healthy  map[uint64]bool
+		nodeName string
+		expected bool
+	}{
+		{name: "healthy member of a healthy cluster", members: members("first", "second", "third"), healthy: healthy(1, 2, 3), nodeName: "first", expected: true},
+		{name: "unhealthy member of a degraded cluster", members: members("first", "second", "third"), healthy: healthy(2, 3), nodeName: "first", expected: true},
+		{name: "healthy member of a degraded cluster", members: members("first", "second", "third"), healthy: healthy(2, 3), nodeName: "second", expected: false},
+		{name: "last member", members: members("first"), healthy: healthy(1), nodeName: "first", expected: false},
+		{name: "not a member", members: members("first"), healthy: healthy(1), nodeName: "second", expected: true},
+		{name: "learners do not count towards quorum", members: withLearner, healthy: healthy(2, 4), nodeName: "third", expected: false},
+		{name: "learner", members: withLearner, healthy: healthy(1, 2, 3), nodeName: "fourth", expected: true},
+	}
+	for _, test := range table {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := canSafelyRemoveEtcdMember(test.members, test.healthy, test.nodeName); actual != test.expected {
+				t.Fatalf("expected %t but got %t", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestEtcdMembershipIsStable(t *testing.T) {
+	clusterKey := types.NamespacedName{Namespace: "default", Name: "test-cluster"}
+	first := &etcdserverpb.Member{ID: 1, Name: "first"}
+	second := &etcdserverpb.Member{ID: 2, Name: "second"}
+
+	table := []struct {
+		name          string
+		before, after []*etcdserverpb.Member
+		expected      bool
+	}{
+		{name: "unchanged membership", before: []*etcdserverpb.Member{first, second}, after: []*etcdserverpb.Member{second, first}, expected: true},
+		{name: "member added", before: []*etcdserverpb.Member{first}, after: []*etcdserverpb.Member{first, second}, expected: false},
+		{name: "member removed", before: []*etcdserverpb.Member{first, second}, after: []*etcdserverpb.Member{first}, expected: false},
+	}
+	for _, test := range table {
+		t.Run(test.name, func(t *testing.T) {
+			member := &fakeEtcd{memberID: 1, members: test.before}
+			workloadCluster := etcdClusterForTest(t, map[string]*fakeEtcd{"first": member}, "first")
+			generator := workloadCluster.etcdClientGenerator
+			dials := 0
+			workloadCluster.etcdClientGenerator = func(nodeName string, tlsConfig *tls.Config, options ...etcd.EtcdClientOption) (*etcd.Client, error) {
+				// The member list changes once the first sample has been taken.
+				dials++
+				if dials > 1 {
+					member.members = test.after
+				}
+				return generator(nodeName, tlsConfig, options...)
+			}
+			m := managementClusterForTest(clusterKey, workloadCluster)
+			m.EtcdMembershipSampleInterval = time.Millisecond
+
+			stable, err := m.EtcdMembershipIsStable(context.Background(), clusterKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stable != test.expected {
+				t.Fatalf("expected %t but got %t", test.expected, stable)
+			}
+		})
+	}
+}
+
+func TestEtcdMembershipIsStableNoHealthyMember(t *testing.T) {
+	clusterKey := types.NamespacedName{Namespace: "default", Name: "test-cluster"}
+	workloadCluster := etcdClusterForTest(t, map[string]*fakeEtcd{
+		"first": {err: errors.New("connection refused")},
+	}, "first")
+	m := managementClusterForTest(clusterKey, workloadCluster)
+	m.EtcdMembershipSampleInterval = time.Millisecond
+
+	if _, err := m.EtcdMembershipIsStable(context.Background(), clusterKey); err == nil {
+		t.Fatal("expected an error when no etcd member is healthy")
+	}
+}
+
+func TestEtcdLearnersReadyForPromotion(t *testing.T) {
+	clusterKey := types.NamespacedName{Namespace: "default", Name: "test-cluster"}
+	members := []*etcdserverpb.Member{
+		{ID: 1, Name: "leader"},
+		{ID: 2, Name: "caught-up", IsLearner: true},
+		{ID: 3, Name: "lagging", IsLearner: true},
+	}
+	member := func(id, raftIndex uint64) *fakeEtcd {
+		return &fakeEtcd{memberID: id, leader: 1, raftIndex: raftIndex, members: members}
+	}
+
+	t.Run("returns the learners that caught up with the leader", func(t *testing.T) {
+		workloadCluster := etcdClusterForTest(t, map[string]*fakeEtcd{
+			"leader":    member(1, 1000),
+			"caught-up": member(2, 950),
+			"lagging":   member(3, 100),
+		}, "leader", "caught-up", "lagging")
+		m := managementClusterForTest(clusterKey, workloadCluster)
+
+		ready, err := m.EtcdLearnersReadyForPromotion(context.Background(), clusterKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(ready, []string{"caught-up"}) {
+			t.Fatalf("expected [caught-up] but got %v", ready)
+		}
+	})
+
+	t.Run("unreachable learners are not ready", func(t *testing.T) {
+		workloadCluster := etcdClusterForTest(t, map[string]*fakeEtcd{
+			"leader":    member(1, 1000),
+			"caught-up": member(2, 1000),
+		}, "leader", "caught-up", "lagging")
+		m := managementClusterForTest(clusterKey, workloadCluster)
+
+		ready, err := m.EtcdLearnersReadyForPromotion(context.Background(), clusterKey)
+		if err == nil {
+			t.Fatal("expected the unreachable learner to be reported")
+		}
+		if !reflect.DeepEqual(ready, []string{"caught-up"}) {
+			t.Fatalf("expected [caught-up] but got %v", ready)
+		}
+	})
+
+	t.Run("no learners", func(t *testing.T) {
+		voters := []*etcdserverpb.Member{{ID: 1, Name: "leader"}}
+		workloadCluster := etcdClusterForTest(t, map[string]*fakeEtcd{
+			"leader": {memberID: 1, leader: 1, raftIndex: 1000, members: voters},
+		}, "leader")
+		m := managementClusterForTest(clusterKey, workloadCluster)
+
+		ready, err := m.EtcdLearnersReadyForPromotion(context.Background(), clusterKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ready == nil || len(ready) != 0 {
+			t.Fatalf("expected an empty slice but got %#v", ready)
+		}
+	})
+}
+
+func TestPromoteEtcdLearner(t *testing.T) {
+	clusterKey := types.NamespacedName{Namespace: "default", Name: "test-cluster"}
+	members := []*etcdserverpb.Member{
+		{ID: 1, Name: "leader"},
+		{ID: 2, Name: "caught-up", IsLearner: true},
+		{ID: 3, Name: "lagging", IsLearner: true},
+	}
+
+	table := []struct {
+		name        string
+		nodeName    string
+		force       bool
+		expectErr   bool
+		expectedIDs []uint64
+	}{
+		{name: "caught up learner", nodeName: "caught-up", expectedIDs: []uint64{2}},
+		{name: "lagging learner", nodeName: "lagging", expectErr: true},
+		{name: "forced lagging learner", nodeName: "lagging", force: true, expectedIDs: []uint64{3}},
+		{name: "voting member", nodeName: "leader", expectErr: true},
+		{name: "not a member", nodeName: "other", force: true, expectErr: true},
+	}
+	for _, test := range table {
+		t.Run(test.name, func(t *testing.T) {
+			leader := &fakeEtcd{memberID: 1, leader: 1, raftIndex: 1000, members: members}
+			workloadCluster := etcdClusterForTest(t, map[string]*fakeEtcd{
+				"leader":    leader,
+				"caught-up": {memberID: 2, leader: 1, raftIndex: 990, members: members},
+				"lagging":   {memberID: 3, leader: 1, raftIndex: 10, members: members},
+			}, "leader", "caught-up", "lagging")
+			m := managementClusterForTest(clusterKey, workloadCluster)
+
+			err := m.PromoteEtcdLearner(context.Background(), clusterKey, test.nodeName, test.force)
+			if test.expectErr != (err != nil) {
+				t.Fatalf("expected error %t but got %v", test.expectErr, err)
+			}
+			if !reflect.DeepEqual(leader.promotions, test.expectedIDs) {
+				t.Fatalf("expected promotions %v but got %v", test.expectedIDs, leader.promotions)
+			}
+		})
+	}
+}
+
+func TestForwardEtcdLeadership(t *testing.T) {
+	clusterKey := types.NamespacedName{Namespace: "default", Name: "test-cluster"}
+	members := []*etcdserverpb.Member{
+		{ID: 1, Name: "leader"},
+		{ID: 2, Name: "learner", IsLearner: true},
+		{ID: 3, Name: "unreachable"},
+		{ID: 4, Name: "follower"},
+	}
+	machineForNode := func(nodeName string) *clusterv1.Machine {
+		return &clusterv1.Machine{Status: clusterv1.MachineStatus{NodeRef: &corev1.ObjectReference{Name: nodeName}}}
+	}
+
+	table := []struct {
+		name        string
+		machine     *clusterv1.Machine
+		noFollower  bool
+		expectErr   bool
+		expectedIDs []uint64
+	}{
+		{name: "leader", machine: machineForNode("leader"), expectedIDs: []uint64{4}},
+		{name: "follower", machine: machineForNode("follower")},
+		{name: "unreachable member", machine: machineForNode("unreachable")},
+		{name: "machine without a node", machine: &clusterv1.Machine{}},
+		{name: "leader without a reachable voting member", machine: machineForNode("leader"), noFollower: true, expectErr: true},
+	}
+	for _, test := range table {
+		t.Run(test.name, func(t *testing.T) {
+			leader := &fakeEtcd{memberID: 1, leader: 1, members: members}
+			etcdMembers := map[string]*fakeEtcd{
+				"leader":  leader,
+				"learner": {memberID: 2, leader: 1, members: members},
+			}
+			if !test.noFollower {
+				etcdMembers["follower"] = &fakeEtcd{memberID: 4, leader: 1, members: members}
+			}
+			workloadCluster := etcdClusterForTest(t, etcdMembers, "leader", "learner", "unreachable", "follower")
+			m := managementClusterForTest(clusterKey, workloadCluster)
+
+			err := m.ForwardEtcdLeadership(context.Background(), clusterKey, test.machine)
+			if test.expectErr != (err != nil) {
+				t.Fatalf("expected error %t but got %v", test.expectErr, err)
+			}
+			if !reflect.DeepEqual(leader.leaderMoves, test.expectedIDs) {
+				t.Fatalf("expected leader moves %v but got %v", test.expectedIDs, leader.leaderMoves)
+			}
+		})
+	}
+}
+
+func TestRemoveEtcdMemberForMachine(t *testing.T) {
+	clusterKey := types.NamespacedName{Namespace: "default", Name: "test-cluster"}
+	members := []*etcdserverpb.Member{{ID: 1, Name: "first"}, {ID: 2, Name: "second"}, {ID: 3, Name: "third"}}
+	machineForNode := func(nodeName string) *clusterv1.Machine {
+		machine := &clusterv1.Machine{}
+		if nodeName != "" {
+			machine.Status.NodeRef = &corev1.ObjectReference{Name: nodeName}
+		}
+		return machine
+	}
+
+	table := []struct {
+		name        string
+		machine     *clusterv1.Machine
+		nodes       []string
+		expectErr   bool
+		expectedIDs []uint64
+	}{
+		{name: "member of the machine's node", machine: machineForNode("third"), nodes: []string{"first", "second", "third"}, expectedIDs: []uint64{3}},
+		{name: "machine without a node", machine: machineForNode("")},
+		{name: "node without a member", machine: machineForNode("other"), nodes: []string{"first", "second", "third", "other"}},
+		{name: "last control plane node", machine: machineForNode("first"), nodes: []string{"first"}, expectErr: true},
+	}
+	for _, test := range table {
+		t.Run(test.name, func(t *testing.T) {
+			first := &fakeEtcd{memberID: 1, members: members}
+			third := &fakeEtcd{memberID: 3, members: members}
+			workloadCluster := etcdClusterForTest(t, map[string]*fakeEtcd{
+				"first":  first,
+				"second": {memberID: 2, members: members},
+				"third":  third,
+			}, test.nodes...)
+			m := managementClusterForTest(clusterKey, workloadCluster)
+
+			err := m.RemoveEtcdMemberForMachine(context.Background(), clusterKey, test.machine)
+			if test.expectErr != (err != nil) {
+				t.Fatalf("expected error %t but got %v", test.expectErr, err)
+			}
+			if !reflect.DeepEqual(first.removals, test.expectedIDs) {
+				t.Fatalf("expected removals %v but got %v", test.expectedIDs, first.removals)
+			}
+			if len(third.removals) > 0 {
+				t.Fatalf("expected the member to be removed through another member but got removals %v", third.removals)
+			}
+		})
+	}
+}
+
+func TestEtcdHealthReport(t *testing.T) {
+	clusterKey := types.NamespacedName{Namespace: "default", Name: "test-cluster"}
+	allMembers := []*etcdserverpb.Member{{ID: 1, Name: "first"}, {ID: 2, Name: "second"}, {ID: 3, Name: "third"}}
+	twoMembers := allMembers[:2]
+
+	t.Run("unreachable members are reported next to the healthy ones", func(t *testing.T) {
+		workloadCluster := etcdClusterForTest(t, map[string]*fakeEtcd{
+			"first":  {memberID: 1, members: allMembers},
+			"second": {memberID: 2, members: allMembers},
+		}, "first", "second", "third")
+		m := managementClusterForTest(clusterKey, workloadCluster)
+
+		report, err := m.EtcdHealthReport(context.Background(), clusterKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report["first"] != nil || report["second"] != nil {
+			t.Fatalf("expected first and second to be healthy but got %v", report)
+		}
+		if report["third"] == nil {
+			t.Fatal("expected third to be reported as unreachable")
+		}
+	})
+
+	t.Run("per node results are kept when the check fails", func(t *testing.T) {
+		workloadCluster := etcdClusterForTest(t, map[string]*fakeEtcd{
+			"first":  {memberID: 1, members: twoMembers},
+			"second": {memberID: 2, members: twoMembers},
+		}, "first", "second", "third")
+		m := managementClusterForTest(clusterKey, workloadCluster)
+
+		report, err := m.EtcdHealthReport(context.Background(), clusterKey)
+		if err == nil {
+			t.Fatal("expected an error because there are more control plane nodes than etcd members")
+		}
+		if len(report) != 3 {
+			t.Fatalf("expected a result for all 3 nodes but got %v", report)
+		}
+		if report["first"] != nil || report["second"] != nil || report["third"] == nil {
+			t.Fatalf("expected first and second to be healthy and third to be unreachable but got %v", report)
+		}
+
+		err = m.TargetClusterEtcdIsHealthy(context.Background(), clusterKey, "my-control-plane")
+		if err == nil {
+			t.Fatal("expected the etcd health check to fail")
+		}
+		if !strings.Contains(err.Error(), "2 of 3 nodes checked healthy") || !strings.Contains(err.Error(), `node "third"`) {
+			t.Fatalf("expected the error to report the per node results but got %q", err.Error())
+		}
+	})
+
+	t.Run("no results when the cluster cannot be reached", func(t *testing.T) {
+		m := &Management{Client: &fakeClient{}}
+
+		report, err := m.EtcdHealthReport(context.Background(), clusterKey)
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+		if report != nil {
+			t.Fatalf("expected no results but got %v", report)
+		}
+	})
+}
+
+func TestEtcdClientsAreClosed(t *testing.T) {
+	clusterKey := types.NamespacedName{Namespace: "default", Name: "test-cluster"}
+	members := []*etcdserverpb.Member{
+		{ID: 1, Name: "first"},
+		{ID: 2, Name: "second", IsLearner: true},
+		{ID: 3, Name: "third"},
+	}
+
+	table := []struct {
+		name string
+		call func(m *Management) error
+	}{
+		{name: "etcd health check", call: func(m *Management) error {
+			_, err := m.EtcdHealthReport(context.Background(), clusterKey)
+			return err
+		}},
+		{name: "compaction", call: func(m *Management) error {
+			return m.CompactEtcd(context.Background(), clusterKey)
+		}},
+		{name: "compaction status", call: func(m *Management) error {
+			_, err := m.GetEtcdCompactionStatus(context.Background(), clusterKey)
+			return err
+		}},
+		{name: "member removal check", call: func(m *Management) error {
+			_, err := m.CanSafelyRemoveEtcdMember(context.Background(), clusterKey, "first")
+			return err
+		}},
+		{name: "membership stability", call: func(m *Management) error {
+			_, err := m.EtcdMembershipIsStable(context.Background(), clusterKey)
+			return err
+		}},
+		{name: "learner readiness", call: func(m *Management) error {
+			_, err := m.EtcdLearnersReadyForPromotion(context.Background(), clusterKey)
+			return err
+		}},
+		{name: "learner promotion", call: func(m *Management) error {
+			return m.PromoteEtcdLearner(context.Background(), clusterKey, "second", false)
+		}},
+	}
+	for _, test := range table {
+		t.Run(test.name, func(t *testing.T) {
+			fakes := map[string]*fakeEtcd{
+				"first":  {memberID: 1, leader: 1, members: members},
+				"second": {memberID: 2, leader: 1, members: members},
+				"third":  {memberID: 3, err: errors.New("connection refused")},
+			}
+			workloadCluster := etcdClusterForTest(t, fakes, "first", "second", "third")
+			generator := workloadCluster.etcdClientGenerator
+			created := map[string]int{}
+			workloadCluster.etcdClientGenerator = func(nodeName string, tlsConfig *tls.Config, options ...etcd.EtcdClientOption) (*etcd.Client, error) {
+				client, err := generator(nodeName, tlsConfig, options...)
+				if err == nil {
+					created[nodeName]++
+				}
+				return client, err
+			}
+			m := managementClusterForTest(clusterKey, workloadCluster)
+			m.EtcdMembershipSampleInterval = time.Millisecond
+
+			// Only leaked clients matter here; some calls are expected to fail because of the unreachable member.
+			_ = test.call(m)
+
+			if len(created) == 0 {
+				t.Fatal("expected etcd clients to be created")
+			}
+			for name, member := range fakes {
+				if member.closed != created[name] {
+					t.Fatalf("expected the %d clients created for node %q to be closed once each, but Close was called %d times", created[name], name, member.closed)
+				}
+			}
+		})
+	}
+}
+
+func TestSnapshotEtcd(t *testing.T) {
+	clusterKey := types.NamespacedName{Namespace: "default", Name: "test-cluster"}
+
+	t.Run("streams the snapshot of the first reachable member", func(t *testing.T) {
+		workloadCluster := etcdClusterForTest(t, map[string]*fakeEtcd{
+			"first":  {err: errors.New("connection refused")},
+			"second": {snapshot: "second-snapshot"},
+		}, "first", "second")
+		m := managementClusterForTest(clusterKey, workloadCluster)
+
+		var buf bytes.Buffer
+		if err := m.SnapshotEtcd(context.Background(), clusterKey, &buf); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != "second-snapshot" {
+			t.Fatalf("expected the snapshot of the second member but got %q", buf.String())
+		}
+	})
+
+	t.Run("fails if no member is reachable", func(t *testing.T) {
+		workloadCluster := etcdClusterForTest(t, map[string]*fakeEtcd{
+			"first": {err: errors.New("connection refused")},
+		}, "first", "second")
+		m := managementClusterForTest(clusterKey, workloadCluster)
+
+		var buf bytes.Buffer
+		if err := m.SnapshotEtcd(context.Background(), clusterKey, &buf); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+
+	t.Run("fails if the stream breaks", func(t *testing.T) {
+		member := &fakeEtcd{snapshot: "partial", snapshotErr: errors.New("stream reset")}
+		workloadCluster := etcdClusterForTest(t, map[string]*fakeEtcd{"first": member}, "first")
+		m := managementClusterForTest(clusterKey, workloadCluster)
+
+		var buf bytes.Buffer
+		if err := m.SnapshotEtcd(context.Background(), clusterKey, &buf); err == nil {
+			t.Fatal("expected an error")
+		}
+		if member.closed != 1 {
+			t.Fatalf("expected the etcd client to be closed once but it was closed %d times", member.closed)
+		}
+	})
+}
+
+func TestEtcdRevisionConsistency(t *testing.T) {
+	clusterKey := types.NamespacedName{Namespace: "default", Name: "test-cluster"}
+
+	table := []struct {
+		name              string
+		members           map[string]*fakeEtcd
+		expectedRevisions map[string]int64
+		expectedSkew      int64
+		expectErr         bool
+	}{
+		{
+			name: "members agree",
+			members: map[string]*fakeEtcd{
+				"first":  {revision: 100},
+				"second": {revision: 100},
+				"third":  {revision: 100},
+			},
+			expectedRevisions: map[string]int64{"first": 100, "second": 100, "third": 100},
+			expectedSkew:      0,
+		},
+		{
+			name: "lagging member",
+			members: map[string]*fakeEtcd{
+				"first":  {revision: 100},
+				"second": {revision: 40},
+				"third":  {revision: 98},
+			},
+			expectedRevisions: map[string]int64{"first": 100, "second": 40, "third": 98},
+			expectedSkew:      60,
+		},
+		{
+			name: "unreachable members are left out of the skew",
+			members: map[string]*fakeEtcd{
+				"first":  {revision: 100},
+				"second": {revision: 1, err: errors.New("connection refused")},
+				"third":  {revision: 97},
+			},
+			expectedRevisions: map[string]int64{"first": 100, "third": 97},
+			expectedSkew:      3,
+			expectErr:         true,
+		},
+	}
+	for _, test := range table {
+		t.Run(test.name, func(t *testing.T) {
+			workloadCluster := etcdClusterForTest(t, test.members, "first", "second", "third")
+			m := managementClusterForTest(clusterKey, workloadCluster)
+
+			revisions, skew, err := m.EtcdRevisionConsistency(context.Background(), clusterKey)
+			if test.expectErr != (err != nil) {
+				t.Fatalf("expected error %t but got %v", test.expectErr, err)
+			}
+			if !reflect.DeepEqual(revisions, test.expectedRevisions) {
+				t.Fatalf("expected revisions %v but got %v", test.expectedRevisions, revisions)
+			}
+			if skew != test.expectedSkew {
+				t.Fatalf("expected skew %d but got %d", test.expectedSkew, skew)
+			}
+		})
+	}
+}
+
+func TestListEtcdAlarms(t *testing.T) {
+	clusterKey := types.NamespacedName{Namespace: "default", Name: "test-cluster"}
+	members := []*etcdserverpb.Member{{ID: 1, Name: "first"}, {ID: 2, Name: "second"}}
+
+	table := []struct {
+		name     string
+		alarms   []*etcdserverpb.AlarmMember
+		expected []EtcdAlarm
+	}{
+		{name: "no alarms", expected: []EtcdAlarm{}},
+		{
+			name: "alarms on known and unknown members",
+			alarms: []*etcdserverpb.AlarmMember{
+				{MemberID: 2, Alarm: etcdserverpb.AlarmType_NOSPACE},
+				{MemberID: 1, Alarm: etcdserverpb.AlarmType_CORRUPT},
+				{MemberID: 3, Alarm: etcdserverpb.AlarmType_NOSPACE},
+			},
+			expected: []EtcdAlarm{
+				{MemberID: 2, NodeName: "second", Type: etcd.AlarmNoSpace},
+				{MemberID: 1, NodeName: "first", Type: etcd.AlarmCorrupt},
+				{MemberID: 3, NodeName: "", Type: etcd.AlarmNoSpace},
+			},
+		},
+	}
+	for _, test := range table {
+		t.Run(test.name, func(t *testing.T) {
+			workloadCluster := etcdClusterForTest(t, map[string]*fakeEtcd{
+				"first":  {err: errors.New("connection refused")},
+				"second": {memberID: 2, members: members, alarms: test.alarms},
+			}, "first", "second")
+			m := managementClusterForTest(clusterKey, workloadCluster)
+
+			alarms, err := m.ListEtcdAlarms(context.Background(), clusterKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(alarms, test.expected) {
+				t.Fatalf("expected %v but got %v", test.expected, alarms)
+			}
+		})
+	}
+}
+
+func TestRecoverEtcdNoSpaceAlarms(t *testing.T) {
+	clusterKey := types.NamespacedName{Namespace: "default", Name: "test-cluster"}
+	members := []*etcdserverpb.Member{{ID: 1, Name: "first"}, {ID: 2, Name: "second"}, {ID: 3, Name: "third"}}
+	alarms := []*etcdserverpb.AlarmMember{
+		{MemberID: 2, Alarm: etcdserverpb.AlarmType_NOSPACE},
+		{MemberID: 1, Alarm: etcdserverpb.AlarmType_CORRUPT},
+		{MemberID: 4, Alarm: etcdserverpb.AlarmType_NOSPACE},
+		{MemberID: 3, Alarm: etcdserverpb.AlarmType_NOSPACE},
+	}
+	first := &fakeEtcd{memberID: 1, members: members, alarms: alarms}
+	second := &fakeEtcd{memberID: 2, members: members, alarms: alarms}
+	third := &fakeEtcd{memberID: 3, members: members, alarms: alarms, err: errors.New("connection refused")}
+	workloadCluster := etcdClusterForTest(t, map[string]*fakeEtcd{
+		"first":  first,
+		"second": second,
+		"third":  third,
+	}, "first", "second", "third")
+	m := managementClusterForTest(clusterKey, workloadCluster)
+
+	disarmed, err := m.RecoverEtcdNoSpaceAlarms(context.Background(), clusterKey)
+	if err == nil {
+		t.Fatal("expected an error for the unknown and the unreachable member")
+	}
+	expected := []EtcdAlarm{{MemberID: 2, NodeName: "second", Type: etcd.AlarmNoSpace}}
+	if !reflect.DeepEqual(disarmed, expected) {
+		t.Fatalf("expected %v to be disarmed but got %v", expected, disarmed)
+	}
+	if first.defragmentation != 0 || len(first.disarmedAlarms) != 0 {
+		t.Fatal("expected the member with a CORRUPT alarm to be left alone")
+	}
+	if second.defragmentation != 1 {
+		t.Fatalf("expected the member with a NOSPACE alarm to be defragmented once, got %d", second.defragmentation)
+	}
+	if len(second.disarmedAlarms) != 1 || second.disarmedAlarms[0].MemberID != 2 || second.disarmedAlarms[0].Alarm != etcdserverpb.AlarmType_NOSPACE {
+		t.Fatalf("expected the NOSPACE alarm of member 2 to be disarmed, got %v", second.disarmedAlarms)
+	}
+}
+
+func TestDefragmentEtcd(t *testing.T) {
+	clusterKey := types.NamespacedName{Namespace: "default", Name: "test-cluster"}
+	members := []*etcdserverpb.Member{{ID: 1, Name: "first"}, {ID: 2, Name: "second"}, {ID: 3, Name: "third"}}
+
+	t.Run("defragments every member, the leader last", func(t *testing.T) {
+		fakeMembers := map[string]*fakeEtcd{
+			"first":  {memberID: 1, leader: 2, members: members},
+			"second": {memberID: 2, leader: 2, members: members},
+			"third":  {memberID: 3, leader: 2, members: members},
+		}
+		m := managementClusterForTest(clusterKey, etcdClusterForTest(t, fakeMembers, "first", "second", "third"))
+
+		defragmented, err := m.DefragmentEtcd(context.Background(), clusterKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []string{"first", "third", "second"}
+		if !reflect.DeepEqual(defragmented, expected) {
+			t.Fatalf("expected the members on %v to be defragmented in order but got %v", expected, defragmented)
+		}
+		for name, member := range fakeMembers {
+			if member.defragmentation != 1 {
+				t.Fatalf("expected the member on %q to be defragmented once, got %d", name, member.defragmentation)
+			}
+		}
+	})
+	t.Run("does not defragment members while a member is not healthy", func(t *testing.T) {
+		fakeMembers := map[string]*fakeEtcd{
+			"first":  {memberID: 1, leader: 2, members: members},
+			"second": {memberID: 2, leader: 2, members: members},
+			"third":  {memberID: 3, leader: 2, members: members, err: errors.New("connection refused")},
+		}
+		m := managementClusterForTest(clusterKey, etcdClusterForTest(t, fakeMembers, "first", "second", "third"))
+
+		defragmented, err := m.DefragmentEtcd(context.Background(), clusterKey)
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+		if len(defragmented) != 0 {
+			t.Fatalf("expected no member to be defragmented but got %v", defragmented)
+		}
+		for name, member := range fakeMembers {
+			if member.defragmentation != 0 {
+				t.Fatalf("expected the member on %q not to be defragmented", name)
+			}
+		}
+	})
+}
+
+func TestEtcdHealthCheckWithUnprovisionedNode(t *testing.T) {
+	clusterKey := types.NamespacedName{Namespace: "default", Name: "test-cluster"}
+	members := []*etcdserverpb.Member{{ID: 1, Name: "first"}, {ID: 2, Name: "second"}}
+	machines := &clusterv1.MachineList{}
+	for _, name := range []string{"first", "second", "third"} {
+		machine := machineListForTestGetMachinesForCluster().Items[0]
+		machine.Name = name
+		machine.Status.NodeRef = &corev1.ObjectReference{Kind: "Node", Name: name}
+		machines.Items = append(machines.Items, machine)
+	}
+
+	for _, tolerate := range []bool{false, true} {
+		t.Run(fmt.Sprintf("tolerate unprovisioned nodes %t", tolerate), func(t *testing.T) {
+			workloadCluster := etcdClusterForTest(t, map[string]*fakeEtcd{
+				"first":  {memberID: 1, members: members},
+				"second": {memberID: 2, members: members},
+			}, "first", "second", "third")
+			workloadCluster.client.(*fakeClient).list.(*corev1.NodeList).Items[2].Spec.ProviderID = ""
+			workloadCluster.tolerateUnprovisionedNodes = tolerate
+			m := managementClusterForTest(clusterKey, workloadCluster)
+			m.Client.(*fakeClient).list = machines
+			m.TolerateUnprovisionedNodes = tolerate
+
+			report, _ := m.EtcdHealthReport(context.Background(), clusterKey)
+			if report["third"] != ErrNodeNotProvisioned {
+				t.Fatalf("expected the node without a provider ID to be reported as not provisioned but got %v", report["third"])
+			}
+
+			err := m.TargetClusterEtcdIsHealthy(context.Background(), clusterKey, "my-control-plane")
+			if tolerate && err != nil {
+				t.Fatalf("expected the unprovisioned node not to fail the etcd health check but got %v", err)
+			}
+			if !tolerate && err == nil {
+				t.Fatal("expected the unprovisioned node to fail the strict etcd health check")
+			}
+		})
+	}
+}
+
+func TestAddEtcdMemberAsLearner(t *testing.T) {
+	clusterKey := types.NamespacedName{Namespace: "default", Name: "test-cluster"}
+	voters := []*etcdserverpb.Member{
+		{ID: 1, Name: "first", PeerURLs: []string{"https://10.0.0.1:2380"}},
+		{ID: 2, Name: "second", PeerURLs: []string{"https://10.0.0.2:2380"}},
+	}
+	withLearner := append([]*etcdserverpb.Member{}, voters...)
+	withLearner = append(withLearner, &etcdserverpb.Member{ID: 3, Name: "third", IsLearner: true})
+
+	table := []struct {
+		name        string
+		members     []*etcdserverpb.Member
+		unreachable bool
+		peerURL     string
+		expectErr   bool
+	}{
+		{name: "healthy cluster", members: voters, peerURL: "https://10.0.0.3:2380"},
+		{name: "unhealthy member", members: voters, unreachable: true, peerURL: "https://10.0.0.3:2380", expectErr: true},
+		{name: "pending learner", members: withLearner, peerURL: "https://10.0.0.4:2380", expectErr: true},
+		{name: "peer URL in use", members: voters, peerURL: "https://10.0.0.2:2380", expectErr: true},
+	}
+	for _, test := range table {
+		t.Run(test.name, func(t *testing.T) {
+			first := &fakeEtcd{memberID: 1, members: test.members}
+			second := &fakeEtcd{memberID: 2, members: test.members}
+			if test.unreachable {
+				second.err = errors.New("connection refused")
+			}
+			workloadCluster := etcdClusterForTest(t, map[string]*fakeEtcd{
+				"first":  first,
+				"second": second,
+				"third":  {memberID: 3, members: test.members},
+			}, "first", "second")
+			m := managementClusterForTest(clusterKey, workloadCluster)
+			m.EtcdMembershipSampleInterval = time.Millisecond
+
+			id, err := m.AddEtcdMemberAsLearner(context.Background(), clusterKey, test.peerURL)
+			if test.expectErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				if len(first.learners) != 0 {
+					t.Fatalf("expected no learner to be added but got %v", first.learners)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if id != 100 {
+				t.Fatalf("expected the ID of the new member but got %d", id)
+			}
+			if !reflect.DeepEqual(first.learners, []string{test.peerURL}) {
+				t.Fatalf("expected a learner with peer URL %s to be added but got %v", test.peerURL, first.learners)
+			}
+		})
+	}
+}
+
+func TestEtcdHealthFailsOnceOnAuthenticationFailure(t *testing.T) {
+	clusterKey := types.NamespacedName{Namespace: "default", Name: "test-cluster"}
+	members := []*etcdserverpb.Member{{ID: 1, Name: "first"}, {ID: 2, Name: "second"}, {ID: 3, Name: "third"}}
+
+	table := []struct {
+		name string
+		err  error
+	}{
+		{name: "client certificate rejected", err: status.Error(codes.Unauthenticated, "certificate rejected")},
+		{name: "etcd permission denied", err: rpctypes.ErrPermissionDenied},
+		{name: "TLS handshake failure", err: errors.New("connection error: desc = \"transport: authentication handshake failed: remote error: tls: bad certificate\"")},
+	}
+	for _, test := range table {
+		t.Run(test.name, func(t *testing.T) {
+			workloadCluster := etcdClusterForTest(t, map[string]*fakeEtcd{
+				"first":  {err: errors.New("connection refused")},
+				"second": {memberID: 2, members: members, err: test.err},
+				"third":  {memberID: 3, members: members, err: test.err},
+			}, "first", "second", "third")
+			generator := workloadCluster.etcdClientGenerator
+			dials := 0
+			workloadCluster.etcdClientGenerator = func(nodeName string, tlsConfig *tls.Config, options ...etcd.EtcdClientOption) (*etcd.Client, error) {
+				dials++
+				return generator(nodeName, tlsConfig, options...)
+			}
+			m := managementClusterForTest(clusterKey, workloadCluster)
+
+			report, err := m.EtcdHealthReport(context.Background(), clusterKey)
+			if pkgerrors.Cause(err) != ErrEtcdClientAuthFailed {
+				t.Fatalf("expected ErrEtcdClientAuthFailed but got %v", err)
+			}
+			if report != nil {
+				t.Fatalf("expected no per node results but got %v", report)
+			}
+			if dials != 2 {
+				t.Fatalf("expected the probe to stop at the first reachable member but %d clients were created", dials)
+			}
+		})
+	}
+
+	t.Run("other failures are reported per node", func(t *testing.T) {
+		workloadCluster := etcdClusterForTest(t, map[string]*fakeEtcd{
+			"first":  {memberID: 1, members: members},
+			"second": {memberID: 2, members: members, err: errors.New("connection refused")},
+			"third":  {memberID: 3, members: members},
+		}, "first", "second", "third")
+		m := managementClusterForTest(clusterKey, workloadCluster)
+
+		report, err := m.EtcdHealthReport(context.Background(), clusterKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report["second"] == nil || report["first"] != nil || report["third"] != nil {
+			t.Fatalf("expected only second to be reported as unhealthy but got %v", report)
+		}
+	})
+}
+
+// slowEtcd is an etcd member that does not answer member list requests until the request's context is done.
+type slowEtcd struct {
+	*fakeEtcd
+}
+
+func (s *slowEtcd) MemberList(ctx context.Context) (*clientv3.MemberListResponse, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestEtcdHealthDoesNotWaitForSlowNodes(t *testing.T) {
+	members := []*etcdserverpb.Member{{ID: 1, Name: "first"}, {ID: 2, Name: "second"}, {ID: 3, Name: "third"}}
+	fakes := map[string]*fakeEtcd{
+		"first":  {memberID: 1, members: members},
+		"second": {memberID: 2, members: members},
+		"third":  {memberID: 3, members: members},
+	}
+	workloadCluster := etcdClusterForTest(t, fakes, "first", "second", "third")
+	workloadCluster.healthCheckNodeTimeout = 100 * time.Millisecond
+	generator := workloadCluster.etcdClientGenerator
+	workloadCluster.etcdClientGenerator = func(nodeName string, tlsConfig *tls.Config, options ...etcd.EtcdClientOption) (*etcd.Client, error) {
+		if nodeName == "third" {
+			return etcd.NewClientWithEtcd(&slowEtcd{fakeEtcd: fakes[nodeName]})
+		}
+		return generator(nodeName, tlsConfig, options...)
+	}
+
+	start := time.Now()
+	response, err := workloadCluster.etcdIsHealthy(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("expected the slow node to time out but the check took %s", elapsed)
+	}
+	if response["first"] != nil || response["second"] != nil {
+		t.Fatalf("expected the other nodes to be healthy but got %v", response)
+	}
+	if pkgerrors.Cause(response["third"]) != context.DeadlineExceeded {
+		t.Fatalf("expected the slow node to time out but got %v", response["third"])
+	}
+}
+
+func TestEtcdHealthSummaryCountsExpectedMembers(t *testing.T) {
+	members := []*etcdserverpb.Member{{ID: 1, Name: "first"}, {ID: 2, Name: "second"}, {ID: 4, Name: "out-of-band"}}
+	workloadCluster := etcdClusterForTest(t, map[string]*fakeEtcd{
+		"first":  {memberID: 1, members: members},
+		"second": {memberID: 2, members: members},
+	}, "first", "second")
+
+	_, summary, err := workloadCluster.etcdHealth(context.Background())
+	if err == nil {
+		t.Fatal("expected the out of band etcd member to fail the check")
+	}
+	if summary.members != 3 || summary.expectedMembers != 2 {
+		t.Fatalf("expected 3 etcd members for 2 expected but got %d for %d", summary.members, summary.expectedMembers)
+	}
+}
+
+func TestEtcdHealthExcludesNodes(t *testing.T) {
+	// The etcd member of the third node, e.g. one of a machine being replaced, has already been removed.
+	members := []*etcdserverpb.Member{{ID: 1, Name: "first"}, {ID: 2, Name: "second"}}
+	workloadCluster := etcdClusterForTest(t, map[string]*fakeEtcd{
+		"first":  {memberID: 1, members: members},
+		"second": {memberID: 2, members: members},
+		"third":  {memberID: 3, err: errors.New("connection refused")},
+	}, "first", "second", "third")
+
+	if _, _, err := workloadCluster.etcdHealth(context.Background()); err == nil {
+		t.Fatal("expected the node without an etcd member to fail the check when it is not excluded")
+	}
+
+	response, summary, err := workloadCluster.etcdHealth(context.Background(), "third")
+	if err != nil {
+		t.Fatalf("expected the excluded node to be left out but got %v", err)
+	}
+	if _, ok := response["third"]; ok {
+		t.Fatalf("expected the excluded node not to be checked but got %v", response)
+	}
+	if response["first"] != nil || response["second"] != nil {
+		t.Fatalf("expected the other nodes to be healthy but got %v", response)
+	}
+	if summary.members != 2 || summary.expectedMembers != 2 {
+		t.Fatalf("expected 2 etcd members for 2 expected but got %d for %d", summary.members, summary.expectedMembers)
+	}
+}
+
+func TestEtcdHealthExcludesMembersOfExcludedNodes(t *testing.T) {
+	// The third node is excluded while its etcd member is still listed.
+	members := []*etcdserverpb.Member{{ID: 1, Name: "first"}, {ID: 2, Name: "second"}, {ID: 3, Name: "third"}}
+	workloadCluster := etcdClusterForTest(t, map[string]*fakeEtcd{
+		"first":  {memberID: 1, members: members},
+		"second": {memberID: 2, members: members},
+		"third":  {memberID: 3, members: members},
+	}, "first", "second", "third")
+
+	_, summary, err := workloadCluster.etcdHealth(context.Background(), "third")
+	if err != nil {
+		t.Fatalf("expected the etcd member of the excluded node to be left out but got %v", err)
+	}
+	if summary.members != 2 || summary.expectedMembers != 2 {
+		t.Fatalf("expected 2 etcd members for 2 expected but got %d for %d", summary.members, summary.expectedMembers)
+	}
+}
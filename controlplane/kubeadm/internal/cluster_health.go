@@ -0,0 +1,145 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internal
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// NodeHealthStatus is the outcome of a health check on a single node.
+type NodeHealthStatus string
+
+const (
+	// NodeHealthy means the node passed the check.
+	NodeHealthy NodeHealthStatus = "Healthy"
+	// NodeUnhealthy means the node failed the check.
+	NodeUnhealthy NodeHealthStatus = "Unhealthy"
+	// NodeHealthUnknown means the check did not complete in time, so the health of the node is not known.
+	NodeHealthUnknown NodeHealthStatus = "Unknown"
+)
+
+// NodeHealth is the result of a health check on a single node.
+type NodeHealth struct {
+	Status NodeHealthStatus
+	// Err is why the node is unhealthy or its health is unknown.
+	Err error
+}
+
+// NodeHealthReport maps the checked nodes to their health.
+type NodeHealthReport map[string]NodeHealth
+
+// Count returns the number of nodes that are healthy, unhealthy and of unknown health.
+func (r NodeHealthReport) Count() (healthy, unhealthy, unknown int) {
+	for _, health := range r {
+		switch health.Status {
+		case NodeHealthy:
+			healthy++
+		case NodeUnhealthy:
+			unhealthy++
+		default:
+			unknown++
+		}
+	}
+	return healthy, unhealthy, unknown
+}
+
+// ControlPlaneHealthReportWithDeadline checks the control plane static pods of every control plane node like
+// TargetClusterControlPlaneIsHealthy, but reports nodes whose check did not complete before the deadline as
+// NodeHealthUnknown rather than unhealthy. Cached health check results are not used.
+func (m *Management) ControlPlaneHealthReportWithDeadline(ctx context.Context, clusterKey types.NamespacedName, deadline time.Time) (NodeHealthReport, error) {
+	cluster, err := m.getCluster(ctx, clusterKey)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+	return cluster.controlPlaneHealthReport(ctx)
+}
+
+// controlPlaneHealthReport checks the control plane nodes concurrently, like checkControlPlaneHealth, and reports
+// the nodes whose check is still queued or running when the context is done as NodeHealthUnknown.
+func (c *cluster) controlPlaneHealthReport(ctx context.Context) (NodeHealthReport, error) {
+	controlPlaneNodes, err := c.getControlPlaneNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	components, err := c.staticPodComponents(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	workers := c.healthCheckConcurrency
+	if workers <= 0 {
+		workers = defaultHealthCheckConcurrency
+	}
+
+	var (
+		lock sync.Mutex
+		wg   sync.WaitGroup
+	)
+	sem := make(chan struct{}, workers)
+	report := make(NodeHealthReport, len(controlPlaneNodes.Items))
+	record := func(name string, health NodeHealth) {
+		lock.Lock()
+		defer lock.Unlock()
+		report[name] = health
+	}
+	for _, node := range controlPlaneNodes.Items {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				record(name, NodeHealth{Status: NodeHealthUnknown, Err: ctx.Err()})
+				return
+			}
+			defer func() { <-sem }()
+
+			// The check may not honor the context, so stop waiting for it once the context is done.
+			done := make(chan error, 1)
+			go func() { done <- c.staticPodsAreReady(ctx, name, components) }()
+			select {
+			case err := <-done:
+				record(name, nodeHealthFromError(err))
+			case <-ctx.Done():
+				record(name, NodeHealth{Status: NodeHealthUnknown, Err: ctx.Err()})
+			}
+		}(node.Name)
+	}
+	wg.Wait()
+
+	return report, nil
+}
+
+// nodeHealthFromError converts the error of a completed node check to the node's health.
+// Checks that failed because their context ended did not determine the node's health.
+func nodeHealthFromError(err error) NodeHealth {
+	switch errors.Cause(err) {
+	case nil:
+		return NodeHealth{Status: NodeHealthy}
+	case context.DeadlineExceeded, context.Canceled:
+		return NodeHealth{Status: NodeHealthUnknown, Err: err}
+	default:
+		return NodeHealth{Status: NodeUnhealthy, Err: err}
+	}
+}
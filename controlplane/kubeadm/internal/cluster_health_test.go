@@ -0,0 +1,216 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	pkgerrors "github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// slowClient blocks Gets of the given keys until the context is done, or until release is closed if the
+// key is stuck, i.e. ignores the context.
+type slowClient struct {
+	client.Client
+
+	slow    map[string]bool
+	stuck   map[string]bool
+	release chan struct{}
+}
+
+func (c *slowClient) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	switch {
+	case c.slow[key.String()]:
+		<-ctx.Done()
+		return ctx.Err()
+	case c.stuck[key.String()]:
+		<-c.release
+	}
+	return c.Client.Get(ctx, key, obj)
+}
+
+func TestControlPlaneHealthReportWithDeadline(t *testing.T) {
+	clusterKey := types.NamespacedName{Namespace: "my-namespace", Name: "my-cluster"}
+	readyPod := &corev1.Pod{Status: corev1.PodStatus{Conditions: []corev1.PodCondition{podReady(corev1.ConditionTrue)}}}
+	notReadyPod := &corev1.Pod{Status: corev1.PodStatus{Conditions: []corev1.PodCondition{podReady(corev1.ConditionFalse)}}}
+
+	nodes := &corev1.NodeList{}
+	pods := map[string]interface{}{}
+	for name, apiServerPod := range map[string]*corev1.Pod{
+		"healthy":   readyPod,
+		"unhealthy": notReadyPod,
+		"slow":      readyPod,
+		"stuck":     readyPod,
+	} {
+		nodes.Items = append(nodes.Items, corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}})
+		pods["kube-system/kube-apiserver-"+name] = apiServerPod
+		pods["kube-system/kube-controller-manager-"+name] = readyPod
+		pods["kube-system/kube-scheduler-"+name] = readyPod
+		pods["kube-system/etcd-"+name] = readyPod
+	}
+	c := &slowClient{
+		Client:  &fakeClient{list: nodes, get: pods},
+		slow:    map[string]bool{"kube-system/kube-apiserver-slow": true},
+		stuck:   map[string]bool{"kube-system/kube-controller-manager-stuck": true},
+		release: make(chan struct{}),
+	}
+	defer close(c.release)
+	m := managementClusterForTest(clusterKey, &cluster{client: c})
+
+	report, err := m.ControlPlaneHealthReportWithDeadline(context.Background(), clusterKey, time.Now().Add(100*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]NodeHealthStatus{
+		"healthy":   NodeHealthy,
+		"unhealthy": NodeUnhealthy,
+		"slow":      NodeHealthUnknown,
+		"stuck":     NodeHealthUnknown,
+	}
+	for name, status := range expected {
+		if report[name].Status != status {
+			t.Fatalf("expected node %q to be %s but got %s (%v)", name, status, report[name].Status, report[name].Err)
+		}
+	}
+	for _, name := range []string{"slow", "stuck"} {
+		if report[name].Err != context.DeadlineExceeded {
+			t.Fatalf("expected node %q to report the deadline but got %v", name, report[name].Err)
+		}
+	}
+	if healthy, unhealthy, unknown := report.Count(); healthy != 1 || unhealthy != 1 || unknown != 2 {
+		t.Fatalf("expected 1 healthy, 1 unhealthy and 2 unknown nodes but got %d, %d and %d", healthy, unhealthy, unknown)
+	}
+}
+
+func TestRetryHealthCheck(t *testing.T) {
+	clusterKey := types.NamespacedName{Namespace: "my-namespace", Name: "my-cluster"}
+	retries := wait.Backoff{Steps: 3, Duration: time.Millisecond, Factor: 2}
+	unhealthy := errors.New("node is not ready")
+
+	tests := []struct {
+		name          string
+		backoff       wait.Backoff
+		failures      int
+		err           error
+		expectedCalls int
+		expectErr     bool
+	}{
+		{
+			name:          "failed checks are not retried by default",
+			failures:      1,
+			err:           unhealthy,
+			expectedCalls: 1,
+			expectErr:     true,
+		},
+		{
+			name:          "failed checks are retried until they pass",
+			backoff:       retries,
+			failures:      2,
+			err:           unhealthy,
+			expectedCalls: 3,
+		},
+		{
+			name:          "checks are run at most Steps times",
+			backoff:       retries,
+			failures:      5,
+			err:           unhealthy,
+			expectedCalls: 3,
+			expectErr:     true,
+		},
+		{
+			name:          "etcd authentication failures are not retried",
+			backoff:       retries,
+			failures:      1,
+			err:           kerrors.NewAggregate([]error{pkgerrors.Wrap(ErrEtcdClientAuthFailed, "node \"first\"")}),
+			expectedCalls: 1,
+			expectErr:     true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := &Management{HealthCheckRetryBackoff: test.backoff}
+			calls := 0
+			err := m.retryHealthCheck(context.Background(), clusterKey, func(context.Context) error {
+				calls++
+				if calls <= test.failures {
+					return test.err
+				}
+				return nil
+			})
+			if (err != nil) != test.expectErr {
+				t.Fatalf("expected an error to be %t but got %v", test.expectErr, err)
+			}
+			if calls != test.expectedCalls {
+				t.Fatalf("expected the check to run %d times but it ran %d times", test.expectedCalls, calls)
+			}
+		})
+	}
+}
+
+func TestRetryHealthCheckDropsCachedHealth(t *testing.T) {
+	clusterKey := types.NamespacedName{Namespace: "my-namespace", Name: "my-cluster"}
+	workloadCluster := &cluster{healthCacheTTL: time.Hour}
+	m := managementClusterForTest(clusterKey, workloadCluster)
+	m.HealthCheckRetryBackoff = wait.Backoff{Steps: 2, Duration: time.Millisecond}
+
+	calls := 0
+	err := m.retryHealthCheck(context.Background(), clusterKey, func(context.Context) error {
+		calls++
+		if calls == 1 {
+			workloadCluster.cacheControlPlaneHealth(healthCheckResult{"first": errors.New("not ready")})
+			return errors.New("node \"first\": not ready")
+		}
+		if _, ok := workloadCluster.cachedControlPlaneHealth(); ok {
+			t.Fatal("expected the cached control plane health to be dropped before retrying")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRetryHealthCheckTimeout(t *testing.T) {
+	clusterKey := types.NamespacedName{Namespace: "my-namespace", Name: "my-cluster"}
+	m := &Management{
+		HealthCheckTimeout:      50 * time.Millisecond,
+		HealthCheckRetryBackoff: wait.Backoff{Steps: 1000, Duration: time.Millisecond},
+	}
+
+	start := time.Now()
+	err := m.retryHealthCheck(context.Background(), clusterKey, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected the check to time out but got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("expected retries to stop once the timeout expired but the check took %s", elapsed)
+	}
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internal
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+)
+
+// ErrNothingToReplace is returned when every control plane machine is up to date.
+var ErrNothingToReplace = errors.New("all control plane machines are up to date")
+
+// NextControlPlaneMachineToReplace returns the oldest outdated control plane machine owned by the named control plane
+// whose removal does not cost the etcd cluster its quorum. Machines that do not run an etcd member yet, and all machines
+// of clusters with external etcd, are always safe to replace.
+// It returns ErrNothingToReplace if every control plane machine matches the given configuration hash.
+func (m *Management) NextControlPlaneMachineToReplace(ctx context.Context, clusterKey types.NamespacedName, controlPlaneName, configHash string) (*clusterv1.Machine, error) {
+	outdated, err := m.GetMachinesForCluster(ctx, clusterKey, OutdatedControlPlaneMachines(controlPlaneName, configHash))
+	if err != nil {
+		return nil, err
+	}
+	if len(outdated) == 0 {
+		return nil, ErrNothingToReplace
+	}
+	sortMachinesOldestFirst(outdated)
+
+	cluster, err := m.getCluster(ctx, clusterKey)
+	if err != nil {
+		return nil, err
+	}
+	clusterConfiguration, err := cluster.getClusterConfiguration(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if clusterConfiguration.Etcd.External != nil {
+		return outdated[0], nil
+	}
+
+	members, healthy, err := cluster.etcdMembersHealth(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, machine := range outdated {
+		if machine.Status.NodeRef == nil || canSafelyRemoveEtcdMember(members, healthy, machine.Status.NodeRef.Name) {
+			return machine, nil
+		}
+	}
+	return nil, errors.Errorf("none of the %d outdated control plane machines can be replaced without losing etcd quorum", len(outdated))
+}
+
+// GetHealthyControlPlaneMachines returns the control plane machines owned by the named control plane whose node runs
+// ready control plane static pods and, unless the cluster uses external etcd, a healthy etcd member.
+// Machines that are being deleted or have no node yet are not healthy.
+func (m *Management) GetHealthyControlPlaneMachines(ctx context.Context, clusterKey types.NamespacedName, controlPlaneName string) ([]*clusterv1.Machine, error) {
+	machines, err := m.GetMachinesForCluster(ctx, clusterKey, OwnedControlPlaneMachines(controlPlaneName))
+	if err != nil {
+		return nil, err
+	}
+
+	cluster, err := m.getCluster(ctx, clusterKey)
+	if err != nil {
+		return nil, err
+	}
+	controlPlaneHealth, err := cluster.controlPlaneIsHealthy(ctx)
+	if err != nil {
+		return nil, err
+	}
+	clusterConfiguration, err := cluster.getClusterConfiguration(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var etcdHealth healthCheckResult
+	if clusterConfiguration.Etcd.External == nil {
+		// Members that were checked are still reported when the etcd cluster as a whole is found unhealthy.
+		etcdHealth, _, err = cluster.etcdHealth(ctx)
+		if etcdHealth == nil {
+			return nil, err
+		}
+	}
+
+	healthy := []*clusterv1.Machine{}
+	for _, machine := range machines {
+		if !machine.DeletionTimestamp.IsZero() || machine.Status.NodeRef == nil {
+			continue
+		}
+		nodeName := machine.Status.NodeRef.Name
+		if err, ok := controlPlaneHealth[nodeName]; !ok || err != nil {
+			continue
+		}
+		if etcdHealth != nil {
+			if err, ok := etcdHealth[nodeName]; !ok || err != nil {
+				continue
+			}
+		}
+		healthy = append(healthy, machine)
+	}
+	return healthy, nil
+}
+
+// ControlPlaneMeetsMinimumHA reports whether the named control plane still has at least minSize healthy machines
+// after removing one of them, i.e. whether scaling it down is allowed. minSize is typically 3 for highly available
+// control planes with stacked etcd, and 1 for control planes that only need to stay available.
+func (m *Management) ControlPlaneMeetsMinimumHA(ctx context.Context, clusterKey types.NamespacedName, controlPlaneName string, minSize int) (bool, error) {
+	healthy, err := m.GetHealthyControlPlaneMachines(ctx, clusterKey, controlPlaneName)
+	if err != nil {
+		return false, err
+	}
+	return len(healthy)-1 >= minSize, nil
+}
+
+// sortMachinesOldestFirst sorts machines by creation time, breaking ties by name.
+func sortMachinesOldestFirst(machines []*clusterv1.Machine) {
+	sort.SliceStable(machines, func(i, j int) bool {
+		if machines[i].CreationTimestamp.Equal(&machines[j].CreationTimestamp) {
+			return machines[i].Name < machines[j].Name
+		}
+		return machines[i].CreationTimestamp.Before(&machines[j].CreationTimestamp)
+	})
+}
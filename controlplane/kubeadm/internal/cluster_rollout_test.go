@@ -0,0 +1,227 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.etcd.io/etcd/etcdserver/etcdserverpb"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	controlplanev1 "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
+)
+
+func TestNextControlPlaneMachineToReplace(t *testing.T) {
+	clusterKey := types.NamespacedName{Namespace: "my-namespace", Name: "my-cluster"}
+	controlPlaneMachine := func(name, hash string, created int64) clusterv1.Machine {
+		machine := machineListForTestGetMachinesForCluster().Items[0]
+		machine.Name = name
+		machine.Labels[controlplanev1.KubeadmControlPlaneHashLabelKey] = hash
+		machine.CreationTimestamp = metav1.NewTime(time.Unix(created, 0))
+		machine.Status.NodeRef = &corev1.ObjectReference{Kind: "Node", Name: name}
+		return machine
+	}
+	stackedEtcd := &corev1.ConfigMap{Data: map[string]string{clusterConfigurationKey: "etcd:\n  local: {}\n"}}
+	externalEtcd := &corev1.ConfigMap{Data: map[string]string{clusterConfigurationKey: "etcd:\n  external:\n    endpoints: []\n"}}
+	etcdMembers := []*etcdserverpb.Member{
+		{ID: 1, Name: "first"},
+		{ID: 2, Name: "second"},
+		{ID: 3, Name: "third"},
+	}
+
+	table := []struct {
+		name          string
+		machines      []clusterv1.Machine
+		kubeadmConfig *corev1.ConfigMap
+		unreachable   []string
+		expected      string
+		expectedErr   error
+		expectAnyErr  bool
+	}{
+		{
+			name: "oldest outdated machine",
+			machines: []clusterv1.Machine{
+				controlPlaneMachine("third", "old", 3),
+				controlPlaneMachine("second", "old", 2),
+				controlPlaneMachine("first", "new", 1),
+			},
+			kubeadmConfig: stackedEtcd,
+			expected:      "second",
+		},
+		{
+			name: "skips machines whose removal would lose quorum",
+			machines: []clusterv1.Machine{
+				controlPlaneMachine("first", "old", 1),
+				controlPlaneMachine("second", "old", 2),
+				controlPlaneMachine("third", "new", 3),
+			},
+			kubeadmConfig: stackedEtcd,
+			unreachable:   []string{"second"},
+			expected:      "second",
+		},
+		{
+			name: "no machine can be safely removed",
+			machines: []clusterv1.Machine{
+				controlPlaneMachine("first", "old", 1),
+				controlPlaneMachine("second", "new", 2),
+				controlPlaneMachine("third", "new", 3),
+			},
+			kubeadmConfig: stackedEtcd,
+			unreachable:   []string{"second"},
+			expectAnyErr:  true,
+		},
+		{
+			name: "external etcd",
+			machines: []clusterv1.Machine{
+				controlPlaneMachine("first", "old", 1),
+				controlPlaneMachine("second", "old", 2),
+				controlPlaneMachine("third", "new", 3),
+			},
+			kubeadmConfig: externalEtcd,
+			unreachable:   []string{"first", "second", "third"},
+			expected:      "first",
+		},
+		{
+			name: "all machines up to date",
+			machines: []clusterv1.Machine{
+				controlPlaneMachine("first", "new", 1),
+				controlPlaneMachine("second", "new", 2),
+				controlPlaneMachine("third", "new", 3),
+			},
+			kubeadmConfig: stackedEtcd,
+			expectedErr:   ErrNothingToReplace,
+			expectAnyErr:  true,
+		},
+	}
+	for _, test := range table {
+		t.Run(test.name, func(t *testing.T) {
+			members := map[string]*fakeEtcd{}
+			for _, member := range etcdMembers {
+				members[member.Name] = &fakeEtcd{memberID: member.ID, members: etcdMembers}
+			}
+			for _, name := range test.unreachable {
+				members[name].err = errors.New("connection refused")
+			}
+			workloadCluster := etcdClusterForTest(t, members, "first", "second", "third")
+			workloadCluster.client.(*fakeClient).get = map[string]interface{}{"kube-system/kubeadm-config": test.kubeadmConfig}
+			m := managementClusterForTest(clusterKey, workloadCluster)
+			m.Client.(*fakeClient).list = &clusterv1.MachineList{Items: test.machines}
+
+			machine, err := m.NextControlPlaneMachineToReplace(context.Background(), clusterKey, "my-control-plane", "new")
+			if test.expectAnyErr != (err != nil) {
+				t.Fatalf("expected error to be %t but got %v", test.expectAnyErr, err)
+			}
+			if test.expectedErr != nil && err != test.expectedErr {
+				t.Fatalf("expected error %v but got %v", test.expectedErr, err)
+			}
+			if test.expectAnyErr {
+				return
+			}
+			if machine.Name != test.expected {
+				t.Fatalf("expected machine %q but got %q", test.expected, machine.Name)
+			}
+		})
+	}
+}
+
+func TestControlPlaneMeetsMinimumHA(t *testing.T) {
+	clusterKey := types.NamespacedName{Namespace: "my-namespace", Name: "my-cluster"}
+	controlPlaneMachine := func(name string) clusterv1.Machine {
+		machine := machineListForTestGetMachinesForCluster().Items[0]
+		machine.Name = name
+		machine.Status.NodeRef = &corev1.ObjectReference{Kind: "Node", Name: name}
+		return machine
+	}
+	readyPod := &corev1.Pod{Status: corev1.PodStatus{Conditions: []corev1.PodCondition{podReady(corev1.ConditionTrue)}}}
+	stackedEtcd := &corev1.ConfigMap{Data: map[string]string{clusterConfigurationKey: "etcd:\n  local: {}\n"}}
+	externalEtcd := &corev1.ConfigMap{Data: map[string]string{clusterConfigurationKey: "etcd:\n  external:\n    endpoints: []\n"}}
+	etcdMembers := []*etcdserverpb.Member{
+		{ID: 1, Name: "first"},
+		{ID: 2, Name: "second"},
+		{ID: 3, Name: "third"},
+	}
+
+	table := []struct {
+		name            string
+		kubeadmConfig   *corev1.ConfigMap
+		unreachableEtcd []string
+		withoutNodeRef  []string
+		minSize         int
+		expected        bool
+		expectedHealthy int
+	}{
+		{name: "healthy control plane above the minimum", kubeadmConfig: stackedEtcd, minSize: 2, expected: true, expectedHealthy: 3},
+		{name: "healthy control plane at the minimum", kubeadmConfig: stackedEtcd, minSize: 3, expected: false, expectedHealthy: 3},
+		{name: "unhealthy etcd member", kubeadmConfig: stackedEtcd, unreachableEtcd: []string{"third"}, minSize: 2, expected: false, expectedHealthy: 2},
+		{name: "unhealthy etcd member with a lower minimum", kubeadmConfig: stackedEtcd, unreachableEtcd: []string{"third"}, minSize: 1, expected: true, expectedHealthy: 2},
+		{name: "machine without a node", kubeadmConfig: stackedEtcd, withoutNodeRef: []string{"third"}, minSize: 2, expected: false, expectedHealthy: 2},
+		{name: "external etcd", kubeadmConfig: externalEtcd, unreachableEtcd: []string{"first", "second", "third"}, minSize: 2, expected: true, expectedHealthy: 3},
+	}
+	for _, test := range table {
+		t.Run(test.name, func(t *testing.T) {
+			members := map[string]*fakeEtcd{}
+			for _, member := range etcdMembers {
+				members[member.Name] = &fakeEtcd{memberID: member.ID, members: etcdMembers}
+			}
+			for _, name := range test.unreachableEtcd {
+				members[name].err = errors.New("connection refused")
+			}
+			workloadCluster := etcdClusterForTest(t, members, "first", "second", "third")
+			objects := map[string]interface{}{"kube-system/kubeadm-config": test.kubeadmConfig}
+			for _, member := range etcdMembers {
+				objects["kube-system/kube-apiserver-"+member.Name] = readyPod
+				objects["kube-system/kube-controller-manager-"+member.Name] = readyPod
+				objects["kube-system/kube-scheduler-"+member.Name] = readyPod
+				objects["kube-system/etcd-"+member.Name] = readyPod
+			}
+			workloadCluster.client.(*fakeClient).get = objects
+
+			machines := []clusterv1.Machine{}
+			for _, member := range etcdMembers {
+				machine := controlPlaneMachine(member.Name)
+				for _, name := range test.withoutNodeRef {
+					if name == member.Name {
+						machine.Status.NodeRef = nil
+					}
+				}
+				machines = append(machines, machine)
+			}
+			m := managementClusterForTest(clusterKey, workloadCluster)
+			m.Client.(*fakeClient).list = &clusterv1.MachineList{Items: machines}
+
+			healthy, err := m.GetHealthyControlPlaneMachines(context.Background(), clusterKey, "my-control-plane")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(healthy) != test.expectedHealthy {
+				t.Fatalf("expected %d healthy machines but got %d", test.expectedHealthy, len(healthy))
+			}
+			meetsMinimum, err := m.ControlPlaneMeetsMinimumHA(context.Background(), clusterKey, "my-control-plane", test.minSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if meetsMinimum != test.expected {
+				t.Fatalf("expected %t but got %t", test.expected, meetsMinimum)
+			}
+		})
+	}
+}
@@ -19,14 +19,31 @@ package internal
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	goruntime "runtime"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/controllers/remote"
+	controlplanev1 "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util/secret"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
 func podReady(isReady corev1.ConditionStatus) corev1.PodCondition {
@@ -109,6 +126,12 @@ func TestControlPlaneIsHealthy(t *testing.T) {
 				"kube-system/kube-controller-manager-first-control-plane":  &corev1.Pod{Status: readyStatus},
 				"kube-system/kube-controller-manager-second-control-plane": &corev1.Pod{Status: readyStatus},
 				"kube-system/kube-controller-manager-third-control-plane":  &corev1.Pod{Status: readyStatus},
+				"kube-system/kube-scheduler-first-control-plane":           &corev1.Pod{Status: readyStatus},
+				"kube-system/kube-scheduler-second-control-plane":          &corev1.Pod{Status: readyStatus},
+				"kube-system/kube-scheduler-third-control-plane":           &corev1.Pod{Status: readyStatus},
+				"kube-system/etcd-first-control-plane":                     &corev1.Pod{Status: readyStatus},
+				"kube-system/etcd-second-control-plane":                    &corev1.Pod{Status: readyStatus},
+				"kube-system/etcd-third-control-plane":                     &corev1.Pod{Status: readyStatus},
 			},
 		},
 	}
@@ -125,6 +148,356 @@ func TestControlPlaneIsHealthy(t *testing.T) {
 	}
 }
 
+func TestControlPlaneImageVersions(t *testing.T) {
+	staticPod := func(component, version string) *corev1.Pod {
+		return &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{
+			{Name: component, Image: "k8s.gcr.io/" + component + ":" + version},
+		}}}
+	}
+	pods := map[string]interface{}{}
+	for _, node := range nodeListForTestControlPlaneIsHealthy().Items {
+		for _, component := range controlPlaneComponents {
+			pods["kube-system/"+staticPodName(component, node.Name)] = staticPod(component, "v1.17.3")
+		}
+	}
+	pods["kube-system/kube-apiserver-second-control-plane"] = staticPod("kube-apiserver", "v1.16.7")
+	workloadCluster := &cluster{
+		client: &fakeClient{
+			list: nodeListForTestControlPlaneIsHealthy(),
+			get:  pods,
+		},
+	}
+
+	versions, err := workloadCluster.controlPlaneImageVersions(context.Background(), "v1.17.3")
+	if err == nil {
+		t.Fatal("expected an error for the lagging kube-apiserver")
+	}
+	if !strings.Contains(err.Error(), "kube-apiserver on node \"second-control-plane\"") {
+		t.Fatalf("expected the error to name the lagging kube-apiserver but got %v", err)
+	}
+	if len(versions) != len(nodeListForTestControlPlaneIsHealthy().Items) {
+		t.Fatalf("expected versions for every node but got %v", versions)
+	}
+	if versions["second-control-plane"]["kube-apiserver"] != "v1.16.7" {
+		t.Fatalf("expected the lagging kube-apiserver to run v1.16.7 but got %q", versions["second-control-plane"]["kube-apiserver"])
+	}
+	if versions["second-control-plane"]["kube-controller-manager"] != "v1.17.3" {
+		t.Fatalf("expected kube-controller-manager to run v1.17.3 but got %q", versions["second-control-plane"]["kube-controller-manager"])
+	}
+
+	pods["kube-system/kube-apiserver-second-control-plane"] = staticPod("kube-apiserver", "v1.17.3")
+	if _, err := workloadCluster.controlPlaneImageVersions(context.Background(), "1.17.3"); err != nil {
+		t.Fatalf("expected every component to match but got %v", err)
+	}
+}
+
+func TestKubeletVersionSkew(t *testing.T) {
+	apiServerPod := func(version string) *corev1.Pod {
+		return &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{
+			{Name: "kube-apiserver", Image: "k8s.gcr.io/kube-apiserver:" + version},
+		}}}
+	}
+	node := func(name, kubeletVersion string) corev1.Node {
+		return corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status:     corev1.NodeStatus{NodeInfo: corev1.NodeSystemInfo{KubeletVersion: kubeletVersion}},
+		}
+	}
+	workloadCluster := &cluster{
+		client: &fakeClient{
+			list: &corev1.NodeList{Items: []corev1.Node{
+				node("upgraded-control-plane", "v1.17.3"),
+				node("control-plane", "v1.16.7"),
+				node("same-minor", "v1.16.2"),
+				node("one-minor-older", "v1.15.11"),
+				node("two-minors-older", "v1.14.10"),
+				node("three-minors-older", "v1.13.12"),
+				node("not-registered-yet", ""),
+				node("unparseable", "latest"),
+			}},
+			get: map[string]interface{}{
+				"kube-system/kube-apiserver-upgraded-control-plane": apiServerPod("v1.17.3"),
+				"kube-system/kube-apiserver-control-plane":          apiServerPod("v1.16.7"),
+			},
+		},
+	}
+
+	skewed, err := workloadCluster.kubeletVersionSkew(context.Background(), 2)
+	if err == nil || !strings.Contains(err.Error(), `node "unparseable"`) {
+		t.Fatalf("expected an error for the unparseable kubelet version but got %v", err)
+	}
+	if strings.Contains(err.Error(), "not-registered-yet") {
+		t.Fatalf("expected the node without a kubelet version to be left out but got %v", err)
+	}
+	expected := map[string]string{
+		"upgraded-control-plane": "v1.17.3",
+		"three-minors-older":     "v1.13.12",
+	}
+	if !reflect.DeepEqual(skewed, expected) {
+		t.Fatalf("expected %v but got %v", expected, skewed)
+	}
+
+	workloadCluster.client.(*fakeClient).get = map[string]interface{}{}
+	if _, err := workloadCluster.kubeletVersionSkew(context.Background(), 2); err == nil {
+		t.Fatal("expected an error without any kube-apiserver version")
+	}
+}
+
+func TestControlPlaneIsHealthyBoundsConcurrency(t *testing.T) {
+	readyStatus := corev1.PodStatus{
+		Conditions: []corev1.PodCondition{podReady(corev1.ConditionTrue)},
+	}
+	nodes := &corev1.NodeList{}
+	pods := map[string]interface{}{}
+	for i := 0; i < 20; i++ {
+		name := fmt.Sprintf("control-plane-%d", i)
+		nodes.Items = append(nodes.Items, corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}})
+		pods["kube-system/kube-apiserver-"+name] = &corev1.Pod{Status: readyStatus}
+		pods["kube-system/kube-controller-manager-"+name] = &corev1.Pod{Status: readyStatus}
+		pods["kube-system/kube-scheduler-"+name] = &corev1.Pod{Status: readyStatus}
+		pods["kube-system/etcd-"+name] = &corev1.Pod{Status: readyStatus}
+	}
+	trackingClient := &concurrencyTrackingClient{Client: &fakeClient{list: nodes, get: pods}}
+	workloadCluster := &cluster{client: trackingClient, healthCheckConcurrency: 4}
+
+	health, err := workloadCluster.controlPlaneIsHealthy(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(health) != len(nodes.Items) {
+		t.Fatalf("expected %d nodes to be checked but got %d", len(nodes.Items), len(health))
+	}
+	for name, err := range health {
+		if err != nil {
+			t.Fatalf("expected node %q to be healthy but got %v", name, err)
+		}
+	}
+	if trackingClient.maxInFlight > 4 {
+		t.Fatalf("expected at most 4 concurrent Gets but got %d", trackingClient.maxInFlight)
+	}
+	if trackingClient.maxInFlight < 2 {
+		t.Fatalf("expected nodes to be checked concurrently but got at most %d concurrent Gets", trackingClient.maxInFlight)
+	}
+}
+
+func TestControlPlaneIsHealthyReportsNotReadyAPIServer(t *testing.T) {
+	workloadCluster := &cluster{
+		client: &fakeClient{
+			list: &corev1.NodeList{Items: []corev1.Node{{ObjectMeta: metav1.ObjectMeta{Name: "first-control-plane"}}}},
+			get: map[string]interface{}{
+				"kube-system/kube-apiserver-first-control-plane": &corev1.Pod{Status: corev1.PodStatus{
+					Conditions: []corev1.PodCondition{podReady(corev1.ConditionFalse)},
+				}},
+				"kube-system/kube-controller-manager-first-control-plane": &corev1.Pod{Status: corev1.PodStatus{
+					Conditions: []corev1.PodCondition{podReady(corev1.ConditionTrue)},
+				}},
+			},
+		},
+	}
+
+	health, err := workloadCluster.controlPlaneIsHealthy(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if health["first-control-plane"] == nil {
+		t.Fatal("expected the not ready kube-apiserver to be reported")
+	}
+}
+
+func TestControlPlaneIsHealthyReportsEveryComponent(t *testing.T) {
+	readyPod := &corev1.Pod{Status: corev1.PodStatus{Conditions: []corev1.PodCondition{podReady(corev1.ConditionTrue)}}}
+	workloadCluster := &cluster{
+		client: &fakeClient{
+			list: &corev1.NodeList{Items: []corev1.Node{{ObjectMeta: metav1.ObjectMeta{Name: "first-control-plane"}}}},
+			get: map[string]interface{}{
+				"kube-system/kube-apiserver-first-control-plane":          readyPod,
+				"kube-system/kube-controller-manager-first-control-plane": readyPod,
+				"kube-system/kube-scheduler-first-control-plane": &corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{Namespace: metav1.NamespaceSystem, Name: "kube-scheduler-first-control-plane"},
+					Status:     corev1.PodStatus{Conditions: []corev1.PodCondition{podReady(corev1.ConditionFalse)}},
+				},
+			},
+		},
+	}
+
+	health, err := workloadCluster.controlPlaneIsHealthy(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodeErr := health["first-control-plane"]
+	if nodeErr == nil {
+		t.Fatal("expected the node to be unhealthy")
+	}
+	// Both the not ready kube-scheduler and the missing etcd pod are reported.
+	if !strings.Contains(nodeErr.Error(), "kube-scheduler-first-control-plane") || !strings.Contains(nodeErr.Error(), "etcd-first-control-plane") {
+		t.Fatalf("expected the kube-scheduler and etcd pods to be reported but got %v", nodeErr)
+	}
+}
+
+func TestControlPlaneIsHealthySkipsExternalEtcd(t *testing.T) {
+	readyPod := &corev1.Pod{Status: corev1.PodStatus{Conditions: []corev1.PodCondition{podReady(corev1.ConditionTrue)}}}
+	workloadCluster := &cluster{
+		client: &fakeClient{
+			list: &corev1.NodeList{Items: []corev1.Node{{ObjectMeta: metav1.ObjectMeta{Name: "first-control-plane"}}}},
+			get: map[string]interface{}{
+				"kube-system/kubeadm-config": &corev1.ConfigMap{Data: map[string]string{
+					clusterConfigurationKey: "etcd:\n  external:\n    endpoints: []\n",
+				}},
+				"kube-system/kube-apiserver-first-control-plane":          readyPod,
+				"kube-system/kube-controller-manager-first-control-plane": readyPod,
+				"kube-system/kube-scheduler-first-control-plane":          readyPod,
+			},
+		},
+	}
+
+	health, err := workloadCluster.controlPlaneIsHealthy(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := health["first-control-plane"]; err != nil {
+		t.Fatalf("expected the node to be healthy without an etcd pod but got %v", err)
+	}
+}
+
+func TestControlPlaneHealthCheckDiscoversNodesFromMachines(t *testing.T) {
+	clusterKey := types.NamespacedName{Namespace: "my-namespace", Name: "my-cluster"}
+	readyPod := &corev1.Pod{Status: corev1.PodStatus{Conditions: []corev1.PodCondition{podReady(corev1.ConditionTrue)}}}
+	machines := machineListForTestGetMachinesForCluster()
+	machines.Items[0].Status.NodeRef = &corev1.ObjectReference{Kind: "Node", Name: "unlabeled-control-plane"}
+	machines.Items[1].Status.NodeRef = &corev1.ObjectReference{Kind: "Node", Name: "worker"}
+
+	for _, discover := range []bool{false, true} {
+		t.Run(fmt.Sprintf("discover nodes from machines %t", discover), func(t *testing.T) {
+			workloadCluster := &cluster{client: &fakeClient{
+				list: &corev1.NodeList{},
+				get: map[string]interface{}{
+					"/unlabeled-control-plane":                                    &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "unlabeled-control-plane"}},
+					"kube-system/kube-apiserver-unlabeled-control-plane":          readyPod,
+					"kube-system/kube-controller-manager-unlabeled-control-plane": readyPod,
+					"kube-system/kube-scheduler-unlabeled-control-plane":          readyPod,
+					"kube-system/etcd-unlabeled-control-plane":                    readyPod,
+				},
+			}}
+			m := managementClusterForTest(clusterKey, workloadCluster)
+			m.Client.(*fakeClient).list = machines
+			m.DiscoverNodesFromMachines = discover
+			// The cluster is cached up front, so wire the fallback the way getCluster does.
+			if discover {
+				workloadCluster.machineNodeNames = m.controlPlaneMachineNodeNames(clusterKey)
+			}
+
+			err := m.TargetClusterControlPlaneIsHealthy(context.Background(), clusterKey, "my-control-plane")
+			if discover && err != nil {
+				t.Fatalf("expected the node referenced by the control plane machine to be checked but got %v", err)
+			}
+			if !discover && err == nil {
+				t.Fatal("expected the health check to fail without any labeled control plane node")
+			}
+		})
+	}
+}
+
+func TestHealthCheckCapsNodeErrors(t *testing.T) {
+	clusterKey := types.NamespacedName{Namespace: "my-namespace", Name: "my-cluster"}
+	failingNodes := healthCheckResult{}
+	for i := 0; i < 20; i++ {
+		failingNodes[fmt.Sprintf("node-%02d", i)] = errors.New("static pod is not ready")
+	}
+	check := func(context.Context) (healthCheckResult, error) {
+		return failingNodes, nil
+	}
+
+	t.Run("caps the aggregated node errors", func(t *testing.T) {
+		m := &Management{MaxAggregatedNodeErrors: 5}
+		err := m.healthCheck(context.Background(), check, clusterKey, "my-control-plane", nil)
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+		if !strings.Contains(err.Error(), "and 15 more") {
+			t.Fatalf("expected the remaining 15 node errors to be summarized but got %q", err.Error())
+		}
+		if count := strings.Count(err.Error(), "static pod is not ready"); count != 5 {
+			t.Fatalf("expected 5 node errors but got %d in %q", count, err.Error())
+		}
+		for i := 0; i < 5; i++ {
+			if !strings.Contains(err.Error(), fmt.Sprintf("node-%02d", i)) {
+				t.Fatalf("expected the first nodes by name to be reported but got %q", err.Error())
+			}
+		}
+	})
+
+	t.Run("includes all node errors by default", func(t *testing.T) {
+		m := &Management{}
+		err := m.healthCheck(context.Background(), check, clusterKey, "my-control-plane", nil)
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+		if count := strings.Count(err.Error(), "static pod is not ready"); count != 20 {
+			t.Fatalf("expected 20 node errors but got %d", count)
+		}
+		if strings.Contains(err.Error(), "more") {
+			t.Fatalf("expected no summary but got %q", err.Error())
+		}
+	})
+}
+
+func TestHealthCheckExcludesMachines(t *testing.T) {
+	clusterKey := types.NamespacedName{Namespace: "my-namespace", Name: "my-cluster"}
+	controlPlaneMachine := func(name string) clusterv1.Machine {
+		machine := machineListForTestGetMachinesForCluster().Items[0]
+		machine.Name = name
+		machine.Status.NodeRef = &corev1.ObjectReference{Kind: "Node", Name: name + "-node"}
+		return machine
+	}
+	machines := &clusterv1.MachineList{Items: []clusterv1.Machine{
+		controlPlaneMachine("first"),
+		controlPlaneMachine("second"),
+		controlPlaneMachine("third"),
+	}}
+	replaced := types.NamespacedName{Namespace: "my-namespace", Name: "third"}
+
+	table := []struct {
+		name        string
+		nodeChecks  healthCheckResult
+		exclude     []types.NamespacedName
+		expectedErr bool
+	}{
+		{
+			name:        "node of the replaced machine is gone",
+			nodeChecks:  healthCheckResult{"first-node": nil, "second-node": nil},
+			expectedErr: true,
+		},
+		{
+			name:       "node of the excluded machine is gone",
+			nodeChecks: healthCheckResult{"first-node": nil, "second-node": nil},
+			exclude:    []types.NamespacedName{replaced},
+		},
+		{
+			name:       "node of the excluded machine is unhealthy",
+			nodeChecks: healthCheckResult{"first-node": nil, "second-node": nil, "third-node": errors.New("etcd member is unreachable")},
+			exclude:    []types.NamespacedName{replaced},
+		},
+		{
+			name:        "other machines are still checked",
+			nodeChecks:  healthCheckResult{"first-node": nil, "third-node": nil},
+			exclude:     []types.NamespacedName{replaced},
+			expectedErr: true,
+		},
+	}
+	for _, test := range table {
+		t.Run(test.name, func(t *testing.T) {
+			m := &Management{Client: &fakeClient{list: machines}}
+			check := func(context.Context) (healthCheckResult, error) {
+				return test.nodeChecks, nil
+			}
+			err := m.healthCheck(context.Background(), check, clusterKey, "my-control-plane", test.exclude)
+			if test.expectedErr != (err != nil) {
+				t.Fatalf("expected error to be %t but got %v", test.expectedErr, err)
+			}
+		})
+	}
+}
+
 func nodeListForTestControlPlaneIsHealthy() *corev1.NodeList {
 	nodeNamed := func(name string) corev1.Node {
 		return corev1.Node{
@@ -143,7 +516,7 @@ func nodeListForTestControlPlaneIsHealthy() *corev1.NodeList {
 }
 
 func TestGetMachinesForCluster(t *testing.T) {
-	m := ManagementCluster{Client: &fakeClient{
+	m := Management{Client: &fakeClient{
 		list: machineListForTestGetMachinesForCluster(),
 	}}
 	clusterKey := types.NamespacedName{
@@ -180,6 +553,82 @@ func TestGetMachinesForCluster(t *testing.T) {
 	}
 }
 
+func TestGetMachinesForClusterListErrors(t *testing.T) {
+	clusterKey := types.NamespacedName{
+		Namespace: "my-namespace",
+		Name:      "my-cluster",
+	}
+	machinesResource := schema.GroupResource{Group: clusterv1.GroupVersion.Group, Resource: "machines"}
+
+	table := []struct {
+		name          string
+		listErr       error
+		expectRBACMsg bool
+	}{
+		{name: "forbidden", listErr: apierrors.NewForbidden(machinesResource, "", errors.New("denied")), expectRBACMsg: true},
+		{name: "unauthorized", listErr: apierrors.NewUnauthorized("invalid token"), expectRBACMsg: true},
+		{name: "connection refused", listErr: errors.New("connection refused"), expectRBACMsg: false},
+		{name: "server timeout", listErr: apierrors.NewServerTimeout(machinesResource, "list", 1), expectRBACMsg: false},
+	}
+	for _, test := range table {
+		t.Run(test.name, func(t *testing.T) {
+			m := Management{Client: &fakeClient{listErr: test.listErr}}
+			_, err := m.GetMachinesForCluster(context.Background(), clusterKey)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if errors.Cause(err) != test.listErr {
+				t.Fatalf("expected the list error to be the cause but got %v", errors.Cause(err))
+			}
+			hasRBACMsg := strings.Contains(err.Error(), "RBAC permissions to list machines")
+			if hasRBACMsg != test.expectRBACMsg {
+				t.Fatalf("expected RBAC message %t but got error %q", test.expectRBACMsg, err.Error())
+			}
+		})
+	}
+}
+
+func TestGetOldestMachines(t *testing.T) {
+	clusterKey := types.NamespacedName{
+		Namespace: "my-namespace",
+		Name:      "my-cluster",
+	}
+	machines := machineListForTestGetMachinesForCluster()
+	for i, created := range []int64{30, 10, 20} {
+		machines.Items[i].CreationTimestamp = metav1.NewTime(time.Unix(created, 0))
+	}
+	m := Management{Client: &fakeClient{list: machines}}
+
+	table := []struct {
+		name     string
+		n        int
+		filters  []func(*clusterv1.Machine) bool
+		expected []string
+	}{
+		{name: "oldest machine", n: 1, expected: []string{"second-machine"}},
+		{name: "oldest two machines", n: 2, expected: []string{"second-machine", "third-machine"}},
+		{name: "more than available", n: 5, expected: []string{"second-machine", "third-machine", "first-machine"}},
+		{name: "zero", n: 0, expected: []string{}},
+		{name: "negative", n: -1, expected: []string{}},
+		{name: "filtered", n: 2, filters: []func(*clusterv1.Machine) bool{OwnedControlPlaneMachines("my-control-plane")}, expected: []string{"first-machine"}},
+	}
+	for _, test := range table {
+		t.Run(test.name, func(t *testing.T) {
+			oldest, err := m.GetOldestMachines(context.Background(), clusterKey, test.n, test.filters...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			names := []string{}
+			for _, machine := range oldest {
+				names = append(names, machine.Name)
+			}
+			if !reflect.DeepEqual(names, test.expected) {
+				t.Fatalf("expected %v but got %v", test.expected, names)
+			}
+		})
+	}
+}
+
 func machineListForTestGetMachinesForCluster() *clusterv1.MachineList {
 	owned := true
 	ownedRef := []metav1.OwnerReference{
@@ -216,8 +665,10 @@ func machineListForTestGetMachinesForCluster() *clusterv1.MachineList {
 
 type fakeClient struct {
 	client.Client
-	list interface{}
-	get  map[string]interface{}
+	list    interface{}
+	listErr error
+	get     map[string]interface{}
+	updated []runtime.Object
 }
 
 func (f *fakeClient) Get(_ context.Context, key client.ObjectKey, obj runtime.Object) error {
@@ -225,13 +676,33 @@ func (f *fakeClient) Get(_ context.Context, key client.ObjectKey, obj runtime.Ob
 	switch l := item.(type) {
 	case *corev1.Pod:
 		l.DeepCopyInto(obj.(*corev1.Pod))
+	case *corev1.Secret:
+		l.DeepCopyInto(obj.(*corev1.Secret))
+	case *corev1.ConfigMap:
+		l.DeepCopyInto(obj.(*corev1.ConfigMap))
+	case *corev1.Node:
+		l.DeepCopyInto(obj.(*corev1.Node))
+	case *appsv1.DaemonSet:
+		l.DeepCopyInto(obj.(*appsv1.DaemonSet))
+	case *controlplanev1.KubeadmControlPlane:
+		l.DeepCopyInto(obj.(*controlplanev1.KubeadmControlPlane))
+	case nil:
+		return apierrors.NewNotFound(schema.GroupResource{}, key.Name)
 	default:
 		return fmt.Errorf("unknown type: %s", l)
 	}
 	return nil
 }
 
+func (f *fakeClient) Update(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+	f.updated = append(f.updated, obj.DeepCopyObject())
+	return nil
+}
+
 func (f *fakeClient) List(_ context.Context, list runtime.Object, _ ...client.ListOption) error {
+	if f.listErr != nil {
+		return f.listErr
+	}
 	switch l := f.list.(type) {
 	case *clusterv1.MachineList:
 		l.DeepCopyInto(list.(*clusterv1.MachineList))
@@ -242,3 +713,644 @@ func (f *fakeClient) List(_ context.Context, list runtime.Object, _ ...client.Li
 	}
 	return nil
 }
+
+func TestMatchesKubernetesVersion(t *testing.T) {
+	machineWithVersion := func(v *string) *clusterv1.Machine {
+		return &clusterv1.Machine{Spec: clusterv1.MachineSpec{Version: v}}
+	}
+	version := func(v string) *string { return &v }
+
+	table := []struct {
+		name     string
+		machine  *clusterv1.Machine
+		expected bool
+	}{
+		{name: "nil machine", machine: nil, expected: false},
+		{name: "no version", machine: machineWithVersion(nil), expected: false},
+		{name: "empty version", machine: machineWithVersion(version("")), expected: false},
+		{name: "same version", machine: machineWithVersion(version("v1.17.3")), expected: true},
+		{name: "same version without prefix", machine: machineWithVersion(version("1.17.3")), expected: true},
+		{name: "other version", machine: machineWithVersion(version("v1.16.3")), expected: false},
+		{name: "unparseable version", machine: machineWithVersion(version("latest")), expected: false},
+	}
+	for _, test := range table {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := MatchesKubernetesVersion("v1.17.3")(test.machine); actual != test.expected {
+				t.Fatalf("expected %t but got %t", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestCreatedBeforeRolloutAfter(t *testing.T) {
+	now := time.Unix(10000, 0)
+	machineCreatedAt := func(seconds int64) *clusterv1.Machine {
+		return &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.Time{Time: time.Unix(seconds, 0)}}}
+	}
+
+	table := []struct {
+		name         string
+		machine      *clusterv1.Machine
+		rolloutAfter *metav1.Time
+		expected     bool
+	}{
+		{name: "nil machine", machine: nil, rolloutAfter: &metav1.Time{Time: time.Unix(5000, 0)}, expected: false},
+		{name: "no rollout time", machine: machineCreatedAt(1000), rolloutAfter: nil, expected: false},
+		{name: "created before a past rollout time", machine: machineCreatedAt(1000), rolloutAfter: &metav1.Time{Time: time.Unix(5000, 0)}, expected: true},
+		{name: "created after a past rollout time", machine: machineCreatedAt(6000), rolloutAfter: &metav1.Time{Time: time.Unix(5000, 0)}, expected: false},
+		{name: "rollout time ahead", machine: machineCreatedAt(1000), rolloutAfter: &metav1.Time{Time: time.Unix(20000, 0)}, expected: false},
+	}
+	for _, test := range table {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := CreatedBeforeRolloutAfter(test.rolloutAfter, now)(test.machine); actual != test.expected {
+				t.Fatalf("expected %t but got %t", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestIsMarkedUnhealthy(t *testing.T) {
+	table := []struct {
+		name     string
+		machine  *clusterv1.Machine
+		expected bool
+	}{
+		{name: "nil machine", machine: nil, expected: false},
+		{name: "no annotations", machine: &clusterv1.Machine{}, expected: false},
+		{name: "other annotation", machine: &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"foo": ""}}}, expected: false},
+		{name: "unhealthy annotation", machine: &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{clusterv1.MachineUnhealthyAnnotation: ""}}}, expected: true},
+		{name: "owner remediated condition false", machine: &clusterv1.Machine{Status: clusterv1.MachineStatus{Conditions: clusterv1.Conditions{{Type: clusterv1.MachineOwnerRemediatedCondition, Status: corev1.ConditionFalse}}}}, expected: true},
+		{name: "owner remediated condition true", machine: &clusterv1.Machine{Status: clusterv1.MachineStatus{Conditions: clusterv1.Conditions{{Type: clusterv1.MachineOwnerRemediatedCondition, Status: corev1.ConditionTrue}}}}, expected: false},
+	}
+	for _, test := range table {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := IsMarkedUnhealthy()(test.machine); actual != test.expected {
+				t.Fatalf("expected %t but got %t", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestHasDeleteMachineAnnotation(t *testing.T) {
+	table := []struct {
+		name     string
+		machine  *clusterv1.Machine
+		expected bool
+	}{
+		{name: "nil machine", machine: nil, expected: false},
+		{name: "no annotations", machine: &clusterv1.Machine{}, expected: false},
+		{name: "other annotation", machine: &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"foo": ""}}}, expected: false},
+		{name: "delete annotation", machine: &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{clusterv1.DeleteMachineAnnotation: ""}}}, expected: true},
+	}
+	for _, test := range table {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := HasDeleteMachineAnnotation()(test.machine); actual != test.expected {
+				t.Fatalf("expected %t but got %t", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestIsUnhealthy(t *testing.T) {
+	failure := "failure"
+	nodeRef := &corev1.ObjectReference{Name: "node"}
+
+	table := []struct {
+		name     string
+		machine  *clusterv1.Machine
+		expected bool
+	}{
+		{name: "nil machine", machine: nil, expected: false},
+		{name: "healthy", machine: &clusterv1.Machine{Status: clusterv1.MachineStatus{NodeRef: nodeRef}}, expected: false},
+		{name: "no node", machine: &clusterv1.Machine{}, expected: true},
+		{name: "failure message", machine: &clusterv1.Machine{Status: clusterv1.MachineStatus{NodeRef: nodeRef, FailureMessage: &failure}}, expected: true},
+		{
+			name: "unhealthy annotation",
+			machine: &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{clusterv1.MachineUnhealthyAnnotation: ""}},
+				Status:     clusterv1.MachineStatus{NodeRef: nodeRef},
+			},
+			expected: true,
+		},
+	}
+	for _, test := range table {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := IsUnhealthy()(test.machine); actual != test.expected {
+				t.Fatalf("expected %t but got %t", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestInFailureDomain(t *testing.T) {
+	machineInFailureDomain := func(failureDomain *string) *clusterv1.Machine {
+		return &clusterv1.Machine{Spec: clusterv1.MachineSpec{FailureDomain: failureDomain}}
+	}
+	failureDomain := func(fd string) *string { return &fd }
+
+	table := []struct {
+		name     string
+		machine  *clusterv1.Machine
+		expected bool
+	}{
+		{name: "nil machine", machine: nil, expected: false},
+		{name: "no failure domain", machine: machineInFailureDomain(nil), expected: false},
+		{name: "same failure domain", machine: machineInFailureDomain(failureDomain("us-west-1a")), expected: true},
+		{name: "other failure domain", machine: machineInFailureDomain(failureDomain("us-west-1b")), expected: false},
+	}
+	for _, test := range table {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := InFailureDomain("us-west-1a")(test.machine); actual != test.expected {
+				t.Fatalf("expected %t but got %t", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestProviderIDFilters(t *testing.T) {
+	machineWithProviderID := func(providerID *string) *clusterv1.Machine {
+		return &clusterv1.Machine{Spec: clusterv1.MachineSpec{ProviderID: providerID}}
+	}
+	providerID := func(id string) *string { return &id }
+
+	table := []struct {
+		name            string
+		machine         *clusterv1.Machine
+		expectedHas     bool
+		expectedMissing bool
+	}{
+		{name: "nil machine", machine: nil, expectedHas: false, expectedMissing: false},
+		{name: "unset provider ID", machine: machineWithProviderID(nil), expectedHas: false, expectedMissing: true},
+		{name: "empty provider ID", machine: machineWithProviderID(providerID("")), expectedHas: false, expectedMissing: true},
+		{name: "set provider ID", machine: machineWithProviderID(providerID("aws:///us-east-1a/i-0123")), expectedHas: true, expectedMissing: false},
+	}
+	for _, test := range table {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := HasProviderID()(test.machine); actual != test.expectedHas {
+				t.Fatalf("expected HasProviderID to be %t but got %t", test.expectedHas, actual)
+			}
+			if actual := MissingProviderID()(test.machine); actual != test.expectedMissing {
+				t.Fatalf("expected MissingProviderID to be %t but got %t", test.expectedMissing, actual)
+			}
+		})
+	}
+
+	t.Run("composes with OwnedControlPlaneMachines", func(t *testing.T) {
+		machines := []*clusterv1.Machine{}
+		for i, item := range machineListForTestGetMachinesForCluster().Items {
+			machine := item
+			if i%2 == 0 {
+				machine.Spec.ProviderID = providerID(fmt.Sprintf("test://%s", machine.Name))
+			}
+			machines = append(machines, &machine)
+		}
+		owned := FilterMachines(machines, OwnedControlPlaneMachines("my-control-plane"))
+		withProviderID := FilterMachines(machines, OwnedControlPlaneMachines("my-control-plane"), HasProviderID())
+		withoutProviderID := FilterMachines(machines, OwnedControlPlaneMachines("my-control-plane"), MissingProviderID())
+		if len(withProviderID)+len(withoutProviderID) != len(owned) {
+			t.Fatalf("expected the %d owned machines to be split between the filters but got %d and %d",
+				len(owned), len(withProviderID), len(withoutProviderID))
+		}
+		for _, machine := range withProviderID {
+			if machine.Spec.ProviderID == nil || !OwnedControlPlaneMachines("my-control-plane")(machine) {
+				t.Fatalf("expected machine %q to be owned and have a provider ID", machine.Name)
+			}
+		}
+	})
+}
+
+func TestUpToDateAndOutdatedControlPlaneMachines(t *testing.T) {
+	machine := func(owner, hash string) *clusterv1.Machine {
+		m := machineListForTestGetMachinesForCluster().Items[0]
+		m.OwnerReferences[0].Name = owner
+		if hash != "" {
+			m.Labels[controlplanev1.KubeadmControlPlaneHashLabelKey] = hash
+		}
+		return &m
+	}
+
+	table := []struct {
+		name             string
+		machine          *clusterv1.Machine
+		expectedUpToDate bool
+		expectedOutdated bool
+	}{
+		{name: "nil machine", machine: nil, expectedUpToDate: false, expectedOutdated: false},
+		{name: "owned with current hash", machine: machine("my-control-plane", "hash"), expectedUpToDate: true, expectedOutdated: false},
+		{name: "owned with other hash", machine: machine("my-control-plane", "other-hash"), expectedUpToDate: false, expectedOutdated: true},
+		{name: "owned without hash", machine: machine("my-control-plane", ""), expectedUpToDate: false, expectedOutdated: true},
+		{name: "not owned with current hash", machine: machine("other-control-plane", "hash"), expectedUpToDate: false, expectedOutdated: false},
+		{name: "not owned with other hash", machine: machine("other-control-plane", "other-hash"), expectedUpToDate: false, expectedOutdated: false},
+	}
+	for _, test := range table {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := UpToDateControlPlaneMachines("my-control-plane", "hash")(test.machine); actual != test.expectedUpToDate {
+				t.Fatalf("expected up-to-date to be %t but got %t", test.expectedUpToDate, actual)
+			}
+			if actual := OutdatedControlPlaneMachines("my-control-plane", "hash")(test.machine); actual != test.expectedOutdated {
+				t.Fatalf("expected outdated to be %t but got %t", test.expectedOutdated, actual)
+			}
+			composed := test.machine != nil &&
+				OwnedControlPlaneMachines("my-control-plane")(test.machine) &&
+				MatchesConfigurationHash("hash")(test.machine)
+			if composed != test.expectedUpToDate {
+				t.Fatalf("expected up-to-date to match the composition of its primitives")
+			}
+		})
+	}
+}
+
+func TestAdoptableControlPlaneMachines(t *testing.T) {
+	machine := func(mutate func(*clusterv1.Machine)) *clusterv1.Machine {
+		m := machineListForTestGetMachinesForCluster().Items[0]
+		m.OwnerReferences = nil
+		mutate(&m)
+		return &m
+	}
+	now := metav1.Now()
+
+	table := []struct {
+		name                 string
+		machine              *clusterv1.Machine
+		expectedControlPlane bool
+		expectedAdoptable    bool
+	}{
+		{name: "nil machine", machine: nil},
+		{name: "control plane machine without owner", machine: machine(func(*clusterv1.Machine) {}), expectedControlPlane: true, expectedAdoptable: true},
+		{
+			name: "control plane machine with other owner",
+			machine: machine(func(m *clusterv1.Machine) {
+				m.OwnerReferences = []metav1.OwnerReference{{Kind: "MachineSet", Name: "other"}}
+			}),
+			expectedControlPlane: true,
+			expectedAdoptable:    true,
+		},
+		{
+			name: "control plane machine with controller",
+			machine: machine(func(m *clusterv1.Machine) {
+				m.OwnerReferences = machineListForTestGetMachinesForCluster().Items[0].OwnerReferences
+			}),
+			expectedControlPlane: true,
+		},
+		{
+			name:                 "deleted control plane machine",
+			machine:              machine(func(m *clusterv1.Machine) { m.DeletionTimestamp = &now }),
+			expectedControlPlane: true,
+		},
+		{
+			name:    "control plane machine of other cluster",
+			machine: machine(func(m *clusterv1.Machine) { m.Labels[clusterv1.ClusterLabelName] = "other-cluster" }),
+		},
+		{
+			name:    "worker machine",
+			machine: machine(func(m *clusterv1.Machine) { delete(m.Labels, clusterv1.MachineControlPlaneLabelName) }),
+		},
+	}
+	for _, test := range table {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := ControlPlaneMachines("my-cluster")(test.machine); actual != test.expectedControlPlane {
+				t.Fatalf("expected control plane machine to be %t but got %t", test.expectedControlPlane, actual)
+			}
+			if actual := AdoptableControlPlaneMachines("my-cluster")(test.machine); actual != test.expectedAdoptable {
+				t.Fatalf("expected adoptable to be %t but got %t", test.expectedAdoptable, actual)
+			}
+		})
+	}
+}
+
+func TestGetControlPlaneEndpoint(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clusterv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	newCluster := func(name string, endpoint clusterv1.APIEndpoint) *clusterv1.Cluster {
+		return &clusterv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "my-namespace", Name: name},
+			Spec:       clusterv1.ClusterSpec{ControlPlaneEndpoint: endpoint},
+		}
+	}
+	m := &Management{Client: fake.NewFakeClientWithScheme(scheme,
+		newCluster("ready", clusterv1.APIEndpoint{Host: "10.0.0.1", Port: 6443}),
+		newCluster("ipv6", clusterv1.APIEndpoint{Host: "fd00::1", Port: 6443}),
+		newCluster("provisioning", clusterv1.APIEndpoint{}),
+	)}
+
+	table := []struct {
+		name           string
+		expected       string
+		expectNotSet   bool
+		expectAnyError bool
+	}{
+		{name: "ready", expected: "10.0.0.1:6443"},
+		{name: "ipv6", expected: "[fd00::1]:6443"},
+		{name: "provisioning", expectNotSet: true, expectAnyError: true},
+		{name: "missing", expectAnyError: true},
+	}
+	for _, test := range table {
+		t.Run(test.name, func(t *testing.T) {
+			endpoint, err := m.GetControlPlaneEndpoint(context.Background(), types.NamespacedName{Namespace: "my-namespace", Name: test.name})
+			if test.expectAnyError != (err != nil) {
+				t.Fatalf("expected error %t but got %v", test.expectAnyError, err)
+			}
+			if test.expectNotSet != (errors.Cause(err) == ErrControlPlaneEndpointNotSet) {
+				t.Fatalf("expected ErrControlPlaneEndpointNotSet %t but got %v", test.expectNotSet, err)
+			}
+			if endpoint != test.expected {
+				t.Fatalf("expected endpoint %q but got %q", test.expected, endpoint)
+			}
+		})
+	}
+}
+
+func TestGetControlPlaneMachinesForClusters(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clusterv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	newCluster := func(name, controlPlaneKind string, labels map[string]string) *clusterv1.Cluster {
+		cluster := &clusterv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "my-namespace", Name: name, Labels: labels},
+		}
+		if controlPlaneKind != "" {
+			cluster.Spec.ControlPlaneRef = &corev1.ObjectReference{Kind: controlPlaneKind, Name: "my-control-plane"}
+		}
+		return cluster
+	}
+	newMachine := func(name, clusterName string) *clusterv1.Machine {
+		machine := machineListForTestGetMachinesForCluster().Items[0]
+		machine.Name = name
+		machine.Labels[clusterv1.ClusterLabelName] = clusterName
+		return &machine
+	}
+	fleet := map[string]string{"fleet": "production"}
+
+	m := &Management{Client: fake.NewFakeClientWithScheme(scheme,
+		newCluster("first-cluster", "KubeadmControlPlane", fleet),
+		newCluster("second-cluster", "KubeadmControlPlane", fleet),
+		newCluster("other-fleet-cluster", "KubeadmControlPlane", map[string]string{"fleet": "staging"}),
+		newCluster("unmanaged-cluster", "", fleet),
+		newCluster("other-control-plane-cluster", "OtherControlPlane", fleet),
+		newMachine("first-cluster-machine-0", "first-cluster"),
+		newMachine("first-cluster-machine-1", "first-cluster"),
+		newMachine("second-cluster-machine-0", "second-cluster"),
+		newMachine("other-fleet-cluster-machine-0", "other-fleet-cluster"),
+		newMachine("unmanaged-cluster-machine-0", "unmanaged-cluster"),
+	)}
+
+	machines, err := m.GetControlPlaneMachinesForClusters(context.Background(), labels.SelectorFromSet(fleet))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[types.NamespacedName]int{
+		{Namespace: "my-namespace", Name: "first-cluster"}:  2,
+		{Namespace: "my-namespace", Name: "second-cluster"}: 1,
+	}
+	if len(machines) != len(expected) {
+		t.Fatalf("expected machines for %d clusters but got %d", len(expected), len(machines))
+	}
+	for clusterKey, count := range expected {
+		if len(machines[clusterKey]) != count {
+			t.Fatalf("expected %d machines for cluster %v but got %d", count, clusterKey, len(machines[clusterKey]))
+		}
+	}
+}
+
+func TestControlPlaneVersionConverged(t *testing.T) {
+	clusterKey := types.NamespacedName{Namespace: "my-namespace", Name: "my-cluster"}
+	machineList := func(versions ...string) *clusterv1.MachineList {
+		list := &clusterv1.MachineList{}
+		for i, v := range versions {
+			machine := machineListForTestGetMachinesForCluster().Items[0]
+			machine.Name = fmt.Sprintf("machine-%d", i)
+			if v != "" {
+				machineVersion := v
+				machine.Spec.Version = &machineVersion
+			}
+			list.Items = append(list.Items, machine)
+		}
+		return list
+	}
+
+	table := []struct {
+		name              string
+		machines          *clusterv1.MachineList
+		expectedConverged bool
+		expectedOutdated  []string
+	}{
+		{
+			name:              "all machines on the target version",
+			machines:          machineList("v1.17.3", "1.17.3", "v1.17.3"),
+			expectedConverged: true,
+			expectedOutdated:  []string{},
+		},
+		{
+			name:              "some machines on another version",
+			machines:          machineList("v1.17.3", "v1.16.2", "v1.17.3"),
+			expectedConverged: false,
+			expectedOutdated:  []string{"machine-1"},
+		},
+		{
+			name:              "machine without a version",
+			machines:          machineList("v1.17.3", ""),
+			expectedConverged: false,
+			expectedOutdated:  []string{"machine-1"},
+		},
+		{
+			name:              "no machines",
+			machines:          machineList(),
+			expectedConverged: false,
+			expectedOutdated:  []string{},
+		},
+	}
+	for _, test := range table {
+		t.Run(test.name, func(t *testing.T) {
+			m := &Management{Client: &fakeClient{list: test.machines}}
+			converged, outdated, err := m.ControlPlaneVersionConverged(context.Background(), clusterKey, "my-control-plane", "v1.17.3")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if converged != test.expectedConverged {
+				t.Fatalf("expected converged to be %t but got %t", test.expectedConverged, converged)
+			}
+			if !reflect.DeepEqual(outdated, test.expectedOutdated) {
+				t.Fatalf("expected outdated machines %v but got %v", test.expectedOutdated, outdated)
+			}
+		})
+	}
+}
+
+func machinesForTestFilterMachinesParallel(count int) []*clusterv1.Machine {
+	list := machineListForTestGetMachinesForCluster()
+	machines := make([]*clusterv1.Machine, 0, count)
+	for i := 0; i < count; i++ {
+		machine := list.Items[i%len(list.Items)].DeepCopy()
+		machine.Name = fmt.Sprintf("machine-%d", i)
+		machine.CreationTimestamp = metav1.NewTime(time.Unix(int64(i), 0))
+		machines = append(machines, machine)
+	}
+	return machines
+}
+
+func TestFilterMachinesParallel(t *testing.T) {
+	filters := []func(machine *clusterv1.Machine) bool{
+		OwnedControlPlaneMachines("my-control-plane"),
+		OlderThan(&metav1.Time{Time: time.Unix(4000, 0)}),
+	}
+
+	table := []struct {
+		name     string
+		machines int
+		workers  int
+	}{
+		{name: "below the parallel threshold", machines: 10, workers: 4},
+		{name: "single worker", machines: 3000, workers: 1},
+		{name: "uneven partitions", machines: 5001, workers: 7},
+		{name: "more workers than machines per partition", machines: 1000, workers: 999},
+	}
+	for _, test := range table {
+		t.Run(test.name, func(t *testing.T) {
+			machines := machinesForTestFilterMachinesParallel(test.machines)
+			expected := FilterMachines(machines, filters...)
+			actual := FilterMachinesParallel(machines, test.workers, filters...)
+			if !reflect.DeepEqual(expected, actual) {
+				t.Fatalf("expected %d machines in input order but got %d", len(expected), len(actual))
+			}
+		})
+	}
+
+	machines := machinesForTestFilterMachinesParallel(2000)
+	if actual := FilterMachinesParallel(machines, 4); len(actual) != len(machines) {
+		t.Fatalf("expected no filters to return all %d machines but got %d", len(machines), len(actual))
+	}
+}
+
+func BenchmarkFilterMachines(b *testing.B) {
+	machines := machinesForTestFilterMachinesParallel(50000)
+	filters := []func(machine *clusterv1.Machine) bool{
+		OwnedControlPlaneMachines("my-control-plane"),
+		HasOutdatedConfiguration("hash"),
+		OlderThan(&metav1.Time{Time: time.Unix(40000, 0)}),
+	}
+
+	b.Run("sequential", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			FilterMachines(machines, filters...)
+		}
+	})
+	b.Run("parallel", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			FilterMachinesParallel(machines, goruntime.NumCPU(), filters...)
+		}
+	})
+}
+
+func TestPingAPIServer(t *testing.T) {
+	table := []struct {
+		name        string
+		status      int
+		expectedErr bool
+	}{
+		{name: "serving API server", status: http.StatusOK, expectedErr: false},
+		{name: "API server not ready", status: http.StatusServiceUnavailable, expectedErr: true},
+	}
+	for _, test := range table {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/version" {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				w.WriteHeader(test.status)
+				fmt.Fprint(w, `{"major":"1","minor":"17"}`)
+			}))
+			defer server.Close()
+
+			workloadCluster := &cluster{restConfig: &rest.Config{Host: server.URL}}
+			err := workloadCluster.pingAPIServer(context.Background())
+			if test.expectedErr && errors.Cause(err) != ErrWorkloadClusterUnreachable {
+				t.Fatalf("expected ErrWorkloadClusterUnreachable but got %v", err)
+			}
+			if !test.expectedErr && err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestHealthChecksPingAPIServer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	clusterKey := types.NamespacedName{Namespace: "my-namespace", Name: "my-cluster"}
+	workloadCluster := &cluster{
+		// Listing nodes would fail with a different error, so the ping must run first.
+		client:     &fakeClient{},
+		restConfig: &rest.Config{Host: server.URL},
+	}
+	m := managementClusterForTest(clusterKey, workloadCluster)
+	m.PingAPIServer = true
+
+	err := m.TargetClusterControlPlaneIsHealthy(context.Background(), clusterKey, "my-control-plane")
+	if errors.Cause(err) != ErrWorkloadClusterUnreachable {
+		t.Fatalf("expected ErrWorkloadClusterUnreachable but got %v", err)
+	}
+	err = m.TargetClusterEtcdIsHealthy(context.Background(), clusterKey, "my-control-plane")
+	if errors.Cause(err) != ErrWorkloadClusterUnreachable {
+		t.Fatalf("expected ErrWorkloadClusterUnreachable but got %v", err)
+	}
+
+	m.PingAPIServer = false
+	err = m.TargetClusterControlPlaneIsHealthy(context.Background(), clusterKey, "my-control-plane")
+	if err == nil || errors.Cause(err) == ErrWorkloadClusterUnreachable {
+		t.Fatalf("expected the node listing to fail without pinging the API server but got %v", err)
+	}
+}
+
+func TestWorkloadClusterClientUsesTracker(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	kubeconfig := `apiVersion: v1
+kind: Config
+clusters:
+- name: my-cluster
+  cluster:
+    server: https://127.0.0.1:6443
+contexts:
+- name: my-cluster
+  context:
+    cluster: my-cluster
+    user: admin
+current-context: my-cluster
+users:
+- name: admin
+  user:
+    token: token
+`
+	managementClient := fake.NewFakeClientWithScheme(scheme, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "my-namespace", Name: "my-cluster-kubeconfig"},
+		Data:       map[string][]byte{secret.KubeconfigDataName: []byte(kubeconfig)},
+	})
+	tracker := remote.NewClusterCacheTracker(log.Log, managementClient, scheme)
+	defer tracker.DeleteClusterCache(types.NamespacedName{Namespace: "my-namespace", Name: "my-cluster"})
+	m := &Management{Client: managementClient, Tracker: tracker}
+	workloadCluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Namespace: "my-namespace", Name: "my-cluster"}}
+
+	restConfig, c, err := m.workloadClusterClient(context.Background(), workloadCluster)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restConfig.Host != "https://127.0.0.1:6443" {
+		t.Fatalf("expected the REST configuration of the workload cluster but got host %q", restConfig.Host)
+	}
+	shared, err := tracker.GetClient(context.Background(), workloadCluster)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != shared {
+		t.Fatal("expected the client shared through the tracker")
+	}
+}
@@ -0,0 +1,194 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internal
+
+import (
+	"context"
+	"strings"
+
+	"github.com/coredns/corefile-migration/migration"
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/version"
+	kubeadmv1beta1 "sigs.k8s.io/cluster-api/bootstrap/kubeadm/types/v1beta1"
+)
+
+const (
+	// coreDNSKey is the name of the CoreDNS Deployment, of its ConfigMap and of its container.
+	coreDNSKey = "coredns"
+
+	// coreDNSVolumeKey is the name of the volume the CoreDNS Deployment mounts its ConfigMap as.
+	coreDNSVolumeKey = "config-volume"
+
+	// corefileKey is the key of the Corefile in the CoreDNS ConfigMap.
+	corefileKey = "Corefile"
+
+	// corefileBackupKey is the key the Corefile is backed up to in the CoreDNS ConfigMap before it is migrated.
+	corefileBackupKey = "Corefile-backup"
+)
+
+// UpdateCoreDNS upgrades the CoreDNS Deployment of the target cluster to the image tag, and image repository if any,
+// set in the DNS settings of the given ClusterConfiguration, and migrates its Corefile to the new CoreDNS version.
+// Nothing is done if no image tag is set, if the cluster does not use CoreDNS or if CoreDNS already runs that image.
+// An error is returned, before anything is changed, if the Corefile cannot be migrated to the new version.
+func (m *Management) UpdateCoreDNS(ctx context.Context, clusterKey types.NamespacedName, clusterConfiguration *kubeadmv1beta1.ClusterConfiguration) error {
+	if !requestsCoreDNSUpdate(clusterConfiguration) {
+		return nil
+	}
+	cluster, err := m.getCluster(ctx, clusterKey)
+	if err != nil {
+		return err
+	}
+	return cluster.updateCoreDNS(ctx, clusterConfiguration)
+}
+
+// requestsCoreDNSUpdate returns whether the given ClusterConfiguration sets a CoreDNS image tag to upgrade to.
+func requestsCoreDNSUpdate(clusterConfiguration *kubeadmv1beta1.ClusterConfiguration) bool {
+	if clusterConfiguration == nil || clusterConfiguration.DNS.ImageTag == "" {
+		return false
+	}
+	return clusterConfiguration.DNS.Type == "" || clusterConfiguration.DNS.Type == kubeadmv1beta1.CoreDNS
+}
+
+func (c *cluster) updateCoreDNS(ctx context.Context, clusterConfiguration *kubeadmv1beta1.ClusterConfiguration) error {
+	deployment := &appsv1.Deployment{}
+	deploymentKey := types.NamespacedName{Namespace: metav1.NamespaceSystem, Name: coreDNSKey}
+	if err := c.client.Get(ctx, deploymentKey, deployment); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "failed to get %s/%s Deployment", deploymentKey.Namespace, deploymentKey.Name)
+	}
+	container := coreDNSContainer(deployment)
+	if container == nil {
+		return errors.Errorf("%s/%s Deployment has no %s container", deploymentKey.Namespace, deploymentKey.Name, coreDNSKey)
+	}
+
+	currentImage, currentTag := splitImage(container.Image)
+	targetImage := currentImage
+	if repository := clusterConfiguration.DNS.ImageRepository; repository != "" {
+		targetImage = strings.TrimSuffix(repository, "/") + "/" + coreDNSKey
+	} else if repository := clusterConfiguration.ImageRepository; repository != "" {
+		targetImage = strings.TrimSuffix(repository, "/") + "/" + coreDNSKey
+	}
+	targetImage = targetImage + ":" + clusterConfiguration.DNS.ImageTag
+	if targetImage == container.Image {
+		return nil
+	}
+
+	configMap := &corev1.ConfigMap{}
+	configMapKey := types.NamespacedName{Namespace: metav1.NamespaceSystem, Name: coreDNSKey}
+	if err := c.client.Get(ctx, configMapKey, configMap); err != nil {
+		return errors.Wrapf(err, "failed to get %s/%s ConfigMap", configMapKey.Namespace, configMapKey.Name)
+	}
+	corefile, ok := configMap.Data[corefileKey]
+	if !ok {
+		return errors.Errorf("%s/%s ConfigMap has no %s", configMapKey.Namespace, configMapKey.Name, corefileKey)
+	}
+	migratedCorefile, err := migrateCorefile(currentTag, clusterConfiguration.DNS.ImageTag, corefile)
+	if err != nil {
+		return err
+	}
+
+	// Back up the Corefile and point the running CoreDNS pods at the backup, so that they do not load the migrated
+	// Corefile, which their version may not support.
+	if configMap.Data[corefileBackupKey] != corefile {
+		configMap.Data[corefileBackupKey] = corefile
+		if err := c.client.Update(ctx, configMap); err != nil {
+			return errors.Wrapf(err, "failed to back up the %s of the %s/%s ConfigMap", corefileKey, configMapKey.Namespace, configMapKey.Name)
+		}
+	}
+	if setCoreDNSCorefileKey(deployment, corefileBackupKey) {
+		if err := c.client.Update(ctx, deployment); err != nil {
+			return errors.Wrapf(err, "failed to update %s/%s Deployment", deploymentKey.Namespace, deploymentKey.Name)
+		}
+	}
+
+	if migratedCorefile != corefile {
+		configMap.Data[corefileKey] = migratedCorefile
+		if err := c.client.Update(ctx, configMap); err != nil {
+			return errors.Wrapf(err, "failed to migrate the %s of the %s/%s ConfigMap", corefileKey, configMapKey.Namespace, configMapKey.Name)
+		}
+	}
+
+	// Roll out the new image with the migrated Corefile.
+	container = coreDNSContainer(deployment)
+	container.Image = targetImage
+	setCoreDNSCorefileKey(deployment, corefileKey)
+	if err := c.client.Update(ctx, deployment); err != nil {
+		return errors.Wrapf(err, "failed to update %s/%s Deployment", deploymentKey.Namespace, deploymentKey.Name)
+	}
+	return nil
+}
+
+// migrateCorefile validates that CoreDNS can be upgraded from the current to the target image tag, and returns the
+// Corefile migrated to the target version.
+func migrateCorefile(currentTag, targetTag, corefile string) (string, error) {
+	currentVersion, err := version.ParseSemantic(currentTag)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to parse current CoreDNS version %q", currentTag)
+	}
+	targetVersion, err := version.ParseSemantic(targetTag)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to parse target CoreDNS version %q", targetTag)
+	}
+	if targetVersion.LessThan(currentVersion) {
+		return "", errors.Errorf("cannot downgrade CoreDNS from %s to %s", currentTag, targetTag)
+	}
+
+	// The migration library identifies versions without the v prefix.
+	from := strings.TrimPrefix(currentTag, "v")
+	to := strings.TrimPrefix(targetTag, "v")
+	migrated, err := migration.Migrate(from, to, corefile, false)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to migrate the Corefile from CoreDNS %s to %s", currentTag, targetTag)
+	}
+	return migrated, nil
+}
+
+// coreDNSContainer returns the CoreDNS container of the given Deployment, or nil if it has none.
+func coreDNSContainer(deployment *appsv1.Deployment) *corev1.Container {
+	containers := deployment.Spec.Template.Spec.Containers
+	for i := range containers {
+		if containers[i].Name == coreDNSKey {
+			return &containers[i]
+		}
+	}
+	return nil
+}
+
+// setCoreDNSCorefileKey mounts the given key of the CoreDNS ConfigMap as the Corefile of the given Deployment.
+// It reports whether the Deployment changed.
+func setCoreDNSCorefileKey(deployment *appsv1.Deployment, key string) bool {
+	changed := false
+	volumes := deployment.Spec.Template.Spec.Volumes
+	for i := range volumes {
+		if volumes[i].Name != coreDNSVolumeKey || volumes[i].ConfigMap == nil {
+			continue
+		}
+		items := []corev1.KeyToPath{{Key: key, Path: corefileKey}}
+		if len(volumes[i].ConfigMap.Items) != 1 || volumes[i].ConfigMap.Items[0] != items[0] {
+			volumes[i].ConfigMap.Items = items
+			changed = true
+		}
+	}
+	return changed
+}
@@ -0,0 +1,193 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	kubeadmv1beta1 "sigs.k8s.io/cluster-api/bootstrap/kubeadm/types/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestUpdateCoreDNS(t *testing.T) {
+	clusterKey := types.NamespacedName{Namespace: "my-namespace", Name: "my-cluster"}
+	corefile := `.:53 {
+    errors
+    health
+    kubernetes cluster.local in-addr.arpa ip6.arpa {
+       pods insecure
+       upstream
+       fallthrough in-addr.arpa ip6.arpa
+    }
+    prometheus :9153
+    proxy . /etc/resolv.conf
+    cache 30
+    loop
+    reload
+    loadbalance
+}
+`
+	coreDNS := func(image string) *appsv1.Deployment {
+		deployment := &appsv1.Deployment{}
+		deployment.Spec.Template.Spec.Containers = []corev1.Container{{Name: "coredns", Image: image}}
+		deployment.Spec.Template.Spec.Volumes = []corev1.Volume{{
+			Name: "config-volume",
+			VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "coredns"},
+				Items:                []corev1.KeyToPath{{Key: "Corefile", Path: "Corefile"}},
+			}},
+		}}
+		return deployment
+	}
+	dns := func(imageRepository, imageTag string) *kubeadmv1beta1.ClusterConfiguration {
+		return &kubeadmv1beta1.ClusterConfiguration{
+			DNS: kubeadmv1beta1.DNS{ImageMeta: kubeadmv1beta1.ImageMeta{ImageRepository: imageRepository, ImageTag: imageTag}},
+		}
+	}
+
+	table := []struct {
+		name                 string
+		deployment           *appsv1.Deployment
+		clusterConfiguration *kubeadmv1beta1.ClusterConfiguration
+		expectedImage        string
+		expectedErr          string
+	}{
+		{
+			name:                 "upgrades CoreDNS and migrates the Corefile",
+			deployment:           coreDNS("k8s.gcr.io/coredns:1.3.1"),
+			clusterConfiguration: dns("", "1.6.7"),
+			expectedImage:        "k8s.gcr.io/coredns:1.6.7",
+		},
+		{
+			name:                 "uses the DNS image repository",
+			deployment:           coreDNS("k8s.gcr.io/coredns:1.3.1"),
+			clusterConfiguration: dns("registry.example.com/", "1.6.7"),
+			expectedImage:        "registry.example.com/coredns:1.6.7",
+		},
+		{
+			name:                 "no image tag",
+			deployment:           coreDNS("k8s.gcr.io/coredns:1.3.1"),
+			clusterConfiguration: dns("", ""),
+		},
+		{
+			name:                 "already up to date",
+			deployment:           coreDNS("k8s.gcr.io/coredns:1.6.7"),
+			clusterConfiguration: dns("", "1.6.7"),
+		},
+		{
+			name:                 "no CoreDNS Deployment",
+			deployment:           nil,
+			clusterConfiguration: dns("", "1.6.7"),
+		},
+		{
+			name:                 "downgrade",
+			deployment:           coreDNS("k8s.gcr.io/coredns:1.6.7"),
+			clusterConfiguration: dns("", "1.6.2"),
+			expectedErr:          "cannot downgrade",
+		},
+		{
+			name:                 "unsupported target version",
+			deployment:           coreDNS("k8s.gcr.io/coredns:1.6.7"),
+			clusterConfiguration: dns("", "1.99.0"),
+			expectedErr:          "unable to migrate",
+		},
+	}
+	for _, test := range table {
+		t.Run(test.name, func(t *testing.T) {
+			workloadClient := &fakeClient{}
+			m := managementClusterForTest(clusterKey, &cluster{client: &coreDNSClient{
+				fakeClient: workloadClient,
+				deployment: test.deployment,
+				configMap:  &corev1.ConfigMap{Data: map[string]string{"Corefile": corefile}},
+			}})
+
+			err := m.UpdateCoreDNS(context.Background(), clusterKey, test.clusterConfiguration)
+			if test.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.expectedErr) {
+					t.Fatalf("expected an error containing %q but got %v", test.expectedErr, err)
+				}
+				if len(workloadClient.updated) != 0 {
+					t.Fatalf("expected no update but got %v", workloadClient.updated)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.expectedImage == "" {
+				if len(workloadClient.updated) != 0 {
+					t.Fatalf("expected no update but got %v", workloadClient.updated)
+				}
+				return
+			}
+
+			var configMap *corev1.ConfigMap
+			var deployment *appsv1.Deployment
+			for _, obj := range workloadClient.updated {
+				switch obj := obj.(type) {
+				case *corev1.ConfigMap:
+					configMap = obj
+				case *appsv1.Deployment:
+					deployment = obj
+				}
+			}
+			if configMap.Data["Corefile-backup"] != corefile {
+				t.Fatalf("expected the Corefile to be backed up but got %q", configMap.Data["Corefile-backup"])
+			}
+			if migrated := configMap.Data["Corefile"]; !strings.Contains(migrated, "forward . /etc/resolv.conf") || strings.Contains(migrated, "upstream") {
+				t.Fatalf("expected the Corefile to be migrated but got %q", migrated)
+			}
+			if image := deployment.Spec.Template.Spec.Containers[0].Image; image != test.expectedImage {
+				t.Fatalf("expected image %q but got %q", test.expectedImage, image)
+			}
+			if key := deployment.Spec.Template.Spec.Volumes[0].ConfigMap.Items[0].Key; key != "Corefile" {
+				t.Fatalf("expected the Deployment to mount the migrated Corefile but got key %q", key)
+			}
+		})
+	}
+}
+
+// coreDNSClient serves the CoreDNS Deployment and ConfigMap, which share their name.
+type coreDNSClient struct {
+	*fakeClient
+	deployment *appsv1.Deployment
+	configMap  *corev1.ConfigMap
+}
+
+func (c *coreDNSClient) Get(_ context.Context, key client.ObjectKey, obj runtime.Object) error {
+	switch obj := obj.(type) {
+	case *appsv1.Deployment:
+		if c.deployment == nil {
+			return apierrors.NewNotFound(schema.GroupResource{}, key.Name)
+		}
+		c.deployment.DeepCopyInto(obj)
+	case *corev1.ConfigMap:
+		c.configMap.DeepCopyInto(obj)
+	default:
+		return fmt.Errorf("unknown type: %T", obj)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internal
+
+import (
+	"context"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+)
+
+// MismatchReport explains why the control plane machines of a cluster do not match its control plane nodes 1:1.
+type MismatchReport struct {
+	// MachinesWithoutNodeRef are the control plane machines that have no status.nodeRef.
+	MachinesWithoutNodeRef []types.NamespacedName
+
+	// MachinesWithMissingNode maps control plane machines to the node their status.nodeRef points to,
+	// for every node that is not a control plane node of the workload cluster.
+	MachinesWithMissingNode map[types.NamespacedName]string
+
+	// NodesWithoutMachine are the control plane nodes that no control plane machine refers to.
+	NodesWithoutMachine []string
+}
+
+// HasMismatch returns true if the report found any inconsistency between machines and nodes.
+func (r *MismatchReport) HasMismatch() bool {
+	return len(r.MachinesWithoutNodeRef) > 0 || len(r.MachinesWithMissingNode) > 0 || len(r.NodesWithoutMachine) > 0
+}
+
+// DiagnoseNodeMachineMismatch compares the control plane machines owned by a KubeadmControlPlane with
+// the control plane nodes of its workload cluster, and reports every inconsistency found.
+// It is purely informational and does not modify either cluster.
+func (m *Management) DiagnoseNodeMachineMismatch(ctx context.Context, clusterKey types.NamespacedName, controlPlaneName string) (*MismatchReport, error) {
+	cluster, err := m.getCluster(ctx, clusterKey)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := cluster.getControlPlaneNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	machines, err := m.GetMachinesForCluster(ctx, clusterKey, OwnedControlPlaneMachines(controlPlaneName))
+	if err != nil {
+		return nil, err
+	}
+
+	nodeNames := make(map[string]struct{}, len(nodes.Items))
+	unreferencedNodes := make(map[string]struct{}, len(nodes.Items))
+	for _, node := range nodes.Items {
+		nodeNames[node.Name] = struct{}{}
+		unreferencedNodes[node.Name] = struct{}{}
+	}
+
+	report := &MismatchReport{
+		MachinesWithoutNodeRef:  []types.NamespacedName{},
+		MachinesWithMissingNode: map[types.NamespacedName]string{},
+		NodesWithoutMachine:     []string{},
+	}
+	for _, machine := range machines {
+		machineKey := types.NamespacedName{Namespace: machine.Namespace, Name: machine.Name}
+		if machine.Status.NodeRef == nil {
+			report.MachinesWithoutNodeRef = append(report.MachinesWithoutNodeRef, machineKey)
+			continue
+		}
+		nodeName := machine.Status.NodeRef.Name
+		if _, ok := nodeNames[nodeName]; !ok {
+			report.MachinesWithMissingNode[machineKey] = nodeName
+			continue
+		}
+		delete(unreferencedNodes, nodeName)
+	}
+	for nodeName := range unreferencedNodes {
+		report.NodesWithoutMachine = append(report.NodesWithoutMachine, nodeName)
+	}
+
+	sort.Slice(report.MachinesWithoutNodeRef, func(i, j int) bool {
+		return report.MachinesWithoutNodeRef[i].String() < report.MachinesWithoutNodeRef[j].String()
+	})
+	sort.Strings(report.NodesWithoutMachine)
+	return report, nil
+}
+
+// GetControlPlaneMachinesWithNodeCondition returns the control plane machines owned by the named control plane whose
+// node reports the given condition with the given status, e.g. all machines whose node is under disk pressure.
+// Machines without a node are left out.
+func (m *Management) GetControlPlaneMachinesWithNodeCondition(ctx context.Context, clusterKey types.NamespacedName, controlPlaneName string, conditionType corev1.NodeConditionType, status corev1.ConditionStatus) ([]*clusterv1.Machine, error) {
+	cluster, err := m.getCluster(ctx, clusterKey)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := cluster.getControlPlaneNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	matchingNodes := make(map[string]struct{}, len(nodes.Items))
+	for i := range nodes.Items {
+		if nodeHasCondition(&nodes.Items[i], conditionType, status) {
+			matchingNodes[nodes.Items[i].Name] = struct{}{}
+		}
+	}
+	nodeMatches := func(machine *clusterv1.Machine) bool {
+		if machine.Status.NodeRef == nil {
+			return false
+		}
+		_, ok := matchingNodes[machine.Status.NodeRef.Name]
+		return ok
+	}
+	return m.GetMachinesForCluster(ctx, clusterKey, OwnedControlPlaneMachines(controlPlaneName), nodeMatches)
+}
+
+// nodeHasCondition reports whether the node has a condition of the given type with the given status.
+func nodeHasCondition(node *corev1.Node, conditionType corev1.NodeConditionType, status corev1.ConditionStatus) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == conditionType {
+			return condition.Status == status
+		}
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internal
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+)
+
+func TestDiagnoseNodeMachineMismatch(t *testing.T) {
+	clusterKey := types.NamespacedName{Namespace: "my-namespace", Name: "my-cluster"}
+	controlPlaneMachine := func(name, nodeName string) clusterv1.Machine {
+		machine := machineListForTestGetMachinesForCluster().Items[0]
+		machine.Name = name
+		if nodeName != "" {
+			machine.Status.NodeRef = &corev1.ObjectReference{Kind: "Node", Name: nodeName}
+		}
+		return machine
+	}
+
+	t.Run("consistent control plane", func(t *testing.T) {
+		m := managementClusterForTest(clusterKey, &cluster{client: &fakeClient{list: nodeListForTestControlPlaneIsHealthy()}})
+		m.Client.(*fakeClient).list = &clusterv1.MachineList{Items: []clusterv1.Machine{
+			controlPlaneMachine("first-machine", "first-control-plane"),
+			controlPlaneMachine("second-machine", "second-control-plane"),
+			controlPlaneMachine("third-machine", "third-control-plane"),
+		}}
+
+		report, err := m.DiagnoseNodeMachineMismatch(context.Background(), clusterKey, "my-control-plane")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.HasMismatch() {
+			t.Fatalf("expected no mismatch but got %+v", report)
+		}
+	})
+
+	t.Run("inconsistent control plane", func(t *testing.T) {
+		m := managementClusterForTest(clusterKey, &cluster{client: &fakeClient{list: nodeListForTestControlPlaneIsHealthy()}})
+		m.Client.(*fakeClient).list = &clusterv1.MachineList{Items: []clusterv1.Machine{
+			controlPlaneMachine("first-machine", "first-control-plane"),
+			controlPlaneMachine("provisioning-machine", ""),
+			controlPlaneMachine("deleted-node-machine", "deleted-control-plane"),
+		}}
+
+		report, err := m.DiagnoseNodeMachineMismatch(context.Background(), clusterKey, "my-control-plane")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := &MismatchReport{
+			MachinesWithoutNodeRef: []types.NamespacedName{{Namespace: "my-namespace", Name: "provisioning-machine"}},
+			MachinesWithMissingNode: map[types.NamespacedName]string{
+				{Namespace: "my-namespace", Name: "deleted-node-machine"}: "deleted-control-plane",
+			},
+			NodesWithoutMachine: []string{"second-control-plane", "third-control-plane"},
+		}
+		if !reflect.DeepEqual(expected, report) {
+			t.Fatalf("expected report %+v but got %+v", expected, report)
+		}
+		if !report.HasMismatch() {
+			t.Fatal("expected a mismatch")
+		}
+	})
+}
+
+func TestGetControlPlaneMachinesWithNodeCondition(t *testing.T) {
+	clusterKey := types.NamespacedName{Namespace: "my-namespace", Name: "my-cluster"}
+	controlPlaneMachine := func(name, nodeName string) clusterv1.Machine {
+		machine := machineListForTestGetMachinesForCluster().Items[0]
+		machine.Name = name
+		if nodeName != "" {
+			machine.Status.NodeRef = &corev1.ObjectReference{Kind: "Node", Name: nodeName}
+		}
+		return machine
+	}
+	node := func(name string, conditions ...corev1.NodeCondition) corev1.Node {
+		return corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status:     corev1.NodeStatus{Conditions: conditions},
+		}
+	}
+	nodes := &corev1.NodeList{Items: []corev1.Node{
+		node("first-control-plane",
+			corev1.NodeCondition{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+			corev1.NodeCondition{Type: corev1.NodeDiskPressure, Status: corev1.ConditionTrue},
+		),
+		node("second-control-plane",
+			corev1.NodeCondition{Type: corev1.NodeReady, Status: corev1.ConditionUnknown},
+			corev1.NodeCondition{Type: corev1.NodeDiskPressure, Status: corev1.ConditionFalse},
+		),
+		node("third-control-plane",
+			corev1.NodeCondition{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+			corev1.NodeCondition{Type: corev1.NodeDiskPressure, Status: corev1.ConditionTrue},
+		),
+	}}
+	machines := &clusterv1.MachineList{Items: []clusterv1.Machine{
+		controlPlaneMachine("first-machine", "first-control-plane"),
+		controlPlaneMachine("second-machine", "second-control-plane"),
+		controlPlaneMachine("provisioning-machine", ""),
+		controlPlaneMachine("deleted-node-machine", "deleted-control-plane"),
+	}}
+
+	table := []struct {
+		name          string
+		conditionType corev1.NodeConditionType
+		status        corev1.ConditionStatus
+		expected      []string
+	}{
+		{name: "disk pressure", conditionType: corev1.NodeDiskPressure, status: corev1.ConditionTrue, expected: []string{"first-machine"}},
+		{name: "no disk pressure", conditionType: corev1.NodeDiskPressure, status: corev1.ConditionFalse, expected: []string{"second-machine"}},
+		{name: "unknown readiness", conditionType: corev1.NodeReady, status: corev1.ConditionUnknown, expected: []string{"second-machine"}},
+		{name: "condition not reported", conditionType: corev1.NodeMemoryPressure, status: corev1.ConditionTrue, expected: []string{}},
+	}
+	for _, test := range table {
+		t.Run(test.name, func(t *testing.T) {
+			m := managementClusterForTest(clusterKey, &cluster{client: &fakeClient{list: nodes}})
+			m.Client.(*fakeClient).list = machines
+
+			matching, err := m.GetControlPlaneMachinesWithNodeCondition(context.Background(), clusterKey, "my-control-plane", test.conditionType, test.status)
+			if err != nil {
+				t.Fatal(err)
+			}
+			names := []string{}
+			for _, machine := range matching {
+				names = append(names, machine.Name)
+			}
+			if !reflect.DeepEqual(names, test.expected) {
+				t.Fatalf("expected machines %v but got %v", test.expected, names)
+			}
+		})
+	}
+}
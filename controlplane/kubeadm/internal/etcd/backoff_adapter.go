@@ -18,9 +18,11 @@ package etcd
 
 import (
 	"context"
+	"io"
 	"time"
 
 	"go.etcd.io/etcd/clientv3"
+	"go.etcd.io/etcd/etcdserver/api/v3rpc/rpctypes"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/klog/klogr"
 )
@@ -83,6 +85,23 @@ func (e *EtcdBackoffAdapter) Close() error {
 	return e.EtcdClient.Close()
 }
 
+// AlarmDisarm calls AlarmDisarm on the etcd client with a backoff retry.
+func (e *EtcdBackoffAdapter) AlarmDisarm(ctx context.Context, m *clientv3.AlarmMember) (*clientv3.AlarmResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.Timeout)
+	defer cancel()
+	var alarmResponse *clientv3.AlarmResponse
+	err := wait.ExponentialBackoff(e.BackoffParams, func() (bool, error) {
+		resp, err := e.EtcdClient.AlarmDisarm(ctx, m)
+		if err != nil {
+			Log.Info("failed to disarm etcd alarm", "etcd client error", err)
+			return false, nil
+		}
+		alarmResponse = resp
+		return true, nil
+	})
+	return alarmResponse, err
+}
+
 // AlarmList calls AlarmList on the etcd client with a backoff retry.
 func (e *EtcdBackoffAdapter) AlarmList(ctx context.Context) (*clientv3.AlarmResponse, error) {
 	ctx, cancel := context.WithTimeout(ctx, e.Timeout)
@@ -117,6 +136,14 @@ func (e *EtcdBackoffAdapter) MemberList(ctx context.Context) (*clientv3.MemberLi
 	return response, err
 }
 
+// MemberAddAsLearner calls MemberAddAsLearner on the etcd client.
+// It is not retried, because a request that timed out may still have added the member.
+func (e *EtcdBackoffAdapter) MemberAddAsLearner(ctx context.Context, peerAddrs []string) (*clientv3.MemberAddResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.Timeout)
+	defer cancel()
+	return e.EtcdClient.MemberAddAsLearner(ctx, peerAddrs)
+}
+
 // MemberUpdate calls MemberUpdate on the etcd client with a backoff retry.
 func (e *EtcdBackoffAdapter) MemberUpdate(ctx context.Context, id uint64, peerURLs []string) (*clientv3.MemberUpdateResponse, error) {
 	ctx, cancel := context.WithTimeout(ctx, e.Timeout)
@@ -151,6 +178,23 @@ func (e *EtcdBackoffAdapter) MemberRemove(ctx context.Context, id uint64) (*clie
 	return response, err
 }
 
+// MemberPromote calls MemberPromote on the etcd client with a backoff retry.
+func (e *EtcdBackoffAdapter) MemberPromote(ctx context.Context, id uint64) (*clientv3.MemberPromoteResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.Timeout)
+	defer cancel()
+	var response *clientv3.MemberPromoteResponse
+	err := wait.ExponentialBackoff(e.BackoffParams, func() (bool, error) {
+		resp, err := e.EtcdClient.MemberPromote(ctx, id)
+		if err != nil {
+			Log.Info("failed to promote etcd member", "etcd client error", err)
+			return false, nil
+		}
+		response = resp
+		return true, nil
+	})
+	return response, err
+}
+
 // MoveLeader calls MoveLeader on the etcd client with a backoff retry.
 func (e *EtcdBackoffAdapter) MoveLeader(ctx context.Context, id uint64) (*clientv3.MoveLeaderResponse, error) {
 	ctx, cancel := context.WithTimeout(ctx, e.Timeout)
@@ -167,3 +211,72 @@ func (e *EtcdBackoffAdapter) MoveLeader(ctx context.Context, id uint64) (*client
 	})
 	return response, err
 }
+
+// Snapshot calls Snapshot on the etcd client.
+// It is neither retried nor bound by the adapter timeout, because the snapshot is streamed after Snapshot returns
+// and a stream that failed part way through cannot be resumed.
+func (e *EtcdBackoffAdapter) Snapshot(ctx context.Context) (io.ReadCloser, error) {
+	return e.EtcdClient.Snapshot(ctx)
+}
+
+// Status calls Status on the etcd client with a backoff retry.
+func (e *EtcdBackoffAdapter) Status(ctx context.Context, endpoint string) (*clientv3.StatusResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.Timeout)
+	defer cancel()
+	var response *clientv3.StatusResponse
+	err := wait.ExponentialBackoff(e.BackoffParams, func() (bool, error) {
+		resp, err := e.EtcdClient.Status(ctx, endpoint)
+		if err != nil {
+			Log.Info("failed to get etcd member status", "etcd client error", err)
+			return false, nil
+		}
+		response = resp
+		return true, nil
+	})
+	return response, err
+}
+
+// Compact calls Compact on the etcd client with a backoff retry.
+func (e *EtcdBackoffAdapter) Compact(ctx context.Context, rev int64, opts ...clientv3.CompactOption) (*clientv3.CompactResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.Timeout)
+	defer cancel()
+	var response *clientv3.CompactResponse
+	err := wait.ExponentialBackoff(e.BackoffParams, func() (bool, error) {
+		resp, err := e.EtcdClient.Compact(ctx, rev, opts...)
+		if err != nil {
+			Log.Info("failed to compact etcd", "etcd client error", err)
+			return false, nil
+		}
+		response = resp
+		return true, nil
+	})
+	return response, err
+}
+
+// Defragment calls Defragment on the etcd client.
+// It is not bound by the adapter timeout, because defragmenting a large database can take much longer,
+// nor retried, because the member does not serve requests until it is done.
+func (e *EtcdBackoffAdapter) Defragment(ctx context.Context, endpoint string) (*clientv3.DefragmentResponse, error) {
+	return e.EtcdClient.Defragment(ctx, endpoint)
+}
+
+// Get calls Get on the etcd client with a backoff retry.
+func (e *EtcdBackoffAdapter) Get(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.Timeout)
+	defer cancel()
+	var response *clientv3.GetResponse
+	err := wait.ExponentialBackoff(e.BackoffParams, func() (bool, error) {
+		resp, err := e.EtcdClient.Get(ctx, key, opts...)
+		if err == rpctypes.ErrCompacted {
+			// Reading a compacted revision fails the same way on every attempt.
+			return false, err
+		}
+		if err != nil {
+			Log.Info("failed to get etcd key", "etcd client error", err)
+			return false, nil
+		}
+		response = resp
+		return true, nil
+	})
+	return response, err
+}
@@ -19,27 +19,43 @@ package etcd
 import (
 	"context"
 	"crypto/tls"
+	"fmt"
+	"io"
 	"net"
+	"time"
 
 	"github.com/pkg/errors"
 	"go.etcd.io/etcd/clientv3"
+	"go.etcd.io/etcd/etcdserver/api/v3rpc/rpctypes"
 	"go.etcd.io/etcd/etcdserver/etcdserverpb"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
 )
 
+// compactionProbeKey is the key read at historical revisions to find out whether they have been compacted.
+const compactionProbeKey = "/cluster-api.x-k8s.io/compaction-probe"
+
 // GRPCDial is a function that creates a connection to a given endpoint.
 type GRPCDial func(ctx context.Context, addr string) (net.Conn, error)
 
 // etcd wraps the etcd client from etcd's clientv3 package.
 // This interface is implemented by both the clientv3 package and the backoff adapter that adds retries to the client.
 type etcd interface {
+	AlarmDisarm(ctx context.Context, m *clientv3.AlarmMember) (*clientv3.AlarmResponse, error)
 	AlarmList(ctx context.Context) (*clientv3.AlarmResponse, error)
 	Close() error
+	Compact(ctx context.Context, rev int64, opts ...clientv3.CompactOption) (*clientv3.CompactResponse, error)
+	Defragment(ctx context.Context, endpoint string) (*clientv3.DefragmentResponse, error)
 	Endpoints() []string
+	Get(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error)
+	MemberAddAsLearner(ctx context.Context, peerAddrs []string) (*clientv3.MemberAddResponse, error)
 	MemberList(ctx context.Context) (*clientv3.MemberListResponse, error)
+	MemberPromote(ctx context.Context, id uint64) (*clientv3.MemberPromoteResponse, error)
 	MemberRemove(ctx context.Context, id uint64) (*clientv3.MemberRemoveResponse, error)
 	MemberUpdate(ctx context.Context, id uint64, peerURLs []string) (*clientv3.MemberUpdateResponse, error)
 	MoveLeader(ctx context.Context, id uint64) (*clientv3.MoveLeaderResponse, error)
+	Snapshot(ctx context.Context) (io.ReadCloser, error)
+	Status(ctx context.Context, endpoint string) (*clientv3.StatusResponse, error)
 }
 
 // Client wraps an etcd client formatting its output to something more consumable.
@@ -70,6 +86,20 @@ const (
 	AlarmCorrupt
 )
 
+// String returns the name etcd uses for the alarm type.
+func (a AlarmType) String() string {
+	switch a {
+	case AlarmOk:
+		return "NONE"
+	case AlarmNoSpace:
+		return "NOSPACE"
+	case AlarmCorrupt:
+		return "CORRUPT"
+	default:
+		return fmt.Sprintf("UNKNOWN(%d)", int32(a))
+	}
+}
+
 // Adapted from kubeadm
 
 // Member struct defines an etcd member; it is used to avoid spreading
@@ -97,6 +127,36 @@ type Member struct {
 	Alarms []AlarmType
 }
 
+// MemberStatus is the status reported by the etcd member a client is connected to.
+type MemberStatus struct {
+	// MemberID is the ID of the member that reported the status.
+	MemberID uint64
+
+	// Version is the cluster protocol version used by the member.
+	Version string
+
+	// Leader is the ID of the member that this member believes is the current leader.
+	Leader uint64
+
+	// Revision is the current revision of the member's keyspace.
+	Revision int64
+
+	// RaftIndex is the current raft committed index of the member.
+	RaftIndex uint64
+
+	// RaftTerm is the current raft term of the member.
+	RaftTerm uint64
+
+	// DBSize is the size of the member's backend database physically allocated, in bytes.
+	DBSize int64
+
+	// DBSizeInUse is the size of the member's backend database logically in use, in bytes.
+	DBSizeInUse int64
+
+	// IsLearner indicates if the member is raft learner.
+	IsLearner bool
+}
+
 // pbMemberToMember converts the protobuf representation of a cluster member to a Member struct.
 func pbMemberToMember(m *etcdserverpb.Member) *Member {
 	return &Member{
@@ -109,9 +169,35 @@ func pbMemberToMember(m *etcdserverpb.Member) *Member {
 	}
 }
 
+// EtcdClientOption defines the option type for the clients created by NewEtcdClient.
+type EtcdClientOption func(*clientv3.Config)
+
+// WithDialKeepAlive configures the client to ping the server every keepAliveTime and to close the
+// connection if a ping is not answered within keepAliveTimeout.
+func WithDialKeepAlive(keepAliveTime, keepAliveTimeout time.Duration) EtcdClientOption {
+	return func(c *clientv3.Config) {
+		c.DialKeepAliveTime = keepAliveTime
+		c.DialKeepAliveTimeout = keepAliveTimeout
+	}
+}
+
+// WithDialTimeout configures how long the client waits for the connection to the server to be established.
+func WithDialTimeout(timeout time.Duration) EtcdClientOption {
+	return func(c *clientv3.Config) {
+		c.DialTimeout = timeout
+	}
+}
+
+// WithMaxCallRecvMsgSize configures the maximum size in bytes of a response the client accepts.
+func WithMaxCallRecvMsgSize(size int) EtcdClientOption {
+	return func(c *clientv3.Config) {
+		c.MaxCallRecvMsgSize = size
+	}
+}
+
 // NewEtcdClient creates a new etcd client with a custom dialer and is configuration with optional functions.
-func NewEtcdClient(endpoint string, dialer GRPCDial, tlsConfig *tls.Config) (*clientv3.Client, error) {
-	etcdClient, err := clientv3.New(clientv3.Config{
+func NewEtcdClient(endpoint string, dialer GRPCDial, tlsConfig *tls.Config, options ...EtcdClientOption) (*clientv3.Client, error) {
+	config := clientv3.Config{
 		Endpoints:   []string{endpoint},
 		DialTimeout: etcdTimeout,
 		DialOptions: []grpc.DialOption{
@@ -119,7 +205,11 @@ func NewEtcdClient(endpoint string, dialer GRPCDial, tlsConfig *tls.Config) (*cl
 			grpc.WithContextDialer(dialer),
 		},
 		TLS: tlsConfig,
-	})
+	}
+	for _, option := range options {
+		option(&config)
+	}
+	etcdClient, err := clientv3.New(config)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to create etcd client")
 	}
@@ -143,6 +233,49 @@ func (c *Client) Close() error {
 	return c.EtcdClient.Close()
 }
 
+// sharedEtcd is an etcd client whose connection is shared with other clients.
+// Closing it calls release instead of closing the connection.
+type sharedEtcd struct {
+	etcd
+	release func() error
+}
+
+func (s *sharedEtcd) Close() error {
+	return s.release()
+}
+
+// Share returns a client that uses the same connection as c, but calls release instead of closing the connection
+// when it is closed. This allows a connection to be reused by callers that close their client once they are done.
+func (c *Client) Share(release func() error) *Client {
+	return &Client{
+		EtcdClient: &sharedEtcd{etcd: c.EtcdClient, release: release},
+		Endpoint:   c.Endpoint,
+	}
+}
+
+// IsConnected reports whether the connection of the client can still be used, i.e. it is neither shut down nor
+// failing to reconnect. Clients that do not expose their connection, such as fakes, are always connected.
+func (c *Client) IsConnected() bool {
+	etcdClient := c.EtcdClient
+	if shared, ok := etcdClient.(*sharedEtcd); ok {
+		etcdClient = shared.etcd
+	}
+	withConnection, ok := etcdClient.(interface{ ActiveConnection() *grpc.ClientConn })
+	if !ok {
+		return true
+	}
+	conn := withConnection.ActiveConnection()
+	if conn == nil {
+		return false
+	}
+	switch conn.GetState() {
+	case connectivity.TransientFailure, connectivity.Shutdown:
+		return false
+	default:
+		return true
+	}
+}
+
 // Members retrieves a list of etcd members.
 func (c *Client) Members(ctx context.Context) ([]*Member, error) {
 	response, err := c.EtcdClient.MemberList(ctx)
@@ -183,6 +316,21 @@ func (c *Client) RemoveMember(ctx context.Context, id uint64) error {
 	return errors.Wrapf(err, "failed to remove member: %v", id)
 }
 
+// AddMemberAsLearner adds a learner member with the given peer URL and returns it.
+func (c *Client) AddMemberAsLearner(ctx context.Context, peerURL string) (*Member, error) {
+	response, err := c.EtcdClient.MemberAddAsLearner(ctx, []string{peerURL})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to add learner member with peer URL %s", peerURL)
+	}
+	return pbMemberToMember(response.Member), nil
+}
+
+// PromoteMember promotes a given learner member to a voting member.
+func (c *Client) PromoteMember(ctx context.Context, id uint64) error {
+	_, err := c.EtcdClient.MemberPromote(ctx, id)
+	return errors.Wrapf(err, "failed to promote member: %v", id)
+}
+
 // UpdateMemberPeerList updates the list of peer URLs
 func (c *Client) UpdateMemberPeerURLs(ctx context.Context, id uint64, peerURLs []string) ([]*Member, error) {
 	response, err := c.EtcdClient.MemberUpdate(ctx, id, peerURLs)
@@ -198,6 +346,93 @@ func (c *Client) UpdateMemberPeerURLs(ctx context.Context, id uint64, peerURLs [
 	return members, nil
 }
 
+// Status retrieves the status of the member the client is connected to.
+func (c *Client) Status(ctx context.Context) (*MemberStatus, error) {
+	response, err := c.EtcdClient.Status(ctx, c.Endpoint)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get etcd member status")
+	}
+
+	return &MemberStatus{
+		MemberID:    response.Header.GetMemberId(),
+		Version:     response.Version,
+		Leader:      response.Leader,
+		Revision:    response.Header.GetRevision(),
+		RaftIndex:   response.RaftIndex,
+		RaftTerm:    response.RaftTerm,
+		DBSize:      response.DbSize,
+		DBSizeInUse: response.DbSizeInUse,
+		IsLearner:   response.IsLearner,
+	}, nil
+}
+
+// Compact compacts the keyspace of the etcd cluster up to the given revision.
+// Compaction is replicated through consensus, so it applies to every member.
+func (c *Client) Compact(ctx context.Context, revision int64) error {
+	_, err := c.EtcdClient.Compact(ctx, revision, clientv3.WithCompactPhysical())
+	return errors.Wrapf(err, "failed to compact etcd to revision %d", revision)
+}
+
+// Defragment releases the free space of the backend database of the member the client is connected to.
+// The member does not serve requests while it is defragmented.
+func (c *Client) Defragment(ctx context.Context) error {
+	_, err := c.EtcdClient.Defragment(ctx, c.Endpoint)
+	return errors.Wrap(err, "failed to defragment etcd member")
+}
+
+// Snapshot streams a snapshot of the backend database of the member the client is connected to.
+// The caller is responsible for closing the returned reader.
+func (c *Client) Snapshot(ctx context.Context) (io.ReadCloser, error) {
+	snapshot, err := c.EtcdClient.Snapshot(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to snapshot etcd")
+	}
+	return snapshot, nil
+}
+
+// CompactedRevision returns the revision the member the client is connected to was last compacted at,
+// or 0 if it has never been compacted. etcd does not report the compacted revision directly, so it is found
+// by searching for the oldest revision up to currentRevision that can still be read.
+func (c *Client) CompactedRevision(ctx context.Context, currentRevision int64) (int64, error) {
+	readable := func(revision int64) (bool, error) {
+		_, err := c.EtcdClient.Get(ctx, compactionProbeKey, clientv3.WithRev(revision), clientv3.WithSerializable(), clientv3.WithCountOnly())
+		if err == rpctypes.ErrCompacted {
+			return false, nil
+		}
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to read etcd revision %d", revision)
+		}
+		return true, nil
+	}
+
+	if currentRevision < 1 {
+		return 0, nil
+	}
+	oldest, err := readable(1)
+	if err != nil {
+		return 0, err
+	}
+	if oldest {
+		return 0, nil
+	}
+
+	// The first revision is not readable but the current one always is.
+	low, high := int64(1), currentRevision
+	for high-low > 1 {
+		mid := low + (high-low)/2
+		ok, err := readable(mid)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			high = mid
+		} else {
+			low = mid
+		}
+	}
+	return high, nil
+}
+
 // Alarms retrieves all alarms on a cluster.
 func (c *Client) Alarms(ctx context.Context) ([]MemberAlarm, error) {
 	alarmResponse, err := c.EtcdClient.AlarmList(ctx)
@@ -215,3 +450,12 @@ func (c *Client) Alarms(ctx context.Context) ([]MemberAlarm, error) {
 
 	return memberAlarms, nil
 }
+
+// DisarmAlarm clears the given alarm. Members raise it again if its cause has not been addressed.
+func (c *Client) DisarmAlarm(ctx context.Context, alarm MemberAlarm) error {
+	_, err := c.EtcdClient.AlarmDisarm(ctx, &clientv3.AlarmMember{
+		MemberID: alarm.MemberID,
+		Alarm:    etcdserverpb.AlarmType(alarm.Type),
+	})
+	return errors.Wrapf(err, "failed to disarm %s alarm of etcd member %x", alarm.Type, alarm.MemberID)
+}
@@ -132,7 +132,7 @@ func (c *FakeEtcdClient) ClearAlarm(alarmType etcd.AlarmType, memberID uint64) e
 		}
 	}
 	if indexToDelete >= 0 {
-		c.alarms = append(c.alarms[:indexToDelete], c.alarms[indexToDelete:]...)
+		c.alarms = append(c.alarms[:indexToDelete], c.alarms[indexToDelete+1:]...)
 	}
 	return nil
 }
@@ -172,6 +172,18 @@ func (c *FakeEtcdClient) RemoveMember(ctx context.Context, memberID uint64) erro
 	return nil
 }
 
+func (c *FakeEtcdClient) PromoteMember(ctx context.Context, memberID uint64) error {
+	m, ok := c.members[memberID]
+	if !ok {
+		return fmt.Errorf("no member with ID %d", memberID)
+	}
+	if !m.IsLearner {
+		return fmt.Errorf("member with ID %d is not a learner", memberID)
+	}
+	m.IsLearner = false
+	return nil
+}
+
 func (c *FakeEtcdClient) UpdateMemberPeerURLs(ctx context.Context, memberID uint64, peerURLs []string) ([]*etcd.Member, error) {
 	m, ok := c.members[memberID]
 	if !ok {
@@ -188,3 +200,11 @@ func (c *FakeEtcdClient) Alarms(ctx context.Context) ([]etcd.MemberAlarm, error)
 	}
 	return alarms, nil
 }
+
+func (c *FakeEtcdClient) DisarmAlarm(ctx context.Context, alarm etcd.MemberAlarm) error {
+	return c.ClearAlarm(alarm.Type, alarm.MemberID)
+}
+
+func (c *FakeEtcdClient) Defragment(ctx context.Context) error {
+	return nil
+}
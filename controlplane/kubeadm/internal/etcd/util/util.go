@@ -38,3 +38,13 @@ func MemberIDSet(members []*etcd.Member) UInt64Set {
 	}
 	return set
 }
+
+// MemberNamesByID returns the names of the members keyed by member ID.
+// Members that have not started yet have no name.
+func MemberNamesByID(members []*etcd.Member) map[uint64]string {
+	names := make(map[uint64]string, len(members))
+	for _, m := range members {
+		names[m.ID] = m.Name
+	}
+	return names
+}
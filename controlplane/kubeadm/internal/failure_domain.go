@@ -39,7 +39,11 @@ func (f failureDomainAggregations) Len() int {
 
 // Less reports whether the element with
 // index i should sort before the element with index j.
+// Failure domains with the same number of machines are ordered by ID, so the picks are stable.
 func (f failureDomainAggregations) Less(i, j int) bool {
+	if f[i].count == f[j].count {
+		return f[i].id < f[j].id
+	}
 	return f[i].count < f[j].count
 }
 
@@ -69,6 +73,21 @@ func PickFewest(failureDomains clusterv1.FailureDomains, machines []*clusterv1.M
 	return aggregations[0].id
 }
 
+// CountMachines returns the number of machines in each of the given failure domains, including the failure domains
+// without machines. Machines without a failure domain or in an unknown failure domain are not counted.
+// It returns nil if there are no failure domains.
+func CountMachines(failureDomains clusterv1.FailureDomains, machines []*clusterv1.Machine) map[string]int32 {
+	aggregations := pick(failureDomains, machines)
+	if len(aggregations) == 0 {
+		return nil
+	}
+	counts := make(map[string]int32, len(aggregations))
+	for _, aggregation := range aggregations {
+		counts[aggregation.id] = int32(aggregation.count)
+	}
+	return counts
+}
+
 func pick(failureDomains clusterv1.FailureDomains, machines []*clusterv1.Machine) failureDomainAggregations {
 	if len(failureDomains) == 0 {
 		return failureDomainAggregations{}
@@ -17,6 +17,7 @@ limitations under the License.
 package internal
 
 import (
+	"reflect"
 	"testing"
 
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
@@ -102,3 +103,80 @@ func TestNewFailureDomainPicker(t *testing.T) {
 		})
 	}
 }
+
+func TestPickMost(t *testing.T) {
+	a := "us-west-1a"
+	b := "us-west-1b"
+	c := "us-west-1c"
+
+	fds := clusterv1.FailureDomains{
+		a: clusterv1.FailureDomainSpec{},
+		b: clusterv1.FailureDomainSpec{},
+	}
+	machinea := &clusterv1.Machine{Spec: clusterv1.MachineSpec{FailureDomain: &a}}
+	machineb := &clusterv1.Machine{Spec: clusterv1.MachineSpec{FailureDomain: &b}}
+	machinec := &clusterv1.Machine{Spec: clusterv1.MachineSpec{FailureDomain: &c}}
+
+	testcases := []struct {
+		name     string
+		fds      clusterv1.FailureDomains
+		machines []*clusterv1.Machine
+		expected string
+	}{
+		{
+			name:     "no failure domains",
+			machines: []*clusterv1.Machine{machinea},
+			expected: "",
+		},
+		{
+			name:     "failure domain with the most machines",
+			fds:      fds,
+			machines: []*clusterv1.Machine{machinea, machineb, machineb},
+			expected: b,
+		},
+		{
+			name:     "machines in unknown failure domains are not counted",
+			fds:      fds,
+			machines: []*clusterv1.Machine{machinea, machinec, machinec},
+			expected: a,
+		},
+		{
+			name:     "ties are broken by failure domain ID",
+			fds:      fds,
+			machines: []*clusterv1.Machine{machinea, machineb},
+			expected: b,
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			if fd := PickMost(tc.fds, tc.machines); fd != tc.expected {
+				t.Fatalf("expected %q but got %q", tc.expected, fd)
+			}
+		})
+	}
+}
+
+func TestCountMachines(t *testing.T) {
+	a := "us-west-1a"
+	b := "us-west-1b"
+	c := "us-west-1c"
+
+	fds := clusterv1.FailureDomains{
+		a: clusterv1.FailureDomainSpec{},
+		b: clusterv1.FailureDomainSpec{},
+	}
+	machines := []*clusterv1.Machine{
+		{Spec: clusterv1.MachineSpec{FailureDomain: &a}},
+		{Spec: clusterv1.MachineSpec{FailureDomain: &a}},
+		{Spec: clusterv1.MachineSpec{FailureDomain: &c}},
+		{Spec: clusterv1.MachineSpec{}},
+	}
+
+	if counts := CountMachines(nil, machines); counts != nil {
+		t.Fatalf("expected no counts without failure domains but got %v", counts)
+	}
+	expected := map[string]int32{a: 2, b: 0}
+	if counts := CountMachines(fds, machines); !reflect.DeepEqual(counts, expected) {
+		t.Fatalf("expected %v but got %v", expected, counts)
+	}
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fake implements in-memory management and workload clusters, so that the kubeadm control plane controller
+// can be unit tested without a workload cluster to talk to.
+package fake
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/types"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	kubeadmv1beta1 "sigs.k8s.io/cluster-api/bootstrap/kubeadm/types/v1beta1"
+	"sigs.k8s.io/cluster-api/controlplane/kubeadm/internal"
+)
+
+// ManagementCluster is a fake internal.ManagementCluster that records the operations run against it.
+type ManagementCluster struct {
+	ControlPlaneHealthy bool
+	EtcdHealthy         bool
+	Machines            []*clusterv1.Machine
+	// CertificatesExpiries is when the certificates of the control plane Machines expire, by Machine name.
+	CertificatesExpiries map[string]time.Time
+	// Workload is the workload cluster returned for every Cluster. GetWorkloadCluster fails if it is nil.
+	Workload *WorkloadCluster
+}
+
+var _ internal.ManagementCluster = &ManagementCluster{}
+
+// GetMachinesForCluster implements internal.ManagementCluster. It returns the Machines that pass all the filters.
+func (f *ManagementCluster) GetMachinesForCluster(ctx context.Context, cluster types.NamespacedName, filters ...func(machine *clusterv1.Machine) bool) ([]*clusterv1.Machine, error) {
+	return internal.FilterMachines(f.Machines, filters...), nil
+}
+
+// GetWorkloadCluster implements internal.ManagementCluster.
+func (f *ManagementCluster) GetWorkloadCluster(ctx context.Context, clusterKey types.NamespacedName) (internal.WorkloadCluster, error) {
+	if f.Workload == nil {
+		return nil, errors.Errorf("no workload cluster for Cluster %s/%s", clusterKey.Namespace, clusterKey.Name)
+	}
+	return f.Workload, nil
+}
+
+// TargetClusterControlPlaneIsHealthy implements internal.ManagementCluster.
+func (f *ManagementCluster) TargetClusterControlPlaneIsHealthy(ctx context.Context, clusterKey types.NamespacedName, controlPlaneName string, excludeMachines ...types.NamespacedName) error {
+	if !f.ControlPlaneHealthy {
+		return errors.New("control plane is not healthy")
+	}
+	return nil
+}
+
+// TargetClusterEtcdIsHealthy implements internal.ManagementCluster.
+func (f *ManagementCluster) TargetClusterEtcdIsHealthy(ctx context.Context, clusterKey types.NamespacedName, controlPlaneName string, excludeMachines ...types.NamespacedName) error {
+	if !f.EtcdHealthy {
+		return errors.New("etcd is not healthy")
+	}
+	return nil
+}
+
+// GetMachineCertificatesExpiries implements internal.ManagementCluster.
+func (f *ManagementCluster) GetMachineCertificatesExpiries(ctx context.Context, clusterKey types.NamespacedName, machines []*clusterv1.Machine) (map[string]time.Time, error) {
+	expiries := map[string]time.Time{}
+	for _, machine := range machines {
+		if expiry, ok := f.CertificatesExpiries[machine.Name]; ok {
+			expiries[machine.Name] = expiry
+		}
+	}
+	return expiries, nil
+}
+
+// WorkloadCluster is a fake internal.WorkloadCluster that records the operations run against it.
+type WorkloadCluster struct {
+	// KubeadmConfigMapVersion is the Kubernetes version last set in the kubeadm-config ConfigMap.
+	KubeadmConfigMapVersion string
+	// KubeProxyVersion is the Kubernetes version last set in the kube-proxy image.
+	KubeProxyVersion string
+	// CoreDNSImageTag is the CoreDNS image tag last requested.
+	CoreDNSImageTag string
+	// UnsafeEtcdMemberRemoval makes removing any etcd member lose quorum.
+	UnsafeEtcdMemberRemoval bool
+	// EtcdLeaderForwards are the nodes the etcd leadership was moved away from.
+	EtcdLeaderForwards []string
+	// RemovedEtcdMembers are the nodes whose etcd member was removed.
+	RemovedEtcdMembers []string
+	// EtcdNoSpaceAlarms are the NOSPACE alarms raised in etcd, which are disarmed when recovered from.
+	EtcdNoSpaceAlarms []internal.EtcdAlarm
+	// EtcdDefragmentations is the number of times etcd was defragmented.
+	EtcdDefragmentations int
+	// EtcdSnapshot is the content of the etcd snapshots taken.
+	EtcdSnapshot string
+}
+
+var _ internal.WorkloadCluster = &WorkloadCluster{}
+
+// UpdateKubeadmConfigMap implements internal.WorkloadCluster.
+func (f *WorkloadCluster) UpdateKubeadmConfigMap(ctx context.Context, version string, clusterConfiguration *kubeadmv1beta1.ClusterConfiguration) error {
+	f.KubeadmConfigMapVersion = version
+	return nil
+}
+
+// UpdateKubeProxyImage implements internal.WorkloadCluster.
+func (f *WorkloadCluster) UpdateKubeProxyImage(ctx context.Context, version string) error {
+	f.KubeProxyVersion = version
+	return nil
+}
+
+// UpdateCoreDNS implements internal.WorkloadCluster.
+func (f *WorkloadCluster) UpdateCoreDNS(ctx context.Context, clusterConfiguration *kubeadmv1beta1.ClusterConfiguration) error {
+	if clusterConfiguration != nil {
+		f.CoreDNSImageTag = clusterConfiguration.DNS.ImageTag
+	}
+	return nil
+}
+
+// CanSafelyRemoveEtcdMember implements internal.WorkloadCluster.
+func (f *WorkloadCluster) CanSafelyRemoveEtcdMember(ctx context.Context, nodeName string) (bool, error) {
+	return !f.UnsafeEtcdMemberRemoval, nil
+}
+
+// ForwardEtcdLeadership implements internal.WorkloadCluster.
+func (f *WorkloadCluster) ForwardEtcdLeadership(ctx context.Context, nodeName string) error {
+	f.EtcdLeaderForwards = append(f.EtcdLeaderForwards, nodeName)
+	return nil
+}
+
+// RemoveEtcdMemberForNode implements internal.WorkloadCluster.
+func (f *WorkloadCluster) RemoveEtcdMemberForNode(ctx context.Context, nodeName string) error {
+	f.RemovedEtcdMembers = append(f.RemovedEtcdMembers, nodeName)
+	return nil
+}
+
+// RecoverEtcdNoSpaceAlarms implements internal.WorkloadCluster.
+func (f *WorkloadCluster) RecoverEtcdNoSpaceAlarms(ctx context.Context) ([]internal.EtcdAlarm, error) {
+	disarmed := f.EtcdNoSpaceAlarms
+	f.EtcdNoSpaceAlarms = nil
+	return disarmed, nil
+}
+
+// DefragmentEtcd implements internal.WorkloadCluster.
+func (f *WorkloadCluster) DefragmentEtcd(ctx context.Context) ([]string, error) {
+	f.EtcdDefragmentations++
+	return []string{"node-1"}, nil
+}
+
+// SnapshotEtcd implements internal.WorkloadCluster.
+func (f *WorkloadCluster) SnapshotEtcd(ctx context.Context, w io.Writer) error {
+	_, err := io.WriteString(w, f.EtcdSnapshot)
+	return err
+}
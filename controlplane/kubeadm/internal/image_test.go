@@ -0,0 +1,34 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internal
+
+import "testing"
+
+func TestSplitImage(t *testing.T) {
+	table := map[string][2]string{
+		"k8s.gcr.io/kube-apiserver:v1.17.3":                 {"k8s.gcr.io/kube-apiserver", "v1.17.3"},
+		"registry:5000/kube-apiserver:v1.17.3":              {"registry:5000/kube-apiserver", "v1.17.3"},
+		"registry:5000/kube-apiserver":                      {"registry:5000/kube-apiserver", ""},
+		"kube-apiserver":                                    {"kube-apiserver", ""},
+		"k8s.gcr.io/kube-apiserver:v1.17.3@sha256:deadbeef": {"k8s.gcr.io/kube-apiserver", "v1.17.3"},
+	}
+	for image, expected := range table {
+		if name, tag := splitImage(image); name != expected[0] || tag != expected[1] {
+			t.Fatalf("expected name %q and tag %q for %q but got %q and %q", expected[0], expected[1], image, name, tag)
+		}
+	}
+}
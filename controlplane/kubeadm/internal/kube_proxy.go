@@ -0,0 +1,74 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internal
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// kubeProxyKey is the name of the kube-proxy DaemonSet and of its container.
+const kubeProxyKey = "kube-proxy"
+
+// UpdateKubeProxyImage sets the tag of the kube-proxy image in the kube-proxy DaemonSet of the target cluster
+// to the given Kubernetes version. Clusters without a kube-proxy DaemonSet are left as they are.
+func (m *Management) UpdateKubeProxyImage(ctx context.Context, clusterKey types.NamespacedName, version string) error {
+	cluster, err := m.getCluster(ctx, clusterKey)
+	if err != nil {
+		return err
+	}
+	return cluster.updateKubeProxyImage(ctx, version)
+}
+
+func (c *cluster) updateKubeProxyImage(ctx context.Context, version string) error {
+	daemonSet := &appsv1.DaemonSet{}
+	daemonSetKey := types.NamespacedName{Namespace: metav1.NamespaceSystem, Name: kubeProxyKey}
+	if err := c.client.Get(ctx, daemonSetKey, daemonSet); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "failed to get %s/%s DaemonSet", daemonSetKey.Namespace, daemonSetKey.Name)
+	}
+
+	// kube-proxy images are tagged with the Kubernetes version, always prefixed with a v.
+	tag := "v" + strings.TrimPrefix(version, "v")
+	updated := false
+	containers := daemonSet.Spec.Template.Spec.Containers
+	for i := range containers {
+		if containers[i].Name != kubeProxyKey {
+			continue
+		}
+		if image := imageWithTag(containers[i].Image, tag); image != containers[i].Image {
+			containers[i].Image = image
+			updated = true
+		}
+	}
+	if !updated {
+		return nil
+	}
+
+	if err := c.client.Update(ctx, daemonSet); err != nil {
+		return errors.Wrapf(err, "failed to update %s/%s DaemonSet", daemonSetKey.Namespace, daemonSetKey.Name)
+	}
+	return nil
+}
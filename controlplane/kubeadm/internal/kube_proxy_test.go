@@ -0,0 +1,106 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internal
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestUpdateKubeProxyImage(t *testing.T) {
+	clusterKey := types.NamespacedName{Namespace: "my-namespace", Name: "my-cluster"}
+	kubeProxy := func(image string) *appsv1.DaemonSet {
+		daemonSet := &appsv1.DaemonSet{}
+		daemonSet.Spec.Template.Spec.Containers = []corev1.Container{
+			{Name: "kube-proxy", Image: image},
+			{Name: "sidecar", Image: "sidecar:v1.0.0"},
+		}
+		return daemonSet
+	}
+
+	table := []struct {
+		name          string
+		daemonSet     interface{}
+		version       string
+		expectedImage string
+	}{
+		{
+			name:          "updates the tag",
+			daemonSet:     kubeProxy("k8s.gcr.io/kube-proxy:v1.16.1"),
+			version:       "v1.17.2",
+			expectedImage: "k8s.gcr.io/kube-proxy:v1.17.2",
+		},
+		{
+			name:          "adds the v prefix to the version",
+			daemonSet:     kubeProxy("k8s.gcr.io/kube-proxy:v1.16.1"),
+			version:       "1.17.2",
+			expectedImage: "k8s.gcr.io/kube-proxy:v1.17.2",
+		},
+		{
+			name:          "keeps the port of the registry",
+			daemonSet:     kubeProxy("registry.example.com:5000/kube-proxy"),
+			version:       "v1.17.2",
+			expectedImage: "registry.example.com:5000/kube-proxy:v1.17.2",
+		},
+		{
+			name:          "replaces a digest",
+			daemonSet:     kubeProxy("k8s.gcr.io/kube-proxy@sha256:0123456789abcdef"),
+			version:       "v1.17.2",
+			expectedImage: "k8s.gcr.io/kube-proxy:v1.17.2",
+		},
+		{
+			name:      "does not update an up to date image",
+			daemonSet: kubeProxy("k8s.gcr.io/kube-proxy:v1.17.2"),
+			version:   "v1.17.2",
+		},
+		{
+			name:      "no kube-proxy DaemonSet",
+			daemonSet: nil,
+			version:   "v1.17.2",
+		},
+	}
+	for _, test := range table {
+		t.Run(test.name, func(t *testing.T) {
+			workloadClient := &fakeClient{get: map[string]interface{}{"kube-system/kube-proxy": test.daemonSet}}
+			m := managementClusterForTest(clusterKey, &cluster{client: workloadClient})
+
+			if err := m.UpdateKubeProxyImage(context.Background(), clusterKey, test.version); err != nil {
+				t.Fatal(err)
+			}
+			if test.expectedImage == "" {
+				if len(workloadClient.updated) != 0 {
+					t.Fatalf("expected no update but got %v", workloadClient.updated)
+				}
+				return
+			}
+			if len(workloadClient.updated) != 1 {
+				t.Fatalf("expected one update but got %d", len(workloadClient.updated))
+			}
+			containers := workloadClient.updated[0].(*appsv1.DaemonSet).Spec.Template.Spec.Containers
+			if containers[0].Image != test.expectedImage {
+				t.Fatalf("expected image %q but got %q", test.expectedImage, containers[0].Image)
+			}
+			if containers[1].Image != "sidecar:v1.0.0" {
+				t.Fatalf("expected the other containers to be kept but got image %q", containers[1].Image)
+			}
+		})
+	}
+}
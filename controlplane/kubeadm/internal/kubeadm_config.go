@@ -0,0 +1,161 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internal
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	kubeadmv1beta1 "sigs.k8s.io/cluster-api/bootstrap/kubeadm/types/v1beta1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// kubeadmConfigKey is the name of the ConfigMap kubeadm uploads its configuration to.
+	kubeadmConfigKey = "kubeadm-config"
+
+	// clusterConfigurationKey is the key of the ClusterConfiguration in the kubeadm-config ConfigMap.
+	clusterConfigurationKey = "ClusterConfiguration"
+)
+
+// ErrExternalEtcd is returned when an etcd operation is requested on a cluster that uses external etcd.
+var ErrExternalEtcd = errors.New("cluster uses external etcd")
+
+// GetStackedEtcdControlPlaneMachines returns the control plane machines owned by the named control plane that run
+// a stacked etcd member. If the cluster uses external etcd, no control plane machine runs an etcd member, and
+// an empty list is returned along with ErrExternalEtcd.
+func (m *Management) GetStackedEtcdControlPlaneMachines(ctx context.Context, clusterKey types.NamespacedName, controlPlaneName string) ([]*clusterv1.Machine, error) {
+	cluster, err := m.getCluster(ctx, clusterKey)
+	if err != nil {
+		return nil, err
+	}
+	clusterConfiguration, err := cluster.getClusterConfiguration(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if clusterConfiguration.Etcd.External != nil {
+		return []*clusterv1.Machine{}, ErrExternalEtcd
+	}
+	return m.GetMachinesForCluster(ctx, clusterKey, OwnedControlPlaneMachines(controlPlaneName))
+}
+
+// getClusterConfiguration returns the ClusterConfiguration kubeadm uploaded to the kubeadm-config ConfigMap.
+func (c *cluster) getClusterConfiguration(ctx context.Context) (*kubeadmv1beta1.ClusterConfiguration, error) {
+	configMap := &corev1.ConfigMap{}
+	configMapKey := types.NamespacedName{Namespace: metav1.NamespaceSystem, Name: kubeadmConfigKey}
+	if err := c.client.Get(ctx, configMapKey, configMap); err != nil {
+		return nil, errors.Wrapf(err, "failed to get %s/%s ConfigMap", configMapKey.Namespace, configMapKey.Name)
+	}
+	data, ok := configMap.Data[clusterConfigurationKey]
+	if !ok {
+		return nil, errors.Errorf("%s/%s ConfigMap has no %s", configMapKey.Namespace, configMapKey.Name, clusterConfigurationKey)
+	}
+	clusterConfiguration := &kubeadmv1beta1.ClusterConfiguration{}
+	if err := yaml.Unmarshal([]byte(data), clusterConfiguration); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode %s", clusterConfigurationKey)
+	}
+	return clusterConfiguration, nil
+}
+
+// UpdateKubeadmConfigMap updates the ClusterConfiguration in the kubeadm-config ConfigMap of the target cluster with
+// the given Kubernetes version and the image repositories and etcd image tag set in the given ClusterConfiguration,
+// so that machines joining the control plane use them. Fields the given ClusterConfiguration does not set are kept.
+func (m *Management) UpdateKubeadmConfigMap(ctx context.Context, clusterKey types.NamespacedName, version string, clusterConfiguration *kubeadmv1beta1.ClusterConfiguration) error {
+	cluster, err := m.getCluster(ctx, clusterKey)
+	if err != nil {
+		return err
+	}
+	return cluster.updateKubeadmConfigMap(ctx, version, clusterConfiguration)
+}
+
+func (c *cluster) updateKubeadmConfigMap(ctx context.Context, version string, clusterConfiguration *kubeadmv1beta1.ClusterConfiguration) error {
+	if clusterConfiguration == nil {
+		clusterConfiguration = &kubeadmv1beta1.ClusterConfiguration{}
+	}
+	return c.updateClusterConfiguration(ctx, func(config map[string]interface{}) error {
+		set := func(value string, path ...string) error {
+			if value == "" {
+				return nil
+			}
+			return unstructured.SetNestedField(config, value, path...)
+		}
+		if err := set(version, "kubernetesVersion"); err != nil {
+			return err
+		}
+		if err := set(clusterConfiguration.ImageRepository, "imageRepository"); err != nil {
+			return err
+		}
+		// The etcd image is only used by control plane machines running a stacked etcd member.
+		if local := clusterConfiguration.Etcd.Local; local != nil {
+			if _, external, _ := unstructured.NestedFieldNoCopy(config, "etcd", "external"); external {
+				return nil
+			}
+			if err := set(local.ImageRepository, "etcd", "local", "imageRepository"); err != nil {
+				return err
+			}
+			if err := set(local.ImageTag, "etcd", "local", "imageTag"); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// updateClusterConfiguration applies the given function to the ClusterConfiguration in the kubeadm-config ConfigMap,
+// and updates the ConfigMap if it changed. The ClusterConfiguration is decoded as a map, so that fields of newer
+// kubeadm API versions are preserved.
+func (c *cluster) updateClusterConfiguration(ctx context.Context, mutate func(map[string]interface{}) error) error {
+	configMap := &corev1.ConfigMap{}
+	configMapKey := types.NamespacedName{Namespace: metav1.NamespaceSystem, Name: kubeadmConfigKey}
+	if err := c.client.Get(ctx, configMapKey, configMap); err != nil {
+		return errors.Wrapf(err, "failed to get %s/%s ConfigMap", configMapKey.Namespace, configMapKey.Name)
+	}
+	data, ok := configMap.Data[clusterConfigurationKey]
+	if !ok {
+		return errors.Errorf("%s/%s ConfigMap has no %s", configMapKey.Namespace, configMapKey.Name, clusterConfigurationKey)
+	}
+	clusterConfiguration := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(data), &clusterConfiguration); err != nil {
+		return errors.Wrapf(err, "failed to decode %s", clusterConfigurationKey)
+	}
+	original, err := yaml.Marshal(clusterConfiguration)
+	if err != nil {
+		return errors.Wrapf(err, "failed to encode %s", clusterConfigurationKey)
+	}
+
+	if err := mutate(clusterConfiguration); err != nil {
+		return errors.Wrapf(err, "failed to update %s", clusterConfigurationKey)
+	}
+	updated, err := yaml.Marshal(clusterConfiguration)
+	if err != nil {
+		return errors.Wrapf(err, "failed to encode %s", clusterConfigurationKey)
+	}
+	if string(updated) == string(original) {
+		return nil
+	}
+
+	configMap.Data[clusterConfigurationKey] = string(updated)
+	if err := c.client.Update(ctx, configMap); err != nil {
+		return errors.Wrapf(err, "failed to update %s/%s ConfigMap", configMapKey.Namespace, configMapKey.Name)
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internal
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	kubeadmv1beta1 "sigs.k8s.io/cluster-api/bootstrap/kubeadm/types/v1beta1"
+)
+
+func TestGetStackedEtcdControlPlaneMachines(t *testing.T) {
+	clusterKey := types.NamespacedName{Namespace: "my-namespace", Name: "my-cluster"}
+	kubeadmConfig := func(clusterConfiguration string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{Data: map[string]string{clusterConfigurationKey: clusterConfiguration}}
+	}
+
+	table := []struct {
+		name             string
+		kubeadmConfig    interface{}
+		expectedMachines int
+		expectedErr      error
+		expectAnyErr     bool
+	}{
+		{
+			name: "stacked etcd",
+			kubeadmConfig: kubeadmConfig(`apiVersion: kubeadm.k8s.io/v1beta2
+kind: ClusterConfiguration
+etcd:
+  local:
+    dataDir: /var/lib/etcd
+`),
+			expectedMachines: 1,
+		},
+		{
+			name: "external etcd",
+			kubeadmConfig: kubeadmConfig(`apiVersion: kubeadm.k8s.io/v1beta2
+kind: ClusterConfiguration
+etcd:
+  external:
+    endpoints:
+    - https://etcd.example.com:2379
+`),
+			expectedMachines: 0,
+			expectedErr:      ErrExternalEtcd,
+			expectAnyErr:     true,
+		},
+		{
+			name:          "missing ClusterConfiguration",
+			kubeadmConfig: &corev1.ConfigMap{},
+			expectAnyErr:  true,
+		},
+		{
+			name:          "missing kubeadm-config",
+			kubeadmConfig: nil,
+			expectAnyErr:  true,
+		},
+	}
+	for _, test := range table {
+		t.Run(test.name, func(t *testing.T) {
+			workloadCluster := &cluster{
+				client: &fakeClient{get: map[string]interface{}{"kube-system/kubeadm-config": test.kubeadmConfig}},
+			}
+			m := managementClusterForTest(clusterKey, workloadCluster)
+			m.Client.(*fakeClient).list = machineListForTestGetMachinesForCluster()
+
+			machines, err := m.GetStackedEtcdControlPlaneMachines(context.Background(), clusterKey, "my-control-plane")
+			if test.expectAnyErr != (err != nil) {
+				t.Fatalf("expected error to be %t but got %v", test.expectAnyErr, err)
+			}
+			if test.expectedErr != nil && errors.Cause(err) != test.expectedErr {
+				t.Fatalf("expected error %v but got %v", test.expectedErr, err)
+			}
+			if len(machines) != test.expectedMachines {
+				t.Fatalf("expected %d machines but got %d", test.expectedMachines, len(machines))
+			}
+		})
+	}
+}
+
+func TestUpdateKubeadmConfigMap(t *testing.T) {
+	clusterKey := types.NamespacedName{Namespace: "my-namespace", Name: "my-cluster"}
+	kubeadmConfig := func(clusterConfiguration string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{Data: map[string]string{clusterConfigurationKey: clusterConfiguration}}
+	}
+	stackedEtcd := kubeadmConfig(`apiVersion: kubeadm.k8s.io/v1beta2
+kind: ClusterConfiguration
+kubernetesVersion: v1.16.1
+etcd:
+  local:
+    dataDir: /var/lib/etcd
+    imageTag: 3.3.15-0
+`)
+
+	table := []struct {
+		name                 string
+		kubeadmConfig        interface{}
+		version              string
+		clusterConfiguration *kubeadmv1beta1.ClusterConfiguration
+		expected             string
+		expectAnyErr         bool
+	}{
+		{
+			name:          "updates the Kubernetes version",
+			kubeadmConfig: stackedEtcd,
+			version:       "v1.17.2",
+			expected: `apiVersion: kubeadm.k8s.io/v1beta2
+etcd:
+  local:
+    dataDir: /var/lib/etcd
+    imageTag: 3.3.15-0
+kind: ClusterConfiguration
+kubernetesVersion: v1.17.2
+`,
+		},
+		{
+			name:          "updates the image repositories and the etcd image tag",
+			kubeadmConfig: stackedEtcd,
+			version:       "v1.16.1",
+			clusterConfiguration: &kubeadmv1beta1.ClusterConfiguration{
+				ImageRepository: "registry.example.com",
+				Etcd: kubeadmv1beta1.Etcd{Local: &kubeadmv1beta1.LocalEtcd{
+					ImageMeta: kubeadmv1beta1.ImageMeta{ImageRepository: "etcd.example.com", ImageTag: "3.4.3-0"},
+				}},
+			},
+			expected: `apiVersion: kubeadm.k8s.io/v1beta2
+etcd:
+  local:
+    dataDir: /var/lib/etcd
+    imageRepository: etcd.example.com
+    imageTag: 3.4.3-0
+imageRepository: registry.example.com
+kind: ClusterConfiguration
+kubernetesVersion: v1.16.1
+`,
+		},
+		{
+			name: "does not set the etcd image of external etcd",
+			kubeadmConfig: kubeadmConfig(`etcd:
+  external:
+    endpoints:
+    - https://etcd.example.com:2379
+kubernetesVersion: v1.17.2
+`),
+			version: "v1.17.2",
+			clusterConfiguration: &kubeadmv1beta1.ClusterConfiguration{
+				Etcd: kubeadmv1beta1.Etcd{Local: &kubeadmv1beta1.LocalEtcd{ImageMeta: kubeadmv1beta1.ImageMeta{ImageTag: "3.4.3-0"}}},
+			},
+		},
+		{
+			name:          "does not update an up to date ConfigMap",
+			kubeadmConfig: stackedEtcd,
+			version:       "v1.16.1",
+			clusterConfiguration: &kubeadmv1beta1.ClusterConfiguration{
+				Etcd: kubeadmv1beta1.Etcd{Local: &kubeadmv1beta1.LocalEtcd{ImageMeta: kubeadmv1beta1.ImageMeta{ImageTag: "3.3.15-0"}}},
+			},
+		},
+		{
+			name:          "missing ClusterConfiguration",
+			kubeadmConfig: &corev1.ConfigMap{},
+			version:       "v1.17.2",
+			expectAnyErr:  true,
+		},
+		{
+			name:          "missing kubeadm-config",
+			kubeadmConfig: nil,
+			version:       "v1.17.2",
+			expectAnyErr:  true,
+		},
+	}
+	for _, test := range table {
+		t.Run(test.name, func(t *testing.T) {
+			workloadClient := &fakeClient{get: map[string]interface{}{"kube-system/kubeadm-config": test.kubeadmConfig}}
+			m := managementClusterForTest(clusterKey, &cluster{client: workloadClient})
+
+			err := m.UpdateKubeadmConfigMap(context.Background(), clusterKey, test.version, test.clusterConfiguration)
+			if test.expectAnyErr != (err != nil) {
+				t.Fatalf("expected error to be %t but got %v", test.expectAnyErr, err)
+			}
+			if test.expected == "" {
+				if len(workloadClient.updated) != 0 {
+					t.Fatalf("expected no update but got %v", workloadClient.updated)
+				}
+				return
+			}
+			if len(workloadClient.updated) != 1 {
+				t.Fatalf("expected one update but got %d", len(workloadClient.updated))
+			}
+			if actual := workloadClient.updated[0].(*corev1.ConfigMap).Data[clusterConfigurationKey]; actual != test.expected {
+				t.Fatalf("expected ClusterConfiguration\n%s\nbut got\n%s", test.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internal
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// HealthCheckType identifies which target cluster health check produced an observation.
+type HealthCheckType string
+
+const (
+	// ControlPlaneHealthCheck is the check run by TargetClusterControlPlaneIsHealthy.
+	ControlPlaneHealthCheck HealthCheckType = "control-plane"
+
+	// EtcdHealthCheck is the check run by TargetClusterEtcdIsHealthy.
+	EtcdHealthCheck HealthCheckType = "etcd"
+)
+
+// HealthCheckObservation is a structured record of a single target cluster health check run.
+//
+// Sinks backed by Prometheus are expected to expose observations with the following metric names, all labeled with
+// "cluster" and "namespace":
+//
+//	capi_kcp_control_plane_nodes_healthy        gauge, HealthyNodes of the last control-plane check
+//	capi_kcp_control_plane_nodes_unhealthy      gauge, UnhealthyNodes of the last control-plane check
+//	capi_kcp_etcd_members                       gauge, EtcdMembers of the last etcd check
+//	capi_kcp_etcd_alarms                        gauge, EtcdAlarms of the last etcd check
+//	capi_kcp_etcd_member_mismatches_total       counter, etcd checks where EtcdMembers differs from ExpectedEtcdMembers
+//	capi_kcp_health_check_duration_seconds      histogram, Duration; additionally labeled with "check"
+//	capi_kcp_health_check_failures_total        counter, checks that returned an error; additionally labeled with "check"
+type HealthCheckObservation struct {
+	// Cluster is the target cluster the check ran against.
+	Cluster types.NamespacedName
+
+	// Check is the type of health check that ran.
+	Check HealthCheckType
+
+	// HealthyNodes is the number of nodes that passed the check.
+	HealthyNodes int
+
+	// UnhealthyNodes is the number of nodes that failed the check.
+	UnhealthyNodes int
+
+	// EtcdMembers is the number of etcd members seen during an etcd check. It is always zero for other checks.
+	EtcdMembers int
+
+	// EtcdAlarms is the number of etcd alarms seen during an etcd check. It is always zero for other checks.
+	EtcdAlarms int
+
+	// ExpectedEtcdMembers is the number of etcd members expected from the control plane nodes during a stacked etcd
+	// check. It is always zero for other checks, including checks of external etcd.
+	ExpectedEtcdMembers int
+
+	// Duration is how long the check took, including building the target cluster client.
+	Duration time.Duration
+
+	// Err is the error the check returned, if any.
+	Err error
+}
+
+// CertificatesExpiryObservation records when the kubeadm-generated certificates of a control plane machine expire.
+//
+// Sinks backed by Prometheus are expected to expose observations with the following metric name, labeled with
+// "cluster", "namespace" and "machine":
+//
+//	capi_kcp_machine_certificates_expiry_timestamp_seconds    gauge, Expiry as a Unix timestamp
+type CertificatesExpiryObservation struct {
+	// Cluster is the target cluster the machine belongs to.
+	Cluster types.NamespacedName
+
+	// Machine is the name of the control plane machine.
+	Machine string
+
+	// Expiry is when the certificates of the machine expire.
+	Expiry time.Time
+}
+
+// MetricsSink receives observations from the health checks and certificate expiry lookups run by ManagementCluster.
+// Implementations must be safe for concurrent use.
+type MetricsSink interface {
+	ObserveHealthCheck(observation HealthCheckObservation)
+	ObserveCertificatesExpiry(observation CertificatesExpiryObservation)
+}
+
+// noopMetricsSink discards every observation.
+type noopMetricsSink struct{}
+
+func (noopMetricsSink) ObserveHealthCheck(HealthCheckObservation) {}
+
+func (noopMetricsSink) ObserveCertificatesExpiry(CertificatesExpiryObservation) {}
+
+// countNodes returns the number of nodes that passed and failed a health check.
+func (h healthCheckResult) countNodes() (healthy, unhealthy int) {
+	for _, err := range h {
+		if err != nil {
+			unhealthy++
+			continue
+		}
+		healthy++
+	}
+	return healthy, unhealthy
+}
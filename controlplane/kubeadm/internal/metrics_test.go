@@ -0,0 +1,99 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+type fakeMetricsSink struct {
+	sync.Mutex
+	observations       []HealthCheckObservation
+	expiryObservations []CertificatesExpiryObservation
+}
+
+func (f *fakeMetricsSink) ObserveHealthCheck(observation HealthCheckObservation) {
+	f.Lock()
+	defer f.Unlock()
+	f.observations = append(f.observations, observation)
+}
+
+func (f *fakeMetricsSink) ObserveCertificatesExpiry(observation CertificatesExpiryObservation) {
+	f.Lock()
+	defer f.Unlock()
+	f.expiryObservations = append(f.expiryObservations, observation)
+}
+
+func TestHealthChecksRecordObservations(t *testing.T) {
+	clusterKey := types.NamespacedName{Namespace: "my-namespace", Name: "my-cluster"}
+	sink := &fakeMetricsSink{}
+	// The fake client cannot return the kubeconfig secret, so both checks fail while building the cluster client.
+	m := &Management{Client: &fakeClient{}, MetricsSink: sink}
+
+	if err := m.TargetClusterControlPlaneIsHealthy(context.Background(), clusterKey, "my-control-plane"); err == nil {
+		t.Fatal("expected the control plane health check to fail")
+	}
+	if err := m.TargetClusterEtcdIsHealthy(context.Background(), clusterKey, "my-control-plane"); err == nil {
+		t.Fatal("expected the etcd health check to fail")
+	}
+
+	if len(sink.observations) != 2 {
+		t.Fatalf("expected 2 observations but got %d", len(sink.observations))
+	}
+	for i, check := range []HealthCheckType{ControlPlaneHealthCheck, EtcdHealthCheck} {
+		observation := sink.observations[i]
+		if observation.Check != check {
+			t.Fatalf("expected observation %d to be for check %q but got %q", i, check, observation.Check)
+		}
+		if observation.Cluster != clusterKey {
+			t.Fatalf("expected observation %d to be for cluster %v but got %v", i, clusterKey, observation.Cluster)
+		}
+		if observation.Err == nil {
+			t.Fatalf("expected observation %d to record the check error", i)
+		}
+		if observation.Duration < 0 {
+			t.Fatalf("expected observation %d to have a non-negative duration", i)
+		}
+	}
+}
+
+func TestHealthChecksWithoutMetricsSink(t *testing.T) {
+	m := &Management{Client: &fakeClient{}}
+	if err := m.TargetClusterEtcdIsHealthy(context.Background(), types.NamespacedName{}, "my-control-plane"); err == nil {
+		t.Fatal("expected the etcd health check to fail")
+	}
+}
+
+func TestHealthCheckResultCountNodes(t *testing.T) {
+	result := healthCheckResult{
+		"first-control-plane":  nil,
+		"second-control-plane": errors.New("static pod is not ready"),
+		"third-control-plane":  nil,
+	}
+	healthy, unhealthy := result.countNodes()
+	if healthy != 2 {
+		t.Fatalf("expected 2 healthy nodes but got %d", healthy)
+	}
+	if unhealthy != 1 {
+		t.Fatalf("expected 1 unhealthy node but got %d", unhealthy)
+	}
+}
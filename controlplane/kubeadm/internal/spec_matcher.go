@@ -0,0 +1,187 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internal
+
+import (
+	"encoding/json"
+
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	bootstrapv1 "sigs.k8s.io/cluster-api/bootstrap/kubeadm/api/v1alpha3"
+	kubeadmv1 "sigs.k8s.io/cluster-api/bootstrap/kubeadm/types/v1beta1"
+	controlplanev1 "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/controlplane/kubeadm/internal/hash"
+)
+
+// MachineConfigs holds the objects control plane machines were created from, which are compared with the spec of
+// their KubeadmControlPlane to find out whether the machines need to be rolled out.
+type MachineConfigs struct {
+	// KubeadmConfigs are the bootstrap configs of the machines, keyed by machine name.
+	KubeadmConfigs map[string]*bootstrapv1.KubeadmConfig
+
+	// InfraMachines are the infrastructure machines of the machines, keyed by machine name.
+	InfraMachines map[string]*unstructured.Unstructured
+
+	// InfraTemplate is the current infrastructure template of the KubeadmControlPlane.
+	InfraTemplate *unstructured.Unstructured
+}
+
+// MatchesKCPConfiguration returns a MachineFilter function to find all machines whose Kubernetes version,
+// ClusterConfiguration, KubeadmConfig and infrastructure machine semantically match the spec of the given
+// KubeadmControlPlane. Fields filled in after the machine was created, such as the join discovery settings or
+// infrastructure machine fields that are not set in the template, are ignored.
+// Machines whose objects are not in the given configs, or that were created before their ClusterConfiguration
+// was recorded, are compared by configuration hash instead.
+func MatchesKCPConfiguration(kcp *controlplanev1.KubeadmControlPlane, configs MachineConfigs) func(machine *clusterv1.Machine) bool {
+	matchesHash := MatchesConfigurationHash(hash.Compute(&kcp.Spec))
+	matchesVersion := MatchesKubernetesVersion(kcp.Spec.Version)
+	return func(machine *clusterv1.Machine) bool {
+		if machine == nil {
+			return false
+		}
+		kubeadmConfig, hasKubeadmConfig := configs.KubeadmConfigs[machine.Name]
+		infraMachine, hasInfraMachine := configs.InfraMachines[machine.Name]
+		clusterConfiguration, hasClusterConfiguration := machine.Annotations[controlplanev1.KubeadmClusterConfigurationAnnotation]
+		if !hasKubeadmConfig || !hasInfraMachine || !hasClusterConfiguration || configs.InfraTemplate == nil {
+			return matchesHash(machine)
+		}
+		return matchesVersion(machine) &&
+			matchesClusterConfiguration(clusterConfiguration, kcp) &&
+			matchesKubeadmConfig(kubeadmConfig, kcp) &&
+			matchesInfraTemplate(infraMachine, configs.InfraTemplate)
+	}
+}
+
+// HasOutdatedKCPConfiguration returns a MachineFilter function to find all machines
+// that do not match the spec of the given KubeadmControlPlane, see MatchesKCPConfiguration.
+func HasOutdatedKCPConfiguration(kcp *controlplanev1.KubeadmControlPlane, configs MachineConfigs) func(machine *clusterv1.Machine) bool {
+	matches := MatchesKCPConfiguration(kcp, configs)
+	return func(machine *clusterv1.Machine) bool {
+		if machine == nil {
+			return false
+		}
+		return !matches(machine)
+	}
+}
+
+// matchesClusterConfiguration compares the JSON encoded ClusterConfiguration recorded on a machine
+// with the ClusterConfiguration of the KubeadmControlPlane.
+func matchesClusterConfiguration(recorded string, kcp *controlplanev1.KubeadmControlPlane) bool {
+	machineClusterConfiguration := &kubeadmv1.ClusterConfiguration{}
+	if err := json.Unmarshal([]byte(recorded), machineClusterConfiguration); err != nil {
+		return false
+	}
+	kcpClusterConfiguration := kcp.Spec.KubeadmConfigSpec.ClusterConfiguration
+	if kcpClusterConfiguration == nil {
+		kcpClusterConfiguration = &kubeadmv1.ClusterConfiguration{}
+	}
+	kcpClusterConfiguration = kcpClusterConfiguration.DeepCopy()
+	machineClusterConfiguration.TypeMeta = metav1.TypeMeta{}
+	kcpClusterConfiguration.TypeMeta = metav1.TypeMeta{}
+	return apiequality.Semantic.DeepEqual(machineClusterConfiguration, kcpClusterConfiguration)
+}
+
+// matchesKubeadmConfig compares the KubeadmConfig of a machine with the KubeadmConfigSpec of the KubeadmControlPlane,
+// apart from the ClusterConfiguration. Machines joining the control plane are compared on their JoinConfiguration
+// and the first machine on its InitConfiguration, as those are the only ones used to create them.
+func matchesKubeadmConfig(kubeadmConfig *bootstrapv1.KubeadmConfig, kcp *controlplanev1.KubeadmControlPlane) bool {
+	machineSpec := kubeadmConfig.Spec.DeepCopy()
+	kcpSpec := kcp.Spec.KubeadmConfigSpec.DeepCopy()
+
+	// The bootstrap provider fills in the ClusterConfiguration of the KubeadmConfig.
+	machineSpec.ClusterConfiguration = nil
+	kcpSpec.ClusterConfiguration = nil
+
+	if machineSpec.JoinConfiguration != nil {
+		machineSpec.InitConfiguration = nil
+		kcpSpec.InitConfiguration = nil
+		if kcpSpec.JoinConfiguration == nil {
+			kcpSpec.JoinConfiguration = &kubeadmv1.JoinConfiguration{}
+		}
+		for _, joinConfiguration := range []*kubeadmv1.JoinConfiguration{machineSpec.JoinConfiguration, kcpSpec.JoinConfiguration} {
+			// The bootstrap provider defaults these fields and generates the discovery settings.
+			joinConfiguration.TypeMeta = metav1.TypeMeta{}
+			joinConfiguration.Discovery = kubeadmv1.Discovery{}
+			if joinConfiguration.ControlPlane == nil {
+				joinConfiguration.ControlPlane = &kubeadmv1.JoinControlPlane{}
+			}
+		}
+	} else {
+		machineSpec.JoinConfiguration = nil
+		kcpSpec.JoinConfiguration = nil
+		if machineSpec.InitConfiguration == nil {
+			machineSpec.InitConfiguration = &kubeadmv1.InitConfiguration{}
+		}
+		if kcpSpec.InitConfiguration == nil {
+			kcpSpec.InitConfiguration = &kubeadmv1.InitConfiguration{}
+		}
+		// The bootstrap provider defaults the type meta of the InitConfiguration.
+		machineSpec.InitConfiguration.TypeMeta = metav1.TypeMeta{}
+		kcpSpec.InitConfiguration.TypeMeta = metav1.TypeMeta{}
+	}
+	return apiequality.Semantic.DeepEqual(machineSpec, kcpSpec)
+}
+
+// matchesInfraTemplate reports whether every field set in the spec of the infrastructure template
+// has the same value in the spec of the infrastructure machine. Fields that are only set on the
+// infrastructure machine, e.g. by its provider, are ignored.
+func matchesInfraTemplate(infraMachine, infraTemplate *unstructured.Unstructured) bool {
+	templateSpec, _, err := unstructured.NestedFieldNoCopy(infraTemplate.Object, "spec", "template", "spec")
+	if err != nil {
+		return false
+	}
+	machineSpec, _, err := unstructured.NestedFieldNoCopy(infraMachine.Object, "spec")
+	if err != nil {
+		return false
+	}
+	return isSubset(templateSpec, machineSpec)
+}
+
+// isSubset reports whether expected is semantically contained in actual: maps must contain every
+// key of expected with a matching value, lists must have the same length and matching items.
+func isSubset(expected, actual interface{}) bool {
+	switch expected := expected.(type) {
+	case nil:
+		return true
+	case map[string]interface{}:
+		actualMap, ok := actual.(map[string]interface{})
+		if !ok && actual != nil {
+			return false
+		}
+		for key, value := range expected {
+			if !isSubset(value, actualMap[key]) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		actual, ok := actual.([]interface{})
+		if !ok || len(actual) != len(expected) {
+			return false
+		}
+		for i := range expected {
+			if !isSubset(expected[i], actual[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return apiequality.Semantic.DeepEqual(expected, actual)
+	}
+}
@@ -0,0 +1,198 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internal
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	bootstrapv1 "sigs.k8s.io/cluster-api/bootstrap/kubeadm/api/v1alpha3"
+	kubeadmv1 "sigs.k8s.io/cluster-api/bootstrap/kubeadm/types/v1beta1"
+	controlplanev1 "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/controlplane/kubeadm/internal/hash"
+)
+
+func TestMatchesKCPConfiguration(t *testing.T) {
+	kcp := &controlplanev1.KubeadmControlPlane{
+		Spec: controlplanev1.KubeadmControlPlaneSpec{
+			Version: "v1.17.2",
+			KubeadmConfigSpec: bootstrapv1.KubeadmConfigSpec{
+				ClusterConfiguration: &kubeadmv1.ClusterConfiguration{
+					APIServer: kubeadmv1.APIServer{
+						ControlPlaneComponent: kubeadmv1.ControlPlaneComponent{ExtraArgs: map[string]string{"a": "1", "b": "2"}},
+					},
+				},
+				JoinConfiguration: &kubeadmv1.JoinConfiguration{
+					NodeRegistration: kubeadmv1.NodeRegistrationOptions{KubeletExtraArgs: map[string]string{"cloud-provider": "aws"}},
+				},
+				PreKubeadmCommands: []string{"echo hello"},
+			},
+		},
+	}
+	template := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"instanceType": "large",
+					"volumes":      []interface{}{map[string]interface{}{"size": int64(20)}},
+				},
+			},
+		},
+	}}
+
+	// joinedMachine returns a control plane machine that joined with the KubeadmControlPlane spec above,
+	// with the fields filled in by the bootstrap and infrastructure providers.
+	joinedMachine := func() (*clusterv1.Machine, *bootstrapv1.KubeadmConfig, *unstructured.Unstructured) {
+		machine := &clusterv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "joined",
+				Annotations: map[string]string{
+					// Keys in a different order than they are marshalled in.
+					controlplanev1.KubeadmClusterConfigurationAnnotation: `{"apiServer":{"extraArgs":{"b":"2","a":"1"}}}`,
+				},
+			},
+			Spec: clusterv1.MachineSpec{Version: &kcp.Spec.Version},
+		}
+		kubeadmConfigSpec := kcp.Spec.KubeadmConfigSpec.DeepCopy()
+		kubeadmConfigSpec.ClusterConfiguration = nil
+		kubeadmConfigSpec.JoinConfiguration.TypeMeta = metav1.TypeMeta{APIVersion: "kubeadm.k8s.io/v1beta1", Kind: "JoinConfiguration"}
+		kubeadmConfigSpec.JoinConfiguration.ControlPlane = &kubeadmv1.JoinControlPlane{}
+		kubeadmConfigSpec.JoinConfiguration.Discovery.BootstrapToken = &kubeadmv1.BootstrapTokenDiscovery{Token: "abcdef.0123456789abcdef"}
+		infraMachine := &unstructured.Unstructured{Object: map[string]interface{}{
+			"spec": map[string]interface{}{
+				"instanceType": "large",
+				"volumes":      []interface{}{map[string]interface{}{"size": int64(20), "type": "gp2"}},
+				"providerID":   "test://joined",
+			},
+		}}
+		return machine, &bootstrapv1.KubeadmConfig{Spec: *kubeadmConfigSpec}, infraMachine
+	}
+
+	tests := []struct {
+		name     string
+		mutate   func(*clusterv1.Machine, *bootstrapv1.KubeadmConfig, *unstructured.Unstructured)
+		expected bool
+	}{
+		{
+			name:     "fields filled in by the providers are ignored",
+			mutate:   func(*clusterv1.Machine, *bootstrapv1.KubeadmConfig, *unstructured.Unstructured) {},
+			expected: true,
+		},
+		{
+			name: "first machine is compared on its InitConfiguration",
+			mutate: func(_ *clusterv1.Machine, kubeadmConfig *bootstrapv1.KubeadmConfig, _ *unstructured.Unstructured) {
+				kubeadmConfig.Spec.JoinConfiguration = nil
+				kubeadmConfig.Spec.InitConfiguration = &kubeadmv1.InitConfiguration{
+					TypeMeta: metav1.TypeMeta{APIVersion: "kubeadm.k8s.io/v1beta1", Kind: "InitConfiguration"},
+				}
+				kubeadmConfig.Spec.ClusterConfiguration = &kubeadmv1.ClusterConfiguration{ClusterName: "filled-in"}
+			},
+			expected: true,
+		},
+		{
+			name: "different Kubernetes version",
+			mutate: func(machine *clusterv1.Machine, _ *bootstrapv1.KubeadmConfig, _ *unstructured.Unstructured) {
+				version := "v1.17.0"
+				machine.Spec.Version = &version
+			},
+		},
+		{
+			name: "different ClusterConfiguration",
+			mutate: func(machine *clusterv1.Machine, _ *bootstrapv1.KubeadmConfig, _ *unstructured.Unstructured) {
+				machine.Annotations[controlplanev1.KubeadmClusterConfigurationAnnotation] = `{"apiServer":{"extraArgs":{"a":"1"}}}`
+			},
+		},
+		{
+			name: "invalid recorded ClusterConfiguration",
+			mutate: func(machine *clusterv1.Machine, _ *bootstrapv1.KubeadmConfig, _ *unstructured.Unstructured) {
+				machine.Annotations[controlplanev1.KubeadmClusterConfigurationAnnotation] = `{`
+			},
+		},
+		{
+			name: "different JoinConfiguration",
+			mutate: func(_ *clusterv1.Machine, kubeadmConfig *bootstrapv1.KubeadmConfig, _ *unstructured.Unstructured) {
+				kubeadmConfig.Spec.JoinConfiguration.NodeRegistration.KubeletExtraArgs = nil
+			},
+		},
+		{
+			name: "different commands",
+			mutate: func(_ *clusterv1.Machine, kubeadmConfig *bootstrapv1.KubeadmConfig, _ *unstructured.Unstructured) {
+				kubeadmConfig.Spec.PreKubeadmCommands = nil
+			},
+		},
+		{
+			name: "different infrastructure machine",
+			mutate: func(_ *clusterv1.Machine, _ *bootstrapv1.KubeadmConfig, infraMachine *unstructured.Unstructured) {
+				_ = unstructured.SetNestedField(infraMachine.Object, "small", "spec", "instanceType")
+			},
+		},
+		{
+			name: "different infrastructure machine list item",
+			mutate: func(_ *clusterv1.Machine, _ *bootstrapv1.KubeadmConfig, infraMachine *unstructured.Unstructured) {
+				_ = unstructured.SetNestedSlice(infraMachine.Object, []interface{}{map[string]interface{}{"size": int64(10)}}, "spec", "volumes")
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			machine, kubeadmConfig, infraMachine := joinedMachine()
+			test.mutate(machine, kubeadmConfig, infraMachine)
+			configs := MachineConfigs{
+				KubeadmConfigs: map[string]*bootstrapv1.KubeadmConfig{machine.Name: kubeadmConfig},
+				InfraMachines:  map[string]*unstructured.Unstructured{machine.Name: infraMachine},
+				InfraTemplate:  template,
+			}
+			if actual := MatchesKCPConfiguration(kcp, configs)(machine); actual != test.expected {
+				t.Fatalf("expected MatchesKCPConfiguration to be %t but got %t", test.expected, actual)
+			}
+			if actual := HasOutdatedKCPConfiguration(kcp, configs)(machine); actual == test.expected {
+				t.Fatalf("expected HasOutdatedKCPConfiguration to be %t but got %t", !test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestMatchesKCPConfigurationFallsBackToHash(t *testing.T) {
+	kcp := &controlplanev1.KubeadmControlPlane{Spec: controlplanev1.KubeadmControlPlaneSpec{Version: "v1.17.2"}}
+	machine := func(configHash string) *clusterv1.Machine {
+		return &clusterv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "legacy",
+				Labels: map[string]string{controlplanev1.KubeadmControlPlaneHashLabelKey: configHash},
+			},
+			Spec: clusterv1.MachineSpec{Version: &kcp.Spec.Version},
+		}
+	}
+	// The machine has no recorded ClusterConfiguration.
+	configs := MachineConfigs{
+		KubeadmConfigs: map[string]*bootstrapv1.KubeadmConfig{"legacy": {}},
+		InfraMachines:  map[string]*unstructured.Unstructured{"legacy": {Object: map[string]interface{}{}}},
+		InfraTemplate:  &unstructured.Unstructured{Object: map[string]interface{}{}},
+	}
+
+	if !MatchesKCPConfiguration(kcp, configs)(machine(hash.Compute(&kcp.Spec))) {
+		t.Fatal("expected a machine with the current configuration hash to match")
+	}
+	if MatchesKCPConfiguration(kcp, configs)(machine("outdated")) {
+		t.Fatal("expected a machine with an outdated configuration hash not to match")
+	}
+	if MatchesKCPConfiguration(kcp, configs)(nil) {
+		t.Fatal("expected a nil machine not to match")
+	}
+}
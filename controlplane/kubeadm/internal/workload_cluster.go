@@ -0,0 +1,115 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internal
+
+import (
+	"context"
+	"io"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	kubeadmv1beta1 "sigs.k8s.io/cluster-api/bootstrap/kubeadm/types/v1beta1"
+)
+
+// WorkloadCluster defines all behaviors necessary to operate on a single workload cluster, once it was looked up
+// through the ManagementCluster.
+type WorkloadCluster interface {
+	// UpdateKubeadmConfigMap sets the Kubernetes version, and the image repositories and etcd image tag of the given
+	// ClusterConfiguration, in the kubeadm-config ConfigMap.
+	UpdateKubeadmConfigMap(ctx context.Context, version string, clusterConfiguration *kubeadmv1beta1.ClusterConfiguration) error
+	// UpdateKubeProxyImage sets the image tag of the kube-proxy DaemonSet to the given Kubernetes version.
+	UpdateKubeProxyImage(ctx context.Context, version string) error
+	// UpdateCoreDNS upgrades CoreDNS to the image set in the DNS settings of the given ClusterConfiguration.
+	UpdateCoreDNS(ctx context.Context, clusterConfiguration *kubeadmv1beta1.ClusterConfiguration) error
+	// CanSafelyRemoveEtcdMember reports whether the etcd member of the given node can be removed without losing quorum.
+	CanSafelyRemoveEtcdMember(ctx context.Context, nodeName string) (bool, error)
+	// ForwardEtcdLeadership moves the etcd leadership away from the member of the given node.
+	ForwardEtcdLeadership(ctx context.Context, nodeName string) error
+	// RemoveEtcdMemberForNode removes the etcd member of the given node from the etcd cluster.
+	RemoveEtcdMemberForNode(ctx context.Context, nodeName string) error
+	// RecoverEtcdNoSpaceAlarms defragments the etcd members that raised a NOSPACE alarm, disarms their alarms and
+	// returns them.
+	RecoverEtcdNoSpaceAlarms(ctx context.Context) ([]EtcdAlarm, error)
+	// DefragmentEtcd defragments the etcd members one at a time, and returns the names of their nodes.
+	DefragmentEtcd(ctx context.Context) ([]string, error)
+	// SnapshotEtcd streams a snapshot of the etcd keyspace to w.
+	SnapshotEtcd(ctx context.Context, w io.Writer) error
+}
+
+var _ WorkloadCluster = &cluster{}
+
+// GetWorkloadCluster returns the workload cluster of the given Cluster. It is built the same way, and shares the same
+// cache, as the workload clusters the other operations of the management cluster run against.
+func (m *Management) GetWorkloadCluster(ctx context.Context, clusterKey types.NamespacedName) (WorkloadCluster, error) {
+	cluster, err := m.getCluster(ctx, clusterKey)
+	if err != nil {
+		return nil, err
+	}
+	return cluster, nil
+}
+
+// UpdateKubeadmConfigMap implements WorkloadCluster.
+func (c *cluster) UpdateKubeadmConfigMap(ctx context.Context, version string, clusterConfiguration *kubeadmv1beta1.ClusterConfiguration) error {
+	return c.updateKubeadmConfigMap(ctx, version, clusterConfiguration)
+}
+
+// UpdateKubeProxyImage implements WorkloadCluster.
+func (c *cluster) UpdateKubeProxyImage(ctx context.Context, version string) error {
+	return c.updateKubeProxyImage(ctx, version)
+}
+
+// UpdateCoreDNS implements WorkloadCluster. Nothing is done if the ClusterConfiguration sets no CoreDNS image tag.
+func (c *cluster) UpdateCoreDNS(ctx context.Context, clusterConfiguration *kubeadmv1beta1.ClusterConfiguration) error {
+	if !requestsCoreDNSUpdate(clusterConfiguration) {
+		return nil
+	}
+	return c.updateCoreDNS(ctx, clusterConfiguration)
+}
+
+// CanSafelyRemoveEtcdMember implements WorkloadCluster.
+func (c *cluster) CanSafelyRemoveEtcdMember(ctx context.Context, nodeName string) (bool, error) {
+	members, healthy, err := c.etcdMembersHealth(ctx)
+	if err != nil {
+		return false, err
+	}
+	return canSafelyRemoveEtcdMember(members, healthy, nodeName), nil
+}
+
+// ForwardEtcdLeadership implements WorkloadCluster.
+func (c *cluster) ForwardEtcdLeadership(ctx context.Context, nodeName string) error {
+	return c.forwardEtcdLeadership(ctx, nodeName)
+}
+
+// RemoveEtcdMemberForNode implements WorkloadCluster.
+func (c *cluster) RemoveEtcdMemberForNode(ctx context.Context, nodeName string) error {
+	return c.removeEtcdMemberForNode(ctx, nodeName)
+}
+
+// RecoverEtcdNoSpaceAlarms implements WorkloadCluster.
+func (c *cluster) RecoverEtcdNoSpaceAlarms(ctx context.Context) ([]EtcdAlarm, error) {
+	return c.recoverEtcdNoSpaceAlarms(ctx)
+}
+
+// DefragmentEtcd implements WorkloadCluster.
+func (c *cluster) DefragmentEtcd(ctx context.Context) ([]string, error) {
+	return c.defragmentEtcd(ctx)
+}
+
+// SnapshotEtcd implements WorkloadCluster.
+func (c *cluster) SnapshotEtcd(ctx context.Context, w io.Writer) error {
+	return c.snapshotEtcd(ctx, w)
+}
@@ -0,0 +1,48 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internal
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestGetWorkloadCluster(t *testing.T) {
+	clusterKey := types.NamespacedName{Namespace: "my-namespace", Name: "my-cluster"}
+
+	t.Run("returns the cached workload cluster", func(t *testing.T) {
+		workloadCluster := &cluster{client: &fakeClient{}}
+		m := managementClusterForTest(clusterKey, workloadCluster)
+
+		workload, err := m.GetWorkloadCluster(context.Background(), clusterKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if workload != workloadCluster {
+			t.Fatal("expected the cached workload cluster to be returned")
+		}
+	})
+
+	t.Run("fails without a kubeconfig", func(t *testing.T) {
+		m := &Management{Client: &fakeClient{}}
+		if _, err := m.GetWorkloadCluster(context.Background(), clusterKey); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
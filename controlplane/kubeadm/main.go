@@ -24,14 +24,17 @@ import (
 	"time"
 
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 	"k8s.io/klog"
 	"k8s.io/klog/klogr"
 	clusterv1alpha3 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	kubeadmbootstrapv1alpha3 "sigs.k8s.io/cluster-api/bootstrap/kubeadm/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/controllers/remote"
 	kubeadmcontrolplanev1alpha3 "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
 	kubeadmcontrolplanecontrollers "sigs.k8s.io/cluster-api/controlplane/kubeadm/controllers"
+	"sigs.k8s.io/cluster-api/controlplane/kubeadm/webhooks"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -62,6 +65,15 @@ var (
 	kubeadmControlPlaneConcurrency int
 	syncPeriod                     time.Duration
 	webhookPort                    int
+	pingWorkloadAPIServer          bool
+	healthCheckCacheTTL            time.Duration
+	discoverNodesFromMachines      bool
+	healthCheckTimeout             time.Duration
+	healthCheckNodeTimeout         time.Duration
+	healthCheckRetries             int
+	healthCheckRetryInterval       time.Duration
+	etcdDialTimeout                time.Duration
+	etcdDefragmentationInterval    time.Duration
 )
 
 func main() {
@@ -86,6 +98,33 @@ func main() {
 	flag.IntVar(&webhookPort, "webhook-port", 0,
 		"Webhook Server port, disabled by default. When enabled, the manager will only work as webhook server, no reconcilers are installed.")
 
+	flag.BoolVar(&pingWorkloadAPIServer, "ping-workload-apiserver", false,
+		"Check that the workload cluster API server is reachable before running control plane health checks.")
+
+	flag.DurationVar(&healthCheckCacheTTL, "health-check-cache-ttl", 0,
+		"How long the result of a control plane health check is reused before querying the workload cluster again (e.g. 5s). Results are not cached if zero.")
+
+	flag.BoolVar(&discoverNodesFromMachines, "discover-nodes-from-machines", false,
+		"Fall back to the nodes referenced by control plane Machines when no workload cluster node has the control plane role label.")
+
+	flag.DurationVar(&healthCheckTimeout, "health-check-timeout", 0,
+		"How long a control plane or etcd health check of a workload cluster may take, retries included (e.g. 2m). Health checks are not bounded if zero.")
+
+	flag.DurationVar(&healthCheckNodeTimeout, "health-check-node-timeout", 0,
+		"How long health checks wait for a single control plane node (e.g. 30s). Defaults to 20s if zero.")
+
+	flag.IntVar(&healthCheckRetries, "health-check-retries", 0,
+		"Number of times a failed control plane or etcd health check is retried before it fails the preflight checks.")
+
+	flag.DurationVar(&healthCheckRetryInterval, "health-check-retry-interval", time.Second,
+		"How long to wait before the first retry of a failed health check. The interval doubles with every retry.")
+
+	flag.DurationVar(&etcdDialTimeout, "etcd-dial-timeout", 0,
+		"How long to wait for a connection to an etcd member of a workload cluster through the pod proxy (e.g. 10s). Client defaults are used if zero.")
+
+	flag.DurationVar(&etcdDefragmentationInterval, "etcd-defragmentation-interval", 0,
+		"How often to defragment the stacked etcd members of each control plane, one at a time (e.g. 168h). Etcd is only defragmented on request if zero.")
+
 	flag.Parse()
 
 	ctrl.SetLogger(klogr.New())
@@ -128,9 +167,24 @@ func setupReconcilers(mgr ctrl.Manager) {
 		return
 	}
 
+	tracker := remote.NewClusterCacheTracker(ctrl.Log.WithName("remote").WithName("ClusterCacheTracker"), mgr.GetClient(), mgr.GetScheme())
 	if err := (&kubeadmcontrolplanecontrollers.KubeadmControlPlaneReconciler{
-		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("KubeadmControlPlane"),
+		Client:                    mgr.GetClient(),
+		Log:                       ctrl.Log.WithName("controllers").WithName("KubeadmControlPlane"),
+		PingWorkloadAPIServer:     pingWorkloadAPIServer,
+		HealthCheckCacheTTL:       healthCheckCacheTTL,
+		DiscoverNodesFromMachines: discoverNodesFromMachines,
+		HealthCheckTimeout:        healthCheckTimeout,
+		HealthCheckNodeTimeout:    healthCheckNodeTimeout,
+		HealthCheckRetryBackoff: wait.Backoff{
+			Steps:    healthCheckRetries + 1,
+			Duration: healthCheckRetryInterval,
+			Factor:   2,
+			Jitter:   0.1,
+		},
+		EtcdDialTimeout:             etcdDialTimeout,
+		EtcdDefragmentationInterval: etcdDefragmentationInterval,
+		Tracker:                     tracker,
 	}).SetupWithManager(mgr, concurrency(kubeadmControlPlaneConcurrency)); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "KubeadmControlPlane")
 		os.Exit(1)
@@ -146,6 +200,10 @@ func setupWebhooks(mgr ctrl.Manager) {
 		setupLog.Error(err, "unable to create webhook", "webhook", "KubeadmControlPlane")
 		os.Exit(1)
 	}
+	if err := (&webhooks.ScaleValidator{Client: mgr.GetClient()}).SetupWebhookWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "KubeadmControlPlane scale")
+		os.Exit(1)
+	}
 }
 
 func concurrency(c int) controller.Options {
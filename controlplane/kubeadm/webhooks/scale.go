@@ -0,0 +1,83 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	controlplanev1 "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
+)
+
+// ScaleValidatorPath is the path the scale validating webhook is served on.
+const ScaleValidatorPath = "/validate-scale-controlplane-cluster-x-k8s-io-v1alpha3-kubeadmcontrolplane"
+
+// +kubebuilder:webhook:verbs=update,path=/validate-scale-controlplane-cluster-x-k8s-io-v1alpha3-kubeadmcontrolplane,mutating=false,failurePolicy=fail,groups=controlplane.cluster.x-k8s.io,resources=kubeadmcontrolplanes/scale,versions=v1alpha3,name=validation-scale.kubeadmcontrolplane.controlplane.cluster.x-k8s.io
+
+// ScaleValidator validates the replicas set through the scale subresource of a KubeadmControlPlane, which the
+// KubeadmControlPlane validating webhook does not see, the same way as replicas set in its spec.
+type ScaleValidator struct {
+	Client  client.Client
+	decoder *admission.Decoder
+}
+
+var _ admission.Handler = &ScaleValidator{}
+var _ admission.DecoderInjector = &ScaleValidator{}
+
+// SetupWebhookWithManager registers the ScaleValidator with the webhook server of the manager.
+func (v *ScaleValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	mgr.GetWebhookServer().Register(ScaleValidatorPath, &webhook.Admission{Handler: v})
+	return nil
+}
+
+// InjectDecoder implements admission.DecoderInjector.
+func (v *ScaleValidator) InjectDecoder(decoder *admission.Decoder) error {
+	v.decoder = decoder
+	return nil
+}
+
+// Handle validates the Scale of a KubeadmControlPlane by validating the KubeadmControlPlane updated with its replicas.
+func (v *ScaleValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	scale := &autoscalingv1.Scale{}
+	if err := v.decoder.Decode(req, scale); err != nil {
+		return admission.Errored(http.StatusBadRequest, errors.Wrap(err, "failed to decode Scale"))
+	}
+
+	kcp := &controlplanev1.KubeadmControlPlane{}
+	if err := v.Client.Get(ctx, types.NamespacedName{Namespace: req.Namespace, Name: req.Name}, kcp); err != nil {
+		if apierrors.IsNotFound(err) {
+			return admission.Errored(http.StatusNotFound, err)
+		}
+		return admission.Errored(http.StatusInternalServerError, errors.Wrapf(err, "failed to get KubeadmControlPlane %s/%s", req.Namespace, req.Name))
+	}
+
+	scaled := kcp.DeepCopy()
+	scaled.Spec.Replicas = &scale.Spec.Replicas
+	if err := scaled.ValidateUpdate(kcp); err != nil {
+		return admission.Denied(err.Error())
+	}
+	return admission.Allowed("")
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/api/admission/v1beta1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	kubeadmv1beta1 "sigs.k8s.io/cluster-api/bootstrap/kubeadm/types/v1beta1"
+	controlplanev1 "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha3"
+)
+
+func TestScaleValidatorHandle(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(controlplanev1.AddToScheme(scheme.Scheme)).To(Succeed())
+	decoder, err := admission.NewDecoder(scheme.Scheme)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	stackedEtcd := &controlplanev1.KubeadmControlPlane{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "foo",
+			Name:      "stacked",
+		},
+		Spec: controlplanev1.KubeadmControlPlaneSpec{
+			Replicas: pointer.Int32Ptr(3),
+			InfrastructureTemplate: corev1.ObjectReference{
+				Namespace: "foo",
+				Name:      "infraTemplate",
+			},
+		},
+	}
+	externalEtcd := stackedEtcd.DeepCopy()
+	externalEtcd.Name = "external"
+	externalEtcd.Spec.KubeadmConfigSpec.ClusterConfiguration = &kubeadmv1beta1.ClusterConfiguration{
+		Etcd: kubeadmv1beta1.Etcd{
+			External: &kubeadmv1beta1.ExternalEtcd{},
+		},
+	}
+
+	validator := &ScaleValidator{
+		Client: fake.NewFakeClientWithScheme(scheme.Scheme, stackedEtcd, externalEtcd),
+	}
+	g.Expect(validator.InjectDecoder(decoder)).To(Succeed())
+
+	tests := []struct {
+		name     string
+		kcp      string
+		replicas int32
+		allowed  bool
+	}{
+		{
+			name:     "should allow scaling to an odd number of replicas",
+			kcp:      stackedEtcd.Name,
+			replicas: 5,
+			allowed:  true,
+		},
+		{
+			name:     "should deny scaling to an even number of replicas when using stacked etcd",
+			kcp:      stackedEtcd.Name,
+			replicas: 4,
+			allowed:  false,
+		},
+		{
+			name:     "should deny scaling to zero replicas",
+			kcp:      stackedEtcd.Name,
+			replicas: 0,
+			allowed:  false,
+		},
+		{
+			name:     "should allow scaling to an even number of replicas when using external etcd",
+			kcp:      externalEtcd.Name,
+			replicas: 4,
+			allowed:  true,
+		},
+		{
+			name:     "should deny scaling a KubeadmControlPlane that does not exist",
+			kcp:      "missing",
+			replicas: 3,
+			allowed:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			scale := &autoscalingv1.Scale{
+				TypeMeta:   metav1.TypeMeta{APIVersion: autoscalingv1.SchemeGroupVersion.String(), Kind: "Scale"},
+				ObjectMeta: metav1.ObjectMeta{Namespace: "foo", Name: tt.kcp},
+				Spec:       autoscalingv1.ScaleSpec{Replicas: tt.replicas},
+			}
+			raw, err := json.Marshal(scale)
+			g.Expect(err).NotTo(HaveOccurred())
+
+			resp := validator.Handle(context.Background(), admission.Request{
+				AdmissionRequest: v1beta1.AdmissionRequest{
+					Operation: v1beta1.Update,
+					Namespace: "foo",
+					Name:      tt.kcp,
+					Object:    runtime.RawExtension{Raw: raw},
+				},
+			})
+			g.Expect(resp.Allowed).To(Equal(tt.allowed))
+		})
+	}
+}
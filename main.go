@@ -20,6 +20,7 @@ import (
 	"net/http"
 	_ "net/http/pprof"
 	"os"
+	"strings"
 	"time"
 
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
@@ -36,6 +37,7 @@ import (
 	clusterv1alpha2 "sigs.k8s.io/cluster-api/api/v1alpha2"
 	clusterv1alpha3 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	"sigs.k8s.io/cluster-api/controllers"
+	"sigs.k8s.io/cluster-api/controllers/remote"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	// +kubebuilder:scaffold:imports
 )
@@ -45,18 +47,26 @@ var (
 	setupLog = ctrl.Log.WithName("setup")
 
 	// flags
-	metricsAddr                  string
-	enableLeaderElection         bool
-	watchNamespace               string
-	profilerAddress              string
-	clusterConcurrency           int
-	machineConcurrency           int
-	machineSetConcurrency        int
-	machineDeploymentConcurrency int
-	machinePoolConcurrency       int
-	syncPeriod                   time.Duration
-	webhookPort                  int
-	healthAddr                   string
+	metricsAddr                   string
+	enableLeaderElection          bool
+	watchNamespace                string
+	profilerAddress               string
+	clusterConcurrency            int
+	machineConcurrency            int
+	machineSetConcurrency         int
+	machineDeploymentConcurrency  int
+	machinePoolConcurrency        int
+	machineHealthCheckConcurrency int
+	clusterResourceSetConcurrency int
+	nodeDrainAttemptTimeout       time.Duration
+	retiredNodeDrainTimeout       time.Duration
+	retiredNodeDrainAttempts      int
+	machinePoolWatchNodes         bool
+	machineWatchNodes             bool
+	unavailableNodeTaintKeys      string
+	syncPeriod                    time.Duration
+	webhookPort                   int
+	healthAddr                    string
 )
 
 func init() {
@@ -97,6 +107,30 @@ func main() {
 	flag.IntVar(&machinePoolConcurrency, "machinepool-concurrency", 10,
 		"Number of machine pools to process simultaneously")
 
+	flag.IntVar(&machineHealthCheckConcurrency, "machinehealthcheck-concurrency", 10,
+		"Number of machine health checks to process simultaneously")
+
+	flag.IntVar(&clusterResourceSetConcurrency, "clusterresourceset-concurrency", 10,
+		"Number of cluster resource sets to process simultaneously")
+
+	flag.DurationVar(&nodeDrainAttemptTimeout, "machine-node-drain-attempt-timeout", 20*time.Second,
+		"Maximum time a single attempt at draining the Node of a deleted machine waits for its pods to be evicted, before it is retried")
+
+	flag.DurationVar(&retiredNodeDrainTimeout, "machinepool-retired-node-drain-timeout", 0,
+		"Maximum time a single attempt at draining a Node retired from a machine pool may take, disabled by default. When disabled, retired Nodes are deleted without being drained.")
+
+	flag.IntVar(&retiredNodeDrainAttempts, "machinepool-retired-node-drain-attempts", 3,
+		"Number of attempts at draining a Node retired from a machine pool before it is deleted regardless")
+
+	flag.BoolVar(&machineWatchNodes, "machine-watch-nodes", false,
+		"Watch the Nodes of every workload cluster with a machine so that NodeRefs are assigned as soon as the Nodes register, instead of polling for them. This adds a watch per workload cluster.")
+
+	flag.BoolVar(&machinePoolWatchNodes, "machinepool-watch-nodes", false,
+		"Watch the Nodes of every workload cluster with a machine pool so that Node readiness changes are reflected promptly. This adds a watch per workload cluster.")
+
+	flag.StringVar(&unavailableNodeTaintKeys, "machinepool-unavailable-node-taint-keys", "",
+		"Comma separated keys of taints that keep a machine pool Node from counting as available, e.g. taints its workloads do not tolerate. Taints are ignored by default.")
+
 	flag.DurationVar(&syncPeriod, "sync-period", 10*time.Minute,
 		"The minimum interval at which watched resources are reconciled (e.g. 15m)")
 
@@ -161,16 +195,23 @@ func setupReconcilers(mgr ctrl.Manager) {
 	if webhookPort != 0 {
 		return
 	}
+	// The caches of the workload clusters are shared by the controllers watching their Nodes.
+	tracker := remote.NewClusterCacheTracker(ctrl.Log.WithName("remote").WithName("ClusterCacheTracker"), mgr.GetClient(), mgr.GetScheme())
+
 	if err := (&controllers.ClusterReconciler{
-		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("Cluster"),
+		Client:  mgr.GetClient(),
+		Log:     ctrl.Log.WithName("controllers").WithName("Cluster"),
+		Tracker: tracker,
 	}).SetupWithManager(mgr, concurrency(clusterConcurrency)); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Cluster")
 		os.Exit(1)
 	}
 	if err := (&controllers.MachineReconciler{
-		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("Machine"),
+		Client:                  mgr.GetClient(),
+		Log:                     ctrl.Log.WithName("controllers").WithName("Machine"),
+		NodeDrainAttemptTimeout: nodeDrainAttemptTimeout,
+		WatchWorkloadNodes:      machineWatchNodes,
+		Tracker:                 tracker,
 	}).SetupWithManager(mgr, concurrency(machineConcurrency)); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Machine")
 		os.Exit(1)
@@ -190,12 +231,33 @@ func setupReconcilers(mgr ctrl.Manager) {
 		os.Exit(1)
 	}
 	if err := (&controllers.MachinePoolReconciler{
-		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("MachinePool"),
+		Client:                      mgr.GetClient(),
+		Log:                         ctrl.Log.WithName("controllers").WithName("MachinePool"),
+		RetiredNodeDrainTimeout:     retiredNodeDrainTimeout,
+		RetiredNodeMaxDrainAttempts: retiredNodeDrainAttempts,
+		WatchWorkloadNodes:          machinePoolWatchNodes,
+		Tracker:                     tracker,
+		UnavailableNodeTaintKeys:    splitFlagList(unavailableNodeTaintKeys),
 	}).SetupWithManager(mgr, concurrency(machinePoolConcurrency)); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "MachinePool")
 		os.Exit(1)
 	}
+	if err := (&controllers.MachineHealthCheckReconciler{
+		Client:  mgr.GetClient(),
+		Log:     ctrl.Log.WithName("controllers").WithName("MachineHealthCheck"),
+		Tracker: tracker,
+	}).SetupWithManager(mgr, concurrency(machineHealthCheckConcurrency)); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "MachineHealthCheck")
+		os.Exit(1)
+	}
+	if err := (&controllers.ClusterResourceSetReconciler{
+		Client:  mgr.GetClient(),
+		Log:     ctrl.Log.WithName("controllers").WithName("ClusterResourceSet"),
+		Tracker: tracker,
+	}).SetupWithManager(mgr, concurrency(clusterResourceSetConcurrency)); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ClusterResourceSet")
+		os.Exit(1)
+	}
 }
 
 func setupWebhooks(mgr ctrl.Manager) {
@@ -263,12 +325,33 @@ func setupWebhooks(mgr ctrl.Manager) {
 		setupLog.Error(err, "unable to create webhook", "webhook", "MachinePool")
 		os.Exit(1)
 	}
+
+	if err := (&clusterv1alpha3.MachineHealthCheck{}).SetupWebhookWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "MachineHealthCheck")
+		os.Exit(1)
+	}
+
+	if err := (&clusterv1alpha3.ClusterResourceSet{}).SetupWebhookWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "ClusterResourceSet")
+		os.Exit(1)
+	}
 }
 
 func concurrency(c int) controller.Options {
 	return controller.Options{MaxConcurrentReconciles: c}
 }
 
+// splitFlagList splits a comma separated flag value, dropping empty items.
+func splitFlagList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 // newClientFunc returns a client reads from cache and write directly to the server
 // this avoid get unstructured object directly from the server
 // see issue: https://github.com/kubernetes-sigs/cluster-api/issues/1663
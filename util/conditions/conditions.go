@@ -0,0 +1,137 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package conditions implements helpers to read and set the conditions of Cluster API objects.
+// Conditions have a positive polarity, i.e. True is the expected state, and only False conditions have a severity,
+// so that consumers can tell what is merely in progress from what requires attention.
+package conditions
+
+import (
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+)
+
+// Getter is an object that has conditions.
+type Getter interface {
+	GetConditions() clusterv1.Conditions
+}
+
+// Setter is an object whose conditions can be set.
+type Setter interface {
+	Getter
+	SetConditions(clusterv1.Conditions)
+}
+
+// Get returns the condition with the given type, or nil if the object does not have it.
+func Get(from Getter, t clusterv1.ConditionType) *clusterv1.Condition {
+	for _, condition := range from.GetConditions() {
+		if condition.Type == t {
+			return condition.DeepCopy()
+		}
+	}
+	return nil
+}
+
+// Has returns true if the object has a condition with the given type.
+func Has(from Getter, t clusterv1.ConditionType) bool {
+	return Get(from, t) != nil
+}
+
+// IsTrue returns true if the condition with the given type is set to True.
+func IsTrue(from Getter, t clusterv1.ConditionType) bool {
+	if c := Get(from, t); c != nil {
+		return c.Status == corev1.ConditionTrue
+	}
+	return false
+}
+
+// IsFalse returns true if the condition with the given type is set to False.
+func IsFalse(from Getter, t clusterv1.ConditionType) bool {
+	if c := Get(from, t); c != nil {
+		return c.Status == corev1.ConditionFalse
+	}
+	return false
+}
+
+// Set sets the given condition on the object, replacing the condition with the same type if any.
+// The last transition time is only updated when the status of the condition changes, and the conditions are kept
+// sorted by type so that setting them in a different order does not change the object.
+func Set(to Setter, condition *clusterv1.Condition) {
+	if condition == nil {
+		return
+	}
+
+	if existing := Get(to, condition.Type); existing != nil && existing.Status == condition.Status {
+		condition.LastTransitionTime = existing.LastTransitionTime
+	} else if condition.LastTransitionTime.IsZero() {
+		condition.LastTransitionTime = metav1.Now()
+	}
+
+	conditions := clusterv1.Conditions{*condition}
+	for _, existing := range to.GetConditions() {
+		if existing.Type != condition.Type {
+			conditions = append(conditions, existing)
+		}
+	}
+	sort.Slice(conditions, func(i, j int) bool {
+		return conditions[i].Type < conditions[j].Type
+	})
+	to.SetConditions(conditions)
+}
+
+// MarkTrue sets the condition with the given type to True.
+func MarkTrue(to Setter, t clusterv1.ConditionType) {
+	Set(to, &clusterv1.Condition{
+		Type:   t,
+		Status: corev1.ConditionTrue,
+	})
+}
+
+// MarkFalse sets the condition with the given type to False, with the given reason, severity and message.
+func MarkFalse(to Setter, t clusterv1.ConditionType, reason string, severity clusterv1.ConditionSeverity, messageFormat string, messageArgs ...interface{}) {
+	Set(to, &clusterv1.Condition{
+		Type:     t,
+		Status:   corev1.ConditionFalse,
+		Reason:   reason,
+		Severity: severity,
+		Message:  fmt.Sprintf(messageFormat, messageArgs...),
+	})
+}
+
+// MarkUnknown sets the condition with the given type to Unknown, with the given reason and message.
+func MarkUnknown(to Setter, t clusterv1.ConditionType, reason string, messageFormat string, messageArgs ...interface{}) {
+	Set(to, &clusterv1.Condition{
+		Type:    t,
+		Status:  corev1.ConditionUnknown,
+		Reason:  reason,
+		Message: fmt.Sprintf(messageFormat, messageArgs...),
+	})
+}
+
+// Delete removes the condition with the given type from the object, if any.
+func Delete(to Setter, t clusterv1.ConditionType) {
+	conditions := make(clusterv1.Conditions, 0, len(to.GetConditions()))
+	for _, condition := range to.GetConditions() {
+		if condition.Type != t {
+			conditions = append(conditions, condition)
+		}
+	}
+	to.SetConditions(conditions)
+}
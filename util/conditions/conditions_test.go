@@ -0,0 +1,102 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conditions
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+)
+
+func TestSet(t *testing.T) {
+	g := NewWithT(t)
+
+	machine := &clusterv1.Machine{}
+	MarkFalse(machine, clusterv1.InfrastructureReadyCondition, clusterv1.WaitingForInfrastructureReason, clusterv1.ConditionSeverityInfo, "waiting for %s", "infra")
+	MarkTrue(machine, clusterv1.BootstrapReadyCondition)
+
+	g.Expect(machine.Status.Conditions).To(HaveLen(2))
+	g.Expect(machine.Status.Conditions[0].Type).To(Equal(clusterv1.BootstrapReadyCondition))
+	g.Expect(machine.Status.Conditions[1].Type).To(Equal(clusterv1.InfrastructureReadyCondition))
+
+	infrastructureReady := Get(machine, clusterv1.InfrastructureReadyCondition)
+	g.Expect(infrastructureReady.Status).To(Equal(corev1.ConditionFalse))
+	g.Expect(infrastructureReady.Reason).To(Equal(clusterv1.WaitingForInfrastructureReason))
+	g.Expect(infrastructureReady.Severity).To(Equal(clusterv1.ConditionSeverityInfo))
+	g.Expect(infrastructureReady.Message).To(Equal("waiting for infra"))
+	g.Expect(infrastructureReady.LastTransitionTime.IsZero()).To(BeFalse())
+
+	g.Expect(IsTrue(machine, clusterv1.BootstrapReadyCondition)).To(BeTrue())
+	g.Expect(IsFalse(machine, clusterv1.InfrastructureReadyCondition)).To(BeTrue())
+	g.Expect(Get(machine, clusterv1.NodeHealthyCondition)).To(BeNil())
+	g.Expect(IsTrue(machine, clusterv1.NodeHealthyCondition)).To(BeFalse())
+	g.Expect(IsFalse(machine, clusterv1.NodeHealthyCondition)).To(BeFalse())
+}
+
+func TestSetLastTransitionTime(t *testing.T) {
+	g := NewWithT(t)
+
+	transition := metav1.NewTime(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	machine := &clusterv1.Machine{
+		Status: clusterv1.MachineStatus{
+			Conditions: clusterv1.Conditions{
+				{
+					Type:               clusterv1.NodeHealthyCondition,
+					Status:             corev1.ConditionFalse,
+					Reason:             clusterv1.WaitingForNodeRefReason,
+					LastTransitionTime: transition,
+				},
+			},
+		},
+	}
+
+	// The last transition time is kept when only the reason changes.
+	MarkFalse(machine, clusterv1.NodeHealthyCondition, clusterv1.NodeNotReadyReason, clusterv1.ConditionSeverityWarning, "")
+	nodeHealthy := Get(machine, clusterv1.NodeHealthyCondition)
+	g.Expect(nodeHealthy.Reason).To(Equal(clusterv1.NodeNotReadyReason))
+	g.Expect(nodeHealthy.LastTransitionTime).To(Equal(transition))
+
+	// The last transition time is updated when the status changes.
+	MarkTrue(machine, clusterv1.NodeHealthyCondition)
+	nodeHealthy = Get(machine, clusterv1.NodeHealthyCondition)
+	g.Expect(nodeHealthy.Status).To(Equal(corev1.ConditionTrue))
+	g.Expect(nodeHealthy.Reason).To(BeEmpty())
+	g.Expect(nodeHealthy.Severity).To(Equal(clusterv1.ConditionSeverityNone))
+	g.Expect(nodeHealthy.LastTransitionTime.After(transition.Time)).To(BeTrue())
+	g.Expect(machine.Status.Conditions).To(HaveLen(1))
+}
+
+func TestDelete(t *testing.T) {
+	g := NewWithT(t)
+
+	machine := &clusterv1.Machine{}
+	MarkTrue(machine, clusterv1.BootstrapReadyCondition)
+	MarkTrue(machine, clusterv1.InfrastructureReadyCondition)
+
+	Delete(machine, clusterv1.BootstrapReadyCondition)
+	g.Expect(machine.Status.Conditions).To(HaveLen(1))
+	g.Expect(Get(machine, clusterv1.BootstrapReadyCondition)).To(BeNil())
+	g.Expect(IsTrue(machine, clusterv1.InfrastructureReadyCondition)).To(BeTrue())
+
+	// Deleting a missing condition is a no-op.
+	Delete(machine, clusterv1.NodeHealthyCondition)
+	g.Expect(machine.Status.Conditions).To(HaveLen(1))
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conditions
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+)
+
+// MergeOption configures how SetSummary and SetAggregate merge conditions.
+type MergeOption func(*mergeOptions)
+
+type mergeOptions struct {
+	conditionTypes []clusterv1.ConditionType
+	stepCounter    bool
+}
+
+// WithConditions limits the summary to the conditions with the given types, in that order; the conditions the object
+// does not have are left out. By default, all the conditions of the object but the Ready one are summarized.
+func WithConditions(types ...clusterv1.ConditionType) MergeOption {
+	return func(o *mergeOptions) {
+		o.conditionTypes = types
+	}
+}
+
+// WithStepCounter replaces the message of the merged condition, while it is not True, with the number of merged
+// conditions that are True, e.g. "2 of 3 completed", for conditions that track successive steps.
+func WithStepCounter() MergeOption {
+	return func(o *mergeOptions) {
+		o.stepCounter = true
+	}
+}
+
+// SetSummary sets the Ready condition of the object to the summary of its other conditions: it is False, with the
+// reason, severity and message of the most severe False condition, if any condition is False, Unknown if any
+// condition is Unknown, and True otherwise. Nothing is set if there is no condition to summarize.
+func SetSummary(to Setter, options ...MergeOption) {
+	o := &mergeOptions{}
+	for _, opt := range options {
+		opt(o)
+	}
+
+	var conditions []*clusterv1.Condition
+	if o.conditionTypes == nil {
+		for _, c := range to.GetConditions() {
+			if c.Type != clusterv1.ReadyCondition {
+				conditions = append(conditions, c.DeepCopy())
+			}
+		}
+	} else {
+		for _, t := range o.conditionTypes {
+			if c := Get(to, t); c != nil {
+				conditions = append(conditions, c)
+			}
+		}
+	}
+
+	if summary := merge(conditions, clusterv1.ReadyCondition, o); summary != nil {
+		Set(to, summary)
+	}
+}
+
+// SetAggregate sets the condition with the given type to the merge of the Ready conditions of the source objects,
+// e.g. of the Machines of a control plane, following the same rules as SetSummary. The source objects without a
+// Ready condition are left out.
+func SetAggregate(to Setter, targetCondition clusterv1.ConditionType, from []Getter, options ...MergeOption) {
+	o := &mergeOptions{}
+	for _, opt := range options {
+		opt(o)
+	}
+
+	var conditions []*clusterv1.Condition
+	for _, source := range from {
+		if c := Get(source, clusterv1.ReadyCondition); c != nil {
+			conditions = append(conditions, c)
+		}
+	}
+
+	if aggregate := merge(conditions, targetCondition, o); aggregate != nil {
+		Set(to, aggregate)
+	}
+}
+
+// merge returns the condition with the given type that merges the given conditions, or nil if there is none.
+func merge(conditions []*clusterv1.Condition, t clusterv1.ConditionType, o *mergeOptions) *clusterv1.Condition {
+	if len(conditions) == 0 {
+		return nil
+	}
+
+	var top *clusterv1.Condition
+	trueCount := 0
+	for _, c := range conditions {
+		if c.Status == corev1.ConditionTrue {
+			trueCount++
+		}
+		if top == nil || mergePriority(c) < mergePriority(top) {
+			top = c
+		}
+	}
+
+	merged := &clusterv1.Condition{
+		Type:   t,
+		Status: top.Status,
+	}
+	if top.Status != corev1.ConditionTrue {
+		merged.Reason = top.Reason
+		merged.Severity = top.Severity
+		merged.Message = top.Message
+		if o.stepCounter {
+			merged.Message = fmt.Sprintf("%d of %d completed", trueCount, len(conditions))
+		}
+	}
+	return merged
+}
+
+// mergePriority returns the priority of a condition when merging, the lowest first: the False conditions by
+// decreasing severity, then the Unknown ones, then the True ones.
+func mergePriority(c *clusterv1.Condition) int {
+	switch c.Status {
+	case corev1.ConditionFalse:
+		switch c.Severity {
+		case clusterv1.ConditionSeverityError:
+			return 0
+		case clusterv1.ConditionSeverityWarning:
+			return 1
+		default:
+			return 2
+		}
+	case corev1.ConditionTrue:
+		return 4
+	default:
+		return 3
+	}
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conditions
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+)
+
+func TestSetSummary(t *testing.T) {
+	tests := []struct {
+		name     string
+		setup    func(m *clusterv1.Machine)
+		options  []MergeOption
+		expected *clusterv1.Condition
+	}{
+		{
+			name:  "no conditions",
+			setup: func(m *clusterv1.Machine) {},
+		},
+		{
+			name: "all conditions are true",
+			setup: func(m *clusterv1.Machine) {
+				MarkTrue(m, clusterv1.BootstrapReadyCondition)
+				MarkTrue(m, clusterv1.InfrastructureReadyCondition)
+			},
+			expected: &clusterv1.Condition{Type: clusterv1.ReadyCondition, Status: corev1.ConditionTrue},
+		},
+		{
+			name: "the most severe false condition wins",
+			setup: func(m *clusterv1.Machine) {
+				MarkFalse(m, clusterv1.BootstrapReadyCondition, clusterv1.WaitingForDataSecretReason, clusterv1.ConditionSeverityInfo, "waiting")
+				MarkFalse(m, clusterv1.InfrastructureReadyCondition, clusterv1.InfrastructureDeletedReason, clusterv1.ConditionSeverityError, "deleted")
+				MarkUnknown(m, clusterv1.NodeHealthyCondition, clusterv1.NodeInspectionFailedReason, "unknown")
+			},
+			expected: &clusterv1.Condition{
+				Type:     clusterv1.ReadyCondition,
+				Status:   corev1.ConditionFalse,
+				Severity: clusterv1.ConditionSeverityError,
+				Reason:   clusterv1.InfrastructureDeletedReason,
+				Message:  "deleted",
+			},
+		},
+		{
+			name: "unknown conditions win over true ones",
+			setup: func(m *clusterv1.Machine) {
+				MarkTrue(m, clusterv1.BootstrapReadyCondition)
+				MarkUnknown(m, clusterv1.NodeHealthyCondition, clusterv1.NodeInspectionFailedReason, "unknown")
+			},
+			expected: &clusterv1.Condition{
+				Type:    clusterv1.ReadyCondition,
+				Status:  corev1.ConditionUnknown,
+				Reason:  clusterv1.NodeInspectionFailedReason,
+				Message: "unknown",
+			},
+		},
+		{
+			name: "only the given conditions are summarized",
+			setup: func(m *clusterv1.Machine) {
+				MarkTrue(m, clusterv1.BootstrapReadyCondition)
+				MarkFalse(m, clusterv1.DrainingSucceededCondition, clusterv1.DrainingFailedReason, clusterv1.ConditionSeverityWarning, "")
+			},
+			options:  []MergeOption{WithConditions(clusterv1.BootstrapReadyCondition, clusterv1.InfrastructureReadyCondition)},
+			expected: &clusterv1.Condition{Type: clusterv1.ReadyCondition, Status: corev1.ConditionTrue},
+		},
+		{
+			name: "the ready condition is not summarized",
+			setup: func(m *clusterv1.Machine) {
+				MarkFalse(m, clusterv1.ReadyCondition, clusterv1.WaitingForDataSecretReason, clusterv1.ConditionSeverityInfo, "")
+				MarkTrue(m, clusterv1.BootstrapReadyCondition)
+			},
+			expected: &clusterv1.Condition{Type: clusterv1.ReadyCondition, Status: corev1.ConditionTrue},
+		},
+		{
+			name: "with a step counter",
+			setup: func(m *clusterv1.Machine) {
+				MarkTrue(m, clusterv1.BootstrapReadyCondition)
+				MarkFalse(m, clusterv1.InfrastructureReadyCondition, clusterv1.WaitingForInfrastructureReason, clusterv1.ConditionSeverityInfo, "waiting")
+			},
+			options: []MergeOption{WithStepCounter()},
+			expected: &clusterv1.Condition{
+				Type:     clusterv1.ReadyCondition,
+				Status:   corev1.ConditionFalse,
+				Severity: clusterv1.ConditionSeverityInfo,
+				Reason:   clusterv1.WaitingForInfrastructureReason,
+				Message:  "1 of 2 completed",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			machine := &clusterv1.Machine{}
+			tt.setup(machine)
+			SetSummary(machine, tt.options...)
+
+			ready := Get(machine, clusterv1.ReadyCondition)
+			if tt.expected == nil {
+				g.Expect(ready).To(BeNil())
+				return
+			}
+			g.Expect(ready).NotTo(BeNil())
+			ready.LastTransitionTime = tt.expected.LastTransitionTime
+			g.Expect(ready).To(Equal(tt.expected))
+		})
+	}
+}
+
+func TestSetAggregate(t *testing.T) {
+	g := NewWithT(t)
+
+	newMachine := func(setup func(m *clusterv1.Machine)) Getter {
+		m := &clusterv1.Machine{}
+		setup(m)
+		return m
+	}
+	machines := []Getter{
+		newMachine(func(m *clusterv1.Machine) { MarkTrue(m, clusterv1.ReadyCondition) }),
+		newMachine(func(m *clusterv1.Machine) {
+			MarkFalse(m, clusterv1.ReadyCondition, clusterv1.NodeNotReadyReason, clusterv1.ConditionSeverityWarning, "node is not ready")
+		}),
+		newMachine(func(m *clusterv1.Machine) {}),
+	}
+
+	cluster := &clusterv1.Cluster{}
+	SetAggregate(cluster, clusterv1.ControlPlaneReadyCondition, machines)
+
+	controlPlaneReady := Get(cluster, clusterv1.ControlPlaneReadyCondition)
+	g.Expect(controlPlaneReady).NotTo(BeNil())
+	g.Expect(controlPlaneReady.Status).To(Equal(corev1.ConditionFalse))
+	g.Expect(controlPlaneReady.Severity).To(Equal(clusterv1.ConditionSeverityWarning))
+	g.Expect(controlPlaneReady.Reason).To(Equal(clusterv1.NodeNotReadyReason))
+	g.Expect(controlPlaneReady.Message).To(Equal("node is not ready"))
+
+	// Nothing is aggregated from objects without a Ready condition.
+	SetAggregate(cluster, clusterv1.InfrastructureReadyCondition, machines[2:])
+	g.Expect(Has(cluster, clusterv1.InfrastructureReadyCondition)).To(BeFalse())
+}
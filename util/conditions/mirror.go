@@ -0,0 +1,68 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conditions
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+)
+
+// MirrorOption configures how SetMirror mirrors a condition.
+type MirrorOption func(*mirrorOptions)
+
+type mirrorOptions struct {
+	fallback *clusterv1.Condition
+}
+
+// WithFallbackValue sets the condition to set when the source object does not report a Ready condition, e.g. because
+// its provider does not implement conditions yet.
+func WithFallbackValue(fallbackValue bool, reason string, severity clusterv1.ConditionSeverity, messageFormat string, messageArgs ...interface{}) MirrorOption {
+	return func(o *mirrorOptions) {
+		if fallbackValue {
+			o.fallback = &clusterv1.Condition{Status: corev1.ConditionTrue}
+			return
+		}
+		o.fallback = &clusterv1.Condition{
+			Status:   corev1.ConditionFalse,
+			Reason:   reason,
+			Severity: severity,
+			Message:  fmt.Sprintf(messageFormat, messageArgs...),
+		}
+	}
+}
+
+// SetMirror sets the condition with the given type to a copy of the Ready condition of the source object, e.g. to
+// surface the Ready condition of an infrastructure object as the InfrastructureReady condition of its Cluster.
+// If the source object does not have a Ready condition, the fallback value is set, if any.
+func SetMirror(to Setter, targetCondition clusterv1.ConditionType, from Getter, options ...MirrorOption) {
+	o := &mirrorOptions{}
+	for _, opt := range options {
+		opt(o)
+	}
+
+	condition := Get(from, clusterv1.ReadyCondition)
+	if condition == nil {
+		if o.fallback == nil {
+			return
+		}
+		condition = o.fallback.DeepCopy()
+	}
+	condition.Type = targetCondition
+	Set(to, condition)
+}
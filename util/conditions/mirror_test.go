@@ -0,0 +1,107 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conditions
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+)
+
+func TestSetMirror(t *testing.T) {
+	newInfrastructure := func(conditions ...interface{}) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{Object: map[string]interface{}{}}
+		if conditions != nil {
+			u.Object["status"] = map[string]interface{}{"conditions": conditions}
+		}
+		return u
+	}
+
+	tests := []struct {
+		name     string
+		from     *unstructured.Unstructured
+		options  []MirrorOption
+		expected *clusterv1.Condition
+	}{
+		{
+			name: "mirrors the ready condition",
+			from: newInfrastructure(
+				map[string]interface{}{"type": "Ready", "status": "False", "severity": "Warning", "reason": "InstanceStopped", "message": "instance is stopped"},
+				map[string]interface{}{"type": "InstanceReady", "status": "True"},
+			),
+			expected: &clusterv1.Condition{
+				Type:     clusterv1.InfrastructureReadyCondition,
+				Status:   corev1.ConditionFalse,
+				Severity: clusterv1.ConditionSeverityWarning,
+				Reason:   "InstanceStopped",
+				Message:  "instance is stopped",
+			},
+		},
+		{
+			name: "does nothing without a ready condition",
+			from: newInfrastructure(),
+		},
+		{
+			name: "does nothing with conditions in another format",
+			from: &unstructured.Unstructured{Object: map[string]interface{}{
+				"status": map[string]interface{}{"conditions": "Ready"},
+			}},
+		},
+		{
+			name:    "sets the fallback value without a ready condition",
+			from:    newInfrastructure(),
+			options: []MirrorOption{WithFallbackValue(false, clusterv1.WaitingForInfrastructureReason, clusterv1.ConditionSeverityInfo, "%s is not ready", "infrastructure")},
+			expected: &clusterv1.Condition{
+				Type:     clusterv1.InfrastructureReadyCondition,
+				Status:   corev1.ConditionFalse,
+				Severity: clusterv1.ConditionSeverityInfo,
+				Reason:   clusterv1.WaitingForInfrastructureReason,
+				Message:  "infrastructure is not ready",
+			},
+		},
+		{
+			name: "prefers the ready condition to the fallback value",
+			from: newInfrastructure(
+				map[string]interface{}{"type": "Ready", "status": "True"},
+			),
+			options:  []MirrorOption{WithFallbackValue(false, clusterv1.WaitingForInfrastructureReason, clusterv1.ConditionSeverityInfo, "")},
+			expected: &clusterv1.Condition{Type: clusterv1.InfrastructureReadyCondition, Status: corev1.ConditionTrue},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			cluster := &clusterv1.Cluster{}
+			SetMirror(cluster, clusterv1.InfrastructureReadyCondition, UnstructuredGetter(tt.from), tt.options...)
+
+			infrastructureReady := Get(cluster, clusterv1.InfrastructureReadyCondition)
+			if tt.expected == nil {
+				g.Expect(infrastructureReady).To(BeNil())
+				return
+			}
+			g.Expect(infrastructureReady).NotTo(BeNil())
+			g.Expect(infrastructureReady.LastTransitionTime.IsZero()).To(BeFalse())
+			infrastructureReady.LastTransitionTime = tt.expected.LastTransitionTime
+			g.Expect(infrastructureReady).To(Equal(tt.expected))
+		})
+	}
+}
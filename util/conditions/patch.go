@@ -0,0 +1,152 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conditions
+
+import (
+	"github.com/pkg/errors"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+)
+
+// Patch is the list of changes made to the conditions of an object, which can be applied to a more recent version of
+// the object without overwriting the changes made to its other conditions in the meantime, e.g. by other controllers.
+type Patch []PatchOperation
+
+// PatchOperation is a change made to a condition.
+type PatchOperation struct {
+	Before *clusterv1.Condition
+	After  *clusterv1.Condition
+	Op     PatchOperationType
+}
+
+// PatchOperationType is the type of a PatchOperation.
+type PatchOperationType string
+
+const (
+	// AddConditionPatch is used when a condition was added.
+	AddConditionPatch PatchOperationType = "Add"
+
+	// ChangeConditionPatch is used when a condition was changed.
+	ChangeConditionPatch PatchOperationType = "Change"
+
+	// RemoveConditionPatch is used when a condition was removed.
+	RemoveConditionPatch PatchOperationType = "Remove"
+)
+
+// NewPatch returns the changes made to the conditions of an object between the before and after versions of it.
+// The last transition times of the conditions are not taken into account.
+func NewPatch(before Getter, after Getter) Patch {
+	var patch Patch
+
+	for _, afterCondition := range after.GetConditions() {
+		afterCondition := afterCondition
+		beforeCondition := Get(before, afterCondition.Type)
+		if beforeCondition == nil {
+			patch = append(patch, PatchOperation{Op: AddConditionPatch, After: &afterCondition})
+			continue
+		}
+		if !hasSameState(beforeCondition, &afterCondition) {
+			patch = append(patch, PatchOperation{Op: ChangeConditionPatch, Before: beforeCondition, After: &afterCondition})
+		}
+	}
+
+	for _, beforeCondition := range before.GetConditions() {
+		beforeCondition := beforeCondition
+		if !Has(after, beforeCondition.Type) {
+			patch = append(patch, PatchOperation{Op: RemoveConditionPatch, Before: &beforeCondition})
+		}
+	}
+	return patch
+}
+
+// ApplyOption configures how a Patch is applied.
+type ApplyOption func(*applyOptions)
+
+type applyOptions struct {
+	ownedConditions []clusterv1.ConditionType
+}
+
+// WithOwnedConditions sets the condition types owned by the caller, whose changes are applied even if they conflict
+// with the latest version of the object.
+func WithOwnedConditions(types ...clusterv1.ConditionType) ApplyOption {
+	return func(o *applyOptions) {
+		o.ownedConditions = types
+	}
+}
+
+func (o *applyOptions) isOwned(t clusterv1.ConditionType) bool {
+	for _, owned := range o.ownedConditions {
+		if owned == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply applies the patch to the latest version of an object. It returns an error if a condition of the patch was
+// also changed in the latest version, to another state, unless the condition is owned by the caller.
+func (p Patch) Apply(latest Setter, options ...ApplyOption) error {
+	o := &applyOptions{}
+	for _, opt := range options {
+		opt(o)
+	}
+
+	for _, op := range p {
+		switch op.Op {
+		case AddConditionPatch:
+			latestCondition := Get(latest, op.After.Type)
+			if latestCondition != nil && !hasSameState(latestCondition, op.After) && !o.isOwned(op.After.Type) {
+				return errors.Errorf("condition %q was added by another process to a different state", op.After.Type)
+			}
+			Set(latest, op.After.DeepCopy())
+		case ChangeConditionPatch:
+			latestCondition := Get(latest, op.After.Type)
+			if !o.isOwned(op.After.Type) {
+				if latestCondition == nil {
+					return errors.Errorf("condition %q was removed by another process", op.After.Type)
+				}
+				if !hasSameState(latestCondition, op.Before) && !hasSameState(latestCondition, op.After) {
+					return errors.Errorf("condition %q was changed by another process to a different state", op.After.Type)
+				}
+			}
+			Set(latest, op.After.DeepCopy())
+		case RemoveConditionPatch:
+			latestCondition := Get(latest, op.Before.Type)
+			if latestCondition == nil {
+				continue
+			}
+			if !hasSameState(latestCondition, op.Before) && !o.isOwned(op.Before.Type) {
+				return errors.Errorf("condition %q was changed by another process and cannot be removed", op.Before.Type)
+			}
+			Delete(latest, op.Before.Type)
+		}
+	}
+	return nil
+}
+
+// IsZero returns true if the patch has no changes.
+func (p Patch) IsZero() bool {
+	return len(p) == 0
+}
+
+// hasSameState returns true if the conditions have the same state, regardless of their last transition time.
+func hasSameState(c1, c2 *clusterv1.Condition) bool {
+	return c1.Type == c2.Type &&
+		c1.Status == c2.Status &&
+		c1.Severity == c2.Severity &&
+		c1.Reason == c2.Reason &&
+		c1.Message == c2.Message
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conditions
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+)
+
+func TestNewPatch(t *testing.T) {
+	g := NewWithT(t)
+
+	before := &clusterv1.Machine{}
+	MarkTrue(before, clusterv1.BootstrapReadyCondition)
+	MarkFalse(before, clusterv1.InfrastructureReadyCondition, clusterv1.WaitingForInfrastructureReason, clusterv1.ConditionSeverityInfo, "")
+	MarkTrue(before, clusterv1.DrainingSucceededCondition)
+
+	after := before.DeepCopy()
+	g.Expect(NewPatch(before, after).IsZero()).To(BeTrue())
+
+	MarkTrue(after, clusterv1.InfrastructureReadyCondition)
+	MarkFalse(after, clusterv1.NodeHealthyCondition, clusterv1.WaitingForNodeRefReason, clusterv1.ConditionSeverityInfo, "")
+	Delete(after, clusterv1.DrainingSucceededCondition)
+
+	patch := NewPatch(before, after)
+	g.Expect(patch).To(HaveLen(3))
+	ops := map[clusterv1.ConditionType]PatchOperationType{}
+	for _, op := range patch {
+		if op.After != nil {
+			ops[op.After.Type] = op.Op
+		} else {
+			ops[op.Before.Type] = op.Op
+		}
+	}
+	g.Expect(ops).To(Equal(map[clusterv1.ConditionType]PatchOperationType{
+		clusterv1.InfrastructureReadyCondition: ChangeConditionPatch,
+		clusterv1.NodeHealthyCondition:         AddConditionPatch,
+		clusterv1.DrainingSucceededCondition:   RemoveConditionPatch,
+	}))
+}
+
+func TestPatchApply(t *testing.T) {
+	before := &clusterv1.Machine{}
+	MarkFalse(before, clusterv1.InfrastructureReadyCondition, clusterv1.WaitingForInfrastructureReason, clusterv1.ConditionSeverityInfo, "")
+	MarkTrue(before, clusterv1.DrainingSucceededCondition)
+
+	after := before.DeepCopy()
+	MarkTrue(after, clusterv1.InfrastructureReadyCondition)
+	MarkTrue(after, clusterv1.BootstrapReadyCondition)
+	Delete(after, clusterv1.DrainingSucceededCondition)
+
+	patch := NewPatch(before, after)
+
+	t.Run("applies the changes and keeps the other ones of the latest version", func(t *testing.T) {
+		g := NewWithT(t)
+
+		latest := before.DeepCopy()
+		MarkFalse(latest, clusterv1.NodeHealthyCondition, clusterv1.NodeNotReadyReason, clusterv1.ConditionSeverityWarning, "")
+
+		g.Expect(patch.Apply(latest)).To(Succeed())
+		g.Expect(IsTrue(latest, clusterv1.InfrastructureReadyCondition)).To(BeTrue())
+		g.Expect(IsTrue(latest, clusterv1.BootstrapReadyCondition)).To(BeTrue())
+		g.Expect(Has(latest, clusterv1.DrainingSucceededCondition)).To(BeFalse())
+		g.Expect(IsFalse(latest, clusterv1.NodeHealthyCondition)).To(BeTrue())
+	})
+
+	t.Run("accepts the latest version having the same changes", func(t *testing.T) {
+		g := NewWithT(t)
+
+		latest := after.DeepCopy()
+		g.Expect(patch.Apply(latest)).To(Succeed())
+		g.Expect(latest.Status.Conditions).To(HaveLen(2))
+	})
+
+	t.Run("fails on conflicting changes", func(t *testing.T) {
+		g := NewWithT(t)
+
+		latest := before.DeepCopy()
+		MarkFalse(latest, clusterv1.InfrastructureReadyCondition, clusterv1.InfrastructureDeletedReason, clusterv1.ConditionSeverityError, "")
+		g.Expect(patch.Apply(latest)).NotTo(Succeed())
+
+		latest = before.DeepCopy()
+		MarkFalse(latest, clusterv1.BootstrapReadyCondition, clusterv1.WaitingForDataSecretReason, clusterv1.ConditionSeverityInfo, "")
+		g.Expect(patch.Apply(latest)).NotTo(Succeed())
+
+		latest = before.DeepCopy()
+		MarkFalse(latest, clusterv1.DrainingSucceededCondition, clusterv1.DrainingFailedReason, clusterv1.ConditionSeverityWarning, "")
+		g.Expect(patch.Apply(latest)).NotTo(Succeed())
+	})
+
+	t.Run("applies the changes to owned conditions on conflicts", func(t *testing.T) {
+		g := NewWithT(t)
+
+		latest := before.DeepCopy()
+		MarkFalse(latest, clusterv1.InfrastructureReadyCondition, clusterv1.InfrastructureDeletedReason, clusterv1.ConditionSeverityError, "")
+
+		g.Expect(patch.Apply(latest, WithOwnedConditions(clusterv1.InfrastructureReadyCondition))).To(Succeed())
+		g.Expect(Get(latest, clusterv1.InfrastructureReadyCondition).Status).To(Equal(corev1.ConditionTrue))
+	})
+}
@@ -0,0 +1,53 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conditions
+
+import (
+	"encoding/json"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+)
+
+// UnstructuredGetter returns a Getter for the conditions of an unstructured object, e.g. an object of a provider
+// referenced by a Cluster API object. The conditions are read from status.conditions, and are expected to follow
+// the Cluster API format; an object without conditions, or with conditions in another format, has none.
+func UnstructuredGetter(u *unstructured.Unstructured) Getter {
+	return &unstructuredWrapper{Unstructured: u}
+}
+
+type unstructuredWrapper struct {
+	*unstructured.Unstructured
+}
+
+// GetConditions returns the conditions from the status of the unstructured object.
+func (u *unstructuredWrapper) GetConditions() clusterv1.Conditions {
+	value, ok, err := unstructured.NestedFieldNoCopy(u.Object, "status", "conditions")
+	if err != nil || !ok {
+		return nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	conditions := clusterv1.Conditions{}
+	if err := json.Unmarshal(data, &conditions); err != nil {
+		return nil
+	}
+	return conditions
+}
@@ -37,6 +37,10 @@ import (
 
 var (
 	ErrDependentCertificateNotFound = errors.New("could not find secret ca")
+
+	// ErrCAPrivateKeyNotFound is returned when the cluster CA secret only holds the CA certificate, e.g. because the
+	// CA is managed outside of the management cluster, so no kubeconfig can be signed.
+	ErrCAPrivateKeyNotFound = errors.New("CA private key not found")
 )
 
 // FromSecret fetches the Kubeconfig for a Cluster.
@@ -128,7 +132,7 @@ func CreateSecretWithOwner(ctx context.Context, c client.Client, clusterName typ
 	if err != nil {
 		return errors.Wrap(err, "failed to decode private key")
 	} else if key == nil {
-		return errors.New("CA private key not found")
+		return ErrCAPrivateKeyNotFound
 	}
 
 	server := fmt.Sprintf("https://%s", endpoint)
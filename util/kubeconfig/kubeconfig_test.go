@@ -29,6 +29,7 @@ import (
 
 	. "github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/tools/clientcmd"
@@ -308,6 +309,44 @@ func TestCreateSecretWithOwner(t *testing.T) {
 	g.Expect(restClient.Host).To(Equal("https://localhost:6443"))
 }
 
+func TestCreateSecretWithOwnerWithoutCAKey(t *testing.T) {
+	g := NewWithT(t)
+
+	caKey, err := certs.NewPrivateKey()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	caCert, err := getTestCACert(caKey)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	caSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test1-ca",
+			Namespace: "test",
+		},
+		Data: map[string][]byte{
+			secret.TLSCrtDataName: certs.EncodeCertPEM(caCert),
+		},
+	}
+
+	c := fake.NewFakeClientWithScheme(setupScheme(), caSecret)
+
+	err = CreateSecretWithOwner(
+		context.Background(),
+		c,
+		client.ObjectKey{
+			Name:      "test1",
+			Namespace: "test",
+		},
+		"localhost:6443",
+		metav1.OwnerReference{},
+	)
+	g.Expect(err).To(Equal(ErrCAPrivateKeyNotFound))
+
+	s := &corev1.Secret{}
+	key := client.ObjectKey{Name: "test1-kubeconfig", Namespace: "test"}
+	g.Expect(apierrors.IsNotFound(c.Get(context.Background(), key, s))).To(BeTrue())
+}
+
 func TestCreateSecret(t *testing.T) {
 	g := NewWithT(t)
 
@@ -21,16 +21,36 @@ import (
 	"reflect"
 
 	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/util/retry"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// Option configures how the Helper patches a resource.
+type Option func(*helperOptions)
+
+type helperOptions struct {
+	ownedConditions []clusterv1.ConditionType
+}
+
+// WithOwnedConditions sets the condition types owned by the caller. Changes to these conditions are applied
+// even if another process changed them in the meantime, while changes to any other condition fail on conflict.
+func WithOwnedConditions(types ...clusterv1.ConditionType) Option {
+	return func(o *helperOptions) {
+		o.ownedConditions = types
+	}
+}
+
 // Helper is a utility for ensuring the proper Patching of resources
 // and their status
 type Helper struct {
 	client        client.Client
+	beforeObject  runtime.Object
 	before        map[string]interface{}
 	hasStatus     bool
 	beforeStatus  interface{}
@@ -72,6 +92,7 @@ func NewHelper(resource runtime.Object, crClient client.Client) (*Helper, error)
 
 	return &Helper{
 		client:        crClient,
+		beforeObject:  resource.DeepCopyObject(),
 		before:        before,
 		beforeStatus:  beforeStatus,
 		hasStatus:     hasStatus,
@@ -80,12 +101,22 @@ func NewHelper(resource runtime.Object, crClient client.Client) (*Helper, error)
 	}, nil
 }
 
-// Patch will attempt to patch the given resource and its status
-func (h *Helper) Patch(ctx context.Context, resource runtime.Object) error {
+// Patch will attempt to patch the given resource and its status.
+//
+// The resource and its status are patched separately. If the resource has conditions, the changes to
+// its conditions are merged condition by condition into the latest version of the resource, retrying
+// on conflict, so that controllers updating different conditions of the same resource don't overwrite
+// each other's changes.
+func (h *Helper) Patch(ctx context.Context, resource runtime.Object, opts ...Option) error {
 	if resource == nil {
 		return errors.Errorf("expected non-nil resource")
 	}
 
+	options := &helperOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
 	// If the object is already unstructured, we need to perform a deepcopy first
 	// because the `DefaultUnstructuredConverter.ToUnstructured` function returns
 	// the underlying unstructured object map without making a copy.
@@ -93,6 +124,18 @@ func (h *Helper) Patch(ctx context.Context, resource runtime.Object) error {
 		resource = resource.DeepCopyObject()
 	}
 
+	// If the object has conditions, compute the changes to its conditions and exclude them from
+	// the status patch; they are applied on top of the latest version of the object later.
+	var conditionsPatch conditions.Patch
+	if after, ok := resource.(conditions.Setter); ok {
+		before := h.beforeObject.(conditions.Getter)
+		conditionsPatch = conditions.NewPatch(before, after)
+		if !conditionsPatch.IsZero() {
+			resource = resource.DeepCopyObject()
+			resource.(conditions.Setter).SetConditions(before.GetConditions())
+		}
+	}
+
 	// Convert the resource to unstructured to compare against our before copy.
 	after, err := runtime.DefaultUnstructuredConverter.ToUnstructured(resource)
 	if err != nil {
@@ -130,5 +173,34 @@ func (h *Helper) Patch(ctx context.Context, resource runtime.Object) error {
 		}
 	}
 
+	if !conditionsPatch.IsZero() {
+		if err := h.patchStatusConditions(ctx, conditionsPatch, options); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
 	return kerrors.NewAggregate(errs)
 }
+
+// patchStatusConditions applies the conditions patch to the latest version of the resource and updates
+// its status, retrying if the resource changed in the meantime.
+func (h *Helper) patchStatusConditions(ctx context.Context, conditionsPatch conditions.Patch, options *helperOptions) error {
+	accessor, err := meta.Accessor(h.beforeObject)
+	if err != nil {
+		return err
+	}
+	key := client.ObjectKey{Namespace: accessor.GetNamespace(), Name: accessor.GetName()}
+
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		latest := h.beforeObject.DeepCopyObject()
+		if err := h.client.Get(ctx, key, latest); err != nil {
+			return err
+		}
+
+		if err := conditionsPatch.Apply(latest.(conditions.Setter), conditions.WithOwnedConditions(options.ownedConditions...)); err != nil {
+			return errors.Wrapf(err, "failed to apply conditions patch to %q", key)
+		}
+
+		return h.client.Status().Update(ctx, latest)
+	})
+}
@@ -31,6 +31,7 @@ import (
 
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	"sigs.k8s.io/cluster-api/controllers/external"
+	"sigs.k8s.io/cluster-api/util/conditions"
 )
 
 func TestHelperUnstructuredPatch(t *testing.T) {
@@ -245,3 +246,108 @@ func TestHelperPatch(t *testing.T) {
 		})
 	}
 }
+
+func TestHelperPatchConditions(t *testing.T) {
+	tests := []struct {
+		name            string
+		otherChanges    func(*clusterv1.Cluster)
+		changes         func(*clusterv1.Cluster)
+		options         []Option
+		wantErr         bool
+		wantConditions  []clusterv1.ConditionType
+		wantInfraStatus corev1.ConditionStatus
+	}{
+		{
+			name: "Changes to different conditions are merged",
+			otherChanges: func(c *clusterv1.Cluster) {
+				conditions.MarkTrue(c, clusterv1.ControlPlaneReadyCondition)
+			},
+			changes: func(c *clusterv1.Cluster) {
+				conditions.MarkTrue(c, clusterv1.InfrastructureReadyCondition)
+			},
+			wantConditions:  []clusterv1.ConditionType{clusterv1.ControlPlaneReadyCondition, clusterv1.InfrastructureReadyCondition},
+			wantInfraStatus: corev1.ConditionTrue,
+		},
+		{
+			name: "Changes to the same condition conflict",
+			otherChanges: func(c *clusterv1.Cluster) {
+				conditions.MarkFalse(c, clusterv1.InfrastructureReadyCondition, "Other", clusterv1.ConditionSeverityInfo, "")
+			},
+			changes: func(c *clusterv1.Cluster) {
+				conditions.MarkTrue(c, clusterv1.InfrastructureReadyCondition)
+			},
+			wantErr:         true,
+			wantConditions:  []clusterv1.ConditionType{clusterv1.InfrastructureReadyCondition},
+			wantInfraStatus: corev1.ConditionFalse,
+		},
+		{
+			name: "Changes to the same owned condition overwrite the other changes",
+			otherChanges: func(c *clusterv1.Cluster) {
+				conditions.MarkFalse(c, clusterv1.InfrastructureReadyCondition, "Other", clusterv1.ConditionSeverityInfo, "")
+			},
+			changes: func(c *clusterv1.Cluster) {
+				conditions.MarkTrue(c, clusterv1.InfrastructureReadyCondition)
+			},
+			options:         []Option{WithOwnedConditions(clusterv1.InfrastructureReadyCondition)},
+			wantConditions:  []clusterv1.ConditionType{clusterv1.InfrastructureReadyCondition},
+			wantInfraStatus: corev1.ConditionTrue,
+		},
+		{
+			name: "Changes to conditions and to other status fields are both patched",
+			otherChanges: func(c *clusterv1.Cluster) {
+				conditions.MarkTrue(c, clusterv1.ControlPlaneReadyCondition)
+			},
+			changes: func(c *clusterv1.Cluster) {
+				c.Status.InfrastructureReady = true
+				conditions.MarkTrue(c, clusterv1.InfrastructureReadyCondition)
+			},
+			wantConditions:  []clusterv1.ConditionType{clusterv1.ControlPlaneReadyCondition, clusterv1.InfrastructureReadyCondition},
+			wantInfraStatus: corev1.ConditionTrue,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			g.Expect(clusterv1.AddToScheme(scheme.Scheme)).To(Succeed())
+
+			ctx := context.Background()
+			cluster := &clusterv1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-cluster",
+					Namespace: "test-namespace",
+				},
+			}
+			fakeClient := fake.NewFakeClientWithScheme(scheme.Scheme, cluster)
+			key := client.ObjectKey{Namespace: "test-namespace", Name: "test-cluster"}
+
+			obj := &clusterv1.Cluster{}
+			g.Expect(fakeClient.Get(ctx, key, obj)).To(Succeed())
+
+			h, err := NewHelper(obj, fakeClient)
+			g.Expect(err).NotTo(HaveOccurred())
+
+			// Simulate another process changing the conditions after the helper has been created.
+			other := &clusterv1.Cluster{}
+			g.Expect(fakeClient.Get(ctx, key, other)).To(Succeed())
+			tt.otherChanges(other)
+			g.Expect(fakeClient.Status().Update(ctx, other)).To(Succeed())
+
+			tt.changes(obj)
+			err = h.Patch(ctx, obj, tt.options...)
+			if tt.wantErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+
+			after := &clusterv1.Cluster{}
+			g.Expect(fakeClient.Get(ctx, key, after)).To(Succeed())
+			for _, conditionType := range tt.wantConditions {
+				g.Expect(conditions.Has(after, conditionType)).To(BeTrue())
+			}
+			g.Expect(conditions.Get(after, clusterv1.InfrastructureReadyCondition).Status).To(Equal(tt.wantInfraStatus))
+			g.Expect(after.Status.InfrastructureReady).To(Equal(obj.Status.InfrastructureReady))
+		})
+	}
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package predicates
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestClusterPausedChanged(t *testing.T) {
+	paused := &clusterv1.Cluster{Spec: clusterv1.ClusterSpec{Paused: true}}
+	unpaused := &clusterv1.Cluster{}
+
+	tests := []struct {
+		name     string
+		old      *clusterv1.Cluster
+		new      *clusterv1.Cluster
+		expected bool
+	}{
+		{
+			name:     "cluster unpaused",
+			old:      paused,
+			new:      unpaused,
+			expected: true,
+		},
+		{
+			name:     "cluster paused",
+			old:      unpaused,
+			new:      paused,
+			expected: true,
+		},
+		{
+			name:     "cluster still paused",
+			old:      paused,
+			new:      paused.DeepCopy(),
+			expected: false,
+		},
+		{
+			name:     "cluster still unpaused",
+			old:      unpaused,
+			new:      unpaused.DeepCopy(),
+			expected: false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			e := event.UpdateEvent{
+				MetaOld:   tc.old,
+				ObjectOld: tc.old,
+				MetaNew:   tc.new,
+				ObjectNew: tc.new,
+			}
+			g.Expect(ClusterPausedChanged().Update(e)).To(Equal(tc.expected))
+		})
+	}
+
+	t.Run("other events", func(t *testing.T) {
+		g := NewWithT(t)
+
+		g.Expect(ClusterPausedChanged().Create(event.CreateEvent{Meta: paused, Object: paused})).To(BeFalse())
+		g.Expect(ClusterPausedChanged().Delete(event.DeleteEvent{Meta: paused, Object: paused})).To(BeFalse())
+		g.Expect(ClusterPausedChanged().Update(event.UpdateEvent{ObjectOld: &corev1.Node{}, ObjectNew: &corev1.Node{}})).To(BeFalse())
+	})
+}
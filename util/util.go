@@ -32,12 +32,15 @@ import (
 	v1 "k8s.io/api/core/v1"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/version"
 	"k8s.io/klog"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
@@ -299,6 +302,19 @@ func EnsureOwnerRef(ownerReferences []metav1.OwnerReference, ref metav1.OwnerRef
 	return ownerReferences
 }
 
+// RemoveOwnerRef returns the slice of owner references without the ones to the same object as the given reference,
+// regardless of their UID.
+func RemoveOwnerRef(ownerReferences []metav1.OwnerReference, ref metav1.OwnerReference) []metav1.OwnerReference {
+	refs := []metav1.OwnerReference{}
+	for _, r := range ownerReferences {
+		if r.APIVersion == ref.APIVersion && r.Kind == ref.Kind && r.Name == ref.Name {
+			continue
+		}
+		refs = append(refs, r)
+	}
+	return refs
+}
+
 // PointsTo returns true if any of the owner references point to the given target
 func PointsTo(refs []metav1.OwnerReference, target *metav1.ObjectMeta) bool {
 	for _, ref := range refs {
@@ -401,16 +417,87 @@ func HasOwner(refList []metav1.OwnerReference, apiVersion string, kinds []string
 
 // IsPaused returns true if the Cluster is paused or the object has the `paused` annotation.
 func IsPaused(cluster *clusterv1.Cluster, v metav1.Object) bool {
+	return PausedReason(cluster, v) != ""
+}
+
+// PausedReason returns why the reconciliation of the object is paused, i.e. clusterv1.ClusterPausedReason if the
+// Cluster is paused or clusterv1.PausedAnnotationReason if the object has the `paused` annotation, or an empty string
+// if it is not paused.
+func PausedReason(cluster *clusterv1.Cluster, v metav1.Object) string {
 	if cluster.Spec.Paused {
-		return true
+		return clusterv1.ClusterPausedReason
 	}
 
 	annotations := v.GetAnnotations()
 	if annotations == nil {
-		return false
+		return ""
+	}
+	if _, ok := annotations[clusterv1.PausedAnnotation]; ok {
+		return clusterv1.PausedAnnotationReason
+	}
+	return ""
+}
+
+// ConditionsObject is a Cluster API object with conditions.
+type ConditionsObject interface {
+	metav1.Object
+	conditions.Setter
+}
+
+// IsPausedWithCondition returns true if the Cluster is paused or the object has the `paused` annotation, like
+// IsPaused, and sets the Paused condition of the object accordingly: True while it is paused, and False once it is
+// no longer paused. Objects that were never paused do not get the condition.
+func IsPausedWithCondition(cluster *clusterv1.Cluster, obj ConditionsObject) bool {
+	reason := PausedReason(cluster, obj)
+	if reason != "" {
+		conditions.Set(obj, &clusterv1.Condition{
+			Type:    clusterv1.PausedCondition,
+			Status:  v1.ConditionTrue,
+			Reason:  reason,
+			Message: "Reconciliation is paused",
+		})
+		return true
+	}
+
+	if conditions.Get(obj, clusterv1.PausedCondition) != nil {
+		conditions.Set(obj, &clusterv1.Condition{
+			Type:   clusterv1.PausedCondition,
+			Status: v1.ConditionFalse,
+		})
+	}
+	return false
+}
+
+// ClusterToObjectsMapper returns a handler.ToRequestsFunc that maps a Cluster to the objects of the given list type,
+// e.g. &clusterv1.MachineList{}, labeled with the name of the Cluster in its namespace.
+func ClusterToObjectsMapper(c client.Client, list runtime.Object) handler.ToRequestsFunc {
+	return func(o handler.MapObject) []reconcile.Request {
+		cluster, ok := o.Object.(*clusterv1.Cluster)
+		if !ok {
+			return nil
+		}
+
+		objects := list.DeepCopyObject()
+		if err := c.List(context.TODO(), objects, client.InNamespace(cluster.Namespace), client.MatchingLabels{clusterv1.ClusterLabelName: cluster.Name}); err != nil {
+			return nil
+		}
+		items, err := meta.ExtractList(objects)
+		if err != nil {
+			return nil
+		}
+
+		requests := make([]reconcile.Request, 0, len(items))
+		for _, item := range items {
+			accessor, err := meta.Accessor(item)
+			if err != nil {
+				continue
+			}
+			requests = append(requests, reconcile.Request{
+				NamespacedName: client.ObjectKey{Namespace: accessor.GetNamespace(), Name: accessor.GetName()},
+			})
+		}
+		return requests
 	}
-	_, ok := annotations[clusterv1.PausedAnnotation]
-	return ok
 }
 
 // GetCRDWithContract retrieves a list of CustomResourceDefinitions from using controller-runtime Client,
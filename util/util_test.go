@@ -313,6 +313,51 @@ func TestPointsTo(t *testing.T) {
 	}
 }
 
+func TestRemoveOwnerRef(t *testing.T) {
+	cluster := metav1.OwnerReference{APIVersion: "cluster.x-k8s.io/v1alpha3", Kind: "Cluster", Name: "test-cluster", UID: "1"}
+	crs := metav1.OwnerReference{APIVersion: "cluster.x-k8s.io/v1alpha3", Kind: "ClusterResourceSet", Name: "test-crs", UID: "2"}
+
+	tests := []struct {
+		name     string
+		refs     []metav1.OwnerReference
+		ref      metav1.OwnerReference
+		expected []metav1.OwnerReference
+	}{
+		{
+			name:     "empty owner list",
+			ref:      crs,
+			expected: []metav1.OwnerReference{},
+		},
+		{
+			name:     "ref not in the list",
+			refs:     []metav1.OwnerReference{cluster},
+			ref:      crs,
+			expected: []metav1.OwnerReference{cluster},
+		},
+		{
+			name:     "ref in the list",
+			refs:     []metav1.OwnerReference{cluster, crs},
+			ref:      crs,
+			expected: []metav1.OwnerReference{cluster},
+		},
+		{
+			name:     "ref in the list with another UID",
+			refs:     []metav1.OwnerReference{cluster, crs},
+			ref:      metav1.OwnerReference{APIVersion: crs.APIVersion, Kind: crs.Kind, Name: crs.Name},
+			expected: []metav1.OwnerReference{cluster},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := RemoveOwnerRef(test.refs, test.ref)
+			if !reflect.DeepEqual(result, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, result)
+			}
+		})
+	}
+}
+
 func TestGetOwnerClusterSuccessByName(t *testing.T) {
 	scheme := runtime.NewScheme()
 	if err := clusterv1.AddToScheme(scheme); err != nil {
@@ -442,3 +487,108 @@ func TestGetMachinesForCluster(t *testing.T) {
 		t.Fatalf("expected list to have machine %v, found %v", machine, machines.Items[0])
 	}
 }
+
+func TestIsPausedWithCondition(t *testing.T) {
+	cluster := &clusterv1.Cluster{}
+	machine := &clusterv1.Machine{}
+
+	if IsPausedWithCondition(cluster, machine) {
+		t.Fatal("expected machine not to be paused")
+	}
+	if len(machine.Status.Conditions) != 0 {
+		t.Fatalf("expected machine never paused not to have conditions, found %v", machine.Status.Conditions)
+	}
+
+	machine.Annotations = map[string]string{clusterv1.PausedAnnotation: ""}
+	if !IsPausedWithCondition(cluster, machine) {
+		t.Fatal("expected machine with the paused annotation to be paused")
+	}
+	if c := machine.Status.Conditions; len(c) != 1 || c[0].Status != corev1.ConditionTrue || c[0].Reason != clusterv1.PausedAnnotationReason {
+		t.Fatalf("expected machine to have a True Paused condition, found %v", c)
+	}
+
+	machine.Annotations = nil
+	cluster.Spec.Paused = true
+	if !IsPausedWithCondition(cluster, machine) {
+		t.Fatal("expected machine of a paused cluster to be paused")
+	}
+	if c := machine.Status.Conditions; len(c) != 1 || c[0].Status != corev1.ConditionTrue || c[0].Reason != clusterv1.ClusterPausedReason {
+		t.Fatalf("expected machine to have a True Paused condition, found %v", c)
+	}
+
+	cluster.Spec.Paused = false
+	if IsPausedWithCondition(cluster, machine) {
+		t.Fatal("expected machine not to be paused")
+	}
+	if c := machine.Status.Conditions; len(c) != 1 || c[0].Status != corev1.ConditionFalse {
+		t.Fatalf("expected machine to have a False Paused condition, found %v", c)
+	}
+}
+
+func TestClusterToObjectsMapper(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clusterv1.AddToScheme(scheme); err != nil {
+		t.Fatal("failed to register cluster api objects to scheme")
+	}
+
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-cluster",
+			Namespace: "my-ns",
+		},
+	}
+
+	machine := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-machine",
+			Namespace: cluster.Namespace,
+			Labels: map[string]string{
+				clusterv1.ClusterLabelName: cluster.Name,
+			},
+		},
+	}
+
+	machineDifferentClusterNameSameNamespace := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "other-machine",
+			Namespace: cluster.Namespace,
+			Labels: map[string]string{
+				clusterv1.ClusterLabelName: "other-cluster",
+			},
+		},
+	}
+
+	machineSet := &clusterv1.MachineSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-machineset",
+			Namespace: cluster.Namespace,
+			Labels: map[string]string{
+				clusterv1.ClusterLabelName: cluster.Name,
+			},
+		},
+	}
+
+	c := fake.NewFakeClientWithScheme(
+		scheme,
+		machine,
+		machineDifferentClusterNameSameNamespace,
+		machineSet,
+	)
+
+	requests := ClusterToObjectsMapper(c, &clusterv1.MachineList{})(handler.MapObject{Object: cluster})
+	expected := []reconcile.Request{
+		{
+			NamespacedName: client.ObjectKey{
+				Namespace: machine.Namespace,
+				Name:      machine.Name,
+			},
+		},
+	}
+	if !reflect.DeepEqual(requests, expected) {
+		t.Fatalf("expected requests %v, found %v", expected, requests)
+	}
+
+	if requests := ClusterToObjectsMapper(c, &clusterv1.MachineList{})(handler.MapObject{Object: machine}); len(requests) != 0 {
+		t.Fatalf("expected no requests for objects other than clusters, found %v", requests)
+	}
+}